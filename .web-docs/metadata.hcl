@@ -1,60 +1,81 @@
-# Copyright (c) HashiCorp, Inc.
-# SPDX-License-Identifier: MPL-2.0
-
-# Details on using this Integration template can be found at https://github.com/hashicorp/integration-template
-# This metadata.hcl file and the adjacent `components` docs directory should
-# be kept in a `.web-docs` directory at the root of your plugin repository.
+# For full specification on the configuration of this file visit:
+# https://github.com/hashicorp/integration-template#metadata-configuration
 integration {
-  name = "Integration Template"
-  description = "This is an integration template"
-  identifier = "packer/hashicorp/scaffolding"
-  flags = [
-    # Remove if the plugin does not conform to the HCP Packer requirements.
-    #
-    # Please refer to our docs if you want your plugin to be compatible with
-    # HCP Packer: https://developer.hashicorp.com/packer/docs/plugins/creation/hcp-support
-    "hcp-ready",
-    # This signals that the plugin is unmaintained and will eventually not be
-    # working with a future version of Packer.
-    #
-    # On the integrations, this will end-up as an icon on the plugin's main card.
-    "archived",
-  ]
+  name = "VMware Cloud Director"
+  description = "Packer plugin for building vApp templates on VMware Cloud Director."
+  identifier = "packer/juanfont/vcd"
   docs {
-    # If you'd prefer not to publish docs on HashiCorp websites, you can
-    # set `process_docs` to `false`. If `process_docs` is `false`, you MUST
-    # provide a `external_url` so we can link back to your plugin repo.
     process_docs = true
-    # Note that the README location is relative to this file. We recommend
-    # keeping the default value, as the adjacent `compile-to-webdocs` script
-    # will automatically copy the README from the `docs` directory of this
-    # repository to the correct location.
     readme_location = "./README.md"
-    # `external_url` allows us to link back to your plugin repo.
-    external_url = "https://github.com/hashicorp/integration-template"
+    external_url = "https://github.com/juanfont/packer-plugin-vcd"
   }
   license {
     type = "MPL-2.0"
-    url = "https://github.com/hashicorp/integration-template/blob/main/LICENSE.md"
+    url = "https://github.com/juanfont/packer-plugin-vcd/blob/main/LICENSE"
   }
   component {
     type = "builder"
-    name = "Component Name (e.g HappyCloud EBS)"
-    slug = "name"
+    name = "VMware Cloud Director ISO"
+    slug = "vcd-iso"
   }
   component {
-    type = "provisioner"
-    name = "Component Name (e.g HappyCloud Shell)"
-    slug = "name"
+    type = "builder"
+    name = "VMware Cloud Director Clone"
+    slug = "vcd-clone"
+  }
+  component {
+    type = "builder"
+    name = "VMware Cloud Director OVF"
+    slug = "vcd-ovf"
+  }
+  component {
+    type = "builder"
+    name = "VMware Cloud Director Existing VM"
+    slug = "vcd-existing"
+  }
+  component {
+    type = "post-processor"
+    name = "VMware Cloud Director"
+    slug = "vcd"
+  }
+  component {
+    type = "post-processor"
+    name = "VMware Cloud Director Smoke Test"
+    slug = "vcd-smoke-test"
+  }
+  component {
+    type = "post-processor"
+    name = "VMware Cloud Director Share"
+    slug = "vcd-share"
   }
   component {
     type = "post-processor"
-    name = "Component Name"
-    slug = "name"
+    name = "VMware Cloud Director Metadata"
+    slug = "vcd-metadata"
+  }
+  component {
+    type = "data-source"
+    name = "VMware Cloud Director Network"
+    slug = "vcd-network"
+  }
+  component {
+    type = "data-source"
+    name = "VMware Cloud Director Storage Profile"
+    slug = "vcd-storage-profile"
+  }
+  component {
+    type = "data-source"
+    name = "VMware Cloud Director Sizing Policy"
+    slug = "vcd-sizing-policy"
+  }
+  component {
+    type = "data-source"
+    name = "VMware Cloud Director Placement Policy"
+    slug = "vcd-placement-policy"
   }
   component {
     type = "data-source"
-    name = "Component Name"
-    slug = "name"
+    name = "VMware Cloud Director vApp Template"
+    slug = "vcd-vapp-template"
   }
 }
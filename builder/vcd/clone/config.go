@@ -0,0 +1,119 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package clone
+
+import (
+	"fmt"
+
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/common"
+
+	packerCommon "github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+type Config struct {
+	packerCommon.PackerConfig `mapstructure:",squash"`
+
+	common.ConnectConfig     `mapstructure:",squash"`
+	CloneConfig              `mapstructure:",squash"`
+	common.LocationConfig    `mapstructure:",squash"`
+	common.HardwareConfig    `mapstructure:",squash"`
+	common.RunConfig         `mapstructure:",squash"`
+	common.WaitIpConfig      `mapstructure:",squash"`
+	common.PortForwardConfig `mapstructure:",squash"`
+	Comm                     communicator.Config `mapstructure:",squash"`
+
+	common.ProvisionSnapshotConfig `mapstructure:",squash"`
+
+	common.ShutdownConfig             `mapstructure:",squash"`
+	common.RemoveNetworkAdapterConfig `mapstructure:",squash"`
+
+	common.OVFPropertiesConfig `mapstructure:",squash"`
+
+	common.MetadataConfig `mapstructure:",squash"`
+
+	// Guest customization of the VM, and of the templates captured from it.
+	// It is not set if [guest customization configuration](#guest-customization-configuration)
+	// is not specified.
+	GuestCustomization *common.GuestCustomizationConfig `mapstructure:"guest_customization"`
+
+	// The configuration for exporting the virtual machine to an OVF.
+	// The virtual machine is not exported if [export configuration](#export-configuration) is not specified.
+	Export *common.ExportConfig `mapstructure:"export"`
+
+	// Export the virtual machine to a catalog.
+	// The virtual machine will not be exported if no [export to catalog configuration](#export-to-catalog-configuration) is specified.
+	ExportToCatalog *common.ExportToCatalogConfig `mapstructure:"export_to_catalog"`
+
+	ctx interpolate.Context
+}
+
+// Prepare processes and validates the configuration for cloning and exporting.
+// It returns a list of warnings and an error if validation fails.
+func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
+	err := config.Decode(c, &config.DecodeOpts{
+		PluginType:         common.BuilderId,
+		Interpolate:        true,
+		InterpolateContext: &c.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{
+				"ovf_properties",
+			},
+		},
+	}, raws...)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings := make([]string, 0)
+	errs := new(packersdk.MultiError)
+
+	errs = packersdk.MultiErrorAppend(errs, c.ConnectConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.CloneConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.LocationConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.HardwareConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.RunConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.WaitIpConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.PortForwardConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.MetadataConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.OVFPropertiesConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.Comm.Prepare(&c.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, c.ProvisionSnapshotConfig.Prepare()...)
+
+	shutdownWarnings, shutdownErrs := c.ShutdownConfig.Prepare(c.Comm)
+	warnings = append(warnings, shutdownWarnings...)
+	errs = packersdk.MultiErrorAppend(errs, shutdownErrs...)
+
+	if c.GuestCustomization != nil {
+		errs = packersdk.MultiErrorAppend(errs, c.GuestCustomization.Prepare()...)
+	}
+
+	if c.Export != nil {
+		errs = packersdk.MultiErrorAppend(errs, c.Export.Prepare(&c.ctx, &c.LocationConfig, &c.PackerConfig)...)
+		// VCD only serves downloads of vApp templates
+		if c.ExportToCatalog == nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'export' downloads the template captured by 'export_to_catalog', which must be set"))
+		}
+	}
+	if c.ExportToCatalog != nil {
+		errs = packersdk.MultiErrorAppend(errs, c.ExportToCatalog.Prepare(&c.LocationConfig)...)
+	}
+
+	// Checked last, as it needs a valid connection and location
+	if len(errs.Errors) == 0 {
+		errs = packersdk.MultiErrorAppend(errs, c.LocationConfig.CheckRemote(&c.ConnectConfig, &c.HardwareConfig)...)
+	}
+
+	if len(errs.Errors) > 0 {
+		return warnings, errs
+	}
+
+	return warnings, nil
+}
@@ -0,0 +1,28 @@
+package clone
+
+import "fmt"
+
+type CloneConfig struct {
+	// The name of the catalog that holds the source vApp template.
+	TemplateCatalog string `mapstructure:"template_catalog" required:"true"`
+	// The name of the vApp template to clone the virtual machine from.
+	Template string `mapstructure:"template" required:"true"`
+	// The name of the virtual machine inside the vApp template to clone.
+	// Defaults to the first virtual machine in the template.
+	TemplateVM string `mapstructure:"template_vm"`
+	// Description for the virtual machine.
+	Description string `mapstructure:"vm_description"`
+}
+
+func (c *CloneConfig) Prepare() []error {
+	var errs []error
+
+	if c.TemplateCatalog == "" {
+		errs = append(errs, fmt.Errorf("'template_catalog' is required"))
+	}
+	if c.Template == "" {
+		errs = append(errs, fmt.Errorf("'template' is required"))
+	}
+
+	return errs
+}
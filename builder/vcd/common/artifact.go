@@ -1,9 +1,12 @@
 package common
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+	"github.com/vmware/go-vcloud-director/v3/govcd"
 )
 
 const BuilderId = "vcd"
@@ -14,6 +17,9 @@ type Artifact struct {
 	VM        driver.VirtualMachine
 	StateData map[string]interface{}
 	Outconfig *string
+	// The vApp template captured by export_to_catalog, if any
+	Template        string
+	TemplateCatalog string
 }
 
 func (a *Artifact) BuilderId() string {
@@ -32,7 +38,33 @@ func (a *Artifact) Id() string {
 }
 
 func (a *Artifact) String() string {
-	return fmt.Sprintf("VCD VM: %s in vApp %s (VDC: %s)", a.Name, a.Location.VApp, a.Location.VDC)
+	s := fmt.Sprintf("VCD VM: %s in vApp %s (VDC: %s)", a.Name, a.Location.VApp, a.Location.VDC)
+	if a.Template != "" {
+		s += fmt.Sprintf(", captured as vApp template %s in catalog %s", a.Template, a.TemplateCatalog)
+	}
+	return s
+}
+
+// AddCapturedTemplate records the vApp template captured by
+// StepExportToCatalog, if any, in the artifact: its name and catalog, and
+// the IDs of the template and of its catalog item in the state data.
+func (a *Artifact) AddCapturedTemplate(state multistep.StateBag) {
+	template, ok := state.Get("vapp_template").(*govcd.VAppTemplate)
+	if !ok {
+		return
+	}
+	a.Template = template.VAppTemplate.Name
+	a.TemplateCatalog, _ = state.Get("vapp_template_catalog").(string)
+
+	if a.StateData == nil {
+		a.StateData = map[string]interface{}{}
+	}
+	a.StateData["vapp_template_catalog"] = a.TemplateCatalog
+	a.StateData["vapp_template"] = a.Template
+	a.StateData["vapp_template_id"] = template.VAppTemplate.ID
+	if id, err := template.GetCatalogItemId(); err == nil {
+		a.StateData["catalog_item_id"] = id
+	}
 }
 
 func (a *Artifact) State(name string) interface{} {
@@ -49,7 +81,7 @@ func (a *Artifact) Destroy() error {
 
 	// Power off if needed
 	if on, _ := a.VM.IsPoweredOn(); on {
-		if err := a.VM.PowerOff(); err != nil {
+		if err := a.VM.PowerOff(context.Background()); err != nil {
 			return fmt.Errorf("error powering off VM: %w", err)
 		}
 	}
@@ -0,0 +1,122 @@
+// Copyright 2025 Juan Font
+// BSD-3-Clause
+
+package common
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/diskfs/go-diskfs"
+)
+
+// bootLoaderConfigs are the boot loader configurations of Linux ISOs that
+// hold kernel command lines: isolinux for BIOS boots, and GRUB for UEFI
+// boots or, on recent Ubuntu ISOs, both
+var bootLoaderConfigs = []string{
+	"isolinux/isolinux.cfg",
+	"isolinux/txt.cfg",
+	"isolinux/adtxt.cfg",
+	"isolinux/gtk.cfg",
+	"syslinux/syslinux.cfg",
+	"syslinux/txt.cfg",
+	"boot/grub/grub.cfg",
+	"boot/grub/loopback.cfg",
+	"boot/grub2/grub.cfg",
+	"EFI/BOOT/grub.cfg",
+}
+
+// AddBootParameters appends params to every kernel command line of the
+// boot loader configurations of the ISO, and adds the edited files to the
+// modified ISO. Configurations added with AddContent are edited instead of
+// those of the ISO. Returns the paths of the edited files.
+func (m *ISOModifier) AddBootParameters(params string) ([]string, error) {
+	d, err := diskfs.Open(m.sourcePath, diskfs.WithOpenMode(diskfs.ReadOnly))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ISO: %w", err)
+	}
+	defer d.Backend.Close()
+
+	fs, err := d.GetFilesystem(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get filesystem: %w", err)
+	}
+
+	var edited []string
+	for _, path := range bootLoaderConfigs {
+		content, ok := m.files[path]
+		if !ok {
+			f, err := fs.OpenFile("/"+path, os.O_RDONLY)
+			if err != nil {
+				continue
+			}
+			content, err = io.ReadAll(f)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", path, err)
+			}
+		}
+
+		isGRUB := strings.HasSuffix(path, "grub.cfg") || strings.HasSuffix(path, "loopback.cfg")
+		cfg, n := appendKernelParameters(string(content), params, isGRUB)
+		if n == 0 {
+			continue
+		}
+		m.AddContent(path, []byte(cfg))
+		edited = append(edited, path)
+	}
+	if len(edited) == 0 {
+		return nil, fmt.Errorf("no kernel command line found in the boot loader configuration of the ISO for boot_parameters")
+	}
+	return edited, nil
+}
+
+// appendKernelParameters appends params to the kernel command lines of a
+// boot loader configuration: isolinux append lines, or GRUB linux lines.
+// They go before a `---` separator, whose arguments Debian installers
+// copy to the installed system. Returns the configuration and the number
+// of lines changed.
+func appendKernelParameters(cfg, params string, isGRUB bool) (string, int) {
+	if isGRUB {
+		// GRUB splits commands at unquoted semicolons, as in
+		// ds=nocloud;s=/cdrom/
+		params = strings.ReplaceAll(strings.ReplaceAll(params, `\;`, ";"), ";", `\;`)
+	}
+
+	lines := strings.Split(cfg, "\n")
+	n := 0
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToLower(fields[0]) {
+		case "append":
+			if isGRUB {
+				continue
+			}
+		case "linux", "linuxefi", "linux16":
+			if !isGRUB {
+				continue
+			}
+		default:
+			continue
+		}
+
+		cr := strings.HasSuffix(line, "\r")
+		line = strings.TrimRight(line, " \t\r")
+		if j := strings.Index(line, " ---"); j >= 0 {
+			line = line[:j] + " " + params + line[j:]
+		} else {
+			line += " " + params
+		}
+		if cr {
+			line += "\r"
+		}
+		lines[i] = line
+		n++
+	}
+	return strings.Join(lines, "\n"), n
+}
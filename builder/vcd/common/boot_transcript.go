@@ -0,0 +1,70 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const sensitiveMask = "<sensitive>"
+
+// bootTranscript records each boot command keygroup as it is typed into the
+// VM console, so the exact input can be replayed when debugging an installer.
+type bootTranscript struct {
+	vmName  string
+	secrets []string
+	started time.Time
+	lines   []string
+}
+
+func newBootTranscript(vmName string, secrets []string) *bootTranscript {
+	return &bootTranscript{
+		vmName:  vmName,
+		secrets: secrets,
+		started: time.Now(),
+	}
+}
+
+// Record adds a keygroup sent at the given time. A non-nil err marks the
+// keygroup as failed.
+func (t *bootTranscript) Record(group int, at time.Time, keys string, err error) {
+	line := fmt.Sprintf("[%s +%.3fs] group %d: %s",
+		at.UTC().Format(time.RFC3339Nano), at.Sub(t.started).Seconds(), group, t.mask(keys))
+	if err != nil {
+		line += fmt.Sprintf("\n    FAILED: %s", t.mask(err.Error()))
+	}
+	t.lines = append(t.lines, line)
+}
+
+// WriteFile writes the transcript to path. The file may still contain
+// sensitive values that were not declared as such, so it is only readable by
+// the current user.
+func (t *bootTranscript) WriteFile(path string) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Boot command transcript for VM %s\n", t.vmName)
+	fmt.Fprintf(&b, "# Started at %s\n", t.started.UTC().Format(time.RFC3339Nano))
+	for _, line := range t.lines {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	return os.WriteFile(path, []byte(b.String()), 0600)
+}
+
+// mask replaces every sensitive value in s.
+func (t *bootTranscript) mask(s string) string {
+	for _, secret := range t.secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, sensitiveMask)
+		}
+	}
+	return s
+}
@@ -0,0 +1,197 @@
+// Copyright 2025 Juan Font
+// BSD-3-Clause
+
+package common
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/common/udf"
+)
+
+// bootWIMPath is the Windows PE image Windows ISOs boot, whose second image
+// runs Windows Setup
+const bootWIMPath = "sources/boot.wim"
+
+var errWimlibNotFound = errors.New("wimlib-imagex not found in PATH, needed for boot_wim_content. Install it with: apt-get install wimtools")
+
+// CheckWimlib verifies that wimlib is installed
+func CheckWimlib() error {
+	if _, err := exec.LookPath("wimlib-imagex"); err != nil {
+		return errWimlibNotFound
+	}
+	return nil
+}
+
+// findBootWIM returns sources/boot.wim of a Windows ISO. UDF names are
+// matched case-insensitively.
+func findBootWIM(img *udf.Image) (*udf.File, error) {
+	var found *udf.File
+	errFound := errors.New("found")
+	err := img.Walk(func(f *udf.File) error {
+		if !f.IsDir && strings.EqualFold(f.Path, bootWIMPath) {
+			found = f
+			return errFound
+		}
+		return nil
+	})
+	if err != nil && err != errFound {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no %s found in the ISO for boot_wim_content", bootWIMPath)
+	}
+	return found, nil
+}
+
+// bootWIMSize returns the size of sources/boot.wim of the source ISO, or 0
+// when no boot.wim content was added
+func (m *ISOModifier) bootWIMSize() (int64, error) {
+	if len(m.wimFiles) == 0 {
+		return 0, nil
+	}
+	src, err := os.Open(m.sourcePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open ISO: %w", err)
+	}
+	defer src.Close()
+
+	img, err := udf.Open(src)
+	if err != nil {
+		return 0, fmt.Errorf("boot_wim_content needs a Windows (UDF) ISO: %w", err)
+	}
+	f, err := findBootWIM(img)
+	if err != nil {
+		return 0, err
+	}
+	return f.Size, nil
+}
+
+// writeBootWIM copies sources/boot.wim of the source ISO under dir, at its
+// path in the ISO, and writes the added boot.wim files into its boot image
+// with wimlib. Returns the path of boot.wim in the ISO, or an empty path
+// when no boot.wim files were added.
+func (m *ISOModifier) writeBootWIM(dir string) (string, error) {
+	if len(m.wimFiles) == 0 {
+		return "", nil
+	}
+	if err := CheckWimlib(); err != nil {
+		return "", err
+	}
+	if _, ok := m.files[bootWIMPath]; ok {
+		return "", fmt.Errorf("%s is replaced by cd_content or cd_files, and cannot take boot_wim_content", bootWIMPath)
+	}
+
+	src, err := os.Open(m.sourcePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open ISO: %w", err)
+	}
+	defer src.Close()
+	img, err := udf.Open(src)
+	if err != nil {
+		return "", fmt.Errorf("boot_wim_content needs a Windows (UDF) ISO: %w", err)
+	}
+	f, err := findBootWIM(img)
+	if err != nil {
+		return "", err
+	}
+
+	wim := filepath.Join(dir, f.Path)
+	if err := os.MkdirAll(filepath.Dir(wim), 0755); err != nil {
+		return "", err
+	}
+	dst, err := os.Create(wim)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, f.Open()); err != nil {
+		dst.Close()
+		return "", fmt.Errorf("failed to extract %s: %w", f.Path, err)
+	}
+	if err := dst.Close(); err != nil {
+		return "", err
+	}
+
+	// The boot index is the Windows Setup image, which Windows PE starts
+	out, err := runWimlib("info", wim)
+	if err != nil {
+		return "", err
+	}
+	index := 0
+	for _, line := range strings.Split(out, "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "Boot Index:"); ok {
+			index, _ = strconv.Atoi(strings.TrimSpace(value))
+			break
+		}
+	}
+	if index == 0 {
+		return "", fmt.Errorf("%s has no boot image", f.Path)
+	}
+
+	// The files are laid out in a directory of their own, added over the
+	// root of the image in one update
+	contentDir, err := os.MkdirTemp(dir, "packer-wim-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	if strings.ContainsRune(contentDir, '"') {
+		return "", fmt.Errorf("work directory %s cannot contain quotes for boot_wim_content", dir)
+	}
+	for path, content := range m.wimFiles {
+		local := filepath.Join(contentDir, path)
+		if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+			return "", fmt.Errorf("failed to create directory for %s: %w", path, err)
+		}
+		if err := os.WriteFile(local, content, 0644); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	command := fmt.Sprintf(`add "%s" /`, contentDir)
+	if _, err := runWimlib("update", wim, strconv.Itoa(index), "--command="+command); err != nil {
+		return "", fmt.Errorf("failed to write boot_wim_content to %s: %w", f.Path, err)
+	}
+
+	return f.Path, nil
+}
+
+// addBootWIMToUDF writes the added boot.wim files into a copy of boot.wim
+// under dir, and adds it to w in place of that of the source ISO
+func (m *ISOModifier) addBootWIMToUDF(w udfTree, dir string) error {
+	path, err := m.writeBootWIM(dir)
+	if err != nil || path == "" {
+		return err
+	}
+	local := filepath.Join(dir, path)
+	fi, err := os.Stat(local)
+	if err != nil {
+		return err
+	}
+	err = w.AddFile(path, fi.Size(), time.Now(), func() (io.Reader, error) {
+		return os.Open(local)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add file %s: %w", path, err)
+	}
+	return nil
+}
+
+// runWimlib runs wimlib-imagex and returns its output
+func runWimlib(args ...string) (string, error) {
+	cmd := exec.Command("wimlib-imagex", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("wimlib-imagex %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
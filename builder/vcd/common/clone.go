@@ -0,0 +1,42 @@
+// Copyright 2025 Juan Font
+// BSD-3-Clause
+
+package common
+
+import (
+	"io"
+	"os"
+)
+
+// canClone reports whether files in dir can be clones of src, sharing its
+// blocks. Cloning takes no space nor time worth mentioning, so this clones
+// src into a temporary file.
+func canClone(src, dir string) bool {
+	s, err := os.Open(src)
+	if err != nil {
+		return false
+	}
+	defer s.Close()
+
+	d, err := os.CreateTemp(dir, "packer-clone-")
+	if err != nil {
+		return false
+	}
+	defer os.Remove(d.Name())
+	defer d.Close()
+
+	return cloneFile(d, s) == nil
+}
+
+// copyImage makes dst a copy of src: a clone when the file system allows
+// it, a full copy otherwise. Returns whether dst is a clone.
+func copyImage(dst, src *os.File) (bool, error) {
+	if err := cloneFile(dst, src); err == nil {
+		return true, nil
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	_, err := io.Copy(dst, src)
+	return false, err
+}
@@ -0,0 +1,18 @@
+// Copyright 2025 Juan Font
+// BSD-3-Clause
+
+//go:build linux
+
+package common
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile makes dst a clone of src, sharing its blocks, on file systems
+// with reflinks such as btrfs and XFS.
+func cloneFile(dst, src *os.File) error {
+	return unix.IoctlFileClone(int(dst.Fd()), int(src.Fd()))
+}
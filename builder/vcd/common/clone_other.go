@@ -0,0 +1,17 @@
+// Copyright 2025 Juan Font
+// BSD-3-Clause
+
+//go:build !linux
+
+package common
+
+import (
+	"errors"
+	"os"
+)
+
+// cloneFile is only supported on Linux, where files are copied in full
+// otherwise.
+func cloneFile(dst, src *os.File) error {
+	return errors.ErrUnsupported
+}
@@ -2,6 +2,11 @@ package common
 
 import (
 	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/vmware/go-vcloud-director/v3/govcd"
 )
 
 type LocationConfig struct {
@@ -12,11 +17,26 @@ type LocationConfig struct {
 	VApp string `mapstructure:"vapp"`
 	// The VDC where the virtual machine is created.
 	VDC string `mapstructure:"vdc"`
+	// The VDC of `tenant_org` where the virtual machine is created, when
+	// logging in to the `System` org as a provider administrator. Same as
+	// `vdc`, for templates that keep tenant settings together; set one or
+	// the other.
+	TenantVDC string `mapstructure:"tenant_vdc"`
 	// If true, create a new vApp if the specified vApp does not exist.
 	// Defaults to true.
 	CreateVApp bool `mapstructure:"create_vapp"`
 	// The network to attach to the virtual machine.
 	Network string `mapstructure:"network"`
+	// Candidate networks to attach to the virtual machine, in fallback order,
+	// instead of a single `network`. When the vApp is composed, a network
+	// whose IP pool is exhausted (in POOL mode) or that cannot be added to
+	// the vApp is skipped and the next one is tried, so builds keep running
+	// when one pool fills up.
+	Networks []string `mapstructure:"networks"`
+	// How the network is picked from `networks`: `ordered` tries them in the
+	// order given, `random` shuffles them first to spread builds across the
+	// pools. Defaults to `ordered`.
+	NetworkSelection string `mapstructure:"network_selection"`
 	// The IP allocation mode for the network connection.
 	// Valid values are: POOL, DHCP, MANUAL, NONE.
 	// Defaults to POOL.
@@ -34,9 +54,27 @@ type LocationConfig struct {
 	// DNS server for the VM. Used for template variables ({{ .VMDNS }}).
 	// For POOL mode, if not set, defaults to 8.8.8.8.
 	VMDNS string `mapstructure:"vm_dns"`
-	// The storage profile to use for the virtual machine.
+	// The default storage profile for the ISO catalog, the virtual machine
+	// and the catalog created by export_to_catalog.
 	// If not specified, the default storage profile for the VDC will be used.
 	StorageProfile string `mapstructure:"storage_profile"`
+	// The storage profile for the temporary catalog holding the (modified) ISO.
+	// Defaults to storage_profile.
+	ISOStorageProfile string `mapstructure:"iso_storage_profile"`
+	// The storage profile for the virtual machine and its disks.
+	// Defaults to storage_profile.
+	VMStorageProfile string `mapstructure:"vm_storage_profile"`
+	// The storage profile used when export_to_catalog creates its catalog.
+	// Defaults to storage_profile, or to the first storage profile of the VDC
+	// when neither is set.
+	CatalogStorageProfile string `mapstructure:"catalog_storage_profile"`
+	// Connect to VCD when the configuration is prepared, e.g. by `packer
+	// validate`, and check that the `vdc`, the storage profiles, the
+	// networks and the VM sizing and placement policies of the template
+	// exist there. Mistyped names then fail validation instead of a build
+	// that has already started. The credentials must be valid
+	// when validating. Defaults to `false`.
+	ValidateRemote bool `mapstructure:"validate_remote"`
 }
 
 func (c *LocationConfig) Prepare() []error {
@@ -45,8 +83,29 @@ func (c *LocationConfig) Prepare() []error {
 	if c.VMName == "" {
 		errs = append(errs, fmt.Errorf("'vm_name' is required"))
 	}
+	if c.TenantVDC != "" {
+		if c.VDC != "" && c.VDC != c.TenantVDC {
+			errs = append(errs, fmt.Errorf("'vdc' and 'tenant_vdc' are mutually exclusive"))
+		}
+		c.VDC = c.TenantVDC
+	}
 	if c.VDC == "" {
-		errs = append(errs, fmt.Errorf("'vdc' is required"))
+		errs = append(errs, fmt.Errorf("'vdc' or 'tenant_vdc' is required"))
+	}
+
+	if len(c.Networks) > 0 {
+		if c.Network != "" {
+			errs = append(errs, fmt.Errorf("'network' and 'networks' are mutually exclusive"))
+		}
+		c.Network = c.Networks[0]
+	} else if c.Network != "" {
+		c.Networks = []string{c.Network}
+	}
+	if c.NetworkSelection == "" {
+		c.NetworkSelection = "ordered"
+	}
+	if c.NetworkSelection != "ordered" && c.NetworkSelection != "random" {
+		errs = append(errs, fmt.Errorf("'network_selection' must be one of: ordered, random"))
 	}
 
 	// Default to creating vApp if not specified
@@ -54,6 +113,18 @@ func (c *LocationConfig) Prepare() []error {
 		c.CreateVApp = true
 	}
 
+	// ISO scratch space, VM disks and the export catalog follow
+	// storage_profile unless set separately
+	if c.ISOStorageProfile == "" {
+		c.ISOStorageProfile = c.StorageProfile
+	}
+	if c.VMStorageProfile == "" {
+		c.VMStorageProfile = c.StorageProfile
+	}
+	if c.CatalogStorageProfile == "" {
+		c.CatalogStorageProfile = c.StorageProfile
+	}
+
 	// Validate IP allocation mode
 	if c.IPAllocationMode == "" {
 		c.IPAllocationMode = "POOL"
@@ -70,3 +141,98 @@ func (c *LocationConfig) Prepare() []error {
 
 	return errs
 }
+
+// CheckRemote checks the VDC resources the configuration references against
+// VCD when validate_remote is set. hardware is nil for builders without
+// hardware settings. It must run after Prepare, once the configuration is
+// otherwise valid.
+func (c *LocationConfig) CheckRemote(connect *ConnectConfig, hardware *HardwareConfig) []error {
+	if !c.ValidateRemote {
+		return nil
+	}
+
+	d, err := connect.Connect()
+	if err != nil {
+		return []error{fmt.Errorf("'validate_remote': %w", err)}
+	}
+	defer func() {
+		if err := d.Cleanup(); err != nil {
+			log.Printf("[WARN] Failed to close VCD client session: %s", err)
+		}
+	}()
+
+	vdc, err := d.GetVdc(c.VDC)
+	if err != nil {
+		return []error{fmt.Errorf("'vdc': %w", err)}
+	}
+
+	errs := c.checkStorageProfiles(vdc)
+	errs = append(errs, c.checkNetworks(vdc)...)
+	if hardware != nil {
+		errs = append(errs, hardware.checkPolicies(d, vdc)...)
+	}
+	return errs
+}
+
+// checkStorageProfiles checks that the storage profiles are available in
+// the VDC.
+func (c *LocationConfig) checkStorageProfiles(vdc *govcd.Vdc) []error {
+	var available []string
+	if vdc.Vdc.VdcStorageProfiles != nil {
+		for _, ref := range vdc.Vdc.VdcStorageProfiles.VdcStorageProfile {
+			available = append(available, ref.Name)
+		}
+	}
+
+	var errs []error
+	profiles := []struct{ key, name string }{
+		{"storage_profile", c.StorageProfile},
+		{"iso_storage_profile", c.ISOStorageProfile},
+		{"vm_storage_profile", c.VMStorageProfile},
+		{"catalog_storage_profile", c.CatalogStorageProfile},
+	}
+	for _, p := range profiles {
+		// The others default to storage_profile, which is reported once
+		if p.name == "" || (p.key != "storage_profile" && p.name == c.StorageProfile) {
+			continue
+		}
+		if !slices.Contains(available, p.name) {
+			errs = append(errs, fmt.Errorf("'%s': storage profile %q not found in VDC %s (available: %s)",
+				p.key, p.name, c.VDC, strings.Join(available, ", ")))
+		}
+	}
+	return errs
+}
+
+// checkNetworks checks that the networks exist in the VDC, or in the vApp
+// when it already exists.
+func (c *LocationConfig) checkNetworks(vdc *govcd.Vdc) []error {
+	key := "network"
+	if len(c.Networks) > 1 {
+		key = "networks"
+	}
+
+	var vapp *govcd.VApp
+	if c.VApp != "" {
+		vapp, _ = vdc.GetVAppByName(c.VApp, false)
+	}
+
+	var errs []error
+	for _, name := range c.Networks {
+		_, err := vdc.GetOrgVdcNetworkByName(name, false)
+		if err == nil {
+			continue
+		}
+		if !govcd.ContainsNotFound(err) {
+			errs = append(errs, fmt.Errorf("'%s': error getting network %s: %w", key, name, err))
+			continue
+		}
+		if vapp != nil {
+			if _, err := vapp.GetVappNetworkByName(name, false); err == nil {
+				continue
+			}
+		}
+		errs = append(errs, fmt.Errorf("'%s': network %q not found in VDC %s", key, name, c.VDC))
+	}
+	return errs
+}
@@ -0,0 +1,30 @@
+// Copyright 2025 Juan Font
+// BSD-3-Clause
+
+package common
+
+import (
+	"fmt"
+	"os"
+)
+
+// checkFreeSpace verifies that dir has at least needed bytes available, so
+// long-running ISO operations fail upfront instead of halfway through.
+func checkFreeSpace(dir string, needed int64) error {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	free, err := freeSpace(dir)
+	if err != nil {
+		return fmt.Errorf("failed to check free space in %s: %w", dir, err)
+	}
+
+	if needed > 0 && free < uint64(needed) {
+		return fmt.Errorf("not enough free space in %s: %d MB available, about %d MB needed. "+
+			"Set 'work_directory' to a location with more space",
+			dir, free/(1024*1024), needed/(1024*1024))
+	}
+
+	return nil
+}
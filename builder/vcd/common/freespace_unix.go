@@ -0,0 +1,18 @@
+// Copyright 2025 Juan Font
+// BSD-3-Clause
+
+//go:build unix
+
+package common
+
+import "golang.org/x/sys/unix"
+
+// freeSpace returns the number of bytes available to unprivileged users on
+// the filesystem holding path.
+func freeSpace(path string) (uint64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
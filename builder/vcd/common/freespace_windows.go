@@ -0,0 +1,23 @@
+// Copyright 2025 Juan Font
+// BSD-3-Clause
+
+//go:build windows
+
+package common
+
+import "golang.org/x/sys/windows"
+
+// freeSpace returns the number of bytes available to the current user on
+// the volume holding path.
+func freeSpace(path string) (uint64, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var free, total, totalFree uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &free, &total, &totalFree); err != nil {
+		return 0, err
+	}
+	return free, nil
+}
@@ -0,0 +1,56 @@
+package common
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+)
+
+// defaultInstallProgressPattern matches console lines showing a percentage,
+// which is how most installers report progress.
+const defaultInstallProgressPattern = `\d{1,3} ?%`
+
+// logInstallProgress samples the console every interval until ctx is done,
+// and reports the lines matching pattern that were not on the previous
+// sample. Failures are only logged: the progress is informational.
+func logInstallProgress(ctx context.Context, ui packersdk.Ui, d driver.Driver, vm driver.VirtualMachine,
+	interval time.Duration, pattern *regexp.Regexp) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	seen := make(map[string]bool)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		img, err := d.CaptureScreenshot(ctx, vm)
+		if err != nil {
+			log.Printf("[DEBUG] Install progress: error capturing console: %v", err)
+			continue
+		}
+		lines, err := driver.RecognizeText(ctx, img)
+		if err != nil {
+			log.Printf("[DEBUG] Install progress: %v", err)
+			continue
+		}
+
+		current := make(map[string]bool)
+		for _, line := range lines {
+			if !pattern.MatchString(line) {
+				continue
+			}
+			current[line] = true
+			if !seen[line] && ctx.Err() == nil {
+				ui.Sayf("Console: %s", line)
+			}
+		}
+		seen = current
+	}
+}
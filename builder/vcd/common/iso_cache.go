@@ -0,0 +1,208 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type ISOCacheConfig
+
+// ISOCacheConfig configures the on-disk cache of ISOs modified with
+// cd_content and cd_files. Builds adding the same files to the same ISO
+// reuse the cached ISO instead of modifying it again. The cache is pruned
+// when a build starts.
+type ISOCacheConfig struct {
+	// Cached modified ISOs not used for longer than this are deleted.
+	// Defaults to `168h` (7 days).
+	ModifiedISOCacheMaxAge time.Duration `mapstructure:"modified_iso_cache_max_age"`
+	// The maximum total size of the cache, in MB. The least recently used
+	// ISOs are deleted until the cache fits. Defaults to `20480` (20 GB).
+	ModifiedISOCacheMaxSizeMB int64 `mapstructure:"modified_iso_cache_max_size_mb"`
+	// Modify the ISO on every build instead of caching it. Cached ISOs hold
+	// the rendered cd_content, passwords included, and are only readable by
+	// the current user. Defaults to `false`.
+	DisableModifiedISOCache bool `mapstructure:"disable_modified_iso_cache"`
+}
+
+func (c *ISOCacheConfig) Prepare() []error {
+	var errs []error
+
+	if c.ModifiedISOCacheMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("'modified_iso_cache_max_age' must not be negative"))
+	}
+	if c.ModifiedISOCacheMaxSizeMB < 0 {
+		errs = append(errs, fmt.Errorf("'modified_iso_cache_max_size_mb' must not be negative"))
+	}
+
+	if c.ModifiedISOCacheMaxAge == 0 {
+		c.ModifiedISOCacheMaxAge = 7 * 24 * time.Hour
+	}
+	if c.ModifiedISOCacheMaxSizeMB == 0 {
+		c.ModifiedISOCacheMaxSizeMB = 20 * 1024
+	}
+
+	return errs
+}
+
+// ModifiedISOCacheDir returns the directory modified ISOs are cached in,
+// inside the Packer cache directory.
+func ModifiedISOCacheDir() (string, error) {
+	return packersdk.CachePath("vcd-modified-iso")
+}
+
+// cachedISOPath returns the path in the cache of the modified ISO named
+// name, made with the modifications identified by key.
+func cachedISOPath(dir, name, key string) string {
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	return filepath.Join(dir, fmt.Sprintf("%s-%s.iso", base, key[:16]))
+}
+
+// lookupCachedISO returns the checksum of the cached ISO at path, saved
+// next to it, if it is cached. A hit counts as a use of the ISO.
+func lookupCachedISO(path string) (string, bool) {
+	checksum, err := os.ReadFile(path + ".sha256")
+	if err != nil {
+		return "", false
+	}
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(checksum)), true
+}
+
+// storeCachedISO moves the ISO at tmpPath, written in the cache directory,
+// to path and saves its checksum next to it. The ISO comes first: one
+// without a checksum is a miss, and is overwritten by the next build.
+func storeCachedISO(tmpPath, path, checksum string) error {
+	if err := os.Chmod(tmpPath, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	if err := writePrivateFile(path+".sha256", []byte(checksum+"\n")); err != nil {
+		log.Printf("[WARN] Failed to save the checksum of cached ISO %s: %s", path, err)
+	}
+	return nil
+}
+
+// isoCacheEntry is a cached ISO; its modification time is its last use.
+type isoCacheEntry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// pruneISOCache deletes the ISOs in dir unused for longer than maxAge, then
+// the least recently used ones until the total size is at most maxSize.
+// Only *.iso files are considered, so ISOs still being written under a
+// temporary name are left alone, unless older than maxAge, when the build
+// writing them is long gone. It returns the number of files and bytes
+// freed.
+func pruneISOCache(dir string, maxAge time.Duration, maxSize int64) (int, int64, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+
+	var entries []isoCacheEntry
+	cutoff := time.Now().Add(-maxAge)
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		if strings.HasSuffix(f.Name(), ".tmp") && info.ModTime().Before(cutoff) {
+			entries = append(entries, isoCacheEntry{
+				path:    filepath.Join(dir, f.Name()),
+				size:    info.Size(),
+				modTime: info.ModTime(),
+			})
+			continue
+		}
+		if !strings.HasSuffix(f.Name(), ".iso") {
+			continue
+		}
+		entries = append(entries, isoCacheEntry{
+			path:    filepath.Join(dir, f.Name()),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+
+	// Oldest first
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+
+	removed := 0
+	var freed int64
+	for _, e := range entries {
+		if !e.modTime.Before(cutoff) && total <= maxSize {
+			break
+		}
+		if err := os.Remove(e.path); err != nil {
+			log.Printf("[WARN] Failed to remove cached ISO %s: %s", e.path, err)
+			continue
+		}
+		log.Printf("[DEBUG] Removed cached ISO %s (%d bytes, last used %s)", e.path, e.size, e.modTime.Format(time.RFC3339))
+		os.Remove(e.path + ".sha256")
+		removed++
+		freed += e.size
+		total -= e.size
+	}
+
+	return removed, freed, nil
+}
+
+// StepPruneISOCache prunes the modified ISO cache by age and size, so CI
+// runners do not fill their disks with ISOs of past builds.
+type StepPruneISOCache struct {
+	Config *ISOCacheConfig
+}
+
+func (s *StepPruneISOCache) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	dir, err := ModifiedISOCacheDir()
+	if err != nil {
+		log.Printf("[WARN] Failed to locate the modified ISO cache: %s", err)
+		return multistep.ActionContinue
+	}
+
+	removed, freed, err := pruneISOCache(dir, s.Config.ModifiedISOCacheMaxAge, s.Config.ModifiedISOCacheMaxSizeMB*1024*1024)
+	if err != nil {
+		// A cache that cannot be pruned should not fail the build
+		ui.Sayf("Warning: failed to prune the modified ISO cache %s: %s", dir, err)
+		return multistep.ActionContinue
+	}
+	if removed > 0 {
+		ui.Sayf("Pruned %d cached modified ISO(s) from %s, freeing %d MB", removed, dir, freed/(1024*1024))
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepPruneISOCache) Cleanup(state multistep.StateBag) {}
@@ -4,25 +4,71 @@
 package common
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/diskfs/go-diskfs"
 	"github.com/diskfs/go-diskfs/filesystem"
 	"github.com/diskfs/go-diskfs/filesystem/iso9660"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/common/udf"
 )
 
 // ISOModifier handles reading and modifying ISO images
 type ISOModifier struct {
 	sourcePath string
+	workDir    string            // temp files go here; empty means os.TempDir()
 	files      map[string][]byte // path -> content
+	links      map[string]string // path -> symbolic link target
+	efiFiles   map[string][]byte // path in the EFI boot image -> content
+	wimFiles   map[string][]byte // path in the boot image of sources/boot.wim -> content
+	volumeID   string            // volume label replacing the source one, if set
+	boot       BootOverrides     // El Torito settings replacing the detected ones
+	tool       string            // one of the ISOTool constants
+}
+
+// Tools to modify ISOs with
+const (
+	// ISOToolAuto uses xorriso for ISO9660 ISOs and rebuilds UDF ISOs
+	// natively
+	ISOToolAuto = "auto"
+	// ISOToolNative rebuilds UDF ISOs in-process
+	ISOToolNative = "native"
+	// ISOToolXorriso modifies any ISO with xorriso. Only the ISO9660 tree
+	// of UDF ISOs is kept.
+	ISOToolXorriso = "xorriso"
+	// ISOToolAppend appends the added files to a copy of UDF ISOs instead
+	// of rebuilding them, see udf.Appender. The copy is a clone where the
+	// file system allows it.
+	ISOToolAppend = "append"
+)
+
+// BootOverrides replaces the El Torito boot settings detected from the
+// source ISO, for ISOs whose layout the detection gets wrong. Zero values
+// keep the detected settings.
+type BootOverrides struct {
+	BIOSBootImage    string // path in the ISO, e.g. boot/isolinux/isolinux.bin
+	BIOSLoadSize     uint16 // in 512 byte sectors
+	BIOSLoadSegment  uint16 // real mode segment, e.g. 0x07C0
+	NeedsBootInfoTbl bool   // patch a boot info table into the BIOS image
+	UEFIBootImage    string // path in the ISO, e.g. efi/boot/efiboot.img
+}
+
+// IsZero reports whether no setting is overridden.
+func (o BootOverrides) IsZero() bool {
+	return o == BootOverrides{}
 }
 
 // NewISOModifier creates a new ISO modifier for the given source ISO
@@ -30,7 +76,116 @@ func NewISOModifier(sourcePath string) *ISOModifier {
 	return &ISOModifier{
 		sourcePath: sourcePath,
 		files:      make(map[string][]byte),
+		links:      make(map[string]string),
+		efiFiles:   make(map[string][]byte),
+		wimFiles:   make(map[string][]byte),
+		tool:       ISOToolAuto,
+	}
+}
+
+// SetTool sets the tool the ISO is modified with, one of the ISOTool
+// constants
+func (m *ISOModifier) SetTool(tool string) {
+	if tool == "" {
+		tool = ISOToolAuto
+	}
+	m.tool = tool
+}
+
+// UsesXorriso reports whether the ISO is modified with xorriso rather than
+// rebuilt natively
+func (m *ISOModifier) UsesXorriso() (bool, error) {
+	isUDF, err := m.IsUDF()
+	if err != nil {
+		return false, err
+	}
+
+	switch m.tool {
+	case ISOToolXorriso:
+		return true, nil
+	case ISOToolNative:
+		if !isUDF {
+			return false, fmt.Errorf("only UDF (Windows) ISOs can be rebuilt natively, ISO9660 ISOs are modified with xorriso")
+		}
+		return false, nil
+	case ISOToolAppend:
+		if !isUDF {
+			return false, fmt.Errorf("files can only be appended to UDF (Windows) ISOs, ISO9660 ISOs are modified with xorriso")
+		}
+		return false, nil
+	default:
+		// xorriso only reads the ISO9660 tree, which Windows ISOs leave
+		// empty but for a README
+		return !isUDF, nil
+	}
+}
+
+// SetWorkDir sets the directory used for temporary files while creating the
+// modified ISO
+func (m *ISOModifier) SetWorkDir(dir string) {
+	m.workDir = dir
+}
+
+// RequiredSpace estimates the free space needed in the work directory to
+// create the modified ISO, including the output ISO itself
+func (m *ISOModifier) RequiredSpace() (int64, error) {
+	fi, err := os.Stat(m.sourcePath)
+	if err != nil {
+		return 0, err
+	}
+
+	var added int64
+	for _, content := range m.files {
+		added += int64(len(content))
+	}
+
+	// boot.wim is extracted and modified on disk, then added like the
+	// other files
+	wimSize, err := m.bootWIMSize()
+	if err != nil {
+		return 0, err
+	}
+	if wimSize > 0 {
+		added += wimSize
+		for _, content := range m.wimFiles {
+			added += int64(len(content))
+		}
+	}
+
+	// The output ISO holds the source plus the added files
+	needed := fi.Size() + added
+
+	// xorriso maps the added files from disk. UDF ISOs are rebuilt
+	// in-process, straight from the source ISO.
+	useXorriso, err := m.UsesXorriso()
+	if err != nil {
+		return 0, err
 	}
+	if useXorriso {
+		needed += added
+	} else {
+		// The modified boot.wim is still read from disk
+		needed += wimSize
+	}
+	// Appending to a clone of the source only takes space for the
+	// appended sectors
+	if m.tool == ISOToolAppend && canClone(m.sourcePath, m.workDir) {
+		needed = added + wimSize
+	}
+
+	return needed, nil
+}
+
+// SetVolumeID sets the volume label of the modified ISO. By default the
+// label of the source ISO is kept.
+func (m *ISOModifier) SetVolumeID(volumeID string) {
+	m.volumeID = volumeID
+}
+
+// SetBootOverrides sets the El Torito settings used instead of the detected
+// ones when the ISO is rebuilt
+func (m *ISOModifier) SetBootOverrides(o BootOverrides) {
+	m.boot = o
 }
 
 // AddContent adds content to be included in the modified ISO
@@ -40,6 +195,16 @@ func (m *ISOModifier) AddContent(path string, content []byte) {
 	// Ensure path doesn't start with /
 	path = strings.TrimPrefix(path, "/")
 	m.files[path] = content
+	delete(m.links, path)
+}
+
+// AddSymlink adds a symbolic link to target to the modified ISO. It is kept
+// as a link: a Rock Ridge SL entry written by xorriso, or a UDF symbolic
+// link.
+func (m *ISOModifier) AddSymlink(path, target string) {
+	path = strings.TrimPrefix(filepath.ToSlash(path), "/")
+	m.links[path] = filepath.ToSlash(target)
+	delete(m.files, path)
 }
 
 // AddFile adds a file from disk to be included in the modified ISO
@@ -52,12 +217,51 @@ func (m *ISOModifier) AddFile(isoPath, localPath string) error {
 	return nil
 }
 
+// AddEFIBootContent adds content to the EFI boot image of the modified ISO,
+// a FAT image that UEFI boot loaders read their configuration from
+func (m *ISOModifier) AddEFIBootContent(path string, content []byte) {
+	path = strings.TrimPrefix(filepath.ToSlash(path), "/")
+	m.efiFiles[path] = content
+}
+
+// AddBootWIMContent adds content to the boot image of sources/boot.wim, the
+// Windows PE image that runs Windows Setup, which finds autounattend.xml at
+// its root whatever media it booted from
+func (m *ISOModifier) AddBootWIMContent(path string, content []byte) {
+	path = strings.TrimPrefix(filepath.ToSlash(path), "/")
+	m.wimFiles[path] = content
+}
+
+// CacheKey returns a hash identifying the modified ISO: that of the source
+// ISO, given by its checksum, and of everything added to it or changed.
+func (m *ISOModifier) CacheKey(sourceChecksum string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "source %s\ntool %s\nvolume %q\nboot %+v\n", sourceChecksum, m.tool, m.volumeID, m.boot)
+	for _, path := range slices.Sorted(maps.Keys(m.files)) {
+		fmt.Fprintf(h, "file %q %d\n", path, len(m.files[path]))
+		h.Write(m.files[path])
+	}
+	for _, path := range slices.Sorted(maps.Keys(m.links)) {
+		fmt.Fprintf(h, "link %q %q\n", path, m.links[path])
+	}
+	for _, path := range slices.Sorted(maps.Keys(m.efiFiles)) {
+		fmt.Fprintf(h, "efi %q %d\n", path, len(m.efiFiles[path]))
+		h.Write(m.efiFiles[path])
+	}
+	for _, path := range slices.Sorted(maps.Keys(m.wimFiles)) {
+		fmt.Fprintf(h, "wim %q %d\n", path, len(m.wimFiles[path]))
+		h.Write(m.wimFiles[path])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // BootConfig holds boot configuration detected from an ISO
 type BootConfig struct {
 	// BIOS boot
 	HasBIOSBoot      bool
 	BIOSBootImage    string // Path to boot image (e.g., boot/etfsboot.com)
 	BIOSLoadSize     uint16 // Load size in sectors (usually 4 for no-emulation)
+	BIOSLoadSegment  uint16 // Load segment, 0 for the BIOS default (0x07C0)
 	NeedsBootInfoTbl bool   // True for isolinux/syslinux (needs boot info table patch)
 
 	// UEFI boot
@@ -157,9 +361,33 @@ func (m *ISOModifier) DetectBootConfig() (*BootConfig, error) {
 		}
 	}
 
+	m.applyBootOverrides(config)
 	return config, nil
 }
 
+// applyBootOverrides replaces the detected boot settings with the overridden
+// ones.
+func (m *ISOModifier) applyBootOverrides(config *BootConfig) {
+	o := m.boot
+	if o.BIOSBootImage != "" {
+		config.HasBIOSBoot = true
+		config.BIOSBootImage = strings.TrimPrefix(o.BIOSBootImage, "/")
+		config.NeedsBootInfoTbl = o.NeedsBootInfoTbl
+	} else if o.NeedsBootInfoTbl {
+		config.NeedsBootInfoTbl = true
+	}
+	if o.BIOSLoadSize != 0 {
+		config.BIOSLoadSize = o.BIOSLoadSize
+	}
+	if o.BIOSLoadSegment != 0 {
+		config.BIOSLoadSegment = o.BIOSLoadSegment
+	}
+	if o.UEFIBootImage != "" {
+		config.HasUEFIBoot = true
+		config.UEFIBootImage = strings.TrimPrefix(o.UEFIBootImage, "/")
+	}
+}
+
 func (m *ISOModifier) fileExists(fs filesystem.FileSystem, path string) bool {
 	f, err := fs.OpenFile(path, os.O_RDONLY)
 	if err != nil {
@@ -172,45 +400,70 @@ func (m *ISOModifier) fileExists(fs filesystem.FileSystem, path string) bool {
 // CreateModifiedISO creates a new ISO with the added content
 // Returns the SHA256 checksum of the new ISO
 func (m *ISOModifier) CreateModifiedISO(outputPath string) (string, error) {
-	// Check if this is a UDF ISO (Windows ISOs use UDF)
-	isUDF, err := m.IsUDF()
+	useXorriso, err := m.UsesXorriso()
 	if err != nil {
 		return "", fmt.Errorf("failed to detect filesystem type: %w", err)
 	}
 
-	if isUDF {
-		return m.createModifiedUDFISO(outputPath)
+	if useXorriso {
+		return m.createModifiedISOXorriso(outputPath)
+	}
+	if m.tool == ISOToolAppend {
+		return m.createAppendedUDFISO(outputPath)
 	}
 
-	return m.createModifiedISO9660(outputPath)
+	return m.createModifiedUDFISO(outputPath)
 }
 
-// createModifiedISO9660 creates a modified ISO for ISO9660 filesystems using xorriso.
+var errXorrisoNotFound = errors.New("xorriso not found in PATH. Install it with: apt-get install xorriso")
+
+// CheckXorriso verifies that xorriso is installed
+func CheckXorriso() error {
+	if _, err := exec.LookPath("xorriso"); err != nil {
+		return errXorrisoNotFound
+	}
+	return nil
+}
+
+// createModifiedISOXorriso creates a modified ISO using xorriso.
 // xorriso properly preserves all boot records (BIOS/UEFI El Torito, boot-info-table, etc.)
 // which is critical for the modified ISO to remain bootable.
-func (m *ISOModifier) createModifiedISO9660(outputPath string) (string, error) {
+func (m *ISOModifier) createModifiedISOXorriso(outputPath string) (string, error) {
 	// Check for xorriso
 	xorrisoPath, err := exec.LookPath("xorriso")
 	if err != nil {
-		return "", fmt.Errorf("xorriso not found in PATH. Install it with: apt-get install xorriso")
+		return "", errXorrisoNotFound
 	}
 
 	// Create a temp directory for the files we want to add
-	addDir, err := os.MkdirTemp("", "packer-iso-add-")
+	addDir, err := os.MkdirTemp(m.workDir, "packer-iso-add-")
 	if err != nil {
 		return "", fmt.Errorf("failed to create temp directory: %w", err)
 	}
 	defer os.RemoveAll(addDir)
 
 	// Write new content files to the temp directory
-	for path, content := range m.files {
-		fullPath := filepath.Join(addDir, path)
-		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
-			return "", fmt.Errorf("failed to create directory for %s: %w", path, err)
-		}
-		if err := os.WriteFile(fullPath, content, 0644); err != nil {
-			return "", fmt.Errorf("failed to write file %s: %w", path, err)
-		}
+	if err := m.writeFiles(addDir); err != nil {
+		return "", err
+	}
+	paths := m.addedPaths()
+
+	// The EFI boot image is replaced by a copy holding the added EFI boot
+	// files
+	efiImage, err := m.writeEFIBootImage(addDir)
+	if err != nil {
+		return "", err
+	}
+	if efiImage != "" {
+		paths = append(paths, efiImage)
+	}
+	// So is boot.wim, by a copy holding the added boot.wim files
+	wim, err := m.writeBootWIM(addDir)
+	if err != nil {
+		return "", err
+	}
+	if wim != "" {
+		paths = append(paths, wim)
 	}
 
 	// Remove output file if it exists (xorriso needs a fresh file for -outdev)
@@ -224,11 +477,53 @@ func (m *ISOModifier) createModifiedISO9660(outputPath string) (string, error) {
 	args := []string{
 		"-indev", m.sourcePath,
 		"-outdev", outputPath,
-		"-boot_image", "any", "replay",
+	}
+	if m.volumeID != "" {
+		args = append(args, "-volid", m.volumeID)
+	}
+	// xorriso writes no UDF, so the files of UDF ISOs need Joliet for
+	// their long names and ISO9660 level 3 for files over 4GB
+	isUDF, err := m.IsUDF()
+	if err != nil {
+		return "", fmt.Errorf("failed to detect filesystem type: %w", err)
+	}
+	if isUDF {
+		args = append(args, "-joliet", "on", "-compliance", "iso_9660_level=3")
+	} else {
+		// xorriso only writes a Joliet tree when asked to, even if the
+		// source ISO has one, and some installers read their long names
+		// from it
+		joliet, err := hasJoliet(m.sourcePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read volume descriptors: %w", err)
+		}
+		if joliet {
+			args = append(args, "-joliet", "on")
+		}
 	}
 
-	// Add each file/directory to the ISO
-	for path := range m.files {
+	if m.boot.IsZero() {
+		// replay also keeps the isohybrid MBR and GPT of the system area
+		args = append(args, "-boot_image", "any", "replay")
+	} else {
+		bootArgs, err := m.xorrisoBootArgs()
+		if err != nil {
+			return "", err
+		}
+		args = append(args, bootArgs...)
+
+		// The boot records are rebuilt, so the system area is copied over
+		// separately
+		hybridArgs, err := m.xorrisoHybridArgs(addDir)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, hybridArgs...)
+	}
+
+	// Add each file/directory to the ISO. Links are not followed, so they
+	// are mapped as Rock Ridge symbolic links
+	for _, path := range paths {
 		localPath := filepath.Join(addDir, path)
 		isoPath := "/" + path
 		args = append(args, "-map", localPath, isoPath)
@@ -252,6 +547,212 @@ func (m *ISOModifier) createModifiedISO9660(outputPath string) (string, error) {
 	return checksum, nil
 }
 
+// writeFiles writes the added files and links under dir, for xorriso to
+// map them
+func (m *ISOModifier) writeFiles(dir string) error {
+	for path, content := range m.files {
+		fullPath := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", path, err)
+		}
+		if err := os.WriteFile(fullPath, content, 0644); err != nil {
+			return fmt.Errorf("failed to write file %s: %w", path, err)
+		}
+	}
+	for path, target := range m.links {
+		fullPath := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", path, err)
+		}
+		if err := os.Symlink(target, fullPath); err != nil {
+			return fmt.Errorf("failed to create link %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// addedPaths returns the paths of the added files and links
+func (m *ISOModifier) addedPaths() []string {
+	paths := make([]string, 0, len(m.files)+len(m.links))
+	for path := range m.files {
+		paths = append(paths, path)
+	}
+	for path := range m.links {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// udfTree is a UDF image being built: a udf.Writer or a udf.Appender
+type udfTree interface {
+	AddFile(p string, size int64, modTime time.Time, open func() (io.Reader, error)) error
+	AddSymlink(p, target string, modTime time.Time) error
+}
+
+// addToUDF adds the added files and links to w, replacing those of the
+// source ISO with the same path
+func (m *ISOModifier) addToUDF(w udfTree) error {
+	now := time.Now()
+	for path, content := range m.files {
+		err := w.AddFile(path, int64(len(content)), now, func() (io.Reader, error) {
+			return bytes.NewReader(content), nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add file %s: %w", path, err)
+		}
+	}
+	for path, target := range m.links {
+		if err := w.AddSymlink(path, target, now); err != nil {
+			return fmt.Errorf("failed to add link %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// CreateISO creates a new ISO holding only the added files, for a second
+// CD-ROM drive. Like the modified ISO, it is written with xorriso, as an
+// ISO9660 image with Joliet and Rock Ridge names, unless the source ISO is
+// rebuilt natively, in which case it is a UDF image.
+// Returns the SHA256 checksum of the new ISO
+func (m *ISOModifier) CreateISO(outputPath, volumeID string) (string, error) {
+	useXorriso, err := m.UsesXorriso()
+	if err != nil {
+		return "", fmt.Errorf("failed to detect filesystem type: %w", err)
+	}
+
+	if useXorriso {
+		xorrisoPath, err := exec.LookPath("xorriso")
+		if err != nil {
+			return "", errXorrisoNotFound
+		}
+		addDir, err := os.MkdirTemp(m.workDir, "packer-iso-add-")
+		if err != nil {
+			return "", fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		defer os.RemoveAll(addDir)
+		if err := m.writeFiles(addDir); err != nil {
+			return "", err
+		}
+
+		os.Remove(outputPath)
+		cmd := exec.Command(xorrisoPath,
+			"-outdev", outputPath,
+			"-volid", volumeID,
+			"-joliet", "on",
+			"-map", addDir, "/",
+		)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("xorriso failed: %w\nstderr: %s", err, stderr.String())
+		}
+	} else {
+		w := udf.NewWriter(volumeID)
+		if err := m.addToUDF(w); err != nil {
+			return "", err
+		}
+		if err := writeUDFISO(w, outputPath); err != nil {
+			os.Remove(outputPath)
+			return "", err
+		}
+	}
+
+	checksum, err := m.calculateChecksum(outputPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to calculate checksum: %w", err)
+	}
+	return checksum, nil
+}
+
+// xorrisoBootArgs sets up the El Torito boot records from the detected
+// boot settings and the overrides, instead of replaying those of the source
+// ISO.
+func (m *ISOModifier) xorrisoBootArgs() ([]string, error) {
+	if m.boot.BIOSLoadSegment != 0 {
+		return nil, fmt.Errorf("a BIOS boot load segment can only be set for UDF (Windows) ISOs rebuilt natively")
+	}
+
+	config, err := m.DetectBootConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect boot configuration: %w", err)
+	}
+	if !config.HasBIOSBoot && !config.HasUEFIBoot {
+		return nil, fmt.Errorf("no boot image found in the ISO, set the BIOS or EFI boot image")
+	}
+
+	args := []string{"-boot_image", "any", "discard"}
+	if config.HasBIOSBoot {
+		args = append(args,
+			"-boot_image", "any", "bin_path=/"+config.BIOSBootImage,
+			"-boot_image", "any", "emul_type=no_emulation",
+		)
+		if config.BIOSLoadSize != 0 {
+			args = append(args, "-boot_image", "any", fmt.Sprintf("load_size=%d", int(config.BIOSLoadSize)*512))
+		}
+		if config.NeedsBootInfoTbl {
+			args = append(args, "-boot_image", "any", "boot_info_table=on")
+		}
+	}
+	if config.HasUEFIBoot {
+		if config.HasBIOSBoot {
+			args = append(args, "-boot_image", "any", "next")
+		}
+		args = append(args, "-boot_image", "any", "efi_path=/"+config.UEFIBootImage)
+	}
+	return append(args, "-boot_image", "any", "cat_path=/boot.cat"), nil
+}
+
+// systemAreaSize is the size of the system area of ISO9660 images, before
+// the volume descriptors
+const systemAreaSize = 16 * 2048
+
+// xorrisoHybridArgs keeps the isohybrid MBR of the source ISO, which lets it
+// be written to a disk and boot, when the boot records are rebuilt. xorriso
+// rewrites its partition table for the new image, and the GPT too if the
+// source has one, with the EFI boot image as the EFI system partition.
+// The system area is saved to a file in dir. Returns no arguments for ISOs
+// without an MBR.
+func (m *ISOModifier) xorrisoHybridArgs(dir string) ([]string, error) {
+	f, err := os.Open(m.sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	systemArea := make([]byte, systemAreaSize)
+	if _, err := f.ReadAt(systemArea, 0); err != nil {
+		return nil, fmt.Errorf("failed to read system area: %w", err)
+	}
+	if systemArea[510] != 0x55 || systemArea[511] != 0xAA {
+		return nil, nil
+	}
+
+	saved, err := os.CreateTemp(dir, "packer-system-area-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	_, err = saved.Write(systemArea)
+	if closeErr := saved.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save system area: %w", err)
+	}
+
+	args := []string{
+		"-boot_image", "any", "system_area=" + saved.Name(),
+		"-boot_image", "any", "partition_table=on",
+	}
+	// The GPT header follows the protective MBR
+	if string(systemArea[512:520]) == "EFI PART" {
+		config, err := m.DetectBootConfig()
+		if err == nil && config.HasUEFIBoot {
+			args = append(args, "-boot_image", "any", "efi_boot_part=--efi-boot-image")
+		}
+	}
+	return args, nil
+}
+
 func (m *ISOModifier) calculateChecksum(path string) (string, error) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -272,29 +773,6 @@ func (m *ISOModifier) IsUDF() (bool, error) {
 	return isUDFFilesystem(m.sourcePath)
 }
 
-// CheckUDFTools verifies that the required external tools for UDF ISO modification
-// are available. Returns an error with installation instructions if tools are missing.
-// Required tools: 7z (p7zip-full), mkisofs or genisoimage
-func CheckUDFTools() error {
-	var missing []string
-
-	if _, err := exec.LookPath("7z"); err != nil {
-		missing = append(missing, "7z (install: apt-get install p7zip-full)")
-	}
-
-	_, mkisofsErr := exec.LookPath("mkisofs")
-	_, genisoimageErr := exec.LookPath("genisoimage")
-	if mkisofsErr != nil && genisoimageErr != nil {
-		missing = append(missing, "mkisofs or genisoimage (install: apt-get install genisoimage)")
-	}
-
-	if len(missing) > 0 {
-		return fmt.Errorf("UDF ISO modification requires external tools that are not installed:\n  - %s", strings.Join(missing, "\n  - "))
-	}
-
-	return nil
-}
-
 // isUDFFilesystem checks if the ISO uses UDF filesystem (common for Windows ISOs)
 func isUDFFilesystem(isoPath string) (bool, error) {
 	f, err := os.Open(isoPath)
@@ -327,161 +805,273 @@ func isUDFFilesystem(isoPath string) (bool, error) {
 	return false, nil
 }
 
-// createModifiedUDFISO creates a modified ISO for Windows ISOs (UDF/ISO9660 dual format)
-// Uses 7z to extract (handles UDF properly) and mkisofs/genisoimage to recreate with proper UDF support.
-// This approach ensures files are visible in both ISO9660 and UDF filesystems.
-func (m *ISOModifier) createModifiedUDFISO(outputPath string) (string, error) {
-	// Check if 7z is available (for UDF extraction)
-	sevenZipPath, err := exec.LookPath("7z")
-	if err != nil {
-		return "", fmt.Errorf("7z not found in PATH. Install it with: apt-get install p7zip-full (Debian/Ubuntu) or yum install p7zip (RHEL/CentOS)")
+var errMtoolsNotFound = errors.New("mtools not found in PATH, needed for efi_boot_content. Install it with: apt-get install mtools")
+
+// CheckMtools verifies that mtools is installed
+func CheckMtools() error {
+	if _, err := exec.LookPath("mcopy"); err != nil {
+		return errMtoolsNotFound
+	}
+	return nil
+}
+
+// writeEFIBootImage copies the EFI boot image of the source ISO under dir,
+// at its path in the ISO, and writes the added EFI boot files into it with
+// mtools. Returns the path of the image in the ISO, or an empty path when
+// no EFI boot files were added.
+func (m *ISOModifier) writeEFIBootImage(dir string) (string, error) {
+	if len(m.efiFiles) == 0 {
+		return "", nil
+	}
+	if err := CheckMtools(); err != nil {
+		return "", err
 	}
 
-	// Check if mkisofs or genisoimage is available (for recreation with UDF)
-	mkisofsPath, err := exec.LookPath("mkisofs")
+	config, err := m.DetectBootConfig()
 	if err != nil {
-		mkisofsPath, err = exec.LookPath("genisoimage")
-		if err != nil {
-			return "", fmt.Errorf("mkisofs/genisoimage not found in PATH. Install with: apt-get install genisoimage")
-		}
+		return "", fmt.Errorf("failed to detect boot configuration: %w", err)
+	}
+	if !config.HasUEFIBoot {
+		return "", fmt.Errorf("no EFI boot image found in the ISO for efi_boot_content, set efi_boot_image")
+	}
+	if _, ok := m.files[config.UEFIBootImage]; ok {
+		return "", fmt.Errorf("EFI boot image %s is replaced by cd_content or cd_files, and cannot take efi_boot_content", config.UEFIBootImage)
+	}
+
+	image := filepath.Join(dir, config.UEFIBootImage)
+	if err := m.extractFile(config.UEFIBootImage, image); err != nil {
+		return "", fmt.Errorf("failed to extract EFI boot image %s: %w", config.UEFIBootImage, err)
 	}
 
-	// Create temporary directory for extraction
-	extractDir, err := os.MkdirTemp("", "packer-iso-extract-")
+	contentDir, err := os.MkdirTemp(dir, "packer-efi-")
 	if err != nil {
-		return "", fmt.Errorf("failed to create extract directory: %w", err)
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
 	}
-	defer os.RemoveAll(extractDir)
+	n := 0
+	for path, content := range m.efiFiles {
+		// Parent directories first, mmd failing on existing ones
+		parts := strings.Split(path, "/")
+		for i := 1; i < len(parts); i++ {
+			parent := "::/" + strings.Join(parts[:i], "/")
+			if runMtools("mdir", "-i", image, parent) != nil {
+				if err := runMtools("mmd", "-i", image, parent); err != nil {
+					return "", fmt.Errorf("failed to create %s in EFI boot image: %w", parent, err)
+				}
+			}
+		}
 
-	// Extract the source ISO using 7z (handles UDF filesystems properly)
-	// 7z x = extract with full paths, -o = output directory
-	extractArgs := []string{
-		"x",
-		m.sourcePath,
-		"-o" + extractDir,
-		"-y", // Yes to all prompts
+		n++
+		local := filepath.Join(contentDir, fmt.Sprintf("file%d", n))
+		if err := os.WriteFile(local, content, 0644); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		if err := runMtools("mcopy", "-o", "-i", image, local, "::/"+path); err != nil {
+			return "", fmt.Errorf("failed to write %s to EFI boot image %s: %w", path, config.UEFIBootImage, err)
+		}
 	}
 
-	cmd := exec.Command(sevenZipPath, extractArgs...)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	return config.UEFIBootImage, nil
+}
 
+// runMtools runs an mtools command on a FAT image. The geometry check is
+// skipped, as EFI boot images rarely have a consistent one.
+func runMtools(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(), "MTOOLS_SKIP_CHECK=1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
 	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("7z extract failed: %w\nstderr: %s\nstdout: %s", err, stderr.String(), stdout.String())
+		return fmt.Errorf("%s failed: %w: %s", name, err, strings.TrimSpace(stderr.String()))
 	}
+	return nil
+}
 
-	// Verify extraction worked by checking for common Windows ISO files
-	bootDir := filepath.Join(extractDir, "boot")
-	if _, err := os.Stat(bootDir); os.IsNotExist(err) {
-		// Try lowercase
-		bootDir = filepath.Join(extractDir, "Boot")
-		if _, err := os.Stat(bootDir); os.IsNotExist(err) {
-			return "", fmt.Errorf("extraction appears to have failed: 'boot' directory not found in extracted ISO")
-		}
+// extractFile copies the file at isoPath in the source ISO to localPath
+func (m *ISOModifier) extractFile(isoPath, localPath string) error {
+	d, err := diskfs.Open(m.sourcePath, diskfs.WithOpenMode(diskfs.ReadOnly))
+	if err != nil {
+		return fmt.Errorf("failed to open ISO: %w", err)
 	}
+	defer d.Backend.Close()
 
-	// Add new files to the extracted directory
-	for path, content := range m.files {
-		fullPath := filepath.Join(extractDir, path)
+	fs, err := d.GetFilesystem(0)
+	if err != nil {
+		return fmt.Errorf("failed to get filesystem: %w", err)
+	}
+	src, err := fs.OpenFile("/"+isoPath, os.O_RDONLY)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
 
-		// Create parent directories
-		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
-			return "", fmt.Errorf("failed to create directory for %s: %w", path, err)
-		}
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return err
+	}
+	dst, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
 
-		// Write file
-		if err := os.WriteFile(fullPath, content, 0644); err != nil {
-			return "", fmt.Errorf("failed to write file %s: %w", path, err)
+// hasJoliet checks if the ISO has a Joliet tree: a supplementary volume
+// descriptor with one of the UCS-2 escape sequences
+func hasJoliet(isoPath string) (bool, error) {
+	f, err := os.Open(isoPath)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	// Volume descriptors start at sector 16 and end with a terminator
+	buf := make([]byte, 2048)
+	for sector := 16; sector < 16+32; sector++ {
+		if _, err := f.ReadAt(buf, int64(sector)*2048); err != nil {
+			return false, err
+		}
+		if string(buf[1:6]) != "CD001" {
+			// Not an ISO9660 descriptor
+			continue
+		}
+		switch buf[0] {
+		case 255:
+			return false, nil
+		case 2:
+			switch string(buf[88:91]) {
+			case "%/@", "%/C", "%/E":
+				return true, nil
+			}
 		}
 	}
+	return false, nil
+}
 
-	// Detect boot files from the extracted directory structure
-	// Windows ISOs use specific paths for BIOS and UEFI boot images
-	var biosBootImage, uefiBootImage string
+// createModifiedUDFISO rebuilds a Windows ISO (UDF/ISO9660 bridge) in-process.
+// The files of the source ISO are copied straight from it into the new UDF
+// file system, next to the added files, so nothing is extracted to disk.
+func (m *ISOModifier) createModifiedUDFISO(outputPath string) (string, error) {
+	if len(m.efiFiles) > 0 {
+		return "", fmt.Errorf("efi_boot_content needs the ISO to be modified with xorriso, set iso_builder_tool to xorriso")
+	}
 
-	// Check for Windows BIOS boot image
-	biosBootPaths := []string{
-		"boot/etfsboot.com",
-		"Boot/etfsboot.com",
-		"BOOT/ETFSBOOT.COM",
+	src, err := os.Open(m.sourcePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open ISO: %w", err)
 	}
-	for _, path := range biosBootPaths {
-		fullPath := filepath.Join(extractDir, path)
-		if _, err := os.Stat(fullPath); err == nil {
-			biosBootImage = path
-			break
-		}
+	defer src.Close()
+
+	img, err := udf.Open(src)
+	if err != nil {
+		return "", fmt.Errorf("failed to read UDF filesystem: %w", err)
 	}
 
-	// Check for Windows UEFI boot image
-	uefiBootPaths := []string{
-		"efi/microsoft/boot/efisys.bin",
-		"EFI/Microsoft/Boot/efisys.bin",
-		"EFI/MICROSOFT/BOOT/EFISYS.BIN",
-		"efi/microsoft/boot/efisys_noprompt.bin",
-		"EFI/Microsoft/Boot/efisys_noprompt.bin",
+	volumeID := m.volumeID
+	if volumeID == "" {
+		volumeID = img.VolumeID()
 	}
-	for _, path := range uefiBootPaths {
-		fullPath := filepath.Join(extractDir, path)
-		if _, err := os.Stat(fullPath); err == nil {
-			uefiBootImage = path
-			break
+	if volumeID == "" {
+		volumeID = m.getVolumeID()
+	}
+	w := udf.NewWriter(volumeID)
+
+	err = img.Walk(func(f *udf.File) error {
+		if f.IsDir {
+			return w.AddDir(f.Path, f.ModTime)
 		}
+		if f.Link != "" {
+			return w.AddSymlink(f.Path, f.Link, f.ModTime)
+		}
+		return w.AddFile(f.Path, f.Size, f.ModTime, func() (io.Reader, error) {
+			return f.Open(), nil
+		})
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read source ISO: %w", err)
 	}
 
-	// Build mkisofs command to recreate ISO with UDF and boot support
-	// Use flags compatible with Windows ISOs
-	mkisofsArgs := []string{
-		"-o", outputPath,
-		"-V", m.getVolumeID(),
-		"-iso-level", "3",       // Allow files > 4GB
-		"-J",                    // Joliet extensions for Windows
-		"-joliet-long",          // Allow long Joliet names
-		"-udf",                  // Generate UDF filesystem
-		"-allow-limited-size",   // Support large files in ISO9660/UDF
-		"-r",                    // Rock Ridge for Unix compatibility
+	// Add new files, replacing those of the source with the same path
+	if err := m.addToUDF(w); err != nil {
+		return "", err
+	}
+	wimDir, err := os.MkdirTemp(m.workDir, "packer-iso-add-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(wimDir)
+	if err := m.addBootWIMToUDF(w, wimDir); err != nil {
+		return "", err
 	}
 
-	// Add BIOS boot if found
-	if biosBootImage != "" {
-		mkisofsArgs = append(mkisofsArgs,
-			"-b", biosBootImage,
-			"-no-emul-boot",
-			"-boot-load-seg", "0x07C0",
-			"-boot-load-size", "8", // Windows uses 8 sectors
-		)
+	boot := m.udfBootOptions(w)
+	if boot.BIOSImage == "" && boot.UEFIImage == "" {
+		return "", fmt.Errorf("no boot image found in the ISO, set the BIOS or EFI boot image")
 	}
+	w.SetBoot(boot)
 
-	// Add UEFI boot if found
-	if uefiBootImage != "" {
-		// Use eltorito-alt-boot for dual BIOS/UEFI
-		if biosBootImage != "" {
-			mkisofsArgs = append(mkisofsArgs, "-eltorito-alt-boot")
-		}
-		mkisofsArgs = append(mkisofsArgs,
-			"-e", uefiBootImage,
-			"-no-emul-boot",
-		)
+	if err := writeUDFISO(w, outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", err
 	}
 
-	// Add boot catalog if we have any boot entries
-	if biosBootImage != "" || uefiBootImage != "" {
-		mkisofsArgs = append(mkisofsArgs, "-c", "boot.cat")
+	// Calculate checksum
+	checksum, err := m.calculateChecksum(outputPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to calculate checksum: %w", err)
 	}
 
-	// Add source directory
-	mkisofsArgs = append(mkisofsArgs, extractDir)
+	return checksum, nil
+}
 
-	// Run mkisofs
-	cmd = exec.Command(mkisofsPath, mkisofsArgs...)
-	stdout.Reset()
-	stderr.Reset()
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+// createAppendedUDFISO adds the files to a copy of a Windows ISO, after its
+// sectors, instead of rebuilding it. Only the appended sectors are written
+// when the copy is a clone, so it needs little space and time. The boot
+// records of the source are kept as they are.
+func (m *ISOModifier) createAppendedUDFISO(outputPath string) (string, error) {
+	if len(m.efiFiles) > 0 {
+		return "", fmt.Errorf("efi_boot_content needs the ISO to be modified with xorriso, set iso_builder_tool to xorriso")
+	}
+	if !m.boot.IsZero() {
+		return "", fmt.Errorf("the boot records of the ISO are kept when appending to it, and cannot be overridden")
+	}
 
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("mkisofs failed: %w\nstderr: %s\nstdout: %s", err, stderr.String(), stdout.String())
+	src, err := os.Open(m.sourcePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open ISO: %w", err)
+	}
+	defer src.Close()
+	fi, err := src.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	img, err := udf.Open(src)
+	if err != nil {
+		return "", fmt.Errorf("failed to read UDF filesystem: %w", err)
+	}
+	a, err := udf.NewAppender(img, fi.Size())
+	if err != nil {
+		return "", fmt.Errorf("failed to read source ISO: %w", err)
+	}
+	a.SetVolumeID(m.volumeID)
+	if err := m.addToUDF(a); err != nil {
+		return "", err
+	}
+	wimDir, err := os.MkdirTemp(m.workDir, "packer-iso-add-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(wimDir)
+	if err := m.addBootWIMToUDF(a, wimDir); err != nil {
+		return "", err
+	}
+
+	if err := appendUDFISO(a, src, outputPath); err != nil {
+		os.Remove(outputPath)
+		return "", err
 	}
 
 	// Calculate checksum
@@ -493,6 +1083,78 @@ func (m *ISOModifier) createModifiedUDFISO(outputPath string) (string, error) {
 	return checksum, nil
 }
 
+// appendUDFISO copies src to path and appends the files of a to the copy
+func appendUDFISO(a *udf.Appender, src *os.File, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	cloned, err := copyImage(f, src)
+	if err != nil {
+		return fmt.Errorf("failed to copy ISO: %w", err)
+	}
+	log.Printf("Copied %s to %s, cloned: %v", src.Name(), path, cloned)
+
+	if _, err := a.Append(f); err != nil {
+		return fmt.Errorf("failed to append to ISO: %w", err)
+	}
+	return f.Close()
+}
+
+// udfBootOptions finds the Windows boot images of the ISO being rebuilt and
+// applies the overrides. UDF names are matched case-insensitively.
+func (m *ISOModifier) udfBootOptions(w *udf.Writer) udf.BootOptions {
+	boot := udf.BootOptions{
+		BIOSLoadSegment: m.boot.BIOSLoadSegment,
+		BIOSLoadSize:    8, // Windows uses 8 sectors
+		BootInfoTable:   m.boot.NeedsBootInfoTbl,
+	}
+	if m.boot.BIOSLoadSize != 0 {
+		boot.BIOSLoadSize = m.boot.BIOSLoadSize
+	}
+
+	if isDir, ok := w.Lookup("boot/etfsboot.com"); ok && !isDir {
+		boot.BIOSImage = "boot/etfsboot.com"
+	}
+	for _, path := range []string{
+		"efi/microsoft/boot/efisys.bin",
+		"efi/microsoft/boot/efisys_noprompt.bin",
+	} {
+		if isDir, ok := w.Lookup(path); ok && !isDir {
+			boot.UEFIImage = path
+			break
+		}
+	}
+
+	if m.boot.BIOSBootImage != "" {
+		boot.BIOSImage = strings.TrimPrefix(m.boot.BIOSBootImage, "/")
+	}
+	if m.boot.UEFIBootImage != "" {
+		boot.UEFIImage = strings.TrimPrefix(m.boot.UEFIBootImage, "/")
+	}
+	return boot
+}
+
+// writeUDFISO writes the image built by w to path
+func writeUDFISO(w *udf.Writer, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	buf := bufio.NewWriterSize(f, 1<<20)
+	if _, err := w.WriteTo(buf); err != nil {
+		return fmt.Errorf("failed to write ISO: %w", err)
+	}
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("failed to write ISO: %w", err)
+	}
+	return f.Close()
+}
+
 // getVolumeID extracts the volume ID from the source ISO
 func (m *ISOModifier) getVolumeID() string {
 	f, err := os.Open(m.sourcePath)
@@ -518,4 +1180,3 @@ func (m *ISOModifier) getVolumeID() string {
 
 	return "DISK"
 }
-
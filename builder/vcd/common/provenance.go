@@ -0,0 +1,57 @@
+package common
+
+import (
+	"os"
+	"time"
+
+	packercommon "github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/uuid"
+	"github.com/juanfont/packer-plugin-vcd/version"
+)
+
+// ProvenanceKeyPrefix is the prefix of the provenance metadata keys written
+// onto captured templates, shared with the vcd-metadata post-processor.
+const ProvenanceKeyPrefix = "packer."
+
+// gitEnvVars lists, per CI system, the environment variables holding the
+// commit and the branch or tag being built.
+var gitEnvVars = []struct{ sha, ref string }{
+	{"GITHUB_SHA", "GITHUB_REF"},                  // GitHub Actions
+	{"CI_COMMIT_SHA", "CI_COMMIT_REF_NAME"},       // GitLab CI
+	{"BUILD_SOURCEVERSION", "BUILD_SOURCEBRANCH"}, // Azure Pipelines
+	{"BITBUCKET_COMMIT", "BITBUCKET_BRANCH"},      // Bitbucket Pipelines
+	{"GIT_COMMIT", "GIT_BRANCH"},                  // Jenkins
+}
+
+// Provenance returns the build provenance entries to write onto a captured
+// template: build date and UUID, Packer and plugin versions, the git commit
+// and ref the CI system is building, and the given source entries (e.g.
+// "source_iso_url"), all prefixed with ProvenanceKeyPrefix. Empty values
+// are left out.
+func Provenance(pc *packercommon.PackerConfig, source map[string]string) map[string]string {
+	entries := map[string]string{
+		"build_date":     time.Now().UTC().Format(time.RFC3339),
+		"build_uuid":     uuid.TimeOrderedUUID(),
+		"version":        pc.PackerCoreVersion,
+		"build_name":     pc.PackerBuildName,
+		"plugin_version": version.PluginVersion.FormattedVersion(),
+	}
+	for _, vars := range gitEnvVars {
+		if sha := os.Getenv(vars.sha); sha != "" {
+			entries["git_sha"] = sha
+			entries["git_ref"] = os.Getenv(vars.ref)
+			break
+		}
+	}
+	for key, value := range source {
+		entries[key] = value
+	}
+
+	provenance := make(map[string]string, len(entries))
+	for key, value := range entries {
+		if value != "" {
+			provenance[ProvenanceKeyPrefix+key] = value
+		}
+	}
+	return provenance
+}
@@ -0,0 +1,81 @@
+package common
+
+import (
+	"context"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+)
+
+// StepAddCDDrive adds a second CD-ROM drive to the VM for cd_content and
+// cd_files, so that StepModifyISO puts them on an ISO of their own instead
+// of rebuilding the boot ISO. When the drive cannot be added, the boot ISO
+// is rebuilt as usual. It must run after StepAttachFloppy, whose floppy
+// files may go on the ISO too, and before StepModifyISO.
+type StepAddCDDrive struct {
+	Enabled bool
+	Config  *commonsteps.CDConfig
+	// Binary files for the ISO, see StepModifyISO
+	BinaryContent map[string][]byte
+}
+
+func (s *StepAddCDDrive) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	if !s.Enabled {
+		return multistep.ActionContinue
+	}
+	floppyInISO, _ := state.Get("floppy_in_iso").(bool)
+	hasCD := s.Config != nil && (len(s.Config.CDContent) > 0 || len(s.Config.CDFiles) > 0) ||
+		len(s.BinaryContent) > 0
+	if !hasCD && !floppyInISO {
+		return multistep.ActionContinue
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	vm := state.Get("vm").(driver.VirtualMachine)
+
+	ui.Say("Adding a second CD-ROM drive for cd_content/cd_files...")
+	deviceID, err := vm.AddCDDrive()
+	if err != nil {
+		ui.Errorf("Warning: failed to add CD-ROM drive, adding the files to the ISO instead: %s", err)
+		return multistep.ActionContinue
+	}
+	state.Put("cd_drive_id", deviceID)
+
+	ui.Sayf("CD-ROM drive added: %s", deviceID)
+	return multistep.ActionContinue
+}
+
+func (s *StepAddCDDrive) Cleanup(state multistep.StateBag) {
+	ui := state.Get("ui").(packersdk.Ui)
+	if err := removeCDDrive(ui, state); err != nil {
+		ui.Errorf("Error removing CD-ROM drive: %s", err)
+	}
+}
+
+// removeCDDrive empties and removes the CD-ROM drive added by
+// StepAddCDDrive, if any, so that it is not captured with the VM. The VM
+// must be powered off.
+func removeCDDrive(ui packersdk.Ui, state multistep.StateBag) error {
+	deviceID, ok := state.Get("cd_drive_id").(string)
+	if !ok {
+		return nil
+	}
+	vm := state.Get("vm").(driver.VirtualMachine)
+
+	if _, ok := state.GetOk("cd_media"); ok {
+		ui.Say("Ejecting CD image...")
+		if err := vm.SetDriveMedia(deviceID, nil); err != nil {
+			return err
+		}
+		state.Remove("cd_media")
+	}
+
+	ui.Sayf("Removing CD-ROM drive: %s", deviceID)
+	if err := vm.RemoveCDDrive(deviceID); err != nil {
+		return err
+	}
+	state.Remove("cd_drive_id")
+	return nil
+}
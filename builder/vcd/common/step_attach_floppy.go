@@ -0,0 +1,128 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+)
+
+// StepAttachFloppy uploads the floppy image made from floppy_files,
+// floppy_dirs and floppy_content and inserts it into the VM's floppy drive.
+// VMs without a floppy drive get the files on the ISO instead, so this step
+// must run before StepModifyISO.
+type StepAttachFloppy struct {
+	// How long to wait for the media to resolve, and then for the VM to
+	// accept it while busy.
+	ResolveTimeout time.Duration
+
+	media *govcd.Media
+}
+
+func (s *StepAttachFloppy) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	floppyPath, ok := state.Get("floppy_path").(string)
+	if !ok || floppyPath == "" {
+		return multistep.ActionContinue
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	d := state.Get("driver").(driver.Driver)
+	vm := state.Get("vm").(driver.VirtualMachine)
+	catalog := state.Get("catalog").(*govcd.Catalog)
+
+	hasDrive, err := vm.HasFloppyDrive()
+	if err != nil {
+		state.Put("error", fmt.Errorf("error checking the VM for a floppy drive: %w", err))
+		return multistep.ActionHalt
+	}
+	if !hasDrive {
+		// The ISO of iso_media_name is not downloaded
+		if _, ok := state.GetOk("iso_path"); !ok {
+			state.Put("error", fmt.Errorf("VM has no floppy drive, and the floppy files cannot be added to the ISO of iso_media_name"))
+			return multistep.ActionHalt
+		}
+		ui.Say("VM has no floppy drive, adding the floppy files to the ISO instead")
+		state.Put("floppy_in_iso", true)
+		return multistep.ActionContinue
+	}
+
+	// Floppies are specific to the build, so they are never cached
+	mediaName := fmt.Sprintf("%s-floppy-%d.flp", vm.GetName(), time.Now().Unix())
+	ui.Sayf("Uploading floppy image: %s", mediaName)
+	media, err := d.UploadFloppyImage(ctx, catalog, mediaName, "Packer floppy upload", floppyPath)
+	if errors.Is(err, driver.ErrMediaNameTaken) {
+		mediaName = fmt.Sprintf("%s-floppy-%d.flp", vm.GetName(), time.Now().UnixNano())
+		media, err = d.UploadFloppyImage(ctx, catalog, mediaName, "Packer floppy upload", floppyPath)
+	}
+	if err != nil {
+		state.Put("error", fmt.Errorf("error uploading floppy image: %w", err))
+		return multistep.ActionHalt
+	}
+	s.media = media
+
+	media, err = d.WaitForMediaResolved(ctx, catalog, media.Media.ID, s.ResolveTimeout, func(status string) {
+		ui.Message(fmt.Sprintf("Media status: %s", status))
+	})
+	if err != nil {
+		state.Put("error", fmt.Errorf("error waiting for floppy image: %w", err))
+		return multistep.ActionHalt
+	}
+	s.media = media
+
+	ui.Say("Inserting floppy image...")
+	if err := vm.InsertMedia(ctx, media, s.ResolveTimeout); err != nil {
+		state.Put("error", fmt.Errorf("error inserting floppy image: %w", err))
+		return multistep.ActionHalt
+	}
+	state.Put("floppy_media", media)
+
+	ui.Say("Floppy image inserted successfully")
+	return multistep.ActionContinue
+}
+
+func (s *StepAttachFloppy) Cleanup(state multistep.StateBag) {
+	if s.media == nil {
+		return
+	}
+	ui := state.Get("ui").(packersdk.Ui)
+
+	if err := ejectFloppy(ui, state); err != nil {
+		ui.Errorf("Error ejecting floppy image: %s", err)
+		// Media still inserted cannot be deleted
+		return
+	}
+
+	// The temporary catalog is deleted with everything in it
+	if tempCatalog, _ := state.Get("temp_catalog").(bool); tempCatalog {
+		return
+	}
+	ui.Sayf("Deleting floppy image: %s", s.media.Media.Name)
+	task, err := s.media.Delete()
+	if err == nil {
+		err = driver.WaitTask(context.Background(), &task)
+	}
+	if err != nil {
+		ui.Errorf("Error deleting floppy image: %s", err)
+	}
+}
+
+// ejectFloppy ejects the floppy image inserted by StepAttachFloppy, if any.
+// VCD cannot capture a vApp with media inserted.
+func ejectFloppy(ui packersdk.Ui, state multistep.StateBag) error {
+	media, ok := state.Get("floppy_media").(*govcd.Media)
+	if !ok {
+		return nil
+	}
+	vm := state.Get("vm").(driver.VirtualMachine)
+	ui.Sayf("Ejecting floppy image: %s", media.Media.Name)
+	if err := vm.EjectMedia(media); err != nil {
+		return err
+	}
+	state.Remove("floppy_media")
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
@@ -23,6 +24,47 @@ type BootCommandConfig struct {
 
 	// Time in ms to wait between each key press. Defaults to 100ms.
 	BootKeyInterval time.Duration `mapstructure:"boot_key_interval"`
+	// The keyboard layout the guest uses while the boot command is typed, so
+	// characters are sent with the keys that type them on that layout. One
+	// of `us`, `uk`, `de`, `fr` and `es`. Boot loaders usually use `us` even
+	// when the installer is configured for another layout. Characters that
+	// are dead keys on the layout, such as `^` on `de`, are followed by a
+	// space. Defaults to `us`.
+	BootKeyboardLayout string `mapstructure:"boot_keyboard_layout"`
+	// Path of a file to write a transcript of the boot command to. Every
+	// `boot_command` entry is recorded after template interpolation, along
+	// with the time it was sent to the console. Values of sensitive variables
+	// are replaced by `<sensitive>`. No transcript is written by default.
+	BootCommandTranscript string `mapstructure:"boot_command_transcript"`
+	// Set to true if the guest reboots while the boot command is being sent,
+	// e.g. between the stages of Windows setup. When the console connection
+	// drops, Packer waits for the VM to be powered on again, reconnects to
+	// the console and continues with the next `boot_command` entry instead of
+	// failing. The entry that was interrupted is not sent again. When unset, a
+	// console connection that drops, e.g. because the console proxy recycled
+	// it, is reconnected and the entry resumes from the keystroke that could
+	// not be sent. Defaults to false.
+	RebootExpected bool `mapstructure:"reboot_expected"`
+	// How long to wait for the VM to come back after a reboot when
+	// `reboot_expected` is set. Defaults to 15m.
+	RebootTimeout time.Duration `mapstructure:"reboot_timeout"`
+	// Text to wait for on the console before sending each `boot_command`
+	// entry, in the same order: the first item gates the first entry, and so
+	// on. Empty items, and entries past the end of the list, are sent without
+	// waiting. Matching is case insensitive and ignores spacing, e.g.
+	// `["Press any key", "", "Install Windows"]`. The screen is read with
+	// `tesseract`, which must be installed. `boot_wait` still applies before
+	// the first entry, and can be lowered when the first item is set.
+	WaitForText []string `mapstructure:"wait_for_text"`
+	// How long to wait for each `wait_for_text` item to appear. Defaults to
+	// 10m.
+	WaitForTextTimeout time.Duration `mapstructure:"wait_for_text_timeout"`
+	// How long to retry reaching the console proxy of VCD before the boot
+	// command is sent. The console proxy often sits on another network than
+	// the API; when it cannot be reached from the host running Packer the
+	// build fails with an error saying so, instead of a console connection
+	// failure that looks like a refused ticket. Defaults to 2m.
+	ConsoleProbeTimeout time.Duration `mapstructure:"console_probe_timeout"`
 }
 
 func (c *BootCommandConfig) Prepare(ctx *interpolate.Context) []error {
@@ -37,6 +79,36 @@ func (c *BootCommandConfig) Prepare(ctx *interpolate.Context) []error {
 		c.BootWait = 0
 	}
 
+	if c.BootKeyboardLayout == "" {
+		c.BootKeyboardLayout = "us"
+	}
+	if _, err := driver.KeyboardLayout(c.BootKeyboardLayout); err != nil {
+		errs = append(errs, fmt.Errorf("invalid 'boot_keyboard_layout': %w", err))
+	}
+
+	if c.RebootTimeout == 0 {
+		c.RebootTimeout = 15 * time.Minute
+	}
+
+	if len(c.WaitForText) > len(c.BootCommand) {
+		errs = append(errs, fmt.Errorf("'wait_for_text' has %d items but 'boot_command' only %d entries",
+			len(c.WaitForText), len(c.BootCommand)))
+	}
+	for _, text := range c.WaitForText {
+		if text != "" {
+			if err := driver.CheckOCR(); err != nil {
+				errs = append(errs, fmt.Errorf("'wait_for_text': %w", err))
+			}
+			break
+		}
+	}
+	if c.WaitForTextTimeout == 0 {
+		c.WaitForTextTimeout = 10 * time.Minute
+	}
+	if c.ConsoleProbeTimeout == 0 {
+		c.ConsoleProbeTimeout = 2 * time.Minute
+	}
+
 	return errs
 }
 
@@ -45,18 +117,8 @@ type StepBootCommand struct {
 	Config *BootCommandConfig
 	VMName string
 	Ctx    interpolate.Context
-}
-
-type bootCommandTemplateData struct {
-	HTTPIP   string
-	HTTPPort int
-	Name     string
-	// Network info (populated for POOL/MANUAL allocation modes)
-	VMIP      string
-	VMGateway string
-	VMNetmask string
-	VMPrefix  string // CIDR prefix (e.g., "24" for 255.255.255.0)
-	VMDNS     string
+	// Values masked in the boot command transcript and logs
+	SensitiveVars []string
 }
 
 func (s *StepBootCommand) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
@@ -79,8 +141,232 @@ func (s *StepBootCommand) Run(ctx context.Context, state multistep.StateBag) mul
 		}
 	}
 
+	wmksClient, err := s.connectConsole(ctx, ui, d, vm)
+	if err != nil {
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
+	// The client is replaced when the console is reacquired after a reboot
+	defer func() {
+		wmksClient.Close()
+	}()
+
+	tplCtx := newTemplateContext(s.Ctx, state, s.VMName)
+
+	// Create boot command driver
+	keyInterval := s.Config.BootKeyInterval
+	if keyInterval == 0 {
+		keyInterval = 100 * time.Millisecond
+	}
+	bootDriver := s.newBootDriver(ctx, ui, d, vm, wmksClient, keyInterval)
+
+	// Parse and execute boot command, one keygroup at a time so each group
+	// can be timestamped in the transcript
+	ui.Say("Sending boot command...")
+
+	packersdk.LogSecretFilter.Set(s.SensitiveVars...)
+	transcript := newBootTranscript(s.VMName, s.SensitiveVars)
+	if s.Config.BootCommandTranscript != "" {
+		defer func() {
+			if err := transcript.WriteFile(s.Config.BootCommandTranscript); err != nil {
+				ui.Errorf("Error writing boot command transcript: %s", err)
+				return
+			}
+			ui.Sayf("Boot command transcript written to %s", s.Config.BootCommandTranscript)
+			state.Put("boot_command_transcript", s.Config.BootCommandTranscript)
+		}()
+	}
+
+	// No delay between groups by default
+	groupInterval := s.Config.BootGroupInterval
+
+	log.Printf("[DEBUG] Starting boot command execution (%d keygroups)", len(s.Config.BootCommand))
+	bootCommandStart := time.Now()
+
+	for i, group := range s.Config.BootCommand {
+		// The guest may have rebooted during the previous keygroup or the
+		// group interval; get a fresh console before sending more keys
+		if s.Config.RebootExpected && !wmksClient.Alive() {
+			wmksClient, bootDriver, err = s.reacquireConsole(ctx, ui, d, vm, wmksClient, keyInterval)
+			if err != nil {
+				state.Put("error", err)
+				return multistep.ActionHalt
+			}
+		}
+
+		if i < len(s.Config.WaitForText) && s.Config.WaitForText[i] != "" {
+			wmksClient, bootDriver, err = s.waitForText(ctx, ui, d, vm, wmksClient, bootDriver, s.Config.WaitForText[i], keyInterval)
+			if err != nil {
+				s.saveErrorScreenshot(ui, wmksClient)
+				state.Put("error", fmt.Errorf("error waiting for boot command keygroup %d: %w", i+1, err))
+				return multistep.ActionHalt
+			}
+		}
+
+		// Interpolate the keygroup to replace {{ .HTTPIP }}, {{ .HTTPPort }}, etc.
+		keys, err := interpolate.Render(group, tplCtx)
+		if err != nil {
+			state.Put("error", fmt.Errorf("error interpolating boot command: %w", err))
+			return multistep.ActionHalt
+		}
+
+		log.Printf("[DEBUG] Boot command keygroup %d interpolated (length=%d chars)", i+1, len(keys))
+
+		seq, err := driver.ParseBootCommand(keys)
+		if err != nil {
+			transcript.Record(i+1, time.Now(), keys, err)
+			state.Put("error", fmt.Errorf("error parsing boot command: %w", err))
+			return multistep.ActionHalt
+		}
+
+		sentAt := time.Now()
+		err = seq.Do(ctx, bootDriver)
+		// The driver replaces the connection when it drops
+		wmksClient = bootDriver.Client()
+		transcript.Record(i+1, sentAt, keys, err)
+		if err != nil && s.Config.RebootExpected && ctx.Err() == nil {
+			// Most likely the guest rebooted under us. The interrupted
+			// keygroup targeted a screen that is gone, so move on to the next.
+			ui.Sayf("Console connection lost during keygroup %d, assuming the guest is rebooting...", i+1)
+			log.Printf("[DEBUG] Keygroup %d interrupted: %v", i+1, err)
+			wmksClient, bootDriver, err = s.reacquireConsole(ctx, ui, d, vm, wmksClient, keyInterval)
+		}
+		if err != nil {
+			elapsed := time.Since(bootCommandStart)
+			log.Printf("[ERROR] Boot command failed at keygroup %d after %s: %v", i+1, elapsed, err)
+			s.saveErrorScreenshot(ui, wmksClient)
+			state.Put("error", fmt.Errorf("error running boot command: %w", err))
+			return multistep.ActionHalt
+		}
+
+		if groupInterval > 0 && i < len(s.Config.BootCommand)-1 {
+			select {
+			case <-time.After(groupInterval):
+			case <-ctx.Done():
+				return multistep.ActionHalt
+			}
+		}
+	}
+
+	// A key left held, e.g. a missing <leftCtrlOff>, would stay stuck in
+	// the guest
+	if err := bootDriver.ReleaseKeys(); err != nil {
+		log.Printf("[WARN] Error releasing held keys: %v", err)
+	}
+
+	elapsed := time.Since(bootCommandStart)
+	log.Printf("[DEBUG] Boot command completed successfully in %s", elapsed)
+	ui.Say("Boot command completed successfully")
+
+	return multistep.ActionContinue
+}
+
+// waitForTextPollInterval is how often the console is read while waiting for
+// wait_for_text.
+const waitForTextPollInterval = 2 * time.Second
+
+// waitForText reads the console until text appears on it. When the guest
+// reboots meanwhile and reboot_expected is set, the console is reacquired
+// and the wait goes on with the new connection.
+func (s *StepBootCommand) waitForText(ctx context.Context, ui packersdk.Ui, d driver.Driver, vm driver.VirtualMachine,
+	wmksClient *driver.WMKSClient, bootDriver *driver.WMKSBootDriver, text string, keyInterval time.Duration) (*driver.WMKSClient, *driver.WMKSBootDriver, error) {
+	ui.Sayf("Waiting for %q on the console (timeout: %s)...", text, s.Config.WaitForTextTimeout)
+
+	want := normalizeScreenText(text)
+	deadline := time.Now().Add(s.Config.WaitForTextTimeout)
+	for {
+		if !wmksClient.Alive() {
+			var err error
+			if s.Config.RebootExpected {
+				wmksClient, bootDriver, err = s.reacquireConsole(ctx, ui, d, vm, wmksClient, keyInterval)
+			} else {
+				wmksClient, bootDriver, err = s.reconnectConsole(ctx, ui, d, vm, wmksClient, keyInterval)
+			}
+			if err != nil {
+				return wmksClient, bootDriver, err
+			}
+		}
+
+		img, err := wmksClient.Screenshot(30 * time.Second)
+		if err == nil {
+			var lines []string
+			lines, err = driver.RecognizeText(ctx, img)
+			if err == nil && strings.Contains(normalizeScreenText(strings.Join(lines, " ")), want) {
+				ui.Sayf("Found %q on the console", text)
+				return wmksClient, bootDriver, nil
+			}
+		}
+		if err != nil {
+			log.Printf("[DEBUG] wait_for_text: error reading console: %v", err)
+		}
+
+		if time.Now().After(deadline) {
+			return wmksClient, bootDriver, fmt.Errorf("%q did not appear on the console within %s", text, s.Config.WaitForTextTimeout)
+		}
+		select {
+		case <-time.After(waitForTextPollInterval):
+		case <-ctx.Done():
+			return wmksClient, bootDriver, ctx.Err()
+		}
+	}
+}
+
+// normalizeScreenText lowercases text and collapses its whitespace, so text
+// matches regardless of how the OCR spaced it.
+func normalizeScreenText(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+// saveErrorScreenshot saves the console screen when the boot command fails,
+// to show what the guest was displaying when the keys did not land.
+func (s *StepBootCommand) saveErrorScreenshot(ui packersdk.Ui, wmksClient *driver.WMKSClient) {
+	if !wmksClient.Alive() {
+		return
+	}
+	img, err := wmksClient.Screenshot(10 * time.Second)
+	if err != nil {
+		log.Printf("[WARN] Could not take a console screenshot: %v", err)
+		return
+	}
+	path := fmt.Sprintf("packer-%s-boot-error.png", s.VMName)
+	if err := driver.WritePNG(path, img); err != nil {
+		log.Printf("[WARN] Could not save console screenshot: %v", err)
+		return
+	}
+	ui.Sayf("Console screenshot saved to %s", path)
+}
+
+// connectConsole acquires an MKS ticket and opens the WMKS console of the VM.
+func (s *StepBootCommand) connectConsole(ctx context.Context, ui packersdk.Ui, d driver.Driver, vm driver.VirtualMachine) (*driver.WMKSClient, error) {
 	ui.Say("Connecting to VM console via WMKS...")
 
+	ticket, err := s.acquireTicket(ctx, ui, d, vm)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tell an unreachable console proxy apart from a refused ticket
+	ticket, err = s.probeConsole(ctx, ui, d, vm, ticket)
+	if err != nil {
+		return nil, err
+	}
+
+	// Connect to console
+	// Trust the console the way the API connection is trusted, including
+	// insecure_connection and ca_file/ca_pem
+	wmksClient := driver.NewWMKSClient(ticket, driver.WithTLSConfig(d.TLSConfig()), driver.WithProxy(d.ConsoleProxy()))
+	if err := wmksClient.Connect(); err != nil {
+		return nil, fmt.Errorf("console proxy %s is reachable but the console connection failed, the MKS ticket may be invalid or expired: %w", ticket.Address(), err)
+	}
+
+	ui.Say("Connected to VM console")
+
+	return wmksClient, nil
+}
+
+// acquireTicket acquires an MKS ticket for the VM, retrying while the console
+// is not ready.
+func (s *StepBootCommand) acquireTicket(ctx context.Context, ui packersdk.Ui, d driver.Driver, vm driver.VirtualMachine) (*driver.MksTicket, error) {
 	// Get the underlying govcd VM to acquire MKS ticket
 	govcdVM := vm.GetVM()
 	client := d.GetClient()
@@ -101,120 +387,163 @@ func (s *StepBootCommand) Run(ctx context.Context, state multistep.StateBag) mul
 		if lastErr == nil {
 			break
 		}
+		// A missing right will not fix itself; retrying only delays the failure
+		if driver.IsForbidden(lastErr) {
+			return nil, fmt.Errorf("VCD denied console access, check that the user's role has the %q right: %w", driver.ConsoleAccessRight, lastErr)
+		}
 		if i < maxRetries-1 {
 			ui.Sayf("Waiting for VM console to be ready (attempt %d/%d)...", i+1, maxRetries)
 			select {
 			case <-time.After(retryDelay):
 			case <-ctx.Done():
-				return multistep.ActionHalt
+				return nil, ctx.Err()
 			}
 		}
 	}
 	if lastErr != nil {
-		state.Put("error", fmt.Errorf("failed to acquire MKS ticket after %d retries: %w", maxRetries, lastErr))
-		return multistep.ActionHalt
+		return nil, fmt.Errorf("failed to acquire MKS ticket after %d retries: %w", maxRetries, lastErr)
 	}
 
 	ui.Sayf("MKS ticket acquired (host: %s, port: %d)", ticket.Host, ticket.Port)
 
-	// Connect to console
-	insecure := true // TODO: get from config
-	wmksClient := driver.NewWMKSClient(ticket, driver.WithInsecure(insecure))
-	if err := wmksClient.Connect(); err != nil {
-		state.Put("error", fmt.Errorf("failed to connect to WMKS console: %w", err))
-		return multistep.ActionHalt
-	}
-	defer wmksClient.Close()
+	return ticket, nil
+}
 
-	ui.Say("Connected to VM console")
+// consoleProbeRetryDelay is the wait between probes of the console proxy.
+const consoleProbeRetryDelay = 10 * time.Second
 
-	// Prepare template data for boot command interpolation
-	httpIP := ""
-	httpPort := 0
-	if ip, ok := state.GetOk("http_ip"); ok {
-		httpIP = ip.(string)
-	}
-	if port, ok := state.GetOk("http_port"); ok {
-		httpPort = port.(int)
-	}
+// mksTicketReuseWindow is how long after it was acquired a ticket is still
+// used to connect. Tickets expire after 30 seconds.
+const mksTicketReuseWindow = 20 * time.Second
 
-	// Get network info from state (populated by StepDiscoverIP)
-	vmIP := ""
-	gateway := ""
-	netmask := ""
-	prefix := ""
-	dns := ""
-	if ip, ok := state.GetOk("vm_ip"); ok {
-		vmIP = ip.(string)
-	}
-	if gw, ok := state.GetOk("network_gateway"); ok {
-		gateway = gw.(string)
-	}
-	if nm, ok := state.GetOk("network_netmask"); ok {
-		netmask = nm.(string)
-		prefix = netmaskToPrefix(netmask)
-	}
-	if d, ok := state.GetOk("network_dns"); ok {
-		dns = d.(string)
-	}
+// probeConsole waits until the console proxy of ticket can be reached, up to
+// console_probe_timeout. A new ticket is returned when the wait outlived the
+// ticket.
+func (s *StepBootCommand) probeConsole(ctx context.Context, ui packersdk.Ui, d driver.Driver, vm driver.VirtualMachine, ticket *driver.MksTicket) (*driver.MksTicket, error) {
+	acquired := time.Now()
+	deadline := acquired.Add(s.Config.ConsoleProbeTimeout)
 
-	s.Ctx.Data = &bootCommandTemplateData{
-		HTTPIP:    httpIP,
-		HTTPPort:  httpPort,
-		Name:      s.VMName,
-		VMIP:      vmIP,
-		VMGateway: gateway,
-		VMNetmask: netmask,
-		VMPrefix:  prefix,
-		VMDNS:     dns,
+	for attempt := 1; ; attempt++ {
+		err := driver.ProbeConsoleProxy(ctx, ticket, d.TLSConfig(), d.ConsoleProxy())
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if time.Now().Add(consoleProbeRetryDelay).After(deadline) {
+			return nil, err
+		}
+		ui.Sayf("Console proxy %s not reachable (attempt %d), retrying: %s", ticket.Address(), attempt, err)
+		select {
+		case <-time.After(consoleProbeRetryDelay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
 	}
 
-	// Create boot command driver
-	keyInterval := s.Config.BootKeyInterval
-	if keyInterval == 0 {
-		keyInterval = 100 * time.Millisecond
+	if time.Since(acquired) > mksTicketReuseWindow {
+		return s.acquireTicket(ctx, ui, d, vm)
 	}
-	bootDriver := driver.NewWMKSBootDriver(wmksClient, keyInterval)
+	return ticket, nil
+}
 
-	// Parse and execute boot command
-	ui.Say("Sending boot command...")
+// reacquireConsole waits for the VM to be powered on again after a reboot,
+// then replaces the dead console connection with a new one. MKS tickets are
+// single use, so a new ticket is acquired.
+func (s *StepBootCommand) reacquireConsole(ctx context.Context, ui packersdk.Ui, d driver.Driver, vm driver.VirtualMachine,
+	old *driver.WMKSClient, keyInterval time.Duration) (*driver.WMKSClient, *driver.WMKSBootDriver, error) {
+	old.Close()
 
-	// Interpolate the boot command to replace {{ .HTTPIP }}, {{ .HTTPPort }}, etc.
-	flatBootCommand, err := interpolate.Render(s.Config.FlatBootCommand(), &s.Ctx)
-	if err != nil {
-		state.Put("error", fmt.Errorf("error interpolating boot command: %w", err))
-		return multistep.ActionHalt
+	if err := waitForPowerCycle(ctx, ui, vm, s.Config.RebootTimeout); err != nil {
+		return old, nil, err
 	}
 
-	log.Printf("[DEBUG] Boot command interpolated (length=%d chars)", len(flatBootCommand))
-
-	seq, err := bootcommand.GenerateExpressionSequence(flatBootCommand)
+	wmksClient, err := s.connectConsole(ctx, ui, d, vm)
 	if err != nil {
-		state.Put("error", fmt.Errorf("error parsing boot command: %w", err))
-		return multistep.ActionHalt
+		return old, nil, fmt.Errorf("error reacquiring console after reboot: %w", err)
 	}
 
-	// Execute boot command with group interval
-	groupInterval := s.Config.BootGroupInterval
-	if groupInterval == 0 {
-		groupInterval = 0 // No delay between groups by default
+	return wmksClient, s.newBootDriver(ctx, ui, d, vm, wmksClient, keyInterval), nil
+}
+
+// reconnectConsole replaces a console connection that dropped while the
+// guest kept running, e.g. because the console proxy recycled it.
+func (s *StepBootCommand) reconnectConsole(ctx context.Context, ui packersdk.Ui, d driver.Driver, vm driver.VirtualMachine,
+	old *driver.WMKSClient, keyInterval time.Duration) (*driver.WMKSClient, *driver.WMKSBootDriver, error) {
+	old.Close()
+
+	ui.Say("Console connection dropped, reconnecting...")
+	wmksClient, err := s.connectConsole(ctx, ui, d, vm)
+	if err != nil {
+		return old, nil, fmt.Errorf("error reconnecting to console: %w", err)
 	}
 
-	log.Printf("[DEBUG] Starting boot command execution (elements in sequence)")
-	bootCommandStart := time.Now()
+	return wmksClient, s.newBootDriver(ctx, ui, d, vm, wmksClient, keyInterval), nil
+}
 
-	if err := seq.Do(ctx, bootDriver); err != nil {
-		elapsed := time.Since(bootCommandStart)
-		log.Printf("[ERROR] Boot command failed after %s: %v", elapsed, err)
-		state.Put("error", fmt.Errorf("error running boot command: %w", err))
-		return multistep.ActionHalt
+// newBootDriver creates the boot driver sending keys on wmksClient. Unless
+// the guest is expected to reboot, in which case a dropped connection means
+// the keygroup is moot, the driver reconnects when the connection drops and
+// resumes from the keystroke that could not be sent.
+func (s *StepBootCommand) newBootDriver(ctx context.Context, ui packersdk.Ui, d driver.Driver, vm driver.VirtualMachine,
+	wmksClient *driver.WMKSClient, keyInterval time.Duration) *driver.WMKSBootDriver {
+	bootDriver := driver.NewWMKSBootDriver(wmksClient, keyInterval, s.Config.BootKeyboardLayout)
+	if !s.Config.RebootExpected {
+		bootDriver.SetReconnect(func() (*driver.WMKSClient, error) {
+			bootDriver.Client().Close()
+			ui.Say("Console connection dropped, reconnecting...")
+			return s.connectConsole(ctx, ui, d, vm)
+		})
 	}
+	return bootDriver
+}
 
-	elapsed := time.Since(bootCommandStart)
-	log.Printf("[DEBUG] Boot command completed successfully in %s", elapsed)
-	ui.Say("Boot command completed successfully")
+// waitForPowerCycle polls the VM status until it is powered on. A reboot
+// initiated by the guest usually keeps the VM powered on in VCD, while a
+// full power cycle goes through POWERED_OFF first; both are accepted.
+func waitForPowerCycle(ctx context.Context, ui packersdk.Ui, vm driver.VirtualMachine, timeout time.Duration) error {
+	const pollInterval = 5 * time.Second
+
+	deadline := time.Now().Add(timeout)
+	cycled := false
+	lastStatus := ""
+
+	for {
+		status, err := vm.GetStatus()
+		if err != nil {
+			log.Printf("[WARN] Error getting VM status while waiting for reboot: %v", err)
+		} else {
+			if status != lastStatus {
+				log.Printf("[DEBUG] VM status during reboot: %s", status)
+				lastStatus = status
+			}
+			if status != "POWERED_ON" {
+				cycled = true
+			} else {
+				if cycled {
+					ui.Say("VM power cycle detected, VM is powered on again")
+				}
+				// Give the console a moment to come back before reconnecting
+				select {
+				case <-time.After(pollInterval):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				return nil
+			}
+		}
 
-	return multistep.ActionContinue
+		if time.Now().After(deadline) {
+			return fmt.Errorf("VM did not come back from reboot within %s (last status: %s)", timeout, lastStatus)
+		}
+
+		select {
+		case <-time.After(pollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 }
 
 func (s *StepBootCommand) Cleanup(state multistep.StateBag) {
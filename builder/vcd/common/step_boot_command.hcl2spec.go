@@ -10,10 +10,17 @@ import (
 // FlatBootCommandConfig is an auto-generated flat version of BootCommandConfig.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatBootCommandConfig struct {
-	BootGroupInterval *string  `mapstructure:"boot_keygroup_interval" cty:"boot_keygroup_interval" hcl:"boot_keygroup_interval"`
-	BootWait          *string  `mapstructure:"boot_wait" cty:"boot_wait" hcl:"boot_wait"`
-	BootCommand       []string `mapstructure:"boot_command" cty:"boot_command" hcl:"boot_command"`
-	BootKeyInterval   *string  `mapstructure:"boot_key_interval" cty:"boot_key_interval" hcl:"boot_key_interval"`
+	BootGroupInterval     *string  `mapstructure:"boot_keygroup_interval" cty:"boot_keygroup_interval" hcl:"boot_keygroup_interval"`
+	BootWait              *string  `mapstructure:"boot_wait" cty:"boot_wait" hcl:"boot_wait"`
+	BootCommand           []string `mapstructure:"boot_command" cty:"boot_command" hcl:"boot_command"`
+	BootKeyInterval       *string  `mapstructure:"boot_key_interval" cty:"boot_key_interval" hcl:"boot_key_interval"`
+	BootKeyboardLayout    *string  `mapstructure:"boot_keyboard_layout" cty:"boot_keyboard_layout" hcl:"boot_keyboard_layout"`
+	BootCommandTranscript *string  `mapstructure:"boot_command_transcript" cty:"boot_command_transcript" hcl:"boot_command_transcript"`
+	RebootExpected        *bool    `mapstructure:"reboot_expected" cty:"reboot_expected" hcl:"reboot_expected"`
+	RebootTimeout         *string  `mapstructure:"reboot_timeout" cty:"reboot_timeout" hcl:"reboot_timeout"`
+	WaitForText           []string `mapstructure:"wait_for_text" cty:"wait_for_text" hcl:"wait_for_text"`
+	WaitForTextTimeout    *string  `mapstructure:"wait_for_text_timeout" cty:"wait_for_text_timeout" hcl:"wait_for_text_timeout"`
+	ConsoleProbeTimeout   *string  `mapstructure:"console_probe_timeout" cty:"console_probe_timeout" hcl:"console_probe_timeout"`
 }
 
 // FlatMapstructure returns a new FlatBootCommandConfig.
@@ -28,10 +35,17 @@ func (*BootCommandConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hc
 // The decoded values from this spec will then be applied to a FlatBootCommandConfig.
 func (*FlatBootCommandConfig) HCL2Spec() map[string]hcldec.Spec {
 	s := map[string]hcldec.Spec{
-		"boot_keygroup_interval": &hcldec.AttrSpec{Name: "boot_keygroup_interval", Type: cty.String, Required: false},
-		"boot_wait":              &hcldec.AttrSpec{Name: "boot_wait", Type: cty.String, Required: false},
-		"boot_command":           &hcldec.AttrSpec{Name: "boot_command", Type: cty.List(cty.String), Required: false},
-		"boot_key_interval":      &hcldec.AttrSpec{Name: "boot_key_interval", Type: cty.String, Required: false},
+		"boot_keygroup_interval":  &hcldec.AttrSpec{Name: "boot_keygroup_interval", Type: cty.String, Required: false},
+		"boot_wait":               &hcldec.AttrSpec{Name: "boot_wait", Type: cty.String, Required: false},
+		"boot_command":            &hcldec.AttrSpec{Name: "boot_command", Type: cty.List(cty.String), Required: false},
+		"boot_key_interval":       &hcldec.AttrSpec{Name: "boot_key_interval", Type: cty.String, Required: false},
+		"boot_keyboard_layout":    &hcldec.AttrSpec{Name: "boot_keyboard_layout", Type: cty.String, Required: false},
+		"boot_command_transcript": &hcldec.AttrSpec{Name: "boot_command_transcript", Type: cty.String, Required: false},
+		"reboot_expected":         &hcldec.AttrSpec{Name: "reboot_expected", Type: cty.Bool, Required: false},
+		"reboot_timeout":          &hcldec.AttrSpec{Name: "reboot_timeout", Type: cty.String, Required: false},
+		"wait_for_text":           &hcldec.AttrSpec{Name: "wait_for_text", Type: cty.List(cty.String), Required: false},
+		"wait_for_text_timeout":   &hcldec.AttrSpec{Name: "wait_for_text_timeout", Type: cty.String, Required: false},
+		"console_probe_timeout":   &hcldec.AttrSpec{Name: "console_probe_timeout", Type: cty.String, Required: false},
 	}
 	return s
 }
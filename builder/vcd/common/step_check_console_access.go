@@ -0,0 +1,43 @@
+package common
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+)
+
+// StepCheckConsoleAccess verifies, before anything is created, that the
+// user may open the VM console the boot command is typed into. A missing
+// right fails the build; if the roles cannot be inspected the build goes
+// on with a warning.
+type StepCheckConsoleAccess struct {
+	Config *BootCommandConfig
+}
+
+func (s *StepCheckConsoleAccess) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	if len(s.Config.BootCommand) == 0 {
+		return multistep.ActionContinue
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	d := state.Get("driver").(driver.Driver)
+
+	err := d.CheckConsoleAccess()
+	if err == nil {
+		return multistep.ActionContinue
+	}
+
+	var accessErr *driver.ConsoleAccessError
+	if errors.As(err, &accessErr) {
+		state.Put("error", accessErr)
+		return multistep.ActionHalt
+	}
+
+	ui.Sayf("Warning: could not verify the %q right needed for boot_command, continuing: %s", driver.ConsoleAccessRight, err)
+	return multistep.ActionContinue
+}
+
+func (s *StepCheckConsoleAccess) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,181 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+	"github.com/vmware/go-vcloud-director/v3/types/v56"
+)
+
+// StepCloneVM adds a new VM to the build vApp using a VM from an existing
+// catalog vApp template as its source.
+type StepCloneVM struct {
+	TemplateCatalog  string
+	Template         string
+	TemplateVM       string
+	Description      string
+	VMName           string
+	StorageProfile   string
+	Network          string
+	IPAllocationMode string
+}
+
+func (s *StepCloneVM) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	d := state.Get("driver").(driver.Driver)
+	vapp := state.Get("vapp").(*govcd.VApp)
+	vdc := state.Get("vdc").(*govcd.Vdc)
+
+	ui.Sayf("Cloning VM %s from template %s/%s", s.VMName, s.TemplateCatalog, s.Template)
+
+	catalog, err := d.GetCatalog(s.TemplateCatalog)
+	if err != nil {
+		state.Put("error", fmt.Errorf("error getting template catalog: %w", err))
+		return multistep.ActionHalt
+	}
+
+	template, err := catalog.GetVAppTemplateByName(s.Template)
+	if err != nil {
+		// Tell the user what the catalog does hold, typos are common
+		if available, lerr := d.ListVAppTemplates(s.TemplateCatalog, driver.VAppTemplateFilter{}); lerr == nil && len(available) > 0 {
+			names := make([]string, 0, len(available))
+			for _, t := range available {
+				names = append(names, t.Name)
+			}
+			err = fmt.Errorf("%w (templates in catalog %s: %s)", err, s.TemplateCatalog, strings.Join(names, ", "))
+		}
+		state.Put("error", fmt.Errorf("error getting vApp template %s: %w", s.Template, err))
+		return multistep.ActionHalt
+	}
+
+	// AddNewVM clones the first VM of the template, so narrow the template
+	// down to the requested VM when one is named.
+	if s.TemplateVM != "" {
+		if template.VAppTemplate.Children == nil {
+			state.Put("error", fmt.Errorf("vApp template %s has no virtual machines", s.Template))
+			return multistep.ActionHalt
+		}
+		var source *types.VAppTemplate
+		for _, child := range template.VAppTemplate.Children.VM {
+			if child.Name == s.TemplateVM {
+				source = child
+				break
+			}
+		}
+		if source == nil {
+			state.Put("error", fmt.Errorf("VM %s not found in vApp template %s", s.TemplateVM, s.Template))
+			return multistep.ActionHalt
+		}
+		template.VAppTemplate.Children.VM = []*types.VAppTemplate{source}
+	}
+
+	var storageProfileRef *types.Reference
+	if s.StorageProfile != "" {
+		sp, err := vdc.FindStorageProfileReference(s.StorageProfile)
+		if err != nil {
+			state.Put("error", fmt.Errorf("error finding storage profile %s: %w", s.StorageProfile, err))
+			return multistep.ActionHalt
+		}
+		storageProfileRef = &sp
+	}
+
+	// Without an explicit network, the NICs from the template are kept as-is.
+	var netSection *types.NetworkConnectionSection
+	network := NetworkName(state, s.Network)
+	if network != "" {
+		netConn := &types.NetworkConnection{
+			Network:                 network,
+			NetworkConnectionIndex:  0,
+			IsConnected:             true,
+			IPAddressAllocationMode: ipAllocationMode(s.IPAllocationMode),
+		}
+
+		if s.IPAllocationMode == "MANUAL" {
+			if vmIP, ok := state.GetOk("vm_ip"); ok {
+				netConn.IPAddress = vmIP.(string)
+				ui.Sayf("Using static IP address: %s", vmIP.(string))
+			}
+		}
+
+		netSection = &types.NetworkConnectionSection{
+			PrimaryNetworkConnectionIndex: 0,
+			NetworkConnection:             []*types.NetworkConnection{netConn},
+		}
+	}
+
+	task, err := vapp.AddNewVMWithStorageProfile(s.VMName, *template, netSection, storageProfileRef, true)
+	if err != nil {
+		state.Put("error", fmt.Errorf("error cloning VM: %w", err))
+		return multistep.ActionHalt
+	}
+	if err := driver.WaitTask(ctx, &task); err != nil {
+		state.Put("error", fmt.Errorf("error waiting for VM clone: %w", err))
+		return multistep.ActionHalt
+	}
+
+	vm, err := vapp.GetVMByName(s.VMName, true)
+	if err != nil {
+		state.Put("error", fmt.Errorf("error getting cloned VM %s: %w", s.VMName, err))
+		return multistep.ActionHalt
+	}
+
+	if s.Description != "" {
+		if _, err := vm.UpdateVmSpecSection(vm.VM.VmSpecSection, s.Description); err != nil {
+			state.Put("error", fmt.Errorf("error setting VM description: %w", err))
+			return multistep.ActionHalt
+		}
+	}
+
+	state.Put("vm", d.NewVM(vm))
+
+	ui.Sayf("VM cloned: %s", s.VMName)
+	return multistep.ActionContinue
+}
+
+func (s *StepCloneVM) Cleanup(state multistep.StateBag) {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	// Only clean up on failure
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	if !cancelled && !halted {
+		return
+	}
+
+	vmRaw, ok := state.GetOk("vm")
+	if !ok {
+		return
+	}
+
+	vm := vmRaw.(driver.VirtualMachine)
+	ui.Sayf("Deleting cloned VM: %s (waiting for completion)...", vm.GetName())
+
+	if on, _ := vm.IsPoweredOn(); on {
+		ui.Say("Powering off VM...")
+		_ = vm.PowerOff(context.Background())
+	}
+
+	if err := vm.GetVM().Delete(); err != nil {
+		ui.Errorf("Error deleting VM: %s", err)
+	} else {
+		ui.Say("VM deleted successfully")
+	}
+}
+
+func ipAllocationMode(mode string) string {
+	switch mode {
+	case "DHCP":
+		return types.IPAllocationModeDHCP
+	case "MANUAL":
+		return types.IPAllocationModeManual
+	case "NONE":
+		return types.IPAllocationModeNone
+	default:
+		return types.IPAllocationModePool
+	}
+}
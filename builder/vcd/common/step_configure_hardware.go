@@ -0,0 +1,134 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+)
+
+type StepHardware struct {
+	Config *HardwareConfig
+}
+
+func (s *StepHardware) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	vm := state.Get("vm").(driver.VirtualMachine)
+	d := state.Get("driver").(driver.Driver)
+
+	// Apply sizing and placement policies together; VCD replaces both at once
+	if s.Config.VMSizingPolicy != "" || s.Config.VMPlacementPolicy != "" {
+		if err := s.applyComputePolicies(ui, state, d, vm); err != nil {
+			state.Put("error", err)
+			return multistep.ActionHalt
+		}
+	}
+
+	if s.Config.VMSizingPolicy == "" {
+		// Manual CPU/memory configuration
+		if s.Config.CPUs > 0 {
+			ui.Sayf("Configuring CPU: %d CPUs, %d cores per socket", s.Config.CPUs, s.Config.CoresPerSocket)
+
+			coresPerSocket := int(s.Config.CoresPerSocket)
+			if coresPerSocket == 0 {
+				coresPerSocket = 1
+			}
+
+			err := vm.ChangeCPU(int(s.Config.CPUs), coresPerSocket)
+			if err != nil {
+				state.Put("error", fmt.Errorf("error configuring CPU: %w", err))
+				return multistep.ActionHalt
+			}
+		}
+
+		if s.Config.Memory > 0 {
+			ui.Sayf("Configuring memory: %d MB", s.Config.Memory)
+			err := vm.ChangeMemory(s.Config.Memory)
+			if err != nil {
+				state.Put("error", fmt.Errorf("error configuring memory: %w", err))
+				return multistep.ActionHalt
+			}
+		}
+	}
+
+	// Created VMs already have the setting; cloned and imported ones
+	// carry whatever their source had
+	if err := vm.SetTimeSync(s.Config.TimeSyncWithHost); err != nil {
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
+
+	if extraConfig := s.Config.VMExtraConfig(); len(extraConfig) > 0 {
+		ui.Sayf("Applying %d extra_config entries", len(extraConfig))
+		if err := vm.ChangeExtraConfig(extraConfig); err != nil {
+			state.Put("error", fmt.Errorf("error applying extra_config: %w", err))
+			return multistep.ActionHalt
+		}
+	}
+
+	// Refresh VM state after hardware changes
+	if err := vm.Refresh(); err != nil {
+		state.Put("error", fmt.Errorf("error refreshing VM after hardware changes: %w", err))
+		return multistep.ActionHalt
+	}
+
+	ui.Say("Hardware configuration complete")
+	return multistep.ActionContinue
+}
+
+// applyComputePolicies sets the configured sizing and placement policies on
+// the VM, keeping whichever of the two is not configured as it is.
+func (s *StepHardware) applyComputePolicies(ui packersdk.Ui, state multistep.StateBag, d driver.Driver, vm driver.VirtualMachine) error {
+	vdc := state.Get("vdc").(*govcd.Vdc)
+
+	policies, err := d.GetClient().GetAllAssignedVdcComputePoliciesV2(vdc.Vdc.ID, url.Values{})
+	if err != nil {
+		return fmt.Errorf("error getting compute policies: %w", err)
+	}
+
+	// Preserve the policies the VM already has
+	govcdVM := vm.GetVM()
+	sizingPolicy := ""
+	placementPolicy := ""
+	if cp := govcdVM.VM.ComputePolicy; cp != nil {
+		if cp.VmSizingPolicy != nil {
+			sizingPolicy = cp.VmSizingPolicy.ID
+		}
+		if cp.VmPlacementPolicy != nil {
+			placementPolicy = cp.VmPlacementPolicy.ID
+		}
+	}
+
+	if s.Config.VMSizingPolicy != "" {
+		ui.Sayf("Applying VM sizing policy: %s", s.Config.VMSizingPolicy)
+		policy, err := driver.GetVMSizingPolicyByName(policies, s.Config.VMSizingPolicy)
+		if err != nil {
+			return fmt.Errorf("VM sizing policy '%s' not found in VDC", s.Config.VMSizingPolicy)
+		}
+		sizingPolicy = policy.VdcComputePolicyV2.ID
+	}
+
+	if s.Config.VMPlacementPolicy != "" {
+		ui.Sayf("Applying VM placement policy: %s", s.Config.VMPlacementPolicy)
+		policy, err := driver.GetVMPlacementPolicy(policies, s.Config.VMPlacementPolicy)
+		if err != nil {
+			return fmt.Errorf("VM placement policy '%s' not found in VDC", s.Config.VMPlacementPolicy)
+		}
+		placementPolicy = policy.VdcComputePolicyV2.ID
+	}
+
+	if _, err := govcdVM.UpdateComputePolicyV2(sizingPolicy, placementPolicy, ""); err != nil {
+		return fmt.Errorf("error applying compute policies: %w", err)
+	}
+
+	ui.Say("VM compute policies applied successfully")
+	return nil
+}
+
+func (s *StepHardware) Cleanup(state multistep.StateBag) {
+	// Nothing to clean up
+}
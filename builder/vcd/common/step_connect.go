@@ -2,26 +2,44 @@ package common
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
 	"log"
+	"os"
 	"reflect"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+	"github.com/vmware/go-vcloud-director/v3/govcd"
 )
 
 type ConnectConfig struct {
 	// The fully qualified domain name or IP address of the vCD Server instance.
 	Host string `mapstructure:"host"`
-	// The username to authenticate with the vCD Server instance.
+	// The organization to log in to. Use `System` to log in as a provider
+	// administrator, together with `tenant_org`.
 	Org string `mapstructure:"org"`
+	// The organization whose VDCs and catalogs the build uses, when logging
+	// in to the `System` org with provider administrator credentials. This
+	// lets a single provider account build in any tenant. Required when
+	// `org` is `System`, not allowed otherwise.
+	TenantOrg string `mapstructure:"tenant_org"`
 	// The username to authenticate with the vCD Server instance.
 	Username string `mapstructure:"username"`
 	// The password to authenticate with the vCD Server instance.
 	Password string `mapstructure:"password"`
 	// The token to authenticate with the vCenter Server instance.
 	Token string `mapstructure:"token"`
+	// An OAuth/OIDC access token obtained externally, e.g. from the identity
+	// provider federated with the organization. It is sent as
+	// `Authorization: Bearer` instead of logging in, for environments where
+	// local accounts and API tokens are disabled. The session is left open
+	// when the build ends, as it belongs to the caller. Conflicts with
+	// `token`.
+	BearerToken string `mapstructure:"bearer_token"`
 
 	// Do not validate the certificate of the vCD Server instance.
 	// Defaults to `false`.
@@ -29,6 +47,86 @@ type ConnectConfig struct {
 	// -> **Note:** This option is beneficial in scenarios where the certificate
 	// is self-signed or does not meet standard validation criteria.
 	InsecureConnection bool `mapstructure:"insecure_connection"`
+	// The path to a PEM file of CA certificates to trust for the vCD Server
+	// instance and its console proxy, in addition to the system roots. Use
+	// it instead of `insecure_connection` when VCD uses a private PKI.
+	CAFile string `mapstructure:"ca_file"`
+	// PEM encoded CA certificates to trust, like `ca_file` but inline, e.g.
+	// from a variable. Both can be set.
+	CAPEM string `mapstructure:"ca_pem"`
+
+	// The number of records requested per page from the VCD query service
+	// when listing vApps, VMs or catalogs. Larger pages mean fewer API calls
+	// on big tenants. Defaults to `128`, the maximum most VCD installations
+	// accept.
+	APIPageSize int `mapstructure:"api_page_size"`
+
+	// The maximum number of ISO/OVF uploads and vApp captures running at the
+	// same time across all builds using this plugin on the host. Builds over
+	// the limit wait for a free slot, so parallel builds do not overload the
+	// provider's transfer service. Defaults to `0` (no limit).
+	MaxConcurrentTransfers int `mapstructure:"max_concurrent_transfers"`
+
+	// The number of ranges of an ISO uploaded at once, over as many
+	// connections, to make use of high-latency links where a single
+	// connection cannot. A failed upload is resumed after the ranges
+	// completed in order, also when retried with `-on-error=ask`, and
+	// deleted if the build gives up on it. Defaults to `1`.
+	UploadParallelism int `mapstructure:"upload_parallelism"`
+	// The maximum rate of ISO uploads, so builds running from offices do
+	// not saturate their uplink, e.g. `50MB/s` or `200Mbit/s`. Byte units
+	// (`B`, `KB`, `MB`, `GB`) are binary, bit units (`Kbit`, `Mbit`, `Gbit`)
+	// decimal. The limit is shared by all the ranges of `upload_parallelism`.
+	// Defaults to no limit.
+	UploadBandwidthLimit string `mapstructure:"upload_bandwidth_limit"`
+
+	// The number of times a VCD API request is retried after a transient
+	// error: HTTP 429 or 5xx, or a refused or reset connection, as returned
+	// by VCD cells behind load balancers. Requests that may have created
+	// something (POST) are only retried if they cannot have been processed.
+	// Defaults to `4`.
+	APIRetryCount int `mapstructure:"api_retry_count"`
+	// The wait before the first retry of a VCD API request. It doubles with
+	// each retry, with random jitter, up to one minute. A `Retry-After`
+	// header sent by VCD takes precedence. Defaults to `2s`.
+	APIRetryWait time.Duration `mapstructure:"api_retry_wait"`
+	// The maximum number of VCD API requests per second, for organizations
+	// whose API throttling is tripped by parallel builds. The limit applies
+	// to each build separately, as Packer runs every build in its own plugin
+	// process: divide the org's budget by the number of parallel builds.
+	// Defaults to `0` (no limit).
+	APIRateLimit float64 `mapstructure:"api_rate_limit"`
+	// How long a VCD task, such as powering on the VM or capturing the
+	// template, may wait in the VCD queue before it is started. Providers
+	// throttling the tasks of an organization queue them; the build reports
+	// queued tasks and fails when one is not started in time. Time spent
+	// running does not count. Defaults to `0` (no limit).
+	QueueTimeout time.Duration `mapstructure:"queue_timeout"`
+	// Log every VCD API call to the Packer log (`PACKER_LOG=1`) as a JSON
+	// line prefixed with `vcd-api`: method, URL, HTTP status, duration, VCD
+	// request ID, the task started and the error message of failed calls.
+	// Request and response bodies are not logged, and tokens, passwords and
+	// transfer URLs are masked, so the log can be attached to support
+	// tickets. Retried requests are logged once per attempt. Defaults to
+	// `false`.
+	APIDebugLog bool `mapstructure:"api_debug_log"`
+
+	// The proxy used to reach the VCD API and the VM console, for build
+	// runners without direct access to VCD, e.g. `http://proxy.example.com:3128`
+	// or `socks5://proxy.example.com:1080`. Credentials can be given in the
+	// URL. Defaults to the `HTTPS_PROXY` environment variable.
+	ProxyURL string `mapstructure:"proxy_url"`
+	// A comma-separated list of hosts, domains (`.example.com`) and CIDR
+	// ranges reached without `proxy_url`, in the `NO_PROXY` format.
+	// Defaults to the `NO_PROXY` environment variable.
+	NoProxy string `mapstructure:"no_proxy"`
+	// The proxy used to reach the VM console (the VCD console proxy, or MKS
+	// host) instead of `proxy_url`, for datacenters where the console proxy
+	// is only reachable through another proxy than the API. http proxies
+	// are used with `CONNECT`. Set to `direct` to reach the console without
+	// a proxy while the API goes through `proxy_url`. Defaults to the proxy
+	// of the API.
+	ConsoleProxyURL string `mapstructure:"console_proxy_url"`
 }
 
 func (c *ConnectConfig) Prepare() []error {
@@ -37,39 +135,144 @@ func (c *ConnectConfig) Prepare() []error {
 	if c.Host == "" {
 		errs = append(errs, fmt.Errorf("'host' is required"))
 	}
-	if c.Token == "" {
+	if c.Token != "" && c.BearerToken != "" {
+		errs = append(errs, fmt.Errorf("'token' and 'bearer_token' are mutually exclusive"))
+	}
+	if c.Token == "" && c.BearerToken == "" {
 		if c.Username == "" {
-			errs = append(errs, fmt.Errorf("'username' is required if 'token' is not provided"))
+			errs = append(errs, fmt.Errorf("'username' is required if 'token' or 'bearer_token' is not provided"))
 		}
 		if c.Password == "" {
-			errs = append(errs, fmt.Errorf("'password' is required if 'token' is not provided"))
+			errs = append(errs, fmt.Errorf("'password' is required if 'token' or 'bearer_token' is not provided"))
 		}
 	}
 
-	if c.Token == "" && c.Username == "" {
-		errs = append(errs, fmt.Errorf("'username' or 'token' is required"))
-	}
-
 	if c.Org == "" {
 		errs = append(errs, fmt.Errorf("'org' is required"))
 	}
+	if strings.EqualFold(c.Org, "System") {
+		if c.TenantOrg == "" {
+			errs = append(errs, fmt.Errorf("'tenant_org' is required when 'org' is System"))
+		}
+	} else if c.TenantOrg != "" {
+		errs = append(errs, fmt.Errorf("'tenant_org' can only be used when 'org' is System"))
+	}
+
+	if c.APIPageSize < 0 {
+		errs = append(errs, fmt.Errorf("'api_page_size' must be a positive number"))
+	}
+
+	if c.MaxConcurrentTransfers < 0 {
+		errs = append(errs, fmt.Errorf("'max_concurrent_transfers' must not be negative"))
+	}
+	if c.UploadParallelism < 0 {
+		errs = append(errs, fmt.Errorf("'upload_parallelism' must not be negative"))
+	}
+	if _, err := driver.ParseBandwidth(c.UploadBandwidthLimit); err != nil {
+		errs = append(errs, fmt.Errorf("'upload_bandwidth_limit': %w", err))
+	}
+
+	if c.APIRetryCount < 0 {
+		errs = append(errs, fmt.Errorf("'api_retry_count' must not be negative"))
+	}
+	if c.APIRetryWait < 0 {
+		errs = append(errs, fmt.Errorf("'api_retry_wait' must not be negative"))
+	}
+
+	if c.APIRateLimit < 0 {
+		errs = append(errs, fmt.Errorf("'api_rate_limit' must not be negative"))
+	}
+
+	if c.QueueTimeout < 0 {
+		errs = append(errs, fmt.Errorf("'queue_timeout' must not be negative"))
+	}
+
+	if c.CAFile != "" || c.CAPEM != "" {
+		if pem, err := c.caCertificates(); err != nil {
+			errs = append(errs, err)
+		} else if !x509.NewCertPool().AppendCertsFromPEM(pem) {
+			errs = append(errs, fmt.Errorf("'ca_file'/'ca_pem' contain no PEM encoded certificate"))
+		}
+	}
+
+	if c.ProxyURL != "" {
+		if _, err := driver.ParseProxyURL(c.ProxyURL); err != nil {
+			errs = append(errs, fmt.Errorf("'proxy_url': %w", err))
+		}
+	}
+	if c.ConsoleProxyURL != "" && c.ConsoleProxyURL != driver.ConsoleProxyDirect {
+		if _, err := driver.ParseProxyURL(c.ConsoleProxyURL); err != nil {
+			errs = append(errs, fmt.Errorf("'console_proxy_url': %w", err))
+		}
+	}
 
 	return errs
 }
 
+// caCertificates returns the PEM data of ca_file followed by ca_pem.
+func (c *ConnectConfig) caCertificates() ([]byte, error) {
+	var pem []byte
+	if c.CAFile != "" {
+		data, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading 'ca_file': %w", err)
+		}
+		pem = append(pem, data...)
+		pem = append(pem, '\n')
+	}
+	return append(pem, c.CAPEM...), nil
+}
+
+// TaskContext returns ctx set up to report the VCD tasks waiting in the queue
+// to ui, and to enforce queue_timeout on them. The progress of uploads and
+// long tasks, such as captures, is reported to ui as well.
+func (c *ConnectConfig) TaskContext(ctx context.Context, ui packersdk.Ui) context.Context {
+	ctx = driver.WithProgress(ctx, ui.Message)
+	return driver.WithTaskQueue(ctx, driver.TaskQueue{
+		Timeout: c.QueueTimeout,
+		Report:  ui.Say,
+	})
+}
+
+// Connect logs in to VCD and returns a driver for the session. Callers
+// outside a step runner (data sources) must call Cleanup on the driver.
+func (c *ConnectConfig) Connect() (driver.Driver, error) {
+	caCertificates, err := c.caCertificates()
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.NewDriver(&driver.ConnectConfig{
+		Host:               c.Host,
+		Org:                c.Org,
+		TenantOrg:          c.TenantOrg,
+		Username:           c.Username,
+		Password:           c.Password,
+		Token:              c.Token,
+		BearerToken:        c.BearerToken,
+		InsecureConnection: c.InsecureConnection,
+		CACertificates:     caCertificates,
+		PageSize:           c.APIPageSize,
+
+		MaxConcurrentTransfers: c.MaxConcurrentTransfers,
+		UploadParallelism:      c.UploadParallelism,
+		UploadBandwidthLimit:   c.UploadBandwidthLimit,
+		RetryCount:             c.APIRetryCount,
+		RetryWait:              c.APIRetryWait,
+		RateLimit:              c.APIRateLimit,
+		ProxyURL:               c.ProxyURL,
+		NoProxy:                c.NoProxy,
+		ConsoleProxyURL:        c.ConsoleProxyURL,
+		APILog:                 c.APIDebugLog,
+	})
+}
+
 type StepConnect struct {
 	Config *ConnectConfig
 }
 
 func (s *StepConnect) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
-	d, err := driver.NewDriver(&driver.ConnectConfig{
-		Host:               s.Config.Host,
-		Org:                s.Config.Org,
-		Username:           s.Config.Username,
-		Password:           s.Config.Password,
-		Token:              s.Config.Token,
-		InsecureConnection: s.Config.InsecureConnection,
-	})
+	d, err := s.Config.Connect()
 	if err != nil {
 		state.Put("error", err)
 		return multistep.ActionHalt
@@ -100,3 +303,24 @@ func (s *StepConnect) Cleanup(state multistep.StateBag) {
 		log.Printf("[WARN] Failed to close VCD client session. The session may already be closed: %s", err.Error())
 	}
 }
+
+// StateError returns the error a step left in the state bag, or nil. VCD
+// quota and lease policy rejections are turned into a driver.LimitError that
+// names the limit and the current usage instead of the raw API message.
+func StateError(state multistep.StateBag) error {
+	rawErr, ok := state.GetOk("error")
+	if !ok {
+		return nil
+	}
+	err := rawErr.(error)
+
+	d, ok := state.GetOk("driver")
+	if !ok {
+		return err
+	}
+	var vdc *govcd.Vdc
+	if v, ok := state.GetOk("vdc"); ok {
+		vdc, _ = v.(*govcd.Vdc)
+	}
+	return d.(driver.Driver).ExplainLimitError(err, vdc)
+}
@@ -0,0 +1,248 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+)
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type ConsoleRecordingConfig
+
+// ConsoleRecordingConfig contains configuration for recording the console
+// during the build.
+type ConsoleRecordingConfig struct {
+	// Record a timelapse of the console from the first power on to the end of
+	// the build, saved as an animated GIF, so a failed unattended install can
+	// be looked at afterwards. The GIF is written to the `output_directory`
+	// of `export` when it is configured, and to the current directory
+	// otherwise, as `<vm_name>-console.gif`. It is written whether the build
+	// succeeds or fails. Defaults to false.
+	ConsoleRecording bool `mapstructure:"console_recording"`
+	// Interval between the frames of `console_recording`. Each frame takes a
+	// console connection, so keep it above a few seconds. Must be at least
+	// 2s. Defaults to 10s.
+	ConsoleRecordingInterval time.Duration `mapstructure:"console_recording_interval"`
+}
+
+func (c *ConsoleRecordingConfig) Prepare() []error {
+	var errs []error
+
+	if c.ConsoleRecordingInterval == 0 {
+		c.ConsoleRecordingInterval = 10 * time.Second
+	}
+	if c.ConsoleRecordingInterval < 2*time.Second {
+		errs = append(errs, fmt.Errorf("'console_recording_interval' must be at least 2s"))
+	}
+
+	return errs
+}
+
+const (
+	// recordingMaxWidth is the width frames are scaled down to, which keeps
+	// a recording of a long install to a reasonable size.
+	recordingMaxWidth = 800
+	// recordingMaxFrames bounds the memory used by the recording: past it,
+	// every other frame is dropped and the frames are shown twice as long.
+	recordingMaxFrames = 1000
+	// recordingFrameDelay is how long one interval is shown on playback, in
+	// hundredths of a second.
+	recordingFrameDelay = 20
+)
+
+// StepConsoleRecording records the console of the VM in the background until
+// the build finishes. The recording is written in Cleanup, so that it covers
+// the steps that follow and is kept when one of them fails.
+type StepConsoleRecording struct {
+	Config *ConsoleRecordingConfig
+	VMName string
+	// Directory the recording is written to. Defaults to the current
+	// directory.
+	OutputDir string
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	mu     sync.Mutex
+	frames []*image.Paletted
+	delays []int
+}
+
+func (s *StepConsoleRecording) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if !s.Config.ConsoleRecording {
+		return multistep.ActionContinue
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	d := state.Get("driver").(driver.Driver)
+	vm := state.Get("vm").(driver.VirtualMachine)
+
+	ui.Sayf("Recording the console every %s", s.Config.ConsoleRecordingInterval)
+
+	// Not bound to ctx: the recording goes on until Cleanup, which also runs
+	// after a cancelled build
+	recordCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		s.record(recordCtx, d, vm)
+	}()
+
+	return multistep.ActionContinue
+}
+
+// record captures a frame every interval until ctx is cancelled. Frames that
+// cannot be captured, e.g. while the VM is powered off, are skipped.
+func (s *StepConsoleRecording) record(ctx context.Context, d driver.Driver, vm driver.VirtualMachine) {
+	ticker := time.NewTicker(s.Config.ConsoleRecordingInterval)
+	defer ticker.Stop()
+
+	for {
+		captureCtx, cancel := context.WithTimeout(ctx, s.Config.ConsoleRecordingInterval)
+		img, err := d.CaptureScreenshot(captureCtx, vm)
+		cancel()
+		if err != nil {
+			log.Printf("[DEBUG] Console recording: skipping frame: %v", err)
+		} else {
+			s.addFrame(img)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// addFrame appends a scaled down frame, or extends the previous frame when
+// the screen did not change.
+func (s *StepConsoleRecording) addFrame(img image.Image) {
+	frame := recordingFrame(img)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n := len(s.frames); n > 0 && samePaletted(s.frames[n-1], frame) {
+		s.delays[n-1] += recordingFrameDelay
+		return
+	}
+	s.frames = append(s.frames, frame)
+	s.delays = append(s.delays, recordingFrameDelay)
+
+	if len(s.frames) > recordingMaxFrames {
+		frames := s.frames[:0]
+		delays := make([]int, 0, len(s.frames)/2+1)
+		for i := 0; i < len(s.frames); i += 2 {
+			delay := s.delays[i]
+			if i+1 < len(s.delays) {
+				delay += s.delays[i+1]
+			}
+			frames = append(frames, s.frames[i])
+			delays = append(delays, delay)
+		}
+		s.frames, s.delays = frames, delays
+	}
+}
+
+// recordingFrame scales img down to recordingMaxWidth, nearest neighbour,
+// and maps it onto the web-safe palette.
+func recordingFrame(img image.Image) *image.Paletted {
+	src := img.Bounds()
+	width, height := src.Dx(), src.Dy()
+	if width > recordingMaxWidth {
+		height = height * recordingMaxWidth / width
+		width = recordingMaxWidth
+	}
+
+	frame := image.NewPaletted(image.Rect(0, 0, width, height), palette.WebSafe)
+	if width == src.Dx() {
+		draw.Draw(frame, frame.Bounds(), img, src.Min, draw.Src)
+		return frame
+	}
+	for y := 0; y < height; y++ {
+		sy := src.Min.Y + y*src.Dy()/height
+		for x := 0; x < width; x++ {
+			frame.Set(x, y, img.At(src.Min.X+x*src.Dx()/width, sy))
+		}
+	}
+	return frame
+}
+
+func samePaletted(a, b *image.Paletted) bool {
+	if a.Bounds() != b.Bounds() {
+		return false
+	}
+	for i := range a.Pix {
+		if a.Pix[i] != b.Pix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *StepConsoleRecording) Cleanup(state multistep.StateBag) {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+
+	ui := state.Get("ui").(packersdk.Ui)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.frames) == 0 {
+		ui.Say("No console frames were recorded")
+		return
+	}
+
+	path := filepath.Join(s.OutputDir, fmt.Sprintf("%s-console.gif", s.VMName))
+	if err := writeRecording(path, s.frames, s.delays); err != nil {
+		ui.Errorf("Error writing console recording: %s", err)
+		return
+	}
+	ui.Sayf("Console recording (%d frames) written to %s", len(s.frames), path)
+	state.Put("console_recording", path)
+}
+
+func writeRecording(path string, frames []*image.Paletted, delays []int) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return fmt.Errorf("error creating %s: %w", dir, err)
+		}
+	}
+
+	// Frames differ in size when the guest changes resolution; the GIF
+	// canvas is the largest of them
+	var config image.Config
+	for _, frame := range frames {
+		size := frame.Bounds().Size()
+		config.Width = max(config.Width, size.X)
+		config.Height = max(config.Height, size.Y)
+	}
+	config.ColorModel = frames[0].Palette
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %w", path, err)
+	}
+	err = gif.EncodeAll(f, &gif.GIF{Image: frames, Delay: delays, Config: config})
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("error encoding %s: %w", path, err)
+	}
+	return f.Close()
+}
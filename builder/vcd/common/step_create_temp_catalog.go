@@ -25,6 +25,13 @@ type CatalogConfig struct {
 	// This catalog is separate from the output catalog where the final vApp template is exported.
 	ISOCatalog string `mapstructure:"iso_catalog"`
 
+	// The name of a media item already in `iso_catalog` to boot the VM
+	// from, for teams that stage their ISOs in a shared catalog. The ISO is
+	// not downloaded, modified or uploaded: it replaces `iso_url`, cannot be
+	// used with the options that modify the ISO, such as `cd_content` or
+	// `boot_parameters`, and floppy files need a VM with a floppy drive.
+	ISOMediaName string `mapstructure:"iso_media_name"`
+
 	// Prefix for temporary catalog names when creating a new catalog.
 	// Only used when iso_catalog is not set.
 	// Defaults to "packer-".
@@ -38,6 +45,34 @@ type CatalogConfig struct {
 	// If true, overwrite existing cached ISO even if it exists in the catalog.
 	// Defaults to false.
 	CacheOverwrite bool `mapstructure:"cache_overwrite"`
+
+	// If true, look for the ISO in every catalog readable by the
+	// organization, including catalogs shared by other teams, before
+	// uploading it, and mount the first ready media with the same checksum.
+	// ISOs uploaded by the plugin record their checksum in the
+	// `packer.iso_checksum` metadata entry, which is what the search matches.
+	// Requires a literal `iso_checksum` such as `sha256:...`. Ignored with
+	// `cache_overwrite`. Defaults to `false`.
+	ISOSearch bool `mapstructure:"iso_search"`
+
+	// How long to wait for the ISO to finish importing in the catalog and
+	// for the VM to accept it while either is busy, before giving up.
+	// Defaults to `10m`.
+	MediaResolveTimeout time.Duration `mapstructure:"media_resolve_timeout"`
+
+	// How long to wait for another build that is uploading the same ISO to
+	// `iso_catalog`. Builds sharing the catalog upload each ISO once: the
+	// first one to create the media uploads it, and the others wait for the
+	// upload to complete and mount it. Defaults to `1h`.
+	ISOUploadWaitTimeout time.Duration `mapstructure:"iso_upload_wait_timeout"`
+
+	// If true, delete the ISO uploaded to `iso_catalog` by the build once it
+	// succeeds, when it is not kept for later builds: a modified ISO, named
+	// after the checksum of its content, or any ISO when `cache_iso` is
+	// false. Unmodified ISOs cached by `cache_iso` and ISOs found in the
+	// catalog, with `iso_media_name` or `iso_search`, are left intact, as
+	// is the ISO of a failed build. Defaults to `false`.
+	CleanupISO bool `mapstructure:"cleanup_iso"`
 }
 
 func (c *CatalogConfig) Prepare() []error {
@@ -47,11 +82,32 @@ func (c *CatalogConfig) Prepare() []error {
 		c.TempCatalogPrefix = "packer-"
 	}
 
+	if c.ISOMediaName != "" && c.ISOCatalog == "" {
+		errs = append(errs, fmt.Errorf("'iso_media_name' requires 'iso_catalog'"))
+	}
+	// The temporary catalog is deleted with the ISO in it
+	if c.CleanupISO && c.ISOCatalog == "" {
+		errs = append(errs, fmt.Errorf("'cleanup_iso' requires 'iso_catalog'"))
+	}
+
 	// Default to caching ISOs when using an existing catalog
 	if c.ISOCatalog != "" && !c.CacheOverwrite {
 		c.CacheISO = true
 	}
 
+	if c.MediaResolveTimeout == 0 {
+		c.MediaResolveTimeout = 10 * time.Minute
+	}
+	if c.MediaResolveTimeout < 0 {
+		errs = append(errs, fmt.Errorf("'media_resolve_timeout' must not be negative"))
+	}
+	if c.ISOUploadWaitTimeout == 0 {
+		c.ISOUploadWaitTimeout = time.Hour
+	}
+	if c.ISOUploadWaitTimeout < 0 {
+		errs = append(errs, fmt.Errorf("'iso_upload_wait_timeout' must not be negative"))
+	}
+
 	return errs
 }
 
@@ -61,7 +117,7 @@ type StepCreateTempCatalog struct {
 	StorageProfile string // Optional storage profile name. If empty, uses VDC default.
 }
 
-func (s *StepCreateTempCatalog) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+func (s *StepCreateTempCatalog) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packersdk.Ui)
 	d := state.Get("driver").(driver.Driver)
 
@@ -110,7 +166,7 @@ func (s *StepCreateTempCatalog) Run(_ context.Context, state multistep.StateBag)
 		state.Put("vdc", vdc)
 	}
 
-	adminCatalog, err := d.CreateCatalogWithStorageProfile(catalogName, "Temporary catalog for Packer ISO build", storageProfileRef)
+	adminCatalog, err := d.CreateCatalogWithStorageProfile(ctx, catalogName, "Temporary catalog for Packer ISO build", storageProfileRef)
 	if err != nil {
 		state.Put("error", fmt.Errorf("error creating temporary catalog: %w", err))
 		return multistep.ActionHalt
@@ -120,7 +176,7 @@ func (s *StepCreateTempCatalog) Run(_ context.Context, state multistep.StateBag)
 	catalog, err := d.GetCatalog(catalogName)
 	if err != nil {
 		// Try to clean up the admin catalog we just created
-		_ = d.DeleteCatalog(adminCatalog)
+		_ = d.DeleteCatalog(context.Background(), adminCatalog)
 		state.Put("error", fmt.Errorf("error getting created catalog: %w", err))
 		return multistep.ActionHalt
 	}
@@ -151,7 +207,7 @@ func (s *StepCreateTempCatalog) Cleanup(state multistep.StateBag) {
 	catalogName, _ := state.GetOk("catalog_name")
 	ui.Sayf("Deleting temporary catalog: %s (waiting for completion)...", catalogName)
 
-	err := d.DeleteCatalog(adminCatalog.(*govcd.AdminCatalog))
+	err := d.DeleteCatalog(context.Background(), adminCatalog.(*govcd.AdminCatalog))
 	if err != nil {
 		ui.Errorf("Error deleting temporary catalog: %s", err)
 	} else {
@@ -10,10 +10,15 @@ import (
 // FlatCatalogConfig is an auto-generated flat version of CatalogConfig.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatCatalogConfig struct {
-	ISOCatalog        *string `mapstructure:"iso_catalog" cty:"iso_catalog" hcl:"iso_catalog"`
-	TempCatalogPrefix *string `mapstructure:"temp_catalog_prefix" cty:"temp_catalog_prefix" hcl:"temp_catalog_prefix"`
-	CacheISO          *bool   `mapstructure:"cache_iso" cty:"cache_iso" hcl:"cache_iso"`
-	CacheOverwrite    *bool   `mapstructure:"cache_overwrite" cty:"cache_overwrite" hcl:"cache_overwrite"`
+	ISOCatalog           *string `mapstructure:"iso_catalog" cty:"iso_catalog" hcl:"iso_catalog"`
+	ISOMediaName         *string `mapstructure:"iso_media_name" cty:"iso_media_name" hcl:"iso_media_name"`
+	TempCatalogPrefix    *string `mapstructure:"temp_catalog_prefix" cty:"temp_catalog_prefix" hcl:"temp_catalog_prefix"`
+	CacheISO             *bool   `mapstructure:"cache_iso" cty:"cache_iso" hcl:"cache_iso"`
+	CacheOverwrite       *bool   `mapstructure:"cache_overwrite" cty:"cache_overwrite" hcl:"cache_overwrite"`
+	ISOSearch            *bool   `mapstructure:"iso_search" cty:"iso_search" hcl:"iso_search"`
+	MediaResolveTimeout  *string `mapstructure:"media_resolve_timeout" cty:"media_resolve_timeout" hcl:"media_resolve_timeout"`
+	ISOUploadWaitTimeout *string `mapstructure:"iso_upload_wait_timeout" cty:"iso_upload_wait_timeout" hcl:"iso_upload_wait_timeout"`
+	CleanupISO           *bool   `mapstructure:"cleanup_iso" cty:"cleanup_iso" hcl:"cleanup_iso"`
 }
 
 // FlatMapstructure returns a new FlatCatalogConfig.
@@ -28,10 +33,15 @@ func (*CatalogConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec
 // The decoded values from this spec will then be applied to a FlatCatalogConfig.
 func (*FlatCatalogConfig) HCL2Spec() map[string]hcldec.Spec {
 	s := map[string]hcldec.Spec{
-		"iso_catalog":         &hcldec.AttrSpec{Name: "iso_catalog", Type: cty.String, Required: false},
-		"temp_catalog_prefix": &hcldec.AttrSpec{Name: "temp_catalog_prefix", Type: cty.String, Required: false},
-		"cache_iso":           &hcldec.AttrSpec{Name: "cache_iso", Type: cty.Bool, Required: false},
-		"cache_overwrite":     &hcldec.AttrSpec{Name: "cache_overwrite", Type: cty.Bool, Required: false},
+		"iso_catalog":             &hcldec.AttrSpec{Name: "iso_catalog", Type: cty.String, Required: false},
+		"iso_media_name":          &hcldec.AttrSpec{Name: "iso_media_name", Type: cty.String, Required: false},
+		"temp_catalog_prefix":     &hcldec.AttrSpec{Name: "temp_catalog_prefix", Type: cty.String, Required: false},
+		"cache_iso":               &hcldec.AttrSpec{Name: "cache_iso", Type: cty.Bool, Required: false},
+		"cache_overwrite":         &hcldec.AttrSpec{Name: "cache_overwrite", Type: cty.Bool, Required: false},
+		"iso_search":              &hcldec.AttrSpec{Name: "iso_search", Type: cty.Bool, Required: false},
+		"media_resolve_timeout":   &hcldec.AttrSpec{Name: "media_resolve_timeout", Type: cty.String, Required: false},
+		"iso_upload_wait_timeout": &hcldec.AttrSpec{Name: "iso_upload_wait_timeout", Type: cty.String, Required: false},
+		"cleanup_iso":             &hcldec.AttrSpec{Name: "cleanup_iso", Type: cty.Bool, Required: false},
 	}
 	return s
 }
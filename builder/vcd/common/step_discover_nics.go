@@ -0,0 +1,59 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+)
+
+// StepDiscoverNICs records the MAC address and network of each of the VM's
+// network adapters, so kickstart/preseed files can match interfaces by MAC
+// rather than by device name.
+type StepDiscoverNICs struct{}
+
+func (s *StepDiscoverNICs) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	vm := state.Get("vm").(driver.VirtualMachine)
+
+	nics, err := vm.GetNICs()
+	if err != nil {
+		state.Put("error", fmt.Errorf("error getting VM network adapters: %w", err))
+		return multistep.ActionHalt
+	}
+
+	state.Put("vm_nics", nics)
+	for _, nic := range nics {
+		ui.Sayf("NIC %d: MAC=%s, Network=%s", nic.Index, nic.MAC, nic.Network)
+		if nic.Primary {
+			state.Put("vm_mac", nic.MAC)
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepDiscoverNICs) Cleanup(state multistep.StateBag) {
+	// Nothing to clean up
+}
+
+// nicTemplateVars returns the NIC template variables from state: VMMAC for
+// the primary adapter, and VMNIC<n>MAC and VMNIC<n>Network for each adapter
+// by connection index.
+func nicTemplateVars(state multistep.StateBag) map[string]string {
+	vars := make(map[string]string)
+
+	if mac, ok := state.Get("vm_mac").(string); ok && mac != "" {
+		vars["VMMAC"] = mac
+	}
+	if nics, ok := state.Get("vm_nics").([]driver.NICInfo); ok {
+		for _, nic := range nics {
+			vars[fmt.Sprintf("VMNIC%dMAC", nic.Index)] = nic.MAC
+			vars[fmt.Sprintf("VMNIC%dNetwork", nic.Index)] = nic.Network
+		}
+	}
+
+	return vars
+}
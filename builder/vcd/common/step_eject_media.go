@@ -0,0 +1,64 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+)
+
+// StepEjectMedia ejects the media from the VM before it is captured by
+// export_to_catalog: the ISO, the floppy image and the CD drive added for
+// the build, and any media left in the VM, e.g. by the source template. A
+// template keeps the references to the media of its VM, and fails to
+// instantiate once they are gone, as the media of a temporary catalog are
+// when the build ends.
+type StepEjectMedia struct {
+	// Config is the export_to_catalog configuration; nothing is ejected
+	// when it is nil.
+	Config *ExportToCatalogConfig
+}
+
+func (s *StepEjectMedia) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Config == nil {
+		return multistep.ActionContinue
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	vm := state.Get("vm").(driver.VirtualMachine)
+
+	// VCD cannot capture a vApp with mounted media
+	if isoMounted, ok := state.GetOk("iso_mounted"); ok && isoMounted.(bool) {
+		media := state.Get("uploaded_media").(*govcd.Media)
+		ui.Sayf("Ejecting ISO before export: %s", media.Media.Name)
+		if err := vm.EjectMedia(media); err != nil {
+			state.Put("error", fmt.Errorf("error ejecting ISO: %w", err))
+			return multistep.ActionHalt
+		}
+		state.Put("iso_mounted", false)
+	}
+	if err := ejectFloppy(ui, state); err != nil {
+		state.Put("error", fmt.Errorf("error ejecting floppy image: %w", err))
+		return multistep.ActionHalt
+	}
+	if err := removeCDDrive(ui, state); err != nil {
+		state.Put("error", fmt.Errorf("error removing CD-ROM drive: %w", err))
+		return multistep.ActionHalt
+	}
+
+	ejected, err := vm.EjectAllMedia()
+	for _, name := range ejected {
+		ui.Sayf("Ejected media left in the VM: %s", name)
+	}
+	if err != nil {
+		state.Put("error", fmt.Errorf("error ejecting media left in the VM: %w", err))
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepEjectMedia) Cleanup(state multistep.StateBag) {}
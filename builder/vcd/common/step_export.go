@@ -1,12 +1,27 @@
 package common
 
 import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
 	"github.com/pkg/errors"
+	"github.com/vmware/go-vcloud-director/v3/govcd"
 )
 
 //go:generate packer-sdc struct-markdown
@@ -21,6 +36,23 @@ type ExportConfig struct {
 	// Forces the export to overwrite existing files. Defaults to `false`.
 	// If set to `false`, an error is returned if the file(s) already exists.
 	Force bool `mapstructure:"force"`
+	// The file name of the OVF descriptor, without the `.ovf` extension, for
+	// import tooling that expects a fixed name. Defaults to `name`.
+	DescriptorName string `mapstructure:"descriptor_name"`
+	// The base name of the disk image files. Disks are named
+	// `<disk_name>-<n>.vmdk`, counting from 1 in the order of the
+	// descriptor. Defaults to `name`.
+	DiskName string `mapstructure:"disk_name"`
+	// Only export the OVF descriptor and the disk images. The NVRAM file and
+	// any other file of the template are skipped, and removed from the
+	// descriptor, for tooling that rejects them. No manifest is written, as
+	// its checksums would not match the edited descriptor. Defaults to
+	// `false`.
+	ImagesOnly bool `mapstructure:"images_only"`
+	// The format of the export: `ovf`, for the descriptor, manifest and
+	// disk images as separate files, or `ova`, for a single `<name>.ova`
+	// archive of them. Defaults to `ovf`.
+	Format string `mapstructure:"format"`
 	// The path to the directory where the exported image will be saved.
 	OutputDir OutputConfig `mapstructure:",squash"`
 }
@@ -34,14 +66,337 @@ func (c *ExportConfig) Prepare(ctx *interpolate.Context, lc *LocationConfig, pc
 	if c.Name == "" {
 		c.Name = lc.VMName
 	}
+	if c.DescriptorName == "" {
+		c.DescriptorName = c.Name
+	}
+	if c.DiskName == "" {
+		c.DiskName = c.Name
+	}
+	for key, name := range map[string]string{"name": c.Name, "descriptor_name": c.DescriptorName, "disk_name": c.DiskName} {
+		if strings.ContainsAny(name, `/\`) {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'%s' must be a file name, not a path: %s", key, name))
+		}
+	}
 
 	// Check if the output directory exists.
 	if err := os.MkdirAll(c.OutputDir.OutputDir, c.OutputDir.DirPerm); err != nil {
 		errs = packersdk.MultiErrorAppend(errs, errors.Wrap(err, "unable to make directory for export"))
 	}
 
-	// TODO(juan): Implement this
-	// https://github.com/hashicorp/packer-plugin-vsphere/blob/main/builder/vsphere/common/step_export.go#L18
+	switch c.Format {
+	case "":
+		c.Format = "ovf"
+	case "ovf", "ova":
+	default:
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'format' must be ovf or ova: %s", c.Format))
+	}
 
 	return errs.Errors
 }
+
+// DescriptorFile returns the file name of the OVF descriptor.
+func (c *ExportConfig) DescriptorFile() string {
+	return c.DescriptorName + ".ovf"
+}
+
+// DiskFile returns the file name of the n-th disk image, counting from 1.
+func (c *ExportConfig) DiskFile(n int) string {
+	return fmt.Sprintf("%s-%d.vmdk", c.DiskName, n)
+}
+
+// ManifestFile returns the file name of the manifest of the descriptor.
+func (c *ExportConfig) ManifestFile() string {
+	return c.DescriptorName + ".mf"
+}
+
+// OVAFile returns the file name of the OVA archive.
+func (c *ExportConfig) OVAFile() string {
+	return c.Name + ".ova"
+}
+
+// ovfPackage is the part of an OVF descriptor listing the files of the
+// package.
+type ovfPackage struct {
+	Files []ovfFile `xml:"References>File"`
+	Disks []struct {
+		FileRef string `xml:"fileRef,attr"`
+	} `xml:"DiskSection>Disk"`
+}
+
+type ovfFile struct {
+	Href string `xml:"href,attr"`
+	ID   string `xml:"id,attr"`
+	Size int64  `xml:"size,attr"`
+}
+
+// StepExport downloads the vApp template captured by export_to_catalog into
+// output_directory, as an OVF package or an OVA. VCD only serves
+// downloads of templates, not of vApps, so the export runs after the
+// capture.
+type StepExport struct {
+	Config *ExportConfig
+
+	files []string // written so far, removed if the build fails
+}
+
+func (s *StepExport) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Config == nil {
+		return multistep.ActionContinue
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	d := state.Get("driver").(driver.Driver)
+
+	template, ok := state.Get("vapp_template").(*govcd.VAppTemplate)
+	if !ok {
+		state.Put("error", fmt.Errorf("no vApp template found in state, export needs export_to_catalog"))
+		return multistep.ActionHalt
+	}
+	name := template.VAppTemplate.Name
+
+	release, err := d.AcquireTransferSlot(ctx, func() {
+		ui.Say("Waiting for a free transfer slot (max_concurrent_transfers)...")
+	})
+	if err != nil {
+		state.Put("error", fmt.Errorf("error waiting for a transfer slot: %w", err))
+		return multistep.ActionHalt
+	}
+	defer release()
+
+	ui.Sayf("Preparing vApp template %s for download (this may take a few minutes...)", name)
+	descriptorURL, err := d.EnableVAppTemplateDownload(ctx, template)
+	if err != nil {
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
+	defer func() {
+		if err := d.DisableVAppTemplateDownload(template); err != nil {
+			ui.Errorf("Warning: %s", err)
+		}
+	}()
+
+	var descriptor bytes.Buffer
+	if _, err := d.DownloadFile(ctx, descriptorURL, &descriptor, 0); err != nil {
+		state.Put("error", fmt.Errorf("error downloading the OVF descriptor of %s: %w", name, err))
+		return multistep.ActionHalt
+	}
+	pkg := &ovfPackage{}
+	if err := xml.Unmarshal(descriptor.Bytes(), pkg); err != nil {
+		state.Put("error", fmt.Errorf("error parsing the OVF descriptor of %s: %w", name, err))
+		return multistep.ActionHalt
+	}
+
+	names, err := s.Config.localNames(pkg)
+	if err != nil {
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
+	ovf := descriptor.String()
+
+	// The files are served next to the descriptor
+	baseURL := descriptorURL[:strings.LastIndex(descriptorURL, "/")+1]
+	sums := make(map[string]string)
+	var order []string
+	for _, f := range pkg.Files {
+		local, ok := names[f.Href]
+		if !ok {
+			ui.Message(fmt.Sprintf("Skipping %s (images_only)", f.Href))
+			ovf = removeOVFFile(ovf, f.ID)
+			continue
+		}
+		ui.Sayf("Downloading %s (%d MB)...", local, f.Size>>20)
+		sum, err := s.writeFile(local, func(w io.Writer) error {
+			_, err := d.DownloadFile(ctx, baseURL+f.Href, w, f.Size)
+			return err
+		})
+		if err != nil {
+			state.Put("error", fmt.Errorf("error downloading %s: %w", f.Href, err))
+			return multistep.ActionHalt
+		}
+		sums[local] = sum
+		order = append(order, local)
+	}
+
+	ovf = renameOVFFiles(ovf, names)
+	sum, err := s.writeFile(s.Config.DescriptorFile(), func(w io.Writer) error {
+		_, err := io.WriteString(w, ovf)
+		return err
+	})
+	if err != nil {
+		state.Put("error", fmt.Errorf("error writing the OVF descriptor: %w", err))
+		return multistep.ActionHalt
+	}
+	sums[s.Config.DescriptorFile()] = sum
+	order = append([]string{s.Config.DescriptorFile()}, order...)
+
+	if !s.Config.ImagesOnly {
+		var manifest strings.Builder
+		for _, file := range order {
+			fmt.Fprintf(&manifest, "SHA256(%s)= %s\n", file, sums[file])
+		}
+		_, err := s.writeFile(s.Config.ManifestFile(), func(w io.Writer) error {
+			_, err := io.WriteString(w, manifest.String())
+			return err
+		})
+		if err != nil {
+			state.Put("error", fmt.Errorf("error writing the manifest: %w", err))
+			return multistep.ActionHalt
+		}
+		// The manifest goes right after the descriptor in an OVA
+		order = append([]string{order[0], s.Config.ManifestFile()}, order[1:]...)
+	}
+
+	if s.Config.Format == "ova" {
+		ui.Sayf("Packing %s...", s.Config.OVAFile())
+		if err := s.writeOVA(order); err != nil {
+			state.Put("error", fmt.Errorf("error writing %s: %w", s.Config.OVAFile(), err))
+			return multistep.ActionHalt
+		}
+		for _, file := range order {
+			if err := os.Remove(filepath.Join(s.Config.OutputDir.OutputDir, file)); err != nil {
+				ui.Errorf("Warning: failed to remove %s: %s", file, err)
+			}
+		}
+		ui.Sayf("vApp template %s exported to %s", name, filepath.Join(s.Config.OutputDir.OutputDir, s.Config.OVAFile()))
+	} else {
+		ui.Sayf("vApp template %s exported to %s", name, filepath.Join(s.Config.OutputDir.OutputDir, s.Config.DescriptorFile()))
+	}
+
+	s.files = nil
+	return multistep.ActionContinue
+}
+
+// localNames maps the hrefs of the files of an OVF package to their names
+// in output_directory: disks by DiskFile, in the order of the descriptor,
+// and other files by their own name, or skipped with images_only.
+func (c *ExportConfig) localNames(pkg *ovfPackage) (map[string]string, error) {
+	hrefs := make(map[string]string, len(pkg.Files))
+	for _, f := range pkg.Files {
+		hrefs[f.ID] = f.Href
+	}
+
+	names := make(map[string]string, len(pkg.Files))
+	for i, disk := range pkg.Disks {
+		if href, ok := hrefs[disk.FileRef]; ok {
+			names[href] = c.DiskFile(i + 1)
+		}
+	}
+	if !c.ImagesOnly {
+		for _, f := range pkg.Files {
+			if _, ok := names[f.Href]; ok {
+				continue
+			}
+			local := path.Base(f.Href)
+			if local == "." || local == ".." || local == c.DescriptorFile() || local == c.ManifestFile() {
+				return nil, fmt.Errorf("invalid file %s in the OVF descriptor", f.Href)
+			}
+			names[f.Href] = local
+		}
+	}
+	return names, nil
+}
+
+// ovfHref matches the href attributes of an OVF descriptor.
+var ovfHref = regexp.MustCompile(`\bhref="([^"]*)"`)
+
+// renameOVFFiles points the hrefs of an OVF descriptor to the names of
+// their files in output_directory.
+func renameOVFFiles(ovf string, names map[string]string) string {
+	return ovfHref.ReplaceAllStringFunc(ovf, func(attr string) string {
+		if name, ok := names[ovfHref.FindStringSubmatch(attr)[1]]; ok {
+			return `href="` + name + `"`
+		}
+		return attr
+	})
+}
+
+// removeOVFFile removes the File element of id from an OVF descriptor,
+// along with the elements referring to it, such as the NVRAM entry of a VM.
+func removeOVFFile(ovf, id string) string {
+	file := regexp.MustCompile(`[ \t]*<(?:\w+:)?File\b[^>]*\bid="` + regexp.QuoteMeta(id) + `"[^>]*(?:/>|>[^<]*</(?:\w+:)?File>)[ \t]*\r?\n?`)
+	ovf = file.ReplaceAllLiteralString(ovf, "")
+	refs := regexp.MustCompile(`[ \t]*<[^<>]*"ovf:/file/` + regexp.QuoteMeta(id) + `"[^<>]*/>[ \t]*\r?\n?`)
+	return refs.ReplaceAllLiteralString(ovf, "")
+}
+
+// writeFile creates name in output_directory with the content written by
+// write, and returns its SHA256 checksum. Existing files are only
+// overwritten with force.
+func (s *StepExport) writeFile(name string, write func(w io.Writer) error) (string, error) {
+	filePath := filepath.Join(s.Config.OutputDir.OutputDir, name)
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if s.Config.Force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(filePath, flags, 0o644)
+	if os.IsExist(err) {
+		return "", fmt.Errorf("%s already exists, set force to overwrite it", filePath)
+	}
+	if err != nil {
+		return "", err
+	}
+	s.files = append(s.files, filePath)
+
+	h := sha256.New()
+	if err := write(io.MultiWriter(f, h)); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeOVA packs files of output_directory, in order, into the OVA.
+func (s *StepExport) writeOVA(files []string) error {
+	dir := s.Config.OutputDir.OutputDir
+	_, err := s.writeFile(s.Config.OVAFile(), func(w io.Writer) error {
+		tw := tar.NewWriter(w)
+		for _, file := range files {
+			if err := addToTar(tw, filepath.Join(dir, file), file); err != nil {
+				return err
+			}
+		}
+		return tw.Close()
+	})
+	return err
+}
+
+func addToTar(tw *tar.Writer, filePath, name string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    fi.Size(),
+		ModTime: fi.ModTime(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+func (s *StepExport) Cleanup(state multistep.StateBag) {
+	if len(s.files) == 0 {
+		return
+	}
+	ui := state.Get("ui").(packersdk.Ui)
+
+	// Files of a failed export are incomplete
+	for _, file := range s.files {
+		ui.Message(fmt.Sprintf("Removing incomplete export file: %s", file))
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			ui.Errorf("Warning: failed to remove %s: %s", file, err)
+		}
+	}
+}
@@ -12,10 +12,14 @@ import (
 // FlatExportConfig is an auto-generated flat version of ExportConfig.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatExportConfig struct {
-	Name      *string     `mapstructure:"name" cty:"name" hcl:"name"`
-	Force     *bool       `mapstructure:"force" cty:"force" hcl:"force"`
-	OutputDir *string     `mapstructure:"output_directory" required:"false" cty:"output_directory" hcl:"output_directory"`
-	DirPerm   os.FileMode `mapstructure:"directory_permission" required:"false" cty:"directory_permission" hcl:"directory_permission"`
+	Name           *string     `mapstructure:"name" cty:"name" hcl:"name"`
+	Force          *bool       `mapstructure:"force" cty:"force" hcl:"force"`
+	DescriptorName *string     `mapstructure:"descriptor_name" cty:"descriptor_name" hcl:"descriptor_name"`
+	DiskName       *string     `mapstructure:"disk_name" cty:"disk_name" hcl:"disk_name"`
+	ImagesOnly     *bool       `mapstructure:"images_only" cty:"images_only" hcl:"images_only"`
+	Format         *string     `mapstructure:"format" cty:"format" hcl:"format"`
+	OutputDir      *string     `mapstructure:"output_directory" required:"false" cty:"output_directory" hcl:"output_directory"`
+	DirPerm        os.FileMode `mapstructure:"directory_permission" required:"false" cty:"directory_permission" hcl:"directory_permission"`
 }
 
 // FlatMapstructure returns a new FlatExportConfig.
@@ -32,6 +36,10 @@ func (*FlatExportConfig) HCL2Spec() map[string]hcldec.Spec {
 	s := map[string]hcldec.Spec{
 		"name":                 &hcldec.AttrSpec{Name: "name", Type: cty.String, Required: false},
 		"force":                &hcldec.AttrSpec{Name: "force", Type: cty.Bool, Required: false},
+		"descriptor_name":      &hcldec.AttrSpec{Name: "descriptor_name", Type: cty.String, Required: false},
+		"disk_name":            &hcldec.AttrSpec{Name: "disk_name", Type: cty.String, Required: false},
+		"images_only":          &hcldec.AttrSpec{Name: "images_only", Type: cty.Bool, Required: false},
+		"format":               &hcldec.AttrSpec{Name: "format", Type: cty.String, Required: false},
 		"output_directory":     &hcldec.AttrSpec{Name: "output_directory", Type: cty.String, Required: false},
 		"directory_permission": &hcldec.AttrSpec{Name: "directory_permission", Type: cty.Bool, Required: false}, /* TODO(azr): could not find type */
 	}
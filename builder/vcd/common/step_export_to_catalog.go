@@ -17,6 +17,10 @@ const (
 	templateStatusTimeout   = 30 * time.Minute
 	templateDeleteTimeout   = 10 * time.Minute
 	templateStatusPollDelay = 30 * time.Second
+
+	// templateVersionLayout is the build time appended to the names of
+	// versioned templates
+	templateVersionLayout = "20060102150405"
 )
 
 //go:generate packer-sdc struct-markdown
@@ -24,6 +28,17 @@ const (
 
 // ExportToCatalogConfig defines configuration for exporting the built VM as a vApp template.
 // This is separate from the ISO catalog (CatalogConfig) which is used for ISO storage during build.
+//
+// The captured template is tagged with build provenance metadata under the
+// `packer.` prefix: `build_date`, `build_uuid`, `version`, `plugin_version`,
+// `build_name`, the build source (e.g. `source_iso_url` and
+// `source_iso_checksum`) and, when built by a CI system, `git_sha` and
+// `git_ref`.
+//
+// Before the capture, the ISO, the floppy image and any other media are
+// ejected from the VM, and the CD-ROM drive added for the build is removed,
+// so that the template does not reference media that may not outlive the
+// build.
 type ExportToCatalogConfig struct {
 	// The name of the catalog to export the vApp template to.
 	Catalog string `mapstructure:"catalog"`
@@ -39,6 +54,12 @@ type ExportToCatalogConfig struct {
 	// Defaults to false.
 	Overwrite bool `mapstructure:"overwrite"`
 
+	// If true, append the UTC build time to `template_name`, as in
+	// `debian-12-20250102150405`, so that each build captures a new template
+	// and earlier ones are kept. Cannot be used with `overwrite`. Defaults to
+	// false.
+	Versioned bool `mapstructure:"versioned"`
+
 	// If true, create the catalog if it doesn't exist.
 	// Defaults to false.
 	CreateCatalog bool `mapstructure:"create_catalog"`
@@ -63,14 +84,27 @@ func (c *ExportToCatalogConfig) Prepare(lc *LocationConfig) []error {
 		c.TemplateName = lc.VMName
 	}
 
+	if c.Versioned && c.Overwrite {
+		errs = append(errs, fmt.Errorf("'versioned' and 'overwrite' cannot be used together"))
+	}
+
 	return errs
 }
 
 type StepExportToCatalog struct {
 	Config *ExportToCatalogConfig
+	// StorageProfile for the catalog when it has to be created.
+	// If empty, uses the first storage profile of the VDC.
+	StorageProfile string
+	// Provenance metadata entries written onto the captured template, see
+	// Provenance.
+	Provenance map[string]string
+	// Metadata entries written onto the captured template, replacing the
+	// provenance entries with the same keys.
+	Metadata map[string]string
 }
 
-func (s *StepExportToCatalog) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+func (s *StepExportToCatalog) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packersdk.Ui)
 	d := state.Get("driver").(driver.Driver)
 
@@ -85,19 +119,14 @@ func (s *StepExportToCatalog) Run(_ context.Context, state multistep.StateBag) m
 		return multistep.ActionHalt
 	}
 
-	// Eject ISO before capturing - VCD cannot capture vApp with mounted media
-	if isoMounted, ok := state.GetOk("iso_mounted"); ok && isoMounted.(bool) {
-		vm := state.Get("vm").(driver.VirtualMachine)
-		catalogName := state.Get("catalog_name").(string)
-		mediaName := state.Get("uploaded_media_name").(string)
+	// The media were ejected by StepEjectMedia
+	if err := restoreBootOrder(ui, state); err != nil {
+		ui.Errorf("Warning: failed to restore boot order: %s", err)
+	}
 
-		ui.Sayf("Ejecting ISO before export: %s", mediaName)
-		if err := vm.EjectMedia(catalogName, mediaName); err != nil {
-			ui.Errorf("Warning: failed to eject ISO: %s", err)
-			// Continue anyway - the capture might still work
-		} else {
-			state.Put("iso_mounted", false)
-		}
+	templateName := s.Config.TemplateName
+	if s.Config.Versioned {
+		templateName = fmt.Sprintf("%s-%s", templateName, time.Now().UTC().Format(templateVersionLayout))
 	}
 
 	ui.Sayf("Exporting vApp as template to catalog: %s", s.Config.Catalog)
@@ -115,12 +144,20 @@ func (s *StepExportToCatalog) Run(_ context.Context, state multistep.StateBag) m
 
 		vdc := state.Get("vdc").(*govcd.Vdc)
 		var storageProfileRef *types.Reference
-		if vdc.Vdc.VdcStorageProfiles != nil && len(vdc.Vdc.VdcStorageProfiles.VdcStorageProfile) > 0 {
+		if s.StorageProfile != "" {
+			sp, err := vdc.FindStorageProfileReference(s.StorageProfile)
+			if err != nil {
+				state.Put("error", fmt.Errorf("error finding storage profile %s: %w", s.StorageProfile, err))
+				return multistep.ActionHalt
+			}
+			storageProfileRef = &sp
+			ui.Sayf("Using storage profile: %s", s.StorageProfile)
+		} else if vdc.Vdc.VdcStorageProfiles != nil && len(vdc.Vdc.VdcStorageProfiles.VdcStorageProfile) > 0 {
 			storageProfileRef = vdc.Vdc.VdcStorageProfiles.VdcStorageProfile[0]
 			ui.Sayf("Using VDC storage profile: %s", storageProfileRef.Name)
 		}
 
-		adminCatalog, err := d.CreateCatalogWithStorageProfile(s.Config.Catalog, "Created by Packer", storageProfileRef)
+		adminCatalog, err := d.CreateCatalogWithStorageProfile(ctx, s.Config.Catalog, "Created by Packer", storageProfileRef)
 		if err != nil {
 			// If catalog already exists (race or GetCatalog permission issue), try to use it
 			if strings.Contains(err.Error(), "already exists") {
@@ -143,25 +180,27 @@ func (s *StepExportToCatalog) Run(_ context.Context, state multistep.StateBag) m
 	}
 
 	// If template already exists, handle overwrite by deleting first
-	existingItem, err := catalog.GetCatalogItemByName(s.Config.TemplateName, true)
+	existingItem, err := catalog.GetCatalogItemByName(templateName, true)
 	if err == nil && existingItem != nil {
 		if !s.Config.Overwrite {
 			state.Put("error", fmt.Errorf("template '%s' already exists in catalog '%s'. Set overwrite=true to replace it",
-				s.Config.TemplateName, s.Config.Catalog))
+				templateName, s.Config.Catalog))
 			return multistep.ActionHalt
 		}
 
 		// Delete old template before capturing with the same name
-		ui.Sayf("Deleting existing template '%s' before capture...", s.Config.TemplateName)
+		ui.Sayf("Deleting existing template '%s' before capture...", templateName)
 		if err := existingItem.Delete(); err != nil && !strings.Contains(err.Error(), "not found") {
-			state.Put("error", fmt.Errorf("error deleting old template '%s': %w", s.Config.TemplateName, err))
+			state.Put("error", fmt.Errorf("error deleting old template '%s': %w", templateName, err))
 			return multistep.ActionHalt
 		}
 
-		// Wait for deletion to complete
+		// Wait for deletion to complete. Poll by ID: a template with the same
+		// name may be captured by another build in the meantime
+		existingID := existingItem.CatalogItem.ID
 		deleteTimeout := time.After(templateDeleteTimeout)
 		for {
-			deletedItem, err := catalog.GetCatalogItemByName(s.Config.TemplateName, true)
+			deletedItem, err := d.GetCatalogItemById(catalog, existingID)
 			if err != nil || deletedItem == nil {
 				ui.Say("Old template deleted successfully")
 				break
@@ -185,9 +224,18 @@ func (s *StepExportToCatalog) Run(_ context.Context, state multistep.StateBag) m
 		description = fmt.Sprintf("Packer-built template from %s", vappRef.VApp.Name)
 	}
 
-	ui.Sayf("Creating vApp template: %s (this may take a few minutes...)", s.Config.TemplateName)
+	release, err := d.AcquireTransferSlot(ctx, func() {
+		ui.Say("Waiting for a free transfer slot (max_concurrent_transfers)...")
+	})
+	if err != nil {
+		state.Put("error", fmt.Errorf("error waiting for a transfer slot: %w", err))
+		return multistep.ActionHalt
+	}
+	defer release()
+
+	ui.Sayf("Creating vApp template: %s (this may take a few minutes...)", templateName)
 	captureParams := &types.CaptureVAppParams{
-		Name:        s.Config.TemplateName,
+		Name:        templateName,
 		Description: description,
 		Source: &types.Reference{
 			HREF: vappRef.VApp.HREF,
@@ -206,7 +254,7 @@ func (s *StepExportToCatalog) Run(_ context.Context, state multistep.StateBag) m
 		return multistep.ActionHalt
 	}
 
-	ui.Sayf("vApp template '%s' captured successfully (status: %d)", s.Config.TemplateName, capturedTemplate.VAppTemplate.Status)
+	ui.Sayf("vApp template '%s' captured successfully (status: %d)", templateName, capturedTemplate.VAppTemplate.Status)
 
 	// Wait for template to reach status 8 (resolved and powered off)
 	// Save the HREF - govcd's Refresh() resets the VAppTemplate struct before
@@ -262,7 +310,24 @@ func (s *StepExportToCatalog) Run(_ context.Context, state multistep.StateBag) m
 		ui.Say("Compute policies are now non-final (template is portable)")
 	}
 
-	ui.Sayf("vApp template '%s' created successfully in catalog '%s'", s.Config.TemplateName, s.Config.Catalog)
+	if metadata := mergeMetadata(s.Provenance, s.Metadata); len(metadata) > 0 {
+		ui.Say("Writing metadata to vApp template...")
+		sayMetadata(ui, metadata)
+		entries := make(map[string]interface{}, len(metadata))
+		for key, value := range metadata {
+			entries[key] = value
+		}
+		// The template is usable without it; do not fail a long build over it
+		if err := capturedTemplate.MergeMetadata(types.MetadataStringValue, entries); err != nil {
+			ui.Errorf("Warning: failed to write metadata: %s", err)
+		}
+	}
+
+	// Downloaded by the export, if any, and recorded in the artifact
+	state.Put("vapp_template", capturedTemplate)
+	state.Put("vapp_template_catalog", s.Config.Catalog)
+
+	ui.Sayf("vApp template '%s' created successfully in catalog '%s'", templateName, s.Config.Catalog)
 
 	return multistep.ActionContinue
 }
@@ -14,6 +14,7 @@ type FlatExportToCatalogConfig struct {
 	TemplateName      *string `mapstructure:"template_name" cty:"template_name" hcl:"template_name"`
 	Description       *string `mapstructure:"description" cty:"description" hcl:"description"`
 	Overwrite         *bool   `mapstructure:"overwrite" cty:"overwrite" hcl:"overwrite"`
+	Versioned         *bool   `mapstructure:"versioned" cty:"versioned" hcl:"versioned"`
 	CreateCatalog     *bool   `mapstructure:"create_catalog" cty:"create_catalog" hcl:"create_catalog"`
 	SizingPolicyFinal *bool   `mapstructure:"sizing_policy_final" cty:"sizing_policy_final" hcl:"sizing_policy_final"`
 }
@@ -34,6 +35,7 @@ func (*FlatExportToCatalogConfig) HCL2Spec() map[string]hcldec.Spec {
 		"template_name":       &hcldec.AttrSpec{Name: "template_name", Type: cty.String, Required: false},
 		"description":         &hcldec.AttrSpec{Name: "description", Type: cty.String, Required: false},
 		"overwrite":           &hcldec.AttrSpec{Name: "overwrite", Type: cty.Bool, Required: false},
+		"versioned":           &hcldec.AttrSpec{Name: "versioned", Type: cty.Bool, Required: false},
 		"create_catalog":      &hcldec.AttrSpec{Name: "create_catalog", Type: cty.Bool, Required: false},
 		"sizing_policy_final": &hcldec.AttrSpec{Name: "sizing_policy_final", Type: cty.Bool, Required: false},
 	}
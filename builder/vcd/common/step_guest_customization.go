@@ -0,0 +1,137 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+	"github.com/vmware/go-vcloud-director/v3/types/v56"
+)
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type GuestCustomizationConfig
+
+// GuestCustomizationConfig sets the guest customization section of the VM,
+// which VMware Tools apply in the guest on its first power on after a
+// deployment. It is captured with the VM by `export_to_catalog`, so VMs
+// deployed from the template are customized with these settings. Set
+// `power_on_force_customization` to also run it during the build.
+type GuestCustomizationConfig struct {
+	// Enable guest customization. Set to false to disable it, e.g. on a VM
+	// cloned from a template that has it enabled. Defaults to true.
+	Enabled *bool `mapstructure:"enabled"`
+	// The computer name (host name) of the guest. Letters, digits and
+	// hyphens, up to 63 characters, or 15 for Windows guests. Defaults to
+	// the VM name, with any other character replaced by a hyphen, truncated
+	// to 15 characters.
+	ComputerName string `mapstructure:"computer_name"`
+	// The password of the administrator (root) account, set by
+	// customization. Cannot be used with `admin_password_auto`.
+	AdminPassword string `mapstructure:"admin_password"`
+	// Have customization generate a random administrator password, which
+	// VCD shows in the guest customization section of the VM. Defaults to
+	// false.
+	AdminPasswordAuto bool `mapstructure:"admin_password_auto"`
+	// Have customization change the Windows SID of the guest, with
+	// sysprep. Defaults to false.
+	ChangeSID bool `mapstructure:"change_sid"`
+	// A script run in the guest by customization: a batch file on Windows,
+	// or a shell script, with a shebang line, on Linux. VCD calls it with
+	// `precustomization` and `postcustomization` arguments.
+	CustomizationScript string `mapstructure:"customization_script"`
+}
+
+// computerNameRe matches the host names VCD accepts as computer names.
+var computerNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]{0,62}$`)
+
+func (c *GuestCustomizationConfig) Prepare() []error {
+	var errs []error
+
+	if c.Enabled == nil {
+		enabled := true
+		c.Enabled = &enabled
+	}
+
+	if c.ComputerName != "" && !computerNameRe.MatchString(c.ComputerName) {
+		errs = append(errs, fmt.Errorf("'computer_name' must be up to 63 letters, digits and hyphens, not starting with a hyphen, got %q", c.ComputerName))
+	}
+
+	if c.AdminPassword != "" && c.AdminPasswordAuto {
+		errs = append(errs, fmt.Errorf("'admin_password' and 'admin_password_auto' cannot be used together"))
+	}
+
+	return errs
+}
+
+// computerNameInvalid matches the characters not allowed in computer names.
+var computerNameInvalid = regexp.MustCompile(`[^A-Za-z0-9-]+`)
+
+// defaultComputerName derives a computer name from vmName, which may hold
+// characters computer names cannot: they are replaced with hyphens, and the
+// name is truncated to the 15 characters Windows allows.
+func defaultComputerName(vmName string) string {
+	name := strings.TrimLeft(computerNameInvalid.ReplaceAllString(vmName, "-"), "-")
+	if len(name) > 15 {
+		name = name[:15]
+	}
+	name = strings.TrimRight(name, "-")
+	if name == "" {
+		return "packer"
+	}
+	return name
+}
+
+// section returns the guest customization section for a VM named vmName.
+func (c *GuestCustomizationConfig) section(vmName string) *types.GuestCustomizationSection {
+	computerName := c.ComputerName
+	if computerName == "" {
+		computerName = defaultComputerName(vmName)
+	}
+
+	passwordEnabled := c.AdminPassword != "" || c.AdminPasswordAuto
+	return &types.GuestCustomizationSection{
+		Info:                 "Specifies Guest OS Customization Settings",
+		Enabled:              c.Enabled,
+		ChangeSid:            &c.ChangeSID,
+		AdminPasswordEnabled: &passwordEnabled,
+		AdminPasswordAuto:    &c.AdminPasswordAuto,
+		AdminPassword:        c.AdminPassword,
+		CustomizationScript:  c.CustomizationScript,
+		ComputerName:         computerName,
+	}
+}
+
+// StepGuestCustomization sets the guest customization section of the VM. It
+// runs before the VM is powered on.
+type StepGuestCustomization struct {
+	Config *GuestCustomizationConfig
+	VMName string
+}
+
+func (s *StepGuestCustomization) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Config == nil {
+		return multistep.ActionContinue
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	vm := state.Get("vm").(driver.VirtualMachine)
+
+	section := s.Config.section(s.VMName)
+	if *section.Enabled {
+		ui.Sayf("Configuring guest customization (computer name %s)...", section.ComputerName)
+	} else {
+		ui.Say("Disabling guest customization...")
+	}
+	if err := vm.SetGuestCustomization(section); err != nil {
+		state.Put("error", fmt.Errorf("error configuring guest customization: %w", err))
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepGuestCustomization) Cleanup(state multistep.StateBag) {}
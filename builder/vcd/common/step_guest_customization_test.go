@@ -0,0 +1,26 @@
+package common
+
+import "testing"
+
+func TestDefaultComputerName(t *testing.T) {
+	tests := []struct {
+		vmName string
+		want   string
+	}{
+		{"web01", "web01"},
+		{"packer-ubuntu-2404-build", "packer-ubuntu-2"},
+		{"ubuntu_24.04 (build)", "ubuntu-24-04-bu"},
+		{"-leading", "leading"},
+		{"abcdefghijklmn-opq", "abcdefghijklmn"},
+		{"__", "packer"},
+	}
+	for _, tt := range tests {
+		got := defaultComputerName(tt.vmName)
+		if got != tt.want {
+			t.Errorf("defaultComputerName(%q) = %q, want %q", tt.vmName, got, tt.want)
+		}
+		if !computerNameRe.MatchString(got) {
+			t.Errorf("defaultComputerName(%q) = %q, not a valid computer name", tt.vmName, got)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+)
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type GuestInfoConfig
+
+// GuestInfoConfig contains configuration for handing boot parameters to the
+// guest through guestinfo properties instead of typing them on the console.
+type GuestInfoConfig struct {
+	// Properties set in the `guestinfo` namespace of the VM before it is
+	// powered on, which the guest reads through VMware Tools, e.g. with
+	// `vmware-rpctool "info-get guestinfo.userdata"`, or through the VMware
+	// datasource of cloud-init and the VMware provider of Ignition. Keys are
+	// prefixed with `guestinfo.` when they are not already. Values are
+	// interpolated with the same variables as `boot_command`, such as
+	// `{{ .HTTPIP }}` and `{{ .VMIP }}`. Together with a boot configuration
+	// on the ISO (see `cd_content`) that starts the installer unattended, no
+	// `boot_command` is needed, and the console, which may not be reachable
+	// from the build host, is never opened.
+	//
+	// ```hcl
+	//   guestinfo = {
+	//     "userdata" = file("user-data.yml")
+	//     "metadata" = "local-hostname: {{ .Name }}"
+	//     "ks_url"   = "http://{{ .HTTPIP }}:{{ .HTTPPort }}/ks.cfg"
+	//   }
+	// ```
+	//
+	// The properties are removed before the VM is exported, unless
+	// `guestinfo_keep` is set. Anyone with access to the VM can read them
+	// while it exists, so keep long-lived secrets out of them.
+	GuestInfo map[string]string `mapstructure:"guestinfo"`
+	// Keep the `guestinfo` properties on the VM, and in the template
+	// exported from it. Defaults to false.
+	GuestInfoKeep bool `mapstructure:"guestinfo_keep"`
+}
+
+// guestInfoPrefix is the ExtraConfig namespace the guest can read.
+const guestInfoPrefix = "guestinfo."
+
+func (c *GuestInfoConfig) Prepare() []error {
+	var errs []error
+
+	for key := range c.GuestInfo {
+		if strings.TrimPrefix(key, guestInfoPrefix) == "" {
+			errs = append(errs, fmt.Errorf("'guestinfo' keys must not be empty"))
+		}
+	}
+
+	return errs
+}
+
+// guestInfoKey returns the ExtraConfig key of a guestinfo property.
+func guestInfoKey(key string) string {
+	if strings.HasPrefix(key, guestInfoPrefix) {
+		return key
+	}
+	return guestInfoPrefix + key
+}
+
+// StepGuestInfo sets the guestinfo properties on the VM. It runs before the
+// VM is powered on, since installers read them early in the boot.
+type StepGuestInfo struct {
+	Config *GuestInfoConfig
+	VMName string
+	Ctx    interpolate.Context
+}
+
+func (s *StepGuestInfo) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	if len(s.Config.GuestInfo) == 0 {
+		return multistep.ActionContinue
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	vm := state.Get("vm").(driver.VirtualMachine)
+
+	tplCtx := newTemplateContext(s.Ctx, state, s.VMName)
+
+	entries := make(map[string]string, len(s.Config.GuestInfo))
+	keys := make([]string, 0, len(s.Config.GuestInfo))
+	for key, value := range s.Config.GuestInfo {
+		rendered, err := interpolate.Render(value, tplCtx)
+		if err != nil {
+			state.Put("error", fmt.Errorf("error interpolating guestinfo %q: %w", key, err))
+			return multistep.ActionHalt
+		}
+		key = guestInfoKey(key)
+		entries[key] = rendered
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	ui.Sayf("Setting guestinfo properties: %s", strings.Join(keys, ", "))
+	if err := vm.ChangeExtraConfig(entries); err != nil {
+		state.Put("error", fmt.Errorf("error setting guestinfo properties: %w", err))
+		return multistep.ActionHalt
+	}
+	state.Put("guestinfo_keys", keys)
+
+	return multistep.ActionContinue
+}
+
+func (s *StepGuestInfo) Cleanup(state multistep.StateBag) {}
+
+// StepRemoveGuestInfo removes the guestinfo properties once the VM is shut
+// down, so that they do not end up in the exported template, where they
+// would leak and be read again by the VMs deployed from it.
+type StepRemoveGuestInfo struct {
+	Config *GuestInfoConfig
+}
+
+func (s *StepRemoveGuestInfo) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	keys, ok := state.GetOk("guestinfo_keys")
+	if !ok || s.Config.GuestInfoKeep {
+		return multistep.ActionContinue
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	vm := state.Get("vm").(driver.VirtualMachine)
+
+	ui.Say("Removing guestinfo properties...")
+	if err := vm.RemoveExtraConfig(keys.([]string)); err != nil {
+		state.Put("error", fmt.Errorf("error removing guestinfo properties: %w", err))
+		return multistep.ActionHalt
+	}
+	state.Remove("guestinfo_keys")
+
+	return multistep.ActionContinue
+}
+
+func (s *StepRemoveGuestInfo) Cleanup(state multistep.StateBag) {}
@@ -1,6 +1,12 @@
 package common
 
-import "fmt"
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+)
 
 type HardwareConfig struct {
 	// The number of virtual CPUs cores for the virtual machine.
@@ -44,6 +50,10 @@ type HardwareConfig struct {
 	// instead of manual CPU and memory configuration. Mutually exclusive with
 	// CPUs and memory settings.
 	VMSizingPolicy string `mapstructure:"vm_sizing_policy"`
+	// VM placement policy name or ID (`urn:vcloud:vdcComputePolicy:...`). The
+	// VM is placed on the VM groups of this policy, e.g. to pin it to hosts
+	// with specific licensing. Can be combined with either sizing option.
+	VMPlacementPolicy string `mapstructure:"vm_placement_policy"`
 
 	// Extra VM configuration entries applied via VCD's ExtraConfig API (the
 	// equivalent of VMware's `.vmx` settings). Keys and values are passed
@@ -55,8 +65,24 @@ type HardwareConfig struct {
 	//     "svga.vramSize" = "134217728"
 	//   }
 	ExtraConfig map[string]string `mapstructure:"extra_config"`
+	// Synchronize the guest clock with the ESXi host through VMware Tools.
+	// The setting is applied to cloned and imported VMs too, whatever their
+	// source had. Leave it off when the guest keeps time with NTP or a
+	// domain controller. Defaults to `false`.
+	TimeSyncWithHost bool `mapstructure:"time_sync_with_host"`
+	// Keep the firmware clock (RTC) of the VM in UTC, as Linux guests
+	// expect, by setting `rtc.diffFromUTC` to 0. Windows guests read the
+	// firmware clock as local time, and are better served by
+	// `rtc.diffFromUTC` in `extra_config` or by setting
+	// `RealTimeIsUniversal` in the guest. Defaults to `false`, leaving the
+	// clock as VCD sets it.
+	FirmwareClockUTC bool `mapstructure:"firmware_clock_utc"`
 }
 
+// rtcOffsetKey is the extra config key holding the offset of the firmware
+// clock from UTC, in seconds.
+const rtcOffsetKey = "rtc.diffFromUTC"
+
 func (c *HardwareConfig) Prepare() []error {
 	var errs []error
 
@@ -76,9 +102,55 @@ func (c *HardwareConfig) Prepare() []error {
 		errs = append(errs, fmt.Errorf("cannot specify both 'vm_sizing_policy' and 'CPUs'/'memory'"))
 	}
 
-	if !hasSizingPolicy && !hasManualSize {
-		errs = append(errs, fmt.Errorf("must specify either 'vm_sizing_policy' or both 'CPUs' and 'memory'"))
+	if _, ok := c.ExtraConfig[rtcOffsetKey]; ok && c.FirmwareClockUTC {
+		errs = append(errs, fmt.Errorf("'firmware_clock_utc' conflicts with %q in 'extra_config'", rtcOffsetKey))
 	}
 
 	return errs
 }
+
+// IsSized reports whether the configuration sets the VM size, either through
+// a sizing policy or through manual CPU/memory values. Builders that create
+// VMs from scratch require this; builders that clone keep the source size
+// when it is not set.
+func (c *HardwareConfig) IsSized() bool {
+	return c.VMSizingPolicy != "" || c.CPUs > 0 || c.Memory > 0
+}
+
+// VMExtraConfig returns the extra config entries to set on the VM:
+// extra_config and the firmware clock setting.
+func (c *HardwareConfig) VMExtraConfig() map[string]string {
+	entries := make(map[string]string, len(c.ExtraConfig)+1)
+	for key, value := range c.ExtraConfig {
+		entries[key] = value
+	}
+	if c.FirmwareClockUTC {
+		entries[rtcOffsetKey] = "0"
+	}
+	return entries
+}
+
+// checkPolicies checks that the VM sizing and placement policies are
+// assigned to the VDC.
+func (c *HardwareConfig) checkPolicies(d driver.Driver, vdc *govcd.Vdc) []error {
+	if c.VMSizingPolicy == "" && c.VMPlacementPolicy == "" {
+		return nil
+	}
+	policies, err := d.GetClient().GetAllAssignedVdcComputePoliciesV2(vdc.Vdc.ID, url.Values{})
+	if err != nil {
+		return []error{fmt.Errorf("error getting compute policies: %w", err)}
+	}
+
+	var errs []error
+	if c.VMSizingPolicy != "" {
+		if _, err := driver.GetVMSizingPolicyByName(policies, c.VMSizingPolicy); err != nil {
+			errs = append(errs, fmt.Errorf("'vm_sizing_policy': VM sizing policy %q not found in VDC %s", c.VMSizingPolicy, vdc.Vdc.Name))
+		}
+	}
+	if c.VMPlacementPolicy != "" {
+		if _, err := driver.GetVMPlacementPolicy(policies, c.VMPlacementPolicy); err != nil {
+			errs = append(errs, fmt.Errorf("'vm_placement_policy': VM placement policy %q not found in VDC %s", c.VMPlacementPolicy, vdc.Vdc.Name))
+		}
+	}
+	return errs
+}
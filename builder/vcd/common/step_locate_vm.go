@@ -0,0 +1,70 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+)
+
+// StepLocateVM finds an existing virtual machine by vApp and name and puts it
+// in state in place of a newly created one. The VM must be powered off so the
+// build starts from a known state.
+type StepLocateVM struct {
+	VDCName  string
+	VAppName string
+	VMName   string
+}
+
+func (s *StepLocateVM) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	d := state.Get("driver").(driver.Driver)
+
+	ui.Sayf("Getting VDC: %s", s.VDCName)
+	vdc, err := d.GetVdc(s.VDCName)
+	if err != nil {
+		state.Put("error", fmt.Errorf("error getting VDC %s: %w", s.VDCName, err))
+		return multistep.ActionHalt
+	}
+	state.Put("vdc", vdc)
+
+	ui.Sayf("Looking for vApp: %s", s.VAppName)
+	vapp, err := vdc.GetVAppByName(s.VAppName, true)
+	if err != nil {
+		state.Put("error", fmt.Errorf("error getting vApp %s: %w", s.VAppName, err))
+		return multistep.ActionHalt
+	}
+	state.Put("vapp", vapp)
+	state.Put("vapp_name", s.VAppName)
+	state.Put("vapp_created", false)
+
+	ui.Sayf("Looking for VM: %s", s.VMName)
+	govcdVM, err := vapp.GetVMByName(s.VMName, true)
+	if err != nil {
+		state.Put("error", fmt.Errorf("error getting VM %s in vApp %s: %w", s.VMName, s.VAppName, err))
+		return multistep.ActionHalt
+	}
+	vm := d.NewVM(govcdVM)
+
+	off, err := vm.IsPoweredOff()
+	if err != nil {
+		state.Put("error", fmt.Errorf("error getting power state of VM %s: %w", s.VMName, err))
+		return multistep.ActionHalt
+	}
+	if !off {
+		status, _ := vm.GetStatus()
+		state.Put("error", fmt.Errorf("VM %s must be powered off before it can be customized (current state: %s)", s.VMName, status))
+		return multistep.ActionHalt
+	}
+
+	state.Put("vm", vm)
+	ui.Sayf("Found existing VM: %s", s.VMName)
+
+	return multistep.ActionContinue
+}
+
+func (s *StepLocateVM) Cleanup(state multistep.StateBag) {
+	// The VM existed before the build and is never deleted
+}
@@ -0,0 +1,94 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+)
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type MetadataConfig
+
+// MetadataConfig defines the metadata written onto the VM and onto the
+// template captured by `export_to_catalog`, next to the build provenance
+// entries, for lifecycle policies and inventories downstream.
+type MetadataConfig struct {
+	// Metadata entries to write onto the VM, once it is created, and onto
+	// the captured template. Entries with the same key as a provenance
+	// entry, such as `packer.build_date`, take precedence. Values are
+	// templates, e.g. `"{{ timestamp }}"`.
+	Metadata map[string]string `mapstructure:"metadata"`
+}
+
+func (c *MetadataConfig) Prepare() []error {
+	var errs []error
+
+	for key := range c.Metadata {
+		if strings.TrimSpace(key) == "" {
+			errs = append(errs, fmt.Errorf("'metadata' keys must not be empty"))
+			break
+		}
+	}
+
+	return errs
+}
+
+// mergeMetadata returns the provenance entries with the metadata entries
+// added, replacing the provenance entries with the same keys.
+func mergeMetadata(provenance, metadata map[string]string) map[string]string {
+	entries := make(map[string]string, len(provenance)+len(metadata))
+	for key, value := range provenance {
+		entries[key] = value
+	}
+	for key, value := range metadata {
+		entries[key] = value
+	}
+	return entries
+}
+
+// sayMetadata lists the metadata entries, sorted by key.
+func sayMetadata(ui packersdk.Ui, entries map[string]string) {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		ui.Message(fmt.Sprintf("%s = %s", key, entries[key]))
+	}
+}
+
+// StepMetadata writes the build provenance and the metadata entries onto
+// the VM, so that it can be told apart from other VMs while it is built.
+type StepMetadata struct {
+	Config *MetadataConfig
+	// Provenance metadata entries, see Provenance. The same entries are
+	// written onto the captured template.
+	Provenance map[string]string
+}
+
+func (s *StepMetadata) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	vm := state.Get("vm").(driver.VirtualMachine)
+
+	entries := mergeMetadata(s.Provenance, s.Config.Metadata)
+	if len(entries) == 0 {
+		return multistep.ActionContinue
+	}
+
+	ui.Say("Writing metadata to VM...")
+	sayMetadata(ui, entries)
+	if err := vm.MergeMetadata(entries); err != nil {
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepMetadata) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,83 @@
+package common
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestMergeMetadata(t *testing.T) {
+	provenance := map[string]string{
+		"packer.build_name": "base",
+		"packer.build_date": "2025-06-30T12:00:00Z",
+	}
+	metadata := map[string]string{
+		"packer.build_date": "2025-01-01",
+		"owner":             "platform",
+	}
+
+	got := mergeMetadata(provenance, metadata)
+	want := map[string]string{
+		"packer.build_name": "base",
+		"packer.build_date": "2025-01-01",
+		"owner":             "platform",
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("mergeMetadata() = %v, want %v", got, want)
+	}
+	if provenance["packer.build_date"] != "2025-06-30T12:00:00Z" {
+		t.Error("mergeMetadata() changed the provenance entries")
+	}
+
+	if got := mergeMetadata(nil, nil); len(got) != 0 {
+		t.Errorf("mergeMetadata(nil, nil) = %v, want no entries", got)
+	}
+	if got := mergeMetadata(nil, metadata); !maps.Equal(got, metadata) {
+		t.Errorf("mergeMetadata(nil, metadata) = %v, want %v", got, metadata)
+	}
+}
+
+func TestStepMetadata(t *testing.T) {
+	state, _, vm := newTestState(t)
+
+	step := &StepMetadata{
+		Config: &MetadataConfig{Metadata: map[string]string{
+			"packer.build_date": "2025-01-01",
+			"owner":             "platform",
+		}},
+		Provenance: map[string]string{
+			"packer.build_name": "base",
+			"packer.build_date": "2025-06-30T12:00:00Z",
+		},
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Run() = %v: %v", action, stepError(state))
+	}
+
+	want := map[string]string{
+		"packer.build_name": "base",
+		"packer.build_date": "2025-01-01",
+		"owner":             "platform",
+	}
+	if !maps.Equal(vm.Metadata, want) {
+		t.Errorf("VM metadata = %v, want %v", vm.Metadata, want)
+	}
+}
+
+func TestStepMetadata_Error(t *testing.T) {
+	state, d, _ := newTestState(t)
+	d.Tasks.Fail("MergeMetadata", context.DeadlineExceeded)
+
+	step := &StepMetadata{
+		Config:     &MetadataConfig{},
+		Provenance: map[string]string{"packer.build_name": "base"},
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("Run() = %v, want ActionHalt", action)
+	}
+	if stepError(state) == nil {
+		t.Error("no error in the state")
+	}
+}
@@ -10,33 +10,157 @@ import (
 	"net/netip"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 )
 
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type ISOBootConfig
+
+// ISOBootConfig overrides the El Torito boot settings used when cd_content
+// and cd_files are added to the ISO, for ISOs whose boot layout is not
+// detected correctly, such as appliance ISOs with nonstandard layouts.
+type ISOBootConfig struct {
+	// Path in the ISO of the BIOS boot image, e.g.
+	// `boot/isolinux/isolinux.bin`. By default the boot records of the ISO
+	// are copied as they are, or for Windows (UDF) ISOs `boot/etfsboot.com`
+	// is used. Setting any of these options rebuilds the boot records from
+	// the detected boot images instead. The isohybrid MBR and GPT of Linux
+	// ISOs are kept either way.
+	BIOSBootImage string `mapstructure:"bios_boot_image"`
+	// Number of 512 byte sectors of the BIOS boot image loaded by the
+	// BIOS. Defaults to 4 for isolinux and 8 for Windows ISOs.
+	BIOSBootLoadSize int `mapstructure:"bios_boot_load_size"`
+	// Real mode segment the BIOS boot image is loaded at, e.g. `0x07C0`.
+	// Can only be set for Windows (UDF) ISOs. Defaults to `0x07C0`.
+	BIOSBootLoadSegment string `mapstructure:"bios_boot_load_segment"`
+	// Patch a boot info table into the BIOS boot image, as isolinux needs.
+	// Enabled for the detected isolinux boot images, and off by default
+	// when `bios_boot_image` is set.
+	BIOSBootInfoTable bool `mapstructure:"bios_boot_info_table"`
+	// Path in the ISO of the EFI boot image, e.g. `efi/boot/efiboot.img`.
+	EFIBootImage string `mapstructure:"efi_boot_image"`
+	// Files to write into the EFI boot image, a FAT image, by path in the
+	// image, e.g. `boot/grub/grub.cfg`. UEFI boot loaders such as GRUB read
+	// their configuration from it, not from the ISO, so kernel arguments
+	// added to the ISO's `grub.cfg` are ignored on UEFI boots. Rendered as
+	// templates like cd_content. The boot image must be a file of the ISO,
+	// like Debian's `boot/grub/efi.img`, and the ISO is modified with
+	// xorriso; mtools must be installed.
+	EFIBootContent map[string]string `mapstructure:"efi_boot_content"`
+	// Kernel arguments appended to every kernel command line of the boot
+	// loader configurations of Linux ISOs, isolinux `append` and GRUB
+	// `linux` lines, e.g. `autoinstall ds=nocloud;s=/cdrom/`. This boots
+	// the installer unattended without typing a boot_command. Arguments
+	// go before a `---` separator, and semicolons are escaped for GRUB.
+	// Rendered as a template like cd_content. Configurations in
+	// cd_content are edited instead of those of the ISO, but not those in
+	// the EFI boot image, which efi_boot_content replaces.
+	BootParameters string `mapstructure:"boot_parameters"`
+
+	loadSegment uint16
+}
+
+func (c *ISOBootConfig) Prepare() []error {
+	var errs []error
+
+	if c.BIOSBootLoadSize < 0 || c.BIOSBootLoadSize > 0xFFFF {
+		errs = append(errs, fmt.Errorf("'bios_boot_load_size' must be between 0 and 65535 sectors"))
+	}
+	if c.BIOSBootLoadSegment != "" {
+		segment, err := strconv.ParseUint(c.BIOSBootLoadSegment, 0, 16)
+		if err != nil || segment == 0 {
+			errs = append(errs, fmt.Errorf("'bios_boot_load_segment' must be a segment such as 0x07C0, got %q", c.BIOSBootLoadSegment))
+		} else {
+			c.loadSegment = uint16(segment)
+		}
+	}
+
+	return errs
+}
+
+// Overrides returns the boot settings for the ISO modifier.
+func (c *ISOBootConfig) Overrides() BootOverrides {
+	return BootOverrides{
+		BIOSBootImage:    c.BIOSBootImage,
+		BIOSLoadSize:     uint16(c.BIOSBootLoadSize),
+		BIOSLoadSegment:  c.loadSegment,
+		NeedsBootInfoTbl: c.BIOSBootInfoTable,
+		UEFIBootImage:    c.EFIBootImage,
+	}
+}
+
 // StepModifyISO modifies the downloaded ISO to include cd_content and cd_files
 // This is needed because VCD only has one media slot, so we can't attach
-// a separate CD for additional content.
+// a separate CD for additional content, unless StepAddCDDrive added a second
+// drive: the files then go on an ISO of their own, for StepMountCD.
 type StepModifyISO struct {
 	Config *commonsteps.CDConfig
+	// Files added as they are, without template rendering, for binary
+	// content. Optional.
+	BinaryContent map[string][]byte
+	// Files written into the boot image of sources/boot.wim of Windows
+	// ISOs, rendered like cd_content. Optional.
+	BootWIMContent map[string]string
+	// Directory for the modified ISO and its temporary files.
+	// Defaults to os.TempDir().
+	WorkDirectory string
+	// Directory to save rendered cd_content to for debugging. Relative
+	// paths are inside WorkDirectory. Nothing is saved when empty.
+	DebugRenderDir string
+	// El Torito settings replacing the detected ones. Optional.
+	Boot *ISOBootConfig
+	// Tool the ISO is modified with, one of the ISOTool constants.
+	// Defaults to ISOToolAuto.
+	Tool string
+	// Floppy files added to the ISO when StepAttachFloppy finds no floppy
+	// drive in the VM. Optional.
+	Floppy *commonsteps.FloppyConfig
+	// Volume label of the modified ISO. Defaults to the label of the
+	// source ISO.
+	VolumeLabel string
+	// Cache the modified ISO in ModifiedISOCacheDir for builds adding the
+	// same files to the same ISO, identified by ISOChecksum, the
+	// iso_checksum of the source ISO. Without a literal checksum the
+	// source ISO is hashed.
+	Cache       bool
+	ISOChecksum string
+	// cd_content is rendered as a template, with the variables and
+	// functions of boot_command.
+	VMName string
+	Ctx    interpolate.Context
 
 	modifiedISOPath string
+	cdPath          string
 	debugFiles      []string
 }
 
 func (s *StepModifyISO) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
 	// Check if there's anything to add
-	if s.Config == nil {
+	floppyInISO, _ := state.Get("floppy_in_iso").(bool)
+	hasFloppy := floppyInISO && s.Floppy != nil
+	hasEFI := s.Boot != nil && len(s.Boot.EFIBootContent) > 0
+	hasBinary := len(s.BinaryContent) > 0
+	hasWIM := len(s.BootWIMContent) > 0
+	hasParams := s.Boot != nil && s.Boot.BootParameters != ""
+	if s.Config == nil && !hasFloppy && !hasEFI && !hasBinary && !hasWIM && !hasParams {
 		return multistep.ActionContinue
 	}
+	if s.Config == nil {
+		s.Config = &commonsteps.CDConfig{}
+	}
 
-	hasContent := len(s.Config.CDContent) > 0
+	hasContent := len(s.Config.CDContent) > 0 || hasBinary
 	hasFiles := len(s.Config.CDFiles) > 0
 
-	if !hasContent && !hasFiles {
+	if !hasContent && !hasFiles && !hasFloppy && !hasEFI && !hasWIM && !hasParams {
 		return multistep.ActionContinue
 	}
 
@@ -49,38 +173,170 @@ func (s *StepModifyISO) Run(_ context.Context, state multistep.StateBag) multist
 		return multistep.ActionHalt
 	}
 
-	ui.Say("Modifying ISO to include cd_content/cd_files...")
+	deviceID, secondaryDrive := state.Get("cd_drive_id").(string)
+	if secondaryDrive {
+		ui.Sayf("Creating ISO with cd_content/cd_files for CD-ROM drive %s...", deviceID)
+	} else {
+		ui.Say("Modifying ISO to include cd_content/cd_files...")
+	}
+
+	workDir := s.WorkDirectory
+	if workDir == "" {
+		workDir = os.TempDir()
+	} else if err := os.MkdirAll(workDir, 0755); err != nil {
+		state.Put("error", fmt.Errorf("failed to create work directory %s: %w", workDir, err))
+		return multistep.ActionHalt
+	}
 
 	// Create modifier
 	modifier := NewISOModifier(isoPath)
+	modifier.SetWorkDir(workDir)
+	if s.Boot != nil {
+		modifier.SetBootOverrides(s.Boot.Overrides())
+	}
+	modifier.SetTool(s.Tool)
+	modifier.SetVolumeID(s.VolumeLabel)
 
-	// Check if this is a UDF ISO (Windows) and verify tools are available
+	// Windows ISOs use UDF, and are rebuilt natively unless xorriso is
+	// asked for
 	isUDF, err := modifier.IsUDF()
 	if err != nil {
 		ui.Message(fmt.Sprintf("Warning: Could not detect filesystem type: %v", err))
 	} else if isUDF {
 		ui.Message("Detected UDF filesystem (Windows ISO)")
-		if err := CheckUDFTools(); err != nil {
+	}
+	useXorriso, err := modifier.UsesXorriso()
+	if err != nil {
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
+	if useXorriso {
+		if err := CheckXorriso(); err != nil {
+			state.Put("error", err)
+			return multistep.ActionHalt
+		}
+		if isUDF && !secondaryDrive {
+			ui.Message("Modifying the ISO with xorriso, which keeps only its ISO9660 tree")
+		}
+	} else if s.Tool == ISOToolAppend && !secondaryDrive {
+		ui.Message("Appending the files to a copy of the ISO instead of rebuilding it")
+	}
+	if hasEFI && !secondaryDrive {
+		if !useXorriso {
+			state.Put("error", fmt.Errorf("efi_boot_content needs the ISO to be modified with xorriso, set iso_builder_tool to xorriso"))
+			return multistep.ActionHalt
+		}
+		if err := CheckMtools(); err != nil {
+			state.Put("error", err)
+			return multistep.ActionHalt
+		}
+	}
+	if hasParams && !secondaryDrive && isUDF {
+		state.Put("error", fmt.Errorf("boot_parameters needs a Linux ISO with an isolinux or GRUB configuration"))
+		return multistep.ActionHalt
+	}
+	if hasWIM && !secondaryDrive {
+		if !isUDF {
+			state.Put("error", fmt.Errorf("boot_wim_content needs a Windows (UDF) ISO with %s", bootWIMPath))
+			return multistep.ActionHalt
+		}
+		if err := CheckWimlib(); err != nil {
 			state.Put("error", err)
 			return multistep.ActionHalt
 		}
 	}
 
-	// Build template variables from state
-	templateVars := s.buildTemplateVars(state, ui)
+	// Rendered cd_content often holds password hashes and keys, so it is
+	// only saved when asked for
+	debugDir := ""
+	if s.DebugRenderDir != "" {
+		debugDir = s.DebugRenderDir
+		if !filepath.IsAbs(debugDir) {
+			debugDir = filepath.Join(workDir, debugDir)
+		}
+		if err := os.MkdirAll(debugDir, 0700); err != nil {
+			state.Put("error", fmt.Errorf("failed to create debug render directory %s: %w", debugDir, err))
+			return multistep.ActionHalt
+		}
+	}
 
-	// Add cd_content entries (with template variable substitution)
+	// Template variables from state
+	tplCtx := newTemplateContext(s.Ctx, state, s.VMName)
+	vars := tplCtx.Data.(map[string]interface{})
+	names := make([]string, 0, len(vars))
+	for name, value := range vars {
+		if value != "" && value != 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ui.Message(fmt.Sprintf("  Template variable: %s = %v", name, vars[name]))
+	}
+
+	// Floppy files go in first, so that cd_content and cd_files take
+	// precedence
+	if hasFloppy {
+		if err := addFloppyFiles(modifier, s.Floppy, ui); err != nil {
+			state.Put("error", err)
+			return multistep.ActionHalt
+		}
+	}
+
+	// Add cd_content entries (rendered as templates)
 	for path, content := range s.Config.CDContent {
-		// Process template variables in content
-		processedContent := s.processTemplateVars(content, templateVars)
+		// Rendered once, so that rendered values are never parsed again
+		processedContent, err := interpolate.RenderOnce(content, tplCtx)
+		if err != nil {
+			state.Put("error", fmt.Errorf("error rendering cd_content %s: %w", path, err))
+			return multistep.ActionHalt
+		}
 		modifier.AddContent(path, []byte(processedContent))
 		ui.Message(fmt.Sprintf("  Adding content: %s (%d bytes)", path, len(processedContent)))
 
-		// Save processed content to /tmp for debugging
-		debugPath := filepath.Join("/tmp", "packer-debug-"+filepath.Base(path))
-		if err := os.WriteFile(debugPath, []byte(processedContent), 0644); err == nil {
-			ui.Message(fmt.Sprintf("  Debug: saved processed content to %s", debugPath))
+		// Save processed content for debugging
+		if debugDir != "" {
+			debugPath := filepath.Join(debugDir, "packer-debug-"+filepath.Base(path))
+			// Register before writing so even a partially written file is
+			// removed on cleanup
 			s.debugFiles = append(s.debugFiles, debugPath)
+			if err := writePrivateFile(debugPath, []byte(processedContent)); err != nil {
+				ui.Error(fmt.Sprintf("Warning: failed to save processed content of %s: %v", path, err))
+			} else {
+				ui.Message(fmt.Sprintf("  Debug: saved processed content to %s", debugPath))
+			}
+		}
+	}
+
+	// Binary content is added as it is
+	for path, content := range s.BinaryContent {
+		modifier.AddContent(path, content)
+		ui.Message(fmt.Sprintf("  Adding binary content: %s (%d bytes)", path, len(content)))
+	}
+
+	// Add efi_boot_content entries, rendered like cd_content
+	if hasEFI {
+		for path, content := range s.Boot.EFIBootContent {
+			processedContent, err := interpolate.RenderOnce(content, tplCtx)
+			if err != nil {
+				state.Put("error", fmt.Errorf("error rendering efi_boot_content %s: %w", path, err))
+				return multistep.ActionHalt
+			}
+			modifier.AddEFIBootContent(path, []byte(processedContent))
+			ui.Message(fmt.Sprintf("  Adding EFI boot content: %s (%d bytes)", path, len(processedContent)))
+		}
+	}
+
+	// Add boot_wim_content entries, rendered like cd_content
+	if hasWIM {
+		for path, content := range s.BootWIMContent {
+			processedContent, err := interpolate.RenderOnce(content, tplCtx)
+			if err != nil {
+				state.Put("error", fmt.Errorf("error rendering boot_wim_content %s: %w", path, err))
+				return multistep.ActionHalt
+			}
+			modifier.AddBootWIMContent(path, []byte(processedContent))
+			ui.Message(fmt.Sprintf("  Adding boot.wim content: %s (%d bytes)", path, len(processedContent)))
 		}
 	}
 
@@ -109,6 +365,19 @@ func (s *StepModifyISO) Run(_ context.Context, state multistep.StateBag) multist
 				}
 
 				isoPath := filepath.Join(baseName, relPath)
+
+				// Links are kept as links rather than copies of their
+				// targets
+				if info.Mode()&os.ModeSymlink != 0 {
+					target, err := os.Readlink(path)
+					if err != nil {
+						return err
+					}
+					ui.Message(fmt.Sprintf("  Adding link: %s -> %s", isoPath, target))
+					modifier.AddSymlink(isoPath, target)
+					return nil
+				}
+
 				ui.Message(fmt.Sprintf("  Adding file: %s", isoPath))
 				return modifier.AddFile(isoPath, path)
 			})
@@ -125,6 +394,10 @@ func (s *StepModifyISO) Run(_ context.Context, state multistep.StateBag) multist
 				ui.Message(fmt.Sprintf("  Skipping %s (overridden by cd_content)", isoPath))
 				continue
 			}
+			if _, exists := s.BinaryContent[isoPath]; exists {
+				ui.Message(fmt.Sprintf("  Skipping %s (overridden by cd_content_base64)", isoPath))
+				continue
+			}
 
 			ui.Message(fmt.Sprintf("  Adding file: %s", isoPath))
 			if err := modifier.AddFile(isoPath, localPath); err != nil {
@@ -134,6 +407,28 @@ func (s *StepModifyISO) Run(_ context.Context, state multistep.StateBag) multist
 		}
 	}
 
+	if secondaryDrive {
+		return s.createCDISO(state, modifier, isoPath, workDir)
+	}
+
+	// boot_parameters edits the boot loader configurations last, so that
+	// those in cd_content are edited too
+	if hasParams {
+		params, err := interpolate.RenderOnce(s.Boot.BootParameters, tplCtx)
+		if err != nil {
+			state.Put("error", fmt.Errorf("error rendering boot_parameters: %w", err))
+			return multistep.ActionHalt
+		}
+		edited, err := modifier.AddBootParameters(params)
+		if err != nil {
+			state.Put("error", err)
+			return multistep.ActionHalt
+		}
+		for _, path := range edited {
+			ui.Message(fmt.Sprintf("  Adding boot parameters to %s: %s", path, params))
+		}
+	}
+
 	// Detect boot configuration
 	bootConfig, err := modifier.DetectBootConfig()
 	if err != nil {
@@ -150,7 +445,12 @@ func (s *StepModifyISO) Run(_ context.Context, state multistep.StateBag) multist
 		if bootConfig.HasUEFIBoot {
 			ui.Message(fmt.Sprintf("  Detected UEFI boot: %s", bootConfig.UEFIBootImage))
 		}
-		if bootConfig.VolumeID != "" {
+		if s.Boot != nil && !s.Boot.Overrides().IsZero() {
+			ui.Message("  Boot settings overridden by configuration, boot records will be rebuilt")
+		}
+		if s.VolumeLabel != "" {
+			ui.Message(fmt.Sprintf("  Volume ID: %s (was %s)", s.VolumeLabel, bootConfig.VolumeID))
+		} else if bootConfig.VolumeID != "" {
 			ui.Message(fmt.Sprintf("  Volume ID: %s", bootConfig.VolumeID))
 		}
 		if !bootConfig.HasBIOSBoot && !bootConfig.HasUEFIBoot {
@@ -158,22 +458,53 @@ func (s *StepModifyISO) Run(_ context.Context, state multistep.StateBag) multist
 		}
 	}
 
-	// Create modified ISO in temp directory
-	tmpDir := os.TempDir()
+	// Create modified ISO in the work directory
 	originalName := filepath.Base(isoPath)
 	modifiedName := strings.TrimSuffix(originalName, filepath.Ext(originalName)) + "-modified.iso"
-	modifiedPath := filepath.Join(tmpDir, modifiedName)
+	modifiedPath := filepath.Join(workDir, modifiedName)
+
+	// Builds adding the same files to the same ISO reuse the modified ISO,
+	// which is written straight into the cache otherwise
+	cachedPath := ""
+	if s.Cache {
+		cachedPath, err = s.cachedISOPath(ui, modifier, isoPath, modifiedName)
+		if err != nil {
+			ui.Error(fmt.Sprintf("Warning: not caching the modified ISO: %v", err))
+		} else if checksum, ok := lookupCachedISO(cachedPath); ok {
+			ui.Say(fmt.Sprintf("Using cached modified ISO: %s", cachedPath))
+			ui.Message(fmt.Sprintf("  SHA256: %s", checksum))
+			state.Put("iso_path", cachedPath)
+			state.Put("iso_checksum", "sha256:"+checksum)
+			state.Put("iso_modified", true)
+			return multistep.ActionContinue
+		} else {
+			// Not an .iso until complete, so that pruning skips it
+			modifiedPath = fmt.Sprintf("%s.%d.tmp", cachedPath, time.Now().UnixNano())
+		}
+	}
+
+	// Make sure the output directory can hold the modified ISO before
+	// spending minutes on it
+	needed, err := modifier.RequiredSpace()
+	if err != nil {
+		state.Put("error", fmt.Errorf("failed to estimate space needed for modified ISO: %w", err))
+		return multistep.ActionHalt
+	}
+	if err := checkFreeSpace(filepath.Dir(modifiedPath), needed); err != nil {
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
 
 	ui.Say(fmt.Sprintf("Creating modified ISO: %s", modifiedName))
 
+	// Registered first so even a partially written ISO is removed
+	s.modifiedISOPath = modifiedPath
 	checksum, err := modifier.CreateModifiedISO(modifiedPath)
 	if err != nil {
 		state.Put("error", fmt.Errorf("failed to create modified ISO: %w", err))
 		return multistep.ActionHalt
 	}
 
-	s.modifiedISOPath = modifiedPath
-
 	// Get modified ISO size
 	modifiedInfo, _ := os.Stat(modifiedPath)
 	originalInfo, _ := os.Stat(isoPath)
@@ -183,6 +514,17 @@ func (s *StepModifyISO) Run(_ context.Context, state multistep.StateBag) multist
 	ui.Message(fmt.Sprintf("  Modified size: %d MB", modifiedInfo.Size()/(1024*1024)))
 	ui.Message(fmt.Sprintf("  SHA256: %s", checksum))
 
+	if cachedPath != "" {
+		if err := storeCachedISO(modifiedPath, cachedPath, checksum); err != nil {
+			ui.Error(fmt.Sprintf("Warning: failed to cache the modified ISO: %v", err))
+		} else {
+			ui.Message(fmt.Sprintf("  Cached as %s", cachedPath))
+			// Kept for later builds
+			modifiedPath = cachedPath
+			s.modifiedISOPath = ""
+		}
+	}
+
 	// Update state with new ISO path
 	state.Put("iso_path", modifiedPath)
 	state.Put("iso_checksum", "sha256:"+checksum)
@@ -191,6 +533,60 @@ func (s *StepModifyISO) Run(_ context.Context, state multistep.StateBag) multist
 	return multistep.ActionContinue
 }
 
+// cachedISOPath returns the path of the modified ISO in the cache
+func (s *StepModifyISO) cachedISOPath(ui packersdk.Ui, modifier *ISOModifier, isoPath, modifiedName string) (string, error) {
+	dir, err := ModifiedISOCacheDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	source := mediaChecksum(s.ISOChecksum)
+	if source == "" {
+		ui.Say("Computing the checksum of the source ISO for the modified ISO cache...")
+		sum, err := modifier.calculateChecksum(isoPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to calculate checksum of %s: %w", isoPath, err)
+		}
+		source = "sha256:" + sum
+	}
+	return cachedISOPath(dir, modifiedName, modifier.CacheKey(source)), nil
+}
+
+// createCDISO creates the ISO with the added files alone, for the drive
+// added by StepAddCDDrive. It is small, so there is no space check, and it
+// needs no boot records.
+func (s *StepModifyISO) createCDISO(state multistep.StateBag, modifier *ISOModifier, isoPath, workDir string) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	label := s.Config.CDLabel
+	if label == "" {
+		label = "PACKER"
+	}
+	originalName := filepath.Base(isoPath)
+	cdName := strings.TrimSuffix(originalName, filepath.Ext(originalName)) + "-cd.iso"
+	cdPath := filepath.Join(workDir, cdName)
+
+	ui.Say(fmt.Sprintf("Creating CD ISO: %s", cdName))
+
+	// Registered first so even a partially written ISO is removed
+	s.cdPath = cdPath
+	checksum, err := modifier.CreateISO(cdPath, label)
+	if err != nil {
+		state.Put("error", fmt.Errorf("failed to create CD ISO: %w", err))
+		return multistep.ActionHalt
+	}
+
+	ui.Say("CD ISO created successfully")
+	ui.Message(fmt.Sprintf("  SHA256: %s", checksum))
+
+	// The boot ISO is uploaded as it is
+	state.Put("cd_path", cdPath)
+	return multistep.ActionContinue
+}
+
 func (s *StepModifyISO) Cleanup(state multistep.StateBag) {
 	ui := state.Get("ui").(packersdk.Ui)
 
@@ -202,6 +598,13 @@ func (s *StepModifyISO) Cleanup(state multistep.StateBag) {
 		}
 	}
 
+	if s.cdPath != "" {
+		ui.Message(fmt.Sprintf("Cleaning up CD ISO: %s", s.cdPath))
+		if err := os.Remove(s.cdPath); err != nil && !os.IsNotExist(err) {
+			ui.Error(fmt.Sprintf("Warning: failed to remove CD ISO: %v", err))
+		}
+	}
+
 	// Clean up debug files
 	for _, debugFile := range s.debugFiles {
 		if err := os.Remove(debugFile); err != nil && !os.IsNotExist(err) {
@@ -210,6 +613,69 @@ func (s *StepModifyISO) Cleanup(state multistep.StateBag) {
 	}
 }
 
+// addFloppyFiles adds the floppy files to the ISO laid out as on the
+// floppy: floppy_files flattened into the root, floppy_dirs with their
+// directory structure, and floppy_content last.
+func addFloppyFiles(modifier *ISOModifier, c *commonsteps.FloppyConfig, ui packersdk.Ui) error {
+	for _, pattern := range c.FloppyFiles {
+		matches := []string{pattern}
+		if strings.ContainsAny(pattern, "*?[") {
+			matches, _ = filepath.Glob(pattern)
+		}
+		for _, match := range matches {
+			err := filepath.Walk(match, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				ui.Message(fmt.Sprintf("  Adding floppy file: %s", filepath.Base(path)))
+				return modifier.AddFile(filepath.Base(path), path)
+			})
+			if err != nil {
+				return fmt.Errorf("failed to add floppy file %s: %w", match, err)
+			}
+		}
+	}
+
+	for _, pattern := range c.FloppyDirectories {
+		matches := []string{pattern}
+		if strings.ContainsAny(pattern, "*?[") {
+			matches, _ = filepath.Glob(pattern)
+		}
+		for _, match := range matches {
+			parent := filepath.Dir(filepath.Clean(match))
+			err := filepath.Walk(match, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				isoPath, err := filepath.Rel(parent, path)
+				if err != nil {
+					return err
+				}
+				ui.Message(fmt.Sprintf("  Adding floppy file: %s", filepath.ToSlash(isoPath)))
+				return modifier.AddFile(isoPath, path)
+			})
+			if err != nil {
+				return fmt.Errorf("failed to add floppy directory %s: %w", match, err)
+			}
+		}
+	}
+
+	for path, content := range c.FloppyContent {
+		ui.Message(fmt.Sprintf("  Adding floppy content: %s (%d bytes)", path, len(content)))
+		modifier.AddContent(path, []byte(content))
+	}
+	return nil
+}
+
+// writePrivateFile writes data to a file only readable by the current user.
+// Any existing file is replaced, as os.WriteFile keeps its permissions.
+func writePrivateFile(path string, data []byte) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
 // copyDir recursively copies a directory
 func copyDir(src, dst string) error {
 	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
@@ -250,69 +716,6 @@ func copyFile(src, dst string) error {
 	return err
 }
 
-// buildTemplateVars creates a map of template variables from state
-// These can be used in cd_content with syntax like {{ .VMIP }}
-func (s *StepModifyISO) buildTemplateVars(state multistep.StateBag, ui packersdk.Ui) map[string]string {
-	vars := make(map[string]string)
-
-	// VM IP address (from StepDiscoverIP)
-	if vmIP, ok := state.Get("vm_ip").(string); ok && vmIP != "" {
-		vars["VMIP"] = vmIP
-		ui.Message(fmt.Sprintf("  Template variable: VMIP = %s", vmIP))
-	}
-
-	// Network gateway
-	if gateway, ok := state.Get("network_gateway").(string); ok && gateway != "" {
-		vars["VMGateway"] = gateway
-		ui.Message(fmt.Sprintf("  Template variable: VMGateway = %s", gateway))
-	}
-
-	// Network netmask
-	if netmask, ok := state.Get("network_netmask").(string); ok && netmask != "" {
-		vars["VMNetmask"] = netmask
-		ui.Message(fmt.Sprintf("  Template variable: VMNetmask = %s", netmask))
-
-		// Also provide CIDR prefix (e.g., 24 for 255.255.255.0)
-		if prefix := netmaskToPrefix(netmask); prefix != "" {
-			vars["VMPrefix"] = prefix
-			ui.Message(fmt.Sprintf("  Template variable: VMPrefix = %s", prefix))
-		}
-	}
-
-	// DNS server
-	if dns, ok := state.Get("network_dns").(string); ok && dns != "" {
-		vars["VMDNS"] = dns
-		ui.Message(fmt.Sprintf("  Template variable: VMDNS = %s", dns))
-	}
-
-	// HTTP IP (from StepHTTPIPDiscover)
-	if httpIP, ok := state.Get("http_ip").(string); ok && httpIP != "" {
-		vars["HTTPIP"] = httpIP
-	}
-
-	// HTTP Port
-	if httpPort, ok := state.Get("http_port").(int); ok && httpPort > 0 {
-		vars["HTTPPort"] = fmt.Sprintf("%d", httpPort)
-	}
-
-	return vars
-}
-
-// processTemplateVars replaces template variables in content
-// Supports both {{ .VarName }} and {{.VarName}} syntax
-func (s *StepModifyISO) processTemplateVars(content string, vars map[string]string) string {
-	result := content
-
-	for name, value := range vars {
-		// Replace {{ .VarName }} (with spaces)
-		result = strings.ReplaceAll(result, "{{ ."+name+" }}", value)
-		// Replace {{.VarName}} (without spaces)
-		result = strings.ReplaceAll(result, "{{."+name+"}}", value)
-	}
-
-	return result
-}
-
 // netmaskToPrefix converts a dotted-decimal netmask to CIDR prefix length
 // e.g., "255.255.255.0" -> "24", "255.255.0.0" -> "16"
 func netmaskToPrefix(netmask string) string {
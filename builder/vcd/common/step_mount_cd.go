@@ -0,0 +1,122 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+)
+
+// StepMountCD uploads the ISO made by StepModifyISO from cd_content and
+// cd_files and mounts it in the drive added by StepAddCDDrive.
+type StepMountCD struct {
+	// How long to wait for the media to resolve.
+	ResolveTimeout time.Duration
+
+	media *govcd.Media
+	// partial is the media a failed upload left in the catalog.
+	partial *driver.MediaUploadError
+}
+
+func (s *StepMountCD) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	deviceID, ok := state.Get("cd_drive_id").(string)
+	if !ok {
+		return multistep.ActionContinue
+	}
+	cdPath, ok := state.Get("cd_path").(string)
+	if !ok || cdPath == "" {
+		return multistep.ActionContinue
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	d := state.Get("driver").(driver.Driver)
+	vm := state.Get("vm").(driver.VirtualMachine)
+	catalog := state.Get("catalog").(*govcd.Catalog)
+
+	// The ISO is specific to the build, so it is never cached
+	var media *govcd.Media
+	var err error
+	if s.partial != nil {
+		// Run again after a failed upload, e.g. retried with -on-error=ask
+		ui.Sayf("Resuming upload of CD image: %s", s.partial.Media.Media.Name)
+		media, err = d.ResumeMediaUpload(ctx, catalog, s.partial.Media, cdPath, s.partial.Uploaded)
+	} else {
+		mediaName := fmt.Sprintf("%s-cd-%d.iso", vm.GetName(), time.Now().Unix())
+		ui.Sayf("Uploading CD image: %s", mediaName)
+		media, err = d.UploadMediaImage(ctx, catalog, mediaName, "Packer CD upload", cdPath)
+		if errors.Is(err, driver.ErrMediaNameTaken) {
+			mediaName = fmt.Sprintf("%s-cd-%d.iso", vm.GetName(), time.Now().UnixNano())
+			media, err = d.UploadMediaImage(ctx, catalog, mediaName, "Packer CD upload", cdPath)
+		}
+	}
+	if uploadErr := (*driver.MediaUploadError)(nil); errors.As(err, &uploadErr) {
+		s.partial = uploadErr
+	}
+	if err != nil {
+		state.Put("error", fmt.Errorf("error uploading CD image %s: %w", filepath.Base(cdPath), err))
+		return multistep.ActionHalt
+	}
+	s.partial = nil
+	s.media = media
+
+	media, err = d.WaitForMediaResolved(ctx, catalog, media.Media.ID, s.ResolveTimeout, func(status string) {
+		ui.Message(fmt.Sprintf("Media status: %s", status))
+	})
+	if err != nil {
+		state.Put("error", fmt.Errorf("error waiting for CD image: %w", err))
+		return multistep.ActionHalt
+	}
+	s.media = media
+
+	ui.Sayf("Mounting CD image in drive %s...", deviceID)
+	if err := vm.SetDriveMedia(deviceID, media); err != nil {
+		state.Put("error", fmt.Errorf("error mounting CD image: %w", err))
+		return multistep.ActionHalt
+	}
+	state.Put("cd_media", media)
+
+	ui.Say("CD image mounted successfully")
+	return multistep.ActionContinue
+}
+
+func (s *StepMountCD) Cleanup(state multistep.StateBag) {
+	if s.partial != nil {
+		// A media without its file stays unresolved forever
+		s.media = s.partial.Media
+		s.partial = nil
+	}
+	if s.media == nil {
+		return
+	}
+	ui := state.Get("ui").(packersdk.Ui)
+
+	if _, ok := state.GetOk("cd_media"); ok {
+		vm := state.Get("vm").(driver.VirtualMachine)
+		ui.Sayf("Ejecting CD image: %s", s.media.Media.Name)
+		if err := vm.SetDriveMedia(state.Get("cd_drive_id").(string), nil); err != nil {
+			ui.Errorf("Error ejecting CD image: %s", err)
+			// Media still mounted cannot be deleted
+			return
+		}
+		state.Remove("cd_media")
+	}
+
+	// The temporary catalog is deleted with everything in it
+	if tempCatalog, _ := state.Get("temp_catalog").(bool); tempCatalog {
+		return
+	}
+	ui.Sayf("Deleting CD image: %s", s.media.Media.Name)
+	task, err := s.media.Delete()
+	if err == nil {
+		err = driver.WaitTask(context.Background(), &task)
+	}
+	if err != nil {
+		ui.Errorf("Error deleting CD image: %s", err)
+	}
+}
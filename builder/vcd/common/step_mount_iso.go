@@ -3,23 +3,48 @@ package common
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+	"github.com/vmware/go-vcloud-director/v3/govcd"
 )
 
-type StepMountISO struct{}
+// StepMountISO waits for the uploaded ISO to be resolved in the catalog and
+// inserts it into the VM.
+type StepMountISO struct {
+	// How long to wait for the media to resolve, and then for the VM to
+	// accept it while busy.
+	ResolveTimeout time.Duration
+}
 
-func (s *StepMountISO) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+func (s *StepMountISO) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packersdk.Ui)
+	d := state.Get("driver").(driver.Driver)
 	vm := state.Get("vm").(driver.VirtualMachine)
+	catalog := state.Get("catalog").(*govcd.Catalog)
 	catalogName := state.Get("catalog_name").(string)
+	if name, ok := state.GetOk("uploaded_media_catalog"); ok {
+		// Media found by iso_search in another catalog
+		catalogName = name.(string)
+	}
 	mediaName := state.Get("uploaded_media_name").(string)
+	uploaded := state.Get("uploaded_media").(*govcd.Media)
+
+	ui.Sayf("Waiting for ISO %s to be ready (timeout %v)...", mediaName, s.ResolveTimeout)
+	media, err := d.WaitForMediaResolved(ctx, catalog, uploaded.Media.ID, s.ResolveTimeout, func(status string) {
+		ui.Message(fmt.Sprintf("Media status: %s", status))
+	})
+	if err != nil {
+		state.Put("error", fmt.Errorf("error waiting for ISO: %w", err))
+		return multistep.ActionHalt
+	}
+	state.Put("uploaded_media", media)
 
 	ui.Sayf("Mounting ISO: %s from catalog %s", mediaName, catalogName)
 
-	err := vm.InsertMedia(catalogName, mediaName)
+	err = vm.InsertMedia(ctx, media, s.ResolveTimeout)
 	if err != nil {
 		state.Put("error", fmt.Errorf("error mounting ISO: %w", err))
 		return multistep.ActionHalt
@@ -44,18 +69,13 @@ func (s *StepMountISO) Cleanup(state multistep.StateBag) {
 	}
 	vm := vmRaw.(driver.VirtualMachine)
 
-	catalogName, ok := state.GetOk("catalog_name")
-	if !ok {
-		return
-	}
-
-	mediaName, ok := state.GetOk("uploaded_media_name")
+	media, ok := state.GetOk("uploaded_media")
 	if !ok {
 		return
 	}
 
-	ui.Sayf("Ejecting ISO: %s", mediaName)
-	err := vm.EjectMedia(catalogName.(string), mediaName.(string))
+	ui.Sayf("Ejecting ISO: %s", media.(*govcd.Media).Media.Name)
+	err := vm.EjectMedia(media.(*govcd.Media))
 	if err != nil {
 		ui.Errorf("Error ejecting ISO: %s", err)
 	}
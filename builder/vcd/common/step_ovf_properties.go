@@ -0,0 +1,156 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+)
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type OVFPropertiesConfig
+
+// OVFPropertiesConfig contains configuration for handing parameters to the
+// guest through the OVF environment of the VM, for guests that configure
+// themselves on first boot, like appliances and cloud images.
+type OVFPropertiesConfig struct {
+	// OVF properties set in the product section of the VM before it is
+	// powered on. VCD hands them to the guest in its OVF environment, which
+	// the guest reads through VMware Tools, e.g. with
+	// `vmtoolsd --cmd "info-get guestinfo.ovfEnv"`, or through the OVF
+	// datasource of cloud-init, which reads the `instance-id`, `hostname`,
+	// `public-keys` and `user-data` (base64 encoded) properties. Properties
+	// of the source template with the same key are overwritten. Values are
+	// interpolated with the build variables, such as `{{ .Name }}` and
+	// `{{ .VMIP }}`.
+	//
+	// ```hcl
+	//   ovf_properties = {
+	//     "instance-id" = "{{ .Name }}"
+	//     "hostname"    = "{{ .Name }}"
+	//     "user-data"   = base64encode(file("user-data.yml"))
+	//   }
+	// ```
+	//
+	// The properties of the source are restored before the VM is exported,
+	// unless `ovf_properties_keep` is set, so that the VMs deployed from the
+	// template do not read the build configuration.
+	OVFProperties map[string]string `mapstructure:"ovf_properties"`
+	// Keep the `ovf_properties` on the VM, and in the template exported
+	// from it, with their build values, e.g. for appliances configured on
+	// deployment. Defaults to false.
+	OVFPropertiesKeep bool `mapstructure:"ovf_properties_keep"`
+}
+
+func (c *OVFPropertiesConfig) Prepare() []error {
+	var errs []error
+
+	for key := range c.OVFProperties {
+		if strings.TrimSpace(key) == "" {
+			errs = append(errs, fmt.Errorf("'ovf_properties' keys must not be empty"))
+			break
+		}
+	}
+
+	return errs
+}
+
+// StepOVFProperties sets the OVF properties on the VM. It runs before the
+// VM is powered on, since VCD builds the OVF environment on power on.
+type StepOVFProperties struct {
+	Config *OVFPropertiesConfig
+	VMName string
+	Ctx    interpolate.Context
+}
+
+func (s *StepOVFProperties) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	if len(s.Config.OVFProperties) == 0 {
+		return multistep.ActionContinue
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	vm := state.Get("vm").(driver.VirtualMachine)
+
+	tplCtx := newTemplateContext(s.Ctx, state, s.VMName)
+
+	properties := make(map[string]string, len(s.Config.OVFProperties))
+	keys := make([]string, 0, len(s.Config.OVFProperties))
+	for key, value := range s.Config.OVFProperties {
+		rendered, err := interpolate.Render(value, tplCtx)
+		if err != nil {
+			state.Put("error", fmt.Errorf("error interpolating OVF property %q: %w", key, err))
+			return multistep.ActionHalt
+		}
+		properties[key] = rendered
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Remembered to restore the properties of the source before export
+	source, err := vm.OVFProperties()
+	if err != nil {
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
+	var added []string
+	overwritten := make(map[string]string)
+	for _, key := range keys {
+		if value, ok := source[key]; ok {
+			overwritten[key] = value
+		} else {
+			added = append(added, key)
+		}
+	}
+
+	ui.Sayf("Setting OVF properties: %s", strings.Join(keys, ", "))
+	if err := vm.SetOVFProperties(properties); err != nil {
+		state.Put("error", fmt.Errorf("error setting OVF properties: %w", err))
+		return multistep.ActionHalt
+	}
+	state.Put("ovf_properties_added", added)
+	state.Put("ovf_properties_overwritten", overwritten)
+
+	return multistep.ActionContinue
+}
+
+func (s *StepOVFProperties) Cleanup(state multistep.StateBag) {}
+
+// StepRestoreOVFProperties restores the OVF properties of the source once
+// the VM is shut down, so that the values set for the build do not end up
+// in the exported template, where the VMs deployed from it would read them
+// again.
+type StepRestoreOVFProperties struct {
+	Config *OVFPropertiesConfig
+}
+
+func (s *StepRestoreOVFProperties) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	added, ok := state.GetOk("ovf_properties_added")
+	if !ok || s.Config.OVFPropertiesKeep {
+		return multistep.ActionContinue
+	}
+	overwritten := state.Get("ovf_properties_overwritten").(map[string]string)
+
+	ui := state.Get("ui").(packersdk.Ui)
+	vm := state.Get("vm").(driver.VirtualMachine)
+
+	ui.Say("Restoring OVF properties...")
+	if err := vm.RemoveOVFProperties(added.([]string)); err != nil {
+		state.Put("error", fmt.Errorf("error removing OVF properties: %w", err))
+		return multistep.ActionHalt
+	}
+	if err := vm.SetOVFProperties(overwritten); err != nil {
+		state.Put("error", fmt.Errorf("error restoring OVF properties: %w", err))
+		return multistep.ActionHalt
+	}
+	state.Remove("ovf_properties_added")
+	state.Remove("ovf_properties_overwritten")
+
+	return multistep.ActionContinue
+}
+
+func (s *StepRestoreOVFProperties) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,63 @@
+package common
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepOVFProperties_Restore(t *testing.T) {
+	state, _, vm := newTestState(t)
+	vm.ProductSection = map[string]string{
+		"hostname": "template",
+		"domain":   "example.com",
+	}
+	source := maps.Clone(vm.ProductSection)
+
+	config := &OVFPropertiesConfig{OVFProperties: map[string]string{
+		"hostname":  "{{ .Name }}",
+		"user-data": "I2Nsb3VkLWNvbmZpZw==",
+	}}
+	step := &StepOVFProperties{Config: config, VMName: "build-vm"}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Run() = %v: %v", action, stepError(state))
+	}
+	want := map[string]string{
+		"hostname":  "build-vm",
+		"domain":    "example.com",
+		"user-data": "I2Nsb3VkLWNvbmZpZw==",
+	}
+	if !maps.Equal(vm.ProductSection, want) {
+		t.Errorf("OVF properties during the build = %v, want %v", vm.ProductSection, want)
+	}
+
+	restore := &StepRestoreOVFProperties{Config: config}
+	if action := restore.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Run() = %v: %v", action, stepError(state))
+	}
+	if !maps.Equal(vm.ProductSection, source) {
+		t.Errorf("OVF properties after the build = %v, want %v", vm.ProductSection, source)
+	}
+}
+
+func TestStepOVFProperties_Keep(t *testing.T) {
+	state, _, vm := newTestState(t)
+
+	config := &OVFPropertiesConfig{
+		OVFProperties:     map[string]string{"hostname": "{{ .Name }}"},
+		OVFPropertiesKeep: true,
+	}
+	step := &StepOVFProperties{Config: config, VMName: "build-vm"}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Run() = %v: %v", action, stepError(state))
+	}
+	restore := &StepRestoreOVFProperties{Config: config}
+	if action := restore.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Run() = %v: %v", action, stepError(state))
+	}
+	if got := vm.ProductSection["hostname"]; got != "build-vm" {
+		t.Errorf("hostname after the build = %q, want build-vm", got)
+	}
+}
@@ -0,0 +1,172 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+)
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type PortForwardConfig,PortForwardRule
+
+// PortForwardGeneratedVars are the build variables set by StepPortForward,
+// to be returned by the builders' Prepare.
+var PortForwardGeneratedVars = []string{"PortForwardIP", "PortForwards"}
+
+// PortForwardRule is a port of the VM made reachable from the Packer host
+// for the duration of the build.
+type PortForwardRule struct {
+	// The port of the service inside the VM, e.g. `8006`.
+	GuestPort int `mapstructure:"guest_port" required:"true"`
+	// The port on `port_forward_external_ip` forwarded to `guest_port`.
+	// Defaults to `guest_port`.
+	ExternalPort int `mapstructure:"external_port"`
+	// The protocol, `tcp` or `udp`. Defaults to `tcp`.
+	Protocol string `mapstructure:"protocol"`
+}
+
+// PortForwardConfig declares temporary DNAT rules on an NSX-T edge gateway,
+// so provisioners and tests running on the Packer host can reach services
+// of the VM besides the communicator.
+type PortForwardConfig struct {
+	// Ports of the VM to forward from the edge gateway while the build runs.
+	// The rules are created once the VM has an IP address and deleted at the
+	// end of the build. Provisioners find them in the `PortForwardIP` and
+	// `PortForwards` build variables, the latter formatted as
+	// `guest_port=ip:external_port`, comma-separated. The edge gateway
+	// firewall must allow the external ports.
+	//
+	// HCL Example:
+	//
+	// ```hcl
+	//   port_forward {
+	//     guest_port    = 8006
+	//     external_port = 18006
+	//   }
+	// ```
+	PortForwards []PortForwardRule `mapstructure:"port_forward"`
+	// The NSX-T edge gateway to create the `port_forward` rules on. Required
+	// with `port_forward`.
+	PortForwardEdgeGateway string `mapstructure:"port_forward_edge_gateway"`
+	// The external IP address of the edge gateway the `port_forward` rules
+	// listen on, from its allocated IPs. Required with `port_forward`.
+	PortForwardExternalIP string `mapstructure:"port_forward_external_ip"`
+}
+
+func (c *PortForwardConfig) Prepare() []error {
+	var errs []error
+
+	if len(c.PortForwards) == 0 {
+		return errs
+	}
+	if c.PortForwardEdgeGateway == "" {
+		errs = append(errs, fmt.Errorf("'port_forward_edge_gateway' is required with 'port_forward'"))
+	}
+	if c.PortForwardExternalIP == "" {
+		errs = append(errs, fmt.Errorf("'port_forward_external_ip' is required with 'port_forward'"))
+	}
+
+	seen := make(map[string]bool)
+	for i := range c.PortForwards {
+		pf := &c.PortForwards[i]
+		if pf.Protocol == "" {
+			pf.Protocol = "tcp"
+		}
+		pf.Protocol = strings.ToLower(pf.Protocol)
+		if pf.Protocol != "tcp" && pf.Protocol != "udp" {
+			errs = append(errs, fmt.Errorf("port_forward[%d]: 'protocol' must be tcp or udp", i))
+		}
+		if pf.GuestPort < 1 || pf.GuestPort > 65535 {
+			errs = append(errs, fmt.Errorf("port_forward[%d]: 'guest_port' must be between 1 and 65535", i))
+		}
+		if pf.ExternalPort == 0 {
+			pf.ExternalPort = pf.GuestPort
+		}
+		if pf.ExternalPort < 1 || pf.ExternalPort > 65535 {
+			errs = append(errs, fmt.Errorf("port_forward[%d]: 'external_port' must be between 1 and 65535", i))
+		}
+		key := fmt.Sprintf("%s/%d", pf.Protocol, pf.ExternalPort)
+		if seen[key] {
+			errs = append(errs, fmt.Errorf("port_forward[%d]: external port %s is forwarded twice", i, key))
+		}
+		seen[key] = true
+	}
+
+	return errs
+}
+
+// StepPortForward creates the port_forward DNAT rules to the VM's IP address
+// and removes them during cleanup.
+type StepPortForward struct {
+	Config *PortForwardConfig
+
+	forwards []*driver.PortForward
+}
+
+func (s *StepPortForward) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	generated := &packerbuilderdata.GeneratedData{State: state}
+	generated.Put("PortForwardIP", "")
+	generated.Put("PortForwards", "")
+
+	if len(s.Config.PortForwards) == 0 {
+		return multistep.ActionContinue
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	d := state.Get("driver").(driver.Driver)
+	vdc := state.Get("vdc").(*govcd.Vdc)
+	ip := state.Get("ip").(string)
+
+	ui.Sayf("Forwarding %d port(s) from edge gateway %s...", len(s.Config.PortForwards), s.Config.PortForwardEdgeGateway)
+
+	prefix := fmt.Sprintf("packer-%d", time.Now().UnixNano())
+	var summary []string
+	for _, rule := range s.Config.PortForwards {
+		spec := driver.PortForwardSpec{
+			Name:         fmt.Sprintf("%s-%s-%d", prefix, rule.Protocol, rule.ExternalPort),
+			Protocol:     rule.Protocol,
+			ExternalIP:   s.Config.PortForwardExternalIP,
+			ExternalPort: rule.ExternalPort,
+			InternalIP:   ip,
+			InternalPort: rule.GuestPort,
+		}
+		pf, err := d.CreatePortForward(vdc, s.Config.PortForwardEdgeGateway, spec)
+		if err != nil {
+			state.Put("error", fmt.Errorf("error forwarding %s port %d: %w", rule.Protocol, rule.GuestPort, err))
+			return multistep.ActionHalt
+		}
+		s.forwards = append(s.forwards, pf)
+
+		ui.Sayf("Forwarded %s:%d/%s to %s:%d", spec.ExternalIP, spec.ExternalPort, rule.Protocol, ip, rule.GuestPort)
+		summary = append(summary, fmt.Sprintf("%d=%s:%d", rule.GuestPort, spec.ExternalIP, spec.ExternalPort))
+	}
+
+	generated.Put("PortForwardIP", s.Config.PortForwardExternalIP)
+	generated.Put("PortForwards", strings.Join(summary, ","))
+
+	return multistep.ActionContinue
+}
+
+func (s *StepPortForward) Cleanup(state multistep.StateBag) {
+	if len(s.forwards) == 0 {
+		return
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	d := state.Get("driver").(driver.Driver)
+
+	ui.Say("Removing port forwards...")
+	for _, pf := range s.forwards {
+		if err := d.DeletePortForward(pf); err != nil {
+			ui.Errorf("Error removing port forward %s, delete it manually: %s", pf.Spec.Name, err)
+		}
+	}
+	s.forwards = nil
+}
@@ -0,0 +1,139 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	probeDialTimeout = 5 * time.Second
+	probeInterval    = 10 * time.Second
+)
+
+// probeResult classifies why the communicator port did or did not answer.
+type probeResult int
+
+const (
+	probeOpen probeResult = iota
+	probeRefused
+	probeFiltered
+	probeUnreachable
+	probeOther
+)
+
+// StepProbeCommunicator waits for the SSH/WinRM port of the VM to accept TCP
+// connections before handing off to communicator.StepConnect, reporting
+// whether the port is filtered (firewall) or closed (service not started yet).
+// The SDK wait only tells the user that it is still waiting.
+type StepProbeCommunicator struct {
+	Config *communicator.Config
+}
+
+func (s *StepProbeCommunicator) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	var timeout time.Duration
+	switch s.Config.Type {
+	case "ssh":
+		// Through a bastion or proxy the port is not reachable from here
+		if s.Config.SSHBastionHost != "" || s.Config.SSHProxyHost != "" {
+			return multistep.ActionContinue
+		}
+		timeout = s.Config.SSHTimeout
+	case "winrm":
+		timeout = s.Config.WinRMTimeout
+	default:
+		return multistep.ActionContinue
+	}
+
+	host, err := CommHost(s.Config.Host())(state)
+	if err != nil || host == "" {
+		log.Printf("[WARN] No communicator host to probe, skipping")
+		return multistep.ActionContinue
+	}
+
+	addr := net.JoinHostPort(host, strconv.Itoa(s.Config.Port()))
+	ui.Sayf("Probing %s port %s...", strings.ToUpper(s.Config.Type), addr)
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		deadline = time.After(timeout)
+	}
+
+	last := probeResult(-1)
+	var lastErr error
+	for {
+		result, err := probePort(ctx, addr)
+		if result == probeOpen {
+			ui.Sayf("%s port %s is accepting connections", strings.ToUpper(s.Config.Type), addr)
+			return multistep.ActionContinue
+		}
+		lastErr = err
+
+		// Only report changes, to keep the output readable during long
+		// Windows installs
+		if result != last {
+			ui.Message(describeProbe(result, s.Config.Type, addr, err))
+			last = result
+		}
+		log.Printf("[DEBUG] Probe of %s failed: %v", addr, err)
+
+		select {
+		case <-ctx.Done():
+			return multistep.ActionHalt
+		case <-deadline:
+			state.Put("error", fmt.Errorf("timeout waiting for %s port %s: %s",
+				s.Config.Type, addr, describeProbe(last, s.Config.Type, addr, lastErr)))
+			return multistep.ActionHalt
+		case <-time.After(probeInterval):
+		}
+	}
+}
+
+func (s *StepProbeCommunicator) Cleanup(state multistep.StateBag) {
+	// Nothing to clean up
+}
+
+// probePort attempts a single TCP connection to addr.
+func probePort(ctx context.Context, addr string) (probeResult, error) {
+	dialer := net.Dialer{Timeout: probeDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err == nil {
+		conn.Close()
+		return probeOpen, nil
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED) || strings.Contains(err.Error(), "actively refused"):
+		return probeRefused, err
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return probeFiltered, err
+	case errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH):
+		return probeUnreachable, err
+	}
+	return probeOther, err
+}
+
+func describeProbe(result probeResult, commType, addr string, err error) string {
+	switch result {
+	case probeRefused:
+		return fmt.Sprintf("%s is reachable but refuses connections: the %s service is not started yet", addr, commType)
+	case probeFiltered:
+		return fmt.Sprintf("%s does not answer: the port is filtered, most likely by the guest firewall, or the guest is still booting", addr)
+	case probeUnreachable:
+		return fmt.Sprintf("%s is unreachable: check the network and routing between Packer and the VM", addr)
+	}
+	return fmt.Sprintf("%s is not accepting connections: %v", addr, err)
+}
@@ -0,0 +1,134 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+)
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type ProvisionSnapshotConfig
+
+// provisionSnapshotName is the name of the snapshot taken before provisioning.
+const provisionSnapshotName = "packer-before-provisioning"
+
+// ProvisionSnapshotConfig contains configuration for snapshotting the VM
+// before provisioning.
+type ProvisionSnapshotConfig struct {
+	// Take a snapshot of the VM, with its memory, once the communicator is
+	// connected and before the provisioners run, so that a failed
+	// provisioner does not cost the OS install. The snapshot is removed when
+	// provisioning succeeds. When it fails, the snapshot is left on the VM,
+	// which is kept with `-on-error=abort` to revert to by hand. VCD keeps a
+	// single snapshot per VM, so this replaces a snapshot of a cloned VM.
+	// Defaults to false.
+	SnapshotBeforeProvision bool `mapstructure:"snapshot_before_provision"`
+	// The number of times to revert the VM to the snapshot and run the
+	// provisioners again when they fail, e.g. on a flaky package mirror.
+	// Requires `snapshot_before_provision`. Defaults to 0.
+	ProvisionRetries int `mapstructure:"provision_retries"`
+}
+
+func (c *ProvisionSnapshotConfig) Prepare() []error {
+	var errs []error
+
+	if c.ProvisionRetries < 0 {
+		errs = append(errs, fmt.Errorf("'provision_retries' must not be negative"))
+	}
+	if c.ProvisionRetries > 0 && !c.SnapshotBeforeProvision {
+		errs = append(errs, fmt.Errorf("'provision_retries' requires 'snapshot_before_provision'"))
+	}
+
+	return errs
+}
+
+// StepProvision runs the provisioners like the step of the SDK, after
+// taking a snapshot of the VM when snapshot_before_provision is set. Failed
+// provisioning is retried from the snapshot provision_retries times.
+type StepProvision struct {
+	Config *ProvisionSnapshotConfig
+	// Comm is used to connect again after reverting to the snapshot, as
+	// the connections open when it was taken do not survive the revert.
+	Comm *communicator.Config
+
+	provision  commonsteps.StepProvision
+	reconnects []*communicator.StepConnect
+}
+
+func (s *StepProvision) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if !s.Config.SnapshotBeforeProvision {
+		return s.provision.Run(ctx, state)
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	vm := state.Get("vm").(driver.VirtualMachine)
+
+	ui.Say("Creating a snapshot of the VM before provisioning...")
+	if err := vm.CreateSnapshot(ctx, provisionSnapshotName, true); err != nil {
+		state.Put("error", fmt.Errorf("error creating snapshot before provisioning: %w", err))
+		return multistep.ActionHalt
+	}
+
+	for attempt := 1; ; attempt++ {
+		if action := s.provision.Run(ctx, state); action == multistep.ActionContinue {
+			break
+		}
+		if _, cancelled := state.GetOk(multistep.StateCancelled); cancelled || ctx.Err() != nil || attempt > s.Config.ProvisionRetries {
+			ui.Sayf("Snapshot %s is left on the VM, to revert to the state before provisioning", provisionSnapshotName)
+			return multistep.ActionHalt
+		}
+
+		ui.Errorf("Provisioning failed (attempt %d of %d): %s", attempt, s.Config.ProvisionRetries+1, state.Get("error"))
+		state.Remove("error")
+
+		ui.Say("Reverting the VM to the snapshot taken before provisioning...")
+		if err := vm.RevertToCurrentSnapshot(ctx); err != nil {
+			state.Put("error", fmt.Errorf("error reverting to snapshot %s: %w", provisionSnapshotName, err))
+			return multistep.ActionHalt
+		}
+		// Reverting resumes the guest from the memory in the snapshot; power
+		// the VM on should it be off anyway
+		if on, err := vm.IsPoweredOn(); err == nil && !on {
+			ui.Say("Powering on VM...")
+			if err := vm.PowerOn(ctx); err != nil {
+				state.Put("error", fmt.Errorf("error powering on VM after revert: %w", err))
+				return multistep.ActionHalt
+			}
+		}
+
+		// Replaces the communicator in the state with a new connection
+		ui.Say("Connecting to the VM again...")
+		connect := &communicator.StepConnect{
+			Config:    s.Comm,
+			Host:      CommHost(s.Comm.Host()),
+			SSHConfig: s.Comm.SSHConfigFunc(),
+		}
+		s.reconnects = append(s.reconnects, connect)
+		if action := connect.Run(ctx, state); action != multistep.ActionContinue {
+			return action
+		}
+	}
+
+	// Snapshots would be captured into the template
+	ui.Say("Removing the snapshot taken before provisioning...")
+	if err := vm.RemoveAllSnapshots(ctx); err != nil {
+		state.Put("error", fmt.Errorf("error removing snapshot %s: %w", provisionSnapshotName, err))
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepProvision) Cleanup(state multistep.StateBag) {
+	// Runs the error-cleanup-provisioner after a failure
+	s.provision.Cleanup(state)
+
+	for i := len(s.reconnects) - 1; i >= 0; i-- {
+		s.reconnects[i].Cleanup(state)
+	}
+}
@@ -0,0 +1,68 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepProvision_RetryFromSnapshot(t *testing.T) {
+	state, d, vm := newTestState(t)
+	vm.SetStatus("POWERED_ON")
+	runs := 0
+	state.Put("hook", &packersdk.MockHook{RunFunc: func(context.Context) error {
+		runs++
+		if runs == 1 {
+			return errors.New("package mirror unreachable")
+		}
+		return nil
+	}})
+
+	step := &StepProvision{
+		Config: &ProvisionSnapshotConfig{SnapshotBeforeProvision: true, ProvisionRetries: 1},
+		Comm:   &communicator.Config{Type: "none"},
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Run() = %v: %v", action, stepError(state))
+	}
+	step.Cleanup(state)
+
+	if runs != 2 {
+		t.Errorf("provisioned %d times, want 2", runs)
+	}
+	if n := d.Tasks.Count("RevertToCurrentSnapshot"); n != 1 {
+		t.Errorf("reverted %d times, want 1", n)
+	}
+	if vm.Snapshot != "" {
+		t.Errorf("snapshot %s left on the VM", vm.Snapshot)
+	}
+	if _, ok := state.GetOk("communicator"); !ok {
+		t.Error("no communicator in the state after reconnecting")
+	}
+}
+
+func TestStepProvision_KeepsSnapshotOnFailure(t *testing.T) {
+	state, d, vm := newTestState(t)
+	vm.SetStatus("POWERED_ON")
+	state.Put("hook", &packersdk.MockHook{RunFunc: func(context.Context) error {
+		return errors.New("script exited with 1")
+	}})
+
+	step := &StepProvision{
+		Config: &ProvisionSnapshotConfig{SnapshotBeforeProvision: true},
+		Comm:   &communicator.Config{Type: "none"},
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("Run() = %v, want ActionHalt", action)
+	}
+	if vm.Snapshot != provisionSnapshotName {
+		t.Errorf("snapshot = %q, want %q", vm.Snapshot, provisionSnapshotName)
+	}
+	if n := d.Tasks.Count("RevertToCurrentSnapshot"); n != 0 {
+		t.Errorf("reverted %d times without provision_retries", n)
+	}
+}
@@ -55,11 +55,12 @@ func (s *StepQueryVMIP) Run(ctx context.Context, state multistep.StateBag) multi
 
 	// Get network info for gateway/netmask/DNS
 	dRaw := state.Get("driver")
-	if dRaw != nil && s.VDCName != "" && s.NetworkName != "" {
+	networkName := NetworkName(state, s.NetworkName)
+	if dRaw != nil && s.VDCName != "" && networkName != "" {
 		d := dRaw.(driver.Driver)
 		vdc, err := d.GetVdc(s.VDCName)
 		if err == nil {
-			networkInfo, err := d.GetNetworkInfo(vdc, s.NetworkName)
+			networkInfo, err := d.GetNetworkInfo(vdc, networkName)
 			if err == nil {
 				gateway := networkInfo.Gateway
 				if s.OverrideGateway != "" {
@@ -2,15 +2,24 @@ package common
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
 )
 
+//go:generate packer-sdc struct-markdown
+
 type RemoveNetworkAdapterConfig struct {
 	// Remove all network adapters from the virtual machine image. Defaults to `false`.
 	RemoveNetworkAdapter bool `mapstructure:"remove_network_adapter"`
 }
 
+// StepRemoveNetworkAdapter removes the network adapters of the VM once it
+// is shut down, so that the template captured from it has none: VMs
+// deployed from it get the adapters of their deployment, rather than one
+// connected to the build network.
 type StepRemoveNetworkAdapter struct {
 	Config *RemoveNetworkAdapterConfig
 }
@@ -20,8 +29,16 @@ func (s *StepRemoveNetworkAdapter) Run(_ context.Context, state multistep.StateB
 		return multistep.ActionContinue
 	}
 
-	// TODO(juan): Implement this
-	// https://github.com/hashicorp/packer-plugin-vsphere/blob/main/builder/vsphere/common/step_remove_network_adapter.go#L18
+	ui := state.Get("ui").(packersdk.Ui)
+	vm := state.Get("vm").(driver.VirtualMachine)
+
+	ui.Say("Removing network adapters...")
+	if err := vm.RemoveNetworkAdapters(); err != nil {
+		state.Put("error", fmt.Errorf("error removing network adapters: %w", err))
+		return multistep.ActionHalt
+	}
 
 	return multistep.ActionContinue
 }
+
+func (s *StepRemoveNetworkAdapter) Cleanup(state multistep.StateBag) {}
@@ -2,7 +2,10 @@ package common
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -12,13 +15,23 @@ import (
 )
 
 type StepResolveVApp struct {
-	VDCName     string
-	VAppName    string
-	NetworkName string
-	CreateVApp  bool
+	VDCName  string
+	VAppName string
+	// Candidate networks in fallback order. The first one that has a free
+	// IP (when CheckPool is set) and can be added to the vApp is used, and
+	// stored in state as "network_name" for the steps that follow.
+	Networks []string
+	// Shuffle Networks before trying them
+	RandomNetwork bool
+	// Skip networks whose IP pool is exhausted (POOL allocation)
+	CheckPool  bool
+	CreateVApp bool
+	// Delete the vApp created by this step once the build has finished
+	// successfully, not only on failure. Set when the vApp is throwaway.
+	DeleteOnSuccess bool
 }
 
-func (s *StepResolveVApp) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+func (s *StepResolveVApp) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packersdk.Ui)
 	d := state.Get("driver").(driver.Driver)
 
@@ -39,12 +52,25 @@ func (s *StepResolveVApp) Run(_ context.Context, state multistep.StateBag) multi
 		state.Put("vdc", vdc)
 	}
 
+	candidates := append([]string(nil), s.Networks...)
+	if s.RandomNetwork {
+		rand.Shuffle(len(candidates), func(i, j int) {
+			candidates[i], candidates[j] = candidates[j], candidates[i]
+		})
+	}
+
 	// Try to get existing vApp
 	if s.VAppName != "" {
 		ui.Sayf("Looking for vApp: %s", s.VAppName)
 		vapp, err := vdc.GetVAppByName(s.VAppName, true)
 		if err == nil && vapp != nil {
 			ui.Sayf("Found existing vApp: %s", s.VAppName)
+			networkName, err := s.selectNetwork(ui, d, vdc, candidates, nil)
+			if err != nil {
+				state.Put("error", err)
+				return multistep.ActionHalt
+			}
+			state.Put("network_name", networkName)
 			state.Put("vapp", vapp)
 			state.Put("vapp_name", s.VAppName)
 			state.Put("vapp_created", false)
@@ -57,19 +83,27 @@ func (s *StepResolveVApp) Run(_ context.Context, state multistep.StateBag) multi
 		}
 	}
 
-	// Create a new vApp
-	vappName := s.VAppName
-	if vappName == "" {
-		vappName = fmt.Sprintf("packer-%d", time.Now().UnixNano())
+	// Create a new vApp, falling back to the next network if composing it
+	// on the current one fails
+	var vapp *govcd.VApp
+	var vappName, networkName string
+	compose := func(network string) error {
+		vappName = s.VAppName
+		if vappName == "" {
+			vappName = fmt.Sprintf("packer-%d", time.Now().UnixNano())
+		}
+		ui.Sayf("Creating vApp: %s", vappName)
+		var err error
+		vapp, err = d.CreateVApp(ctx, vdc, vappName, "Packer build vApp", network)
+		return err
 	}
-
-	ui.Sayf("Creating vApp: %s", vappName)
-	vapp, err := d.CreateVApp(vdc, vappName, "Packer build vApp", s.NetworkName)
+	networkName, err := s.selectNetwork(ui, d, vdc, candidates, compose)
 	if err != nil {
 		state.Put("error", fmt.Errorf("error creating vApp: %w", err))
 		return multistep.ActionHalt
 	}
 
+	state.Put("network_name", networkName)
 	state.Put("vapp", vapp)
 	state.Put("vapp_name", vappName)
 	state.Put("vapp_created", true)
@@ -78,6 +112,59 @@ func (s *StepResolveVApp) Run(_ context.Context, state multistep.StateBag) multi
 	return multistep.ActionContinue
 }
 
+// selectNetwork returns the first candidate network that has a free IP, when
+// CheckPool is set, and for which use succeeds. The last candidate is not
+// checked ahead, so a single network behaves as before. With no candidates,
+// use is called once without a network.
+func (s *StepResolveVApp) selectNetwork(ui packersdk.Ui, d driver.Driver, vdc *govcd.Vdc, candidates []string, use func(network string) error) (string, error) {
+	if len(candidates) == 0 {
+		if use == nil {
+			return "", nil
+		}
+		return "", use("")
+	}
+
+	var errs []error
+	for i, network := range candidates {
+		last := i == len(candidates)-1
+		// The last candidate is tried regardless, as with a single network
+		if s.CheckPool && !last {
+			if _, err := d.FindAvailableIP(vdc, network); err != nil {
+				errs = append(errs, err)
+				ui.Errorf("Skipping network %s: %s", network, err)
+				continue
+			}
+		}
+		if use != nil {
+			if err := use(network); err != nil {
+				errs = append(errs, fmt.Errorf("network %s: %w", network, err))
+				if !last {
+					ui.Errorf("Could not use network %s, trying the next one: %s", network, err)
+				}
+				continue
+			}
+		}
+		if len(candidates) > 1 {
+			ui.Sayf("Using network: %s", network)
+		}
+		return network, nil
+	}
+
+	if len(errs) == 1 {
+		return "", errs[0]
+	}
+	return "", fmt.Errorf("no usable network among %s: %w", strings.Join(candidates, ", "), errors.Join(errs...))
+}
+
+// NetworkName returns the network chosen by StepResolveVApp, or configured
+// when the build did not resolve a vApp.
+func NetworkName(state multistep.StateBag, configured string) string {
+	if name, ok := state.GetOk("network_name"); ok {
+		return name.(string)
+	}
+	return configured
+}
+
 func (s *StepResolveVApp) Cleanup(state multistep.StateBag) {
 	ui := state.Get("ui").(packersdk.Ui)
 
@@ -86,10 +173,10 @@ func (s *StepResolveVApp) Cleanup(state multistep.StateBag) {
 		return
 	}
 
-	// Only clean up on failure
+	// Only clean up on failure, unless the vApp is throwaway
 	_, cancelled := state.GetOk(multistep.StateCancelled)
 	_, halted := state.GetOk(multistep.StateHalted)
-	if !cancelled && !halted {
+	if !cancelled && !halted && !s.DeleteOnSuccess {
 		return
 	}
 
@@ -99,50 +186,21 @@ func (s *StepResolveVApp) Cleanup(state multistep.StateBag) {
 	}
 
 	vappName, _ := state.GetOk("vapp_name")
-	vappObj := vapp.(*govcd.VApp)
-
-	// Refresh vApp to get current state
-	if err := vappObj.Refresh(); err != nil {
-		ui.Errorf("Error refreshing vApp state: %s", err)
-		return
-	}
-
-	// Power off vApp before deleting (required by VCD)
-	status, err := vappObj.GetStatus()
-	if err != nil {
-		ui.Errorf("Error getting vApp status: %s", err)
-	} else if status != "POWERED_OFF" && status != "RESOLVED" {
-		ui.Sayf("Powering off vApp: %s", vappName)
-		task, err := vappObj.PowerOff()
-		if err != nil {
-			ui.Errorf("Error powering off vApp: %s", err)
-		} else {
-			_ = task.WaitTaskCompletion()
-		}
-	}
+	d := state.Get("driver").(driver.Driver)
 
-	// Undeploy vApp before deleting (required if any VMs exist)
-	if err := vappObj.Refresh(); err == nil {
-		status, _ := vappObj.GetStatus()
-		if status != "RESOLVED" {
-			ui.Sayf("Undeploying vApp: %s", vappName)
-			task, err := vappObj.Undeploy()
-			if err != nil {
-				ui.Errorf("Error undeploying vApp: %s", err)
-			} else {
-				_ = task.WaitTaskCompletion()
-			}
-		}
+	// Re-fetch by ID: a failed Refresh earlier in the build may have left
+	// the object without its HREF
+	vappObj := vapp.(*govcd.VApp)
+	if fresh, err := d.GetVAppById(vappObj.VApp.ID); err == nil {
+		vappObj = fresh
 	}
 
 	ui.Sayf("Deleting vApp: %s (waiting for completion)...", vappName)
-	task, err := vappObj.Delete()
+	err := d.ForceDeleteVApp(context.Background(), vappObj, func(step string) {
+		ui.Sayf("vApp %s: %s", vappName, step)
+	})
 	if err != nil {
 		ui.Errorf("Error deleting vApp: %s", err)
-		return
-	}
-	if err := task.WaitTaskCompletion(); err != nil {
-		ui.Errorf("Error waiting for vApp deletion: %s", err)
 	} else {
 		ui.Say("vApp deleted successfully")
 	}
@@ -12,19 +12,87 @@ import (
 )
 
 type RunConfig struct {
-	// The priority of boot devices. Defaults to `disk,cdrom`.
+	// The priority of boot devices, e.g. `cdrom,disk`.
 	//
 	// The available boot devices are: `floppy`, `cdrom`, `ethernet`, and
 	// `disk`.
 	//
-	// -> **Note:** If not set, the boot order is temporarily set to
-	// `disk,cdrom` for the duration of the build and then cleared upon
-	// build completion.
+	// The order is set in the `bios.bootOrder` extra config key before the
+	// VM is powered on, and the original order is restored before the VM is
+	// captured, so the template keeps the boot order of its source. Only
+	// BIOS firmware reads it; EFI firmware keeps its own boot order.
+	//
+	// -> **Note:** If not set, the `vcd-iso` builder boots from `disk,cdrom`
+	// during the build, so that the VM boots the installer while the disk is
+	// empty and the installed system once it is bootable. The other
+	// builders leave the boot order of the VM unchanged.
 	BootOrder string `mapstructure:"boot_order"`
+	// How the VM is powered on: `vm` powers on the VM alone, `vapp` deploys
+	// the vApp, powering on all of its VMs. Some VCD versions only run guest
+	// customization on one of the two. Defaults to `vm`.
+	PowerOnMode string `mapstructure:"power_on_mode"`
+	// Deploy with the force customization flag, so that guest customization
+	// runs on power on even if it already ran, e.g. in the source template.
+	// Without it, customization only runs the first time the VM is powered
+	// on, and only when it is enabled on the VM. Defaults to false.
+	PowerOnForceCustomization bool `mapstructure:"power_on_force_customization"`
+}
+
+// bootOrderKey is the extra config key holding the boot order of BIOS VMs.
+const bootOrderKey = "bios.bootOrder"
+
+// defaultBootOrder is the boot order set for the build when boot_order is
+// not set and StepRun.SetOrder is.
+const defaultBootOrder = "disk,cdrom"
+
+// bootDevices maps the boot_order devices to their names in bootOrderKey.
+var bootDevices = map[string]string{
+	"floppy":   "floppy",
+	"cdrom":    "cdrom",
+	"ethernet": "ethernet",
+	"disk":     "hdd",
+}
+
+// vmxBootOrder returns the bootOrderKey value for a boot_order.
+func vmxBootOrder(order string) string {
+	devices := strings.Split(order, ",")
+	for i, device := range devices {
+		devices[i] = bootDevices[strings.TrimSpace(device)]
+	}
+	return strings.Join(devices, ",")
+}
+
+func (c *RunConfig) Prepare() []error {
+	var errs []error
+
+	if c.BootOrder != "" {
+		seen := make(map[string]bool)
+		for _, device := range strings.Split(c.BootOrder, ",") {
+			device = strings.TrimSpace(device)
+			if _, ok := bootDevices[device]; !ok {
+				errs = append(errs, fmt.Errorf("'boot_order' devices must be 'floppy', 'cdrom', 'ethernet' or 'disk', got %q", device))
+			} else if seen[device] {
+				errs = append(errs, fmt.Errorf("'boot_order' lists %q more than once", device))
+			}
+			seen[device] = true
+		}
+	}
+
+	switch c.PowerOnMode {
+	case "":
+		c.PowerOnMode = "vm"
+	case "vm", "vapp":
+	default:
+		errs = append(errs, fmt.Errorf("'power_on_mode' must be 'vm' or 'vapp', got %q", c.PowerOnMode))
+	}
+
+	return errs
 }
 
 type StepRun struct {
-	Config      *RunConfig
+	Config *RunConfig
+	// SetOrder sets the boot order to defaultBootOrder for the build when
+	// boot_order is not set.
 	SetOrder    bool
 	VDCName     string
 	NetworkName string
@@ -33,7 +101,7 @@ type StepRun struct {
 
 const defaultMaxIPRetries = 5
 
-func (s *StepRun) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+func (s *StepRun) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packersdk.Ui)
 	vm := state.Get("vm").(driver.VirtualMachine)
 
@@ -42,6 +110,11 @@ func (s *StepRun) Run(_ context.Context, state multistep.StateBag) multistep.Ste
 		maxRetries = defaultMaxIPRetries
 	}
 
+	if err := s.setBootOrder(ui, state, vm); err != nil {
+		state.Put("error", fmt.Errorf("error setting boot order: %w", err))
+		return multistep.ActionHalt
+	}
+
 	// Track IPs that have failed due to conflicts
 	var failedIPs []string
 
@@ -52,7 +125,7 @@ func (s *StepRun) Run(_ context.Context, state multistep.StateBag) multistep.Ste
 			ui.Sayf("Retrying power on (attempt %d/%d)...", attempt+1, maxRetries+1)
 		}
 
-		err := vm.PowerOn()
+		err := s.powerOn(ctx, vm)
 		if err == nil {
 			ui.Say("Virtual machine powered on.")
 			return multistep.ActionContinue
@@ -79,7 +152,8 @@ func (s *StepRun) Run(_ context.Context, state multistep.StateBag) multistep.Ste
 		// Check if we can retry (need driver, VDC, and network info)
 		dRaw := state.Get("driver")
 		vdcRaw := state.Get("vdc")
-		if dRaw == nil || vdcRaw == nil || s.NetworkName == "" {
+		networkName := NetworkName(state, s.NetworkName)
+		if dRaw == nil || vdcRaw == nil || networkName == "" {
 			// Can't retry without driver/VDC/network info
 			err = fmt.Errorf("IP address %s is already in use. "+
 				"Use 'ip_allocation_mode = \"POOL\"' to let VCD assign an available IP, "+
@@ -104,7 +178,7 @@ func (s *StepRun) Run(_ context.Context, state multistep.StateBag) multistep.Ste
 
 		ui.Sayf("IP address %s is in use, trying to find another available IP...", currentIP)
 
-		networkInfo, err := d.FindAvailableIPExcluding(vdc, s.NetworkName, failedIPs)
+		networkInfo, err := d.FindAvailableIPExcluding(vdc, networkName, failedIPs)
 		if err != nil {
 			state.Put("error", fmt.Errorf("failed to find alternative IP: %w", err))
 			ui.Error(err.Error())
@@ -130,6 +204,67 @@ func (s *StepRun) Run(_ context.Context, state multistep.StateBag) multistep.Ste
 	return multistep.ActionHalt
 }
 
+// setBootOrder sets boot_order on the VM, or the default boot order for the
+// build with SetOrder. The original boot order is kept in the state, for
+// restoreBootOrder.
+func (s *StepRun) setBootOrder(ui packersdk.Ui, state multistep.StateBag, vm driver.VirtualMachine) error {
+	order := s.Config.BootOrder
+	if order == "" {
+		if !s.SetOrder {
+			return nil
+		}
+		order = defaultBootOrder
+	}
+
+	extraConfig, err := vm.GetExtraConfig()
+	if err != nil {
+		return err
+	}
+	original, ok := extraConfig[bootOrderKey]
+
+	ui.Sayf("Setting boot order: %s", order)
+	if err := vm.ChangeExtraConfig(map[string]string{bootOrderKey: vmxBootOrder(order)}); err != nil {
+		return err
+	}
+	state.Put("boot_order_set", true)
+	if ok {
+		state.Put("boot_order_original", original)
+	}
+	return nil
+}
+
+// restoreBootOrder restores the boot order the VM had before StepRun set
+// it, if it did. The VM must be powered off.
+func restoreBootOrder(ui packersdk.Ui, state multistep.StateBag) error {
+	if _, ok := state.GetOk("boot_order_set"); !ok {
+		return nil
+	}
+	vm := state.Get("vm").(driver.VirtualMachine)
+	ui.Say("Restoring the original boot order...")
+	if original, ok := state.GetOk("boot_order_original"); ok {
+		if err := vm.ChangeExtraConfig(map[string]string{bootOrderKey: original.(string)}); err != nil {
+			return err
+		}
+	} else if err := vm.RemoveExtraConfig([]string{bootOrderKey}); err != nil {
+		return err
+	}
+	state.Remove("boot_order_set")
+	state.Remove("boot_order_original")
+	return nil
+}
+
+// powerOn powers on the VM as configured by power_on_mode.
+func (s *StepRun) powerOn(ctx context.Context, vm driver.VirtualMachine) error {
+	switch {
+	case s.Config.PowerOnMode == "vapp":
+		return vm.DeployVApp(ctx, s.Config.PowerOnForceCustomization)
+	case s.Config.PowerOnForceCustomization:
+		return vm.Deploy(ctx, true)
+	default:
+		return vm.PowerOn(ctx)
+	}
+}
+
 func (s *StepRun) Cleanup(state multistep.StateBag) {
 	ui := state.Get("ui").(packersdk.Ui)
 
@@ -141,24 +276,33 @@ func (s *StepRun) Cleanup(state multistep.StateBag) {
 
 	_, cancelled := state.GetOk(multistep.StateCancelled)
 	_, halted := state.GetOk(multistep.StateHalted)
-	if !cancelled && !halted {
-		return
+	if cancelled || halted {
+		// Check if VM is already powered off
+		powered, err := vm.IsPoweredOn()
+		if err != nil {
+			ui.Errorf("Error checking VM power state: %s", err)
+			return
+		}
+		if powered {
+			ui.Say("Powering off virtual machine...")
+
+			err = vm.PowerOff(context.Background())
+			if err != nil {
+				ui.Errorf("Error powering off VM: %s", err)
+				return
+			}
+		}
 	}
 
-	// Check if VM is already powered off
-	powered, err := vm.IsPoweredOn()
-	if err != nil {
-		ui.Errorf("Error checking VM power state: %s", err)
+	// Normally restored before the capture already. The extra config of a
+	// running VM cannot be changed.
+	if _, ok := state.GetOk("boot_order_set"); !ok {
 		return
 	}
-	if !powered {
+	if on, err := vm.IsPoweredOn(); err != nil || on {
 		return
 	}
-
-	ui.Say("Powering off virtual machine...")
-
-	err = vm.PowerOff()
-	if err != nil {
-		ui.Errorf("Error powering off VM: %s", err)
+	if err := restoreBootOrder(ui, state); err != nil {
+		ui.Errorf("Error restoring boot order: %s", err)
 	}
 }
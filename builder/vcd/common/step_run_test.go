@@ -0,0 +1,116 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+)
+
+func TestVMXBootOrder(t *testing.T) {
+	tests := []struct {
+		order string
+		want  string
+	}{
+		{"disk,cdrom", "hdd,cdrom"},
+		{"cdrom, disk", "cdrom,hdd"},
+		{"ethernet,floppy,disk,cdrom", "ethernet,floppy,hdd,cdrom"},
+	}
+	for _, tt := range tests {
+		if got := vmxBootOrder(tt.order); got != tt.want {
+			t.Errorf("vmxBootOrder(%q) = %q, want %q", tt.order, got, tt.want)
+		}
+	}
+}
+
+func TestRunConfigPrepare_BootOrder(t *testing.T) {
+	tests := []struct {
+		order   string
+		wantErr bool
+	}{
+		{"", false},
+		{"disk,cdrom", false},
+		{"cdrom, disk", false},
+		{"usb", true},
+		{"disk,disk", true},
+	}
+	for _, tt := range tests {
+		c := &RunConfig{BootOrder: tt.order}
+		if errs := c.Prepare(); (len(errs) > 0) != tt.wantErr {
+			t.Errorf("boot_order %q: got errors %v, want error: %t", tt.order, errs, tt.wantErr)
+		}
+	}
+}
+
+func TestStepRun_BootOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		bootOrder string
+		setOrder  bool
+		original  string // "" when the VM has no boot order
+		want      string
+	}{
+		{"boot_order", "cdrom,disk", false, "hdd", "cdrom,hdd"},
+		{"default", "", true, "", "hdd,cdrom"},
+		{"unset", "", false, "hdd", "hdd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, _, vm := newTestState(t)
+			if tt.original != "" {
+				vm.ExtraConfig[bootOrderKey] = tt.original
+			}
+
+			step := &StepRun{Config: &RunConfig{BootOrder: tt.bootOrder, PowerOnMode: "vm"}, SetOrder: tt.setOrder}
+			if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+				t.Fatalf("Run() = %v: %v", action, stepError(state))
+			}
+			if got := vm.ExtraConfig[bootOrderKey]; got != tt.want {
+				t.Errorf("boot order during the build = %q, want %q", got, tt.want)
+			}
+			if vm.Status != "POWERED_ON" {
+				t.Errorf("VM status = %s, want POWERED_ON", vm.Status)
+			}
+
+			// Restored once the VM is shut down
+			vm.SetStatus("POWERED_OFF")
+			step.Cleanup(state)
+			got, ok := vm.ExtraConfig[bootOrderKey]
+			if tt.original == "" && ok {
+				t.Errorf("boot order after the build = %q, want none", got)
+			}
+			if tt.original != "" && got != tt.original {
+				t.Errorf("boot order after the build = %q, want %q", got, tt.original)
+			}
+		})
+	}
+}
+
+func TestStepRun_IPConflict(t *testing.T) {
+	state, d, vm := newTestState(t)
+	err := d.AddNetwork("vdc", "net", driver.NetworkInfo{
+		Gateway:  "10.0.0.1",
+		IPRanges: []driver.IPRange{{Start: "10.0.0.10", End: "10.0.0.20"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.Put("vm_ip", "10.0.0.10")
+	d.Tasks.Fail("PowerOn", errors.New("the following IP/MAC addresses have already been used: 10.0.0.10"))
+
+	step := &StepRun{Config: &RunConfig{PowerOnMode: "vm"}, NetworkName: "net"}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Run() = %v: %v", action, stepError(state))
+	}
+	if got := state.Get("vm_ip"); got != "10.0.0.11" {
+		t.Errorf("vm_ip = %v, want 10.0.0.11", got)
+	}
+	if vm.IP != "10.0.0.11" {
+		t.Errorf("VM IP = %s, want 10.0.0.11", vm.IP)
+	}
+	if n := d.Tasks.Count("PowerOn"); n != 2 {
+		t.Errorf("powered on %d times, want 2", n)
+	}
+}
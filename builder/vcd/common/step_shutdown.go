@@ -84,7 +84,7 @@ func (s *StepShutdown) Run(ctx context.Context, state multistep.StateBag) multis
 	} else {
 		// No shutdown command specified - try VMware Tools graceful shutdown
 		ui.Sayf("Shutting down virtual machine via VMware Tools (timeout: %s)...", s.Config.Timeout)
-		err := vm.Shutdown()
+		err := vm.Shutdown(ctx)
 		if err != nil {
 			state.Put("error", fmt.Errorf("error shutting down virtual machine: %v", err))
 			return multistep.ActionHalt
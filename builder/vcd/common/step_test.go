@@ -0,0 +1,35 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/internal/driverfake"
+)
+
+// newTestState returns the state a step finds once the VM exists, with a
+// fake driver and a fake VM in a vApp of the VDC "vdc".
+func newTestState(t *testing.T) (multistep.StateBag, *driverfake.Driver, *driverfake.VM) {
+	t.Helper()
+
+	d := driverfake.NewDriver("org")
+	vdc := d.AddVdc("vdc")
+	vm, err := d.AddVM("vdc", "vapp", "vm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("driver", d)
+	state.Put("vdc", vdc)
+	state.Put("vm", vm)
+	return state, d, vm
+}
+
+// stepError returns the error a step put in the state, if any.
+func stepError(state multistep.StateBag) error {
+	err, _ := state.Get("error").(error)
+	return err
+}
@@ -15,17 +15,24 @@ type StepConfigureTPM struct {
 	Enabled bool
 }
 
-func (s *StepConfigureTPM) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+func (s *StepConfigureTPM) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	if !s.Enabled {
 		return multistep.ActionContinue
 	}
 
 	ui := state.Get("ui").(packersdk.Ui)
+	d := state.Get("driver").(driver.Driver)
 	vm := state.Get("vm").(driver.VirtualMachine)
 
+	if !d.SupportsAPIVersion(driver.APIVersionTPM) {
+		state.Put("error", fmt.Errorf("virtual TPM requires VCD 10.4.2 or later (API %s), this VCD only supports API %s",
+			driver.APIVersionTPM, d.APIVersion()))
+		return multistep.ActionHalt
+	}
+
 	ui.Say("Enabling virtual TPM...")
 
-	if err := vm.SetTPM(true); err != nil {
+	if err := vm.SetTPM(ctx, true); err != nil {
 		state.Put("error", fmt.Errorf("error enabling TPM: %w", err))
 		return multistep.ActionHalt
 	}
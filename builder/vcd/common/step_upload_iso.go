@@ -2,8 +2,14 @@ package common
 
 import (
 	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
@@ -12,18 +18,52 @@ import (
 )
 
 type StepUploadISO struct {
+	// MediaName is the iso_media_name to mount from the catalog instead of
+	// uploading an ISO.
+	MediaName string
 	// CacheISO when true checks if ISO already exists in catalog before uploading.
 	CacheISO bool
 	// CacheOverwrite when true will delete and re-upload existing ISO.
 	CacheOverwrite bool
+	// Search when true looks for media with the same checksum in every
+	// readable catalog before uploading.
+	Search bool
+	// ISOChecksum is the iso_checksum of the source ISO. The checksum of a
+	// modified ISO is taken from the state instead.
+	ISOChecksum string
+	// UploadWaitTimeout is how long to wait for a cached ISO that another
+	// build is still uploading.
+	UploadWaitTimeout time.Duration
+	// DeleteMedia when true deletes the uploaded ISO after a successful build,
+	// unless it is an unmodified ISO cached for later builds.
+	DeleteMedia bool
+
+	// partial is the media a failed upload left in the catalog, resumed
+	// when the step is run again and deleted on cleanup otherwise.
+	partial *driver.MediaUploadError
 }
 
-func (s *StepUploadISO) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+func (s *StepUploadISO) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packersdk.Ui)
 	d := state.Get("driver").(driver.Driver)
 	catalog := state.Get("catalog").(*govcd.Catalog)
 	catalogName := state.Get("catalog_name").(string)
 
+	if s.MediaName != "" {
+		ui.Sayf("Using ISO %s from catalog %s", s.MediaName, catalogName)
+		media, err := catalog.GetMediaByName(s.MediaName, true)
+		if err != nil {
+			state.Put("error", fmt.Errorf("error getting ISO %s from catalog %s: %w", s.MediaName, catalogName, err))
+			return multistep.ActionHalt
+		}
+		if !strings.EqualFold(media.Media.ImageType, "iso") {
+			state.Put("error", fmt.Errorf("media %s in catalog %s is not an ISO (image type %s)",
+				s.MediaName, catalogName, media.Media.ImageType))
+			return multistep.ActionHalt
+		}
+		return s.reuseMedia(ctx, state, media)
+	}
+
 	// Get the local ISO path from the download step
 	isoPathRaw, ok := state.GetOk("iso_path")
 	if !ok {
@@ -51,8 +91,47 @@ func (s *StepUploadISO) Run(_ context.Context, state multistep.StateBag) multist
 	}
 	ui.Sayf("Preparing to upload ISO: %s", mediaName)
 
+	checksum := s.ISOChecksum
+	if isoModified, _ := state.GetOk("iso_modified"); isoModified != nil && isoModified.(bool) {
+		checksum, _ = state.Get("iso_checksum").(string)
+	}
+	checksum = mediaChecksum(checksum)
+
+	// Run again after a failed upload, e.g. retried with -on-error=ask
+	var partial *govcd.Media
+	if s.partial != nil {
+		media, err := d.GetMediaById(catalog, s.partial.Media.Media.ID)
+		if err == nil && media.Media.Status == 0 {
+			partial = media
+		} else {
+			log.Printf("[WARN] Partially uploaded ISO %s is gone, uploading it again", s.partial.Media.Media.Name)
+			s.partial = nil
+		}
+	}
+
+	if partial == nil && s.Search && !s.CacheOverwrite {
+		if checksum == "" {
+			ui.Say("iso_search needs a literal iso_checksum (e.g. sha256:...), uploading the ISO")
+		} else {
+			ui.Sayf("Searching catalogs for an ISO with checksum %s...", checksum)
+			media, mediaCatalog, err := d.FindMediaByChecksum(checksum)
+			if err != nil {
+				ui.Errorf("Warning: ISO search failed, uploading the ISO: %s", err)
+			} else if media != nil {
+				ui.Sayf("Found ISO %s in catalog %s, skipping upload", media.Media.Name, mediaCatalog)
+				state.Put("uploaded_media", media)
+				state.Put("uploaded_media_name", media.Media.Name)
+				state.Put("uploaded_media_catalog", mediaCatalog)
+				state.Put("media_was_uploaded", false) // Not ours to delete
+				return multistep.ActionContinue
+			} else {
+				ui.Say("No matching ISO found")
+			}
+		}
+	}
+
 	// Check if media already exists (cache check)
-	if s.CacheISO {
+	if partial == nil && s.CacheISO {
 		existingMedia, err := catalog.GetMediaByName(mediaName, false)
 		if err == nil && existingMedia != nil {
 			if s.CacheOverwrite {
@@ -62,27 +141,73 @@ func (s *StepUploadISO) Run(_ context.Context, state multistep.StateBag) multist
 					state.Put("error", fmt.Errorf("error deleting existing media: %w", err))
 					return multistep.ActionHalt
 				}
-				if err := task.WaitTaskCompletion(); err != nil {
+				if err := driver.WaitTask(ctx, &task); err != nil {
 					state.Put("error", fmt.Errorf("error waiting for media deletion: %w", err))
 					return multistep.ActionHalt
 				}
 			} else {
 				ui.Sayf("ISO already exists in catalog, skipping upload: %s", mediaName)
-				state.Put("uploaded_media", existingMedia)
-				state.Put("uploaded_media_name", mediaName)
-				state.Put("media_was_uploaded", false) // Don't delete on cleanup
-				return multistep.ActionContinue
+				return s.reuseMedia(ctx, state, existingMedia)
 			}
 		}
 	}
 
+	release, err := d.AcquireTransferSlot(ctx, func() {
+		ui.Say("Waiting for a free transfer slot (max_concurrent_transfers)...")
+	})
+	if err != nil {
+		state.Put("error", fmt.Errorf("error waiting for a transfer slot: %w", err))
+		return multistep.ActionHalt
+	}
+	// Released early when waiting for another build's upload instead
+	release = sync.OnceFunc(release)
+	defer release()
+
 	// Upload the ISO
-	ui.Sayf("Uploading ISO to catalog %s: %s", catalogName, mediaName)
-	media, err := d.UploadMediaImage(catalog, mediaName, "Packer ISO upload", isoPath)
+	var media *govcd.Media
+	if partial != nil {
+		ui.Sayf("Resuming upload of ISO to catalog %s: %s", catalogName, partial.Media.Name)
+		media, err = d.ResumeMediaUpload(ctx, catalog, partial, isoPath, s.partial.Uploaded)
+	} else {
+		ui.Sayf("Uploading ISO to catalog %s: %s", catalogName, mediaName)
+		media, err = d.UploadMediaImage(ctx, catalog, mediaName, "Packer ISO upload", isoPath)
+	}
+	if errors.Is(err, driver.ErrMediaNameTaken) && s.CacheISO && !s.CacheOverwrite {
+		// Another build created the media between the cache check and the
+		// upload; theirs is as good as ours
+		release()
+		existingMedia, getErr := catalog.GetMediaByName(mediaName, true)
+		if getErr != nil {
+			state.Put("error", fmt.Errorf("error getting ISO %s uploaded by another build: %w", mediaName, getErr))
+			return multistep.ActionHalt
+		}
+		return s.reuseMedia(ctx, state, existingMedia)
+	}
+	if errors.Is(err, driver.ErrMediaNameTaken) {
+		// Not caching: leave the existing media alone and use another name
+		ext := filepath.Ext(mediaName)
+		mediaName = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(mediaName, ext), time.Now().Unix(), ext)
+		ui.Sayf("A media with that name already exists, uploading as %s", mediaName)
+		media, err = d.UploadMediaImage(ctx, catalog, mediaName, "Packer ISO upload", isoPath)
+	}
+	if uploadErr := (*driver.MediaUploadError)(nil); errors.As(err, &uploadErr) {
+		s.partial = uploadErr
+	}
 	if err != nil {
 		state.Put("error", fmt.Errorf("error uploading ISO: %w", err))
 		return multistep.ActionHalt
 	}
+	s.partial = nil
+
+	// The name as stored by VCD
+	mediaName = media.Media.Name
+
+	// Make the ISO findable by iso_search in later builds
+	if checksum != "" {
+		if err := d.SetMediaChecksum(media, checksum); err != nil {
+			log.Printf("[WARN] %s", err)
+		}
+	}
 
 	state.Put("uploaded_media", media)
 	state.Put("uploaded_media_name", mediaName)
@@ -92,6 +217,53 @@ func (s *StepUploadISO) Run(_ context.Context, state multistep.StateBag) multist
 	return multistep.ActionContinue
 }
 
+// reuseMedia mounts a cached media rather than uploading the ISO. When
+// another build is still uploading it, it waits for the upload to complete,
+// so that each ISO is only uploaded once to a shared catalog.
+func (s *StepUploadISO) reuseMedia(ctx context.Context, state multistep.StateBag, media *govcd.Media) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	d := state.Get("driver").(driver.Driver)
+	catalog := state.Get("catalog").(*govcd.Catalog)
+	mediaName := media.Media.Name
+
+	// Status 0 (UNRESOLVED) while the upload is in progress
+	if media.Media.Status == 0 {
+		ui.Sayf("ISO %s is being uploaded by another build, waiting for it (timeout %s)...", mediaName, s.UploadWaitTimeout)
+		resolved, err := d.WaitForMediaResolved(ctx, catalog, media.Media.ID, s.UploadWaitTimeout, func(status string) {
+			ui.Message(fmt.Sprintf("Media status: %s", status))
+		})
+		if err != nil {
+			state.Put("error", fmt.Errorf("error waiting for the upload of ISO %s by another build "+
+				"(set cache_overwrite to upload it again): %w", mediaName, err))
+			return multistep.ActionHalt
+		}
+		media = resolved
+	}
+
+	state.Put("uploaded_media", media)
+	state.Put("uploaded_media_name", mediaName)
+	state.Put("media_was_uploaded", false) // Don't delete on cleanup
+	return multistep.ActionContinue
+}
+
+// mediaChecksum returns checksum in the "<type>:<hex>" form recorded on
+// uploaded media, or "" if it is not a literal checksum (none, file: or URL).
+func mediaChecksum(checksum string) string {
+	typ, value, ok := strings.Cut(checksum, ":")
+	if !ok || value == "" {
+		return ""
+	}
+	switch strings.ToLower(typ) {
+	case "md5", "sha1", "sha256", "sha512":
+	default:
+		return ""
+	}
+	if _, err := hex.DecodeString(value); err != nil {
+		return ""
+	}
+	return strings.ToLower(typ) + ":" + strings.ToLower(value)
+}
+
 func (s *StepUploadISO) Cleanup(state multistep.StateBag) {
 	ui := state.Get("ui").(packersdk.Ui)
 
@@ -103,6 +275,21 @@ func (s *StepUploadISO) Cleanup(state multistep.StateBag) {
 		return
 	}
 
+	// A media without its file stays unresolved forever
+	if s.partial != nil {
+		media := s.partial.Media
+		s.partial = nil
+		ui.Sayf("Deleting partially uploaded ISO from catalog: %s", media.Media.Name)
+		task, err := media.Delete()
+		if err == nil {
+			err = driver.WaitTask(context.Background(), &task)
+		}
+		if err != nil {
+			ui.Errorf("Error deleting partially uploaded ISO %s: %s", media.Media.Name, err)
+		}
+		return
+	}
+
 	// For persistent catalogs, we don't delete cached ISOs
 	// This matches vsphere behavior - ISOs stay cached for future builds
 	wasUploaded, ok := state.GetOk("media_was_uploaded")
@@ -117,5 +304,30 @@ func (s *StepUploadISO) Cleanup(state multistep.StateBag) {
 	if cancelled || halted {
 		mediaName, _ := state.GetOk("uploaded_media_name")
 		ui.Sayf("Build cancelled/halted. Uploaded ISO remains in catalog: %s", mediaName)
+		return
+	}
+
+	if !s.DeleteMedia {
+		return
+	}
+	// An unmodified ISO uploaded with cache_iso is there for later builds
+	isoModified, _ := state.Get("iso_modified").(bool)
+	if s.CacheISO && !isoModified {
+		return
+	}
+	// Still mounted when StepMountISO failed to eject it
+	if isoMounted, _ := state.Get("iso_mounted").(bool); isoMounted {
+		ui.Errorf("Uploaded ISO is still mounted, leaving it in the catalog: %s", state.Get("uploaded_media_name"))
+		return
+	}
+
+	media := state.Get("uploaded_media").(*govcd.Media)
+	ui.Sayf("Deleting uploaded ISO from catalog: %s", media.Media.Name)
+	task, err := media.Delete()
+	if err == nil {
+		err = driver.WaitTask(context.Background(), &task)
+	}
+	if err != nil {
+		ui.Errorf("Error deleting uploaded ISO %s: %s", media.Media.Name, err)
 	}
 }
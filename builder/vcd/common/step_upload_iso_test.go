@@ -0,0 +1,149 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+)
+
+func TestStepUploadISO_ResumesPartialUpload(t *testing.T) {
+	state, d, _ := newTestState(t)
+	state.Put("catalog", d.AddCatalog("media"))
+	state.Put("catalog_name", "media")
+	isoPath := filepath.Join(t.TempDir(), "install.iso")
+	if err := os.WriteFile(isoPath, make([]byte, 4096), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	state.Put("iso_path", isoPath)
+
+	d.Tasks.Fail("UploadMediaImage", errors.New("connection reset by peer"))
+	step := &StepUploadISO{}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("Run() = %v, want ActionHalt", action)
+	}
+	media := d.CatalogMedia("media")
+	if len(media) != 1 || media[0].Media.Status != 0 {
+		t.Fatalf("catalog media after the failed upload = %v, want the unresolved install.iso", media)
+	}
+
+	// Retried, as with -on-error=ask
+	state.Remove("error")
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Run() = %v: %v", action, stepError(state))
+	}
+	media = d.CatalogMedia("media")
+	if len(media) != 1 || media[0].Media.Name != "install.iso" || media[0].Media.Status != 1 {
+		t.Fatalf("catalog media after the retry = %v, want the resolved install.iso", media)
+	}
+	if !slices.Contains(d.Calls(), "ResumeMediaUpload") {
+		t.Errorf("the retry did not resume the upload, calls: %v", d.Calls())
+	}
+	if got := state.Get("media_was_uploaded"); got != true {
+		t.Errorf("media_was_uploaded = %v, want true", got)
+	}
+}
+
+func TestStepUploadISO_Search(t *testing.T) {
+	state, d, _ := newTestState(t)
+	state.Put("catalog", d.AddCatalog("media"))
+	state.Put("catalog_name", "media")
+	d.AddCatalog("shared")
+	checksum := "sha256:" + strings.Repeat("ab", 32)
+	if _, err := d.AddMedia("shared", "ubuntu.iso", checksum); err != nil {
+		t.Fatal(err)
+	}
+	state.Put("iso_path", filepath.Join(t.TempDir(), "ubuntu-24.04.iso"))
+
+	step := &StepUploadISO{Search: true, ISOChecksum: strings.ToUpper(checksum)}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("Run() = %v: %v", action, stepError(state))
+	}
+	if got := state.Get("uploaded_media_name"); got != "ubuntu.iso" {
+		t.Errorf("uploaded_media_name = %v, want ubuntu.iso", got)
+	}
+	if got := state.Get("media_was_uploaded"); got != false {
+		t.Errorf("media_was_uploaded = %v, want false", got)
+	}
+	if slices.Contains(d.Calls(), "UploadMediaImage") {
+		t.Error("the ISO was uploaded although the catalogs have it")
+	}
+}
+
+func TestStepUploadISO_ReuseMediaWaitsForUpload(t *testing.T) {
+	state, d, _ := newTestState(t)
+	state.Put("catalog", d.AddCatalog("media"))
+	media, err := d.AddMedia("media", "install.iso", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Still being uploaded by another build
+	media.Media.Status = 0
+
+	step := &StepUploadISO{UploadWaitTimeout: time.Minute}
+	if action := step.reuseMedia(context.Background(), state, media); action != multistep.ActionContinue {
+		t.Fatalf("reuseMedia() = %v: %v", action, stepError(state))
+	}
+	if !slices.Contains(d.Calls(), "WaitForMediaResolved") {
+		t.Errorf("reuseMedia() did not wait for the upload, calls: %v", d.Calls())
+	}
+	uploaded, _ := state.Get("uploaded_media").(*govcd.Media)
+	if uploaded == nil || uploaded.Media.Status != 1 {
+		t.Errorf("uploaded_media = %v, want the resolved install.iso", uploaded)
+	}
+	if got := state.Get("media_was_uploaded"); got != false {
+		t.Errorf("media_was_uploaded = %v, want false", got)
+	}
+}
+
+func TestStepUploadISO_ReuseMediaWaitError(t *testing.T) {
+	state, d, _ := newTestState(t)
+	state.Put("catalog", d.AddCatalog("media"))
+	media, err := d.AddMedia("media", "install.iso", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	media.Media.Status = 0
+
+	d.Errors["WaitForMediaResolved"] = errors.New("timed out")
+	step := &StepUploadISO{UploadWaitTimeout: time.Minute}
+	if action := step.reuseMedia(context.Background(), state, media); action != multistep.ActionHalt {
+		t.Fatalf("reuseMedia() = %v, want ActionHalt", action)
+	}
+	if err := stepError(state); err == nil || !strings.Contains(err.Error(), "cache_overwrite") {
+		t.Errorf("error = %v, want a hint at cache_overwrite", err)
+	}
+	if _, ok := state.GetOk("uploaded_media"); ok {
+		t.Error("uploaded_media set although the upload never completed")
+	}
+}
+
+func TestMediaChecksum(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"sha256:ABCDEF0123", "sha256:abcdef0123"},
+		{"SHA1:00ff", "sha1:00ff"},
+		{"md5:d41d8cd98f00b204e9800998ecf8427e", "md5:d41d8cd98f00b204e9800998ecf8427e"},
+		{"", ""},
+		{"none", ""},
+		{"sha256:", ""},
+		{"sha256:not-hex", ""},
+		{"crc32:1234abcd", ""},
+		{"file:./SHA256SUMS", ""},
+		{"https://example.com/SHA256SUMS", ""},
+	}
+	for _, tt := range tests {
+		if got := mediaChecksum(tt.in); got != tt.want {
+			t.Errorf("mediaChecksum(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+)
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type VerifyDiskBootConfig
+
+// VerifyDiskBootConfig contains configuration for checking that the VM
+// boots from its disk once the installer has finished.
+type VerifyDiskBootConfig struct {
+	// Eject the ISO and restart the VM once the installer has finished,
+	// before provisioning, and check that the installed system comes up
+	// from the disk: the communicator port must accept connections, or the
+	// guest tools report an IP address with the `none` communicator. When it
+	// does not, the build fails with the console screen saved as
+	// `packer-<vm_name>-disk-boot.png`, and the firmware message read from it
+	// when `tesseract` is installed, instead of failing later in
+	// provisioning or in the first deployment of the template. Defaults to
+	// false.
+	VerifyDiskBoot bool `mapstructure:"verify_disk_boot"`
+	// How long the VM has to boot from disk when `verify_disk_boot` is set.
+	// Defaults to 10m.
+	VerifyDiskBootTimeout time.Duration `mapstructure:"verify_disk_boot_timeout"`
+}
+
+func (c *VerifyDiskBootConfig) Prepare() []error {
+	if c.VerifyDiskBootTimeout == 0 {
+		c.VerifyDiskBootTimeout = 10 * time.Minute
+	}
+	return nil
+}
+
+// noBootMessages are shown by BIOS and EFI firmware, or by a broken boot
+// loader, when the VM cannot boot from its disk.
+var noBootMessages = []string{
+	"no bootable device",
+	"operating system not found",
+	"missing operating system",
+	"boot failed",
+	"no boot device",
+	"bootmgr is missing",
+	"grub rescue",
+	"pxe-e",
+	"shell>",
+}
+
+// StepVerifyDiskBoot ejects the installation ISO and restarts the VM, then
+// waits for the installed system to come up from the disk. It catches
+// installs to the wrong disk, or without a boot loader, before provisioning.
+type StepVerifyDiskBoot struct {
+	Config *VerifyDiskBootConfig
+	Comm   *communicator.Config
+	VMName string
+}
+
+func (s *StepVerifyDiskBoot) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if !s.Config.VerifyDiskBoot {
+		return multistep.ActionContinue
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	d := state.Get("driver").(driver.Driver)
+	vm := state.Get("vm").(driver.VirtualMachine)
+
+	ui.Say("Verifying that the VM boots from disk...")
+
+	// Without the ISO, the firmware can only boot the disk
+	if isoMounted, ok := state.GetOk("iso_mounted"); ok && isoMounted.(bool) {
+		media := state.Get("uploaded_media").(*govcd.Media)
+		ui.Sayf("Ejecting ISO: %s", media.Media.Name)
+		if err := vm.EjectMedia(media); err != nil {
+			state.Put("error", fmt.Errorf("error ejecting ISO before verifying disk boot: %w", err))
+			return multistep.ActionHalt
+		}
+		state.Put("iso_mounted", false)
+	}
+	if err := ejectFloppy(ui, state); err != nil {
+		state.Put("error", fmt.Errorf("error ejecting floppy image before verifying disk boot: %w", err))
+		return multistep.ActionHalt
+	}
+
+	if err := restartVM(ctx, ui, vm); err != nil {
+		state.Put("error", fmt.Errorf("error restarting VM to verify disk boot: %w", err))
+		return multistep.ActionHalt
+	}
+
+	err := s.waitForBoot(ctx, ui, vm, state)
+	if err == nil {
+		ui.Say("VM booted from disk")
+		return multistep.ActionContinue
+	}
+	if ctx.Err() != nil {
+		return multistep.ActionHalt
+	}
+
+	state.Put("error", s.diagnose(ctx, ui, d, vm, err))
+	return multistep.ActionHalt
+}
+
+// restartVM shuts the guest down cleanly, the installer having just written
+// the disk, and powers it on again. A VM that does not shut down is powered
+// off.
+func restartVM(ctx context.Context, ui packersdk.Ui, vm driver.VirtualMachine) error {
+	ui.Say("Restarting VM...")
+	if err := vm.Shutdown(ctx); err != nil {
+		log.Printf("[WARN] Guest shutdown failed, powering off: %v", err)
+	}
+	if err := vm.WaitForPowerOff(ctx, 5*time.Minute); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		ui.Say("VM did not shut down, powering it off...")
+		if err := vm.PowerOff(ctx); err != nil {
+			return err
+		}
+	}
+	return vm.PowerOn(ctx)
+}
+
+// waitForBoot waits for the communicator port to accept connections, or for
+// the guest tools to report an IP address when there is no port to probe.
+func (s *StepVerifyDiskBoot) waitForBoot(ctx context.Context, ui packersdk.Ui, vm driver.VirtualMachine, state multistep.StateBag) error {
+	timeout := s.Config.VerifyDiskBootTimeout
+
+	host, _ := CommHost(s.Comm.Host())(state)
+	if host == "" || s.Comm.Type == "none" || s.Comm.SSHBastionHost != "" || s.Comm.SSHProxyHost != "" {
+		ui.Sayf("Waiting for the guest tools to report an IP address (timeout: %s)...", timeout)
+		_, err := vm.WaitForIP(ctx, timeout)
+		return err
+	}
+
+	addr := net.JoinHostPort(host, strconv.Itoa(s.Comm.Port()))
+	ui.Sayf("Waiting for %s port %s (timeout: %s)...", strings.ToUpper(s.Comm.Type), addr, timeout)
+
+	deadline := time.After(timeout)
+	for {
+		result, err := probePort(ctx, addr)
+		if result == probeOpen {
+			return nil
+		}
+		log.Printf("[DEBUG] Disk boot probe of %s failed: %v", addr, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			return fmt.Errorf("%s port %s did not open within %s", s.Comm.Type, addr, timeout)
+		case <-time.After(probeInterval):
+		}
+	}
+}
+
+// diagnose saves the console screen and turns the firmware message on it,
+// if any, into a targeted error.
+func (s *StepVerifyDiskBoot) diagnose(ctx context.Context, ui packersdk.Ui, d driver.Driver, vm driver.VirtualMachine, waitErr error) error {
+	generic := fmt.Errorf("VM did not boot from disk after the ISO was ejected (%w): "+
+		"check that the installer wrote a boot loader to the disk the VM boots from", waitErr)
+
+	img, err := d.CaptureScreenshot(ctx, vm)
+	if err != nil {
+		log.Printf("[WARN] Could not take a console screenshot: %v", err)
+		return generic
+	}
+	path := fmt.Sprintf("packer-%s-disk-boot.png", s.VMName)
+	if err := driver.WritePNG(path, img); err != nil {
+		log.Printf("[WARN] Could not save console screenshot: %v", err)
+	} else {
+		ui.Sayf("Console screenshot saved to %s", path)
+	}
+
+	if driver.CheckOCR() != nil {
+		return generic
+	}
+	lines, err := driver.RecognizeText(ctx, img)
+	if err != nil {
+		log.Printf("[WARN] Could not read the console: %v", err)
+		return generic
+	}
+	for _, line := range lines {
+		lower := strings.ToLower(line)
+		for _, msg := range noBootMessages {
+			if strings.Contains(lower, msg) {
+				return fmt.Errorf("VM did not boot from disk after the ISO was ejected, the console shows %q: "+
+					"the installer did not install a boot loader, or installed to another disk", line)
+			}
+		}
+	}
+	return generic
+}
+
+func (s *StepVerifyDiskBoot) Cleanup(state multistep.StateBag) {
+	// Nothing to clean up
+}
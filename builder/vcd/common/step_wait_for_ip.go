@@ -3,6 +3,7 @@ package common
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -20,6 +21,15 @@ type WaitIpConfig struct {
 
 	// Time to wait after IP is discovered before considering it stable. Defaults to 5s.
 	SettleTimeout time.Duration `mapstructure:"ip_settle_timeout"`
+
+	// Interval at which the console is sampled while waiting for the IP
+	// address, to log the progress shown by the installer (e.g. `Installing
+	// the base system 42%`). Text is read from the screen with `tesseract`,
+	// which must be installed. Must be at least 10s. Disabled by default.
+	InstallProgressInterval time.Duration `mapstructure:"install_progress_interval"`
+	// Regular expression selecting the console lines logged by
+	// `install_progress_interval`. Defaults to lines showing a percentage.
+	InstallProgressPattern string `mapstructure:"install_progress_pattern"`
 }
 
 func (c *WaitIpConfig) Prepare() []error {
@@ -32,6 +42,21 @@ func (c *WaitIpConfig) Prepare() []error {
 		c.SettleTimeout = 5 * time.Second
 	}
 
+	if c.InstallProgressInterval != 0 {
+		if c.InstallProgressInterval < 10*time.Second {
+			errs = append(errs, fmt.Errorf("'install_progress_interval' must be at least 10s"))
+		}
+		if err := driver.CheckOCR(); err != nil {
+			errs = append(errs, fmt.Errorf("'install_progress_interval': %w", err))
+		}
+	}
+	if c.InstallProgressPattern == "" {
+		c.InstallProgressPattern = defaultInstallProgressPattern
+	}
+	if _, err := regexp.Compile(c.InstallProgressPattern); err != nil {
+		errs = append(errs, fmt.Errorf("invalid 'install_progress_pattern': %w", err))
+	}
+
 	return errs
 }
 
@@ -50,6 +75,13 @@ func (s *StepWaitForIP) Run(ctx context.Context, state multistep.StateBag) multi
 
 	ui.Sayf("Waiting for VM to acquire IP address (timeout: %s)...", timeout)
 
+	if s.Config.InstallProgressInterval > 0 {
+		progressCtx, stopProgress := context.WithCancel(ctx)
+		defer stopProgress()
+		go logInstallProgress(progressCtx, ui, state.Get("driver").(driver.Driver), vm,
+			s.Config.InstallProgressInterval, regexp.MustCompile(s.Config.InstallProgressPattern))
+	}
+
 	deadline := time.Now().Add(timeout)
 	var lastIP string
 	var settleStart time.Time
@@ -10,8 +10,10 @@ import (
 // FlatWaitIpConfig is an auto-generated flat version of WaitIpConfig.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatWaitIpConfig struct {
-	WaitTimeout   *string `mapstructure:"ip_wait_timeout" cty:"ip_wait_timeout" hcl:"ip_wait_timeout"`
-	SettleTimeout *string `mapstructure:"ip_settle_timeout" cty:"ip_settle_timeout" hcl:"ip_settle_timeout"`
+	WaitTimeout             *string `mapstructure:"ip_wait_timeout" cty:"ip_wait_timeout" hcl:"ip_wait_timeout"`
+	SettleTimeout           *string `mapstructure:"ip_settle_timeout" cty:"ip_settle_timeout" hcl:"ip_settle_timeout"`
+	InstallProgressInterval *string `mapstructure:"install_progress_interval" cty:"install_progress_interval" hcl:"install_progress_interval"`
+	InstallProgressPattern  *string `mapstructure:"install_progress_pattern" cty:"install_progress_pattern" hcl:"install_progress_pattern"`
 }
 
 // FlatMapstructure returns a new FlatWaitIpConfig.
@@ -26,8 +28,10 @@ func (*WaitIpConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.
 // The decoded values from this spec will then be applied to a FlatWaitIpConfig.
 func (*FlatWaitIpConfig) HCL2Spec() map[string]hcldec.Spec {
 	s := map[string]hcldec.Spec{
-		"ip_wait_timeout":   &hcldec.AttrSpec{Name: "ip_wait_timeout", Type: cty.String, Required: false},
-		"ip_settle_timeout": &hcldec.AttrSpec{Name: "ip_settle_timeout", Type: cty.String, Required: false},
+		"ip_wait_timeout":           &hcldec.AttrSpec{Name: "ip_wait_timeout", Type: cty.String, Required: false},
+		"ip_settle_timeout":         &hcldec.AttrSpec{Name: "ip_settle_timeout", Type: cty.String, Required: false},
+		"install_progress_interval": &hcldec.AttrSpec{Name: "install_progress_interval", Type: cty.String, Required: false},
+		"install_progress_pattern":  &hcldec.AttrSpec{Name: "install_progress_pattern", Type: cty.String, Required: false},
 	}
 	return s
 }
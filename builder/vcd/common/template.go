@@ -0,0 +1,137 @@
+// Copyright 2025 Juan Font
+// BSD-3-Clause
+
+package common
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"net/netip"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// newTemplateContext returns a copy of ctx for rendering boot_command,
+// guestinfo and cd_content: the variables gathered from the state of the
+// build, and the functions of templateFuncs on top of the Packer ones
+// (user, build_name, env, ...).
+func newTemplateContext(ctx interpolate.Context, state multistep.StateBag, vmName string) *interpolate.Context {
+	funcs := make(map[string]interface{}, len(ctx.Funcs)+len(templateFuncs))
+	for name, f := range ctx.Funcs {
+		funcs[name] = f
+	}
+	for name, f := range templateFuncs {
+		funcs[name] = f
+	}
+	ctx.Funcs = funcs
+	ctx.Data = newTemplateData(state, vmName)
+	return &ctx
+}
+
+// newTemplateData gathers the template variables from the state of the
+// build. Values not known yet are empty, so that templates rendered before
+// the VM exists do not fail.
+func newTemplateData(state multistep.StateBag, vmName string) map[string]interface{} {
+	data := map[string]interface{}{
+		"HTTPIP":   "",
+		"HTTPPort": 0,
+		"Name":     vmName,
+		// Network info (populated for POOL/MANUAL allocation modes)
+		"VMIP":      "",
+		"VMGateway": "",
+		"VMNetmask": "",
+		"VMPrefix":  "", // CIDR prefix (e.g., "24" for 255.255.255.0)
+		"VMDNS":     "",
+		// NIC info (populated by StepDiscoverNICs)
+		"VMMAC":         "",
+		"VMNIC0MAC":     "",
+		"VMNIC0Network": "",
+		"VMNIC1MAC":     "",
+		"VMNIC1Network": "",
+		// Uploaded ISO (populated by StepCreateTempCatalog and StepUploadISO)
+		"ISOCatalog":   "",
+		"ISOMediaName": "", // may carry a content hash or retry suffix
+	}
+
+	if ip, ok := state.GetOk("http_ip"); ok {
+		data["HTTPIP"] = ip.(string)
+	}
+	if port, ok := state.GetOk("http_port"); ok {
+		data["HTTPPort"] = port.(int)
+	}
+	if ip, ok := state.GetOk("vm_ip"); ok {
+		data["VMIP"] = ip.(string)
+	}
+	if gw, ok := state.GetOk("network_gateway"); ok {
+		data["VMGateway"] = gw.(string)
+	}
+	if nm, ok := state.GetOk("network_netmask"); ok {
+		data["VMNetmask"] = nm.(string)
+		data["VMPrefix"] = netmaskToPrefix(nm.(string))
+	}
+	if d, ok := state.GetOk("network_dns"); ok {
+		data["VMDNS"] = d.(string)
+	}
+	if name, ok := state.GetOk("catalog_name"); ok {
+		data["ISOCatalog"] = name.(string)
+	}
+	if name, ok := state.GetOk("uploaded_media_name"); ok {
+		data["ISOMediaName"] = name.(string)
+	}
+
+	// Every adapter, not only the first two
+	for name, value := range nicTemplateVars(state) {
+		data[name] = value
+	}
+
+	return data
+}
+
+// templateFuncs are the functions added to the Packer template functions,
+// named after their HCL counterparts.
+var templateFuncs = map[string]interface{}{
+	"cidrhost":    cidrHost,
+	"cidrnetmask": cidrNetmask,
+}
+
+// cidrHost returns the IP address of host number hostNum in prefix, e.g.
+// cidrhost "10.0.0.0/24" 5 is 10.0.0.5. Negative numbers count back from
+// the end of the range.
+func cidrHost(prefix string, hostNum int) (string, error) {
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return "", fmt.Errorf("cidrhost: %w", err)
+	}
+	p = p.Masked()
+
+	hostBits := p.Addr().BitLen() - p.Bits()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(hostBits))
+	num := big.NewInt(int64(hostNum))
+	if hostNum < 0 {
+		num.Add(num, size)
+	}
+	if num.Sign() < 0 || num.Cmp(size) >= 0 {
+		return "", fmt.Errorf("cidrhost: prefix %s has no host number %d", prefix, hostNum)
+	}
+
+	addr := new(big.Int).SetBytes(p.Addr().AsSlice())
+	addr.Add(addr, num)
+	b := addr.FillBytes(make([]byte, len(p.Addr().AsSlice())))
+	host, _ := netip.AddrFromSlice(b)
+	return host.String(), nil
+}
+
+// cidrNetmask returns the netmask of an IPv4 prefix, e.g. cidrnetmask
+// "10.0.0.0/24" is 255.255.255.0.
+func cidrNetmask(prefix string) (string, error) {
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return "", fmt.Errorf("cidrnetmask: %w", err)
+	}
+	if !p.Addr().Is4() {
+		return "", fmt.Errorf("cidrnetmask: %s is not an IPv4 prefix", prefix)
+	}
+	return net.IP(net.CIDRMask(p.Bits(), 32)).String(), nil
+}
@@ -0,0 +1,444 @@
+// Copyright 2025 Juan Font
+// BSD-3-Clause
+
+package udf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Appender adds files to a copy of an image instead of rebuilding it. The
+// sectors of the source are kept as they are: the added files, the data of
+// the directories gaining entries and the new file entries go after them,
+// and the file entries of replaced files and changed directories are
+// rewritten in place. The partition, the integrity descriptor and the
+// ISO 9660 volume size are updated, and a new anchor ends the image.
+//
+// The El Torito boot records are kept as they are, so boot images cannot be
+// replaced.
+type Appender struct {
+	img          *Image
+	size         int64 // of the source image, in bytes
+	w            *Writer
+	volumeID     string
+	nextUniqueID uint64
+	bootImages   map[*node]bool
+}
+
+// NewAppender reads the directory tree of img, whose image is size bytes
+// long, for files to be added to it.
+func NewAppender(img *Image, size int64) (*Appender, error) {
+	if len(img.partitions) != 1 {
+		return nil, fmt.Errorf("images with %d UDF partitions cannot be appended to", len(img.partitions))
+	}
+
+	w := NewWriter(img.volumeID)
+	w.root.existing = true
+	w.root.entry = img.root.block
+	a := &Appender{img: img, size: size, w: w, nextUniqueID: 16, bootImages: make(map[*node]bool)}
+
+	bootSectors, err := img.bootSectors()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read boot records: %w", err)
+	}
+
+	dirs := map[string]*node{"": w.root}
+	err = img.Walk(func(f *File) error {
+		dir, name := splitPath(f.Path)
+		parent := dirs[dir]
+		if parent == nil {
+			return fmt.Errorf("parent directory of %s not found", f.Path)
+		}
+		if f.icb.partition != 0 {
+			return fmt.Errorf("%s: invalid UDF partition reference %d", f.Path, f.icb.partition)
+		}
+		n := &node{
+			name:     name,
+			dir:      f.IsDir,
+			link:     f.Link != "",
+			size:     f.Size,
+			modTime:  f.ModTime,
+			existing: true,
+			entry:    f.icb.block,
+			uniqueID: f.uniqueID,
+		}
+		parent.children = append(parent.children, n)
+		if f.IsDir {
+			dirs[f.Path] = n
+		}
+		if len(f.extents) > 0 && bootSectors[f.extents[0].offset/SectorSize] {
+			a.bootImages[n] = true
+		}
+		a.nextUniqueID = max(a.nextUniqueID, f.uniqueID+1)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// SetVolumeID replaces the volume identifier of the image
+func (a *Appender) SetVolumeID(volumeID string) {
+	a.volumeID = volumeID
+}
+
+// AddFile adds a file, replacing the file with the same path if any, as
+// Writer.AddFile does.
+func (a *Appender) AddFile(p string, size int64, modTime time.Time, open func() (io.Reader, error)) error {
+	return a.w.AddFile(p, size, modTime, open)
+}
+
+// AddSymlink adds a symbolic link to target, as Writer.AddSymlink does.
+func (a *Appender) AddSymlink(p, target string, modTime time.Time) error {
+	return a.w.AddSymlink(p, target, modTime)
+}
+
+// vdsDescriptor is a descriptor of a volume descriptor sequence, and the
+// sector it was read from
+type vdsDescriptor struct {
+	sector int64
+	b      []byte
+}
+
+// Append writes the added files to out, which must hold a copy of the
+// source image, and returns the size of the new image.
+func (a *Appender) Append(out io.WriterAt) (int64, error) {
+	anchor, err := a.readSector(anchorSector)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read anchor volume descriptor pointer: %w", err)
+	}
+
+	// The partition length, and the volume identifier when replaced, are
+	// updated in both the main and the reserve sequence
+	var descriptors []vdsDescriptor
+	var partitionBlocks uint32
+	var integrity int64 = -1
+	for _, off := range []int{16, 24} {
+		length := int64(binary.LittleEndian.Uint32(anchor[off:]))
+		start := int64(binary.LittleEndian.Uint32(anchor[off+4:]))
+		for i := int64(0); i < length/SectorSize; i++ {
+			b, err := a.readSector(start + i)
+			if err != nil {
+				return 0, fmt.Errorf("failed to read volume descriptor sequence: %w", err)
+			}
+			t, ok := parseTag(b)
+			if !ok || t.id == tagTerminating {
+				break
+			}
+			switch t.id {
+			case tagPartition:
+				partitionBlocks = max(partitionBlocks, binary.LittleEndian.Uint32(b[192:]))
+			case tagLogicalVolume:
+				if binary.LittleEndian.Uint32(b[432:]) > 0 {
+					integrity = int64(binary.LittleEndian.Uint32(b[436:]))
+				}
+			case tagPrimaryVolume, tagImplementationUse:
+			default:
+				continue
+			}
+			descriptors = append(descriptors, vdsDescriptor{start + i, b})
+		}
+	}
+	if partitionBlocks == 0 {
+		return 0, errors.New("no UDF partition descriptor found")
+	}
+
+	var lvid []byte
+	if integrity >= 0 {
+		b, err := a.readSector(integrity)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read logical volume integrity descriptor: %w", err)
+		}
+		if t, ok := parseTag(b); ok && t.id == tagIntegrity {
+			lvid = b
+			a.nextUniqueID = max(a.nextUniqueID, binary.LittleEndian.Uint64(b[40:]))
+		}
+	}
+
+	// The appended blocks follow both the source image and its partition
+	partitionSector := a.img.partitions[0] / SectorSize
+	first := max(sectors(a.size)-partitionSector, int64(partitionBlocks))
+	l, err := a.layout(first)
+	if err != nil {
+		return 0, err
+	}
+	total := partitionSector + int64(l.partitionBlocks) + 1
+
+	s := &sectorWriter{w: io.NewOffsetWriter(out, (partitionSector+first)*SectorSize)}
+	for _, n := range l.meta {
+		if !n.existing {
+			if err := s.write(fileEntry(n)); err != nil {
+				return 0, err
+			}
+		}
+		if n.dir {
+			if err := s.write(directoryData(n)); err != nil {
+				return 0, err
+			}
+		}
+	}
+	for _, f := range l.files {
+		if err := a.w.writeFileData(s, f, false); err != nil {
+			return 0, fmt.Errorf("failed to write %s: %w", f.name, err)
+		}
+	}
+	binary.LittleEndian.PutUint32(anchor[12:], uint32(total-1))
+	retag(anchor)
+	if err := s.write(anchor[:512]); err != nil {
+		return 0, err
+	}
+
+	// File entries of replaced files and changed directories
+	for _, n := range l.rewritten {
+		b := make([]byte, SectorSize)
+		copy(b, fileEntry(n))
+		if _, err := out.WriteAt(b, a.img.partitions[0]+int64(n.entry)*SectorSize); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, d := range descriptors {
+		a.updateDescriptor(d.b, l)
+		if _, err := out.WriteAt(d.b, d.sector*SectorSize); err != nil {
+			return 0, err
+		}
+	}
+	if lvid != nil {
+		a.updateIntegrity(lvid, l)
+		if _, err := out.WriteAt(lvid, integrity*SectorSize); err != nil {
+			return 0, err
+		}
+	}
+	if a.volumeID != "" {
+		if err := a.updateFileSet(out); err != nil {
+			return 0, err
+		}
+	}
+	if err := a.updateISO9660(out, uint32(total)); err != nil {
+		return 0, err
+	}
+
+	return total * SectorSize, nil
+}
+
+// appendLayout is the placement of the appended descriptors and files
+type appendLayout struct {
+	meta            []*node // in block order: new file entries and directory data
+	files           []*node // in block order: file data
+	rewritten       []*node // file entries rewritten in place
+	partitionBlocks uint32
+	fileCount       uint32 // new files
+	dirCount        uint32 // new directories
+}
+
+// layout places the appended blocks from partition block first on
+func (a *Appender) layout(first int64) (*appendLayout, error) {
+	l := &appendLayout{}
+	next := first
+
+	var place func(n *node) error
+	place = func(n *node) error {
+		if n.existing {
+			if n.open != nil && a.bootImages[n] {
+				return fmt.Errorf("boot image %s cannot be replaced when appending to the image", n.name)
+			}
+			if n.open != nil || n.changed {
+				l.rewritten = append(l.rewritten, n)
+			}
+		} else {
+			n.entry = uint32(next)
+			next++
+			n.uniqueID = a.nextUniqueID
+			a.nextUniqueID++
+			if n.dir {
+				l.dirCount++
+			} else {
+				l.fileCount++
+			}
+		}
+
+		if !n.dir {
+			if !n.existing {
+				l.meta = append(l.meta, n)
+			}
+			if n.open != nil {
+				l.files = append(l.files, n)
+			}
+			return nil
+		}
+
+		for _, c := range n.children {
+			c.parent = n
+		}
+		if !n.existing || n.changed {
+			l.meta = append(l.meta, n)
+			sort.Slice(n.children, func(i, j int) bool { return n.children[i].name < n.children[j].name })
+			n.dataSize = fidSize("")
+			for _, c := range n.children {
+				n.dataSize += fidSize(c.name)
+			}
+			n.data = uint32(next)
+			next += sectors(n.dataSize)
+		}
+		for _, c := range n.children {
+			if err := place(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	root := a.w.root
+	root.parent = root
+	if err := place(root); err != nil {
+		return nil, err
+	}
+
+	for _, f := range l.files {
+		if (f.size+maxExtentLength-1)/maxExtentLength*shortADSize > SectorSize-fileEntryHeaderSize {
+			return nil, fmt.Errorf("file %s is too large", f.name)
+		}
+		f.data = uint32(next)
+		f.dataSize = f.size
+		next += sectors(f.size)
+	}
+	if a.img.partitions[0]/SectorSize+next >= math.MaxUint32 {
+		return nil, errors.New("image too large")
+	}
+	l.partitionBlocks = uint32(next)
+	return l, nil
+}
+
+// updateDescriptor updates a descriptor of a volume descriptor sequence:
+// the length of the partition, and the volume identifier if replaced
+func (a *Appender) updateDescriptor(b []byte, l *appendLayout) {
+	t, _ := parseTag(b)
+	switch t.id {
+	case tagPartition:
+		binary.LittleEndian.PutUint32(b[192:], l.partitionBlocks)
+	case tagPrimaryVolume:
+		if a.volumeID == "" {
+			return
+		}
+		putDString(b[24:56], a.volumeID)
+	case tagLogicalVolume:
+		if a.volumeID == "" {
+			return
+		}
+		putDString(b[84:212], a.volumeID)
+	case tagImplementationUse:
+		if a.volumeID == "" || !strings.HasPrefix(string(b[21:44]), "*UDF LV Info") {
+			return
+		}
+		putDString(b[116:244], a.volumeID)
+	}
+	retag(b)
+}
+
+// updateIntegrity updates the logical volume integrity descriptor for the
+// appended files
+func (a *Appender) updateIntegrity(b []byte, l *appendLayout) {
+	putTimestamp(b[16:], a.w.modTime)
+	binary.LittleEndian.PutUint64(b[40:], a.nextUniqueID)
+	partitions := int(binary.LittleEndian.Uint32(b[72:]))
+	implUse := int(binary.LittleEndian.Uint32(b[76:]))
+	if 80+8*partitions+implUse > len(b) || partitions < 1 {
+		return
+	}
+	binary.LittleEndian.PutUint32(b[80:], 0) // free blocks
+	binary.LittleEndian.PutUint32(b[80+4*partitions:], l.partitionBlocks)
+	if implUse >= 40 {
+		counts := b[80+8*partitions+32:]
+		binary.LittleEndian.PutUint32(counts[0:], binary.LittleEndian.Uint32(counts[0:])+l.fileCount)
+		binary.LittleEndian.PutUint32(counts[4:], binary.LittleEndian.Uint32(counts[4:])+l.dirCount)
+	}
+	retag(b)
+}
+
+// updateFileSet replaces the volume identifier of the file set descriptor
+func (a *Appender) updateFileSet(out io.WriterAt) error {
+	off, err := a.img.offset(a.img.fileSet.partition, a.img.fileSet.block)
+	if err != nil {
+		return err
+	}
+	b := make([]byte, SectorSize)
+	if _, err := a.img.r.ReadAt(b, off); err != nil {
+		return fmt.Errorf("failed to read file set descriptor: %w", err)
+	}
+	putDString(b[112:240], a.volumeID)
+	putDString(b[304:336], a.volumeID)
+	retag(b)
+	_, err = out.WriteAt(b, off)
+	return err
+}
+
+// updateISO9660 updates the volume size of the ISO 9660 volume
+// descriptors, and the volume identifier if replaced
+func (a *Appender) updateISO9660(out io.WriterAt, totalSectors uint32) error {
+	for sector := int64(16); sector < anchorSector; sector++ {
+		b, err := a.readSector(sector)
+		if err != nil {
+			return err
+		}
+		if string(b[1:6]) != "CD001" || b[0] == 255 {
+			return nil
+		}
+		if b[0] != 1 && b[0] != 2 {
+			continue
+		}
+		putBothEndian32(b[80:], totalSectors)
+		if b[0] == 1 && a.volumeID != "" {
+			putPadded(b[40:72], strings.ToUpper(a.volumeID))
+		}
+		if _, err := out.WriteAt(b, sector*SectorSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bootSectors returns the sectors of the El Torito boot images of the
+// image
+func (img *Image) bootSectors() (map[int64]bool, error) {
+	boot := make(map[int64]bool)
+	buf := make([]byte, SectorSize)
+	for sector := int64(16); sector < anchorSector; sector++ {
+		if _, err := img.r.ReadAt(buf, sector*SectorSize); err != nil {
+			return nil, err
+		}
+		if string(buf[1:6]) != "CD001" || buf[0] == 255 {
+			return boot, nil
+		}
+		if buf[0] != 0 || !strings.HasPrefix(string(buf[7:39]), "EL TORITO SPECIFICATION") {
+			continue
+		}
+
+		catalog := make([]byte, SectorSize)
+		if _, err := img.r.ReadAt(catalog, int64(binary.LittleEndian.Uint32(buf[71:]))*SectorSize); err != nil {
+			return nil, err
+		}
+		// Bootable entries follow the validation entry, among section
+		// headers and extensions
+		for e := catalog[32:]; len(e) >= 32; e = e[32:] {
+			if e[0] == 0x88 {
+				boot[int64(binary.LittleEndian.Uint32(e[8:]))] = true
+			}
+		}
+	}
+	return boot, nil
+}
+
+func (a *Appender) readSector(sector int64) ([]byte, error) {
+	b := make([]byte, SectorSize)
+	if _, err := a.img.r.ReadAt(b, sector*SectorSize); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,220 @@
+// Copyright 2025 Juan Font
+// BSD-3-Clause
+
+package udf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The ISO 9660 side of a bridge image: an empty root directory, and the
+// El Torito boot records pointing at boot images in the UDF partition.
+
+// El Torito platform identifiers
+const (
+	platformBIOS = 0x00
+	platformEFI  = 0xEF
+)
+
+// putBothEndian32 writes v in both byte orders, as ISO 9660 does
+func putBothEndian32(b []byte, v uint32) {
+	binary.LittleEndian.PutUint32(b[0:], v)
+	binary.BigEndian.PutUint32(b[4:], v)
+}
+
+func putBothEndian16(b []byte, v uint16) {
+	binary.LittleEndian.PutUint16(b[0:], v)
+	binary.BigEndian.PutUint16(b[2:], v)
+}
+
+// putPadded writes s into b padded with spaces
+func putPadded(b []byte, s string) {
+	n := copy(b, s)
+	for i := n; i < len(b); i++ {
+		b[i] = ' '
+	}
+}
+
+// putVolumeDate writes t as a 17 byte ISO 9660 volume date
+func putVolumeDate(b []byte, t time.Time) {
+	if t.IsZero() {
+		copy(b, "0000000000000000")
+		b[16] = 0
+		return
+	}
+	t = t.UTC()
+	copy(b, fmt.Sprintf("%04d%02d%02d%02d%02d%02d%02d",
+		t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond()/10_000_000))
+	b[16] = 0
+}
+
+// directoryRecord returns an ISO 9660 directory record of the root
+// directory, named "\x00" for itself or "\x01" for its parent.
+func directoryRecord(name byte, t time.Time) []byte {
+	b := make([]byte, 34)
+	b[0] = 34
+	putBothEndian32(b[2:], isoRootSector)
+	putBothEndian32(b[10:], SectorSize)
+	t = t.UTC()
+	b[18] = byte(t.Year() - 1900)
+	b[19] = byte(t.Month())
+	b[20] = byte(t.Day())
+	b[21] = byte(t.Hour())
+	b[22] = byte(t.Minute())
+	b[23] = byte(t.Second())
+	b[25] = 0x02 // directory
+	putBothEndian16(b[28:], 1)
+	b[32] = 1
+	b[33] = name
+	return b
+}
+
+func isoPrimaryVolumeDescriptor(volumeID string, totalSectors uint32, t time.Time) []byte {
+	b := make([]byte, SectorSize)
+	b[0] = 1
+	copy(b[1:], "CD001")
+	b[6] = 1
+	putPadded(b[8:40], "")
+	putPadded(b[40:72], strings.ToUpper(volumeID))
+	putBothEndian32(b[80:], totalSectors)
+	putBothEndian16(b[120:], 1) // volume set size
+	putBothEndian16(b[124:], 1) // volume sequence number
+	putBothEndian16(b[128:], SectorSize)
+	putBothEndian32(b[132:], 10) // path table size
+	binary.LittleEndian.PutUint32(b[140:], pathTableLSector)
+	binary.BigEndian.PutUint32(b[148:], pathTableMSector)
+	copy(b[156:], directoryRecord(0, t))
+	putPadded(b[190:813], "") // set, publisher, preparer, application and file identifiers
+	putVolumeDate(b[813:], t)
+	putVolumeDate(b[830:], t)
+	putVolumeDate(b[847:], time.Time{})
+	putVolumeDate(b[864:], t)
+	b[881] = 1 // file structure version
+	return b
+}
+
+func bootRecordDescriptor() []byte {
+	b := make([]byte, SectorSize)
+	copy(b[1:], "CD001")
+	b[6] = 1
+	copy(b[7:], "EL TORITO SPECIFICATION")
+	binary.LittleEndian.PutUint32(b[71:], bootCatalogSector)
+	return b
+}
+
+func isoTerminatorDescriptor() []byte {
+	b := make([]byte, SectorSize)
+	b[0] = 255
+	copy(b[1:], "CD001")
+	b[6] = 1
+	return b
+}
+
+// recognitionDescriptor returns a descriptor of the UDF volume recognition
+// sequence
+func recognitionDescriptor(id string) []byte {
+	b := make([]byte, SectorSize)
+	copy(b[1:], id)
+	b[6] = 1
+	return b
+}
+
+func isoRootDirectory(t time.Time) []byte {
+	return append(directoryRecord(0, t), directoryRecord(1, t)...)
+}
+
+// pathTable returns the path table of the root directory alone
+func pathTable(bigEndian bool) []byte {
+	b := make([]byte, 10)
+	b[0] = 1
+	if bigEndian {
+		binary.BigEndian.PutUint32(b[2:], isoRootSector)
+		binary.BigEndian.PutUint16(b[6:], 1)
+	} else {
+		binary.LittleEndian.PutUint32(b[2:], isoRootSector)
+		binary.LittleEndian.PutUint16(b[6:], 1)
+	}
+	return b
+}
+
+// bootEntry is a no emulation El Torito boot entry
+type bootEntry struct {
+	platform    byte
+	loadSegment uint16
+	sectors     uint16 // 512 byte sectors loaded
+	lba         uint32
+}
+
+func (e bootEntry) put(b []byte) {
+	b[0] = 0x88 // bootable, no emulation
+	binary.LittleEndian.PutUint16(b[2:], e.loadSegment)
+	binary.LittleEndian.PutUint16(b[6:], e.sectors)
+	binary.LittleEndian.PutUint32(b[8:], e.lba)
+}
+
+// bootCatalog returns the El Torito boot catalog: the BIOS image as the
+// default entry, and the UEFI image in a section of its own.
+func (w *Writer) bootCatalog(l *layout) []byte {
+	var entries []bootEntry
+	if l.bios != nil {
+		sectors := w.boot.BIOSLoadSize
+		if sectors == 0 {
+			sectors = 4
+		}
+		entries = append(entries, bootEntry{
+			platform:    platformBIOS,
+			loadSegment: w.boot.BIOSLoadSegment,
+			sectors:     sectors,
+			lba:         lba(l.bios.data),
+		})
+	}
+	if l.uefi != nil {
+		entries = append(entries, bootEntry{
+			platform: platformEFI,
+			sectors:  uint16(min((l.uefi.size+511)/512, 0xFFFF)),
+			lba:      lba(l.uefi.data),
+		})
+	}
+
+	b := make([]byte, SectorSize)
+
+	// Validation entry, whose 16 bit words sum to zero
+	b[0] = 1
+	b[1] = entries[0].platform
+	b[30] = 0x55
+	b[31] = 0xAA
+	var sum uint16
+	for i := 0; i < 32; i += 2 {
+		sum += binary.LittleEndian.Uint16(b[i:])
+	}
+	binary.LittleEndian.PutUint16(b[28:], -sum)
+
+	entries[0].put(b[32:])
+	if len(entries) > 1 {
+		b[64] = 0x91 // final section header
+		b[65] = entries[1].platform
+		binary.LittleEndian.PutUint16(b[66:], 1)
+		entries[1].put(b[96:])
+	}
+	return b
+}
+
+// patchBootInfoTable writes the boot info table isolinux expects at offset
+// 8 of its image: where the volume descriptors and the image are, and a
+// checksum of the image past the table.
+func patchBootInfoTable(image []byte, lba uint32) {
+	var sum uint32
+	for i := 64; i < len(image); i += 4 {
+		var word [4]byte
+		copy(word[:], image[i:])
+		sum += binary.LittleEndian.Uint32(word[:])
+	}
+	binary.LittleEndian.PutUint32(image[8:], 16)
+	binary.LittleEndian.PutUint32(image[12:], lba)
+	binary.LittleEndian.PutUint32(image[16:], uint32(len(image)))
+	binary.LittleEndian.PutUint32(image[20:], sum)
+	clear(image[24:64])
+}
@@ -0,0 +1,406 @@
+// Copyright 2025 Juan Font
+// BSD-3-Clause
+
+package udf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+)
+
+// Image is a UDF file system opened for reading
+type Image struct {
+	r          io.ReaderAt
+	blockSize  int64
+	partitions []int64 // start of each partition map, in bytes
+	volumeID   string
+	fileSet    longAD
+	root       longAD
+}
+
+// File is a file or directory of a UDF image
+type File struct {
+	Path    string // slash separated, without a leading slash
+	IsDir   bool
+	Size    int64
+	ModTime time.Time
+	Link    string // target of a symbolic link, empty for other files
+
+	img      *Image
+	icb      longAD // of the file entry
+	uniqueID uint64
+	extents  []extent
+	embedded []byte
+}
+
+// extent is a run of file data. Unrecorded extents read as zeros.
+type extent struct {
+	offset   int64 // absolute, in bytes
+	length   int64
+	recorded bool
+}
+
+// longAD is a UDF long allocation descriptor, an extent in any partition
+type longAD struct {
+	length    uint32
+	block     uint32
+	partition uint16
+}
+
+func parseLongAD(b []byte) longAD {
+	return longAD{
+		length:    binary.LittleEndian.Uint32(b[0:]),
+		block:     binary.LittleEndian.Uint32(b[4:]),
+		partition: binary.LittleEndian.Uint16(b[8:]),
+	}
+}
+
+// Open reads the volume descriptors of the UDF file system in r
+func Open(r io.ReaderAt) (*Image, error) {
+	anchor := make([]byte, 512)
+	if _, err := r.ReadAt(anchor, anchorSector*SectorSize); err != nil {
+		return nil, fmt.Errorf("failed to read anchor volume descriptor pointer: %w", err)
+	}
+	if t, ok := parseTag(anchor); !ok || t.id != tagAnchorVolumePointer {
+		return nil, errors.New("no UDF anchor volume descriptor pointer at sector 256")
+	}
+	vdsLength := int64(binary.LittleEndian.Uint32(anchor[16:]))
+	vdsSector := int64(binary.LittleEndian.Uint32(anchor[20:]))
+
+	img := &Image{r: r}
+	partitionStarts := make(map[uint16]int64)
+	var lvd []byte
+	buf := make([]byte, SectorSize)
+	for i := int64(0); i < vdsLength/SectorSize; i++ {
+		if _, err := r.ReadAt(buf, (vdsSector+i)*SectorSize); err != nil {
+			return nil, fmt.Errorf("failed to read volume descriptor sequence: %w", err)
+		}
+		t, ok := parseTag(buf)
+		if !ok || t.id == tagTerminating {
+			break
+		}
+		switch t.id {
+		case tagPrimaryVolume:
+			if img.volumeID == "" {
+				img.volumeID = decodeDString(buf[24:56])
+			}
+		case tagPartition:
+			number := binary.LittleEndian.Uint16(buf[22:])
+			start := int64(binary.LittleEndian.Uint32(buf[188:]))
+			partitionStarts[number] = start * SectorSize
+		case tagLogicalVolume:
+			lvd = append([]byte(nil), buf...)
+		}
+	}
+	if lvd == nil {
+		return nil, errors.New("no UDF logical volume descriptor found")
+	}
+
+	img.blockSize = int64(binary.LittleEndian.Uint32(lvd[212:]))
+	if img.blockSize != SectorSize {
+		return nil, fmt.Errorf("unsupported UDF logical block size %d", img.blockSize)
+	}
+	if id := decodeDString(lvd[84:212]); id != "" {
+		img.volumeID = id
+	}
+
+	// Only type 1 partition maps are supported. Type 2 maps hold the
+	// virtual, sparable and metadata partitions of UDF 1.50 and later,
+	// which install media does not use.
+	mapTableLength := int(binary.LittleEndian.Uint32(lvd[264:]))
+	maps := int(binary.LittleEndian.Uint32(lvd[268:]))
+	if mapTableLength < 0 || 440+mapTableLength > len(lvd) {
+		return nil, errors.New("invalid UDF partition map table")
+	}
+	table := lvd[440 : 440+mapTableLength]
+	for i := 0; i < maps; i++ {
+		if len(table) < 2 || int(table[1]) > len(table) || table[1] == 0 {
+			return nil, errors.New("invalid UDF partition map table")
+		}
+		if table[0] != 1 {
+			return nil, fmt.Errorf("unsupported UDF partition map type %d", table[0])
+		}
+		// Type 1 maps are 6 bytes, ending with the partition number
+		if table[1] < 6 {
+			return nil, errors.New("invalid UDF partition map table")
+		}
+		start, ok := partitionStarts[binary.LittleEndian.Uint16(table[4:])]
+		if !ok {
+			return nil, fmt.Errorf("UDF partition %d not found", binary.LittleEndian.Uint16(table[4:]))
+		}
+		img.partitions = append(img.partitions, start)
+		table = table[table[1]:]
+	}
+
+	img.fileSet = parseLongAD(lvd[248:])
+	fsd, err := img.readDescriptor(img.fileSet, tagFileSet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file set descriptor: %w", err)
+	}
+	img.root = parseLongAD(fsd[400:])
+	return img, nil
+}
+
+// VolumeID returns the logical volume identifier of the image
+func (img *Image) VolumeID() string {
+	return img.volumeID
+}
+
+// offset returns the absolute offset of a block of a partition
+func (img *Image) offset(partition uint16, block uint32) (int64, error) {
+	if int(partition) >= len(img.partitions) {
+		return 0, fmt.Errorf("invalid UDF partition reference %d", partition)
+	}
+	return img.partitions[partition] + int64(block)*img.blockSize, nil
+}
+
+// readDescriptor reads the block at ad and checks its tag
+func (img *Image) readDescriptor(ad longAD, id uint16) ([]byte, error) {
+	off, err := img.offset(ad.partition, ad.block)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, img.blockSize)
+	if _, err := img.r.ReadAt(b, off); err != nil {
+		return nil, err
+	}
+	t, ok := parseTag(b)
+	if !ok {
+		return nil, fmt.Errorf("invalid descriptor tag at block %d", ad.block)
+	}
+	if t.id != id && !(id == tagFileEntry && t.id == tagExtendedFileEntry) {
+		return nil, fmt.Errorf("unexpected descriptor %d at block %d, expected %d", t.id, ad.block, id)
+	}
+	return b, nil
+}
+
+// Walk calls fn for every file and directory of the image, parents before
+// their children. Deleted entries are skipped.
+func (img *Image) Walk(fn func(f *File) error) error {
+	root, err := img.readFile("", img.root)
+	if err != nil {
+		return fmt.Errorf("failed to read root directory: %w", err)
+	}
+	if !root.IsDir {
+		return errors.New("UDF root is not a directory")
+	}
+	return img.walk(root, map[longAD]bool{img.root: true}, fn)
+}
+
+func (img *Image) walk(dir *File, seen map[longAD]bool, fn func(f *File) error) error {
+	data, err := io.ReadAll(dir.Open())
+	if err != nil {
+		return fmt.Errorf("failed to read directory %s: %w", dir.Path, err)
+	}
+
+	for len(data) > 0 {
+		if len(data) < 38 {
+			return fmt.Errorf("truncated file identifier in directory %s", dir.Path)
+		}
+		t, ok := parseTag(data)
+		if !ok || t.id != tagFileIdentifier {
+			return fmt.Errorf("invalid file identifier in directory %s", dir.Path)
+		}
+		characteristics := data[18]
+		nameLength := int(data[19])
+		icb := parseLongAD(data[20:])
+		implUseLength := int(binary.LittleEndian.Uint16(data[36:]))
+		size := (38 + implUseLength + nameLength + 3) &^ 3
+		if size > len(data) {
+			return fmt.Errorf("truncated file identifier in directory %s", dir.Path)
+		}
+		name := decodeCS0(data[38+implUseLength : 38+implUseLength+nameLength])
+		data = data[size:]
+
+		if characteristics&(fidParent|fidDeleted) != 0 {
+			continue
+		}
+		if name == "" {
+			return fmt.Errorf("empty file name in directory %s", dir.Path)
+		}
+
+		f, err := img.readFile(path.Join(dir.Path, name), icb)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path.Join(dir.Path, name), err)
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+		if f.IsDir {
+			if seen[icb] {
+				return fmt.Errorf("directory loop at %s", f.Path)
+			}
+			seen[icb] = true
+			if err := img.walk(f, seen, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readFile reads the (extended) file entry at icb
+func (img *Image) readFile(name string, icb longAD) (*File, error) {
+	b, err := img.readDescriptor(icb, tagFileEntry)
+	if err != nil {
+		return nil, err
+	}
+	t, _ := parseTag(b)
+
+	f := &File{Path: name, img: img, icb: icb}
+	fileType := b[16+11]
+	switch fileType {
+	case fileTypeDirectory:
+		f.IsDir = true
+	case fileTypeRegular, fileTypeSymlink, 0:
+	default:
+		return nil, fmt.Errorf("unsupported file type %d", fileType)
+	}
+	if strategy := binary.LittleEndian.Uint16(b[16+4:]); strategy != 4 {
+		return nil, fmt.Errorf("unsupported ICB strategy %d", strategy)
+	}
+	allocType := binary.LittleEndian.Uint16(b[16+18:]) & 7
+	f.Size = int64(binary.LittleEndian.Uint64(b[56:]))
+	if f.Size < 0 {
+		return nil, fmt.Errorf("invalid file size %d", uint64(f.Size))
+	}
+
+	var eaLength, adLength, adStart int
+	if t.id == tagExtendedFileEntry {
+		f.ModTime = decodeTimestamp(b[92:])
+		f.uniqueID = binary.LittleEndian.Uint64(b[200:])
+		eaLength = int(binary.LittleEndian.Uint32(b[208:]))
+		adLength = int(binary.LittleEndian.Uint32(b[212:]))
+		adStart = 216 + eaLength
+	} else {
+		f.ModTime = decodeTimestamp(b[84:])
+		f.uniqueID = binary.LittleEndian.Uint64(b[160:])
+		eaLength = int(binary.LittleEndian.Uint32(b[168:]))
+		adLength = int(binary.LittleEndian.Uint32(b[172:]))
+		adStart = fileEntryHeaderSize + eaLength
+	}
+	if eaLength < 0 || adLength < 0 || adStart+adLength > len(b) {
+		return nil, errors.New("allocation descriptors overflow the file entry")
+	}
+	ads := b[adStart : adStart+adLength]
+
+	if allocType == allocEmbedded {
+		if int64(len(ads)) < f.Size {
+			return nil, errors.New("embedded data shorter than the file")
+		}
+		f.embedded = ads[:f.Size]
+	} else if err := img.readExtents(f, ads, allocType, icb.partition); err != nil {
+		return nil, err
+	}
+
+	if fileType == fileTypeSymlink {
+		data, err := io.ReadAll(f.Open())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read link: %w", err)
+		}
+		if f.Link, err = decodeSymlink(data); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// readExtents decodes the allocation descriptors of a file, following
+// continuation extents.
+func (img *Image) readExtents(f *File, ads []byte, allocType uint16, partition uint16) error {
+	var adSize int
+	switch allocType {
+	case allocShort:
+		adSize = shortADSize
+	case allocLong:
+		adSize = longADSize
+	default:
+		return fmt.Errorf("unsupported allocation descriptor type %d", allocType)
+	}
+
+	var total int64
+	for continuations := 0; ; continuations++ {
+		var next *longAD
+		for len(ads) >= adSize {
+			rawLength := binary.LittleEndian.Uint32(ads[0:])
+			ad := longAD{
+				length:    rawLength & extentLengthMask,
+				block:     binary.LittleEndian.Uint32(ads[4:]),
+				partition: partition,
+			}
+			if allocType == allocLong {
+				ad.partition = binary.LittleEndian.Uint16(ads[8:])
+			}
+			ads = ads[adSize:]
+			if ad.length == 0 {
+				break
+			}
+
+			kind := rawLength >> 30
+			if kind == extentContinuation {
+				next = &ad
+				break
+			}
+			off, err := img.offset(ad.partition, ad.block)
+			if err != nil {
+				return err
+			}
+			f.extents = append(f.extents, extent{
+				offset:   off,
+				length:   int64(ad.length),
+				recorded: kind == extentRecorded,
+			})
+			total += int64(ad.length)
+		}
+		if next == nil {
+			break
+		}
+		if continuations > 1024 {
+			return errors.New("too many allocation extents")
+		}
+
+		b, err := img.readDescriptor(*next, tagAllocationExtent)
+		if err != nil {
+			return fmt.Errorf("failed to read allocation extent: %w", err)
+		}
+		length := int(binary.LittleEndian.Uint32(b[20:]))
+		if length < 0 || 24+length > len(b) {
+			return errors.New("allocation extent overflows its block")
+		}
+		ads = b[24 : 24+length]
+	}
+
+	if total < f.Size {
+		return fmt.Errorf("file extents hold %d bytes, expected %d", total, f.Size)
+	}
+	return nil
+}
+
+// Open returns a reader of the file contents
+func (f *File) Open() io.Reader {
+	if f.embedded != nil {
+		return bytes.NewReader(f.embedded)
+	}
+	readers := make([]io.Reader, 0, len(f.extents))
+	for _, e := range f.extents {
+		if e.recorded {
+			readers = append(readers, io.NewSectionReader(f.img.r, e.offset, e.length))
+		} else {
+			readers = append(readers, io.LimitReader(zeros{}, e.length))
+		}
+	}
+	return io.LimitReader(io.MultiReader(readers...), f.Size)
+}
+
+// zeros reads as an endless run of zero bytes
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
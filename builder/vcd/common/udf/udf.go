@@ -0,0 +1,365 @@
+// Copyright 2025 Juan Font
+// BSD-3-Clause
+
+// Package udf reads and writes the UDF file systems of Windows install
+// ISOs, so they can be rebuilt without external tools.
+//
+// Images are written as UDF 1.02 bridge images, the layout Microsoft uses:
+// the ISO 9660 side only carries the El Torito boot records, and all files
+// live in the UDF file system.
+package udf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// SectorSize is the sector size of optical media, and the logical block
+// size of the images written.
+const SectorSize = 2048
+
+// Descriptor tag identifiers (ECMA-167 3/7.2.1 and 4/7.2.1)
+const (
+	tagPrimaryVolume       = 1
+	tagAnchorVolumePointer = 2
+	tagImplementationUse   = 4
+	tagPartition           = 5
+	tagLogicalVolume       = 6
+	tagUnallocatedSpace    = 7
+	tagTerminating         = 8
+	tagIntegrity           = 9
+	tagFileSet             = 256
+	tagFileIdentifier      = 257
+	tagAllocationExtent    = 258
+	tagFileEntry           = 261
+	tagExtendedFileEntry   = 266
+)
+
+// anchorSector is where the first Anchor Volume Descriptor Pointer lives
+const anchorSector = 256
+
+// File characteristics of File Identifier Descriptors
+const (
+	fidHidden    = 0x01
+	fidDirectory = 0x02
+	fidDeleted   = 0x04
+	fidParent    = 0x08
+)
+
+// ICB file types
+const (
+	fileTypeDirectory = 4
+	fileTypeRegular   = 5
+	fileTypeSymlink   = 12
+)
+
+// Path component types of symbolic link targets (ECMA-167 4/14.16.1)
+const (
+	componentRoot    = 2
+	componentParent  = 3
+	componentCurrent = 4
+	componentName    = 5
+)
+
+// Allocation descriptor types, from the ICB tag flags
+const (
+	allocShort    = 0
+	allocLong     = 1
+	allocExtended = 2
+	allocEmbedded = 3
+)
+
+// Extent types, from the two high bits of an allocation descriptor length
+const (
+	extentRecorded      = 0
+	extentNotRecorded   = 1
+	extentNotAllocated  = 2
+	extentContinuation  = 3
+	extentLengthMask    = 0x3FFFFFFF
+	maxExtentLength     = extentLengthMask &^ (SectorSize - 1)
+	shortADSize         = 8
+	longADSize          = 16
+	fileEntryHeaderSize = 176
+)
+
+// crcTable is the CRC-16/CCITT table used for descriptor CRCs
+var crcTable = func() [256]uint16 {
+	var table [256]uint16
+	for i := range table {
+		crc := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+func crc16(b []byte) uint16 {
+	var crc uint16
+	for _, c := range b {
+		crc = crc<<8 ^ crcTable[byte(crc>>8)^c]
+	}
+	return crc
+}
+
+// tag is the 16 byte header of every UDF descriptor
+type tag struct {
+	id        uint16
+	version   uint16
+	crcLength uint16
+	location  uint32
+}
+
+func parseTag(b []byte) (tag, bool) {
+	if len(b) < 16 {
+		return tag{}, false
+	}
+	var sum byte
+	for i := 0; i < 16; i++ {
+		if i != 4 {
+			sum += b[i]
+		}
+	}
+	if sum != b[4] {
+		return tag{}, false
+	}
+	t := tag{
+		id:        binary.LittleEndian.Uint16(b[0:]),
+		version:   binary.LittleEndian.Uint16(b[2:]),
+		crcLength: binary.LittleEndian.Uint16(b[10:]),
+		location:  binary.LittleEndian.Uint32(b[12:]),
+	}
+	if int(t.crcLength)+16 <= len(b) && crc16(b[16:16+int(t.crcLength)]) != binary.LittleEndian.Uint16(b[8:]) {
+		return tag{}, false
+	}
+	return t, true
+}
+
+// putTag fills in the tag of the descriptor b, whose CRC covers everything
+// after the tag.
+func putTag(b []byte, id uint16, location uint32) {
+	binary.LittleEndian.PutUint16(b[0:], id)
+	binary.LittleEndian.PutUint16(b[2:], 2) // NSR02
+	binary.LittleEndian.PutUint16(b[6:], 1)
+	binary.LittleEndian.PutUint16(b[8:], crc16(b[16:]))
+	binary.LittleEndian.PutUint16(b[10:], uint16(len(b)-16))
+	binary.LittleEndian.PutUint32(b[12:], location)
+	b[4] = tagChecksum(b)
+}
+
+// retag updates the CRC and checksum of a descriptor read from an image and
+// changed, keeping the rest of its tag
+func retag(b []byte) {
+	crcLength := min(int(binary.LittleEndian.Uint16(b[10:])), len(b)-16)
+	binary.LittleEndian.PutUint16(b[8:], crc16(b[16:16+crcLength]))
+	b[4] = tagChecksum(b)
+}
+
+func tagChecksum(b []byte) byte {
+	var sum byte
+	for i := 0; i < 16; i++ {
+		if i != 4 {
+			sum += b[i]
+		}
+	}
+	return sum
+}
+
+// decodeCS0 decodes OSTA Compressed Unicode, the encoding of UDF names
+func decodeCS0(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	switch b[0] {
+	case 8:
+		r := make([]rune, 0, len(b)-1)
+		for _, c := range b[1:] {
+			r = append(r, rune(c))
+		}
+		return string(r)
+	case 16:
+		u := make([]uint16, 0, len(b)/2)
+		for i := 1; i+1 < len(b); i += 2 {
+			u = append(u, binary.BigEndian.Uint16(b[i:]))
+		}
+		return string(utf16.Decode(u))
+	}
+	return ""
+}
+
+// encodeCS0 encodes s as OSTA Compressed Unicode, with 8 bits per character
+// when possible.
+func encodeCS0(s string) []byte {
+	compact := true
+	for _, r := range s {
+		if r > 0xFF {
+			compact = false
+			break
+		}
+	}
+	if compact {
+		b := []byte{8}
+		for _, r := range s {
+			b = append(b, byte(r))
+		}
+		return b
+	}
+	b := []byte{16}
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = binary.BigEndian.AppendUint16(b, u)
+	}
+	return b
+}
+
+// decodeDString decodes a fixed size dstring field, whose last byte holds
+// the length used.
+func decodeDString(b []byte) string {
+	n := int(b[len(b)-1])
+	if n == 0 || n >= len(b) {
+		return ""
+	}
+	return decodeCS0(b[:n])
+}
+
+// putDString encodes s into the fixed size dstring field b, truncating it
+// when it does not fit.
+func putDString(b []byte, s string) {
+	if s == "" {
+		return
+	}
+	enc := encodeCS0(s)
+	if len(enc) > len(b)-1 {
+		enc = enc[:len(b)-1]
+		if enc[0] == 16 && len(enc)%2 == 0 {
+			enc = enc[:len(enc)-1]
+		}
+	}
+	copy(b, enc)
+	b[len(b)-1] = byte(len(enc))
+}
+
+// encodeSymlink encodes the target of a symbolic link as the path
+// components stored as the data of the link.
+func encodeSymlink(target string) ([]byte, error) {
+	var b []byte
+	if strings.HasPrefix(target, "/") {
+		b = append(b, componentRoot, 0, 0, 0)
+	}
+	for _, name := range strings.Split(target, "/") {
+		switch name {
+		case "":
+		case "..":
+			b = append(b, componentParent, 0, 0, 0)
+		case ".":
+			b = append(b, componentCurrent, 0, 0, 0)
+		default:
+			enc := encodeCS0(name)
+			if len(enc) > 255 {
+				return nil, fmt.Errorf("link target component too long: %s", name)
+			}
+			b = append(b, componentName, byte(len(enc)), 0, 0)
+			b = append(b, enc...)
+		}
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("invalid link target %q", target)
+	}
+	return b, nil
+}
+
+// decodeSymlink decodes the path components of a symbolic link into its
+// target.
+func decodeSymlink(b []byte) (string, error) {
+	var parts []string
+	root := false
+	for len(b) > 0 {
+		if len(b) < 4 || len(b) < 4+int(b[1]) {
+			return "", errors.New("truncated link target")
+		}
+		componentType, id := b[0], b[4:4+int(b[1])]
+		b = b[4+len(id):]
+		switch componentType {
+		case componentRoot:
+			root = true
+			parts = nil
+		case componentParent:
+			parts = append(parts, "..")
+		case componentCurrent:
+			parts = append(parts, ".")
+		case componentName:
+			parts = append(parts, decodeCS0(id))
+		default:
+			return "", fmt.Errorf("unsupported link target component type %d", componentType)
+		}
+	}
+	target := strings.Join(parts, "/")
+	if root {
+		target = "/" + target
+	}
+	return target, nil
+}
+
+// putCharspec writes the OSTA CS0 character set specification
+func putCharspec(b []byte) {
+	b[0] = 0
+	copy(b[1:], "OSTA Compressed Unicode")
+}
+
+// putRegID writes an entity identifier with the given suffix
+func putRegID(b []byte, id string, suffix ...byte) {
+	copy(b[1:24], id)
+	copy(b[24:32], suffix)
+}
+
+// udfRevision is the UDF revision of the images written
+const udfRevision = 0x0102
+
+// udfSuffix is the suffix of UDF entity identifiers: the UDF revision, and
+// no operating system class.
+var udfSuffix = []byte{udfRevision & 0xFF, udfRevision >> 8}
+
+// implementationID identifies this package as the writer of an image
+const implementationID = "*packer-plugin-vcd"
+
+// decodeTimestamp decodes a 12 byte UDF timestamp
+func decodeTimestamp(b []byte) time.Time {
+	typeAndZone := binary.LittleEndian.Uint16(b[0:])
+	year := int(int16(binary.LittleEndian.Uint16(b[2:])))
+	if year == 0 {
+		return time.Time{}
+	}
+	loc := time.UTC
+	if typeAndZone>>12 == 1 {
+		offset := int16(typeAndZone<<4) >> 4 // sign extend 12 bits
+		if offset != -2047 {
+			loc = time.FixedZone("", int(offset)*60)
+		}
+	}
+	nsec := int(b[9])*10_000_000 + int(b[10])*100_000 + int(b[11])*1_000
+	return time.Date(year, time.Month(b[4]), int(b[5]), int(b[6]), int(b[7]), int(b[8]), nsec, loc)
+}
+
+// putTimestamp writes t as a 12 byte UDF timestamp in UTC
+func putTimestamp(b []byte, t time.Time) {
+	t = t.UTC()
+	binary.LittleEndian.PutUint16(b[0:], 1<<12) // local time, UTC offset 0
+	binary.LittleEndian.PutUint16(b[2:], uint16(t.Year()))
+	b[4] = byte(t.Month())
+	b[5] = byte(t.Day())
+	b[6] = byte(t.Hour())
+	b[7] = byte(t.Minute())
+	b[8] = byte(t.Second())
+	ns := t.Nanosecond()
+	b[9] = byte(ns / 10_000_000)
+	b[10] = byte(ns / 100_000 % 100)
+	b[11] = byte(ns / 1_000 % 100)
+}
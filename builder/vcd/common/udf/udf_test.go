@@ -0,0 +1,346 @@
+// Copyright 2025 Juan Font
+// BSD-3-Clause
+
+package udf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"maps"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testModTime = time.Date(2025, 3, 14, 15, 9, 26, 0, time.UTC)
+
+// testDirs and testFiles are the contents of the image built by
+// writeTestImage.
+var testDirs = []string{"boot", "efi", "efi/microsoft", "efi/microsoft/boot", "sources"}
+
+var testFiles = map[string]string{
+	"boot/etfsboot.com":             strings.Repeat("B", 4096),
+	"efi/microsoft/boot/efisys.bin": strings.Repeat("E", 1440*1024),
+	"sources/boot.wim":              strings.Repeat("0123456789abcdef", 20000),
+	"sources/ei.cfg":                "[Channel]\r\nRetail\r\n",
+	"sources/empty.txt":             "",
+	"Autounattend.xml":              "<unattend/>",
+	"Ünïcode ファイル.txt":              "unicode",
+}
+
+// testContents returns the contents of the image built by writeTestImage,
+// as read by readImage.
+func testContents() map[string]string {
+	contents := maps.Clone(testFiles)
+	for _, p := range testDirs {
+		contents[p] = ""
+	}
+	contents["sources/latest.wim"] = "->boot.wim"
+	return contents
+}
+
+func writeTestImage(t *testing.T) []byte {
+	t.Helper()
+
+	w := NewWriter("CCCOMA_X64FRE_EN-US_DV9")
+	for _, p := range testDirs {
+		if err := w.AddDir(p, testModTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for p, content := range testFiles {
+		content := content
+		err := w.AddFile(p, int64(len(content)), testModTime, func() (io.Reader, error) {
+			return strings.NewReader(content), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.AddSymlink("sources/latest.wim", "boot.wim", testModTime); err != nil {
+		t.Fatal(err)
+	}
+	w.SetBoot(BootOptions{
+		BIOSImage:    "boot/etfsboot.com",
+		BIOSLoadSize: 8,
+		UEFIImage:    "efi/microsoft/boot/efisys.bin",
+	})
+
+	var buf bytes.Buffer
+	n, err := w.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) || n%SectorSize != 0 {
+		t.Fatalf("WriteTo() = %d, wrote %d bytes", n, buf.Len())
+	}
+	return buf.Bytes()
+}
+
+// readImage returns the files of an image by path, with the contents of
+// regular files, and the targets of links prefixed with "->".
+func readImage(t *testing.T, b []byte) map[string]string {
+	t.Helper()
+
+	img, err := Open(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	err = img.Walk(func(f *File) error {
+		// Directories created for the files appended are dated now
+		if !f.IsDir && !f.ModTime.Equal(testModTime) {
+			t.Errorf("%s: ModTime = %s, want %s", f.Path, f.ModTime, testModTime)
+		}
+		switch {
+		case f.IsDir:
+			files[f.Path] = ""
+		case f.Link != "":
+			files[f.Path] = "->" + f.Link
+		default:
+			data, err := io.ReadAll(f.Open())
+			if err != nil {
+				return err
+			}
+			if int64(len(data)) != f.Size {
+				t.Errorf("%s: read %d bytes, Size = %d", f.Path, len(data), f.Size)
+			}
+			files[f.Path] = string(data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestRoundTrip(t *testing.T) {
+	b := writeTestImage(t)
+
+	img, err := Open(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := img.VolumeID(); got != "CCCOMA_X64FRE_EN-US_DV9" {
+		t.Errorf("VolumeID() = %q", got)
+	}
+
+	want := testContents()
+	got := readImage(t, b)
+	for p, content := range want {
+		if got[p] != content {
+			t.Errorf("%s: got %d bytes, want %d", p, len(got[p]), len(content))
+		}
+	}
+	for p := range got {
+		if _, ok := want[p]; !ok {
+			t.Errorf("unexpected file %s", p)
+		}
+	}
+}
+
+func TestRoundTrip_BootRecords(t *testing.T) {
+	b := writeTestImage(t)
+	img, err := Open(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	boot, err := img.bootSectors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = img.Walk(func(f *File) error {
+		isBoot := f.Path == "boot/etfsboot.com" || f.Path == "efi/microsoft/boot/efisys.bin"
+		if len(f.extents) > 0 && boot[f.extents[0].offset/SectorSize] != isBoot {
+			t.Errorf("%s: boot image = %v, want %v", f.Path, !isBoot, isBoot)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// memImage is an image being appended to
+type memImage struct {
+	b []byte
+}
+
+func (m *memImage) WriteAt(p []byte, off int64) (int, error) {
+	if end := off + int64(len(p)); end > int64(len(m.b)) {
+		m.b = append(m.b, make([]byte, end-int64(len(m.b)))...)
+	}
+	copy(m.b[off:], p)
+	return len(p), nil
+}
+
+func TestAppend(t *testing.T) {
+	src := writeTestImage(t)
+	img, err := Open(bytes.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewAppender(img, int64(len(src)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	added := []struct {
+		path, content string
+	}{
+		{"Autounattend.xml", "<unattend><settings/></unattend>"},
+		{"sources/$OEM$/setup.cmd", strings.Repeat("echo\r\n", 1000)},
+		{"sources/$oem$/drivers.inf", ""},
+	}
+	for _, f := range added {
+		content := f.content
+		err := a.AddFile(f.path, int64(len(content)), testModTime, func() (io.Reader, error) {
+			return strings.NewReader(content), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	a.SetVolumeID("PACKER")
+
+	out := &memImage{b: bytes.Clone(src)}
+	size, err := a.Append(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(out.b)) {
+		t.Fatalf("Append() = %d, image is %d bytes", size, len(out.b))
+	}
+
+	appended, err := Open(bytes.NewReader(out.b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := appended.VolumeID(); got != "PACKER" {
+		t.Errorf("VolumeID() = %q, want PACKER", got)
+	}
+
+	want := testContents()
+	want["sources/$OEM$"] = ""
+	// Added to the directory created by the first file, matched
+	// case-insensitively
+	want["sources/$OEM$/setup.cmd"] = added[1].content
+	want["sources/$OEM$/drivers.inf"] = ""
+	want["Autounattend.xml"] = added[0].content
+	got := readImage(t, out.b)
+	if len(got) != len(want) {
+		t.Errorf("got %d files, want %d", len(got), len(want))
+	}
+	for p, content := range want {
+		if c, ok := got[p]; !ok || c != content {
+			t.Errorf("%s: got %q (found %v), want %d bytes", p, truncate(c), ok, len(content))
+		}
+	}
+}
+
+func truncate(s string) string {
+	if len(s) > 32 {
+		return s[:32] + "..."
+	}
+	return s
+}
+
+func TestOpen_Truncated(t *testing.T) {
+	b := writeTestImage(t)
+
+	// Cut before the end of the descriptors and directories
+	for _, size := range []int{0, 100, 16 * SectorSize, vdsSector * SectorSize, anchorSector * SectorSize, (partitionSector + 2) * SectorSize} {
+		img, err := Open(bytes.NewReader(b[:size]))
+		if err == nil {
+			err = img.Walk(func(*File) error { return nil })
+		}
+		if err == nil {
+			t.Errorf("image truncated to %d bytes: no error", size)
+		}
+	}
+
+	// Cut in the file data: the files read short, but nothing panics
+	for size := (partitionSector + 2) * SectorSize; size < len(b); size += 37 * SectorSize {
+		walkImage(bytes.NewReader(b[:size]))
+	}
+}
+
+// walkImage reads every file of an image, ignoring errors
+func walkImage(r io.ReaderAt) {
+	img, err := Open(r)
+	if err != nil {
+		return
+	}
+	_ = img.Walk(func(f *File) error {
+		_, err := io.Copy(io.Discard, f.Open())
+		return err
+	})
+}
+
+func TestOpen_Corrupt(t *testing.T) {
+	b := writeTestImage(t)
+
+	// Every descriptor and directory sector overwritten, with and without a
+	// valid tag, must fail cleanly
+	metaEnd := partitionSector + 64
+	for sector := 16; sector < metaEnd && sector*SectorSize < len(b); sector++ {
+		for _, fill := range []byte{0x00, 0xFF, 0x7F} {
+			corrupt := bytes.Clone(b)
+			s := corrupt[sector*SectorSize : (sector+1)*SectorSize]
+			for i := 16; i < len(s); i++ {
+				s[i] = fill
+			}
+			if _, ok := parseTag(s); ok {
+				retag(s)
+			}
+			walkImage(bytes.NewReader(corrupt))
+		}
+	}
+}
+
+func TestOpen_InvalidPartitionMap(t *testing.T) {
+	tests := []struct {
+		name       string
+		mapType    byte
+		mapLength  byte
+		tableBytes uint32
+	}{
+		{"table shorter than a type 1 map", 1, 2, 2},
+		{"map shorter than a type 1 map", 1, 2, 6},
+		{"table shorter than its map", 1, 6, 4},
+		{"empty map", 1, 0, 6},
+		{"table past the descriptor", 1, 6, SectorSize},
+		{"type 2 map", 2, 64, 64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := writeTestImage(t)
+			lvd := findDescriptor(t, b, tagLogicalVolume)
+			binary.LittleEndian.PutUint32(lvd[264:], tt.tableBytes)
+			lvd[440] = tt.mapType
+			lvd[441] = tt.mapLength
+			retag(lvd)
+
+			if _, err := Open(bytes.NewReader(b)); err == nil {
+				t.Error("Open() succeeded")
+			}
+		})
+	}
+}
+
+// findDescriptor returns the first descriptor of the main volume descriptor
+// sequence with the tag id, in b
+func findDescriptor(t *testing.T, b []byte, id uint16) []byte {
+	t.Helper()
+	for sector := vdsSector; sector < vdsSector+vdsSectors; sector++ {
+		s := b[sector*SectorSize : (sector+1)*SectorSize]
+		if tag, ok := parseTag(s); ok && tag.id == id {
+			return s
+		}
+	}
+	t.Fatalf("no descriptor %d in the volume descriptor sequence", id)
+	return nil
+}
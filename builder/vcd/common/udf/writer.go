@@ -0,0 +1,681 @@
+// Copyright 2025 Juan Font
+// BSD-3-Clause
+
+package udf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Layout of the sectors before the UDF partition. The ISO 9660 volume
+// descriptors and the UDF volume recognition sequence start at sector 16.
+const (
+	isoRootSector     = 24
+	pathTableLSector  = 25
+	pathTableMSector  = 26
+	bootCatalogSector = 27
+	vdsSector         = 32
+	reserveVDSSector  = 48
+	vdsSectors        = 16
+	integritySector   = 64
+	partitionSector   = anchorSector + 1
+)
+
+// BootOptions are the El Torito boot records of a written image. Images
+// are the paths of files of the image.
+type BootOptions struct {
+	BIOSImage       string
+	BIOSLoadSegment uint16 // 0 for the BIOS default, 0x07C0
+	BIOSLoadSize    uint16 // in 512 byte sectors, 0 for 4
+	BootInfoTable   bool   // patch a boot info table into the BIOS image
+	UEFIImage       string
+}
+
+// Writer builds a UDF bridge image from files added to it
+type Writer struct {
+	volumeID string
+	modTime  time.Time
+	root     *node
+	boot     BootOptions
+}
+
+// node is a file or directory of the image being written
+type node struct {
+	name     string
+	dir      bool
+	link     bool // a symbolic link, whose data is its target
+	children []*node
+	size     int64
+	modTime  time.Time
+	open     func() (io.Reader, error)
+
+	// Set when laying out the image
+	parent   *node
+	uniqueID uint64
+	entry    uint32 // partition block of the file entry
+	data     uint32 // first partition block of the data
+	dataSize int64
+
+	// Set for the files of an image appended to, see Appender
+	existing bool // in the source image, with its file entry at entry
+	changed  bool // a directory with new children
+}
+
+// NewWriter creates a writer of an empty image
+func NewWriter(volumeID string) *Writer {
+	now := time.Now()
+	return &Writer{
+		volumeID: volumeID,
+		modTime:  now,
+		root:     &node{dir: true, modTime: now},
+	}
+}
+
+// SetBoot sets the El Torito boot records of the image
+func (w *Writer) SetBoot(o BootOptions) {
+	w.boot = o
+}
+
+// AddDir adds a directory and its missing parents. Names are matched
+// case-insensitively, as Windows does.
+func (w *Writer) AddDir(p string, modTime time.Time) error {
+	n, err := w.lookup(p, true)
+	if err != nil {
+		return err
+	}
+	if !n.dir {
+		return fmt.Errorf("%s is a file", p)
+	}
+	n.modTime = modTime
+	return nil
+}
+
+// AddFile adds a file, replacing the file with the same path if any but
+// keeping its name. open is called while the image is written and must
+// return size bytes; the reader is closed afterwards if it is an io.Closer.
+func (w *Writer) AddFile(p string, size int64, modTime time.Time, open func() (io.Reader, error)) error {
+	return w.add(p, &node{size: size, modTime: modTime, open: open})
+}
+
+// AddSymlink adds a symbolic link to target, replacing the file with the
+// same path if any, as AddFile does.
+func (w *Writer) AddSymlink(p, target string, modTime time.Time) error {
+	data, err := encodeSymlink(target)
+	if err != nil {
+		return fmt.Errorf("%s: %w", p, err)
+	}
+	return w.add(p, &node{link: true, size: int64(len(data)), modTime: modTime, open: func() (io.Reader, error) {
+		return bytes.NewReader(data), nil
+	}})
+}
+
+// add adds the file f at p
+func (w *Writer) add(p string, f *node) error {
+	dir, name := splitPath(p)
+	if name == "" {
+		return fmt.Errorf("invalid file path %q", p)
+	}
+	if len(encodeCS0(name)) > 255 {
+		return fmt.Errorf("file name too long: %s", name)
+	}
+	parent, err := w.lookup(dir, true)
+	if err != nil {
+		return err
+	}
+	if !parent.dir {
+		return fmt.Errorf("%s is a file", dir)
+	}
+
+	f.name = name
+	if existing := parent.child(name); existing != nil {
+		if existing.dir {
+			return fmt.Errorf("%s is a directory", p)
+		}
+		// A replaced file of an image appended to keeps its file entry
+		f.name = existing.name
+		f.existing, f.entry, f.uniqueID = existing.existing, existing.entry, existing.uniqueID
+		*existing = *f
+		return nil
+	}
+	parent.children = append(parent.children, f)
+	parent.changed = true
+	return nil
+}
+
+// Lookup reports whether the image has a file or directory at p
+func (w *Writer) Lookup(p string) (isDir bool, ok bool) {
+	n, err := w.lookup(p, false)
+	if err != nil || n == nil {
+		return false, false
+	}
+	return n.dir, true
+}
+
+func splitPath(p string) (dir, name string) {
+	p = strings.Trim(p, "/")
+	if i := strings.LastIndex(p, "/"); i >= 0 {
+		return p[:i], p[i+1:]
+	}
+	return "", p
+}
+
+func (n *node) child(name string) *node {
+	for _, c := range n.children {
+		if strings.EqualFold(c.name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// lookup finds the node at p, creating missing directories when create is
+// set.
+func (w *Writer) lookup(p string, create bool) (*node, error) {
+	n := w.root
+	for _, name := range strings.Split(strings.Trim(p, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		if !n.dir {
+			return nil, fmt.Errorf("%s: not a directory", p)
+		}
+		c := n.child(name)
+		if c == nil {
+			if !create {
+				return nil, nil
+			}
+			if len(encodeCS0(name)) > 255 {
+				return nil, fmt.Errorf("directory name too long: %s", name)
+			}
+			c = &node{name: name, dir: true, modTime: w.modTime}
+			n.children = append(n.children, c)
+			n.changed = true
+		}
+		n = c
+	}
+	return n, nil
+}
+
+// layout is the placement of every descriptor and file of the image
+type layout struct {
+	meta            []*node // in block order: file entries and directory data
+	files           []*node // in block order: file data
+	partitionBlocks uint32
+	nextUniqueID    uint64
+	fileCount       uint32
+	dirCount        uint32
+	bios, uefi      *node
+}
+
+// sectors returns the number of sectors holding size bytes
+func sectors(size int64) int64 {
+	return (size + SectorSize - 1) / SectorSize
+}
+
+func fidSize(name string) int64 {
+	n := int64(38)
+	if name != "" {
+		n += int64(len(encodeCS0(name)))
+	}
+	return (n + 3) &^ 3
+}
+
+func (w *Writer) layout() (*layout, error) {
+	l := &layout{nextUniqueID: 16}
+	next := int64(2) // after the file set descriptor and its terminator
+
+	var place func(n *node)
+	place = func(n *node) {
+		n.entry = uint32(next)
+		next++
+		if n != w.root {
+			n.uniqueID = l.nextUniqueID
+			l.nextUniqueID++
+		}
+		l.meta = append(l.meta, n)
+		if !n.dir {
+			l.fileCount++
+			l.files = append(l.files, n)
+			return
+		}
+
+		l.dirCount++
+		sort.Slice(n.children, func(i, j int) bool { return n.children[i].name < n.children[j].name })
+		n.dataSize = fidSize("")
+		for _, c := range n.children {
+			c.parent = n
+			n.dataSize += fidSize(c.name)
+		}
+		n.data = uint32(next)
+		next += sectors(n.dataSize)
+		for _, c := range n.children {
+			place(c)
+		}
+	}
+	w.root.parent = w.root
+	place(w.root)
+
+	for _, f := range l.files {
+		// The allocation descriptors of a file must fit in its file entry
+		if (f.size+maxExtentLength-1)/maxExtentLength*shortADSize > SectorSize-fileEntryHeaderSize {
+			return nil, fmt.Errorf("file %s is too large", f.name)
+		}
+		f.data = uint32(next)
+		f.dataSize = f.size
+		next += sectors(f.size)
+		if next > math.MaxUint32-anchorSector*2 {
+			return nil, errors.New("image too large")
+		}
+	}
+	l.partitionBlocks = uint32(next)
+
+	var err error
+	if w.boot.BIOSImage != "" {
+		if l.bios, err = w.bootImage(w.boot.BIOSImage); err != nil {
+			return nil, err
+		}
+		if w.boot.BootInfoTable && l.bios.size < 64 {
+			return nil, fmt.Errorf("boot image %s is too small for a boot info table", w.boot.BIOSImage)
+		}
+	}
+	if w.boot.UEFIImage != "" {
+		if l.uefi, err = w.bootImage(w.boot.UEFIImage); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func (w *Writer) bootImage(p string) (*node, error) {
+	n, err := w.lookup(p, false)
+	if err != nil || n == nil {
+		return nil, fmt.Errorf("boot image %s not found in the ISO", p)
+	}
+	if n.dir || n.size == 0 {
+		return nil, fmt.Errorf("boot image %s is not a file", p)
+	}
+	return n, nil
+}
+
+// lba returns the absolute sector of a partition block
+func lba(block uint32) uint32 {
+	return partitionSector + block
+}
+
+// sectorWriter writes whole sectors, padding them with zeros
+type sectorWriter struct {
+	w       io.Writer
+	written int64
+}
+
+func (s *sectorWriter) write(b []byte) error {
+	n, err := s.w.Write(b)
+	s.written += int64(n)
+	if err != nil {
+		return err
+	}
+	return s.pad()
+}
+
+// pad fills the current sector with zeros
+func (s *sectorWriter) pad() error {
+	if rem := s.written % SectorSize; rem != 0 {
+		n, err := s.w.Write(make([]byte, SectorSize-rem))
+		s.written += int64(n)
+		return err
+	}
+	return nil
+}
+
+// seek writes zero sectors up to sector
+func (s *sectorWriter) seek(sector int64) error {
+	gap := sector*SectorSize - s.written
+	if gap < 0 {
+		return fmt.Errorf("sector %d already written", sector)
+	}
+	n, err := io.CopyN(s.w, zeros{}, gap)
+	s.written += n
+	return err
+}
+
+// WriteTo writes the image to out
+func (w *Writer) WriteTo(out io.Writer) (int64, error) {
+	l, err := w.layout()
+	if err != nil {
+		return 0, err
+	}
+	total := int64(partitionSector) + int64(l.partitionBlocks) + 1
+	s := &sectorWriter{w: out}
+
+	if err := w.writeVolumeDescriptors(s, l, total); err != nil {
+		return s.written, err
+	}
+
+	// The UDF partition: the file set, the directories and the file data
+	if err := s.write(w.fileSetDescriptor()); err != nil {
+		return s.written, err
+	}
+	if err := s.write(terminatingDescriptor(1)); err != nil {
+		return s.written, err
+	}
+	for _, n := range l.meta {
+		if err := s.write(fileEntry(n)); err != nil {
+			return s.written, err
+		}
+		if n.dir {
+			if err := s.write(directoryData(n)); err != nil {
+				return s.written, err
+			}
+		}
+	}
+	for _, f := range l.files {
+		if err := w.writeFileData(s, f, f == l.bios && w.boot.BootInfoTable); err != nil {
+			return s.written, fmt.Errorf("failed to write %s: %w", f.name, err)
+		}
+	}
+
+	if err := s.write(anchorVolumePointer(uint32(total - 1))); err != nil {
+		return s.written, err
+	}
+	return s.written, nil
+}
+
+func (w *Writer) writeVolumeDescriptors(s *sectorWriter, l *layout, total int64) error {
+	if err := s.seek(16); err != nil {
+		return err
+	}
+	descriptors := [][]byte{isoPrimaryVolumeDescriptor(w.volumeID, uint32(total), w.modTime)}
+	if l.bios != nil || l.uefi != nil {
+		descriptors = append(descriptors, bootRecordDescriptor())
+	}
+	descriptors = append(descriptors,
+		isoTerminatorDescriptor(),
+		recognitionDescriptor("BEA01"),
+		recognitionDescriptor("NSR02"),
+		recognitionDescriptor("TEA01"),
+	)
+	for _, d := range descriptors {
+		if err := s.write(d); err != nil {
+			return err
+		}
+	}
+
+	if err := s.seek(isoRootSector); err != nil {
+		return err
+	}
+	for _, d := range [][]byte{isoRootDirectory(w.modTime), pathTable(false), pathTable(true)} {
+		if err := s.write(d); err != nil {
+			return err
+		}
+	}
+	if l.bios != nil || l.uefi != nil {
+		if err := s.write(w.bootCatalog(l)); err != nil {
+			return err
+		}
+	}
+
+	// Main and reserve volume descriptor sequences
+	for _, start := range []uint32{vdsSector, reserveVDSSector} {
+		if err := s.seek(int64(start)); err != nil {
+			return err
+		}
+		for _, d := range w.volumeDescriptorSequence(start, l.partitionBlocks) {
+			if err := s.write(d); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := s.seek(integritySector); err != nil {
+		return err
+	}
+	if err := s.write(w.integrityDescriptor(l)); err != nil {
+		return err
+	}
+	if err := s.write(terminatingDescriptor(integritySector + 1)); err != nil {
+		return err
+	}
+
+	if err := s.seek(anchorSector); err != nil {
+		return err
+	}
+	return s.write(anchorVolumePointer(anchorSector))
+}
+
+// writeFileData copies the contents of f, patching in a boot info table
+// when asked for.
+func (w *Writer) writeFileData(s *sectorWriter, f *node, bootInfoTable bool) error {
+	if f.size == 0 {
+		return nil
+	}
+	r, err := f.open()
+	if err != nil {
+		return err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	if bootInfoTable {
+		data := make([]byte, f.size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		patchBootInfoTable(data, lba(f.data))
+		return s.write(data)
+	}
+
+	n, err := io.CopyN(s.w, r, f.size)
+	s.written += n
+	if err == io.EOF {
+		return fmt.Errorf("file is %d bytes, expected %d", n, f.size)
+	}
+	if err != nil {
+		return err
+	}
+	return s.pad()
+}
+
+func anchorVolumePointer(location uint32) []byte {
+	b := make([]byte, 512)
+	binary.LittleEndian.PutUint32(b[16:], vdsSectors*SectorSize)
+	binary.LittleEndian.PutUint32(b[20:], vdsSector)
+	binary.LittleEndian.PutUint32(b[24:], vdsSectors*SectorSize)
+	binary.LittleEndian.PutUint32(b[28:], reserveVDSSector)
+	putTag(b, tagAnchorVolumePointer, location)
+	return b
+}
+
+func terminatingDescriptor(location uint32) []byte {
+	b := make([]byte, 512)
+	putTag(b, tagTerminating, location)
+	return b
+}
+
+// volumeDescriptorSequence returns the descriptors of a volume descriptor
+// sequence starting at sector start.
+func (w *Writer) volumeDescriptorSequence(start uint32, partitionBlocks uint32) [][]byte {
+	pvd := make([]byte, 512)
+	binary.LittleEndian.PutUint32(pvd[16:], 0) // sequence number
+	putDString(pvd[24:56], w.volumeID)
+	binary.LittleEndian.PutUint16(pvd[56:], 1) // volume sequence number
+	binary.LittleEndian.PutUint16(pvd[58:], 1)
+	binary.LittleEndian.PutUint16(pvd[60:], 2) // interchange level
+	binary.LittleEndian.PutUint16(pvd[62:], 2)
+	binary.LittleEndian.PutUint32(pvd[64:], 1) // CS0
+	binary.LittleEndian.PutUint32(pvd[68:], 1)
+	putDString(pvd[72:200], fmt.Sprintf("%016X%s", w.modTime.UnixNano(), w.volumeID))
+	putCharspec(pvd[200:])
+	putCharspec(pvd[264:])
+	putRegID(pvd[344:376], implementationID)
+	putTimestamp(pvd[376:], w.modTime)
+	putRegID(pvd[388:420], implementationID)
+	putTag(pvd, tagPrimaryVolume, start)
+
+	iuvd := make([]byte, 512)
+	binary.LittleEndian.PutUint32(iuvd[16:], 1)
+	putRegID(iuvd[20:52], "*UDF LV Info", udfSuffix...)
+	putCharspec(iuvd[52:])
+	putDString(iuvd[116:244], w.volumeID)
+	putRegID(iuvd[352:384], implementationID)
+	putTag(iuvd, tagImplementationUse, start+1)
+
+	pd := make([]byte, 512)
+	binary.LittleEndian.PutUint32(pd[16:], 2)
+	binary.LittleEndian.PutUint16(pd[20:], 1) // allocated
+	binary.LittleEndian.PutUint16(pd[22:], 0) // partition number
+	putRegID(pd[24:56], "+NSR02")
+	binary.LittleEndian.PutUint32(pd[184:], 1) // read only
+	binary.LittleEndian.PutUint32(pd[188:], partitionSector)
+	binary.LittleEndian.PutUint32(pd[192:], partitionBlocks)
+	putRegID(pd[196:228], implementationID)
+	putTag(pd, tagPartition, start+2)
+
+	lvd := make([]byte, 446)
+	binary.LittleEndian.PutUint32(lvd[16:], 3)
+	putCharspec(lvd[20:])
+	putDString(lvd[84:212], w.volumeID)
+	binary.LittleEndian.PutUint32(lvd[212:], SectorSize)
+	putRegID(lvd[216:248], "*OSTA UDF Compliant", udfSuffix...)
+	binary.LittleEndian.PutUint32(lvd[248:], SectorSize) // file set descriptor, block 0
+	binary.LittleEndian.PutUint32(lvd[264:], 6)          // partition map table length
+	binary.LittleEndian.PutUint32(lvd[268:], 1)          // partition maps
+	putRegID(lvd[272:304], implementationID)
+	binary.LittleEndian.PutUint32(lvd[432:], 2*SectorSize) // integrity sequence
+	binary.LittleEndian.PutUint32(lvd[436:], integritySector)
+	lvd[440] = 1 // type 1 partition map
+	lvd[441] = 6
+	binary.LittleEndian.PutUint16(lvd[442:], 1) // volume sequence number
+	binary.LittleEndian.PutUint16(lvd[444:], 0) // partition number
+	putTag(lvd, tagLogicalVolume, start+3)
+
+	usd := make([]byte, 24)
+	binary.LittleEndian.PutUint32(usd[16:], 4)
+	putTag(usd, tagUnallocatedSpace, start+4)
+
+	return [][]byte{pvd, iuvd, pd, lvd, usd, terminatingDescriptor(start + 5)}
+}
+
+func (w *Writer) integrityDescriptor(l *layout) []byte {
+	b := make([]byte, 134)
+	putTimestamp(b[16:], w.modTime)
+	binary.LittleEndian.PutUint32(b[28:], 1) // closed
+	binary.LittleEndian.PutUint64(b[40:], l.nextUniqueID)
+	binary.LittleEndian.PutUint32(b[72:], 1)  // partitions
+	binary.LittleEndian.PutUint32(b[76:], 46) // implementation use length
+	binary.LittleEndian.PutUint32(b[80:], 0)  // free blocks
+	binary.LittleEndian.PutUint32(b[84:], l.partitionBlocks)
+	putRegID(b[88:120], implementationID)
+	binary.LittleEndian.PutUint32(b[120:], l.fileCount)
+	binary.LittleEndian.PutUint32(b[124:], l.dirCount)
+	binary.LittleEndian.PutUint16(b[128:], udfRevision) // minimum read revision
+	binary.LittleEndian.PutUint16(b[130:], udfRevision) // minimum write revision
+	binary.LittleEndian.PutUint16(b[132:], udfRevision) // maximum write revision
+	putTag(b, tagIntegrity, integritySector)
+	return b
+}
+
+func (w *Writer) fileSetDescriptor() []byte {
+	b := make([]byte, 512)
+	putTimestamp(b[16:], w.modTime)
+	binary.LittleEndian.PutUint16(b[28:], 3) // interchange level
+	binary.LittleEndian.PutUint16(b[30:], 3)
+	binary.LittleEndian.PutUint32(b[32:], 1) // CS0
+	binary.LittleEndian.PutUint32(b[36:], 1)
+	putCharspec(b[48:])
+	putDString(b[112:240], w.volumeID)
+	putCharspec(b[240:])
+	putDString(b[304:336], w.volumeID)
+	binary.LittleEndian.PutUint32(b[400:], SectorSize) // root directory ICB
+	binary.LittleEndian.PutUint32(b[404:], w.root.entry)
+	putRegID(b[416:448], "*OSTA UDF Compliant", udfSuffix...)
+	putTag(b, tagFileSet, 0)
+	return b
+}
+
+// fileEntry returns the file entry of n, with short allocation descriptors
+// for its data.
+func fileEntry(n *node) []byte {
+	var ads []byte
+	block := n.data
+	for remaining := n.dataSize; remaining > 0; {
+		length := min(remaining, maxExtentLength)
+		ads = binary.LittleEndian.AppendUint32(ads, uint32(length))
+		ads = binary.LittleEndian.AppendUint32(ads, block)
+		block += uint32(sectors(length))
+		remaining -= length
+	}
+
+	b := make([]byte, fileEntryHeaderSize+len(ads))
+	binary.LittleEndian.PutUint16(b[16+4:], 4) // strategy type
+	binary.LittleEndian.PutUint16(b[16+8:], 1) // maximum entries
+	linkCount := uint16(1)
+	if n.dir {
+		b[16+11] = fileTypeDirectory
+		for _, c := range n.children {
+			if c.dir {
+				linkCount++
+			}
+		}
+	} else if n.link {
+		b[16+11] = fileTypeSymlink
+	} else {
+		b[16+11] = fileTypeRegular
+	}
+	binary.LittleEndian.PutUint16(b[16+18:], allocShort)
+	binary.LittleEndian.PutUint32(b[44:], 0x14A5) // read and execute for everyone
+	binary.LittleEndian.PutUint16(b[48:], linkCount)
+	binary.LittleEndian.PutUint64(b[56:], uint64(n.dataSize))
+	binary.LittleEndian.PutUint64(b[64:], uint64(sectors(n.dataSize)))
+	putTimestamp(b[72:], n.modTime)
+	putTimestamp(b[84:], n.modTime)
+	putTimestamp(b[96:], n.modTime)
+	binary.LittleEndian.PutUint32(b[108:], 1) // checkpoint
+	putRegID(b[128:160], implementationID)
+	binary.LittleEndian.PutUint64(b[160:], n.uniqueID)
+	binary.LittleEndian.PutUint32(b[172:], uint32(len(ads)))
+	copy(b[fileEntryHeaderSize:], ads)
+	putTag(b, tagFileEntry, n.entry)
+	return b
+}
+
+// directoryData returns the file identifiers of a directory: its parent,
+// then its children.
+func directoryData(dir *node) []byte {
+	b := make([]byte, 0, dir.dataSize)
+	b = appendFileIdentifier(b, dir.data, fidDirectory|fidParent, "", dir.parent.entry)
+	for _, c := range dir.children {
+		var characteristics byte
+		if c.dir {
+			characteristics = fidDirectory
+		}
+		b = appendFileIdentifier(b, dir.data, characteristics, c.name, c.entry)
+	}
+	return b
+}
+
+// appendFileIdentifier appends a file identifier descriptor to the data
+// of the directory starting at block dataBlock.
+func appendFileIdentifier(b []byte, dataBlock uint32, characteristics byte, name string, entry uint32) []byte {
+	var encoded []byte
+	if name != "" {
+		encoded = encodeCS0(name)
+	}
+	fid := make([]byte, fidSize(name))
+	binary.LittleEndian.PutUint16(fid[16:], 1) // file version
+	fid[18] = characteristics
+	fid[19] = byte(len(encoded))
+	binary.LittleEndian.PutUint32(fid[20:], SectorSize) // ICB
+	binary.LittleEndian.PutUint32(fid[24:], entry)
+	copy(fid[38:], encoded)
+	putTag(fid, tagFileIdentifier, dataBlock+uint32(len(b)/SectorSize))
+	return append(b, fid...)
+}
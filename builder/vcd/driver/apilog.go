@@ -0,0 +1,145 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// apiLogEntry is one VCD API call as logged by apiLogTransport.
+type apiLogEntry struct {
+	Method     string `json:"method"`
+	URL        string `json:"url"`
+	Status     int    `json:"status,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+	RequestID  string `json:"request_id,omitempty"`
+	Task       string `json:"task,omitempty"`
+	Message    string `json:"message,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// apiLogTransport logs every request sent to VCD as a JSON entry on one
+// line, prefixed with "vcd-api" so the entries can be grepped out of the
+// Packer log. Credentials never appear: bodies are not logged, secrets in
+// query strings and transfer URLs are masked, and only the error message
+// of failed calls is kept from responses.
+type apiLogTransport struct {
+	base http.RoundTripper
+}
+
+// newAPILogTransport wraps base with API call logging when enabled, and
+// returns base unchanged otherwise.
+func newAPILogTransport(base http.RoundTripper, enabled bool) http.RoundTripper {
+	if !enabled {
+		return base
+	}
+	return &apiLogTransport{base: base}
+}
+
+func (t *apiLogTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+
+	entry := apiLogEntry{
+		Method:     req.Method,
+		URL:        sanitizeURL(req.URL),
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.Status = resp.StatusCode
+		entry.RequestID = resp.Header.Get("X-Vmware-Vcloud-Request-Id")
+		entry.Task, entry.Message = inspectResponse(resp)
+	}
+
+	data, jsonErr := json.Marshal(entry)
+	if jsonErr == nil {
+		log.Printf("[DEBUG] vcd-api %s", data)
+	}
+	return resp, err
+}
+
+// maxInspectedBody bounds how much of a task or error response is read to
+// find the task ID or error message. Both fit in a few KB.
+const maxInspectedBody = 64 * 1024
+
+var (
+	taskIDPattern       = regexp.MustCompile(`id="(urn:vcloud:task:[^"]+)"`)
+	xmlMessagePattern   = regexp.MustCompile(`message="([^"]*)"`)
+	taskHREFPattern     = regexp.MustCompile(`/task/([0-9a-fA-F-]{36})`)
+	sensitiveQueryKeys  = []string{"password", "token", "secret", "ticket", "signature"}
+	transferPathPattern = regexp.MustCompile(`/transfer/[^/]+`)
+)
+
+// inspectResponse returns the task started by a request and the error
+// message of a failed one. Bodies are only peeked at for task and error
+// responses, and are restored for the caller.
+func inspectResponse(resp *http.Response) (task, message string) {
+	if m := taskHREFPattern.FindStringSubmatch(resp.Header.Get("Location")); m != nil {
+		task = "urn:vcloud:task:" + m[1]
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	isTask := strings.Contains(contentType, "task+")
+	if resp.Body == nil || (!isTask && resp.StatusCode < 400) {
+		return task, ""
+	}
+
+	peek, err := io.ReadAll(io.LimitReader(resp.Body, maxInspectedBody))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peek), resp.Body), resp.Body}
+	if err != nil {
+		return task, ""
+	}
+
+	if m := taskIDPattern.FindSubmatch(peek); isTask && m != nil {
+		task = string(m[1])
+	}
+	if resp.StatusCode >= 400 {
+		if m := xmlMessagePattern.FindSubmatch(peek); m != nil {
+			message = string(m[1])
+		} else {
+			var jsonErr struct {
+				Message string `json:"message"`
+			}
+			if json.Unmarshal(peek, &jsonErr) == nil {
+				message = jsonErr.Message
+			}
+		}
+	}
+	return task, message
+}
+
+// sanitizeURL returns u without user info, with the values of query
+// parameters that may carry secrets masked, and with the transfer ID of
+// upload and download URLs, which grants access to the file, masked.
+func sanitizeURL(u *url.URL) string {
+	clean := *u
+	clean.User = nil
+	clean.Path = transferPathPattern.ReplaceAllString(clean.Path, "/transfer/***")
+	clean.RawPath = ""
+
+	if clean.RawQuery != "" {
+		query := clean.Query()
+		for key := range query {
+			lower := strings.ToLower(key)
+			for _, s := range sensitiveQueryKeys {
+				if strings.Contains(lower, s) {
+					query.Set(key, "***")
+					break
+				}
+			}
+		}
+		clean.RawQuery = query.Encode()
+	}
+	return clean.String()
+}
@@ -1,16 +1,41 @@
 package driver
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/xml"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/vmware/go-vcloud-director/v3/govcd"
 	"github.com/vmware/go-vcloud-director/v3/types/v56"
 )
 
+// ConsoleAccessRight is the VCD right needed to acquire MKS tickets
+const ConsoleAccessRight = "vApp: Access to VM Console"
+
+// ConsoleAccessError is returned when the session's roles lack
+// ConsoleAccessRight
+type ConsoleAccessError struct {
+	User  string
+	Roles []string
+}
+
+func (e *ConsoleAccessError) Error() string {
+	return fmt.Sprintf("user %q lacks the %q right required for boot_command (roles: %s); ask your organization administrator to add it to one of these roles",
+		e.User, ConsoleAccessRight, strings.Join(e.Roles, ", "))
+}
+
+// IsForbidden reports whether err is a 403 response from the VCD API
+func IsForbidden(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "API Error: 403")
+}
+
 // MksTicket represents the response from acquireMksTicket API
 // This is used to establish a WebMKS console connection to a VM
 type MksTicket struct {
@@ -76,10 +101,8 @@ func AcquireMksTicketDirect(client *govcd.VCDClient, vmHref string) (*MksTicket,
 	return acquireMksTicketFromURL(&client.Client, ticketURL)
 }
 
-// WebSocketURL constructs the WebSocket URL for connecting to the VM console
-func (t *MksTicket) WebSocketURL() string {
-	// VCD 10.4+ console proxy URL format: wss://{host}:{port}/{port};{ticket}
-	// The port appears twice: once in the host:port and once in the path with semicolon
+// address returns the host and port of the console proxy of the ticket
+func (t *MksTicket) address() (string, int) {
 	host := t.Host
 	port := t.Port
 
@@ -97,6 +120,21 @@ func (t *MksTicket) WebSocketURL() string {
 		port = 443
 	}
 
+	return host, port
+}
+
+// Address returns the host:port of the console proxy of the ticket
+func (t *MksTicket) Address() string {
+	host, port := t.address()
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// WebSocketURL constructs the WebSocket URL for connecting to the VM console
+func (t *MksTicket) WebSocketURL() string {
+	// VCD 10.4+ console proxy URL format: wss://{host}:{port}/{port};{ticket}
+	// The port appears twice: once in the host:port and once in the path with semicolon
+	host, port := t.address()
+
 	// Ticket should start with / (e.g., /cst-xxx--tp-xxx--)
 	ticket := t.Ticket
 	if !strings.HasPrefix(ticket, "/") {
@@ -106,3 +144,55 @@ func (t *MksTicket) WebSocketURL() string {
 	// Format: wss://host:port/port;ticket
 	return fmt.Sprintf("wss://%s:%d/%d;%s", host, port, port, ticket)
 }
+
+// ConsoleUnreachableError is returned when the console proxy cannot be
+// reached from this host, as opposed to the console proxy refusing the
+// ticket
+type ConsoleUnreachableError struct {
+	Address string
+	Err     error
+}
+
+func (e *ConsoleUnreachableError) Error() string {
+	return fmt.Sprintf("console proxy %s is unreachable from this host, check the firewall and console_proxy_url/proxy_url: %v", e.Address, e.Err)
+}
+
+func (e *ConsoleUnreachableError) Unwrap() error {
+	return e.Err
+}
+
+// consoleProbeTimeout bounds a single probe of the console proxy
+const consoleProbeTimeout = 10 * time.Second
+
+// ProbeConsoleProxy checks that the console proxy of ticket accepts TLS
+// connections from this host, through proxy when one applies. The ticket
+// itself is not used, as tickets are single use; any HTTP response means
+// the console proxy is reachable. Failures are *ConsoleUnreachableError.
+func ProbeConsoleProxy(ctx context.Context, ticket *MksTicket, tlsConfig *tls.Config, proxy ProxyFunc) error {
+	address := ticket.Address()
+
+	transport := &http.Transport{
+		Proxy:               proxy,
+		DialContext:         (&net.Dialer{Timeout: consoleProbeTimeout}).DialContext,
+		TLSClientConfig:     tlsConfig.Clone(),
+		TLSHandshakeTimeout: consoleProbeTimeout,
+		DisableKeepAlives:   true,
+	}
+	defer transport.CloseIdleConnections()
+
+	ctx, cancel := context.WithTimeout(ctx, consoleProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, "https://"+address+"/", nil)
+	if err != nil {
+		return &ConsoleUnreachableError{Address: address, Err: err}
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return &ConsoleUnreachableError{Address: address, Err: err}
+	}
+	resp.Body.Close()
+
+	log.Printf("[DEBUG] Console proxy %s reachable (HTTP %d)", address, resp.StatusCode)
+	return nil
+}
@@ -0,0 +1,290 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+)
+
+// govcd builds its requests without a context, so a cancelled build cannot
+// interrupt them directly. abortTransport also cancels every request when a
+// driver-wide context is cancelled; cancelling it aborts whatever is in
+// flight, such as the chunk PUTs of an ISO upload running in govcd's
+// background goroutine. The request's own context still applies.
+type abortTransport struct {
+	base http.RoundTripper
+
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newAbortTransport(base http.RoundTripper) *abortTransport {
+	t := &abortTransport{base: base}
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	return t
+}
+
+func (t *abortTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	abortCtx := t.ctx
+	t.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(req.Context())
+	stop := context.AfterFunc(abortCtx, cancel)
+	release := func() {
+		stop()
+		cancel()
+	}
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil || resp.Body == nil {
+		release()
+		return resp, err
+	}
+	// The body is read after RoundTrip returns, so the context lives until
+	// it is closed.
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releaseOnClose calls release once the body it wraps is closed.
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// abort cancels the requests in flight. Later requests, such as those made
+// by step cleanups, go through normally.
+func (t *abortTransport) abort() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cancel()
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+}
+
+// abortRequests aborts the HTTP requests in flight on the driver's client.
+func (d *VCDDriver) abortRequests() {
+	if t, ok := d.client.Client.Http.Transport.(*abortTransport); ok {
+		log.Printf("[DEBUG] Aborting in-flight VCD requests")
+		t.abort()
+	}
+}
+
+// runWithContext runs a blocking govcd call, returning early with the
+// context's error if it is cancelled first. The abandoned call's requests
+// are aborted so it does not keep transferring in the background.
+func (d *VCDDriver) runWithContext(ctx context.Context, op func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- op()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		d.abortRequests()
+		return ctx.Err()
+	}
+}
+
+// TaskQueue controls how WaitTask treats tasks that VCD has queued but not
+// started, e.g. when the provider throttles the tasks of an organization.
+type TaskQueue struct {
+	// Timeout is how long a task may stay queued before it is cancelled.
+	// Time spent running does not count. Zero means no limit.
+	Timeout time.Duration
+	// Report is called when a task has been queued for a while, and again
+	// every queueReportInterval until it starts, so a throttled build does
+	// not look hung.
+	Report func(msg string)
+}
+
+type taskQueueKey struct{}
+
+// WithTaskQueue returns a context carrying q to the WaitTask calls made
+// with it.
+func WithTaskQueue(ctx context.Context, q TaskQueue) context.Context {
+	return context.WithValue(ctx, taskQueueKey{}, q)
+}
+
+func taskQueueFrom(ctx context.Context) TaskQueue {
+	q, _ := ctx.Value(taskQueueKey{}).(TaskQueue)
+	return q
+}
+
+type progressKey struct{}
+
+// WithProgress returns a context carrying report to the transfers and
+// WaitTask calls made with it, which report their progress every
+// progressReportInterval, so a long upload or capture does not look hung.
+func WithProgress(ctx context.Context, report func(msg string)) context.Context {
+	return context.WithValue(ctx, progressKey{}, report)
+}
+
+// reportProgress logs msg, and reports it to the function set by
+// WithProgress.
+func reportProgress(ctx context.Context, msg string) {
+	log.Printf("[INFO] %s", msg)
+	if report, _ := ctx.Value(progressKey{}).(func(msg string)); report != nil {
+		report(msg)
+	}
+}
+
+const (
+	// queueReportDelay lets tasks that VCD starts promptly go unreported.
+	queueReportDelay = 10 * time.Second
+	// queueReportInterval is how often a task still queued is reported.
+	queueReportInterval = time.Minute
+	// progressReportInterval is how often the progress of running tasks
+	// and transfers is reported. Tasks finishing sooner go unreported.
+	progressReportInterval = 30 * time.Second
+)
+
+// WaitTask waits for a VCD task to finish. If the context is cancelled
+// first, the task is cancelled in VCD as well. Tasks waiting in the VCD
+// queue are reported and limited as configured by WithTaskQueue.
+func WaitTask(ctx context.Context, task *govcd.Task) error {
+	const pollInterval = 3 * time.Second
+
+	queue := taskQueueFrom(ctx)
+	var queuedAt, reportedAt time.Time
+	var runningAt, progressAt time.Time
+	for {
+		if err := task.Refresh(); err != nil {
+			return fmt.Errorf("error refreshing task: %w", err)
+		}
+
+		switch status := task.Task.Status; status {
+		case "success":
+			return nil
+		case "error", "aborted":
+			if task.Task.Error != nil {
+				return fmt.Errorf("task did not complete successfully: %s", task.Task.Error.Message)
+			}
+			return fmt.Errorf("task did not complete successfully (status %s)", task.Task.Status)
+		case "queued", "preRunning":
+			if queuedAt.IsZero() {
+				queuedAt = time.Now()
+			}
+			waited := time.Since(queuedAt)
+			if queue.Timeout > 0 && waited >= queue.Timeout {
+				cancelTask(task)
+				return fmt.Errorf("task %q did not start within %s (status %s)", taskName(task), queue.Timeout, status)
+			}
+			if waited >= queueReportDelay && time.Since(reportedAt) >= queueReportInterval {
+				reportedAt = time.Now()
+				msg := fmt.Sprintf("VCD task %q is %s, waiting for it to start (queued for %s)",
+					taskName(task), status, waited.Round(time.Second))
+				if task.Task.Details != "" {
+					msg += ": " + task.Task.Details
+				}
+				log.Printf("[INFO] %s", msg)
+				if queue.Report != nil {
+					queue.Report(msg)
+				}
+			}
+		default:
+			if !reportedAt.IsZero() {
+				msg := fmt.Sprintf("VCD task %q started after %s in the queue",
+					taskName(task), time.Since(queuedAt).Round(time.Second))
+				log.Printf("[INFO] %s", msg)
+				if queue.Report != nil {
+					queue.Report(msg)
+				}
+			}
+			queuedAt, reportedAt = time.Time{}, time.Time{}
+
+			if runningAt.IsZero() {
+				runningAt, progressAt = time.Now(), time.Now()
+			}
+			if time.Since(progressAt) >= progressReportInterval {
+				progressAt = time.Now()
+				running := time.Since(runningAt).Round(time.Second)
+				if task.Task.Progress > 0 {
+					reportProgress(ctx, fmt.Sprintf("VCD task %q is %d%% done after %s", taskName(task), task.Task.Progress, running))
+				} else {
+					reportProgress(ctx, fmt.Sprintf("VCD task %q is still running after %s", taskName(task), running))
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			cancelTask(task)
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// taskName describes a task for the user, e.g. "Starting Virtual Machine
+// packer-build".
+func taskName(task *govcd.Task) string {
+	if task.Task.Operation != "" {
+		return task.Task.Operation
+	}
+	return task.Task.Name
+}
+
+// waitUpload waits for the file transfer of an upload task, then for VCD to
+// import it. Unlike govcd's ShowUploadProgress it stops, aborting the
+// transfer and cancelling the task, when the context is cancelled.
+func (d *VCDDriver) waitUpload(ctx context.Context, uploadTask *govcd.UploadTask) error {
+	const pollInterval = time.Second
+
+	reportedAt := time.Now()
+	for {
+		if err := uploadTask.GetUploadError(); err != nil {
+			return err
+		}
+		progress := uploadTask.GetUploadProgress()
+		if progress == "100.00" {
+			break
+		}
+		if time.Since(reportedAt) >= progressReportInterval {
+			reportedAt = time.Now()
+			reportProgress(ctx, fmt.Sprintf("Uploaded %s%%", progress))
+		}
+		// The upload may have been cancelled in the VCD UI
+		if err := uploadTask.Refresh(); err != nil {
+			return fmt.Errorf("error refreshing upload task: %w", err)
+		}
+		if status := uploadTask.Task.Task.Status; status != "queued" && status != "preRunning" && status != "running" {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			d.abortRequests()
+			cancelTask(uploadTask.Task)
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+
+	return WaitTask(ctx, uploadTask.Task)
+}
+
+func cancelTask(task *govcd.Task) {
+	if task == nil || task.Task == nil {
+		return
+	}
+	log.Printf("[DEBUG] Cancelling VCD task %s", task.Task.Name)
+	if err := task.CancelTask(); err != nil {
+		log.Printf("[WARN] Failed to cancel VCD task %s: %s", task.Task.Name, err)
+	}
+}
@@ -0,0 +1,72 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// blockingServer answers every request once unblock is closed.
+func blockingServer(t *testing.T) *httptest.Server {
+	unblock := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-unblock:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(func() {
+		close(unblock)
+		srv.Close()
+	})
+	return srv
+}
+
+func TestAbortTransport_KeepsRequestContext(t *testing.T) {
+	srv := blockingServer(t)
+	client := &http.Client{Transport: newAbortTransport(http.DefaultTransport)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	_, err := client.Do(req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the request deadline to apply, got %v", err)
+	}
+}
+
+func TestAbortTransport_Abort(t *testing.T) {
+	srv := blockingServer(t)
+	transport := newAbortTransport(http.DefaultTransport)
+	client := &http.Client{Transport: transport}
+
+	done := make(chan error, 1)
+	go func() {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		_, err := client.Do(req)
+		done <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	transport.abort()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected the request to be cancelled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("abort did not cancel the request in flight")
+	}
+
+	// Requests made after abort go through
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ok.Close()
+	resp, err := client.Get(ok.URL)
+	if err != nil {
+		t.Fatalf("request after abort failed: %v", err)
+	}
+	resp.Body.Close()
+}
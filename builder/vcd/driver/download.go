@@ -0,0 +1,124 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+	"github.com/vmware/go-vcloud-director/v3/types/v56"
+)
+
+// EnableVAppTemplateDownload has VCD serve a vApp template as an OVF
+// package, and returns the URL of its descriptor. The files of the package
+// are served next to the descriptor, at their href in its References. VCD
+// only serves templates, not vApps.
+func (d *VCDDriver) EnableVAppTemplateDownload(ctx context.Context, template *govcd.VAppTemplate) (string, error) {
+	enableLink := ""
+	for _, link := range template.VAppTemplate.Link {
+		if link.Rel == "enable" {
+			enableLink = link.HREF
+			break
+		}
+	}
+	if enableLink == "" {
+		return "", fmt.Errorf("vApp template %s cannot be downloaded", template.VAppTemplate.Name)
+	}
+
+	task, err := d.client.Client.ExecuteTaskRequest(enableLink, http.MethodPost, "",
+		"error enabling download: %s", nil)
+	if err != nil {
+		return "", fmt.Errorf("error enabling download of vApp template %s: %w", template.VAppTemplate.Name, err)
+	}
+	if err := WaitTask(ctx, &task); err != nil {
+		return "", fmt.Errorf("error waiting for download of vApp template %s: %w", template.VAppTemplate.Name, err)
+	}
+
+	current := &types.VAppTemplate{}
+	_, err = d.client.Client.ExecuteRequest(template.VAppTemplate.HREF, http.MethodGet, "",
+		"error getting vApp template: %s", nil, current)
+	if err != nil {
+		return "", err
+	}
+	for _, link := range current.Link {
+		if link.Rel == types.RelDownloadDefault {
+			return link.HREF, nil
+		}
+	}
+	return "", fmt.Errorf("no download link for vApp template %s", template.VAppTemplate.Name)
+}
+
+// DisableVAppTemplateDownload stops VCD serving a vApp template enabled by
+// EnableVAppTemplateDownload, releasing the space of the package.
+func (d *VCDDriver) DisableVAppTemplateDownload(template *govcd.VAppTemplate) error {
+	err := d.client.Client.ExecuteRequestWithoutResponse(
+		template.VAppTemplate.HREF+"/action/disableDownload",
+		http.MethodPost,
+		"",
+		"error disabling download: %s",
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("error disabling download of vApp template %s: %w", template.VAppTemplate.Name, err)
+	}
+	return nil
+}
+
+// DownloadFile writes the file served at fileURL, a transfer URL of VCD, to
+// w and returns its size. size is the expected size for progress reports,
+// or 0 when unknown.
+func (d *VCDDriver) DownloadFile(ctx context.Context, fileURL string, w io.Writer, size int64) (int64, error) {
+	u, err := url.ParseRequestURI(fileURL)
+	if err != nil {
+		return 0, fmt.Errorf("invalid download URL %s: %w", fileURL, err)
+	}
+	req := d.client.Client.NewRequest(map[string]string{}, http.MethodGet, *u, nil)
+	req = req.WithContext(ctx)
+	resp, err := d.client.Client.Http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return 0, fmt.Errorf("download failed with status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if size <= 0 {
+		size = resp.ContentLength
+	}
+
+	name := u.Path[strings.LastIndex(u.Path, "/")+1:]
+	reportedAt := time.Now()
+	buf := make([]byte, 1<<20)
+	var n int64
+	for {
+		read, err := resp.Body.Read(buf)
+		if read > 0 {
+			if _, werr := w.Write(buf[:read]); werr != nil {
+				return n, werr
+			}
+			n += int64(read)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return n, fmt.Errorf("error downloading %s: %w", name, err)
+		}
+		if time.Since(reportedAt) >= progressReportInterval {
+			reportedAt = time.Now()
+			if size > 0 {
+				reportProgress(ctx, fmt.Sprintf("Downloaded %d of %d MB of %s (%d%%)", n>>20, size>>20, name, n*100/size))
+			} else {
+				reportProgress(ctx, fmt.Sprintf("Downloaded %d MB of %s", n>>20, name))
+			}
+		}
+	}
+	log.Printf("[DEBUG] Downloaded %d bytes of %s", n, name)
+	return n, nil
+}
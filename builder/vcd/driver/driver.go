@@ -1,30 +1,53 @@
 package driver
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"image"
 	"io"
 	"log"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/vmware/go-vcloud-director/v3/govcd"
 	"github.com/vmware/go-vcloud-director/v3/types/v56"
+	"golang.org/x/time/rate"
 )
 
-const vcdAPIVersion = "38.1"
-
 // NetworkInfo contains information about a network's IP configuration
 type NetworkInfo struct {
 	Gateway     string
 	Netmask     string
 	DNS1        string
 	DNS2        string
-	AvailableIP string // First available IP from pool
+	DNSSuffix   string
+	AvailableIP string    // First available IP from pool
+	IPRanges    []IPRange // Static IP pool ranges
+}
+
+// IPRange is an inclusive range of a network's static IP pool
+type IPRange struct {
+	Start string
+	End   string
+}
+
+// StorageProfileInfo describes a storage profile available to a VDC
+type StorageProfileInfo struct {
+	Name    string
+	ID      string
+	Enabled bool
+	Default bool
+	LimitMB int64 // 0 means unlimited
+	UsedMB  int64
 }
 
 // TrustedPlatformModuleEdit is used to enable/disable TPM on a VM
@@ -36,6 +59,42 @@ type TrustedPlatformModuleEdit struct {
 	TpmPresent bool     `xml:"root:TpmPresent"`
 }
 
+// CreateSnapshotParams is used to take a snapshot of a VM, replacing any
+// snapshot it has: VCD keeps a single snapshot per VM
+// API: POST {vm}/action/createSnapshot
+// Content-Type: application/vnd.vmware.vcloud.createSnapshotParams+xml
+type CreateSnapshotParams struct {
+	XMLName     xml.Name `xml:"CreateSnapshotParams"`
+	Xmlns       string   `xml:"xmlns,attr"`
+	Name        string   `xml:"name,attr,omitempty"`
+	Memory      bool     `xml:"memory,attr"`
+	Quiesce     bool     `xml:"quiesce,attr"`
+	Description string   `xml:"Description,omitempty"`
+}
+
+// CopyOrMoveCatalogItemParams is used to copy a catalog item into a catalog
+// API: POST {catalog}/action/copy
+// Content-Type: application/vnd.vmware.vcloud.copyOrMoveCatalogItemParams+xml
+type CopyOrMoveCatalogItemParams struct {
+	XMLName     xml.Name         `xml:"CopyOrMoveCatalogItemParams"`
+	Xmlns       string           `xml:"xmlns,attr"`
+	Name        string           `xml:"name,attr"`
+	Description string           `xml:"Description,omitempty"`
+	Source      *types.Reference `xml:"Source"`
+}
+
+// CreateMediaParams is used to create a media item in a catalog
+// API: POST {catalog}/catalogItems
+// Content-Type: application/vnd.vmware.vcloud.media+xml
+type CreateMediaParams struct {
+	XMLName     xml.Name `xml:"Media"`
+	Xmlns       string   `xml:"xmlns,attr"`
+	Name        string   `xml:"name,attr"`
+	ImageType   string   `xml:"imageType,attr"`
+	Size        int64    `xml:"size,attr"`
+	Description string   `xml:"Description,omitempty"`
+}
+
 // Driver defines the interface for VCD operations
 type Driver interface {
 	// VM operations
@@ -45,13 +104,19 @@ type Driver interface {
 	// Org operations
 	GetOrg() (*govcd.Org, error)
 	GetAdminOrg() (*govcd.AdminOrg, error)
+	CheckConsoleAccess() error
 
 	// VDC operations
 	GetVdc(name string) (*govcd.Vdc, error)
+	ListStorageProfiles(vdc *govcd.Vdc) ([]*StorageProfileInfo, error)
 
 	// vApp operations
 	GetVApp(vdcName, vappName string) (*govcd.VApp, error)
-	CreateVApp(vdc *govcd.Vdc, name, description, networkName string) (*govcd.VApp, error)
+	GetVAppById(id string) (*govcd.VApp, error)
+	ListVApps(vdc *govcd.Vdc) ([]*types.QueryResultVAppRecordType, error)
+	ListVMs(vdc *govcd.Vdc) ([]*types.QueryResultVMRecordType, error)
+	CreateVApp(ctx context.Context, vdc *govcd.Vdc, name, description, networkName string) (*govcd.VApp, error)
+	ForceDeleteVApp(ctx context.Context, vapp *govcd.VApp, report func(string)) error
 
 	// Network operations
 	FindAvailableIP(vdc *govcd.Vdc, networkName string) (*NetworkInfo, error)
@@ -60,38 +125,124 @@ type Driver interface {
 
 	// Catalog operations
 	GetCatalog(name string) (*govcd.Catalog, error)
-	CreateCatalogWithStorageProfile(name, description string, storageProfileRef *types.Reference) (*govcd.AdminCatalog, error)
-	DeleteCatalog(catalog *govcd.AdminCatalog) error
-	UploadMediaImage(catalog *govcd.Catalog, name, description, filePath string) (*govcd.Media, error)
+	ListCatalogs() ([]*types.CatalogRecord, error)
+	CreateCatalogWithStorageProfile(ctx context.Context, name, description string, storageProfileRef *types.Reference) (*govcd.AdminCatalog, error)
+	DeleteCatalog(ctx context.Context, catalog *govcd.AdminCatalog) error
+	UploadMediaImage(ctx context.Context, catalog *govcd.Catalog, name, description, filePath string) (*govcd.Media, error)
+	ResumeMediaUpload(ctx context.Context, catalog *govcd.Catalog, media *govcd.Media, filePath string, offset int64) (*govcd.Media, error)
+	UploadFloppyImage(ctx context.Context, catalog *govcd.Catalog, name, description, filePath string) (*govcd.Media, error)
+	WaitForMediaResolved(ctx context.Context, catalog *govcd.Catalog, mediaID string, timeout time.Duration, report func(status string)) (*govcd.Media, error)
+	GetMediaById(catalog *govcd.Catalog, id string) (*govcd.Media, error)
+	GetCatalogItemById(catalog *govcd.Catalog, id string) (*govcd.CatalogItem, error)
+	SetMediaChecksum(media *govcd.Media, checksum string) error
+	FindMediaByChecksum(checksum string) (*govcd.Media, string, error)
+	GetOrgCatalog(orgName, catalogName string) (*govcd.Catalog, error)
+	ShareCatalog(catalog *govcd.Catalog, orgNames []string, accessLevel string) error
+	PublishCatalog(catalog *govcd.Catalog) error
 
 	// Template operations
+	ListVAppTemplates(catalog string, filter VAppTemplateFilter) ([]*VAppTemplateInfo, error)
+	UploadOvf(ctx context.Context, catalog *govcd.Catalog, name, description, filePath string) (*govcd.VAppTemplate, error)
 	MakeTemplatePoliciesNonFinal(template *govcd.VAppTemplate) error
+	CopyVAppTemplate(ctx context.Context, source *govcd.Catalog, name string, target *govcd.Catalog, targetName, description string) error
+	EnableVAppTemplateDownload(ctx context.Context, template *govcd.VAppTemplate) (string, error)
+	DisableVAppTemplateDownload(template *govcd.VAppTemplate) error
+	DownloadFile(ctx context.Context, fileURL string, w io.Writer, size int64) (int64, error)
+
+	// Network operations
+	CreatePortForward(vdc *govcd.Vdc, edgeGatewayName string, spec PortForwardSpec) (*PortForward, error)
+	DeletePortForward(pf *PortForward) error
+
+	// Console operations
+	CaptureScreenshot(ctx context.Context, vm VirtualMachine) (image.Image, error)
+
+	// Transfer operations
+	AcquireTransferSlot(ctx context.Context, waiting func()) (func(), error)
 
 	// Lifecycle
 	Cleanup() error
 	GetClient() *govcd.VCDClient
+	Proxy() ProxyFunc
+	ConsoleProxy() ProxyFunc
+	TLSConfig() *tls.Config
+
+	// API version negotiated at connect time
+	APIVersion() string
+	SupportsAPIVersion(minVersion string) bool
+
+	// Diagnostics
+	ExplainLimitError(err error, vdc *govcd.Vdc) error
 }
 
 type VCDDriver struct {
-	client  *govcd.VCDClient
-	orgName string
-	stopCh  chan struct{} // signals keepalive goroutine to stop
+	client            *govcd.VCDClient
+	orgName           string
+	pageSize          int           // records per query service page
+	maxTransfers      int           // concurrent uploads/captures across builds, 0 = unlimited
+	uploadParallelism int           // ranges of an ISO uploaded at once
+	uploadLimiter     *rate.Limiter // upload_bandwidth_limit, nil = unlimited
+	stopCh            chan struct{} // signals keepalive goroutine to stop
+	keepSession       bool          // bearer token session owned by the caller, never logged out
+	proxy             ProxyFunc     // proxy selection of the API client
+	consoleProxy      ProxyFunc     // proxy selection of the console, nil = same as the API
+	tlsConfig         *tls.Config   // TLS settings shared by the API client and the console
 }
 
+// VCDDriver must implement the whole Driver contract used by the builders,
+// so steps can be tested against a mock.
+var _ Driver = (*VCDDriver)(nil)
+
 func NewVCDDriver(client *govcd.VCDClient, orgName string) Driver {
 	return &VCDDriver{
-		client:  client,
-		orgName: orgName,
+		client:   client,
+		orgName:  orgName,
+		pageSize: defaultQueryPageSize,
 	}
 }
 
 type ConnectConfig struct {
 	Host               string
 	Org                string
+	TenantOrg          string
 	Username           string
 	Password           string
 	Token              string
+	BearerToken        string
 	InsecureConnection bool
+	// CACertificates holds PEM encoded CA certificates trusted in addition
+	// to the system roots, for VCD installations using a private PKI.
+	CACertificates []byte
+	// PageSize is the number of records requested per query service page.
+	// Zero means defaultQueryPageSize.
+	PageSize int
+	// MaxConcurrentTransfers limits the uploads and captures running at once
+	// across all builds on the host. Zero means no limit.
+	MaxConcurrentTransfers int
+	// UploadParallelism is the number of ranges of an ISO uploaded at once.
+	// Zero means one.
+	UploadParallelism int
+	// UploadBandwidthLimit is the maximum upload rate of ISOs across all
+	// the uploads of the driver, as parsed by ParseBandwidth. Empty means
+	// no limit.
+	UploadBandwidthLimit string
+	// RetryCount and RetryWait control the retries of transient API errors.
+	// Zero means defaultAPIRetryCount and defaultAPIRetryWait.
+	RetryCount int
+	RetryWait  time.Duration
+	// RateLimit is the maximum number of API requests per second. Zero
+	// means no limit.
+	RateLimit float64
+	// ProxyURL is the http, https or socks5 proxy used to reach VCD, and
+	// NoProxy the comma-separated hosts bypassing it. Empty values fall
+	// back to the proxy environment variables.
+	ProxyURL string
+	NoProxy  string
+	// ConsoleProxyURL is the proxy used to reach the console proxy instead
+	// of the API proxy, or ConsoleProxyDirect to reach it without a proxy.
+	// Empty means the same proxy as the API.
+	ConsoleProxyURL string
+	// APILog logs every API call, see apiLogTransport.
+	APILog bool
 }
 
 func NewDriver(config *ConnectConfig) (Driver, error) {
@@ -100,15 +251,55 @@ func NewDriver(config *ConnectConfig) (Driver, error) {
 		return nil, err
 	}
 
-	govcdClient, err := newClient(*apiURL, config.Org, config.Username, config.Password, config.Token, config.InsecureConnection)
+	proxy, err := config.proxyFunc()
+	if err != nil {
+		return nil, err
+	}
+	consoleProxy, err := config.consoleProxyFunc()
 	if err != nil {
 		return nil, err
 	}
 
+	tlsConfig, err := config.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	uploadLimit, err := ParseBandwidth(config.UploadBandwidthLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	govcdClient, err := newClient(*apiURL, config, proxy, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := config.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultQueryPageSize
+	}
+
+	// A provider administrator logs in to System but works in a tenant org
+	orgName := config.Org
+	if config.TenantOrg != "" {
+		orgName = config.TenantOrg
+	}
+
 	driver := &VCDDriver{
-		client:  govcdClient,
-		orgName: config.Org,
-		stopCh:  make(chan struct{}),
+		client:            govcdClient,
+		orgName:           orgName,
+		pageSize:          pageSize,
+		maxTransfers:      config.MaxConcurrentTransfers,
+		uploadParallelism: config.UploadParallelism,
+		uploadLimiter:     newBandwidthLimiter(uploadLimit),
+		stopCh:            make(chan struct{}),
+		// The session behind a bearer token belongs to whoever issued it;
+		// logging out would revoke it for other builds sharing the token
+		keepSession:  config.BearerToken != "",
+		proxy:        proxy,
+		consoleProxy: consoleProxy,
+		tlsConfig:    tlsConfig,
 	}
 	driver.startKeepalive()
 
@@ -117,7 +308,8 @@ func NewDriver(config *ConnectConfig) (Driver, error) {
 
 // startKeepalive runs a background goroutine that pings VCD every 10 minutes
 // to prevent the session from expiring during long operations (e.g. Windows
-// Update provisioning that can take 3+ hours with no VCD API calls).
+// Update provisioning that can take 3+ hours with no VCD API calls). If the
+// session expires anyway, sessionTransport logs in again on the next request.
 func (d *VCDDriver) startKeepalive() {
 	go func() {
 		ticker := time.NewTicker(10 * time.Minute)
@@ -140,7 +332,7 @@ func (d *VCDDriver) Cleanup() error {
 	if d.stopCh != nil {
 		close(d.stopCh)
 	}
-	if d.client != nil {
+	if d.client != nil && !d.keepSession {
 		return d.client.Disconnect()
 	}
 	return nil
@@ -196,6 +388,45 @@ func (d *VCDDriver) GetAdminOrg() (*govcd.AdminOrg, error) {
 	return adminOrg, nil
 }
 
+// CheckConsoleAccess verifies that one of the session's roles grants
+// ConsoleAccessRight, returning a *ConsoleAccessError if none does. Other
+// errors mean the roles could not be inspected, which org users without
+// the "View Roles" right commonly cannot do.
+func (d *VCDDriver) CheckConsoleAccess() error {
+	if d.client.Client.IsSysAdmin {
+		return nil
+	}
+
+	session, err := d.client.Client.GetSessionInfo()
+	if err != nil {
+		return fmt.Errorf("error getting session info: %w", err)
+	}
+
+	adminOrg, err := d.GetAdminOrg()
+	if err != nil {
+		return err
+	}
+
+	for _, roleName := range session.Roles {
+		role, err := adminOrg.GetRoleByName(roleName)
+		if err != nil {
+			return fmt.Errorf("error getting role %s: %w", roleName, err)
+		}
+		rights, err := role.GetRights(nil)
+		if err != nil {
+			return fmt.Errorf("error getting rights of role %s: %w", roleName, err)
+		}
+		for _, right := range rights {
+			if right.Name == ConsoleAccessRight {
+				log.Printf("[DEBUG] Role %s grants %q", roleName, ConsoleAccessRight)
+				return nil
+			}
+		}
+	}
+
+	return &ConsoleAccessError{User: session.User.Name, Roles: session.Roles}
+}
+
 // --- VDC Operations ---
 
 func (d *VCDDriver) GetVdc(name string) (*govcd.Vdc, error) {
@@ -211,6 +442,37 @@ func (d *VCDDriver) GetVdc(name string) (*govcd.Vdc, error) {
 	return vdc, nil
 }
 
+// ListStorageProfiles returns the storage profiles of a VDC with their usage.
+// Each profile is fetched individually, as the VDC only holds references.
+func (d *VCDDriver) ListStorageProfiles(vdc *govcd.Vdc) ([]*StorageProfileInfo, error) {
+	if vdc.Vdc.VdcStorageProfiles == nil {
+		return nil, nil
+	}
+
+	var profiles []*StorageProfileInfo
+	for _, ref := range vdc.Vdc.VdcStorageProfiles.VdcStorageProfile {
+		sp, err := d.client.GetStorageProfileByHref(ref.HREF)
+		if err != nil {
+			return nil, fmt.Errorf("error getting storage profile %s: %w", ref.Name, err)
+		}
+
+		info := &StorageProfileInfo{
+			Name:    sp.Name,
+			ID:      ref.ID,
+			Enabled: sp.Enabled == nil || *sp.Enabled,
+			Default: sp.Default,
+			LimitMB: sp.Limit,
+			UsedMB:  sp.StorageUsedMB,
+		}
+		// Limits are reported in MB unless the profile says otherwise
+		if strings.EqualFold(sp.Units, "GB") {
+			info.LimitMB = sp.Limit * 1024
+		}
+		profiles = append(profiles, info)
+	}
+	return profiles, nil
+}
+
 // --- vApp Operations ---
 
 func (d *VCDDriver) GetVApp(vdcName, vappName string) (*govcd.VApp, error) {
@@ -226,7 +488,7 @@ func (d *VCDDriver) GetVApp(vdcName, vappName string) (*govcd.VApp, error) {
 	return vapp, nil
 }
 
-func (d *VCDDriver) CreateVApp(vdc *govcd.Vdc, name, description, networkName string) (*govcd.VApp, error) {
+func (d *VCDDriver) CreateVApp(ctx context.Context, vdc *govcd.Vdc, name, description, networkName string) (*govcd.VApp, error) {
 	// Create an empty vApp
 	vapp, err := vdc.CreateRawVApp(name, description)
 	if err != nil {
@@ -241,6 +503,9 @@ func (d *VCDDriver) CreateVApp(vdc *govcd.Vdc, name, description, networkName st
 
 	for {
 		select {
+		case <-ctx.Done():
+			_, _ = vapp.Delete()
+			return nil, ctx.Err()
 		case <-timeout:
 			_, _ = vapp.Delete()
 			return nil, fmt.Errorf("timeout waiting for vApp %s to be ready", name)
@@ -317,7 +582,7 @@ func (d *VCDDriver) FindAvailableIPExcluding(vdc *govcd.Vdc, networkName string,
 
 	// IMPORTANT: Also check IPs actually in use by VMs in this VDC
 	// VCD's AllocatedIPAddresses doesn't track MANUAL allocations
-	usedIPs, err := d.getUsedIPsInVDC(vdc, networkName)
+	usedIPs, err := d.getUsedIPsInVDC(vdc)
 	if err != nil {
 		// Log warning but continue - we'll still have the allocated list
 		// The build might fail at power-on if there's a conflict
@@ -366,52 +631,111 @@ func (d *VCDDriver) GetNetworkInfo(vdc *govcd.Vdc, networkName string) (*Network
 
 	ipScope := cfg.IPScopes.IPScope[0]
 
-	return &NetworkInfo{
-		Gateway: ipScope.Gateway,
-		Netmask: ipScope.Netmask,
-		DNS1:    ipScope.DNS1,
-		DNS2:    ipScope.DNS2,
-	}, nil
+	info := &NetworkInfo{
+		Gateway:   ipScope.Gateway,
+		Netmask:   ipScope.Netmask,
+		DNS1:      ipScope.DNS1,
+		DNS2:      ipScope.DNS2,
+		DNSSuffix: ipScope.DNSSuffix,
+	}
+	if ipScope.IPRanges != nil {
+		for _, r := range ipScope.IPRanges.IPRange {
+			info.IPRanges = append(info.IPRanges, IPRange{Start: r.StartAddress, End: r.EndAddress})
+		}
+	}
+
+	return info, nil
 }
 
-// getUsedIPsInVDC queries all VMs in the VDC to find IPs actually in use on a network
-func (d *VCDDriver) getUsedIPsInVDC(vdc *govcd.Vdc, networkName string) ([]string, error) {
+// getUsedIPsInVDC returns the IPs of every NIC of every VM in the VDC,
+// whether or not the VM is deployed
+func (d *VCDDriver) getUsedIPsInVDC(vdc *govcd.Vdc) ([]string, error) {
+	// The query service lists the VMs in a few paginated calls. Its records
+	// only carry the primary NIC address though, so only the vApps that hold
+	// VMs are then fetched, for the NICs of all their VMs.
+	records, err := d.ListVMs(vdc)
+	if err != nil {
+		log.Printf("[WARN] VM query failed, falling back to vApp scan: %s", err)
+		return d.scanUsedIPsInVApps(vdc)
+	}
+
+	byVApp := make(map[string][]*types.QueryResultVMRecordType)
+	var vappHREFs []string
+	for _, record := range records {
+		if _, ok := byVApp[record.ContainerID]; !ok {
+			vappHREFs = append(vappHREFs, record.ContainerID)
+		}
+		byVApp[record.ContainerID] = append(byVApp[record.ContainerID], record)
+	}
+
+	var usedIPs []string
+	for _, href := range vappHREFs {
+		vapp, err := vdc.GetVAppByHref(href)
+		if err != nil {
+			// Keep the primary NIC addresses of the VMs we can't look into
+			log.Printf("[WARN] Error getting vApp %s, only its primary IPs are excluded: %s", href, err)
+			for _, record := range byVApp[href] {
+				if record.IpAddress != "" {
+					usedIPs = append(usedIPs, record.IpAddress)
+				}
+			}
+			continue
+		}
+		usedIPs = append(usedIPs, vappNICAddresses(vapp)...)
+	}
+
+	log.Printf("[DEBUG] Found %d used IPs across %d VMs in VDC %s", len(usedIPs), len(records), vdc.Vdc.Name)
+	return usedIPs, nil
+}
+
+// scanUsedIPsInVApps walks every vApp and VM in the VDC and collects the IPs
+// of all their NICs. It is much slower than the query service on large
+// tenants and is only used when the query fails.
+func (d *VCDDriver) scanUsedIPsInVApps(vdc *govcd.Vdc) ([]string, error) {
 	var usedIPs []string
 
 	// Get all vApps in the VDC
-	vappRefs := vdc.GetVappList()
+	vappRecords, err := d.ListVApps(vdc)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, vappRef := range vappRefs {
-		vapp, err := vdc.GetVAppByName(vappRef.Name, true)
+	for _, vappRecord := range vappRecords {
+		vapp, err := vdc.GetVAppByHref(vappRecord.HREF)
 		if err != nil {
 			continue // Skip vApps we can't access
 		}
+		usedIPs = append(usedIPs, vappNICAddresses(vapp)...)
+	}
+
+	return usedIPs, nil
+}
+
+// vappNICAddresses returns the IPs of all the NICs of the VMs in a vApp
+func vappNICAddresses(vapp *govcd.VApp) []string {
+	// Check each VM in the vApp
+	if vapp.VApp.Children == nil {
+		return nil
+	}
 
-		// Check each VM in the vApp
-		if vapp.VApp.Children == nil {
+	var ips []string
+	for _, vmRef := range vapp.VApp.Children.VM {
+		// Get network connection section
+		if vmRef.NetworkConnectionSection == nil {
 			continue
 		}
 
-		for _, vmRef := range vapp.VApp.Children.VM {
-			// Get network connection section
-			if vmRef.NetworkConnectionSection == nil {
-				continue
-			}
-
-			for _, conn := range vmRef.NetworkConnectionSection.NetworkConnection {
-				// Collect ALL IPs from ALL networks - VCD validates across all networks
-				// Network isolation happens at a different layer
-				if conn.IPAddress != "" {
-					usedIPs = append(usedIPs, conn.IPAddress)
-				}
+		for _, conn := range vmRef.NetworkConnectionSection.NetworkConnection {
+			// Collect ALL IPs from ALL networks - VCD validates across all networks
+			// Network isolation happens at a different layer
+			if conn.IPAddress != "" {
+				ips = append(ips, conn.IPAddress)
 			}
 		}
 	}
-
-	return usedIPs, nil
+	return ips
 }
 
-// findFirstAvailableIP finds the first IP in the range that is not in the allocated set
 func findFirstAvailableIP(start, end string, allocated map[string]bool) string {
 	startIP := net.ParseIP(start).To4()
 	endIP := net.ParseIP(end).To4()
@@ -465,7 +789,7 @@ func (d *VCDDriver) GetCatalog(name string) (*govcd.Catalog, error) {
 	return catalog, nil
 }
 
-func (d *VCDDriver) CreateCatalogWithStorageProfile(name, description string, storageProfileRef *types.Reference) (*govcd.AdminCatalog, error) {
+func (d *VCDDriver) CreateCatalogWithStorageProfile(ctx context.Context, name, description string, storageProfileRef *types.Reference) (*govcd.AdminCatalog, error) {
 	adminOrg, err := d.GetAdminOrg()
 	if err != nil {
 		return nil, err
@@ -478,98 +802,635 @@ func (d *VCDDriver) CreateCatalogWithStorageProfile(name, description string, st
 		}
 	}
 
-	catalog, err := adminOrg.CreateCatalogWithStorageProfile(name, description, storageProfiles)
+	var catalog *govcd.AdminCatalog
+	err = d.runWithContext(ctx, func() error {
+		var err error
+		catalog, err = adminOrg.CreateCatalogWithStorageProfile(name, description, storageProfiles)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("error creating catalog %s with storage profile: %w", name, err)
 	}
 	return catalog, nil
 }
 
-func (d *VCDDriver) DeleteCatalog(catalog *govcd.AdminCatalog) error {
-	err := catalog.Delete(true, true)
+func (d *VCDDriver) DeleteCatalog(ctx context.Context, catalog *govcd.AdminCatalog) error {
+	err := d.runWithContext(ctx, func() error {
+		return catalog.Delete(true, true)
+	})
 	if err != nil {
 		return fmt.Errorf("error deleting catalog: %w", err)
 	}
 	return nil
 }
 
-func (d *VCDDriver) UploadMediaImage(catalog *govcd.Catalog, name, description, filePath string) (*govcd.Media, error) {
-	// Upload with 50MB chunks - larger chunks reduce HTTP round-trips and
-	// help complete uploads before server-side connection timeouts.
-	// 10MB was too small for ISOs >650MB on some VCD servers.
+// GetOrgCatalog returns a catalog of another organization. Looking up other
+// organizations requires a provider (System) session.
+func (d *VCDDriver) GetOrgCatalog(orgName, catalogName string) (*govcd.Catalog, error) {
+	adminOrg, err := d.client.GetAdminOrgByName(orgName)
+	if err != nil {
+		return nil, fmt.Errorf("error getting admin org %s: %w", orgName, err)
+	}
+	catalog, err := adminOrg.GetCatalogByName(catalogName, true)
+	if err != nil {
+		return nil, fmt.Errorf("error getting catalog %s in org %s: %w", catalogName, orgName, err)
+	}
+	return catalog, nil
+}
+
+// ShareCatalog grants the given organizations access to the catalog. Existing
+// access settings are kept; organizations that already have access get the
+// new access level.
+func (d *VCDDriver) ShareCatalog(catalog *govcd.Catalog, orgNames []string, accessLevel string) error {
+	acl, err := catalog.GetAccessControl(true)
+	if err != nil {
+		return fmt.Errorf("error getting access control for catalog %s: %w", catalog.Catalog.Name, err)
+	}
+	if acl.AccessSettings == nil {
+		acl.AccessSettings = &types.AccessSettingList{}
+	}
+
+	for _, orgName := range orgNames {
+		adminOrg, err := d.client.GetAdminOrgByName(orgName)
+		if err != nil {
+			return fmt.Errorf("error getting admin org %s: %w", orgName, err)
+		}
+		href := adminOrg.AdminOrg.HREF
+
+		updated := false
+		for _, setting := range acl.AccessSettings.AccessSetting {
+			if setting.Subject != nil && setting.Subject.HREF == href {
+				setting.AccessLevel = accessLevel
+				updated = true
+				break
+			}
+		}
+		if !updated {
+			acl.AccessSettings.AccessSetting = append(acl.AccessSettings.AccessSetting, &types.AccessSetting{
+				Subject: &types.LocalSubject{
+					HREF: href,
+					Type: types.MimeAdminOrg,
+				},
+				AccessLevel: accessLevel,
+			})
+		}
+	}
+
+	if err := catalog.SetAccessControl(acl, true); err != nil {
+		return fmt.Errorf("error sharing catalog %s: %w", catalog.Catalog.Name, err)
+	}
+	return nil
+}
+
+// PublishCatalog shares the catalog read-only with all organizations. Unlike
+// govcd's SetReadOnlyAccessControl, existing access settings are left alone.
+func (d *VCDDriver) PublishCatalog(catalog *govcd.Catalog) error {
+	isPublished := true
+	params := types.PublishCatalogParams{
+		Xmlns:       types.XMLNamespaceVCloud,
+		IsPublished: &isPublished,
+	}
+
+	err := d.client.Client.ExecuteRequestWithoutResponse(
+		catalog.Catalog.HREF+"/action/publish",
+		http.MethodPost,
+		types.PublishCatalog,
+		"error publishing catalog: %s",
+		params,
+	)
+	if err != nil {
+		return fmt.Errorf("error publishing catalog %s: %w", catalog.Catalog.Name, err)
+	}
+	return nil
+}
+
+// ErrMediaNameTaken is returned by UploadMediaImage when the catalog already
+// has a media of that name, typically uploaded by a concurrent build.
+var ErrMediaNameTaken = errors.New("media name already taken in catalog")
+
+// MediaUploadError is returned by UploadMediaImage and ResumeMediaUpload
+// when the file could not be uploaded in full. The media is left in the
+// catalog, unresolved, so that ResumeMediaUpload can carry on from
+// Uploaded; a caller giving up on it must delete it.
+type MediaUploadError struct {
+	Media    *govcd.Media
+	Uploaded int64
+	Err      error
+}
+
+func (e *MediaUploadError) Error() string {
+	return fmt.Sprintf("error uploading media %s: %s", e.Media.Media.Name, e.Err)
+}
+
+func (e *MediaUploadError) Unwrap() error {
+	return e.Err
+}
+
+// UploadMediaImage uploads an ISO into the catalog. The file is sent in
+// ranges, upload_parallelism of them at once, each retried on its own, and
+// a broken off upload is resumed instead of starting over. If it still
+// fails, the partial media is kept and a *MediaUploadError returned.
+func (d *VCDDriver) UploadMediaImage(ctx context.Context, catalog *govcd.Catalog, name, description, filePath string) (*govcd.Media, error) {
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening ISO: %w", err)
+	}
+
+	media, err := d.createMedia(catalog, name, "iso", fi.Size(), description)
+	if err != nil {
+		return nil, err
+	}
+	return d.ResumeMediaUpload(ctx, catalog, media, filePath, 0)
+}
+
+// ResumeMediaUpload uploads the file of a media created by
+// UploadMediaImage from offset, or from the bytes VCD has received if it
+// has fewer, then waits for VCD to import it.
+func (d *VCDDriver) ResumeMediaUpload(ctx context.Context, catalog *govcd.Catalog, media *govcd.Media, filePath string, offset int64) (*govcd.Media, error) {
+	const maxAttempts = 3
+
+	name := media.Media.Name
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening ISO: %w", err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("error opening ISO: %w", err)
+	}
+
+	uploaded := offset
+	for attempt := 1; ; attempt++ {
+		uploaded, err = d.uploadMediaFile(ctx, media.Media, f, fi.Size(), uploaded)
+		if err == nil || ctx.Err() != nil || attempt == maxAttempts {
+			break
+		}
+		log.Printf("[WARN] Upload attempt %d/%d of media %s failed, resuming at %d bytes: %s",
+			attempt, maxAttempts, name, uploaded, err)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, &MediaUploadError{Media: media, Uploaded: uploaded, Err: err}
+	}
+
+	// VCD imports the file once it is complete
+	if err := d.waitMediaImport(ctx, media); err != nil {
+		return nil, fmt.Errorf("error importing media %s: %w", name, err)
+	}
+
+	// The media may still be resolving; callers wait with WaitForMediaResolved
+	result, err := catalog.GetMediaByName(name, true)
+	if err != nil {
+		return nil, fmt.Errorf("error getting uploaded media %s: %w", name, err)
+	}
+	return result, nil
+}
+
+// uploadMediaFile uploads the file of a media item from offset, or from
+// the bytes VCD has received if it has fewer, in ranges sent over
+// upload_parallelism connections. Ranges complete in order: the returned
+// offset only moves past ranges whose predecessors are all uploaded, so
+// that a failed upload is resumed after the last byte known to be in place.
+func (d *VCDDriver) uploadMediaFile(ctx context.Context, media *types.Media, f io.ReaderAt, size, offset int64) (int64, error) {
+	// 50MB ranges reduce HTTP round-trips and help complete uploads before
+	// server-side connection timeouts. 10MB was too small for ISOs >650MB
+	// on some VCD servers.
 	const uploadPieceSize = 50 * 1024 * 1024
-	const maxRetries = 3
-
-	var lastErr error
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		// Use unique name per attempt to avoid "name not unique" errors from
-		// partially uploaded media that VCD won't let us delete
-		uploadName := name
-		if attempt > 1 {
-			ext := ""
-			base := name
-			if idx := len(name) - 4; idx > 0 && name[idx] == '.' {
-				ext = name[idx:]
-				base = name[:idx]
+
+	if media.Files == nil || len(media.Files.File) == 0 || len(media.Files.File[0].Link) == 0 {
+		return offset, fmt.Errorf("no upload link for media %s", media.Name)
+	}
+	uploadURL, err := url.ParseRequestURI(media.Files.File[0].Link[0].HREF)
+	if err != nil {
+		return offset, fmt.Errorf("error parsing upload link: %w", err)
+	}
+
+	received, err := d.mediaBytesTransferred(media)
+	if err != nil {
+		return offset, err
+	}
+	offset = min(offset, received)
+	if offset > 0 {
+		log.Printf("[INFO] Resuming upload of media %s at %d of %d bytes", media.Name, offset, size)
+	}
+
+	// The first failed range stops the others
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type rangeResult struct {
+		offset, n int64
+		err       error
+	}
+	next := make(chan int64)
+	results := make(chan rangeResult)
+	var wg sync.WaitGroup
+	for range max(d.uploadParallelism, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range next {
+				n := min(int64(uploadPieceSize), size-start)
+				err := d.uploadRangeWithRetries(ctx, uploadURL, f, start, n, size)
+				results <- rangeResult{start, n, err}
+			}
+		}()
+	}
+	go func() {
+		defer close(next)
+		for start := offset; start < size; start += uploadPieceSize {
+			select {
+			case next <- start:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	completed := make(map[int64]int64) // offset -> length of ranges done out of order
+	var firstErr error
+	reportedAt := time.Now()
+	for r := range results {
+		if r.err != nil {
+			if firstErr == nil {
+				firstErr = r.err
+				cancel()
 			}
-			uploadName = fmt.Sprintf("%s-retry%d%s", base, attempt, ext)
-			fmt.Printf("Upload attempt %d/%d (previous failed: %v)\n", attempt, maxRetries, lastErr)
+			continue
+		}
+		completed[r.offset] = r.n
+		for n, ok := completed[offset]; ok; n, ok = completed[offset] {
+			delete(completed, offset)
+			offset += n
+		}
+		log.Printf("[DEBUG] Uploaded %d of %d bytes of media %s", offset, size, media.Name)
+		if time.Since(reportedAt) >= progressReportInterval && offset < size {
+			reportedAt = time.Now()
+			reportProgress(ctx, fmt.Sprintf("Uploaded %d of %d MB (%d%%)", offset>>20, size>>20, offset*100/size))
 		}
+	}
+	return offset, firstErr
+}
+
+// uploadRangeWithRetries uploads the n bytes of a media file at offset,
+// retrying the range after transient failures.
+func (d *VCDDriver) uploadRangeWithRetries(ctx context.Context, uploadURL *url.URL, f io.ReaderAt, offset, n, size int64) error {
+	const maxRangeRetries = 3
 
-		media, err := d.uploadMediaOnce(catalog, uploadName, description, filePath, uploadPieceSize)
+	for retries := 0; ; retries++ {
+		r := limitBandwidth(ctx, io.NewSectionReader(f, offset, n), d.uploadLimiter)
+		err := d.uploadRange(ctx, uploadURL, r, offset, n, size)
 		if err == nil {
-			return media, nil
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if retries == maxRangeRetries {
+			return fmt.Errorf("upload of bytes %d-%d failed %d times: %w", offset, offset+n-1, retries+1, err)
+		}
+		log.Printf("[WARN] Upload of bytes %d-%d failed, retrying (%d/%d): %s",
+			offset, offset+n-1, retries+1, maxRangeRetries, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(retries+1) * 5 * time.Second):
 		}
-		lastErr = err
-		fmt.Printf("Upload attempt %d failed: %v\n", attempt, err)
 	}
+}
 
-	return nil, fmt.Errorf("upload failed after %d attempts: %w", maxRetries, lastErr)
+// uploadRange uploads the bytes of a media file from offset, of length n,
+// out of size.
+func (d *VCDDriver) uploadRange(ctx context.Context, uploadURL *url.URL, r io.Reader, offset, n, size int64) error {
+	req := d.client.Client.NewRequest(map[string]string{}, http.MethodPut, *uploadURL, r)
+	req = req.WithContext(ctx)
+	req.ContentLength = n
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+n-1, size))
+	resp, err := d.client.Client.Http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("upload failed with status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
 }
 
-func (d *VCDDriver) uploadMediaOnce(catalog *govcd.Catalog, name, description, filePath string, uploadPieceSize int64) (*govcd.Media, error) {
-	uploadTask, err := catalog.UploadMediaImage(name, description, filePath, uploadPieceSize)
+// mediaBytesTransferred returns how much of the file of a media item VCD has
+// received.
+func (d *VCDDriver) mediaBytesTransferred(media *types.Media) (int64, error) {
+	current := &types.Media{}
+	_, err := d.client.Client.ExecuteRequest(media.HREF, http.MethodGet, "",
+		"error getting media: %s", nil, current)
 	if err != nil {
-		return nil, fmt.Errorf("error starting media upload: %w", err)
+		return 0, err
 	}
+	if current.Files == nil || len(current.Files.File) == 0 {
+		return 0, fmt.Errorf("no file in media %s", media.Name)
+	}
+	return current.Files.File[0].BytesTransferred, nil
+}
 
-	// Wait for upload to complete - must check returned error
-	err = uploadTask.ShowUploadProgress()
+// createMedia creates a media item of imageType, iso or floppy, without its
+// file, which is uploaded to the upload link of the returned media.
+func (d *VCDDriver) createMedia(catalog *govcd.Catalog, name, imageType string, size int64, description string) (*govcd.Media, error) {
+	addLink := ""
+	for _, link := range catalog.Catalog.Link {
+		if link.Rel == "add" && link.Type == types.MimeMediaItem {
+			addLink = link.HREF
+			break
+		}
+	}
+	if addLink == "" {
+		return nil, fmt.Errorf("catalog %s does not accept media", catalog.Catalog.Name)
+	}
+
+	// The catalog item is returned, with the media as its entity
+	item := &types.Media{}
+	_, err := d.client.Client.ExecuteRequest(addLink, http.MethodPost, types.MimeMediaItem,
+		"error creating "+imageType+" media: %w", &CreateMediaParams{
+			Xmlns:       types.XMLNamespaceVCloud,
+			Name:        name,
+			ImageType:   imageType,
+			Size:        size,
+			Description: description,
+		}, item)
 	if err != nil {
-		return nil, fmt.Errorf("error during media upload: %w", err)
+		if isDuplicateName(err) {
+			return nil, fmt.Errorf("%w: %s", ErrMediaNameTaken, name)
+		}
+		return nil, err
+	}
+	if item.Entity == nil {
+		return nil, fmt.Errorf("error creating %s media %s: no media in the response", imageType, name)
 	}
 
-	// Wait for VCD to import/process the media (separate from upload)
-	err = uploadTask.WaitTaskCompletion()
+	media := govcd.NewMedia(&d.client.Client)
+	media.Media = &types.Media{}
+	_, err = d.client.Client.ExecuteRequest(item.Entity.HREF, http.MethodGet, "",
+		"error getting "+imageType+" media: %s", nil, media.Media)
 	if err != nil {
-		return nil, fmt.Errorf("error waiting for media import: %w", err)
+		return nil, err
 	}
+	return media, nil
+}
 
-	// Wait for media to reach RESOLVED status (status = 1)
-	// Even after task completion, VCD may still be processing internally
+// waitMediaImport waits for VCD to import the uploaded file of a media item
+// created by createMedia.
+func (d *VCDDriver) waitMediaImport(ctx context.Context, media *govcd.Media) error {
+	if media.Media.Tasks == nil {
+		return nil
+	}
+	for _, t := range media.Media.Tasks.Task {
+		task := govcd.NewTask(&d.client.Client)
+		task.Task = t
+		if err := WaitTask(ctx, task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UploadFloppyImage uploads a floppy image to the catalog. govcd only
+// creates ISO media, so the media item is created here, and the image,
+// 1.44 MB at most, is uploaded in a single request.
+func (d *VCDDriver) UploadFloppyImage(ctx context.Context, catalog *govcd.Catalog, name, description, filePath string) (*govcd.Media, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening floppy image: %w", err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("error opening floppy image: %w", err)
+	}
+
+	media, err := d.createMedia(catalog, name, "floppy", fi.Size(), description)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.uploadFloppyFile(ctx, media.Media, f, fi.Size()); err != nil {
+		// A media without its file stays unresolved forever
+		if task, delErr := media.Delete(); delErr == nil {
+			_ = task.WaitTaskCompletion()
+		}
+		return nil, fmt.Errorf("error uploading floppy media %s: %w", name, err)
+	}
+
+	// VCD imports the file once it is complete
+	if err := d.waitMediaImport(ctx, media); err != nil {
+		return nil, fmt.Errorf("error importing floppy media %s: %w", name, err)
+	}
+
+	uploaded, err := catalog.GetMediaByName(name, true)
+	if err != nil {
+		return nil, fmt.Errorf("error getting uploaded media %s: %w", name, err)
+	}
+	return uploaded, nil
+}
+
+// uploadFloppyFile uploads the file of a media item created without one.
+func (d *VCDDriver) uploadFloppyFile(ctx context.Context, media *types.Media, r io.Reader, size int64) error {
+	if media.Files == nil || len(media.Files.File) == 0 || len(media.Files.File[0].Link) == 0 {
+		return fmt.Errorf("no upload link for media %s", media.Name)
+	}
+	uploadURL, err := url.ParseRequestURI(media.Files.File[0].Link[0].HREF)
+	if err != nil {
+		return fmt.Errorf("error parsing upload link: %w", err)
+	}
+
+	req := d.client.Client.NewRequest(map[string]string{}, http.MethodPut, *uploadURL, r)
+	req = req.WithContext(ctx)
+	req.ContentLength = size
+	resp, err := d.client.Client.Http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("upload failed with status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// isDuplicateName reports whether err is VCD refusing a catalog item name
+// that is already used.
+func isDuplicateName(err error) bool {
+	return hasMinorErrorCode(err, "DUPLICATE_NAME")
+}
+
+// hasMinorErrorCode reports whether err wraps a VCD API error with the minor
+// error code. The error message alone doesn't carry the code.
+func hasMinorErrorCode(err error, code string) bool {
+	var apiErr *types.Error
+	return errors.As(err, &apiErr) && apiErr.MinorErrorCode == code
+}
+
+// mediaStatusName returns a readable name for a media status code.
+func mediaStatusName(status int64) string {
+	switch status {
+	case -1:
+		return "FAILED_CREATION"
+	case 0:
+		return "UNRESOLVED"
+	case 1:
+		return "RESOLVED"
+	case 2:
+		return "DEPLOYED"
+	case 3:
+		return "SUSPENDED"
+	case 4:
+		return "POWERED_ON"
+	case 5:
+		return "WAITING_FOR_INPUT"
+	case 6:
+		return "UNKNOWN"
+	case 7:
+		return "UNRECOGNIZED"
+	case 8:
+		return "POWERED_OFF"
+	default:
+		return strconv.FormatInt(status, 10)
+	}
+}
+
+// WaitForMediaResolved polls a catalog media item, by ID, until VCD has
+// finished importing it (status RESOLVED) and returns it. report, if set, is
+// called whenever the status changes.
+func (d *VCDDriver) WaitForMediaResolved(ctx context.Context, catalog *govcd.Catalog, mediaID string, timeout time.Duration, report func(status string)) (*govcd.Media, error) {
+	const pollInterval = 10 * time.Second
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	lastStatus := ""
+	for {
+		media, err := d.GetMediaById(catalog, mediaID)
+		if err != nil {
+			return nil, err
+		}
+		name := media.Media.Name
+
+		status := mediaStatusName(media.Media.Status)
+		if status != lastStatus {
+			lastStatus = status
+			if report != nil {
+				report(status)
+			}
+		}
+
+		switch media.Media.Status {
+		case 1:
+			return media, nil
+		case -1:
+			return nil, fmt.Errorf("media %s failed to import (status %s)", name, status)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("media %s did not resolve within %v (status %s)", mediaID, timeout, status)
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// --- Template Operations ---
+
+// UploadOvf uploads a local OVA, or an OVF together with the files it
+// references, into the catalog as a vApp template and waits until the
+// template is ready to be instantiated.
+func (d *VCDDriver) UploadOvf(ctx context.Context, catalog *govcd.Catalog, name, description, filePath string) (*govcd.VAppTemplate, error) {
+	const uploadPieceSize = 50 * 1024 * 1024
+
+	uploadTask, err := catalog.UploadOvf(filePath, name, description, uploadPieceSize)
+	if err != nil {
+		return nil, fmt.Errorf("error starting OVF upload: %w", err)
+	}
+
+	err = d.waitUpload(ctx, &uploadTask)
+	if err != nil {
+		return nil, fmt.Errorf("error during OVF upload: %w", err)
+	}
+
+	// Wait for the template to reach status 8 (resolved and powered off),
+	// which is required before it can be instantiated
 	maxStatusRetries := 30
 	statusRetryDelay := 10 * time.Second
 
-	var media *govcd.Media
+	// The name is only needed to find the new template; poll it by ID so a
+	// template renamed or created with the same name meanwhile is not picked
+	template, err := catalog.GetVAppTemplateByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("error getting imported vApp template %s: %w", name, err)
+	}
+	templateID := template.VAppTemplate.ID
+
 	for i := 0; i < maxStatusRetries; i++ {
-		media, err = catalog.GetMediaByName(name, true)
+		template, err = catalog.GetVAppTemplateById(templateID)
 		if err != nil {
-			return nil, fmt.Errorf("error getting uploaded media %s: %w", name, err)
+			return nil, fmt.Errorf("error getting imported vApp template %s: %w", name, err)
 		}
 
-		if media.Media.Status == 1 { // RESOLVED
-			return media, nil
+		if template.VAppTemplate.Status == 8 {
+			return template, nil
 		}
 
-		fmt.Printf("Media status is %d (need 1=RESOLVED), waiting %v... (%d/%d)\n",
-			media.Media.Status, statusRetryDelay, i+1, maxStatusRetries)
-		time.Sleep(statusRetryDelay)
+		log.Printf("[DEBUG] vApp template status is %d (need 8=POWERED_OFF), waiting %v... (%d/%d)",
+			template.VAppTemplate.Status, statusRetryDelay, i+1, maxStatusRetries)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(statusRetryDelay):
+		}
 	}
 
-	return nil, fmt.Errorf("media %s never reached RESOLVED status after upload (current: %d)", name, media.Media.Status)
+	return nil, fmt.Errorf("vApp template %s never became ready after import (current status: %d)", name, template.VAppTemplate.Status)
 }
 
-// --- Template Operations ---
+// CopyVAppTemplate copies a vApp template into another catalog, which may
+// belong to a different organization, and waits for the copy to finish.
+func (d *VCDDriver) CopyVAppTemplate(ctx context.Context, source *govcd.Catalog, name string, target *govcd.Catalog, targetName, description string) error {
+	item, err := source.GetCatalogItemByName(name, true)
+	if err != nil {
+		return fmt.Errorf("error getting catalog item %s: %w", name, err)
+	}
+
+	params := &CopyOrMoveCatalogItemParams{
+		Xmlns:       types.XMLNamespaceVCloud,
+		Name:        targetName,
+		Description: description,
+		Source: &types.Reference{
+			HREF: item.CatalogItem.HREF,
+		},
+	}
+
+	task, err := d.client.Client.ExecuteTaskRequest(
+		target.Catalog.HREF+"/action/copy",
+		http.MethodPost,
+		"application/vnd.vmware.vcloud.copyOrMoveCatalogItemParams+xml",
+		"error copying catalog item: %s",
+		params,
+	)
+	if err != nil {
+		return fmt.Errorf("error copying %s to catalog %s: %w", name, target.Catalog.Name, err)
+	}
+
+	if err := WaitTask(ctx, &task); err != nil {
+		return fmt.Errorf("error waiting for copy of %s to catalog %s: %w", name, target.Catalog.Name, err)
+	}
+	return nil
+}
 
 // MakeTemplatePoliciesNonFinal fetches the raw XML of a vApp template,
 // sets VmSizingPolicyFinal and VmPlacementPolicyFinal to false via string
@@ -656,43 +1517,52 @@ func (d *VCDDriver) MakeTemplatePoliciesNonFinal(template *govcd.VAppTemplate) e
 
 // --- Internal helpers ---
 
-func newClient(apiURL url.URL, org string, username string, password string, token string, insecure bool) (*govcd.VCDClient, error) {
+func newClient(apiURL url.URL, config *ConnectConfig, proxy ProxyFunc, tlsConfig *tls.Config) (*govcd.VCDClient, error) {
+	session := newSessionTransport(newRetryTransport(newRateLimitTransport(newAPILogTransport(&http.Transport{
+		TLSClientConfig:     tlsConfig,
+		Proxy:               proxy,
+		TLSHandshakeTimeout: 120 * time.Second,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       0, // No idle timeout - keep connections alive
+		ExpectContinueTimeout: 10 * time.Second,
+	}, config.APILog), config.RateLimit), config.RetryCount, config.RetryWait))
+
 	client := &govcd.VCDClient{
 		Client: govcd.Client{
 			VCDHREF:    apiURL,
-			APIVersion: vcdAPIVersion,
+			APIVersion: supportedAPIVersions[0],
 			Http: http.Client{
-				Transport: &http.Transport{
-					TLSClientConfig: &tls.Config{
-						InsecureSkipVerify: insecure,
-					},
-					Proxy:               http.ProxyFromEnvironment,
-					TLSHandshakeTimeout: 120 * time.Second,
-					DialContext: (&net.Dialer{
-						Timeout:   30 * time.Second,
-						KeepAlive: 30 * time.Second,
-					}).DialContext,
-					MaxIdleConns:          100,
-					IdleConnTimeout:       0, // No idle timeout - keep connections alive
-					ExpectContinueTimeout: 10 * time.Second,
-				},
-				Timeout: 0, // No timeout - uploads can take a long time
+				Transport: newAbortTransport(session),
+				Timeout:   0, // No timeout - uploads can take a long time
 			},
 			MaxRetryTimeout: 60,
 		},
 	}
 
-	if token != "" {
-		err := client.SetToken(org, govcd.ApiTokenHeader, token)
-		if err != nil {
-			return nil, fmt.Errorf("unable to authenticate to Org %q: %w", org, err)
-		}
-	} else {
-		err := client.Authenticate(username, password, org)
-		if err != nil {
-			return nil, fmt.Errorf("unable to authenticate to Org %q: %w", org, err)
-		}
+	version, err := negotiateAPIVersion(client)
+	if err != nil {
+		return nil, err
 	}
+	client.Client.APIVersion = version
+	log.Printf("[INFO] Using VCD API version %s", version)
+
+	if err := authenticate(client, config); err != nil {
+		return nil, err
+	}
+
+	// Only refresh sessions once the initial login succeeded, so bad
+	// credentials fail immediately
+	session.start(clientToken(client), func() (*sessionToken, error) {
+		log.Printf("[INFO] VCD session expired, re-authenticating to Org %q", config.Org)
+		if err := authenticate(client, config); err != nil {
+			return nil, err
+		}
+		return clientToken(client), nil
+	})
 
 	return client, nil
 }
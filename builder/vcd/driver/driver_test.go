@@ -0,0 +1,31 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/vmware/go-vcloud-director/v3/types/v56"
+)
+
+func TestIsDuplicateName(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"duplicate name", fmt.Errorf("error creating iso media: %w",
+			&types.Error{MajorErrorCode: 400, MinorErrorCode: "DUPLICATE_NAME", Message: "The VCD entity install.iso already exists."}), true},
+		{"other minor code", fmt.Errorf("error creating iso media: %w",
+			&types.Error{MajorErrorCode: 400, MinorErrorCode: "BAD_REQUEST", Message: "Storage profile already exists."}), false},
+		{"message only", errors.New("media item 'install.iso' already exists. Upload with different name"), false},
+		{"not an API error", errors.New("connection reset by peer"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDuplicateName(tt.err); got != tt.want {
+				t.Errorf("isDuplicateName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
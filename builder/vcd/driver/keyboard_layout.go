@@ -0,0 +1,151 @@
+package driver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// KeyStroke is how a character is typed on a keyboard layout: a key, named
+// as in VScanCodes after its position on a US keyboard, and the modifiers
+// held while pressing it.
+type KeyStroke struct {
+	ScanCode int
+	Shift    bool
+	AltGr    bool
+	// Dead keys only print once another key is pressed; a space is sent
+	// after them.
+	Dead bool
+}
+
+// keyLevels are the characters of a key: unshifted, with shift, and with
+// AltGr. Zero means the level types nothing useful.
+type keyLevels [3]rune
+
+// keyboardLayout describes the keys of a layout whose characters differ from
+// the letters a-z at their US positions. Dead lists, per key, the characters
+// it types as a dead key.
+type keyboardLayout struct {
+	keys map[string]keyLevels
+	dead map[string]string
+}
+
+// keyboardLayouts are the layouts of boot_keyboard_layout, as configured in
+// Linux (xkb) guests.
+var keyboardLayouts = map[string]keyboardLayout{
+	"us": {
+		keys: map[string]keyLevels{
+			"BACKTICK": {'`', '~'}, "1": {'1', '!'}, "2": {'2', '@'}, "3": {'3', '#'},
+			"4": {'4', '$'}, "5": {'5', '%'}, "6": {'6', '^'}, "7": {'7', '&'},
+			"8": {'8', '*'}, "9": {'9', '('}, "0": {'0', ')'}, "MINUS": {'-', '_'},
+			"EQUALS": {'=', '+'}, "LBRACKET": {'[', '{'}, "RBRACKET": {']', '}'},
+			"BACKSLASH": {'\\', '|'}, "SEMICOLON": {';', ':'}, "QUOTE": {'\'', '"'},
+			"COMMA": {',', '<'}, "PERIOD": {'.', '>'}, "SLASH": {'/', '?'},
+		},
+	},
+	"uk": {
+		keys: map[string]keyLevels{
+			"BACKTICK": {'`', '¬', '¦'}, "1": {'1', '!'}, "2": {'2', '"'}, "3": {'3', '£'},
+			"4": {'4', '$', '€'}, "5": {'5', '%'}, "6": {'6', '^'}, "7": {'7', '&'},
+			"8": {'8', '*'}, "9": {'9', '('}, "0": {'0', ')'}, "MINUS": {'-', '_'},
+			"EQUALS": {'=', '+'}, "LBRACKET": {'[', '{'}, "RBRACKET": {']', '}'},
+			"SEMICOLON": {';', ':'}, "QUOTE": {'\'', '@'}, "BACKSLASH": {'#', '~'},
+			"INTL": {'\\', '|'}, "COMMA": {',', '<'}, "PERIOD": {'.', '>'}, "SLASH": {'/', '?'},
+		},
+	},
+	"de": {
+		keys: map[string]keyLevels{
+			"BACKTICK": {'^', '°'}, "1": {'1', '!'}, "2": {'2', '"', '²'}, "3": {'3', '§', '³'},
+			"4": {'4', '$'}, "5": {'5', '%'}, "6": {'6', '&'}, "7": {'7', '/', '{'},
+			"8": {'8', '(', '['}, "9": {'9', ')', ']'}, "0": {'0', '=', '}'},
+			"MINUS": {'ß', '?', '\\'}, "EQUALS": {'´', '`'},
+			"LBRACKET": {'ü', 'Ü'}, "RBRACKET": {'+', '*', '~'},
+			"SEMICOLON": {'ö', 'Ö'}, "QUOTE": {'ä', 'Ä'}, "BACKSLASH": {'#', '\''},
+			"INTL": {'<', '>', '|'}, "COMMA": {',', ';'}, "PERIOD": {'.', ':'}, "SLASH": {'-', '_'},
+			"Q": {'q', 'Q', '@'}, "E": {'e', 'E', '€'}, "M": {'m', 'M', 'µ'},
+			"Y": {'z', 'Z'}, "Z": {'y', 'Y'},
+		},
+		dead: map[string]string{"BACKTICK": "^", "EQUALS": "´`"},
+	},
+	"fr": {
+		keys: map[string]keyLevels{
+			"BACKTICK": {'²'}, "1": {'&', '1'}, "2": {'é', '2', '~'}, "3": {'"', '3', '#'},
+			"4": {'\'', '4', '{'}, "5": {'(', '5', '['}, "6": {'-', '6', '|'},
+			"7": {'è', '7', '`'}, "8": {'_', '8', '\\'}, "9": {'ç', '9', '^'},
+			"0": {'à', '0', '@'}, "MINUS": {')', '°', ']'}, "EQUALS": {'=', '+', '}'},
+			"LBRACKET": {'^', '¨'}, "RBRACKET": {'$', '£', '¤'},
+			"SEMICOLON": {'m', 'M'}, "QUOTE": {'ù', '%'}, "BACKSLASH": {'*', 'µ'},
+			"INTL": {'<', '>'}, "M": {',', '?'}, "COMMA": {';', '.'}, "PERIOD": {':', '/'},
+			"SLASH": {'!', '§'}, "Q": {'a', 'A'}, "A": {'q', 'Q'}, "W": {'z', 'Z'},
+			"Z": {'w', 'W'}, "E": {'e', 'E', '€'},
+		},
+		dead: map[string]string{"LBRACKET": "^¨"},
+	},
+	"es": {
+		keys: map[string]keyLevels{
+			"BACKTICK": {'º', 'ª', '\\'}, "1": {'1', '!', '|'}, "2": {'2', '"', '@'},
+			"3": {'3', '·', '#'}, "4": {'4', '$', '~'}, "5": {'5', '%', '€'},
+			"6": {'6', '&', '¬'}, "7": {'7', '/'}, "8": {'8', '('}, "9": {'9', ')'},
+			"0": {'0', '='}, "MINUS": {'\'', '?'}, "EQUALS": {'¡', '¿'},
+			"LBRACKET": {'`', '^', '['}, "RBRACKET": {'+', '*', ']'},
+			"SEMICOLON": {'ñ', 'Ñ'}, "QUOTE": {'´', '¨', '{'}, "BACKSLASH": {'ç', 'Ç', '}'},
+			"INTL": {'<', '>'}, "COMMA": {',', ';'}, "PERIOD": {'.', ':'}, "SLASH": {'-', '_'},
+			"E": {'e', 'E', '€'},
+		},
+		dead: map[string]string{"LBRACKET": "`^", "QUOTE": "´¨"},
+	},
+}
+
+// KeyboardLayoutNames returns the supported keyboard layouts.
+func KeyboardLayoutNames() []string {
+	names := make([]string, 0, len(keyboardLayouts))
+	for name := range keyboardLayouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// KeyboardLayout returns how each character is typed on the named layout.
+// When a character can be typed on several keys, the one needing the fewest
+// modifiers is used.
+func KeyboardLayout(name string) (map[rune]KeyStroke, error) {
+	layout, ok := keyboardLayouts[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown keyboard layout %q, must be one of: %s",
+			name, strings.Join(KeyboardLayoutNames(), ", "))
+	}
+
+	keys := map[string]keyLevels{"SPACE": {' '}}
+	for c := 'a'; c <= 'z'; c++ {
+		keys[strings.ToUpper(string(c))] = keyLevels{c, c - 'a' + 'A'}
+	}
+	for key, levels := range layout.keys {
+		keys[key] = levels
+	}
+
+	strokes := make(map[rune]KeyStroke)
+	rank := make(map[rune]int)
+	for key, levels := range keys {
+		scanCode, ok := VScanCodes[key]
+		if !ok {
+			return nil, fmt.Errorf("keyboard layout %s: unknown key %s", name, key)
+		}
+		for level, char := range levels {
+			if char == 0 {
+				continue
+			}
+			if r, seen := rank[char]; seen && (r < level || r == level && strokes[char].ScanCode < scanCode) {
+				continue
+			}
+			rank[char] = level
+			strokes[char] = KeyStroke{
+				ScanCode: scanCode,
+				Shift:    level == 1,
+				AltGr:    level == 2,
+				Dead:     strings.ContainsRune(layout.dead[key], char),
+			}
+		}
+	}
+	return strokes, nil
+}
@@ -0,0 +1,54 @@
+package driver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+)
+
+// Name lookups go through the query service or a parent's children list, so
+// a resource renamed mid-build, or another one created with the same name,
+// can make a later step pick the wrong entity. Once a resource exists its ID
+// never changes, so steps that already hold one should use the lookups below.
+// They build the entity HREF from the ID and fetch it directly.
+
+// entityHREF returns the API HREF of the entity with the given ID, e.g.
+// urn:vcloud:media:<uuid> and path "media" give <api>/media/<uuid>.
+func (d *VCDDriver) entityHREF(path, prefix, id string) string {
+	uuid := id[strings.LastIndex(id, ":")+1:]
+	href := d.client.Client.VCDHREF
+	href.Path += "/" + path + "/" + prefix + uuid
+	return href.String()
+}
+
+// GetMediaById returns the catalog media with the given ID.
+func (d *VCDDriver) GetMediaById(catalog *govcd.Catalog, id string) (*govcd.Media, error) {
+	media, err := catalog.GetMediaByHref(d.entityHREF("media", "", id))
+	if err != nil {
+		return nil, fmt.Errorf("error getting media %s: %w", id, err)
+	}
+	return media, nil
+}
+
+// GetCatalogItemById returns the catalog item with the given ID.
+func (d *VCDDriver) GetCatalogItemById(catalog *govcd.Catalog, id string) (*govcd.CatalogItem, error) {
+	item, err := catalog.GetCatalogItemByHref(d.entityHREF("catalogItem", "", id))
+	if err != nil {
+		return nil, fmt.Errorf("error getting catalog item %s: %w", id, err)
+	}
+	return item, nil
+}
+
+// GetVAppById returns the vApp with the given ID.
+func (d *VCDDriver) GetVAppById(id string) (*govcd.VApp, error) {
+	org, err := d.GetOrg()
+	if err != nil {
+		return nil, err
+	}
+	vapp, err := org.GetVAppByHref(d.entityHREF("vApp", "vapp-", id))
+	if err != nil {
+		return nil, fmt.Errorf("error getting vApp %s: %w", id, err)
+	}
+	return vapp, nil
+}
@@ -0,0 +1,60 @@
+package driver
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+	"github.com/vmware/go-vcloud-director/v3/types/v56"
+)
+
+// MediaChecksumMetadataKey is the metadata entry holding the checksum of the
+// ISO a media was uploaded from, e.g. "sha256:<hex>". FindMediaByChecksum
+// looks media up by it.
+const MediaChecksumMetadataKey = "packer.iso_checksum"
+
+// SetMediaChecksum records the checksum of the ISO a media was uploaded from.
+func (d *VCDDriver) SetMediaChecksum(media *govcd.Media, checksum string) error {
+	if err := media.AddMetadataEntry(types.MetadataStringValue, MediaChecksumMetadataKey, checksum); err != nil {
+		return fmt.Errorf("error setting checksum metadata on media %s: %w", media.Media.Name, err)
+	}
+	return nil
+}
+
+// FindMediaByChecksum returns a resolved ISO media whose checksum metadata is
+// checksum, in any catalog the user can read, including catalogs shared
+// with the organization, and the name of that catalog. It returns a nil
+// media if there is none.
+func (d *VCDDriver) FindMediaByChecksum(checksum string) (*govcd.Media, string, error) {
+	queryType := d.client.Client.GetQueryType(types.QtMedia)
+	filter := fmt.Sprintf("isIso==true;metadata:%s==STRING:%s", MediaChecksumMetadataKey, url.QueryEscape(checksum))
+	if queryType == types.QtAdminMedia {
+		// Provider administrators see the media of every organization
+		org, err := d.GetOrg()
+		if err != nil {
+			return nil, "", err
+		}
+		filter += ";org==" + org.Org.HREF
+	}
+
+	results, err := d.queryAll(queryType, filter)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, r := range append(results.MediaRecord, results.AdminMediaRecord...) {
+		if r.Status != "RESOLVED" || r.IsBusy {
+			log.Printf("[DEBUG] Skipping media %s in catalog %s: status %s, busy %t", r.Name, r.CatalogName, r.Status, r.IsBusy)
+			continue
+		}
+		media := govcd.NewMedia(&d.client.Client)
+		media.Media.HREF = r.HREF
+		if err := media.Refresh(); err != nil {
+			log.Printf("[WARN] Could not read media %s in catalog %s: %s", r.Name, r.CatalogName, err)
+			continue
+		}
+		return media, r.CatalogName, nil
+	}
+	return nil, "", nil
+}
@@ -0,0 +1,99 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+	"github.com/vmware/go-vcloud-director/v3/types/v56"
+)
+
+// PortForwardSpec describes a temporary DNAT rule forwarding a port of an
+// NSX-T edge gateway's external address to a port of the VM.
+type PortForwardSpec struct {
+	Name         string
+	Protocol     string // TCP or UDP
+	ExternalIP   string
+	ExternalPort int
+	InternalIP   string
+	InternalPort int
+}
+
+// PortForward is a DNAT rule created by CreatePortForward, together with the
+// application port profile holding its internal port.
+type PortForward struct {
+	Spec    PortForwardSpec
+	rule    *govcd.NsxtNatRule
+	profile *govcd.NsxtAppPortProfile
+}
+
+// CreatePortForward creates a DNAT rule on the named NSX-T edge gateway. The
+// internal port is carried by a tenant application port profile created for
+// the rule, as VCD no longer accepts it on the rule itself. Both are removed
+// by DeletePortForward.
+func (d *VCDDriver) CreatePortForward(vdc *govcd.Vdc, edgeGatewayName string, spec PortForwardSpec) (*PortForward, error) {
+	org, err := d.GetOrg()
+	if err != nil {
+		return nil, err
+	}
+	egw, err := org.GetNsxtEdgeGatewayByName(edgeGatewayName)
+	if err != nil {
+		return nil, fmt.Errorf("error getting edge gateway %s: %w", edgeGatewayName, err)
+	}
+
+	protocol := strings.ToUpper(spec.Protocol)
+	profile, err := org.CreateNsxtAppPortProfile(&types.NsxtAppPortProfile{
+		Name:        spec.Name,
+		Description: "Temporary port forward created by Packer",
+		ApplicationPorts: []types.NsxtAppPortProfilePort{{
+			Protocol:         protocol,
+			DestinationPorts: []string{strconv.Itoa(spec.InternalPort)},
+		}},
+		OrgRef:          &types.OpenApiReference{ID: org.Org.ID, Name: org.Org.Name},
+		ContextEntityId: vdc.Vdc.ID,
+		Scope:           types.ApplicationPortProfileScopeTenant,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating application port profile %s: %w", spec.Name, err)
+	}
+
+	rule, err := egw.CreateNatRule(&types.NsxtNatRule{
+		Name:                   spec.Name,
+		Description:            "Temporary port forward created by Packer",
+		Enabled:                true,
+		RuleType:               types.NsxtNatRuleTypeDnat,
+		Type:                   types.NsxtNatRuleTypeDnat,
+		ExternalAddresses:      spec.ExternalIP,
+		InternalAddresses:      spec.InternalIP,
+		ApplicationPortProfile: &types.OpenApiReference{ID: profile.NsxtAppPortProfile.ID},
+		DnatExternalPort:       strconv.Itoa(spec.ExternalPort),
+	})
+	if err != nil {
+		if delErr := profile.Delete(); delErr != nil {
+			return nil, fmt.Errorf("error creating DNAT rule %s: %w (application port profile %s left behind: %v)",
+				spec.Name, err, spec.Name, delErr)
+		}
+		return nil, fmt.Errorf("error creating DNAT rule %s: %w", spec.Name, err)
+	}
+
+	return &PortForward{Spec: spec, rule: rule, profile: profile}, nil
+}
+
+// DeletePortForward removes a DNAT rule and its application port profile.
+// Rules or profiles already deleted by someone else are not an error.
+func (d *VCDDriver) DeletePortForward(pf *PortForward) error {
+	if pf.rule != nil {
+		if err := pf.rule.Delete(); err != nil && !govcd.ContainsNotFound(err) {
+			return fmt.Errorf("error deleting DNAT rule %s: %w", pf.Spec.Name, err)
+		}
+		pf.rule = nil
+	}
+	if pf.profile != nil {
+		if err := pf.profile.Delete(); err != nil && !govcd.ContainsNotFound(err) {
+			return fmt.Errorf("error deleting application port profile %s: %w", pf.Spec.Name, err)
+		}
+		pf.profile = nil
+	}
+	return nil
+}
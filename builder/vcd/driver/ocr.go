@@ -0,0 +1,56 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"os/exec"
+	"strings"
+)
+
+// OCRTool is the external program used to read text off console screenshots.
+const OCRTool = "tesseract"
+
+// CheckOCR returns an error when the OCR tool is not installed.
+func CheckOCR() error {
+	if _, err := exec.LookPath(OCRTool); err != nil {
+		return fmt.Errorf("%s not found in PATH, it is required to read text from the console", OCRTool)
+	}
+	return nil
+}
+
+// RecognizeText returns the text on a console screenshot, one line per
+// recognized line of text, with blank lines removed.
+func RecognizeText(ctx context.Context, img image.Image) ([]string, error) {
+	path, err := exec.LookPath(OCRTool)
+	if err != nil {
+		return nil, fmt.Errorf("%s not found in PATH: %w", OCRTool, err)
+	}
+
+	var in bytes.Buffer
+	if err := png.Encode(&in, img); err != nil {
+		return nil, fmt.Errorf("error encoding screenshot: %w", err)
+	}
+
+	// Page segmentation mode 6 reads the screen as one block of text,
+	// which suits text consoles and installer dialogs
+	var out, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, "stdin", "stdout", "--psm", "6")
+	cmd.Stdin = &in
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed: %w: %s", OCRTool, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var lines []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
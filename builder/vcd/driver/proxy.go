@@ -0,0 +1,99 @@
+package driver
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ProxyFunc selects the proxy of an API or console request, in the form
+// expected by http.Transport and websocket.Dialer.
+type ProxyFunc func(*http.Request) (*url.URL, error)
+
+// proxyFunc returns the proxy selection for the configured proxy_url and
+// no_proxy. Settings left empty fall back to the HTTPS_PROXY, HTTP_PROXY and
+// NO_PROXY environment variables. http, https and socks5 proxies are
+// supported by both the API client and the console websocket.
+func (c *ConnectConfig) proxyFunc() (ProxyFunc, error) {
+	if c.ProxyURL == "" && c.NoProxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	cfg := httpproxy.FromEnvironment()
+	if c.ProxyURL != "" {
+		if _, err := ParseProxyURL(c.ProxyURL); err != nil {
+			return nil, err
+		}
+		cfg.HTTPProxy = c.ProxyURL
+		cfg.HTTPSProxy = c.ProxyURL
+	}
+	if c.NoProxy != "" {
+		cfg.NoProxy = c.NoProxy
+	}
+
+	proxy := cfg.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}, nil
+}
+
+// ConsoleProxyDirect is the console_proxy_url value reaching the console
+// proxy without a proxy, when only the API needs one.
+const ConsoleProxyDirect = "direct"
+
+// consoleProxyFunc returns the proxy selection for the configured
+// console_proxy_url, or nil to use the API proxy. The console proxy of VCD
+// (the MKS host) often lives on another network than the API, only reachable
+// through a datacenter proxy.
+func (c *ConnectConfig) consoleProxyFunc() (ProxyFunc, error) {
+	switch c.ConsoleProxyURL {
+	case "":
+		return nil, nil
+	case ConsoleProxyDirect:
+		return func(*http.Request) (*url.URL, error) {
+			return nil, nil
+		}, nil
+	}
+
+	u, err := ParseProxyURL(c.ConsoleProxyURL)
+	if err != nil {
+		return nil, err
+	}
+	return http.ProxyURL(u), nil
+}
+
+// ParseProxyURL parses and validates a proxy_url setting.
+func ParseProxyURL(proxyURL string) (*url.URL, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %q: %w", proxyURL, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q: scheme must be http, https or socks5", proxyURL)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", proxyURL)
+	}
+	return u, nil
+}
+
+// Proxy returns the proxy selection used for the VCD API.
+func (d *VCDDriver) Proxy() ProxyFunc {
+	if d.proxy == nil {
+		return http.ProxyFromEnvironment
+	}
+	return d.proxy
+}
+
+// ConsoleProxy returns the proxy selection used for the console websocket:
+// console_proxy_url when set, the API proxy otherwise.
+func (d *VCDDriver) ConsoleProxy() ProxyFunc {
+	if d.consoleProxy == nil {
+		return d.Proxy()
+	}
+	return d.consoleProxy
+}
@@ -0,0 +1,201 @@
+package driver
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+	"github.com/vmware/go-vcloud-director/v3/types/v56"
+)
+
+// defaultQueryPageSize is the page size used for query service listings.
+// 128 is the largest page most VCD installations accept; the default of 25
+// makes listings on large tenants needlessly chatty.
+const defaultQueryPageSize = 128
+
+// ListVApps returns every vApp in the VDC, paging through the query service.
+func (d *VCDDriver) ListVApps(vdc *govcd.Vdc) ([]*types.QueryResultVAppRecordType, error) {
+	queryType := d.client.Client.GetQueryType(types.QtVapp)
+	results, err := d.queryAll(queryType, fmt.Sprintf("vdc==%s", vdc.Vdc.HREF))
+	if err != nil {
+		return nil, err
+	}
+	return append(results.VAppRecord, results.AdminVAppRecord...), nil
+}
+
+// ListVMs returns every VM of the vApps in the VDC, deployed or not (but not
+// the VMs inside vApp templates), paging through the query service.
+func (d *VCDDriver) ListVMs(vdc *govcd.Vdc) ([]*types.QueryResultVMRecordType, error) {
+	queryType := d.client.Client.GetQueryType(types.QtVm)
+	results, err := d.queryAll(queryType, fmt.Sprintf("isVAppTemplate==false;vdc==%s", vdc.Vdc.HREF))
+	if err != nil {
+		return nil, err
+	}
+	return append(results.VMRecord, results.AdminVMRecord...), nil
+}
+
+// ListCatalogs returns every catalog in the organization, paging through the
+// query service.
+func (d *VCDDriver) ListCatalogs() ([]*types.CatalogRecord, error) {
+	org, err := d.GetOrg()
+	if err != nil {
+		return nil, err
+	}
+
+	queryType := d.client.Client.GetQueryType(types.QtCatalog)
+	results, err := d.queryAll(queryType, fmt.Sprintf("org==%s", org.Org.HREF))
+	if err != nil {
+		return nil, err
+	}
+	return append(results.CatalogRecord, results.AdminCatalogRecord...), nil
+}
+
+// VAppTemplateInfo summarizes a vApp template returned by ListVAppTemplates.
+type VAppTemplateInfo struct {
+	Name        string
+	ID          string
+	HREF        string
+	Catalog     string
+	Description string
+	Status      string
+	Created     time.Time
+	// Storage allocated to the template, in bytes
+	SizeBytes int64
+}
+
+// VAppTemplateFilter narrows down ListVAppTemplates. Zero fields match every
+// template. Filtering is done by the query service, not client side.
+type VAppTemplateFilter struct {
+	// Name of the template. '*' matches any sequence of characters, e.g.
+	// "ubuntu-22.04-*".
+	Name string
+	// Only templates created at or after this time.
+	CreatedAfter time.Time
+	// Only templates created before this time.
+	CreatedBefore time.Time
+}
+
+// ListVAppTemplates returns the vApp templates of a catalog in the
+// organization that match the filter, newest first. An empty catalog name
+// lists the templates of every catalog.
+func (d *VCDDriver) ListVAppTemplates(catalog string, filter VAppTemplateFilter) ([]*VAppTemplateInfo, error) {
+	org, err := d.GetOrg()
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := []string{fmt.Sprintf("org==%s", org.Org.HREF)}
+	if catalog != "" {
+		conditions = append(conditions, fmt.Sprintf("catalogName==%s", url.QueryEscape(catalog)))
+	}
+	if filter.Name != "" {
+		conditions = append(conditions, fmt.Sprintf("name==%s", url.QueryEscape(filter.Name)))
+	}
+	if !filter.CreatedAfter.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("creationDate=ge=%s",
+			url.QueryEscape(filter.CreatedAfter.UTC().Format(time.RFC3339))))
+	}
+	if !filter.CreatedBefore.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("creationDate=lt=%s",
+			url.QueryEscape(filter.CreatedBefore.UTC().Format(time.RFC3339))))
+	}
+
+	queryType := d.client.Client.GetQueryType(types.QtVappTemplate)
+	results, err := d.queryAll(queryType, strings.Join(conditions, ";"))
+	if err != nil {
+		return nil, err
+	}
+
+	records := append(results.VappTemplateRecord, results.AdminVappTemplateRecord...)
+	templates := make([]*VAppTemplateInfo, 0, len(records))
+	for _, r := range records {
+		info := &VAppTemplateInfo{
+			Name:        r.Name,
+			ID:          r.ID,
+			HREF:        r.HREF,
+			Catalog:     r.CatalogName,
+			Description: r.Description,
+			Status:      r.Status,
+			SizeBytes:   int64(r.StorageKb) * 1024,
+		}
+		if r.CreationDate != "" {
+			created, err := time.Parse(time.RFC3339Nano, r.CreationDate)
+			if err != nil {
+				log.Printf("[WARN] Unparseable creation date %q for vApp template %s: %v", r.CreationDate, r.Name, err)
+			} else {
+				info.Created = created
+			}
+		}
+		templates = append(templates, info)
+	}
+
+	sort.SliceStable(templates, func(i, j int) bool {
+		return templates[i].Created.After(templates[j].Created)
+	})
+
+	return templates, nil
+}
+
+// queryAll runs a query service request and follows every result page,
+// returning the records of all pages merged together.
+func (d *VCDDriver) queryAll(queryType, filter string) (*types.QueryResultRecordsType, error) {
+	all := &types.QueryResultRecordsType{}
+	retrieved := 0
+
+	for page := 1; ; page++ {
+		params := map[string]string{
+			"type":          queryType,
+			"filterEncoded": "true",
+			"page":          strconv.Itoa(page),
+			"pageSize":      strconv.Itoa(d.pageSize),
+		}
+		if filter != "" {
+			params["filter"] = filter
+		}
+
+		result, err := d.client.Client.QueryWithNotEncodedParams(nil, params)
+		if err != nil {
+			return nil, fmt.Errorf("error querying %s (page %d): %w", queryType, page, err)
+		}
+
+		n := appendQueryRecords(all, result.Results)
+		retrieved += n
+		all.Total = result.Results.Total
+
+		log.Printf("[DEBUG] Query %s page %d: %d records (%d/%d)", queryType, page, n, retrieved, int(all.Total))
+
+		if n == 0 || retrieved >= int(all.Total) {
+			break
+		}
+	}
+
+	return all, nil
+}
+
+// appendQueryRecords merges the records of one result page into dst and
+// returns how many records the page held.
+func appendQueryRecords(dst, page *types.QueryResultRecordsType) int {
+	n := len(page.VMRecord) + len(page.AdminVMRecord) +
+		len(page.VAppRecord) + len(page.AdminVAppRecord) +
+		len(page.CatalogRecord) + len(page.AdminCatalogRecord) +
+		len(page.VappTemplateRecord) + len(page.AdminVappTemplateRecord) +
+		len(page.MediaRecord) + len(page.AdminMediaRecord)
+
+	dst.VMRecord = append(dst.VMRecord, page.VMRecord...)
+	dst.AdminVMRecord = append(dst.AdminVMRecord, page.AdminVMRecord...)
+	dst.VAppRecord = append(dst.VAppRecord, page.VAppRecord...)
+	dst.AdminVAppRecord = append(dst.AdminVAppRecord, page.AdminVAppRecord...)
+	dst.CatalogRecord = append(dst.CatalogRecord, page.CatalogRecord...)
+	dst.AdminCatalogRecord = append(dst.AdminCatalogRecord, page.AdminCatalogRecord...)
+	dst.VappTemplateRecord = append(dst.VappTemplateRecord, page.VappTemplateRecord...)
+	dst.AdminVappTemplateRecord = append(dst.AdminVappTemplateRecord, page.AdminVappTemplateRecord...)
+	dst.MediaRecord = append(dst.MediaRecord, page.MediaRecord...)
+	dst.AdminMediaRecord = append(dst.AdminMediaRecord, page.AdminMediaRecord...)
+
+	return n
+}
@@ -0,0 +1,210 @@
+package driver
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+)
+
+// Limit kinds reported by LimitError
+const (
+	LimitStorage      = "storage"
+	LimitCPU          = "CPU"
+	LimitMemory       = "memory"
+	LimitVMCount      = "VM count"
+	LimitRuntimeLease = "runtime lease"
+	LimitStorageLease = "storage lease"
+)
+
+// LimitError is returned by ExplainLimitError when VCD rejected an operation
+// because of an allocation quota of the VDC or organization, or because of
+// the organization's lease policy. Error names the limit, the usage VCD
+// reported or the driver could look up, and what to do about it; the raw
+// API error stays available through Unwrap.
+type LimitError struct {
+	Kind string
+	// Limit and Usage describe the limit that was hit, e.g. "102400 MB"
+	// and "98304 MB used". Either may be empty when unknown.
+	Limit string
+	Usage string
+	Hint  string
+	Err   error
+}
+
+func (e *LimitError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "VCD rejected the operation: %s limit reached", e.Kind)
+	switch {
+	case e.Limit != "" && e.Usage != "":
+		fmt.Fprintf(&b, " (limit %s, %s)", e.Limit, e.Usage)
+	case e.Limit != "":
+		fmt.Fprintf(&b, " (limit %s)", e.Limit)
+	case e.Usage != "":
+		fmt.Fprintf(&b, " (%s)", e.Usage)
+	}
+	if e.Hint != "" {
+		b.WriteString(". ")
+		b.WriteString(e.Hint)
+	}
+	fmt.Fprintf(&b, "\n\nVCD error: %s", vcdMessage(e.Err))
+	return b.String()
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+var limitPatterns = []struct {
+	kind     string
+	patterns []*regexp.Regexp
+}{
+	{LimitStorageLease, []*regexp.Regexp{
+		regexp.MustCompile(`(?i)storage lease.*(exceed|maximum|not allowed|greater than)`),
+	}},
+	{LimitRuntimeLease, []*regexp.Regexp{
+		regexp.MustCompile(`(?i)(runtime|deployment) lease.*(exceed|maximum|not allowed|greater than)`),
+		regexp.MustCompile(`(?i)lease.*(exceeds|maximum allowed)`),
+	}},
+	{LimitVMCount, []*regexp.Regexp{
+		regexp.MustCompile(`(?i)(vm|virtual machine)s? quota`),
+		regexp.MustCompile(`(?i)(maximum|quota of) (number of )?(running |deployed |stored )?(vms|virtual machines)`),
+	}},
+	{LimitStorage, []*regexp.Regexp{
+		regexp.MustCompile(`(?i)storage (quota|limit|allocation)`),
+		regexp.MustCompile(`(?i)(insufficient|not enough) (storage|disk space)`),
+		regexp.MustCompile(`(?i)storage (policy|profile).*(exceed|insufficient|not enough (space|capacity))`),
+	}},
+	{LimitCPU, []*regexp.Regexp{
+		regexp.MustCompile(`(?i)cpu (quota|limit|allocation|reservation)`),
+		regexp.MustCompile(`(?i)(insufficient|not enough) cpu`),
+	}},
+	{LimitMemory, []*regexp.Regexp{
+		regexp.MustCompile(`(?i)memory (quota|limit|allocation|reservation)`),
+		regexp.MustCompile(`(?i)(insufficient|not enough) memory`),
+	}},
+}
+
+// classifyLimitError returns the LimitStorage... kind of a VCD error, or ""
+// if the error is not about a quota or lease.
+func classifyLimitError(err error) string {
+	msg := err.Error()
+	for _, l := range limitPatterns {
+		for _, p := range l.patterns {
+			if p.MatchString(msg) {
+				return l.kind
+			}
+		}
+	}
+	return ""
+}
+
+// vcdMessage strips the request wrapping govcd adds around VCD's message,
+// e.g. "error instantiating ...: API Error: 400: [ <uuid> ] <message>".
+var vcdMessagePrefix = regexp.MustCompile(`^.*API Error: \d+: \[ [^\]]* \] `)
+
+func vcdMessage(err error) string {
+	return vcdMessagePrefix.ReplaceAllString(err.Error(), "")
+}
+
+// ExplainLimitError turns a VCD error caused by a quota or lease policy into
+// a LimitError, filling in the current usage of vdc (which may be nil) and
+// of the organization. Other errors are returned unchanged.
+func (d *VCDDriver) ExplainLimitError(err error, vdc *govcd.Vdc) error {
+	if err == nil {
+		return nil
+	}
+	kind := classifyLimitError(err)
+	if kind == "" {
+		return err
+	}
+
+	e := &LimitError{Kind: kind, Err: err}
+	switch kind {
+	case LimitStorage:
+		e.Hint = "Free up space in the VDC, choose another storage_profile, or ask your provider to raise the storage policy limit"
+		if vdc != nil {
+			e.Usage = d.storageUsage(vdc)
+		}
+	case LimitCPU, LimitMemory:
+		e.Hint = "Power off or delete other VMs in the VDC, lower the VM's cpus/memory, or ask your provider to raise the allocation"
+		if vdc != nil {
+			e.Limit, e.Usage = computeUsage(vdc, kind)
+		}
+	case LimitVMCount:
+		e.Hint = "Delete unused vApps, such as those left by failed builds, or ask your provider to raise the VM quota"
+		if vdc != nil {
+			e.Limit, e.Usage = d.vmCountUsage(vdc)
+		}
+	case LimitRuntimeLease, LimitStorageLease:
+		e.Hint = "The organization's lease policy does not allow the requested lease; ask your organization administrator to extend it"
+		e.Limit = d.leaseLimit(kind)
+	}
+	return e
+}
+
+func (d *VCDDriver) storageUsage(vdc *govcd.Vdc) string {
+	profiles, err := d.ListStorageProfiles(vdc)
+	if err != nil {
+		log.Printf("[DEBUG] Could not look up storage usage: %s", err)
+		return ""
+	}
+	var usage []string
+	for _, p := range profiles {
+		limit := "unlimited"
+		if p.LimitMB > 0 {
+			limit = fmt.Sprintf("%d MB", p.LimitMB)
+		}
+		usage = append(usage, fmt.Sprintf("%s: %d MB used of %s", p.Name, p.UsedMB, limit))
+	}
+	return strings.Join(usage, "; ")
+}
+
+func computeUsage(vdc *govcd.Vdc, kind string) (limit, usage string) {
+	if err := vdc.Refresh(); err != nil || len(vdc.Vdc.ComputeCapacity) == 0 {
+		return "", ""
+	}
+	capacity := vdc.Vdc.ComputeCapacity[0].CPU
+	if kind == LimitMemory {
+		capacity = vdc.Vdc.ComputeCapacity[0].Memory
+	}
+	if capacity == nil {
+		return "", ""
+	}
+	if capacity.Limit > 0 {
+		limit = fmt.Sprintf("%d %s", capacity.Limit, capacity.Units)
+	}
+	return limit, fmt.Sprintf("%d %s used, %d %s allocated", capacity.Used, capacity.Units, capacity.Allocated, capacity.Units)
+}
+
+func (d *VCDDriver) vmCountUsage(vdc *govcd.Vdc) (limit, usage string) {
+	if vdc.Vdc.VMQuota > 0 {
+		limit = fmt.Sprintf("%d VMs in VDC %s", vdc.Vdc.VMQuota, vdc.Vdc.Name)
+	}
+	if vms, err := d.ListVMs(vdc); err == nil {
+		usage = fmt.Sprintf("%d VMs in the VDC", len(vms))
+	}
+	return limit, usage
+}
+
+func (d *VCDDriver) leaseLimit(kind string) string {
+	adminOrg, err := d.GetAdminOrg()
+	if err != nil || adminOrg.AdminOrg.OrgSettings == nil || adminOrg.AdminOrg.OrgSettings.OrgVAppLeaseSettings == nil {
+		return ""
+	}
+	settings := adminOrg.AdminOrg.OrgSettings.OrgVAppLeaseSettings
+	seconds := settings.DeploymentLeaseSeconds
+	if kind == LimitStorageLease {
+		seconds = settings.StorageLeaseSeconds
+	}
+	if seconds == nil {
+		return ""
+	}
+	if *seconds == 0 {
+		return "never expires"
+	}
+	return (time.Duration(*seconds) * time.Second).String()
+}
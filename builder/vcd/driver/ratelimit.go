@@ -0,0 +1,120 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimitTransport spaces out requests to at most limit per second, with
+// bursts of up to one second's worth, so a build does not trip VCD's API
+// throttling. Retries count against the limit too.
+type rateLimitTransport struct {
+	base    http.RoundTripper
+	limiter *rate.Limiter
+}
+
+// newRateLimitTransport wraps base with a limiter of limit requests per
+// second. A limit of zero returns base unchanged.
+func newRateLimitTransport(base http.RoundTripper, limit float64) http.RoundTripper {
+	if limit <= 0 {
+		return base
+	}
+	burst := max(1, int(math.Ceil(limit)))
+	return &rateLimitTransport{
+		base:    base,
+		limiter: rate.NewLimiter(rate.Limit(limit), burst),
+	}
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// bandwidthUnits are the units of ParseBandwidth, in bytes per second.
+// Byte units are binary, as in the sizes of ISOs; bit units are decimal, as
+// in the speeds of network links.
+var bandwidthUnits = []struct {
+	suffix string
+	bytes  float64
+}{
+	{"gbit", 1e9 / 8},
+	{"mbit", 1e6 / 8},
+	{"kbit", 1e3 / 8},
+	{"gb", 1 << 30},
+	{"mb", 1 << 20},
+	{"kb", 1 << 10},
+	{"b", 1},
+}
+
+// ParseBandwidth parses an upload_bandwidth_limit setting, such as
+// "50MB/s" or "200Mbit/s", into bytes per second. An empty setting is 0,
+// for no limit.
+func ParseBandwidth(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	v := strings.ToLower(strings.TrimSpace(s))
+	v = strings.TrimSuffix(v, "/s")
+	for _, unit := range bandwidthUnits {
+		number, ok := strings.CutSuffix(v, unit.suffix)
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+		if err != nil || n <= 0 || n*unit.bytes < 1 {
+			break
+		}
+		return int64(n * unit.bytes), nil
+	}
+	return 0, fmt.Errorf("invalid bandwidth %q: expected a positive rate such as 50MB/s or 200Mbit/s", s)
+}
+
+// newBandwidthLimiter returns a limiter of limit bytes per second, with
+// bursts of up to one second's worth, shared by the uploads of the driver.
+// A limit of zero returns nil, for no limit.
+func newBandwidthLimiter(limit int64) *rate.Limiter {
+	if limit <= 0 {
+		return nil
+	}
+	burst := int(min(limit, math.MaxInt32))
+	return rate.NewLimiter(rate.Limit(limit), burst)
+}
+
+// bandwidthReader reads from r no faster than limiter allows.
+type bandwidthReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+// limitBandwidth returns r read no faster than limiter allows, or r itself
+// when limiter is nil.
+func limitBandwidth(ctx context.Context, r io.Reader, limiter *rate.Limiter) io.Reader {
+	if limiter == nil {
+		return r
+	}
+	return &bandwidthReader{ctx: ctx, r: r, limiter: limiter}
+}
+
+func (b *bandwidthReader) Read(p []byte) (int, error) {
+	if len(p) > b.limiter.Burst() {
+		p = p[:b.limiter.Burst()]
+	}
+	n, err := b.r.Read(p)
+	if n > 0 {
+		if werr := b.limiter.WaitN(b.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,37 @@
+package driver
+
+import "testing"
+
+func TestParseBandwidth(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"50MB/s", 50 << 20, false},
+		{"200Mbit/s", 25_000_000, false},
+		{"1.5GB/s", 3 << 29, false},
+		{"100kbit", 12_500, false},
+		{"512B/s", 512, false},
+		{" 10 mb/s ", 10 << 20, false},
+		{"1Gbit/s", 125_000_000, false},
+		{"fast", 0, true},
+		{"MB/s", 0, true},
+		{"0MB/s", 0, true},
+		{"-1MB/s", 0, true},
+		{"0.5B/s", 0, true},
+		{"10TB/s", 0, true},
+		{"100bit/s", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseBandwidth(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseBandwidth(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseBandwidth(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
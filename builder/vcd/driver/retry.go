@@ -0,0 +1,123 @@
+package driver
+
+import (
+	"errors"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultAPIRetryCount = 4
+	defaultAPIRetryWait  = 2 * time.Second
+	maxAPIRetryWait      = time.Minute
+)
+
+// retryTransport retries requests that failed with an error VCD cells
+// behind load balancers return transiently: 429, 5xx and reset or refused
+// connections. Waits grow exponentially with jitter, honouring
+// Retry-After when the server sends it.
+//
+// Only failures where the request cannot have been processed (429, 503, a
+// refused connection) are retried for every method. Other 5xx responses and
+// connection resets are only retried for idempotent methods, so a POST that
+// created something is never sent twice.
+type retryTransport struct {
+	base  http.RoundTripper
+	count int
+	wait  time.Duration
+}
+
+func newRetryTransport(base http.RoundTripper, count int, wait time.Duration) *retryTransport {
+	if count <= 0 {
+		count = defaultAPIRetryCount
+	}
+	if wait <= 0 {
+		wait = defaultAPIRetryWait
+	}
+	return &retryTransport{base: base, count: count, wait: wait}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if attempt >= t.count || !t.shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		// The body has been consumed; retry with a fresh copy
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return resp, err
+			}
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return resp, err
+			}
+			newReq := req.Clone(req.Context())
+			newReq.Body = body
+			req = newReq
+		}
+
+		wait := t.backoff(attempt, resp)
+		if resp != nil {
+			log.Printf("[DEBUG] %s %s returned %s, retrying in %v (%d/%d)", req.Method, req.URL.Path, resp.Status, wait, attempt+1, t.count)
+			// Drain so the connection can be reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		} else {
+			log.Printf("[DEBUG] %s %s failed: %s, retrying in %v (%d/%d)", req.Method, req.URL.Path, err, wait, attempt+1, t.count)
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (t *retryTransport) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		if req.Context().Err() != nil {
+			return false
+		}
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return true
+		}
+		return isIdempotent(req.Method) && (errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF))
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return isIdempotent(req.Method)
+	}
+	return false
+}
+
+// backoff returns the wait before the next attempt: the server's
+// Retry-After if present, otherwise a random duration up to wait * 2^attempt.
+func (t *retryTransport) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			return min(time.Duration(seconds)*time.Second, maxAPIRetryWait)
+		}
+	}
+
+	limit := min(t.wait<<attempt, maxAPIRetryWait)
+	return limit/2 + time.Duration(rand.Int63n(int64(limit/2)+1))
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,363 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// RFB rectangle encodings handled by the framebuffer
+const (
+	encRaw         = 0
+	encCopyRect    = 1
+	encTightPNG    = -260
+	encDesktopSize = -223
+)
+
+// Tight compression control values (upper 4 bits), as used by TightPNG
+const (
+	tightFill = 0x08
+	tightJPEG = 0x09
+	tightPNG  = 0x0A
+)
+
+// pixelFormat is the RFB pixel format announced in ServerInit, used for raw
+// rectangles.
+type pixelFormat struct {
+	bitsPerPixel uint8
+	bigEndian    bool
+	redMax       uint16
+	greenMax     uint16
+	blueMax      uint16
+	redShift     uint8
+	greenShift   uint8
+	blueShift    uint8
+}
+
+// defaultPixelFormat is the 32 bit true colour format VCD consoles use.
+var defaultPixelFormat = pixelFormat{
+	bitsPerPixel: 32,
+	redMax:       255, greenMax: 255, blueMax: 255,
+	redShift: 16, greenShift: 8, blueShift: 0,
+}
+
+func parsePixelFormat(b []byte) pixelFormat {
+	if len(b) < 16 || b[0] == 0 {
+		return defaultPixelFormat
+	}
+	return pixelFormat{
+		bitsPerPixel: b[0],
+		bigEndian:    b[2] != 0,
+		redMax:       binary.BigEndian.Uint16(b[4:6]),
+		greenMax:     binary.BigEndian.Uint16(b[6:8]),
+		blueMax:      binary.BigEndian.Uint16(b[8:10]),
+		redShift:     b[10],
+		greenShift:   b[11],
+		blueShift:    b[12],
+	}
+}
+
+// framebuffer mirrors the VM screen from the FramebufferUpdate messages read
+// by the WMKS reader goroutine.
+type framebuffer struct {
+	mu      sync.Mutex
+	img     *image.RGBA
+	format  pixelFormat
+	updates uint64    // FramebufferUpdates applied so far
+	lastAt  time.Time // when the last update was applied
+}
+
+func newFramebuffer(width, height int, format pixelFormat) *framebuffer {
+	return &framebuffer{
+		img:    image.NewRGBA(image.Rect(0, 0, width, height)),
+		format: format,
+	}
+}
+
+// apply decodes a FramebufferUpdate message onto the framebuffer and returns
+// the screen size, which changes when the guest switches resolution.
+// Decoding stops at the first rectangle that is truncated or uses an
+// encoding the client did not expect; the rectangles before it are kept.
+func (fb *framebuffer) apply(msg []byte) (width, height uint16) {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	defer func() {
+		fb.updates++
+		fb.lastAt = time.Now()
+		size := fb.img.Bounds().Size()
+		width, height = uint16(size.X), uint16(size.Y)
+	}()
+
+	if len(msg) < 4 {
+		return
+	}
+	numRects := int(binary.BigEndian.Uint16(msg[2:4]))
+	r := &byteReader{data: msg[4:]}
+
+	for i := 0; i < numRects; i++ {
+		hdr, ok := r.next(12)
+		if !ok {
+			return
+		}
+		x := int(binary.BigEndian.Uint16(hdr[0:2]))
+		y := int(binary.BigEndian.Uint16(hdr[2:4]))
+		w := int(binary.BigEndian.Uint16(hdr[4:6]))
+		h := int(binary.BigEndian.Uint16(hdr[6:8]))
+		enc := int32(binary.BigEndian.Uint32(hdr[8:12]))
+		rect := image.Rect(x, y, x+w, y+h)
+
+		var err error
+		switch enc {
+		case encTightPNG:
+			err = fb.tightRect(r, rect)
+		case encCopyRect:
+			err = fb.copyRect(r, rect)
+		case encRaw:
+			err = fb.rawRect(r, rect)
+		case encDesktopSize:
+			resized := image.NewRGBA(image.Rect(0, 0, w, h))
+			draw.Draw(resized, resized.Bounds(), fb.img, image.Point{}, draw.Src)
+			fb.img = resized
+		default:
+			// VMware pseudo-encodings carry no pixels, but their payload
+			// size is not known; the rest of the message is skipped
+			log.Printf("[DEBUG] WMKS framebuffer: skipping rest of update at encoding %d", enc)
+			return
+		}
+		if err != nil {
+			log.Printf("[DEBUG] WMKS framebuffer: %v", err)
+			return
+		}
+	}
+	return
+}
+
+// tightRect decodes a TightPNG rectangle: a solid fill, a JPEG or a PNG.
+func (fb *framebuffer) tightRect(r *byteReader, rect image.Rectangle) error {
+	ctl, ok := r.next(1)
+	if !ok {
+		return fmt.Errorf("truncated tight rectangle")
+	}
+
+	switch ctl[0] >> 4 {
+	case tightFill:
+		rgb, ok := r.next(3)
+		if !ok {
+			return fmt.Errorf("truncated tight fill")
+		}
+		fill := color.RGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 255}
+		draw.Draw(fb.img, rect, &image.Uniform{C: fill}, image.Point{}, draw.Src)
+		return nil
+
+	case tightJPEG, tightPNG:
+		n, ok := r.compactLength()
+		if !ok {
+			return fmt.Errorf("truncated tight length")
+		}
+		data, ok := r.next(n)
+		if !ok {
+			return fmt.Errorf("truncated tight image (%d bytes)", n)
+		}
+		var img image.Image
+		var err error
+		if ctl[0]>>4 == tightJPEG {
+			img, err = jpeg.Decode(bytes.NewReader(data))
+		} else {
+			img, err = png.Decode(bytes.NewReader(data))
+		}
+		if err != nil {
+			return fmt.Errorf("error decoding tight image: %w", err)
+		}
+		draw.Draw(fb.img, rect, img, img.Bounds().Min, draw.Src)
+		return nil
+	}
+
+	return fmt.Errorf("unsupported tight compression 0x%02x", ctl[0])
+}
+
+// copyRect copies a region of the screen to rect.
+func (fb *framebuffer) copyRect(r *byteReader, rect image.Rectangle) error {
+	src, ok := r.next(4)
+	if !ok {
+		return fmt.Errorf("truncated copy rectangle")
+	}
+	sp := image.Pt(int(binary.BigEndian.Uint16(src[0:2])), int(binary.BigEndian.Uint16(src[2:4])))
+
+	// Copy through a temporary image, the regions may overlap
+	tmp := image.NewRGBA(image.Rectangle{Max: rect.Size()})
+	draw.Draw(tmp, tmp.Bounds(), fb.img, sp, draw.Src)
+	draw.Draw(fb.img, rect, tmp, image.Point{}, draw.Src)
+	return nil
+}
+
+// rawRect decodes an uncompressed rectangle in the server pixel format.
+func (fb *framebuffer) rawRect(r *byteReader, rect image.Rectangle) error {
+	bpp := int(fb.format.bitsPerPixel) / 8
+	if bpp != 1 && bpp != 2 && bpp != 4 {
+		return fmt.Errorf("unsupported pixel size %d bits", fb.format.bitsPerPixel)
+	}
+	data, ok := r.next(rect.Dx() * rect.Dy() * bpp)
+	if !ok {
+		return fmt.Errorf("truncated raw rectangle")
+	}
+
+	f := fb.format
+	scale := func(v uint32, max uint16) uint8 {
+		if max == 0 {
+			return 0
+		}
+		return uint8(v * 255 / uint32(max))
+	}
+	i := 0
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			var v uint32
+			p := data[i : i+bpp]
+			switch {
+			case bpp == 1:
+				v = uint32(p[0])
+			case bpp == 2 && f.bigEndian:
+				v = uint32(binary.BigEndian.Uint16(p))
+			case bpp == 2:
+				v = uint32(binary.LittleEndian.Uint16(p))
+			case f.bigEndian:
+				v = binary.BigEndian.Uint32(p)
+			default:
+				v = binary.LittleEndian.Uint32(p)
+			}
+			i += bpp
+			fb.img.SetRGBA(x, y, color.RGBA{
+				R: scale(v>>f.redShift&uint32(f.redMax), f.redMax),
+				G: scale(v>>f.greenShift&uint32(f.greenMax), f.greenMax),
+				B: scale(v>>f.blueShift&uint32(f.blueMax), f.blueMax),
+				A: 255,
+			})
+		}
+	}
+	return nil
+}
+
+// snapshot returns a copy of the screen and the number of updates applied.
+func (fb *framebuffer) snapshot() (*image.RGBA, uint64, time.Time) {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	img := image.NewRGBA(fb.img.Bounds())
+	copy(img.Pix, fb.img.Pix)
+	return img, fb.updates, fb.lastAt
+}
+
+// byteReader consumes a message rectangle by rectangle.
+type byteReader struct {
+	data []byte
+}
+
+func (r *byteReader) next(n int) ([]byte, bool) {
+	if n < 0 || len(r.data) < n {
+		return nil, false
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b, true
+}
+
+// compactLength reads a Tight compact length: 1 to 3 bytes of 7, 7 and 8
+// bits, least significant first.
+func (r *byteReader) compactLength() (int, bool) {
+	n := 0
+	for i := 0; i < 3; i++ {
+		b, ok := r.next(1)
+		if !ok {
+			return 0, false
+		}
+		if i == 2 {
+			return n | int(b[0])<<14, true
+		}
+		n |= int(b[0]&0x7f) << (7 * i)
+		if b[0]&0x80 == 0 {
+			return n, true
+		}
+	}
+	return n, true
+}
+
+// screenshotSettle is how long the screen must go without updates before a
+// screenshot is taken, so all rectangles of the refresh have arrived.
+const screenshotSettle = 250 * time.Millisecond
+
+// Screenshot returns the current VM screen. It asks the server for a full
+// refresh and waits up to timeout for it to arrive.
+func (c *WMKSClient) Screenshot(timeout time.Duration) (image.Image, error) {
+	if !c.Alive() || c.screen == nil {
+		return nil, fmt.Errorf("console is not connected")
+	}
+
+	_, start, _ := c.screen.snapshot()
+	c.sendFBUpdateRequest(false)
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		img, updates, lastAt := c.screen.snapshot()
+		if updates > start && time.Since(lastAt) >= screenshotSettle {
+			return img, nil
+		}
+		if !c.Alive() {
+			return nil, fmt.Errorf("console connection lost while taking screenshot")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// A screen that did not change can legitimately get no update
+	img, updates, _ := c.screen.snapshot()
+	if updates == 0 {
+		return nil, fmt.Errorf("no screen update received within %s", timeout)
+	}
+	return img, nil
+}
+
+// CaptureScreenshot connects to the console of a powered on VM and returns
+// its screen.
+func (d *VCDDriver) CaptureScreenshot(ctx context.Context, vm VirtualMachine) (image.Image, error) {
+	govcdVM := vm.GetVM()
+	ticket, err := AcquireMksTicket(d.client, govcdVM)
+	if err != nil {
+		ticket, err = AcquireMksTicketDirect(d.client, govcdVM.VM.HREF)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error acquiring console ticket: %w", err)
+	}
+
+	client := NewWMKSClient(ticket, WithTLSConfig(d.TLSConfig()), WithProxy(d.ConsoleProxy()))
+	if err := client.Connect(); err != nil {
+		return nil, fmt.Errorf("error connecting to console: %w", err)
+	}
+	defer client.Close()
+
+	timeout := 30 * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	return client.Screenshot(timeout)
+}
+
+// WritePNG saves a screenshot as a PNG file.
+func WritePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %w", path, err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return fmt.Errorf("error encoding %s: %w", path, err)
+	}
+	return f.Close()
+}
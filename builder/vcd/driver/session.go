@@ -0,0 +1,160 @@
+package driver
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+)
+
+// sessionTransport re-authenticates transparently when VCD rejects a request
+// because the session expired, then replays the request once with the new
+// token. Long builds (Windows installs, hours of provisioning) can outlive
+// the session idle timeout despite the keepalive, e.g. when the host sleeps
+// or VCD restarts its cells.
+type sessionTransport struct {
+	base http.RoundTripper
+
+	// login authenticates again and returns the new session. It is set
+	// once the client exists and logged in, see newClient.
+	login func() (*sessionToken, error)
+
+	// token is the current session, swapped by refresh. govcd's own copy
+	// in the client is written during the login, so it is never read here.
+	token      atomic.Pointer[sessionToken]
+	mu         sync.Mutex
+	refreshing atomic.Bool
+}
+
+// sessionToken is a session token and the header it is sent in.
+type sessionToken struct {
+	header string
+	value  string
+}
+
+func newSessionTransport(base http.RoundTripper) *sessionTransport {
+	return &sessionTransport{base: base}
+}
+
+// start enables session refreshes, from the session of the initial login.
+func (t *sessionTransport) start(token *sessionToken, login func() (*sessionToken, error)) {
+	t.token.Store(token)
+	t.login = login
+}
+
+func (t *sessionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || t.login == nil || isLoginRequest(req) {
+		return resp, err
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	current := t.token.Load()
+	stale := req.Header.Get(current.header)
+	if t.refreshing.Load() && stale != current.value {
+		// Request made by the refresh itself with the new token
+		return resp, nil
+	}
+	if err := t.refresh(stale); err != nil {
+		log.Printf("[WARN] VCD session expired and could not be refreshed: %s", err)
+		return resp, nil
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	t.setAuthHeaders(retry)
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	log.Printf("[DEBUG] Replaying %s %s with refreshed VCD session", req.Method, req.URL.Path)
+	return t.base.RoundTrip(retry)
+}
+
+// refresh logs in again unless another request already did so since the
+// stale token was issued.
+func (t *sessionTransport) refresh(stale string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token.Load().value != stale {
+		return nil
+	}
+
+	t.refreshing.Store(true)
+	defer t.refreshing.Store(false)
+
+	token, err := t.login()
+	if err != nil {
+		return err
+	}
+	t.token.Store(token)
+	return nil
+}
+
+// setAuthHeaders replaces the authorization headers of req with the current
+// session token, the same way govcd sets them when building a request.
+func (t *sessionTransport) setAuthHeaders(req *http.Request) {
+	token := t.token.Load()
+	req.Header.Del("Authorization")
+	req.Header.Del("X-Vmware-Vcloud-Token-Type")
+	req.Header.Set(token.header, token.value)
+	if len(token.value) > 32 {
+		req.Header.Set("X-Vmware-Vcloud-Token-Type", "Bearer")
+		req.Header.Set("Authorization", "bearer "+token.value)
+	}
+}
+
+// isLoginRequest reports whether req creates a session or exchanges an API
+// token; a 401 on those means bad credentials, not an expired session.
+func isLoginRequest(req *http.Request) bool {
+	if req.Method != http.MethodPost {
+		return false
+	}
+	path := req.URL.Path
+	return strings.HasSuffix(path, "/sessions") || strings.Contains(path, "/sessions/provider") ||
+		strings.Contains(path, "/oauth/")
+}
+
+// clientToken returns the session the client is logged in with.
+func clientToken(client *govcd.VCDClient) *sessionToken {
+	return &sessionToken{header: client.Client.VCDAuthHeader, value: client.Client.VCDToken}
+}
+
+// authenticate logs the client in with whichever credentials the config
+// carries. A bearer token cannot be renewed by the plugin; once it expires
+// the build fails with the original 401.
+func authenticate(client *govcd.VCDClient, config *ConnectConfig) error {
+	org := config.Org
+	switch {
+	case config.BearerToken != "":
+		if client.Client.VCDToken != "" {
+			return fmt.Errorf("bearer token for Org %q expired and cannot be refreshed", org)
+		}
+		if err := client.SetToken(org, govcd.BearerTokenHeader, config.BearerToken); err != nil {
+			return fmt.Errorf("unable to authenticate to Org %q with bearer token: %w", org, err)
+		}
+	case config.Token != "":
+		if err := client.SetToken(org, govcd.ApiTokenHeader, config.Token); err != nil {
+			return fmt.Errorf("unable to authenticate to Org %q: %w", org, err)
+		}
+	default:
+		if err := client.Authenticate(config.Username, config.Password, org); err != nil {
+			return fmt.Errorf("unable to authenticate to Org %q: %w", org, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package driver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testAuthHeader = "X-Vcloud-Authorization"
+
+// sessionServer answers 401 to requests that don't carry the valid token.
+func sessionServer(t *testing.T, valid *atomic.Pointer[string]) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(testAuthHeader) != *valid.Load() {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSessionTransport_ConcurrentRefresh(t *testing.T) {
+	var valid atomic.Pointer[string]
+	expired, renewed := "expired", "renewed"
+	valid.Store(&renewed)
+	srv := sessionServer(t, &valid)
+
+	transport := newSessionTransport(http.DefaultTransport)
+	var logins atomic.Int32
+	transport.start(&sessionToken{header: testAuthHeader, value: expired}, func() (*sessionToken, error) {
+		logins.Add(1)
+		// Let the other requests pile up on the refresh
+		time.Sleep(50 * time.Millisecond)
+		return &sessionToken{header: testAuthHeader, value: renewed}, nil
+	})
+	client := &http.Client{Transport: transport}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			req.Header.Set(testAuthHeader, expired)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := logins.Load(); n != 1 {
+		t.Errorf("logged in %d times, want 1", n)
+	}
+}
+
+func TestSessionTransport_RefreshRequestUnauthorized(t *testing.T) {
+	var valid atomic.Pointer[string]
+	other := "other"
+	valid.Store(&other)
+	srv := sessionServer(t, &valid)
+
+	transport := newSessionTransport(http.DefaultTransport)
+	client := &http.Client{Transport: transport}
+	transport.start(&sessionToken{header: testAuthHeader, value: "expired"}, func() (*sessionToken, error) {
+		// A request of the login itself, with the new token, is rejected
+		// too: it must not try to refresh again
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		req.Header.Set(testAuthHeader, "renewed")
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("login request status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+		}
+		return &sessionToken{header: testAuthHeader, value: "renewed"}, nil
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set(testAuthHeader, "expired")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,40 @@
+package driver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+)
+
+// tlsConfig returns the TLS settings shared by the API client and the console
+// websocket. Certificates in CACertificates are trusted in addition to the
+// system roots.
+func (c *ConnectConfig) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		InsecureSkipVerify: c.InsecureConnection,
+	}
+	if len(c.CACertificates) == 0 {
+		return cfg, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		log.Printf("[WARN] Could not load the system CA certificates, trusting only the configured CA: %s", err)
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(c.CACertificates) {
+		return nil, fmt.Errorf("the CA bundle contains no PEM encoded certificate")
+	}
+	cfg.RootCAs = pool
+	return cfg, nil
+}
+
+// TLSConfig returns a copy of the TLS settings used for the VCD API, for
+// other connections to VCD such as the console websocket.
+func (d *VCDDriver) TLSConfig() *tls.Config {
+	if d.tlsConfig == nil {
+		return &tls.Config{}
+	}
+	return d.tlsConfig.Clone()
+}
@@ -0,0 +1,69 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// transferSlotPollInterval is how often a build waiting for a transfer slot
+// retries the slot locks.
+const transferSlotPollInterval = 5 * time.Second
+
+// transferSlotDir holds one lock file per transfer slot. Packer starts a
+// plugin process per build, so the slots are file locks shared by every
+// plugin process on the host rather than an in-memory semaphore.
+func transferSlotDir() string {
+	return filepath.Join(os.TempDir(), "packer-plugin-vcd", "transfers")
+}
+
+// AcquireTransferSlot blocks until one of the max_concurrent_transfers slots
+// is free and returns a function releasing it. waiting is called once if the
+// caller has to wait. With no limit configured it returns immediately.
+func (d *VCDDriver) AcquireTransferSlot(ctx context.Context, waiting func()) (func(), error) {
+	if d.maxTransfers <= 0 {
+		return func() {}, nil
+	}
+
+	dir := transferSlotDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating transfer slot directory: %w", err)
+	}
+
+	notified := false
+	for {
+		for i := 0; i < d.maxTransfers; i++ {
+			path := filepath.Join(dir, fmt.Sprintf("slot-%d.lock", i))
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+			if err != nil {
+				return nil, fmt.Errorf("error opening transfer slot %s: %w", path, err)
+			}
+			if err := tryLockFile(f); err != nil {
+				f.Close()
+				continue
+			}
+
+			log.Printf("[DEBUG] Acquired transfer slot %d of %d", i+1, d.maxTransfers)
+			return func() {
+				// Closing the file releases the lock
+				if err := f.Close(); err != nil {
+					log.Printf("[WARN] Failed to release transfer slot %s: %s", path, err)
+				}
+			}, nil
+		}
+
+		if !notified && waiting != nil {
+			waiting()
+			notified = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(transferSlotPollInterval):
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+	"github.com/vmware/go-vcloud-director/v3/types/v56"
+)
+
+const (
+	// forceDeleteMaxSteps bounds the number of transitions ForceDeleteVApp
+	// attempts before giving up, so a vApp that never settles cannot hang
+	// cleanup forever.
+	forceDeleteMaxSteps = 8
+
+	// forceDeleteRetryWait is how long ForceDeleteVApp waits before retrying
+	// a failed transition, typically because another task still holds the
+	// vApp busy.
+	forceDeleteRetryWait = 5 * time.Second
+)
+
+// ForceDeleteVApp deletes a vApp whatever state it is in. Cleanup code paths
+// used to assume the vApp was POWERED_OFF or RESOLVED; vApps left behind by a
+// failed or cancelled build may also be SUSPENDED, MIXED, partially deployed,
+// UNRESOLVED or FAILED_CREATION. Each step refreshes the vApp and picks the
+// transition its current state needs:
+//
+//   - SUSPENDED: the suspended state is discarded.
+//   - deployed (POWERED_ON, MIXED, PARTIALLY_*, WAITING_FOR_INPUT...): the
+//     vApp is undeployed with the "force" power action, which powers VMs off
+//     without waiting for the guest.
+//   - anything else, including FAILED_CREATION and UNRESOLVED: the vApp is
+//     deleted.
+//
+// A vApp that disappears along the way counts as deleted. The report callback,
+// if not nil, receives a short description of each transition.
+func (d *VCDDriver) ForceDeleteVApp(ctx context.Context, vapp *govcd.VApp, report func(string)) error {
+	if report == nil {
+		report = func(string) {}
+	}
+
+	var lastErr error
+	for step := 0; step < forceDeleteMaxSteps; step++ {
+		if step > 0 && lastErr != nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(forceDeleteRetryWait):
+			}
+		}
+
+		if err := vapp.Refresh(); err != nil {
+			if govcd.ContainsNotFound(err) {
+				return nil
+			}
+			lastErr = fmt.Errorf("error refreshing vApp %s: %w", vapp.VApp.Name, err)
+			continue
+		}
+
+		status := vAppStatus(vapp)
+		log.Printf("[DEBUG] Force delete of vApp %s: status %s, deployed %t", vapp.VApp.Name, status, vapp.VApp.Deployed)
+
+		switch {
+		case status == "SUSPENDED":
+			report("discarding suspended state")
+			lastErr = d.vappAction(ctx, vapp, "/action/discardSuspendedState", "", nil)
+
+		case vapp.VApp.Deployed || vAppNeedsUndeploy(status):
+			report(fmt.Sprintf("force undeploying (status %s)", status))
+			lastErr = d.vappAction(ctx, vapp, "/action/undeploy", types.MimeUndeployVappParams,
+				&types.UndeployVAppParams{
+					Xmlns:               types.XMLNamespaceVCloud,
+					UndeployPowerAction: "force",
+				})
+			if lastErr != nil {
+				// VCD refuses to undeploy some broken vApps it will still
+				// delete, so fall through to a delete attempt.
+				log.Printf("[WARN] Force undeploy of vApp %s failed: %s", vapp.VApp.Name, lastErr)
+				report(fmt.Sprintf("deleting (status %s)", status))
+				if err := d.deleteVApp(ctx, vapp); err == nil {
+					return nil
+				}
+			}
+
+		default:
+			report(fmt.Sprintf("deleting (status %s)", status))
+			lastErr = d.deleteVApp(ctx, vapp)
+			if lastErr == nil {
+				return nil
+			}
+		}
+
+		if lastErr != nil {
+			if govcd.ContainsNotFound(lastErr) {
+				return nil
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("[WARN] Force delete of vApp %s: %s", vapp.VApp.Name, lastErr)
+		}
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("vApp did not reach a deletable state")
+	}
+	return fmt.Errorf("error force deleting vApp %s after %d attempts: %w", vapp.VApp.Name, forceDeleteMaxSteps, lastErr)
+}
+
+func (d *VCDDriver) deleteVApp(ctx context.Context, vapp *govcd.VApp) error {
+	task, err := vapp.Delete()
+	if err != nil {
+		return fmt.Errorf("error deleting vApp %s: %w", vapp.VApp.Name, err)
+	}
+	return WaitTask(ctx, &task)
+}
+
+// vappAction posts an action to the vApp and waits for the resulting task.
+func (d *VCDDriver) vappAction(ctx context.Context, vapp *govcd.VApp, action, contentType string, payload interface{}) error {
+	task, err := d.client.Client.ExecuteTaskRequest(vapp.VApp.HREF+action, http.MethodPost,
+		contentType, "error running "+action+" on vApp: %s", payload)
+	if err != nil {
+		return err
+	}
+	return WaitTask(ctx, &task)
+}
+
+func vAppStatus(vapp *govcd.VApp) string {
+	if status, ok := types.VAppStatuses[vapp.VApp.Status]; ok {
+		return status
+	}
+	return fmt.Sprintf("UNRECOGNIZED(%d)", vapp.VApp.Status)
+}
+
+// vAppNeedsUndeploy reports whether a vApp in the given state has to be
+// undeployed before VCD accepts deleting it.
+func vAppNeedsUndeploy(status string) bool {
+	switch status {
+	case "POWERED_ON", "MIXED", "WAITING_FOR_INPUT", "UNKNOWN", "INCONSISTENT_STATE",
+		"VAPP_PARTIALLY_DEPLOYED", "PARTIALLY_POWERED_OFF", "PARTIALLY_SUSPENDED":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package driver
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+)
+
+// supportedAPIVersions lists the VCD API versions the plugin works with,
+// newest first: 36.x is VCD 10.3, 37.x 10.4, 38.x 10.5 and 39.0 10.6.
+var supportedAPIVersions = []string{
+	"39.0",
+	"38.1", "38.0",
+	"37.3", "37.2", "37.1", "37.0",
+	"36.3", "36.2", "36.1", "36.0",
+}
+
+// Minimum API versions of the features that are not available on every
+// supported VCD release. Check them with SupportsAPIVersion.
+const (
+	// Virtual TPM devices, VCD 10.4.2.
+	APIVersionTPM = "37.2"
+)
+
+// negotiateAPIVersion returns the newest API version supported by both the
+// plugin and the VCD server, read from the unauthenticated /api/versions
+// endpoint.
+func negotiateAPIVersion(client *govcd.VCDClient) (string, error) {
+	endpoint := client.Client.VCDHREF
+	endpoint.Path += "/versions"
+
+	versions := new(govcd.SupportedVersions)
+	_, err := client.Client.ExecuteRequest(endpoint.String(), http.MethodGet,
+		"", "error fetching supported API versions: %s", nil, versions)
+	if err != nil {
+		return "", err
+	}
+
+	offered := make(map[string]bool, len(versions.VersionInfos))
+	var all []string
+	for _, v := range versions.VersionInfos {
+		offered[v.Version] = true
+		all = append(all, v.Version)
+	}
+	log.Printf("[DEBUG] VCD supports API versions: %s", strings.Join(all, ", "))
+
+	for _, v := range supportedAPIVersions {
+		if offered[v] {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("VCD supports none of the API versions this plugin uses (%s to %s, VCD 10.3 to 10.6); server offers: %s",
+		supportedAPIVersions[len(supportedAPIVersions)-1], supportedAPIVersions[0], strings.Join(all, ", "))
+}
+
+// APIVersion returns the API version negotiated with VCD at connect time.
+func (d *VCDDriver) APIVersion() string {
+	return d.client.Client.APIVersion
+}
+
+// SupportsAPIVersion reports whether the negotiated API version is at least
+// minVersion, e.g. APIVersionTPM.
+func (d *VCDDriver) SupportsAPIVersion(minVersion string) bool {
+	return d.client.Client.APIClientVersionIs(">= " + minVersion)
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
@@ -12,14 +15,21 @@ import (
 	"github.com/vmware/go-vcloud-director/v3/types/v56"
 )
 
-var errPolicyNotFound = errors.New("VM sizing policy not found")
+// ErrPolicyNotFound is returned when a VM sizing policy is not assigned to the VDC.
+var ErrPolicyNotFound = errors.New("VM sizing policy not found")
+
+// ErrPlacementPolicyNotFound is returned when a VM placement policy is not
+// assigned to the VDC.
+var ErrPlacementPolicyNotFound = errors.New("VM placement policy not found")
 
 // VirtualMachine defines the interface for VM operations
 type VirtualMachine interface {
 	// Power operations
-	PowerOn() error
-	PowerOff() error
-	Shutdown() error
+	PowerOn(ctx context.Context) error
+	PowerOff(ctx context.Context) error
+	Shutdown(ctx context.Context) error
+	Deploy(ctx context.Context, forceCustomization bool) error
+	DeployVApp(ctx context.Context, forceCustomization bool) error
 
 	// Status
 	GetStatus() (string, error)
@@ -29,19 +39,39 @@ type VirtualMachine interface {
 
 	// Network
 	GetIPAddress() (string, error)
+	GetNICs() ([]NICInfo, error)
 	WaitForIP(ctx context.Context, timeout time.Duration) (string, error)
 	ChangeIPAddress(newIP string) error
+	RemoveNetworkAdapters() error
 
 	// Media operations
-	InsertMedia(catalogName, mediaName string) error
-	EjectMedia(catalogName, mediaName string) error
+	InsertMedia(ctx context.Context, media *govcd.Media, timeout time.Duration) error
+	EjectMedia(media *govcd.Media) error
+	EjectAllMedia() ([]string, error)
+	HasFloppyDrive() (bool, error)
+	AddCDDrive() (string, error)
+	SetDriveMedia(deviceID string, media *govcd.Media) error
+	RemoveCDDrive(deviceID string) error
 
 	// Hardware configuration
 	ChangeCPU(cpuCount, coresPerSocket int) error
 	ChangeMemory(memoryMB int64) error
+	GetExtraConfig() (map[string]string, error)
 	ChangeExtraConfig(entries map[string]string) error
-	SetTPM(enabled bool) error
+	RemoveExtraConfig(keys []string) error
+	OVFProperties() (map[string]string, error)
+	SetOVFProperties(properties map[string]string) error
+	RemoveOVFProperties(keys []string) error
+	SetTPM(ctx context.Context, enabled bool) error
 	SetBootOptions(bootDelayMs int, efiSecureBoot bool) error
+	SetTimeSync(enabled bool) error
+	SetGuestCustomization(section *types.GuestCustomizationSection) error
+	MergeMetadata(entries map[string]string) error
+
+	// Snapshots
+	CreateSnapshot(ctx context.Context, name string, memory bool) error
+	RevertToCurrentSnapshot(ctx context.Context) error
+	RemoveAllSnapshots(ctx context.Context) error
 
 	// Info
 	GetName() string
@@ -49,35 +79,81 @@ type VirtualMachine interface {
 	Refresh() error
 }
 
+// NICInfo describes a network adapter of a VM
+type NICInfo struct {
+	Index   int
+	MAC     string
+	Network string
+	Primary bool
+}
+
 type VirtualMachineDriver struct {
 	vm     *govcd.VM
 	driver *VCDDriver
 }
 
+var _ VirtualMachine = (*VirtualMachineDriver)(nil)
+
 // --- Power Operations ---
 
-func (v *VirtualMachineDriver) PowerOn() error {
+func (v *VirtualMachineDriver) PowerOn(ctx context.Context) error {
 	task, err := v.vm.PowerOn()
 	if err != nil {
 		return fmt.Errorf("error powering on VM: %w", err)
 	}
-	return task.WaitTaskCompletion()
+	return WaitTask(ctx, &task)
 }
 
-func (v *VirtualMachineDriver) PowerOff() error {
+func (v *VirtualMachineDriver) PowerOff(ctx context.Context) error {
 	task, err := v.vm.PowerOff()
 	if err != nil {
 		return fmt.Errorf("error powering off VM: %w", err)
 	}
-	return task.WaitTaskCompletion()
+	return WaitTask(ctx, &task)
 }
 
-func (v *VirtualMachineDriver) Shutdown() error {
+func (v *VirtualMachineDriver) Shutdown(ctx context.Context) error {
 	task, err := v.vm.Shutdown()
 	if err != nil {
 		return fmt.Errorf("error shutting down VM: %w", err)
 	}
-	return task.WaitTaskCompletion()
+	return WaitTask(ctx, &task)
+}
+
+// Deploy deploys the VM and powers it on. forceCustomization makes guest
+// customization run even if it already ran; VCD only honours it when the VM
+// is undeployed.
+func (v *VirtualMachineDriver) Deploy(ctx context.Context, forceCustomization bool) error {
+	return v.deploy(ctx, v.vm.VM.HREF, "VM", forceCustomization)
+}
+
+// DeployVApp deploys the vApp of the VM, which powers on all its VMs.
+func (v *VirtualMachineDriver) DeployVApp(ctx context.Context, forceCustomization bool) error {
+	vapp, err := v.vm.GetParentVApp()
+	if err != nil {
+		return fmt.Errorf("error getting vApp of VM: %w", err)
+	}
+	return v.deploy(ctx, vapp.VApp.HREF, "vApp", forceCustomization)
+}
+
+func (v *VirtualMachineDriver) deploy(ctx context.Context, href, kind string, forceCustomization bool) error {
+	params := &types.DeployVAppParams{
+		Xmlns:              types.XMLNamespaceVCloud,
+		PowerOn:            true,
+		ForceCustomization: forceCustomization,
+	}
+
+	task, err := v.driver.client.Client.ExecuteTaskRequest(
+		href+"/action/deploy",
+		http.MethodPost,
+		types.MimeDeployVappParams,
+		"error deploying "+kind+": %s",
+		params,
+	)
+	if err != nil {
+		return fmt.Errorf("error deploying %s: %w", kind, err)
+	}
+	return WaitTask(ctx, &task)
 }
 
 // --- Status Operations ---
@@ -130,6 +206,28 @@ func (v *VirtualMachineDriver) WaitForPowerOff(ctx context.Context, timeout time
 // --- Network Operations ---
 
 // GetIPAddress returns the IP address of the primary NIC
+// GetNICs returns the VM's network adapters ordered by connection index.
+func (v *VirtualMachineDriver) GetNICs() ([]NICInfo, error) {
+	netSection, err := v.vm.GetNetworkConnectionSection()
+	if err != nil {
+		return nil, fmt.Errorf("error getting network connection section: %w", err)
+	}
+
+	var nics []NICInfo
+	for _, conn := range netSection.NetworkConnection {
+		nics = append(nics, NICInfo{
+			Index:   conn.NetworkConnectionIndex,
+			MAC:     conn.MACAddress,
+			Network: conn.Network,
+			Primary: conn.NetworkConnectionIndex == netSection.PrimaryNetworkConnectionIndex,
+		})
+	}
+	sort.Slice(nics, func(i, j int) bool {
+		return nics[i].Index < nics[j].Index
+	})
+	return nics, nil
+}
+
 func (v *VirtualMachineDriver) GetIPAddress() (string, error) {
 	// Refresh VM to get latest network info
 	if err := v.vm.Refresh(); err != nil {
@@ -215,80 +313,281 @@ func (v *VirtualMachineDriver) ChangeIPAddress(newIP string) error {
 	return nil
 }
 
+// RemoveNetworkAdapters removes every network adapter of the VM, which
+// must be powered off.
+func (v *VirtualMachineDriver) RemoveNetworkAdapters() error {
+	err := v.vm.UpdateNetworkConnectionSection(&types.NetworkConnectionSection{})
+	if err != nil {
+		return fmt.Errorf("error updating network connection section: %w", err)
+	}
+
+	return nil
+}
+
 // --- Media Operations ---
 
-func (v *VirtualMachineDriver) InsertMedia(catalogName, mediaName string) error {
-	org, err := v.driver.GetOrg()
-	if err != nil {
-		return err
+// InsertMedia inserts media into the VM, retrying while the VM or the media
+// is busy with another operation, until timeout elapses. The media must
+// already be resolved; see WaitForMediaResolved. The media is referenced by
+// HREF, so its catalog or name changing meanwhile does not matter.
+func (v *VirtualMachineDriver) InsertMedia(ctx context.Context, media *govcd.Media, timeout time.Duration) error {
+	mediaName := media.Media.Name
+	params := &types.MediaInsertOrEjectParams{
+		Media: &types.Reference{
+			HREF: media.Media.HREF,
+			Name: media.Media.Name,
+			ID:   media.Media.ID,
+			Type: media.Media.Type,
+		},
 	}
 
-	// First, get the catalog and check media status
-	catalog, err := org.GetCatalogByName(catalogName, true)
+	const retryDelay = 30 * time.Second
+	// The timeout only bounds the busy retries, not the insert itself
+	busyCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for attempt := 1; ; attempt++ {
+		task, err := v.vm.InsertMedia(params)
+		if err == nil {
+			return WaitTask(ctx, &task)
+		}
+
+		// A 409 means the VM or media is busy; anything else is permanent
+		if !strings.Contains(err.Error(), "409") && !strings.Contains(err.Error(), "not supported in the current state") {
+			return fmt.Errorf("error inserting media %s: %w", mediaName, err)
+		}
+		log.Printf("[DEBUG] Media insert attempt %d failed (busy), retrying in %v: %s", attempt, retryDelay, err)
+
+		select {
+		case <-busyCtx.Done():
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("timed out after %v inserting media %s, still busy: %w", timeout, mediaName, err)
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+// EjectMedia ejects the media from the VM, answering the guest's "eject
+// locked CD-ROM" question with yes.
+func (v *VirtualMachineDriver) EjectMedia(media *govcd.Media) error {
+	task, err := v.vm.EjectMedia(&types.MediaInsertOrEjectParams{
+		Media: &types.Reference{
+			HREF: media.Media.HREF,
+		},
+	})
+	if err == nil {
+		err = task.WaitTaskCompletion(true)
+	}
 	if err != nil {
-		return fmt.Errorf("error getting catalog %s: %w", catalogName, err)
+		return fmt.Errorf("error ejecting media %s: %w", media.Media.Name, err)
 	}
 
-	// Wait for media to be RESOLVED (status = 1)
-	maxStatusRetries := 30
-	statusRetryDelay := 10 * time.Second
+	// Older VCD versions report the media as inserted for a moment after
+	// the eject task completes. Only CD-ROMs show their media.
+	if media.Media.ImageType == "floppy" {
+		return nil
+	}
+	for i := 0; i < 10; i++ {
+		if err := v.vm.Refresh(); err != nil {
+			return fmt.Errorf("error refreshing VM after eject: %w", err)
+		}
+		if !hasInsertedMedia(v.vm) {
+			return nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return fmt.Errorf("media %s still shown as inserted after eject", media.Media.Name)
+}
 
-	for i := 0; i < maxStatusRetries; i++ {
-		media, err := catalog.GetMediaByName(mediaName, true)
+// EjectAllMedia ejects the media still inserted in the VM, whoever inserted
+// them, and returns their names.
+func (v *VirtualMachineDriver) EjectAllMedia() ([]string, error) {
+	if err := v.vm.Refresh(); err != nil {
+		return nil, fmt.Errorf("error refreshing VM: %w", err)
+	}
+	spec := v.vm.VM.VmSpecSection
+	if spec == nil || spec.MediaSection == nil {
+		return nil, nil
+	}
+
+	var ejected []string
+	for _, settings := range spec.MediaSection.MediaSettings {
+		if settings.MediaImage == nil || settings.MediaImage.HREF == "" {
+			continue
+		}
+		task, err := v.vm.EjectMedia(&types.MediaInsertOrEjectParams{
+			Media: &types.Reference{
+				HREF: settings.MediaImage.HREF,
+			},
+		})
+		if err == nil {
+			err = task.WaitTaskCompletion(true)
+		}
 		if err != nil {
-			return fmt.Errorf("error getting media %s: %w", mediaName, err)
+			return ejected, fmt.Errorf("error ejecting media %s: %w", settings.MediaImage.Name, err)
 		}
+		ejected = append(ejected, settings.MediaImage.Name)
+	}
+	return ejected, nil
+}
 
-		if media.Media.Status == 1 { // RESOLVED
-			break
+func hasInsertedMedia(vm *govcd.VM) bool {
+	if vm.VM.VirtualHardwareSection == nil {
+		return false
+	}
+	for _, item := range vm.VM.VirtualHardwareSection.Item {
+		if item.ResourceSubType == types.VMsCDResourceSubType {
+			return true
 		}
+	}
+	return false
+}
 
-		fmt.Printf("Media status is %d (need 1=RESOLVED), waiting %v... (%d/%d)\n",
-			media.Media.Status, statusRetryDelay, i+1, maxStatusRetries)
-
-		if i == maxStatusRetries-1 {
-			return fmt.Errorf("media %s never reached RESOLVED status (current: %d)", mediaName, media.Media.Status)
+// HasFloppyDrive reports whether the VM has a floppy drive to insert floppy
+// media into.
+func (v *VirtualMachineDriver) HasFloppyDrive() (bool, error) {
+	if err := v.vm.Refresh(); err != nil {
+		return false, fmt.Errorf("error refreshing VM: %w", err)
+	}
+	if v.vm.VM.VirtualHardwareSection == nil {
+		return false, nil
+	}
+	for _, item := range v.vm.VM.VirtualHardwareSection.Item {
+		if item.ResourceType == types.ResourceTypeFloppy {
+			return true, nil
 		}
-		time.Sleep(statusRetryDelay)
 	}
+	return false, nil
+}
 
-	// Retry logic for media insertion
-	maxRetries := 12
-	retryDelay := 30 * time.Second
+// AddCDDrive adds a CD-ROM drive to the VM, in the first free slot of the
+// controller of its CD-ROM drive, and returns its device ID. Media can only
+// be mounted in it with SetDriveMedia: InsertMedia picks the drive itself.
+func (v *VirtualMachineDriver) AddCDDrive() (string, error) {
+	if err := v.vm.Refresh(); err != nil {
+		return "", fmt.Errorf("error refreshing VM: %w", err)
+	}
+	spec := v.vm.VM.VmSpecSection
+	if spec == nil || spec.MediaSection == nil {
+		return "", fmt.Errorf("VM %s has no media section", v.vm.VM.Name)
+	}
 
-	var lastErr error
-	for i := 0; i < maxRetries; i++ {
-		task, err := v.vm.HandleInsertMedia(org, catalogName, mediaName)
-		if err == nil {
-			return task.WaitTaskCompletion()
+	var cdrom *types.MediaSettings
+	for _, m := range spec.MediaSection.MediaSettings {
+		if m.MediaType == "CDROM" {
+			cdrom = m
+			break
 		}
+	}
+	if cdrom == nil {
+		return "", fmt.Errorf("VM %s has no CD-ROM drive", v.vm.VM.Name)
+	}
 
-		// Check if it's a 409 state error
-		if strings.Contains(err.Error(), "409") || strings.Contains(err.Error(), "not supported in the current state") {
-			lastErr = err
-			fmt.Printf("Media insert attempt %d/%d failed (state error), waiting %v before retry...\n", i+1, maxRetries, retryDelay)
-			time.Sleep(retryDelay)
-			continue
+	// Slots of the controller taken by media drives and disks
+	used := make(map[[2]int]bool)
+	for _, m := range spec.MediaSection.MediaSettings {
+		if m.AdapterType == cdrom.AdapterType {
+			used[[2]int{m.BusNumber, m.UnitNumber}] = true
 		}
+	}
+	if spec.DiskSection != nil {
+		for _, disk := range spec.DiskSection.DiskSettings {
+			if disk.AdapterType == cdrom.AdapterType {
+				used[[2]int{disk.BusNumber, disk.UnitNumber}] = true
+			}
+		}
+	}
+	bus, unit := -1, -1
+	// IDE controllers have two buses of two devices
+	for slot := 0; slot < 4 && bus < 0; slot++ {
+		if !used[[2]int{slot / 2, slot % 2}] {
+			bus, unit = slot/2, slot%2
+		}
+	}
+	if bus < 0 {
+		return "", fmt.Errorf("no free slot for a CD-ROM drive on the controller of VM %s", v.vm.VM.Name)
+	}
 
-		// Non-retryable error
-		return fmt.Errorf("error inserting media %s: %w", mediaName, err)
+	updated := *spec
+	updated.MediaSection = &types.MediaSection{
+		MediaSettings: append(append([]*types.MediaSettings(nil), spec.MediaSection.MediaSettings...), &types.MediaSettings{
+			MediaType:   "CDROM",
+			MediaState:  "DISCONNECTED",
+			BusNumber:   bus,
+			UnitNumber:  unit,
+			AdapterType: cdrom.AdapterType,
+		}),
+	}
+	if _, err := v.vm.UpdateVmSpecSection(&updated, v.vm.VM.Description); err != nil {
+		return "", fmt.Errorf("error adding CD-ROM drive: %w", err)
+	}
+
+	// VCD assigns the device ID
+	if spec := v.vm.VM.VmSpecSection; spec != nil && spec.MediaSection != nil {
+		for _, m := range spec.MediaSection.MediaSettings {
+			if m.MediaType == "CDROM" && m.AdapterType == cdrom.AdapterType && m.BusNumber == bus && m.UnitNumber == unit {
+				return m.DeviceId, nil
+			}
+		}
 	}
+	return "", fmt.Errorf("CD-ROM drive added to VM %s not found", v.vm.VM.Name)
+}
 
-	return fmt.Errorf("error inserting media %s after %d retries: %w", mediaName, maxRetries, lastErr)
+// SetDriveMedia mounts media in the media drive deviceID, or empties the
+// drive when media is nil.
+func (v *VirtualMachineDriver) SetDriveMedia(deviceID string, media *govcd.Media) error {
+	return v.updateMediaSettings(deviceID, func(settings []*types.MediaSettings, i int) []*types.MediaSettings {
+		drive := *settings[i]
+		if media != nil {
+			drive.MediaImage = &types.Reference{
+				HREF: media.Media.HREF,
+				Name: media.Media.Name,
+				ID:   media.Media.ID,
+				Type: media.Media.Type,
+			}
+			drive.MediaState = "CONNECTED"
+		} else {
+			drive.MediaImage = nil
+			drive.MediaState = "DISCONNECTED"
+		}
+		settings[i] = &drive
+		return settings
+	})
 }
 
-func (v *VirtualMachineDriver) EjectMedia(catalogName, mediaName string) error {
-	org, err := v.driver.GetOrg()
-	if err != nil {
-		return err
+// RemoveCDDrive removes the media drive deviceID, added by AddCDDrive.
+func (v *VirtualMachineDriver) RemoveCDDrive(deviceID string) error {
+	return v.updateMediaSettings(deviceID, func(settings []*types.MediaSettings, i int) []*types.MediaSettings {
+		return append(settings[:i], settings[i+1:]...)
+	})
+}
+
+// updateMediaSettings reconfigures the media drives of the VM with change,
+// given a copy of the drives and the index of the drive deviceID.
+func (v *VirtualMachineDriver) updateMediaSettings(deviceID string, change func(settings []*types.MediaSettings, i int) []*types.MediaSettings) error {
+	if err := v.vm.Refresh(); err != nil {
+		return fmt.Errorf("error refreshing VM: %w", err)
+	}
+	spec := v.vm.VM.VmSpecSection
+	if spec == nil || spec.MediaSection == nil {
+		return fmt.Errorf("VM %s has no media section", v.vm.VM.Name)
 	}
 
-	_, err = v.vm.HandleEjectMediaAndAnswer(org, catalogName, mediaName, true)
-	if err != nil {
-		return fmt.Errorf("error ejecting media %s: %w", mediaName, err)
+	settings := append([]*types.MediaSettings(nil), spec.MediaSection.MediaSettings...)
+	for i, m := range settings {
+		if m.DeviceId != deviceID {
+			continue
+		}
+		updated := *spec
+		updated.MediaSection = &types.MediaSection{MediaSettings: change(settings, i)}
+		if _, err := v.vm.UpdateVmSpecSection(&updated, v.vm.VM.Description); err != nil {
+			return fmt.Errorf("error reconfiguring media drive %s: %w", deviceID, err)
+		}
+		return nil
 	}
-	return nil
+	return fmt.Errorf("VM %s has no media drive %s", v.vm.VM.Name, deviceID)
 }
 
 // --- Hardware Configuration ---
@@ -309,6 +608,20 @@ func (v *VirtualMachineDriver) ChangeMemory(memoryMB int64) error {
 	return nil
 }
 
+// GetExtraConfig returns the key/value pairs of the VM's ExtraConfig.
+func (v *VirtualMachineDriver) GetExtraConfig() (map[string]string, error) {
+	existing, err := v.vm.GetExtraConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving extra config: %w", err)
+	}
+
+	entries := make(map[string]string, len(existing))
+	for _, ec := range existing {
+		entries[ec.Key] = ec.Value
+	}
+	return entries, nil
+}
+
 // ChangeExtraConfig sets the given key/value pairs on the VM's ExtraConfig
 // (the VCD equivalent of VMware's .vmx data). Existing keys not in the map are
 // left untouched; matching keys are overwritten. Pass-through: caller is
@@ -344,7 +657,128 @@ func (v *VirtualMachineDriver) ChangeExtraConfig(entries map[string]string) erro
 	return nil
 }
 
-func (v *VirtualMachineDriver) SetTPM(enabled bool) error {
+// RemoveExtraConfig removes the given keys from the VM's ExtraConfig. Keys
+// that are not set are ignored.
+func (v *VirtualMachineDriver) RemoveExtraConfig(keys []string) error {
+	existing, err := v.vm.GetExtraConfig()
+	if err != nil {
+		return fmt.Errorf("error retrieving existing extra config: %w", err)
+	}
+
+	var remove []*types.ExtraConfigMarshal
+	for _, ec := range existing {
+		if slices.Contains(keys, ec.Key) {
+			remove = append(remove, ec)
+		}
+	}
+	if len(remove) == 0 {
+		return nil
+	}
+
+	if _, err := v.vm.DeleteExtraConfig(remove); err != nil {
+		return fmt.Errorf("error removing extra config: %w", err)
+	}
+	return nil
+}
+
+// productSectionList returns the product section of the VM, with an empty
+// ProductSection when it has no OVF properties.
+func (v *VirtualMachineDriver) productSectionList() (*types.ProductSectionList, error) {
+	list, err := v.vm.GetProductSectionList()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving product section: %w", err)
+	}
+	if list.ProductSection == nil {
+		list.ProductSection = &types.ProductSection{}
+	}
+	return list, nil
+}
+
+// OVFProperties returns the OVF properties of the VM's product section,
+// by key, with their current values.
+func (v *VirtualMachineDriver) OVFProperties() (map[string]string, error) {
+	list, err := v.productSectionList()
+	if err != nil {
+		return nil, err
+	}
+
+	properties := make(map[string]string, len(list.ProductSection.Property))
+	for _, property := range list.ProductSection.Property {
+		value := property.DefaultValue
+		if property.Value != nil {
+			value = property.Value.Value
+		}
+		properties[property.Key] = value
+	}
+	return properties, nil
+}
+
+// SetOVFProperties sets OVF properties in the VM's product section,
+// which VCD hands to the guest in its OVF environment. Properties not in
+// the map are left untouched; matching ones are overwritten, and new ones
+// are added as user configurable strings.
+func (v *VirtualMachineDriver) SetOVFProperties(properties map[string]string) error {
+	if len(properties) == 0 {
+		return nil
+	}
+
+	list, err := v.productSectionList()
+	if err != nil {
+		return err
+	}
+
+	for key, value := range properties {
+		updated := false
+		for _, property := range list.ProductSection.Property {
+			if property.Key == key {
+				property.Value = &types.Value{Value: value}
+				updated = true
+				break
+			}
+		}
+		if !updated {
+			list.ProductSection.Property = append(list.ProductSection.Property, &types.Property{
+				Key:              key,
+				Type:             "string",
+				UserConfigurable: true,
+				DefaultValue:     value,
+				Value:            &types.Value{Value: value},
+			})
+		}
+	}
+
+	if _, err := v.vm.SetProductSectionList(list); err != nil {
+		return fmt.Errorf("error setting product section: %w", err)
+	}
+	return nil
+}
+
+// RemoveOVFProperties removes the given OVF properties from the VM's
+// product section. Properties that are not set are ignored.
+func (v *VirtualMachineDriver) RemoveOVFProperties(keys []string) error {
+	list, err := v.productSectionList()
+	if err != nil {
+		return err
+	}
+
+	kept := list.ProductSection.Property[:0]
+	for _, property := range list.ProductSection.Property {
+		if !slices.Contains(keys, property.Key) {
+			kept = append(kept, property)
+		}
+	}
+	if len(kept) == len(list.ProductSection.Property) {
+		return nil
+	}
+	list.ProductSection.Property = kept
+
+	if _, err := v.vm.SetProductSectionList(list); err != nil {
+		return fmt.Errorf("error removing OVF properties: %w", err)
+	}
+	return nil
+}
+
+func (v *VirtualMachineDriver) SetTPM(ctx context.Context, enabled bool) error {
 	tpmEdit := &TrustedPlatformModuleEdit{
 		Xmlns:      types.XMLNamespaceVCloud,
 		TpmPresent: enabled,
@@ -361,7 +795,7 @@ func (v *VirtualMachineDriver) SetTPM(enabled bool) error {
 		return fmt.Errorf("error setting TPM: %w", err)
 	}
 
-	return task.WaitTaskCompletion()
+	return WaitTask(ctx, &task)
 }
 
 func (v *VirtualMachineDriver) SetBootOptions(bootDelayMs int, efiSecureBoot bool) error {
@@ -383,6 +817,107 @@ func (v *VirtualMachineDriver) SetBootOptions(bootDelayMs int, efiSecureBoot boo
 	return nil
 }
 
+// SetTimeSync turns the synchronization of the guest clock with the host
+// through VMware Tools on or off. Nothing is changed when the VM already
+// has the setting.
+func (v *VirtualMachineDriver) SetTimeSync(enabled bool) error {
+	spec := v.vm.VM.VmSpecSection
+	if spec == nil {
+		return fmt.Errorf("VM %s has no VM specification section", v.vm.VM.Name)
+	}
+	if spec.TimeSyncWithHost != nil && *spec.TimeSyncWithHost == enabled {
+		return nil
+	}
+
+	updated := *spec
+	updated.TimeSyncWithHost = boolPtr(enabled)
+	if _, err := v.vm.UpdateVmSpecSection(&updated, v.vm.VM.Description); err != nil {
+		return fmt.Errorf("error setting time sync with host: %w", err)
+	}
+	return nil
+}
+
+// SetGuestCustomization replaces the guest customization section of the VM.
+func (v *VirtualMachineDriver) SetGuestCustomization(section *types.GuestCustomizationSection) error {
+	if _, err := v.vm.SetGuestCustomizationSection(section); err != nil {
+		return fmt.Errorf("error setting guest customization section: %w", err)
+	}
+	return nil
+}
+
+// MergeMetadata adds the string metadata entries to the VM, replacing the
+// entries with the same keys.
+func (v *VirtualMachineDriver) MergeMetadata(entries map[string]string) error {
+	metadata := make(map[string]interface{}, len(entries))
+	for key, value := range entries {
+		metadata[key] = value
+	}
+	if err := v.vm.MergeMetadata(types.MetadataStringValue, metadata); err != nil {
+		return fmt.Errorf("error writing metadata of VM %s: %w", v.vm.VM.Name, err)
+	}
+	return nil
+}
+
+// --- Snapshots ---
+
+// CreateSnapshot takes a snapshot of the VM, replacing its current one. With
+// memory, the snapshot of a running VM includes its memory, and reverting
+// to it resumes the running guest rather than powering the VM off.
+func (v *VirtualMachineDriver) CreateSnapshot(ctx context.Context, name string, memory bool) error {
+	params := &CreateSnapshotParams{
+		Xmlns:  types.XMLNamespaceVCloud,
+		Name:   name,
+		Memory: memory,
+	}
+
+	task, err := v.driver.client.Client.ExecuteTaskRequest(
+		v.vm.VM.HREF+"/action/createSnapshot",
+		http.MethodPost,
+		"application/vnd.vmware.vcloud.createSnapshotParams+xml",
+		"error creating snapshot of VM: %s",
+		params,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating snapshot %s: %w", name, err)
+	}
+
+	return WaitTask(ctx, &task)
+}
+
+// RevertToCurrentSnapshot reverts the VM to the snapshot taken by
+// CreateSnapshot.
+func (v *VirtualMachineDriver) RevertToCurrentSnapshot(ctx context.Context) error {
+	task, err := v.driver.client.Client.ExecuteTaskRequest(
+		v.vm.VM.HREF+"/action/revertToCurrentSnapshot",
+		http.MethodPost,
+		"",
+		"error reverting VM to snapshot: %s",
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("error reverting to snapshot: %w", err)
+	}
+
+	return WaitTask(ctx, &task)
+}
+
+// RemoveAllSnapshots removes the snapshots of the VM, consolidating its
+// disks.
+func (v *VirtualMachineDriver) RemoveAllSnapshots(ctx context.Context) error {
+	task, err := v.driver.client.Client.ExecuteTaskRequest(
+		v.vm.VM.HREF+"/action/removeAllSnapshots",
+		http.MethodPost,
+		"",
+		"error removing snapshots of VM: %s",
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("error removing snapshots: %w", err)
+	}
+
+	return WaitTask(ctx, &task)
+}
+
 func boolPtr(b bool) *bool {
 	return &b
 }
@@ -410,5 +945,25 @@ func GetVMSizingPolicyByName(sizingPolicies []*govcd.VdcComputePolicyV2, policyN
 			return policy, nil
 		}
 	}
-	return nil, errPolicyNotFound
+	return nil, ErrPolicyNotFound
+}
+
+// IsVMPlacementPolicy reports whether a compute policy places VMs on VM
+// groups, as opposed to only sizing them.
+func IsVMPlacementPolicy(policy *govcd.VdcComputePolicyV2) bool {
+	return policy.VdcComputePolicyV2.PolicyType == "VdcVmPolicy" && !policy.VdcComputePolicyV2.IsSizingOnly
+}
+
+// GetVMPlacementPolicy finds a VM placement policy by name or URN from a list
+// of policies
+func GetVMPlacementPolicy(policies []*govcd.VdcComputePolicyV2, nameOrID string) (*govcd.VdcComputePolicyV2, error) {
+	for _, policy := range policies {
+		if !IsVMPlacementPolicy(policy) {
+			continue
+		}
+		if policy.VdcComputePolicyV2.ID == nameOrID || policy.VdcComputePolicyV2.Name == nameOrID {
+			return policy, nil
+		}
+	}
+	return nil, ErrPlacementPolicyNotFound
 }
@@ -4,7 +4,9 @@ import (
 	"crypto/tls"
 	"fmt"
 	"log"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -119,6 +121,7 @@ var VScanCodes = map[string]int{
 	"KP3":       81, // Keypad 3/PgDn
 	"KP0":       82, // Keypad 0/Ins
 	"KPDOT":     83, // Keypad ./Del
+	"INTL":      86, // Key between left shift and Z on ISO keyboards
 	"F11":       87,
 	"F12":       88,
 	// Extended keys (prefixed with 0xE0 in raw scan codes)
@@ -136,6 +139,9 @@ var VScanCodes = map[string]int{
 	"PAGEDOWN":  0x151,
 	"INSERT":    0x152,
 	"DELETE":    0x153,
+	"LSUPER":    0x15B,
+	"RSUPER":    0x15C,
+	"MENU":      0x15D,
 }
 
 // WMKSClient provides console access to a VM via WebMKS protocol
@@ -143,6 +149,8 @@ type WMKSClient struct {
 	conn          *websocket.Conn
 	ticket        *MksTicket
 	insecure      bool
+	tlsConfig     *tls.Config
+	proxy         ProxyFunc
 	connected     bool
 	keyDelay      time.Duration
 	groupDelay    time.Duration
@@ -154,6 +162,7 @@ type WMKSClient struct {
 	bytesWritten  int64         // track bytes sent
 	keysPressed   int           // track number of keys sent
 	lastWriteTime time.Time     // track last successful write
+	lost          atomic.Bool   // set by the reader when the server drops the connection
 
 	// Write mutex - gorilla/websocket doesn't support concurrent writers.
 	// Needed because reader goroutine sends ACKs/ClientCaps while main
@@ -164,6 +173,9 @@ type WMKSClient struct {
 	fbWidth  uint16
 	fbHeight uint16
 
+	// Screen contents, kept up to date by the reader for Screenshot
+	screen *framebuffer
+
 	// Server capability tracking
 	useVMWAck bool   // Server expects frame update ACKs (serverCapUpdateAck)
 	ackCounter uint16 // Incrementing ACK sequence number
@@ -179,6 +191,23 @@ func WithInsecure(insecure bool) WMKSOption {
 	}
 }
 
+// WithTLSConfig sets the TLS settings used to reach the console, normally
+// Driver.TLSConfig so a custom CA trusted for the API is trusted here too.
+// It takes precedence over WithInsecure.
+func WithTLSConfig(cfg *tls.Config) WMKSOption {
+	return func(c *WMKSClient) {
+		c.tlsConfig = cfg
+	}
+}
+
+// WithProxy sets the proxy selection used to reach the console, normally
+// Driver.ConsoleProxy so console_proxy_url and proxy_url are honored
+func WithProxy(proxy ProxyFunc) WMKSOption {
+	return func(c *WMKSClient) {
+		c.proxy = proxy
+	}
+}
+
 // WithKeyDelay sets the delay between individual key presses
 func WithKeyDelay(d time.Duration) WMKSOption {
 	return func(c *WMKSClient) {
@@ -208,6 +237,7 @@ func NewWMKSClient(ticket *MksTicket, opts ...WMKSOption) *WMKSClient {
 		keyDelay:     10 * time.Millisecond,
 		groupDelay:   100 * time.Millisecond,
 		specialDelay: 50 * time.Millisecond,
+		proxy:        http.ProxyFromEnvironment,
 	}
 	for _, opt := range opts {
 		opt(c)
@@ -219,11 +249,14 @@ func NewWMKSClient(ticket *MksTicket, opts ...WMKSOption) *WMKSClient {
 func (c *WMKSClient) Connect() error {
 	wsURL := c.ticket.WebSocketURL()
 
+	tlsConfig := c.tlsConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{InsecureSkipVerify: c.insecure}
+	}
 	dialer := websocket.Dialer{
-		Subprotocols: []string{"binary"}, // VCD console uses "binary" subprotocol
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: c.insecure,
-		},
+		Subprotocols:    []string{"binary"}, // VCD console uses "binary" subprotocol
+		Proxy:           c.proxy,
+		TLSClientConfig: tlsConfig,
 	}
 
 	// Add required headers for WMKS handshake
@@ -247,6 +280,7 @@ func (c *WMKSClient) Connect() error {
 	}
 
 	c.connected = true
+	c.lost.Store(false)
 	c.connectedAt = time.Now()
 	log.Printf("[DEBUG] WMKS connection established at %s", c.connectedAt.Format(time.RFC3339))
 
@@ -276,11 +310,13 @@ func (c *WMKSClient) Connect() error {
 				}
 				if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
 					log.Printf("[DEBUG] WMKS connection closed normally after %s", time.Since(c.connectedAt))
+					c.lost.Store(true)
 					return
 				}
 				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 					log.Printf("[WARN] WMKS unexpected connection close after %s (keys=%d, bytes=%d): %v",
 						time.Since(c.connectedAt), c.keysPressed, c.bytesWritten, err)
+					c.lost.Store(true)
 					return
 				}
 				// Track consecutive non-timeout errors to detect dead connections
@@ -288,6 +324,7 @@ func (c *WMKSClient) Connect() error {
 					consecutiveErrors++
 					if consecutiveErrors >= 3 {
 						log.Printf("[WARN] WMKS reader: %d consecutive errors, connection likely dead: %v", consecutiveErrors, err)
+						c.lost.Store(true)
 					}
 				}
 				continue
@@ -305,6 +342,10 @@ func (c *WMKSClient) Connect() error {
 				// after each update, ACK it and request the next frame.
 				// This keeps bidirectional data flowing and satisfies server
 				// flow control (serverCapUpdateAck).
+				if width, height := c.screen.apply(data); width != c.fbWidth || height != c.fbHeight {
+					// The guest changed resolution; request the whole new screen
+					c.fbWidth, c.fbHeight = width, height
+				}
 				if c.useVMWAck {
 					c.sendAck()
 				}
@@ -423,6 +464,12 @@ func (c *WMKSClient) rfbHandshake() error {
 	log.Printf("[DEBUG] WMKS ServerInit: framebuffer %dx%d (parsed from bytes [%d %d %d %d])",
 		c.fbWidth, c.fbHeight, serverInit[0], serverInit[1], serverInit[2], serverInit[3])
 
+	format := defaultPixelFormat
+	if len(serverInit) >= 20 {
+		format = parsePixelFormat(serverInit[4:20])
+	}
+	c.screen = newFramebuffer(int(c.fbWidth), int(c.fbHeight), format)
+
 	// Step 8: Send SetEncodings to advertise supported encodings
 	// Must include at least one image encoding (TightPNG) so the server can
 	// respond to FBUpdateRequests. Must include ServerCaps to trigger the
@@ -585,12 +632,19 @@ func (c *WMKSClient) sendAck() {
 	}
 }
 
+// Alive reports whether the console connection is still usable. It turns
+// false once the server drops the connection, e.g. when the VM is reset.
+func (c *WMKSClient) Alive() bool {
+	return c.connected && !c.lost.Load()
+}
+
 // Close closes the WebSocket connection
 func (c *WMKSClient) Close() error {
 	if c.conn != nil {
 		c.connected = false
 		if c.stopReader != nil {
 			close(c.stopReader)
+			c.stopReader = nil
 			// Give the reader goroutine a moment to exit
 			time.Sleep(100 * time.Millisecond)
 		}
@@ -1,27 +1,129 @@
 package driver
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"os"
-	"strings"
+	"regexp"
+	"slices"
+	"strconv"
 	"time"
-	"unicode"
 
 	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
 )
 
-const shiftedChars = "~!@#$%^&*()_+{}|:\"<>?"
-
 // WMKSBootDriver implements bootcommand.BCDriver for WMKS console
 type WMKSBootDriver struct {
 	client      *WMKSClient
 	interval    time.Duration
-	specialMap  map[string]int // maps special key names to scan codes
-	scancodeMap map[rune]int   // maps characters to scan codes
+	specialMap  map[string]int     // maps special key names to scan codes
+	scancodeMap map[rune]KeyStroke // maps characters to keys of the layout
+	reconnect   func() (*WMKSClient, error)
+	held        []int // keys held down by the boot command, in press order
+}
+
+// maxReconnects is how many times in a row the console may be reconnected
+// before the keystroke is given up.
+const maxReconnects = 3
+
+// SetReconnect makes the driver replace a console connection that dropped,
+// e.g. when the console proxy recycled it, with one from reconnect and carry
+// on from the keystroke that could not be sent. Without it, a dropped
+// connection fails the boot command.
+func (d *WMKSBootDriver) SetReconnect(reconnect func() (*WMKSClient, error)) {
+	d.reconnect = reconnect
+}
+
+// Client returns the console connection in use, which changes when the
+// driver reconnects.
+func (d *WMKSBootDriver) Client() *WMKSClient {
+	return d.client
+}
+
+// keyEvent is a key going down or up.
+type keyEvent struct {
+	scanCode int
+	down     bool
+}
+
+// stroke sends the key events of one keystroke on the current connection.
+// When the connection drops, it reconnects and sends the whole keystroke
+// again, after pressing the keys held by the boot command once more, since
+// keys held down on the old connection are released with it.
+func (d *WMKSBootDriver) stroke(events []keyEvent) error {
+	send := events
+	for attempt := 0; ; attempt++ {
+		if d.reconnect == nil || d.client.Alive() {
+			err := d.send(send)
+			if err == nil || d.reconnect == nil {
+				return err
+			}
+			log.Printf("[DEBUG] Error sending keystroke: %v", err)
+		}
+		if attempt == maxReconnects {
+			return fmt.Errorf("console connection lost, gave up after %d reconnects", maxReconnects)
+		}
+
+		log.Printf("[WARN] Console connection lost after %d keys, reconnecting", d.client.keysPressed)
+		client, err := d.reconnect()
+		if err != nil {
+			return fmt.Errorf("error reconnecting to console: %w", err)
+		}
+		d.client = client
+
+		send = nil
+		for _, scanCode := range d.held {
+			send = append(send, keyEvent{scanCode, true})
+		}
+		send = append(send, events...)
+	}
+}
+
+func (d *WMKSBootDriver) send(events []keyEvent) error {
+	for _, e := range events {
+		if err := d.client.SendKeyEvent(e.scanCode, e.down); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *WMKSBootDriver) isHeld(scanCode int) bool {
+	return slices.Contains(d.held, scanCode)
+}
+
+// hold records the keys the boot command holds down, e.g. with
+// <leftCtrlOn>, until they are released.
+func (d *WMKSBootDriver) hold(scanCode int, down bool) {
+	d.held = slices.DeleteFunc(d.held, func(c int) bool { return c == scanCode })
+	if down {
+		d.held = append(d.held, scanCode)
+	}
+}
+
+// ReleaseKeys releases the keys the boot command left held down, most
+// recently pressed first, so no modifier stays stuck in the guest.
+func (d *WMKSBootDriver) ReleaseKeys() error {
+	if len(d.held) == 0 {
+		return nil
+	}
+	log.Printf("[WARN] Releasing %d keys left held down by the boot command", len(d.held))
+
+	var events []keyEvent
+	for i := len(d.held) - 1; i >= 0; i-- {
+		events = append(events, keyEvent{d.held[i], false})
+	}
+	d.held = nil
+	if !d.client.Alive() {
+		return nil
+	}
+	return d.send(events)
 }
 
-// NewWMKSBootDriver creates a boot command driver that sends keystrokes via WMKS
-func NewWMKSBootDriver(client *WMKSClient, interval time.Duration) *WMKSBootDriver {
+// NewWMKSBootDriver creates a boot command driver that sends keystrokes via WMKS,
+// typing characters as on the given keyboard layout of the guest
+func NewWMKSBootDriver(client *WMKSClient, interval time.Duration, layout string) *WMKSBootDriver {
 	// Default key interval from environment or use default
 	keyInterval := 100 * time.Millisecond
 	if delay, err := time.ParseDuration(os.Getenv(bootcommand.PackerKeyEnv)); err == nil {
@@ -57,6 +159,8 @@ func NewWMKSBootDriver(client *WMKSClient, interval time.Duration) *WMKSBootDriv
 		"leftalt":    VScanCodes["LALT"],
 		"leftctrl":   VScanCodes["LCTRL"],
 		"leftshift":  VScanCodes["LSHIFT"],
+		"leftsuper":  VScanCodes["LSUPER"],
+		"menu":       VScanCodes["MENU"],
 		"pagedown":   VScanCodes["PAGEDOWN"],
 		"pageup":     VScanCodes["PAGEUP"],
 		"return":     VScanCodes["ENTER"],
@@ -64,54 +168,17 @@ func NewWMKSBootDriver(client *WMKSClient, interval time.Duration) *WMKSBootDriv
 		"rightalt":   VScanCodes["RALT"],
 		"rightctrl":  VScanCodes["RCTRL"],
 		"rightshift": VScanCodes["RSHIFT"],
+		"rightsuper": VScanCodes["RSUPER"],
 		"spacebar":   VScanCodes["SPACE"],
 		"tab":        VScanCodes["TAB"],
 		"up":         VScanCodes["UP"],
 	}
 
-	// Build character to scancode map
-	// Maps each character to its PS/2 scan code
-	scancodeMap := map[rune]int{
-		'1': VScanCodes["1"], '2': VScanCodes["2"], '3': VScanCodes["3"],
-		'4': VScanCodes["4"], '5': VScanCodes["5"], '6': VScanCodes["6"],
-		'7': VScanCodes["7"], '8': VScanCodes["8"], '9': VScanCodes["9"],
-		'0': VScanCodes["0"],
-		'!': VScanCodes["1"], '@': VScanCodes["2"], '#': VScanCodes["3"],
-		'$': VScanCodes["4"], '%': VScanCodes["5"], '^': VScanCodes["6"],
-		'&': VScanCodes["7"], '*': VScanCodes["8"], '(': VScanCodes["9"],
-		')': VScanCodes["0"],
-		'-': VScanCodes["MINUS"], '_': VScanCodes["MINUS"],
-		'=': VScanCodes["EQUALS"], '+': VScanCodes["EQUALS"],
-		'q': VScanCodes["Q"], 'w': VScanCodes["W"], 'e': VScanCodes["E"],
-		'r': VScanCodes["R"], 't': VScanCodes["T"], 'y': VScanCodes["Y"],
-		'u': VScanCodes["U"], 'i': VScanCodes["I"], 'o': VScanCodes["O"],
-		'p': VScanCodes["P"],
-		'Q': VScanCodes["Q"], 'W': VScanCodes["W"], 'E': VScanCodes["E"],
-		'R': VScanCodes["R"], 'T': VScanCodes["T"], 'Y': VScanCodes["Y"],
-		'U': VScanCodes["U"], 'I': VScanCodes["I"], 'O': VScanCodes["O"],
-		'P': VScanCodes["P"],
-		'[': VScanCodes["LBRACKET"], ']': VScanCodes["RBRACKET"],
-		'{': VScanCodes["LBRACKET"], '}': VScanCodes["RBRACKET"],
-		'a': VScanCodes["A"], 's': VScanCodes["S"], 'd': VScanCodes["D"],
-		'f': VScanCodes["F"], 'g': VScanCodes["G"], 'h': VScanCodes["H"],
-		'j': VScanCodes["J"], 'k': VScanCodes["K"], 'l': VScanCodes["L"],
-		'A': VScanCodes["A"], 'S': VScanCodes["S"], 'D': VScanCodes["D"],
-		'F': VScanCodes["F"], 'G': VScanCodes["G"], 'H': VScanCodes["H"],
-		'J': VScanCodes["J"], 'K': VScanCodes["K"], 'L': VScanCodes["L"],
-		';': VScanCodes["SEMICOLON"], ':': VScanCodes["SEMICOLON"],
-		'\'': VScanCodes["QUOTE"], '"': VScanCodes["QUOTE"],
-		'`': VScanCodes["BACKTICK"], '~': VScanCodes["BACKTICK"],
-		'\\': VScanCodes["BACKSLASH"], '|': VScanCodes["BACKSLASH"],
-		'z': VScanCodes["Z"], 'x': VScanCodes["X"], 'c': VScanCodes["C"],
-		'v': VScanCodes["V"], 'b': VScanCodes["B"], 'n': VScanCodes["N"],
-		'm': VScanCodes["M"],
-		'Z': VScanCodes["Z"], 'X': VScanCodes["X"], 'C': VScanCodes["C"],
-		'V': VScanCodes["V"], 'B': VScanCodes["B"], 'N': VScanCodes["N"],
-		'M': VScanCodes["M"],
-		',': VScanCodes["COMMA"], '<': VScanCodes["COMMA"],
-		'.': VScanCodes["PERIOD"], '>': VScanCodes["PERIOD"],
-		'/': VScanCodes["SLASH"], '?': VScanCodes["SLASH"],
-		' ': VScanCodes["SPACE"],
+	// Unknown layouts are rejected when the configuration is prepared
+	scancodeMap, err := KeyboardLayout(layout)
+	if err != nil {
+		log.Printf("[WARN] %v, using the US layout", err)
+		scancodeMap, _ = KeyboardLayout("us")
 	}
 
 	return &WMKSBootDriver{
@@ -122,69 +189,187 @@ func NewWMKSBootDriver(client *WMKSClient, interval time.Duration) *WMKSBootDriv
 	}
 }
 
-// SendKey sends a regular character key
+// SendKey sends a regular character key. The modifiers the character needs
+// on the layout are pressed around its key going down, unless the boot
+// command already holds them.
 func (d *WMKSBootDriver) SendKey(key rune, action bootcommand.KeyAction) error {
-	scancode, ok := d.scancodeMap[key]
+	if key >= scancodeRuneBase && key <= scancodeRuneBase+0x1FF {
+		return d.SendScancode(int(key-scancodeRuneBase), action)
+	}
+
+	stroke, ok := d.scancodeMap[key]
 	if !ok {
 		return fmt.Errorf("unknown key: %c", key)
 	}
 
-	needsShift := unicode.IsUpper(key) || strings.ContainsRune(shiftedChars, key)
+	var modifiers []int
+	if stroke.Shift && !d.isHeld(VScanCodes["LSHIFT"]) && !d.isHeld(VScanCodes["RSHIFT"]) {
+		modifiers = append(modifiers, VScanCodes["LSHIFT"])
+	}
+	if stroke.AltGr && !d.isHeld(VScanCodes["RALT"]) {
+		modifiers = append(modifiers, VScanCodes["RALT"])
+	}
 
+	var events []keyEvent
 	// Handle key down
 	if action&(bootcommand.KeyOn|bootcommand.KeyPress) != 0 {
-		if needsShift {
-			if err := d.client.SendKeyEvent(VScanCodes["LSHIFT"], true); err != nil {
-				return err
-			}
+		for _, modifier := range modifiers {
+			events = append(events, keyEvent{modifier, true})
 		}
-		if err := d.client.SendKeyEvent(scancode, true); err != nil {
-			return err
+		events = append(events, keyEvent{stroke.ScanCode, true})
+		// A held character must not hold its modifiers with it
+		if action&bootcommand.KeyOn != 0 {
+			for i := len(modifiers) - 1; i >= 0; i-- {
+				events = append(events, keyEvent{modifiers[i], false})
+			}
 		}
 	}
 
 	// Handle key up
 	if action&(bootcommand.KeyOff|bootcommand.KeyPress) != 0 {
-		if err := d.client.SendKeyEvent(scancode, false); err != nil {
-			return err
-		}
-		if needsShift {
-			if err := d.client.SendKeyEvent(VScanCodes["LSHIFT"], false); err != nil {
-				return err
+		events = append(events, keyEvent{stroke.ScanCode, false})
+		if action&bootcommand.KeyPress != 0 {
+			for i := len(modifiers) - 1; i >= 0; i-- {
+				events = append(events, keyEvent{modifiers[i], false})
 			}
 		}
+		// A dead key prints its character when followed by a space
+		if stroke.Dead {
+			events = append(events, keyEvent{VScanCodes["SPACE"], true}, keyEvent{VScanCodes["SPACE"], false})
+		}
+	}
+
+	if err := d.stroke(events); err != nil {
+		return err
 	}
+	d.updateHeld(stroke.ScanCode, action)
 
 	time.Sleep(d.interval)
 	return nil
 }
 
-// SendSpecial sends a special key (like enter, esc, f1, etc.)
+// SendSpecial sends a special key (like enter, esc, f1, etc.). Modifiers
+// held with <leftCtrlOn> and the like stay down until released, so
+// combinations such as <leftCtrlOn><leftAltOn><del><leftAltOff><leftCtrlOff>
+// reach the guest as typed.
 func (d *WMKSBootDriver) SendSpecial(special string, action bootcommand.KeyAction) error {
 	scancode, ok := d.specialMap[special]
 	if !ok {
 		return fmt.Errorf("unknown special key: %s", special)
 	}
 
-	// Handle key down
-	if action&(bootcommand.KeyOn|bootcommand.KeyPress) != 0 {
-		if err := d.client.SendKeyEvent(scancode, true); err != nil {
-			return err
-		}
+	var events []keyEvent
+	// Handle key down, unless the key is already held
+	if action&(bootcommand.KeyOn|bootcommand.KeyPress) != 0 && !(action&bootcommand.KeyOn != 0 && d.isHeld(scancode)) {
+		events = append(events, keyEvent{scancode, true})
 	}
 
 	// Handle key up
 	if action&(bootcommand.KeyOff|bootcommand.KeyPress) != 0 {
-		if err := d.client.SendKeyEvent(scancode, false); err != nil {
-			return err
-		}
+		events = append(events, keyEvent{scancode, false})
+	}
+
+	if err := d.stroke(events); err != nil {
+		return err
 	}
+	d.updateHeld(scancode, action)
 
 	time.Sleep(d.interval)
 	return nil
 }
 
+// SendScancode sends a raw PS/2 scan code given with a <scancode-0x1C>
+// token, for keys missing from the special keys, e.g. keypad keys. Extended keys have the
+// 0x100 bit set, as in VScanCodes.
+func (d *WMKSBootDriver) SendScancode(scanCode int, action bootcommand.KeyAction) error {
+	var events []keyEvent
+	if action&(bootcommand.KeyOn|bootcommand.KeyPress) != 0 && !(action&bootcommand.KeyOn != 0 && d.isHeld(scanCode)) {
+		events = append(events, keyEvent{scanCode, true})
+	}
+	if action&(bootcommand.KeyOff|bootcommand.KeyPress) != 0 {
+		events = append(events, keyEvent{scanCode, false})
+	}
+
+	if err := d.stroke(events); err != nil {
+		return err
+	}
+	d.updateHeld(scanCode, action)
+
+	time.Sleep(d.interval)
+	return nil
+}
+
+// updateHeld records a key held or released by the boot command.
+func (d *WMKSBootDriver) updateHeld(scanCode int, action bootcommand.KeyAction) {
+	switch {
+	case action&bootcommand.KeyOn != 0:
+		d.hold(scanCode, true)
+	case action&bootcommand.KeyOff != 0:
+		d.hold(scanCode, false)
+	}
+}
+
 // Flush sends any buffered scancodes - WMKS sends immediately so this is a no-op
 func (d *WMKSBootDriver) Flush() error {
 	return nil
 }
+
+// scancodeToken matches the raw scan code tokens of boot_command, which the
+// Packer boot command grammar does not know: <scancode-0x1C>, optionally
+// followed by On or Off to hold and release the key.
+var scancodeToken = regexp.MustCompile(`(?i)<scancode-(0x[0-9a-f]+|[0-9]+)(on|off)?>`)
+
+// scancodeRuneBase is where the private use runes standing for raw scan
+// codes start. Scan code tokens are replaced by these runes before the
+// boot command is parsed, and SendKey sends them as scan codes.
+const scancodeRuneBase = 0xF0000
+
+// BootSequence is a parsed boot_command entry.
+type BootSequence interface {
+	Do(ctx context.Context, d bootcommand.BCDriver) error
+}
+
+// ParseBootCommand parses a boot_command entry, including raw scan code
+// tokens. Scan codes are given in hex or decimal, with 0xE0 in the high
+// byte, or the 0x100 bit, for extended keys: <scancode-0x1C> is Enter,
+// <scancode-0xE01C> keypad Enter.
+func ParseBootCommand(keys string) (BootSequence, error) {
+	var parseErr error
+	keys = scancodeToken.ReplaceAllStringFunc(keys, func(token string) string {
+		m := scancodeToken.FindStringSubmatch(token)
+		scanCode, err := parseScancode(m[1])
+		if err != nil {
+			if parseErr == nil {
+				parseErr = fmt.Errorf("invalid %s: %w", token, err)
+			}
+			return token
+		}
+		r := string(rune(scancodeRuneBase + scanCode))
+		if m[2] == "" {
+			return r
+		}
+		// Held like a character, e.g. <aOn>
+		return "<" + r + m[2] + ">"
+	})
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	return bootcommand.GenerateExpressionSequence(keys)
+}
+
+// parseScancode converts a scan code of a <scancode-...> token to the
+// VScanCode sent by WMKS.
+func parseScancode(s string) (int, error) {
+	code, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return 0, err
+	}
+	if code&0xFF00 == 0xE000 {
+		code = 0x100 | code&0xFF
+	}
+	if code&0xFF == 0 || code > 0x1FF {
+		return 0, fmt.Errorf("scan code must be between 0x01 and 0xFF, prefixed by 0xE0 for extended keys")
+	}
+	return int(code), nil
+}
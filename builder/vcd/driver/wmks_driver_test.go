@@ -0,0 +1,33 @@
+package driver
+
+import "testing"
+
+func TestParseScancode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{"0x1c", 0x1c, false},
+		{"28", 28, false},
+		{"0xFF", 0xff, false},
+		{"0xe05b", 0x15b, false},
+		{"0xE048", 0x148, false},
+		{"0", 0, true},
+		{"0x100", 0, true},
+		{"0x1c1c", 0, true},
+		{"0xe000", 0, true},
+		{"0x10000", 0, true},
+		{"enter", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseScancode(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseScancode(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseScancode(%q) = %#x, want %#x", tt.in, got, tt.want)
+		}
+	}
+}
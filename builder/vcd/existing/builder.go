@@ -0,0 +1,146 @@
+// Copyright 2025 Juan Font
+// BSD-3-Clause
+
+package existing
+
+import (
+	"context"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/common"
+)
+
+type Builder struct {
+	config Config
+	runner multistep.Runner
+}
+
+// ConfigSpec returns an HCL2 object specification based on the Builder's configuration mapping.
+func (b *Builder) ConfigSpec() hcldec.ObjectSpec {
+	return b.config.FlatMapstructure().HCL2Spec()
+}
+
+// Prepare processes the given raw inputs, validates the configuration, and returns warnings or errors if any occur.
+func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
+	warnings, errs := b.config.Prepare(raws...)
+	if errs != nil {
+		return nil, warnings, errs
+	}
+
+	return common.PortForwardGeneratedVars, warnings, nil
+}
+
+// Run powers on an existing, powered-off VM, provisions it, shuts it down
+// and optionally re-captures it into a catalog. No catalog, ISO or vApp is
+// created along the way.
+func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
+	state := new(multistep.BasicStateBag)
+	state.Put("debug", b.config.PackerDebug)
+	state.Put("hook", hook)
+	state.Put("ui", ui)
+
+	// Written onto the VM and onto the captured template
+	provenance := common.Provenance(&b.config.PackerConfig, map[string]string{
+		"source_vm": b.config.LocationConfig.VMName,
+	})
+
+	steps := []multistep.Step{
+		// Step 1: Connect to VCD
+		&common.StepConnect{
+			Config: &b.config.ConnectConfig,
+		},
+
+		// Step 2: Locate the existing VM (must be powered off)
+		&common.StepLocateVM{
+			VDCName:  b.config.LocationConfig.VDC,
+			VAppName: b.config.LocationConfig.VApp,
+			VMName:   b.config.LocationConfig.VMName,
+		},
+
+		// Step 3: Write the provenance and metadata entries onto the VM
+		&common.StepMetadata{
+			Config:     &b.config.MetadataConfig,
+			Provenance: provenance,
+		},
+
+		// Step 4: Power on VM (with IP conflict retry logic)
+		&common.StepRun{
+			Config:      &b.config.RunConfig,
+			VDCName:     b.config.LocationConfig.VDC,
+			NetworkName: b.config.LocationConfig.Network,
+		},
+
+		// Step 5: Wait for VM to get IP address (for communicator)
+		&common.StepWaitForIP{
+			Config: &b.config.WaitIpConfig,
+		},
+
+		// Step 6: Forward extra ports for provisioners (if configured)
+		&common.StepPortForward{
+			Config: &b.config.PortForwardConfig,
+		},
+
+		// Step 7: Probe SSH/WinRM port (reports firewall vs service issues)
+		&common.StepProbeCommunicator{
+			Config: &b.config.Comm,
+		},
+
+		// Step 8: Connect to VM via SSH/WinRM
+		&communicator.StepConnect{
+			Config:    &b.config.Comm,
+			Host:      common.CommHost(b.config.Comm.Host()),
+			SSHConfig: b.config.Comm.SSHConfigFunc(),
+		},
+
+		// Step 9: Run provisioners
+		&commonsteps.StepProvision{},
+
+		// Step 10: Shutdown VM
+		&common.StepShutdown{
+			Config:   &b.config.ShutdownConfig,
+			CommType: b.config.Comm.Type,
+		},
+
+		// Step 11: Eject media before the capture (if exporting)
+		&common.StepEjectMedia{
+			Config: b.config.ExportToCatalog,
+		},
+
+		// Step 12: Re-capture to catalog (optional)
+		&common.StepExportToCatalog{
+			Config:         b.config.ExportToCatalog,
+			StorageProfile: b.config.LocationConfig.CatalogStorageProfile,
+			Provenance:     provenance,
+			Metadata:       b.config.Metadata,
+		},
+	}
+
+	b.runner = commonsteps.NewRunnerWithPauseFn(steps, b.config.PackerConfig, ui, state)
+	b.runner.Run(b.config.ConnectConfig.TaskContext(ctx, ui), state)
+
+	if err := common.StateError(state); err != nil {
+		return nil, err
+	}
+
+	if _, ok := state.GetOk("vm"); !ok {
+		return nil, nil
+	}
+
+	// The VM outlives the build, so it is deliberately left out of the
+	// artifact: destroying the artifact must not delete it.
+	artifact := &common.Artifact{
+		Name:     b.config.LocationConfig.VMName,
+		Location: b.config.LocationConfig,
+		StateData: map[string]interface{}{
+			"vapp_name": state.Get("vapp_name"),
+		},
+	}
+
+	artifact.AddCapturedTemplate(state)
+
+	return artifact, nil
+}
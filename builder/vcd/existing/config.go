@@ -0,0 +1,89 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package existing
+
+import (
+	"fmt"
+
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/common"
+
+	packerCommon "github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+type Config struct {
+	packerCommon.PackerConfig `mapstructure:",squash"`
+
+	common.ConnectConfig     `mapstructure:",squash"`
+	common.LocationConfig    `mapstructure:",squash"`
+	common.RunConfig         `mapstructure:",squash"`
+	common.WaitIpConfig      `mapstructure:",squash"`
+	common.PortForwardConfig `mapstructure:",squash"`
+	Comm                     communicator.Config `mapstructure:",squash"`
+
+	common.ShutdownConfig `mapstructure:",squash"`
+
+	common.MetadataConfig `mapstructure:",squash"`
+
+	// Capture the customized virtual machine into a catalog.
+	// The virtual machine is only modified in place if no [export to catalog configuration](#export-to-catalog-configuration) is specified.
+	ExportToCatalog *common.ExportToCatalogConfig `mapstructure:"export_to_catalog"`
+
+	ctx interpolate.Context
+}
+
+// Prepare processes and validates the configuration for customizing an
+// existing virtual machine. It returns a list of warnings and an error if
+// validation fails.
+func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
+	err := config.Decode(c, &config.DecodeOpts{
+		PluginType:         common.BuilderId,
+		Interpolate:        true,
+		InterpolateContext: &c.ctx,
+	}, raws...)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings := make([]string, 0)
+	errs := new(packersdk.MultiError)
+
+	// The vApp has to exist, so it must be named
+	if c.LocationConfig.VApp == "" {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'vapp' is required"))
+	}
+
+	errs = packersdk.MultiErrorAppend(errs, c.ConnectConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.LocationConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.RunConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.WaitIpConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.PortForwardConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.MetadataConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.Comm.Prepare(&c.ctx)...)
+
+	shutdownWarnings, shutdownErrs := c.ShutdownConfig.Prepare(c.Comm)
+	warnings = append(warnings, shutdownWarnings...)
+	errs = packersdk.MultiErrorAppend(errs, shutdownErrs...)
+
+	if c.ExportToCatalog != nil {
+		errs = packersdk.MultiErrorAppend(errs, c.ExportToCatalog.Prepare(&c.LocationConfig)...)
+	}
+
+	// Checked last, as it needs a valid connection and location
+	if len(errs.Errors) == 0 {
+		errs = packersdk.MultiErrorAppend(errs, c.LocationConfig.CheckRemote(&c.ConnectConfig, nil)...)
+	}
+
+	if len(errs.Errors) > 0 {
+		return warnings, errs
+	}
+
+	return warnings, nil
+}
@@ -0,0 +1,1239 @@
+// Package driverfake provides in-memory fakes of the VCD driver, so that
+// the build steps can be tested without a VCD. It is only imported by
+// tests.
+package driverfake
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"net/http"
+	"net/netip"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+	"github.com/vmware/go-vcloud-director/v3/types/v56"
+)
+
+// Driver is an in-memory driver.Driver for unit testing steps without a VCD.
+// Tests register the VDCs, networks, catalogs, vApps and VMs a step expects
+// with the Add methods, run the step, then inspect the fake. Long running
+// operations (vApp creation and deletion, uploads, copies) and every VM
+// operation run as tasks on Tasks, where failures and delays are injected.
+// Any other call fails with the error registered in Errors for its method
+// name. Calls records the methods called, in order.
+//
+// The govcd objects returned carry names and IDs but no API client, so a
+// step that calls their methods directly, rather than going through
+// Driver, cannot run against the fake yet.
+type Driver struct {
+	OrgName string
+	// Version is the API version reported by APIVersion, "38.0" by default.
+	Version string
+	Tasks   *TaskEngine
+	Errors  map[string]error
+
+	mu           sync.Mutex
+	calls        []string
+	vdcs         map[string]*fakeVdc
+	catalogs     map[string]*fakeCatalog // by org/catalog
+	portForwards []*driver.PortForward
+	downloads    map[string][]byte // by transfer URL
+}
+
+var _ driver.Driver = (*Driver)(nil)
+
+type fakeVdc struct {
+	vdc             *govcd.Vdc
+	storageProfiles []*driver.StorageProfileInfo
+	networks        map[string]*driver.NetworkInfo
+	allocatedIPs    map[string]bool
+	vapps           map[string]*fakeVApp
+}
+
+type fakeVApp struct {
+	vapp *govcd.VApp
+	vms  map[string]*VM
+}
+
+type fakeCatalog struct {
+	org         string
+	catalog     *govcd.Catalog
+	media       []*fakeMedia
+	templates   []*driver.VAppTemplateInfo
+	sharedWith  []string
+	accessLevel string
+	published   bool
+}
+
+type fakeMedia struct {
+	media    *govcd.Media
+	checksum string
+}
+
+// NewDriver returns an empty Driver for the organization.
+func NewDriver(orgName string) *Driver {
+	return &Driver{
+		OrgName:   orgName,
+		Version:   "38.0",
+		Tasks:     &TaskEngine{},
+		Errors:    make(map[string]error),
+		vdcs:      make(map[string]*fakeVdc),
+		catalogs:  make(map[string]*fakeCatalog),
+		downloads: make(map[string][]byte),
+	}
+}
+
+var fakeIDCounter uint64
+
+// fakeID returns a new UUID-shaped ID for a fake object.
+func fakeID() string {
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", atomic.AddUint64(&fakeIDCounter, 1))
+}
+
+// call records a call to method and returns the error registered for it.
+// It must be called with the lock held.
+func (d *Driver) call(method string) error {
+	d.calls = append(d.calls, method)
+	return d.Errors[method]
+}
+
+// Calls returns the Driver methods called so far, in order.
+func (d *Driver) Calls() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.calls...)
+}
+
+// --- Test Setup ---
+
+// AddVdc registers a VDC with a default storage profile.
+func (d *Driver) AddVdc(name string) *govcd.Vdc {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	id := fakeID()
+	vdc := govcd.NewVdc(nil)
+	vdc.Vdc = &types.Vdc{
+		Name: name,
+		ID:   "urn:vcloud:vdc:" + id,
+		HREF: "https://vcd.example.com/api/vdc/" + id,
+	}
+	d.vdcs[name] = &fakeVdc{
+		vdc: vdc,
+		storageProfiles: []*driver.StorageProfileInfo{{
+			Name:    "*",
+			ID:      "urn:vcloud:vdcstorageProfile:" + fakeID(),
+			Enabled: true,
+			Default: true,
+		}},
+		networks:     make(map[string]*driver.NetworkInfo),
+		allocatedIPs: make(map[string]bool),
+		vapps:        make(map[string]*fakeVApp),
+	}
+	return vdc
+}
+
+// AddNetwork registers an org VDC network. IP addresses are handed out by
+// FindAvailableIP from its IPRanges, skipping the gateway.
+func (d *Driver) AddNetwork(vdcName, name string, info driver.NetworkInfo) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	v, ok := d.vdcs[vdcName]
+	if !ok {
+		return fmt.Errorf("VDC %s not found", vdcName)
+	}
+	v.networks[name] = &info
+	return nil
+}
+
+// AddVM registers a VM in a vApp, creating the vApp when needed.
+func (d *Driver) AddVM(vdcName, vappName, vmName string) (*VM, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	v, ok := d.vdcs[vdcName]
+	if !ok {
+		return nil, fmt.Errorf("VDC %s not found", vdcName)
+	}
+	a, ok := v.vapps[vappName]
+	if !ok {
+		a = newFakeVApp(vappName, "")
+		v.vapps[vappName] = a
+	}
+	vm := NewVM(vmName, d.Tasks)
+	a.vms[vmName] = vm
+	return vm, nil
+}
+
+func newFakeVApp(name, description string) *fakeVApp {
+	id := fakeID()
+	vapp := govcd.NewVApp(nil)
+	vapp.VApp = &types.VApp{
+		Name:        name,
+		ID:          "urn:vcloud:vapp:" + id,
+		HREF:        "https://vcd.example.com/api/vApp/vapp-" + id,
+		Description: description,
+		Status:      8, // RESOLVED
+	}
+	return &fakeVApp{vapp: vapp, vms: make(map[string]*VM)}
+}
+
+// AddCatalog registers a catalog of the driver's organization.
+func (d *Driver) AddCatalog(name string) *govcd.Catalog {
+	return d.AddOrgCatalog(d.OrgName, name)
+}
+
+// AddOrgCatalog registers a catalog of another organization, as seen by
+// GetOrgCatalog.
+func (d *Driver) AddOrgCatalog(orgName, name string) *govcd.Catalog {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.addCatalog(orgName, name, "").catalog
+}
+
+func (d *Driver) addCatalog(orgName, name, description string) *fakeCatalog {
+	id := fakeID()
+	catalog := govcd.NewCatalog(nil)
+	catalog.Catalog = &types.Catalog{
+		Name:        name,
+		ID:          "urn:vcloud:catalog:" + id,
+		HREF:        "https://vcd.example.com/api/catalog/" + id,
+		Description: description,
+	}
+	c := &fakeCatalog{org: orgName, catalog: catalog}
+	d.catalogs[orgName+"/"+name] = c
+	return c
+}
+
+// AddMedia registers a resolved media in a catalog of the driver's
+// organization, tagged with checksum unless it is empty.
+func (d *Driver) AddMedia(catalogName, name, checksum string) (*govcd.Media, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c, ok := d.catalogs[d.OrgName+"/"+catalogName]
+	if !ok {
+		return nil, fmt.Errorf("catalog %s not found", catalogName)
+	}
+	m := newFakeMedia(name, "")
+	m.checksum = checksum
+	c.media = append(c.media, m)
+	return m.media, nil
+}
+
+func newFakeMedia(name, description string) *fakeMedia {
+	id := fakeID()
+	media := govcd.NewMedia(nil)
+	media.Media = &types.Media{
+		Name:        name,
+		ID:          "urn:vcloud:media:" + id,
+		HREF:        "https://vcd.example.com/api/media/" + id,
+		Description: description,
+		ImageType:   "iso",
+		Status:      1, // RESOLVED
+	}
+	return &fakeMedia{media: media}
+}
+
+// AddTemplate registers a vApp template in a catalog of the driver's
+// organization, filling in its ID, catalog and creation time when unset.
+func (d *Driver) AddTemplate(catalogName string, info driver.VAppTemplateInfo) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c, ok := d.catalogs[d.OrgName+"/"+catalogName]
+	if !ok {
+		return fmt.Errorf("catalog %s not found", catalogName)
+	}
+	c.addTemplate(info)
+	return nil
+}
+
+func (c *fakeCatalog) addTemplate(info driver.VAppTemplateInfo) *driver.VAppTemplateInfo {
+	if info.ID == "" {
+		info.ID = "urn:vcloud:vapptemplate:" + fakeID()
+	}
+	if info.Created.IsZero() {
+		info.Created = time.Now()
+	}
+	if info.Status == "" {
+		info.Status = "RESOLVED"
+	}
+	info.Catalog = c.catalog.Catalog.Name
+	c.templates = append(c.templates, &info)
+	return &info
+}
+
+// AddDownload registers the content served at a transfer URL. The
+// descriptor of a vApp template enabled for download is served at
+// https://vcd.example.com/transfer/<template ID>/descriptor.ovf.
+func (d *Driver) AddDownload(fileURL string, content []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.downloads[fileURL] = content
+}
+
+// --- Test Inspection ---
+
+// HasVApp reports whether the vApp exists in the VDC.
+func (d *Driver) HasVApp(vdcName, vappName string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	v, ok := d.vdcs[vdcName]
+	if !ok {
+		return false
+	}
+	_, ok = v.vapps[vappName]
+	return ok
+}
+
+// CatalogMedia returns the media of a catalog of the driver's organization.
+func (d *Driver) CatalogMedia(catalogName string) []*govcd.Media {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c, ok := d.catalogs[d.OrgName+"/"+catalogName]
+	if !ok {
+		return nil
+	}
+	media := make([]*govcd.Media, 0, len(c.media))
+	for _, m := range c.media {
+		media = append(media, m.media)
+	}
+	return media
+}
+
+// PortForwards returns the port forwards that have not been deleted.
+func (d *Driver) PortForwards() []*driver.PortForward {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]*driver.PortForward(nil), d.portForwards...)
+}
+
+// --- VM Operations ---
+
+func (d *Driver) NewVM(ref *govcd.VM) driver.VirtualMachine {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, v := range d.vdcs {
+		for _, a := range v.vapps {
+			for _, vm := range a.vms {
+				if vm.vm.VM.ID == ref.VM.ID {
+					return vm
+				}
+			}
+		}
+	}
+	vm := NewVM(ref.VM.Name, d.Tasks)
+	vm.vm = ref
+	return vm
+}
+
+func (d *Driver) FindVM(vdcName, vappName, vmName string) (driver.VirtualMachine, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("FindVM"); err != nil {
+		return nil, err
+	}
+
+	a, err := d.vapp(vdcName, vappName)
+	if err != nil {
+		return nil, err
+	}
+	vm, ok := a.vms[vmName]
+	if !ok {
+		return nil, fmt.Errorf("error getting VM %s: %w", vmName, govcd.ErrorEntityNotFound)
+	}
+	return vm, nil
+}
+
+// vapp must be called with the lock held.
+func (d *Driver) vapp(vdcName, vappName string) (*fakeVApp, error) {
+	v, ok := d.vdcs[vdcName]
+	if !ok {
+		return nil, fmt.Errorf("error getting VDC %s: %w", vdcName, govcd.ErrorEntityNotFound)
+	}
+	a, ok := v.vapps[vappName]
+	if !ok {
+		return nil, fmt.Errorf("error getting vApp %s: %w", vappName, govcd.ErrorEntityNotFound)
+	}
+	return a, nil
+}
+
+// --- Org Operations ---
+
+func (d *Driver) GetOrg() (*govcd.Org, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("GetOrg"); err != nil {
+		return nil, err
+	}
+	org := govcd.NewOrg(nil)
+	org.Org = &types.Org{Name: d.OrgName, ID: "urn:vcloud:org:" + d.OrgName}
+	return org, nil
+}
+
+func (d *Driver) GetAdminOrg() (*govcd.AdminOrg, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("GetAdminOrg"); err != nil {
+		return nil, err
+	}
+	org := govcd.NewAdminOrg(nil)
+	org.AdminOrg = &types.AdminOrg{Name: d.OrgName, ID: "urn:vcloud:org:" + d.OrgName}
+	return org, nil
+}
+
+func (d *Driver) CheckConsoleAccess() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.call("CheckConsoleAccess")
+}
+
+// --- VDC Operations ---
+
+func (d *Driver) GetVdc(name string) (*govcd.Vdc, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("GetVdc"); err != nil {
+		return nil, err
+	}
+	v, ok := d.vdcs[name]
+	if !ok {
+		return nil, fmt.Errorf("error getting VDC %s: %w", name, govcd.ErrorEntityNotFound)
+	}
+	return v.vdc, nil
+}
+
+func (d *Driver) ListStorageProfiles(vdc *govcd.Vdc) ([]*driver.StorageProfileInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("ListStorageProfiles"); err != nil {
+		return nil, err
+	}
+	v, ok := d.vdcs[vdc.Vdc.Name]
+	if !ok {
+		return nil, fmt.Errorf("error getting VDC %s: %w", vdc.Vdc.Name, govcd.ErrorEntityNotFound)
+	}
+	return append([]*driver.StorageProfileInfo(nil), v.storageProfiles...), nil
+}
+
+// --- vApp Operations ---
+
+func (d *Driver) GetVApp(vdcName, vappName string) (*govcd.VApp, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("GetVApp"); err != nil {
+		return nil, err
+	}
+	a, err := d.vapp(vdcName, vappName)
+	if err != nil {
+		return nil, err
+	}
+	return a.vapp, nil
+}
+
+func (d *Driver) GetVAppById(id string) (*govcd.VApp, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("GetVAppById"); err != nil {
+		return nil, err
+	}
+	for _, v := range d.vdcs {
+		for _, a := range v.vapps {
+			if a.vapp.VApp.ID == id {
+				return a.vapp, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("error getting vApp %s: %w", id, govcd.ErrorEntityNotFound)
+}
+
+func (d *Driver) ListVApps(vdc *govcd.Vdc) ([]*types.QueryResultVAppRecordType, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("ListVApps"); err != nil {
+		return nil, err
+	}
+	v, ok := d.vdcs[vdc.Vdc.Name]
+	if !ok {
+		return nil, nil
+	}
+	var records []*types.QueryResultVAppRecordType
+	for _, a := range v.vapps {
+		records = append(records, &types.QueryResultVAppRecordType{
+			Name:    a.vapp.VApp.Name,
+			HREF:    a.vapp.VApp.HREF,
+			VdcName: vdc.Vdc.Name,
+			VdcHREF: vdc.Vdc.HREF,
+		})
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Name < records[j].Name })
+	return records, nil
+}
+
+func (d *Driver) ListVMs(vdc *govcd.Vdc) ([]*types.QueryResultVMRecordType, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("ListVMs"); err != nil {
+		return nil, err
+	}
+	v, ok := d.vdcs[vdc.Vdc.Name]
+	if !ok {
+		return nil, nil
+	}
+	var records []*types.QueryResultVMRecordType
+	for _, a := range v.vapps {
+		for _, vm := range a.vms {
+			records = append(records, &types.QueryResultVMRecordType{
+				Name:          vm.Name,
+				ID:            vm.vm.VM.ID,
+				ContainerName: a.vapp.VApp.Name,
+				ContainerID:   a.vapp.VApp.ID,
+				VdcName:       vdc.Vdc.Name,
+				VdcHREF:       vdc.Vdc.HREF,
+			})
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].ContainerName != records[j].ContainerName {
+			return records[i].ContainerName < records[j].ContainerName
+		}
+		return records[i].Name < records[j].Name
+	})
+	return records, nil
+}
+
+func (d *Driver) CreateVApp(ctx context.Context, vdc *govcd.Vdc, name, description, networkName string) (*govcd.VApp, error) {
+	d.mu.Lock()
+	err := d.call("CreateVApp")
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.Tasks.Run(ctx, "CreateVApp", name); err != nil {
+		return nil, fmt.Errorf("error creating vApp %s: %w", name, err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	v, ok := d.vdcs[vdc.Vdc.Name]
+	if !ok {
+		return nil, fmt.Errorf("error getting VDC %s: %w", vdc.Vdc.Name, govcd.ErrorEntityNotFound)
+	}
+	if _, exists := v.vapps[name]; exists {
+		return nil, fmt.Errorf("error creating vApp %s: a vApp with that name already exists", name)
+	}
+	if networkName != "" {
+		if _, ok := v.networks[networkName]; !ok {
+			return nil, fmt.Errorf("error getting network %s: %w", networkName, govcd.ErrorEntityNotFound)
+		}
+	}
+	a := newFakeVApp(name, description)
+	v.vapps[name] = a
+	return a.vapp, nil
+}
+
+func (d *Driver) ForceDeleteVApp(ctx context.Context, vapp *govcd.VApp, report func(string)) error {
+	d.mu.Lock()
+	err := d.call("ForceDeleteVApp")
+	d.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	name := vapp.VApp.Name
+	if report != nil {
+		report(fmt.Sprintf("Deleting vApp %s...", name))
+	}
+	if err := d.Tasks.Run(ctx, "ForceDeleteVApp", name); err != nil {
+		return fmt.Errorf("error deleting vApp %s: %w", name, err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, v := range d.vdcs {
+		for vappName, a := range v.vapps {
+			if a.vapp.VApp.ID == vapp.VApp.ID {
+				delete(v.vapps, vappName)
+			}
+		}
+	}
+	return nil
+}
+
+// --- Network Operations ---
+
+func (d *Driver) FindAvailableIP(vdc *govcd.Vdc, networkName string) (*driver.NetworkInfo, error) {
+	return d.FindAvailableIPExcluding(vdc, networkName, nil)
+}
+
+// FindAvailableIPExcluding hands out the first address of the network's IP
+// ranges that is neither the gateway, excluded, nor handed out before.
+func (d *Driver) FindAvailableIPExcluding(vdc *govcd.Vdc, networkName string, excludeIPs []string) (*driver.NetworkInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("FindAvailableIP"); err != nil {
+		return nil, err
+	}
+
+	v, network, err := d.network(vdc, networkName)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := map[string]bool{network.Gateway: true}
+	for _, ip := range excludeIPs {
+		excluded[ip] = true
+	}
+	for _, r := range network.IPRanges {
+		start, err := netip.ParseAddr(r.Start)
+		if err != nil {
+			return nil, fmt.Errorf("network %s: invalid IP range start %q: %w", networkName, r.Start, err)
+		}
+		end, err := netip.ParseAddr(r.End)
+		if err != nil {
+			return nil, fmt.Errorf("network %s: invalid IP range end %q: %w", networkName, r.End, err)
+		}
+		for ip := start; ip.IsValid() && ip.Compare(end) <= 0; ip = ip.Next() {
+			candidate := ip.String()
+			if excluded[candidate] || v.allocatedIPs[candidate] {
+				continue
+			}
+			v.allocatedIPs[candidate] = true
+			info := *network
+			info.AvailableIP = candidate
+			return &info, nil
+		}
+	}
+	return nil, fmt.Errorf("no available IP addresses in network %s", networkName)
+}
+
+func (d *Driver) GetNetworkInfo(vdc *govcd.Vdc, networkName string) (*driver.NetworkInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("GetNetworkInfo"); err != nil {
+		return nil, err
+	}
+	_, network, err := d.network(vdc, networkName)
+	if err != nil {
+		return nil, err
+	}
+	info := *network
+	return &info, nil
+}
+
+// network must be called with the lock held.
+func (d *Driver) network(vdc *govcd.Vdc, networkName string) (*fakeVdc, *driver.NetworkInfo, error) {
+	v, ok := d.vdcs[vdc.Vdc.Name]
+	if !ok {
+		return nil, nil, fmt.Errorf("error getting VDC %s: %w", vdc.Vdc.Name, govcd.ErrorEntityNotFound)
+	}
+	network, ok := v.networks[networkName]
+	if !ok {
+		return nil, nil, fmt.Errorf("error getting network %s: %w", networkName, govcd.ErrorEntityNotFound)
+	}
+	return v, network, nil
+}
+
+func (d *Driver) CreatePortForward(vdc *govcd.Vdc, edgeGatewayName string, spec driver.PortForwardSpec) (*driver.PortForward, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("CreatePortForward"); err != nil {
+		return nil, err
+	}
+	for _, pf := range d.portForwards {
+		if pf.Spec.ExternalIP == spec.ExternalIP && pf.Spec.ExternalPort == spec.ExternalPort &&
+			strings.EqualFold(pf.Spec.Protocol, spec.Protocol) {
+			return nil, fmt.Errorf("error creating DNAT rule %s: port %d already forwarded", spec.Name, spec.ExternalPort)
+		}
+	}
+	pf := &driver.PortForward{Spec: spec}
+	d.portForwards = append(d.portForwards, pf)
+	return pf, nil
+}
+
+func (d *Driver) DeletePortForward(pf *driver.PortForward) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("DeletePortForward"); err != nil {
+		return err
+	}
+	for i, existing := range d.portForwards {
+		if existing == pf {
+			d.portForwards = append(d.portForwards[:i], d.portForwards[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// --- Catalog Operations ---
+
+func (d *Driver) GetCatalog(name string) (*govcd.Catalog, error) {
+	return d.getOrgCatalog("GetCatalog", d.OrgName, name)
+}
+
+func (d *Driver) GetOrgCatalog(orgName, catalogName string) (*govcd.Catalog, error) {
+	return d.getOrgCatalog("GetOrgCatalog", orgName, catalogName)
+}
+
+func (d *Driver) getOrgCatalog(method, orgName, name string) (*govcd.Catalog, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call(method); err != nil {
+		return nil, err
+	}
+	c, ok := d.catalogs[orgName+"/"+name]
+	if !ok {
+		return nil, fmt.Errorf("error getting catalog %s: %w", name, govcd.ErrorEntityNotFound)
+	}
+	return c.catalog, nil
+}
+
+// catalog returns the fake behind a catalog returned by the driver. It must
+// be called with the lock held.
+func (d *Driver) catalog(catalog *govcd.Catalog) (*fakeCatalog, error) {
+	for _, c := range d.catalogs {
+		if c.catalog.Catalog.ID == catalog.Catalog.ID {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("error getting catalog %s: %w", catalog.Catalog.Name, govcd.ErrorEntityNotFound)
+}
+
+func (d *Driver) ListCatalogs() ([]*types.CatalogRecord, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("ListCatalogs"); err != nil {
+		return nil, err
+	}
+	var records []*types.CatalogRecord
+	for _, c := range d.catalogs {
+		if c.org != d.OrgName {
+			continue
+		}
+		records = append(records, &types.CatalogRecord{
+			Name:                  c.catalog.Catalog.Name,
+			ID:                    c.catalog.Catalog.ID,
+			HREF:                  c.catalog.Catalog.HREF,
+			Description:           c.catalog.Catalog.Description,
+			OrgName:               c.org,
+			IsPublished:           c.published,
+			IsShared:              len(c.sharedWith) > 0,
+			NumberOfMedia:         int64(len(c.media)),
+			NumberOfVAppTemplates: int64(len(c.templates)),
+		})
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Name < records[j].Name })
+	return records, nil
+}
+
+func (d *Driver) CreateCatalogWithStorageProfile(ctx context.Context, name, description string, storageProfileRef *types.Reference) (*govcd.AdminCatalog, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("CreateCatalogWithStorageProfile"); err != nil {
+		return nil, err
+	}
+	if _, exists := d.catalogs[d.OrgName+"/"+name]; exists {
+		return nil, fmt.Errorf("error creating catalog %s: a catalog with that name already exists", name)
+	}
+
+	c := d.addCatalog(d.OrgName, name, description)
+	admin := govcd.NewAdminCatalog(nil)
+	admin.AdminCatalog = &types.AdminCatalog{Catalog: *c.catalog.Catalog}
+	return admin, nil
+}
+
+func (d *Driver) DeleteCatalog(ctx context.Context, catalog *govcd.AdminCatalog) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("DeleteCatalog"); err != nil {
+		return err
+	}
+	for key, c := range d.catalogs {
+		if c.catalog.Catalog.ID == catalog.AdminCatalog.ID {
+			delete(d.catalogs, key)
+		}
+	}
+	return nil
+}
+
+// UploadMediaImage adds an unresolved media to the catalog, then resolves
+// it once the file, which must exist, has been "uploaded" by an
+// UploadMediaImage task. A failed task leaves the media unresolved and
+// returns a *MediaUploadError, as VCDDriver does.
+func (d *Driver) UploadMediaImage(ctx context.Context, catalog *govcd.Catalog, name, description, filePath string) (*govcd.Media, error) {
+	d.mu.Lock()
+	err := d.call("UploadMediaImage")
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(filePath); err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", filePath, err)
+	}
+
+	d.mu.Lock()
+	c, err := d.catalog(catalog)
+	if err != nil {
+		d.mu.Unlock()
+		return nil, err
+	}
+	for _, m := range c.media {
+		if m.media.Media.Name == name {
+			d.mu.Unlock()
+			return nil, fmt.Errorf("%w: %s", driver.ErrMediaNameTaken, name)
+		}
+	}
+	m := newFakeMedia(name, description)
+	m.media.Media.Status = 0 // UNRESOLVED
+	c.media = append(c.media, m)
+	d.mu.Unlock()
+
+	return d.uploadMedia(ctx, m.media, filePath, 0)
+}
+
+// ResumeMediaUpload resolves a media left unresolved by a failed
+// UploadMediaImage once an UploadMediaImage task succeeds.
+func (d *Driver) ResumeMediaUpload(ctx context.Context, catalog *govcd.Catalog, media *govcd.Media, filePath string, offset int64) (*govcd.Media, error) {
+	m, err := d.getMediaById("ResumeMediaUpload", catalog, media.Media.ID)
+	if err != nil {
+		return nil, err
+	}
+	return d.uploadMedia(ctx, m, filePath, offset)
+}
+
+func (d *Driver) uploadMedia(ctx context.Context, media *govcd.Media, filePath string, offset int64) (*govcd.Media, error) {
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", filePath, err)
+	}
+	if err := d.Tasks.Run(ctx, "UploadMediaImage", media.Media.Name); err != nil {
+		return nil, &driver.MediaUploadError{Media: media, Uploaded: offset, Err: err}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	media.Media.Size = fi.Size()
+	media.Media.Status = 1 // RESOLVED
+	return media, nil
+}
+
+// UploadFloppyImage adds a floppy media to the catalog, as UploadMediaImage
+// does for ISOs.
+func (d *Driver) UploadFloppyImage(ctx context.Context, catalog *govcd.Catalog, name, description, filePath string) (*govcd.Media, error) {
+	d.mu.Lock()
+	err := d.call("UploadFloppyImage")
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening floppy image: %w", err)
+	}
+	if err := d.Tasks.Run(ctx, "UploadFloppyImage", name); err != nil {
+		return nil, fmt.Errorf("error uploading floppy media %s: %w", name, err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c, err := d.catalog(catalog)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range c.media {
+		if m.media.Media.Name == name {
+			return nil, fmt.Errorf("%w: %s", driver.ErrMediaNameTaken, name)
+		}
+	}
+	m := newFakeMedia(name, description)
+	m.media.Media.ImageType = "floppy"
+	m.media.Media.Size = fi.Size()
+	c.media = append(c.media, m)
+	return m.media, nil
+}
+
+func (d *Driver) WaitForMediaResolved(ctx context.Context, catalog *govcd.Catalog, mediaID string, timeout time.Duration, report func(status string)) (*govcd.Media, error) {
+	media, err := d.getMediaById("WaitForMediaResolved", catalog, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	// The upload being waited for completes
+	d.mu.Lock()
+	media.Media.Status = 1
+	d.mu.Unlock()
+	if report != nil {
+		report("RESOLVED")
+	}
+	return media, nil
+}
+
+func (d *Driver) GetMediaById(catalog *govcd.Catalog, id string) (*govcd.Media, error) {
+	return d.getMediaById("GetMediaById", catalog, id)
+}
+
+func (d *Driver) getMediaById(method string, catalog *govcd.Catalog, id string) (*govcd.Media, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call(method); err != nil {
+		return nil, err
+	}
+	c, err := d.catalog(catalog)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range c.media {
+		if m.media.Media.ID == id {
+			return m.media, nil
+		}
+	}
+	return nil, fmt.Errorf("error getting media %s: %w", id, govcd.ErrorEntityNotFound)
+}
+
+func (d *Driver) GetCatalogItemById(catalog *govcd.Catalog, id string) (*govcd.CatalogItem, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("GetCatalogItemById"); err != nil {
+		return nil, err
+	}
+	c, err := d.catalog(catalog)
+	if err != nil {
+		return nil, err
+	}
+
+	item := govcd.NewCatalogItem(nil)
+	for _, m := range c.media {
+		if m.media.Media.ID == id {
+			item.CatalogItem = &types.CatalogItem{ID: id, Name: m.media.Media.Name, Size: m.media.Media.Size}
+			return item, nil
+		}
+	}
+	for _, t := range c.templates {
+		if t.ID == id {
+			item.CatalogItem = &types.CatalogItem{ID: id, Name: t.Name, Size: t.SizeBytes}
+			return item, nil
+		}
+	}
+	return nil, fmt.Errorf("error getting catalog item %s: %w", id, govcd.ErrorEntityNotFound)
+}
+
+func (d *Driver) SetMediaChecksum(media *govcd.Media, checksum string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("SetMediaChecksum"); err != nil {
+		return err
+	}
+	for _, c := range d.catalogs {
+		for _, m := range c.media {
+			if m.media.Media.ID == media.Media.ID {
+				m.checksum = checksum
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("error tagging media %s: %w", media.Media.Name, govcd.ErrorEntityNotFound)
+}
+
+func (d *Driver) FindMediaByChecksum(checksum string) (*govcd.Media, string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("FindMediaByChecksum"); err != nil {
+		return nil, "", err
+	}
+
+	keys := make([]string, 0, len(d.catalogs))
+	for key := range d.catalogs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		c := d.catalogs[key]
+		if c.org != d.OrgName {
+			continue
+		}
+		for _, m := range c.media {
+			if m.checksum == checksum {
+				return m.media, c.catalog.Catalog.Name, nil
+			}
+		}
+	}
+	return nil, "", nil
+}
+
+func (d *Driver) ShareCatalog(catalog *govcd.Catalog, orgNames []string, accessLevel string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("ShareCatalog"); err != nil {
+		return err
+	}
+	c, err := d.catalog(catalog)
+	if err != nil {
+		return err
+	}
+	c.sharedWith = append([]string(nil), orgNames...)
+	c.accessLevel = accessLevel
+	return nil
+}
+
+func (d *Driver) PublishCatalog(catalog *govcd.Catalog) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("PublishCatalog"); err != nil {
+		return err
+	}
+	c, err := d.catalog(catalog)
+	if err != nil {
+		return err
+	}
+	c.published = true
+	return nil
+}
+
+// --- Template Operations ---
+
+func (d *Driver) ListVAppTemplates(catalog string, filter driver.VAppTemplateFilter) ([]*driver.VAppTemplateInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("ListVAppTemplates"); err != nil {
+		return nil, err
+	}
+
+	var templates []*driver.VAppTemplateInfo
+	for _, c := range d.catalogs {
+		if c.org != d.OrgName || (catalog != "" && c.catalog.Catalog.Name != catalog) {
+			continue
+		}
+		for _, t := range c.templates {
+			if filter.Name != "" {
+				// The query service '*' wildcard, as a glob
+				pattern := strings.NewReplacer("?", `\?`, "[", `\[`).Replace(filter.Name)
+				if ok, _ := path.Match(pattern, t.Name); !ok {
+					continue
+				}
+			}
+			if !filter.CreatedAfter.IsZero() && t.Created.Before(filter.CreatedAfter) {
+				continue
+			}
+			if !filter.CreatedBefore.IsZero() && !t.Created.Before(filter.CreatedBefore) {
+				continue
+			}
+			info := *t
+			templates = append(templates, &info)
+		}
+	}
+
+	sort.SliceStable(templates, func(i, j int) bool {
+		return templates[i].Created.After(templates[j].Created)
+	})
+	return templates, nil
+}
+
+func (d *Driver) UploadOvf(ctx context.Context, catalog *govcd.Catalog, name, description, filePath string) (*govcd.VAppTemplate, error) {
+	d.mu.Lock()
+	err := d.call("UploadOvf")
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(filePath); err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", filePath, err)
+	}
+	if err := d.Tasks.Run(ctx, "UploadOvf", name); err != nil {
+		return nil, fmt.Errorf("error uploading OVF %s: %w", name, err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c, err := d.catalog(catalog)
+	if err != nil {
+		return nil, err
+	}
+	info := c.addTemplate(driver.VAppTemplateInfo{Name: name, Description: description})
+
+	template := govcd.NewVAppTemplate(nil)
+	template.VAppTemplate = &types.VAppTemplate{
+		Name:        info.Name,
+		ID:          info.ID,
+		Description: info.Description,
+	}
+	return template, nil
+}
+
+func (d *Driver) MakeTemplatePoliciesNonFinal(template *govcd.VAppTemplate) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.call("MakeTemplatePoliciesNonFinal")
+}
+
+func (d *Driver) CopyVAppTemplate(ctx context.Context, source *govcd.Catalog, name string, target *govcd.Catalog, targetName, description string) error {
+	d.mu.Lock()
+	err := d.call("CopyVAppTemplate")
+	d.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := d.Tasks.Run(ctx, "CopyVAppTemplate", name); err != nil {
+		return fmt.Errorf("error copying vApp template %s: %w", name, err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	from, err := d.catalog(source)
+	if err != nil {
+		return err
+	}
+	to, err := d.catalog(target)
+	if err != nil {
+		return err
+	}
+	for _, t := range from.templates {
+		if t.Name == name {
+			to.addTemplate(driver.VAppTemplateInfo{Name: targetName, Description: description, SizeBytes: t.SizeBytes})
+			return nil
+		}
+	}
+	return fmt.Errorf("error getting vApp template %s: %w", name, govcd.ErrorEntityNotFound)
+}
+
+func (d *Driver) EnableVAppTemplateDownload(ctx context.Context, template *govcd.VAppTemplate) (string, error) {
+	d.mu.Lock()
+	err := d.call("EnableVAppTemplateDownload")
+	d.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	if err := d.Tasks.Run(ctx, "EnableVAppTemplateDownload", template.VAppTemplate.Name); err != nil {
+		return "", fmt.Errorf("error enabling download of vApp template %s: %w", template.VAppTemplate.Name, err)
+	}
+	return "https://vcd.example.com/transfer/" + template.VAppTemplate.ID + "/descriptor.ovf", nil
+}
+
+func (d *Driver) DisableVAppTemplateDownload(template *govcd.VAppTemplate) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.call("DisableVAppTemplateDownload")
+}
+
+func (d *Driver) DownloadFile(ctx context.Context, fileURL string, w io.Writer, size int64) (int64, error) {
+	d.mu.Lock()
+	err := d.call("DownloadFile")
+	content, ok := d.downloads[fileURL]
+	d.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("download failed with status 404 Not Found: %s", fileURL)
+	}
+	n, err := w.Write(content)
+	return int64(n), err
+}
+
+// --- Console Operations ---
+
+func (d *Driver) CaptureScreenshot(ctx context.Context, vm driver.VirtualMachine) (image.Image, error) {
+	d.mu.Lock()
+	err := d.call("CaptureScreenshot")
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if fake, ok := vm.(*VM); ok {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		if fake.Status != "POWERED_ON" {
+			return nil, fmt.Errorf("error acquiring console ticket: VM %s is not powered on", fake.Name)
+		}
+		if fake.Screen != nil {
+			return fake.Screen, nil
+		}
+	}
+	screen := image.NewRGBA(image.Rect(0, 0, 1024, 768))
+	draw.Draw(screen, screen.Bounds(), image.Black, image.Point{}, draw.Src)
+	return screen, nil
+}
+
+// --- Transfer Operations ---
+
+func (d *Driver) AcquireTransferSlot(ctx context.Context, waiting func()) (func(), error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("AcquireTransferSlot"); err != nil {
+		return nil, err
+	}
+	return func() {}, nil
+}
+
+// --- Lifecycle ---
+
+func (d *Driver) Cleanup() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.call("Cleanup")
+}
+
+// GetClient returns nil: the fake has no API client.
+func (d *Driver) GetClient() *govcd.VCDClient {
+	return nil
+}
+
+func (d *Driver) Proxy() driver.ProxyFunc {
+	return http.ProxyFromEnvironment
+}
+
+func (d *Driver) ConsoleProxy() driver.ProxyFunc {
+	return http.ProxyFromEnvironment
+}
+
+func (d *Driver) TLSConfig() *tls.Config {
+	return &tls.Config{}
+}
+
+func (d *Driver) APIVersion() string {
+	return d.Version
+}
+
+func (d *Driver) SupportsAPIVersion(minVersion string) bool {
+	have := strings.SplitN(d.Version, ".", 2)
+	want := strings.SplitN(minVersion, ".", 2)
+	for i := 0; i < 2; i++ {
+		var h, w int
+		if i < len(have) {
+			h, _ = strconv.Atoi(have[i])
+		}
+		if i < len(want) {
+			w, _ = strconv.Atoi(want[i])
+		}
+		if h != w {
+			return h > w
+		}
+	}
+	return true
+}
+
+// --- Diagnostics ---
+
+func (d *Driver) ExplainLimitError(err error, vdc *govcd.Vdc) error {
+	return err
+}
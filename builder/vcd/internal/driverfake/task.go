@@ -0,0 +1,96 @@
+package driverfake
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Task is a task run by TaskEngine.
+type Task struct {
+	Operation string // driver method, e.g. "PowerOn"
+	Target    string // name of the object the task runs on
+	Status    string // success, error or aborted, as in VCD
+	Err       error
+}
+
+// TaskEngine stands in for the VCD task service behind Driver and
+// VM: every long running operation becomes a task that completes after
+// Delay, or fails with an error injected through Fail. Tasks are recorded
+// so tests can assert on what a step asked VCD to do.
+type TaskEngine struct {
+	// Delay is how long each task runs. Zero completes tasks immediately.
+	Delay time.Duration
+
+	mu       sync.Mutex
+	failures map[string][]error
+	tasks    []Task
+}
+
+// Fail makes the next task of the operation fail with err. Calling it
+// several times queues errors for the following tasks.
+func (e *TaskEngine) Fail(operation string, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.failures == nil {
+		e.failures = make(map[string][]error)
+	}
+	e.failures[operation] = append(e.failures[operation], err)
+}
+
+// Run runs a task of the operation on target and waits for it, like
+// WaitTask, honoring the cancellation of ctx.
+func (e *TaskEngine) Run(ctx context.Context, operation, target string) error {
+	e.mu.Lock()
+	var err error
+	if queued := e.failures[operation]; len(queued) > 0 {
+		err = queued[0]
+		e.failures[operation] = queued[1:]
+	}
+	delay := e.Delay
+	e.mu.Unlock()
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	task := Task{Operation: operation, Target: target, Status: "success"}
+	select {
+	case <-ctx.Done():
+		task.Status = "aborted"
+		task.Err = fmt.Errorf("task %s on %s aborted: %w", operation, target, ctx.Err())
+	case <-timer.C:
+		if err != nil {
+			task.Status = "error"
+			task.Err = fmt.Errorf("task %s on %s failed: %w", operation, target, err)
+		}
+	}
+
+	e.mu.Lock()
+	e.tasks = append(e.tasks, task)
+	e.mu.Unlock()
+	return task.Err
+}
+
+// Tasks returns the tasks run so far, oldest first.
+func (e *TaskEngine) Tasks() []Task {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Task(nil), e.tasks...)
+}
+
+// Count returns how many tasks of the operation have run.
+func (e *TaskEngine) Count(operation string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	n := 0
+	for _, t := range e.tasks {
+		if t.Operation == operation {
+			n++
+		}
+	}
+	return n
+}
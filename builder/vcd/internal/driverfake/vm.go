@@ -0,0 +1,458 @@
+package driverfake
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+	"github.com/vmware/go-vcloud-director/v3/types/v56"
+)
+
+// fakePollInterval is how often a VM re-checks the state it waits for.
+const fakePollInterval = 10 * time.Millisecond
+
+// VM is an in-memory driver.VirtualMachine for unit testing steps. Power and
+// reconfiguration calls run as tasks on the TaskEngine shared with its
+// Driver and update the exported fields, which tests set up and inspect.
+// Guest behaviour, such as a shutdown from inside the VM or an IP address
+// reported by the tools, is simulated by calling SetStatus and SetIP, which
+// are safe to call while a step is waiting.
+type VM struct {
+	Name string
+	// Status is a VCD power state, e.g. POWERED_OFF or POWERED_ON.
+	Status string
+	IP     string
+	NICs   []driver.NICInfo
+
+	// InsertedMedia is the media in the VM's CD-ROM, nil when empty.
+	InsertedMedia *govcd.Media
+	// FloppyDrive is whether the VM has a floppy drive, and InsertedFloppy
+	// the floppy media in it.
+	FloppyDrive    bool
+	InsertedFloppy *govcd.Media
+	// CDDrives are the drives added by AddCDDrive, by device ID, with the
+	// media mounted in them.
+	CDDrives       map[string]*govcd.Media
+	CPUCount       int
+	CoresPerSocket int
+	MemoryMB       int64
+	ExtraConfig    map[string]string
+	TPM            bool
+	BootDelayMs    int
+	EFISecureBoot  bool
+	TimeSync       bool
+	// GuestCustomization is the section set by SetGuestCustomization.
+	GuestCustomization *types.GuestCustomizationSection
+	// Metadata holds the entries written by MergeMetadata.
+	Metadata map[string]string
+	// ProductSection holds the OVF properties of the VM, by key.
+	ProductSection map[string]string
+	// Snapshot is the name of the snapshot of the VM, "" when it has none.
+	// Reverting to it sets Status back to SnapshotStatus.
+	Snapshot       string
+	SnapshotStatus string
+	// Screen is returned by Driver.CaptureScreenshot, a black 1024x768
+	// screen when nil.
+	Screen image.Image
+
+	// Errors makes the calls that do not run a task fail, keyed by method
+	// name, e.g. "GetIPAddress".
+	Errors map[string]error
+
+	mu    sync.Mutex
+	tasks *TaskEngine
+	vm    *govcd.VM
+}
+
+var _ driver.VirtualMachine = (*VM)(nil)
+
+// NewVM returns a powered off VM running its tasks on tasks.
+func NewVM(name string, tasks *TaskEngine) *VM {
+	vm := govcd.NewVM(nil)
+	vm.VM = &types.Vm{
+		Name: name,
+		ID:   "urn:vcloud:vm:" + fakeID(),
+	}
+	return &VM{
+		Name:        name,
+		Status:      "POWERED_OFF",
+		ExtraConfig: make(map[string]string),
+		Metadata:    make(map[string]string),
+		CDDrives:    make(map[string]*govcd.Media),
+		Errors:      make(map[string]error),
+		tasks:       tasks,
+		vm:          vm,
+	}
+}
+
+// SetStatus changes the power state, as the guest or another user would.
+func (v *VM) SetStatus(status string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.Status = status
+}
+
+// SetIP changes the IP address reported by the guest tools.
+func (v *VM) SetIP(ip string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.IP = ip
+}
+
+func (v *VM) err(method string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.Errors[method]
+}
+
+// run runs a task of the operation and applies change when it succeeds.
+func (v *VM) run(ctx context.Context, operation string, change func()) error {
+	if err := v.tasks.Run(ctx, operation, v.Name); err != nil {
+		return err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	change()
+	return nil
+}
+
+// --- Power Operations ---
+
+func (v *VM) PowerOn(ctx context.Context) error {
+	return v.run(ctx, "PowerOn", func() { v.Status = "POWERED_ON" })
+}
+
+func (v *VM) Deploy(ctx context.Context, forceCustomization bool) error {
+	return v.run(ctx, "Deploy", func() { v.Status = "POWERED_ON" })
+}
+
+func (v *VM) DeployVApp(ctx context.Context, forceCustomization bool) error {
+	return v.run(ctx, "DeployVApp", func() { v.Status = "POWERED_ON" })
+}
+
+func (v *VM) PowerOff(ctx context.Context) error {
+	return v.run(ctx, "PowerOff", func() { v.Status = "POWERED_OFF" })
+}
+
+func (v *VM) Shutdown(ctx context.Context) error {
+	return v.run(ctx, "Shutdown", func() { v.Status = "POWERED_OFF" })
+}
+
+// --- Status Operations ---
+
+func (v *VM) GetStatus() (string, error) {
+	if err := v.err("GetStatus"); err != nil {
+		return "", err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.Status, nil
+}
+
+func (v *VM) IsPoweredOn() (bool, error) {
+	status, err := v.GetStatus()
+	return status == "POWERED_ON", err
+}
+
+func (v *VM) IsPoweredOff() (bool, error) {
+	status, err := v.GetStatus()
+	return status == "POWERED_OFF", err
+}
+
+func (v *VM) WaitForPowerOff(ctx context.Context, timeout time.Duration) error {
+	return v.waitFor(ctx, timeout, "power off", func() bool { return v.Status == "POWERED_OFF" })
+}
+
+// waitFor polls done, called with the lock held, until it is true, the
+// timeout expires or ctx is cancelled.
+func (v *VM) waitFor(ctx context.Context, timeout time.Duration, what string, done func() bool) error {
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(fakePollInterval)
+	defer ticker.Stop()
+
+	for {
+		v.mu.Lock()
+		ok := done()
+		v.mu.Unlock()
+		if ok {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			return fmt.Errorf("timeout waiting for VM %s to %s", v.Name, what)
+		case <-ticker.C:
+		}
+	}
+}
+
+// --- Network Operations ---
+
+func (v *VM) GetIPAddress() (string, error) {
+	if err := v.err("GetIPAddress"); err != nil {
+		return "", err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.IP, nil
+}
+
+func (v *VM) GetNICs() ([]driver.NICInfo, error) {
+	if err := v.err("GetNICs"); err != nil {
+		return nil, err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return append([]driver.NICInfo(nil), v.NICs...), nil
+}
+
+func (v *VM) WaitForIP(ctx context.Context, timeout time.Duration) (string, error) {
+	if err := v.waitFor(ctx, timeout, "report an IP address", func() bool { return v.IP != "" }); err != nil {
+		return "", err
+	}
+	return v.GetIPAddress()
+}
+
+func (v *VM) ChangeIPAddress(newIP string) error {
+	return v.run(context.Background(), "ChangeIPAddress", func() { v.IP = newIP })
+}
+
+func (v *VM) RemoveNetworkAdapters() error {
+	return v.run(context.Background(), "RemoveNetworkAdapters", func() {
+		v.NICs = nil
+		v.IP = ""
+	})
+}
+
+// --- Media Operations ---
+
+func (v *VM) InsertMedia(ctx context.Context, media *govcd.Media, timeout time.Duration) error {
+	slot := &v.InsertedMedia
+	if media.Media.ImageType == "floppy" {
+		slot = &v.InsertedFloppy
+	}
+	v.mu.Lock()
+	busy := *slot != nil
+	noDrive := slot == &v.InsertedFloppy && !v.FloppyDrive
+	v.mu.Unlock()
+	if noDrive {
+		return fmt.Errorf("VM %s has no floppy drive", v.Name)
+	}
+	if busy {
+		return fmt.Errorf("VM %s already has media inserted", v.Name)
+	}
+	return v.run(ctx, "InsertMedia", func() { *slot = media })
+}
+
+func (v *VM) EjectMedia(media *govcd.Media) error {
+	return v.run(context.Background(), "EjectMedia", func() {
+		if media.Media.ImageType == "floppy" {
+			v.InsertedFloppy = nil
+		} else {
+			v.InsertedMedia = nil
+		}
+	})
+}
+
+func (v *VM) EjectAllMedia() ([]string, error) {
+	var ejected []string
+	err := v.run(context.Background(), "EjectAllMedia", func() {
+		for _, slot := range []**govcd.Media{&v.InsertedMedia, &v.InsertedFloppy} {
+			if *slot != nil {
+				ejected = append(ejected, (*slot).Media.Name)
+				*slot = nil
+			}
+		}
+		for deviceID, media := range v.CDDrives {
+			if media != nil {
+				ejected = append(ejected, media.Media.Name)
+				v.CDDrives[deviceID] = nil
+			}
+		}
+	})
+	return ejected, err
+}
+
+func (v *VM) HasFloppyDrive() (bool, error) {
+	if err := v.err("HasFloppyDrive"); err != nil {
+		return false, err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.FloppyDrive, nil
+}
+
+func (v *VM) AddCDDrive() (string, error) {
+	var deviceID string
+	err := v.run(context.Background(), "AddCDDrive", func() {
+		deviceID = fmt.Sprintf("%d", 3002+len(v.CDDrives))
+		v.CDDrives[deviceID] = nil
+	})
+	return deviceID, err
+}
+
+func (v *VM) SetDriveMedia(deviceID string, media *govcd.Media) error {
+	v.mu.Lock()
+	_, ok := v.CDDrives[deviceID]
+	v.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("VM %s has no media drive %s", v.Name, deviceID)
+	}
+	return v.run(context.Background(), "SetDriveMedia", func() { v.CDDrives[deviceID] = media })
+}
+
+func (v *VM) RemoveCDDrive(deviceID string) error {
+	v.mu.Lock()
+	_, ok := v.CDDrives[deviceID]
+	v.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("VM %s has no media drive %s", v.Name, deviceID)
+	}
+	return v.run(context.Background(), "RemoveCDDrive", func() { delete(v.CDDrives, deviceID) })
+}
+
+// --- Hardware Configuration ---
+
+func (v *VM) ChangeCPU(cpuCount, coresPerSocket int) error {
+	return v.run(context.Background(), "ChangeCPU", func() {
+		v.CPUCount = cpuCount
+		v.CoresPerSocket = coresPerSocket
+	})
+}
+
+func (v *VM) ChangeMemory(memoryMB int64) error {
+	return v.run(context.Background(), "ChangeMemory", func() { v.MemoryMB = memoryMB })
+}
+
+func (v *VM) GetExtraConfig() (map[string]string, error) {
+	if err := v.err("GetExtraConfig"); err != nil {
+		return nil, err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return maps.Clone(v.ExtraConfig), nil
+}
+
+func (v *VM) ChangeExtraConfig(entries map[string]string) error {
+	return v.run(context.Background(), "ChangeExtraConfig", func() {
+		for key, value := range entries {
+			v.ExtraConfig[key] = value
+		}
+	})
+}
+
+func (v *VM) RemoveExtraConfig(keys []string) error {
+	return v.run(context.Background(), "RemoveExtraConfig", func() {
+		for _, key := range keys {
+			delete(v.ExtraConfig, key)
+		}
+	})
+}
+
+func (v *VM) OVFProperties() (map[string]string, error) {
+	if err := v.err("OVFProperties"); err != nil {
+		return nil, err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return maps.Clone(v.ProductSection), nil
+}
+
+func (v *VM) SetOVFProperties(properties map[string]string) error {
+	return v.run(context.Background(), "SetOVFProperties", func() {
+		if v.ProductSection == nil {
+			v.ProductSection = make(map[string]string)
+		}
+		for key, value := range properties {
+			v.ProductSection[key] = value
+		}
+	})
+}
+
+func (v *VM) RemoveOVFProperties(keys []string) error {
+	return v.run(context.Background(), "RemoveOVFProperties", func() {
+		for _, key := range keys {
+			delete(v.ProductSection, key)
+		}
+	})
+}
+
+func (v *VM) SetTPM(ctx context.Context, enabled bool) error {
+	return v.run(ctx, "SetTPM", func() { v.TPM = enabled })
+}
+
+func (v *VM) SetBootOptions(bootDelayMs int, efiSecureBoot bool) error {
+	return v.run(context.Background(), "SetBootOptions", func() {
+		v.BootDelayMs = bootDelayMs
+		v.EFISecureBoot = efiSecureBoot
+	})
+}
+
+func (v *VM) SetTimeSync(enabled bool) error {
+	return v.run(context.Background(), "SetTimeSync", func() { v.TimeSync = enabled })
+}
+
+func (v *VM) SetGuestCustomization(section *types.GuestCustomizationSection) error {
+	return v.run(context.Background(), "SetGuestCustomization", func() { v.GuestCustomization = section })
+}
+
+func (v *VM) MergeMetadata(entries map[string]string) error {
+	return v.run(context.Background(), "MergeMetadata", func() {
+		for key, value := range entries {
+			v.Metadata[key] = value
+		}
+	})
+}
+
+// --- Snapshots ---
+
+func (v *VM) CreateSnapshot(ctx context.Context, name string, memory bool) error {
+	return v.run(ctx, "CreateSnapshot", func() {
+		v.Snapshot = name
+		v.SnapshotStatus = v.Status
+		if !memory {
+			v.SnapshotStatus = "POWERED_OFF"
+		}
+	})
+}
+
+func (v *VM) RevertToCurrentSnapshot(ctx context.Context) error {
+	v.mu.Lock()
+	hasSnapshot := v.Snapshot != ""
+	v.mu.Unlock()
+	if !hasSnapshot {
+		return fmt.Errorf("VM %s has no snapshot", v.Name)
+	}
+	return v.run(ctx, "RevertToCurrentSnapshot", func() { v.Status = v.SnapshotStatus })
+}
+
+func (v *VM) RemoveAllSnapshots(ctx context.Context) error {
+	return v.run(ctx, "RemoveAllSnapshots", func() {
+		v.Snapshot = ""
+		v.SnapshotStatus = ""
+	})
+}
+
+// --- Info ---
+
+func (v *VM) GetName() string {
+	return v.Name
+}
+
+// GetVM returns a govcd.VM carrying the name and ID of the fake. It has no
+// API client: steps calling its methods directly cannot run against the
+// fake until those calls move behind VirtualMachine.
+func (v *VM) GetVM() *govcd.VM {
+	return v.vm
+}
+
+func (v *VM) Refresh() error {
+	return v.err("Refresh")
+}
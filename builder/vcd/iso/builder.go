@@ -5,6 +5,7 @@ package iso
 
 import (
 	"context"
+	"strings"
 
 	"github.com/hashicorp/hcl/v2/hcldec"
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
@@ -32,7 +33,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 		return nil, warnings, errs
 	}
 
-	return nil, warnings, nil
+	return common.PortForwardGeneratedVars, warnings, nil
 }
 
 // Run executes the build process steps for the `Builder`, leveraging the provided context, UI, and lifecycle hook.
@@ -51,6 +52,12 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	ipAllocationMode := b.config.LocationConfig.IPAllocationMode
 	needsVMFirstForIP := ipAllocationMode == "POOL"
 
+	// Written onto the VM and onto the captured template
+	provenance := common.Provenance(&b.config.PackerConfig, map[string]string{
+		"source_iso_url":      strings.Join(b.config.ISOUrls, " "),
+		"source_iso_checksum": b.config.ISOChecksum,
+	})
+
 	var steps []multistep.Step
 
 	// Common initial steps
@@ -60,7 +67,17 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Config: &b.config.ConnectConfig,
 		},
 
-		// Step 2: Download ISO locally (using Packer SDK)
+		// Step 2: Check console access for the boot command
+		&common.StepCheckConsoleAccess{
+			Config: &b.config.BootCommandConfig,
+		},
+
+		// Step 3: Prune the modified ISO cache
+		&common.StepPruneISOCache{
+			Config: &b.config.ISOCacheConfig,
+		},
+
+		// Step 4: Download ISO locally (using Packer SDK)
 		&commonsteps.StepDownload{
 			Checksum:    b.config.ISOChecksum,
 			Description: "ISO",
@@ -70,66 +87,80 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Url:         b.config.ISOUrls,
 		},
 
-		// Step 3: Discover HTTP IP for preseed/kickstart server
+		// Step 5: Discover HTTP IP for preseed/kickstart server
 		&common.StepHTTPIPDiscover{
 			HTTPIP:        b.config.HTTPConfig.HTTPAddress,
 			HTTPInterface: b.config.HTTPConfig.HTTPInterface,
 			TargetHost:    b.config.ConnectConfig.Host,
 		},
 
-		// Step 4: Start HTTP server for preseed/kickstart files
+		// Step 6: Start HTTP server for preseed/kickstart files
 		commonsteps.HTTPServerFromHTTPConfig(&b.config.HTTPConfig),
+
+		// Create the floppy image (if floppy_files/floppy_dirs/floppy_content specified)
+		&commonsteps.StepCreateFloppy{
+			Files:       b.config.FloppyFiles,
+			Directories: b.config.FloppyDirectories,
+			Content:     b.config.FloppyContent,
+			Label:       b.config.FloppyLabel,
+		},
 	)
 
 	if needsVMFirstForIP {
 		// POOL mode: Create VM first so VCD assigns IP, then query it for templates
 		steps = append(steps,
-			// Step 5: Create temporary catalog
+			// Step 7: Create temporary catalog
 			&common.StepCreateTempCatalog{
 				Config:         &b.config.CatalogConfig,
 				VDCName:        b.config.LocationConfig.VDC,
-				StorageProfile: b.config.LocationConfig.StorageProfile,
+				StorageProfile: b.config.LocationConfig.ISOStorageProfile,
 			},
 
-			// Step 6: Resolve or create vApp
+			// Step 8: Resolve or create vApp
 			&common.StepResolveVApp{
-				VDCName:     b.config.LocationConfig.VDC,
-				VAppName:    b.config.LocationConfig.VApp,
-				NetworkName: b.config.LocationConfig.Network,
-				CreateVApp:  b.config.LocationConfig.CreateVApp,
+				VDCName:       b.config.LocationConfig.VDC,
+				VAppName:      b.config.LocationConfig.VApp,
+				Networks:      b.config.LocationConfig.Networks,
+				RandomNetwork: b.config.LocationConfig.NetworkSelection == "random",
+				CheckPool:     b.config.LocationConfig.IPAllocationMode == "POOL",
+				CreateVApp:    b.config.LocationConfig.CreateVApp,
 			},
 
-			// Step 7: Create VM with POOL allocation (VCD assigns IP)
+			// Step 9: Create VM with POOL allocation (VCD assigns IP)
 			&StepCreateVM{
 				VMName:           b.config.LocationConfig.VMName,
 				Description:      b.config.CreateConfig.Description,
-				StorageProfile:   b.config.LocationConfig.StorageProfile,
+				StorageProfile:   b.config.LocationConfig.VMStorageProfile,
 				Network:          b.config.LocationConfig.Network,
 				IPAllocationMode: ipAllocationMode,
 				GuestOSType:      b.config.CreateConfig.GuestOSType,
 				Firmware:         b.config.HardwareConfig.Firmware,
 				HardwareVersion:  b.config.HardwareConfig.HardwareVersion,
 				DiskSizeMB:       b.config.CreateConfig.DiskSizeMB,
+				TimeSyncWithHost: b.config.HardwareConfig.TimeSyncWithHost,
 			},
 
-			// Step 8: Configure hardware (CPU, memory)
-			&StepHardware{
+			// Step 10: Configure hardware (CPU, memory)
+			&common.StepHardware{
 				Config: &b.config.HardwareConfig,
 			},
 
-			// Step 9: Configure boot options (delay, EFI secure boot)
+			// Step 11: Configure boot options (delay, EFI secure boot)
 			// Must be before TPM - TPM requires EFI firmware
 			&common.StepConfigureBootOptions{
 				BootDelay: b.config.HardwareConfig.BootDelay,
 				Firmware:  b.config.HardwareConfig.Firmware,
 			},
 
-			// Step 10: Configure TPM (if enabled)
+			// Step 12: Configure TPM (if enabled)
 			&common.StepConfigureTPM{
 				Enabled: b.config.HardwareConfig.VTPMEnabled,
 			},
 
-			// Step 11: Query the IP that VCD assigned to the VM
+			// Step 13: Record NIC MAC addresses for templates
+			&common.StepDiscoverNICs{},
+
+			// Step 14: Query the IP that VCD assigned to the VM
 			&common.StepQueryVMIP{
 				VDCName:         b.config.LocationConfig.VDC,
 				NetworkName:     b.config.LocationConfig.Network,
@@ -137,105 +168,245 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 				OverrideDNS:     b.config.LocationConfig.VMDNS,
 			},
 
-			// Step 11: NOW modify ISO with the actual assigned IP
+			// Step 15: Insert the floppy image, or leave its files to the ISO
+			&common.StepAttachFloppy{
+				ResolveTimeout: b.config.MediaResolveTimeout,
+			},
+
+			// Step 16: Add a CD-ROM drive for cd_content/cd_files (if enabled)
+			&common.StepAddCDDrive{
+				Enabled:       b.config.CDSecondaryDrive,
+				Config:        &b.config.CDConfig,
+				BinaryContent: b.config.cdBinary,
+			},
+
+			// Step 17: NOW modify ISO with the actual assigned IP
 			&common.StepModifyISO{
-				Config: &b.config.CDConfig,
+				Config:         &b.config.CDConfig,
+				BinaryContent:  b.config.cdBinary,
+				BootWIMContent: b.config.BootWIMContent,
+				WorkDirectory:  b.config.WorkDirectory,
+				DebugRenderDir: b.config.DebugRenderDir,
+				Boot:           &b.config.ISOBootConfig,
+				Tool:           b.config.ISOBuilderTool,
+				Floppy:         &b.config.FloppyConfig,
+				VolumeLabel:    b.config.ISOTargetVolumeLabel,
+				Cache:          !b.config.DisableModifiedISOCache,
+				ISOChecksum:    b.config.ISOChecksum,
+				VMName:         b.config.LocationConfig.VMName,
+				Ctx:            b.config.ctx,
 			},
 
-			// Step 12: Upload modified ISO to catalog
+			// Step 18: Upload modified ISO to catalog
 			&common.StepUploadISO{
-				CacheISO:       false, // Don't cache modified ISOs
-				CacheOverwrite: false,
+				MediaName:         b.config.CatalogConfig.ISOMediaName,
+				CacheISO:          false, // Don't cache modified ISOs
+				CacheOverwrite:    false,
+				Search:            b.config.CatalogConfig.ISOSearch,
+				ISOChecksum:       b.config.ISOChecksum,
+				UploadWaitTimeout: b.config.ISOUploadWaitTimeout,
+				DeleteMedia:       b.config.CleanupISO,
+			},
+
+			// Step 19: Mount ISO to VM
+			&common.StepMountISO{
+				ResolveTimeout: b.config.MediaResolveTimeout,
 			},
 
-			// Step 13: Mount ISO to VM
-			&common.StepMountISO{},
+			// Step 20: Mount the cd_content/cd_files ISO in the added drive
+			&common.StepMountCD{
+				ResolveTimeout: b.config.MediaResolveTimeout,
+			},
 		)
 	} else {
 		// MANUAL/DHCP mode: We know the IP upfront (or don't need it for DHCP)
 		steps = append(steps,
-			// Step 5: Set IP in state for templates (MANUAL mode)
+			// Step 7: Set IP in state for templates (MANUAL mode)
 			&common.StepSetManualIP{
 				ManualIP:        b.config.LocationConfig.VMIPAddress,
 				OverrideGateway: b.config.LocationConfig.VMGateway,
 				OverrideDNS:     b.config.LocationConfig.VMDNS,
 			},
 
-			// Step 6: Modify ISO (if cd_content/cd_files specified)
-			&common.StepModifyISO{
-				Config: &b.config.CDConfig,
-			},
-
-			// Step 7: Create temporary catalog
+			// Step 8: Create temporary catalog
 			&common.StepCreateTempCatalog{
 				Config:         &b.config.CatalogConfig,
 				VDCName:        b.config.LocationConfig.VDC,
-				StorageProfile: b.config.LocationConfig.StorageProfile,
+				StorageProfile: b.config.LocationConfig.ISOStorageProfile,
+			},
+		)
+
+		isoSteps := []multistep.Step{
+			// Step 9: Modify ISO (if cd_content/cd_files specified)
+			&common.StepModifyISO{
+				Config:         &b.config.CDConfig,
+				BinaryContent:  b.config.cdBinary,
+				BootWIMContent: b.config.BootWIMContent,
+				WorkDirectory:  b.config.WorkDirectory,
+				DebugRenderDir: b.config.DebugRenderDir,
+				Boot:           &b.config.ISOBootConfig,
+				Tool:           b.config.ISOBuilderTool,
+				Floppy:         &b.config.FloppyConfig,
+				VolumeLabel:    b.config.ISOTargetVolumeLabel,
+				Cache:          !b.config.DisableModifiedISOCache,
+				ISOChecksum:    b.config.ISOChecksum,
+				VMName:         b.config.LocationConfig.VMName,
+				Ctx:            b.config.ctx,
 			},
 
-			// Step 8: Upload ISO to catalog
+			// Step 10: Upload ISO to catalog
 			&common.StepUploadISO{
-				CacheISO:       b.config.CatalogConfig.CacheISO,
-				CacheOverwrite: b.config.CatalogConfig.CacheOverwrite,
+				MediaName:         b.config.CatalogConfig.ISOMediaName,
+				CacheISO:          b.config.CatalogConfig.CacheISO,
+				CacheOverwrite:    b.config.CatalogConfig.CacheOverwrite,
+				Search:            b.config.CatalogConfig.ISOSearch,
+				ISOChecksum:       b.config.ISOChecksum,
+				UploadWaitTimeout: b.config.ISOUploadWaitTimeout,
+				DeleteMedia:       b.config.CleanupISO,
 			},
+		}
+
+		// The ISO is uploaded before the VM is created, unless floppy files
+		// may have to go on it, which depends on the VM having a floppy
+		// drive, or cd_content/cd_files may go on a drive added to the VM
+		hasFloppy := len(b.config.FloppyFiles) > 0 || len(b.config.FloppyDirectories) > 0 ||
+			len(b.config.FloppyContent) > 0
+		isoAfterVM := hasFloppy || b.config.CDSecondaryDrive
+		if !isoAfterVM {
+			steps = append(steps, isoSteps...)
+		}
 
-			// Step 9: Resolve or create vApp
+		steps = append(steps,
+			// Step 11: Resolve or create vApp
 			&common.StepResolveVApp{
-				VDCName:     b.config.LocationConfig.VDC,
-				VAppName:    b.config.LocationConfig.VApp,
-				NetworkName: b.config.LocationConfig.Network,
-				CreateVApp:  b.config.LocationConfig.CreateVApp,
+				VDCName:       b.config.LocationConfig.VDC,
+				VAppName:      b.config.LocationConfig.VApp,
+				Networks:      b.config.LocationConfig.Networks,
+				RandomNetwork: b.config.LocationConfig.NetworkSelection == "random",
+				CheckPool:     b.config.LocationConfig.IPAllocationMode == "POOL",
+				CreateVApp:    b.config.LocationConfig.CreateVApp,
 			},
 
-			// Step 10: Create VM
+			// Step 12: Create VM
 			&StepCreateVM{
 				VMName:           b.config.LocationConfig.VMName,
 				Description:      b.config.CreateConfig.Description,
-				StorageProfile:   b.config.LocationConfig.StorageProfile,
+				StorageProfile:   b.config.LocationConfig.VMStorageProfile,
 				Network:          b.config.LocationConfig.Network,
 				IPAllocationMode: ipAllocationMode,
 				GuestOSType:      b.config.CreateConfig.GuestOSType,
 				Firmware:         b.config.HardwareConfig.Firmware,
 				HardwareVersion:  b.config.HardwareConfig.HardwareVersion,
 				DiskSizeMB:       b.config.CreateConfig.DiskSizeMB,
+				TimeSyncWithHost: b.config.HardwareConfig.TimeSyncWithHost,
 			},
 
-			// Step 11: Configure hardware (CPU, memory)
-			&StepHardware{
+			// Step 13: Configure hardware (CPU, memory)
+			&common.StepHardware{
 				Config: &b.config.HardwareConfig,
 			},
 
-			// Step 12: Configure boot options (delay, EFI secure boot)
+			// Step 14: Configure boot options (delay, EFI secure boot)
 			// Must be before TPM - TPM requires EFI firmware
 			&common.StepConfigureBootOptions{
 				BootDelay: b.config.HardwareConfig.BootDelay,
 				Firmware:  b.config.HardwareConfig.Firmware,
 			},
 
-			// Step 13: Configure TPM (if enabled)
+			// Step 15: Configure TPM (if enabled)
 			&common.StepConfigureTPM{
 				Enabled: b.config.HardwareConfig.VTPMEnabled,
 			},
 
-			// Step 14: Mount ISO to VM
-			&common.StepMountISO{},
+			// Step 16: Record NIC MAC addresses for templates
+			&common.StepDiscoverNICs{},
+		)
+
+		if isoAfterVM {
+			steps = append(steps,
+				// Insert the floppy image, or leave its files to the ISO
+				&common.StepAttachFloppy{
+					ResolveTimeout: b.config.MediaResolveTimeout,
+				},
+
+				// Add a CD-ROM drive for cd_content/cd_files (if enabled)
+				&common.StepAddCDDrive{
+					Enabled:       b.config.CDSecondaryDrive,
+					Config:        &b.config.CDConfig,
+					BinaryContent: b.config.cdBinary,
+				},
+			)
+			steps = append(steps, isoSteps...)
+		}
+
+		steps = append(steps,
+			// Step 17: Mount ISO to VM
+			&common.StepMountISO{
+				ResolveTimeout: b.config.MediaResolveTimeout,
+			},
+
+			// Step 18: Mount the cd_content/cd_files ISO in the added drive
+			&common.StepMountCD{
+				ResolveTimeout: b.config.MediaResolveTimeout,
+			},
 		)
 	}
 
+	// The console recording goes next to the exported files, if any
+	recordingDir := ""
+	if b.config.Export != nil {
+		recordingDir = b.config.Export.OutputDir.OutputDir
+	}
+
 	// Common final steps for both flows
 	steps = append(steps,
+		// Write the provenance and metadata entries onto the VM
+		&common.StepMetadata{
+			Config:     &b.config.MetadataConfig,
+			Provenance: provenance,
+		},
+
+		// Hand boot parameters to the guest (if configured)
+		&common.StepGuestInfo{
+			Config: &b.config.GuestInfoConfig,
+			VMName: b.config.LocationConfig.VMName,
+			Ctx:    b.config.ctx,
+		},
+
+		// Hand parameters to the guest in the OVF environment (if configured)
+		&common.StepOVFProperties{
+			Config: &b.config.OVFPropertiesConfig,
+			VMName: b.config.LocationConfig.VMName,
+			Ctx:    b.config.ctx,
+		},
+
+		// Set the guest customization section (if configured)
+		&common.StepGuestCustomization{
+			Config: b.config.GuestCustomization,
+			VMName: b.config.LocationConfig.VMName,
+		},
+
 		// Power on VM (with IP conflict retry logic)
 		&common.StepRun{
 			Config:      &b.config.RunConfig,
+			SetOrder:    true,
 			VDCName:     b.config.LocationConfig.VDC,
 			NetworkName: b.config.LocationConfig.Network,
 		},
 
+		// Record the console until the end of the build (if enabled)
+		&common.StepConsoleRecording{
+			Config:    &b.config.ConsoleRecordingConfig,
+			VMName:    b.config.LocationConfig.VMName,
+			OutputDir: recordingDir,
+		},
+
 		// Boot command via WMKS console
 		&common.StepBootCommand{
-			Config: &b.config.BootCommandConfig,
-			VMName: b.config.LocationConfig.VMName,
-			Ctx:    b.config.ctx,
+			Config:        &b.config.BootCommandConfig,
+			VMName:        b.config.LocationConfig.VMName,
+			Ctx:           b.config.ctx,
+			SensitiveVars: b.config.PackerSensitiveVars,
 		},
 
 		// Wait for VM to get IP address (for communicator)
@@ -243,6 +414,23 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Config: &b.config.WaitIpConfig,
 		},
 
+		// Forward extra ports for provisioners (if configured)
+		&common.StepPortForward{
+			Config: &b.config.PortForwardConfig,
+		},
+
+		// Probe SSH/WinRM port (reports firewall vs service issues)
+		&common.StepProbeCommunicator{
+			Config: &b.config.Comm,
+		},
+
+		// Restart from disk without the ISO (if enabled)
+		&common.StepVerifyDiskBoot{
+			Config: &b.config.VerifyDiskBootConfig,
+			Comm:   &b.config.Comm,
+			VMName: b.config.LocationConfig.VMName,
+		},
+
 		// Connect to VM via SSH/WinRM
 		&communicator.StepConnect{
 			Config:    &b.config.Comm,
@@ -251,7 +439,10 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		},
 
 		// Run provisioners
-		&commonsteps.StepProvision{},
+		&common.StepProvision{
+			Config: &b.config.ProvisionSnapshotConfig,
+			Comm:   &b.config.Comm,
+		},
 
 		// Shutdown VM
 		&common.StepShutdown{
@@ -259,17 +450,45 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			CommType: b.config.Comm.Type,
 		},
 
+		// Keep the boot parameters out of the template
+		&common.StepRemoveGuestInfo{
+			Config: &b.config.GuestInfoConfig,
+		},
+
+		// Keep the OVF properties of the build out of the template
+		&common.StepRestoreOVFProperties{
+			Config: &b.config.OVFPropertiesConfig,
+		},
+
+		// Remove network adapters (if configured)
+		&common.StepRemoveNetworkAdapter{
+			Config: &b.config.RemoveNetworkAdapterConfig,
+		},
+
+		// Eject media before the capture (if exporting)
+		&common.StepEjectMedia{
+			Config: b.config.ExportToCatalog,
+		},
+
 		// Export to catalog (optional)
 		&common.StepExportToCatalog{
-			Config: b.config.ExportToCatalog,
+			Config:         b.config.ExportToCatalog,
+			StorageProfile: b.config.LocationConfig.CatalogStorageProfile,
+			Provenance:     provenance,
+			Metadata:       b.config.Metadata,
+		},
+
+		// Download the captured template (optional)
+		&common.StepExport{
+			Config: b.config.Export,
 		},
 	)
 
 	b.runner = commonsteps.NewRunnerWithPauseFn(steps, b.config.PackerConfig, ui, state)
-	b.runner.Run(ctx, state)
+	b.runner.Run(b.config.ConnectConfig.TaskContext(ctx, ui), state)
 
-	if rawErr, ok := state.GetOk("error"); ok {
-		return nil, rawErr.(error)
+	if err := common.StateError(state); err != nil {
+		return nil, err
 	}
 
 	if _, ok := state.GetOk("vm"); !ok {
@@ -285,12 +504,20 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			"iso_path":     state.Get("iso_path"),
 			"catalog_name": state.Get("catalog_name"),
 			"vapp_name":    state.Get("vapp_name"),
+			// The checksum of the downloaded ISO, before any modification
+			"source_iso_checksum": b.config.ISOChecksum,
 		},
 	}
 
+	if transcript, ok := state.GetOk("boot_command_transcript"); ok {
+		artifact.StateData["boot_command_transcript"] = transcript
+	}
+
 	if b.config.Export != nil {
 		artifact.Outconfig = &b.config.Export.OutputDir.OutputDir
 	}
 
+	artifact.AddCapturedTemplate(state)
+
 	return artifact, nil
 }
@@ -7,6 +7,10 @@
 package iso
 
 import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
 	"github.com/juanfont/packer-plugin-vcd/builder/vcd/common"
 
 	packerCommon "github.com/hashicorp/packer-plugin-sdk/common"
@@ -21,22 +25,89 @@ type Config struct {
 	packerCommon.PackerConfig `mapstructure:",squash"`
 	commonsteps.HTTPConfig    `mapstructure:",squash"`
 	commonsteps.CDConfig      `mapstructure:",squash"`
+	commonsteps.FloppyConfig  `mapstructure:",squash"`
 
-	common.ConnectConfig      `mapstructure:",squash"`
-	common.CatalogConfig      `mapstructure:",squash"`
-	CreateConfig              `mapstructure:",squash"`
-	common.LocationConfig     `mapstructure:",squash"`
-	common.HardwareConfig     `mapstructure:",squash"`
-	commonsteps.ISOConfig     `mapstructure:",squash"`
-	common.BootCommandConfig  `mapstructure:",squash"`
+	common.ConnectConfig          `mapstructure:",squash"`
+	common.CatalogConfig          `mapstructure:",squash"`
+	common.ISOCacheConfig         `mapstructure:",squash"`
+	CreateConfig                  `mapstructure:",squash"`
+	common.LocationConfig         `mapstructure:",squash"`
+	common.HardwareConfig         `mapstructure:",squash"`
+	commonsteps.ISOConfig         `mapstructure:",squash"`
+	common.ISOBootConfig          `mapstructure:",squash"`
+	common.BootCommandConfig      `mapstructure:",squash"`
+	common.ConsoleRecordingConfig `mapstructure:",squash"`
+	common.GuestInfoConfig        `mapstructure:",squash"`
+	common.OVFPropertiesConfig    `mapstructure:",squash"`
 	// common.CDRomConfig                `mapstructure:",squash"` // we will probably need this
 	common.RemoveNetworkAdapterConfig `mapstructure:",squash"`
 	common.RunConfig                  `mapstructure:",squash"`
 	common.WaitIpConfig               `mapstructure:",squash"`
+	common.VerifyDiskBootConfig       `mapstructure:",squash"`
+	common.PortForwardConfig          `mapstructure:",squash"`
 	Comm                              communicator.Config `mapstructure:",squash"`
 
+	common.ProvisionSnapshotConfig `mapstructure:",squash"`
+
 	common.ShutdownConfig `mapstructure:",squash"`
 
+	common.MetadataConfig `mapstructure:",squash"`
+
+	// Directory for temporary files created while adding cd_content and
+	// cd_files to the ISO, and for the modified ISO itself when
+	// `disable_modified_iso_cache` is set. Free space is checked before the
+	// ISO is modified.
+	// Defaults to the system temporary directory.
+	WorkDirectory string `mapstructure:"work_directory"`
+	// Directory to save the rendered cd_content files to, for debugging
+	// template variables. A relative path is created inside
+	// `work_directory`. The files are only readable by the current user and
+	// are removed at the end of the build. Rendered files are not saved by
+	// default, as they often contain passwords and keys.
+	DebugRenderDir string `mapstructure:"debug_render_dir"`
+	// Tool used to add cd_content and cd_files to the ISO: `auto`, `native`,
+	// `append` or `xorriso`. Linux (ISO9660) ISOs are always modified with
+	// xorriso, which keeps their boot records exactly. Windows (UDF) ISOs are
+	// rebuilt natively by default, since xorriso only reads their ISO9660
+	// tree, which Microsoft ISOs leave empty but for a README. `append` adds
+	// the files to a copy of a UDF ISO instead of rebuilding it, keeping its
+	// boot records; the copy shares the blocks of the source on file systems
+	// with reflinks, such as btrfs and XFS, so only the appended files take
+	// space. Set `xorriso` for UDF ISOs whose ISO9660 tree holds every file,
+	// when the native rebuild does not boot; the modified ISO then has no UDF
+	// file system. Defaults to `auto`.
+	ISOBuilderTool string `mapstructure:"iso_builder_tool"`
+	// Volume label of the modified ISO, for installers that find their
+	// media by label, such as `inst.stage2=hd:LABEL=` in kickstart boot
+	// options. At most 32 characters. Defaults to the label of the source
+	// ISO.
+	ISOTargetVolumeLabel string `mapstructure:"iso_target_volume_label"`
+	// Put cd_content and cd_files on a small ISO of their own, mounted in a
+	// second CD-ROM drive added to the VM, instead of rebuilding the boot
+	// ISO with them. This skips the rebuild of large ISOs, but the
+	// installer has to look for its files on the second drive, as Windows
+	// Setup does for `autounattend.xml`. Falls back to rebuilding the ISO
+	// when the drive cannot be added. Defaults to `false`.
+	CDSecondaryDrive bool `mapstructure:"cd_secondary_drive"`
+	// Binary files to add to the ISO, such as driver cabinets and
+	// certificates, by path in the ISO, base64 encoded, e.g.
+	// `"drivers/viostor.cab" = filebase64("viostor.cab")`. Unlike
+	// cd_content, they are added as they are, without template rendering.
+	// A path cannot be in both.
+	CDContentBase64 map[string]string `mapstructure:"cd_content_base64"`
+	// Files to write into the Windows Setup image of `sources/boot.wim` on
+	// Windows ISOs, by path in the image, e.g. `autounattend.xml`. Windows
+	// Setup finds `autounattend.xml` at the root of that image however the
+	// ISO was booted, where some firmwares keep it from searching the ISO
+	// root. Rendered as templates like cd_content. wimlib
+	// (`wimlib-imagex`) must be installed.
+	BootWIMContent map[string]string `mapstructure:"boot_wim_content"`
+
+	// Guest customization of the VM, and of the templates captured from it.
+	// It is not set if [guest customization configuration](#guest-customization-configuration)
+	// is not specified.
+	GuestCustomization *common.GuestCustomizationConfig `mapstructure:"guest_customization"`
+
 	// The configuration for exporting the virtual machine to an OVF.
 	// The virtual machine is not exported if [export configuration](#export-configuration) is not specified.
 	Export *common.ExportConfig `mapstructure:"export"`
@@ -45,7 +116,8 @@ type Config struct {
 	// The virtual machine will not be exported if no [export to catalog configuration](#export-to-catalog-configuration) is specified.
 	ExportToCatalog *common.ExportToCatalogConfig `mapstructure:"export_to_catalog"`
 
-	ctx interpolate.Context
+	ctx      interpolate.Context
+	cdBinary map[string][]byte // decoded cd_content_base64
 }
 
 // Prepare processes and validates the configuration for building and exporting.
@@ -58,6 +130,12 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		InterpolateFilter: &interpolate.RenderFilter{
 			Exclude: []string{
 				"boot_command",
+				"guestinfo",
+				"ovf_properties",
+				"cd_content",
+				"efi_boot_content",
+				"boot_parameters",
+				"boot_wim_content",
 			},
 		},
 	}, raws...)
@@ -68,7 +146,12 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	warnings := make([]string, 0)
 	errs := new(packersdk.MultiError)
 
-	if c.ISOUrls != nil || c.RawSingleISOUrl != "" {
+	// An ISO staged in iso_catalog is used as it is, instead of a download
+	if c.ISOMediaName != "" {
+		if c.ISOUrls != nil || c.RawSingleISOUrl != "" {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'iso_url' and 'iso_media_name' are mutually exclusive"))
+		}
+	} else {
 		isoWarnings, isoErrs := c.ISOConfig.Prepare(&c.ctx)
 		warnings = append(warnings, isoWarnings...)
 		errs = packersdk.MultiErrorAppend(errs, isoErrs...)
@@ -76,26 +159,118 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 
 	errs = packersdk.MultiErrorAppend(errs, c.ConnectConfig.Prepare()...)
 	errs = packersdk.MultiErrorAppend(errs, c.CatalogConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.ISOCacheConfig.Prepare()...)
 	errs = packersdk.MultiErrorAppend(errs, c.CreateConfig.Prepare()...)
 	errs = packersdk.MultiErrorAppend(errs, c.LocationConfig.Prepare()...)
 	errs = packersdk.MultiErrorAppend(errs, c.HardwareConfig.Prepare()...)
+	if !c.HardwareConfig.IsSized() {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("must specify either 'vm_sizing_policy' or both 'CPUs' and 'memory'"))
+	}
 	errs = packersdk.MultiErrorAppend(errs, c.BootCommandConfig.Prepare(&c.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, c.ConsoleRecordingConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.GuestInfoConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.OVFPropertiesConfig.Prepare()...)
 	errs = packersdk.MultiErrorAppend(errs, c.HTTPConfig.Prepare(&c.ctx)...)
 	errs = packersdk.MultiErrorAppend(errs, c.CDConfig.Prepare(&c.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, c.FloppyConfig.Prepare(&c.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, c.ISOBootConfig.Prepare()...)
+	switch c.ISOBuilderTool {
+	case "":
+		c.ISOBuilderTool = common.ISOToolAuto
+	case common.ISOToolAuto, common.ISOToolNative, common.ISOToolAppend, common.ISOToolXorriso:
+	default:
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'iso_builder_tool' must be %q, %q, %q or %q",
+			common.ISOToolAuto, common.ISOToolNative, common.ISOToolAppend, common.ISOToolXorriso))
+	}
+	if c.ISOBuilderTool == common.ISOToolAppend && !c.ISOBootConfig.Overrides().IsZero() {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'iso_builder_tool' %q keeps the boot records of the ISO, which cannot be overridden", common.ISOToolAppend))
+	}
+	c.cdBinary = make(map[string][]byte, len(c.CDContentBase64))
+	for path, encoded := range c.CDContentBase64 {
+		if _, ok := c.CDContent[path]; ok {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("%s is in both 'cd_content' and 'cd_content_base64'", path))
+			continue
+		}
+		// Line breaks of wrapped base64 are ignored
+		data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+		if err != nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'cd_content_base64' %s is not valid base64: %w", path, err))
+			continue
+		}
+		c.cdBinary[path] = data
+	}
+	if c.ISOMediaName != "" {
+		modifiers := []struct {
+			name string
+			set  bool
+		}{
+			{"cd_content", len(c.CDContent) > 0},
+			{"cd_content_base64", len(c.CDContentBase64) > 0},
+			{"cd_files", len(c.CDFiles) > 0},
+			{"cd_secondary_drive", c.CDSecondaryDrive},
+			{"boot_parameters", c.BootParameters != ""},
+			{"boot_wim_content", len(c.BootWIMContent) > 0},
+			{"efi_boot_content", len(c.EFIBootContent) > 0},
+			{"iso_target_volume_label", c.ISOTargetVolumeLabel != ""},
+		}
+		for _, m := range modifiers {
+			if m.set {
+				errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'%s' modifies the ISO, and cannot be used with 'iso_media_name'", m.name))
+			}
+		}
+		if !c.ISOBootConfig.Overrides().IsZero() {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("the boot records of 'iso_media_name' cannot be overridden"))
+		}
+	}
+	if c.BootParameters != "" && c.CDSecondaryDrive {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'boot_parameters' modifies the boot ISO, and cannot be used with 'cd_secondary_drive'"))
+	}
+	if len(c.BootWIMContent) > 0 && c.CDSecondaryDrive {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'boot_wim_content' modifies the boot ISO, and cannot be used with 'cd_secondary_drive'"))
+	}
+	if len(c.ISOTargetVolumeLabel) > 32 {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'iso_target_volume_label' must be at most 32 characters"))
+	}
+	if len(c.EFIBootContent) > 0 {
+		if c.ISOBuilderTool == common.ISOToolNative || c.ISOBuilderTool == common.ISOToolAppend {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'efi_boot_content' needs the ISO to be modified with xorriso"))
+		}
+		if c.CDSecondaryDrive {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'efi_boot_content' modifies the boot ISO, and cannot be used with 'cd_secondary_drive'"))
+		}
+	}
+	errs = packersdk.MultiErrorAppend(errs, c.RunConfig.Prepare()...)
 	errs = packersdk.MultiErrorAppend(errs, c.WaitIpConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.VerifyDiskBootConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.PortForwardConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.MetadataConfig.Prepare()...)
 	errs = packersdk.MultiErrorAppend(errs, c.Comm.Prepare(&c.ctx)...)
+	errs = packersdk.MultiErrorAppend(errs, c.ProvisionSnapshotConfig.Prepare()...)
 
 	shutdownWarnings, shutdownErrs := c.ShutdownConfig.Prepare(c.Comm)
 	warnings = append(warnings, shutdownWarnings...)
 	errs = packersdk.MultiErrorAppend(errs, shutdownErrs...)
 
+	if c.GuestCustomization != nil {
+		errs = packersdk.MultiErrorAppend(errs, c.GuestCustomization.Prepare()...)
+	}
+
 	if c.Export != nil {
 		errs = packersdk.MultiErrorAppend(errs, c.Export.Prepare(&c.ctx, &c.LocationConfig, &c.PackerConfig)...)
+		// VCD only serves downloads of vApp templates
+		if c.ExportToCatalog == nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'export' downloads the template captured by 'export_to_catalog', which must be set"))
+		}
 	}
 	if c.ExportToCatalog != nil {
 		errs = packersdk.MultiErrorAppend(errs, c.ExportToCatalog.Prepare(&c.LocationConfig)...)
 	}
 
+	// Checked last, as it needs a valid connection and location
+	if len(errs.Errors) == 0 {
+		errs = packersdk.MultiErrorAppend(errs, c.LocationConfig.CheckRemote(&c.ConnectConfig, &c.HardwareConfig)...)
+	}
+
 	if len(errs.Errors) > 0 {
 		return warnings, errs
 	}
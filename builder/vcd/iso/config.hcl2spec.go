@@ -11,128 +11,206 @@ import (
 // FlatConfig is an auto-generated flat version of Config.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatConfig struct {
-	PackerBuildName           *string                           `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
-	PackerBuilderType         *string                           `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
-	PackerCoreVersion         *string                           `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
-	PackerDebug               *bool                             `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
-	PackerForce               *bool                             `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
-	PackerOnError             *string                           `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
-	PackerUserVars            map[string]string                 `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
-	PackerSensitiveVars       []string                          `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
-	HTTPDir                   *string                           `mapstructure:"http_directory" cty:"http_directory" hcl:"http_directory"`
-	HTTPContent               map[string]string                 `mapstructure:"http_content" cty:"http_content" hcl:"http_content"`
-	HTTPPortMin               *int                              `mapstructure:"http_port_min" cty:"http_port_min" hcl:"http_port_min"`
-	HTTPPortMax               *int                              `mapstructure:"http_port_max" cty:"http_port_max" hcl:"http_port_max"`
-	HTTPAddress               *string                           `mapstructure:"http_bind_address" cty:"http_bind_address" hcl:"http_bind_address"`
-	HTTPInterface             *string                           `mapstructure:"http_interface" undocumented:"true" cty:"http_interface" hcl:"http_interface"`
-	HTTPNetworkProtocol       *string                           `mapstructure:"http_network_protocol" cty:"http_network_protocol" hcl:"http_network_protocol"`
-	CDFiles                   []string                          `mapstructure:"cd_files" cty:"cd_files" hcl:"cd_files"`
-	CDContent                 map[string]string                 `mapstructure:"cd_content" cty:"cd_content" hcl:"cd_content"`
-	CDLabel                   *string                           `mapstructure:"cd_label" cty:"cd_label" hcl:"cd_label"`
-	Host                      *string                           `mapstructure:"host" cty:"host" hcl:"host"`
-	Org                       *string                           `mapstructure:"org" cty:"org" hcl:"org"`
-	Username                  *string                           `mapstructure:"username" cty:"username" hcl:"username"`
-	Password                  *string                           `mapstructure:"password" cty:"password" hcl:"password"`
-	Token                     *string                           `mapstructure:"token" cty:"token" hcl:"token"`
-	InsecureConnection        *bool                             `mapstructure:"insecure_connection" cty:"insecure_connection" hcl:"insecure_connection"`
-	ISOCatalog                *string                           `mapstructure:"iso_catalog" cty:"iso_catalog" hcl:"iso_catalog"`
-	TempCatalogPrefix         *string                           `mapstructure:"temp_catalog_prefix" cty:"temp_catalog_prefix" hcl:"temp_catalog_prefix"`
-	CacheISO                  *bool                             `mapstructure:"cache_iso" cty:"cache_iso" hcl:"cache_iso"`
-	CacheOverwrite            *bool                             `mapstructure:"cache_overwrite" cty:"cache_overwrite" hcl:"cache_overwrite"`
-	Version                   *string                           `mapstructure:"vm_version" cty:"vm_version" hcl:"vm_version"`
-	GuestOSType               *string                           `mapstructure:"guest_os_type" cty:"guest_os_type" hcl:"guest_os_type"`
-	Description               *string                           `mapstructure:"vm_description" cty:"vm_description" hcl:"vm_description"`
-	DiskSizeMB                *int64                            `mapstructure:"disk_size_mb" cty:"disk_size_mb" hcl:"disk_size_mb"`
-	VMName                    *string                           `mapstructure:"vm_name" cty:"vm_name" hcl:"vm_name"`
-	VApp                      *string                           `mapstructure:"vapp" cty:"vapp" hcl:"vapp"`
-	VDC                       *string                           `mapstructure:"vdc" cty:"vdc" hcl:"vdc"`
-	CreateVApp                *bool                             `mapstructure:"create_vapp" cty:"create_vapp" hcl:"create_vapp"`
-	Network                   *string                           `mapstructure:"network" cty:"network" hcl:"network"`
-	IPAllocationMode          *string                           `mapstructure:"ip_allocation_mode" cty:"ip_allocation_mode" hcl:"ip_allocation_mode"`
-	VMIPAddress               *string                           `mapstructure:"vm_ip" cty:"vm_ip" hcl:"vm_ip"`
-	VMGateway                 *string                           `mapstructure:"vm_gateway" cty:"vm_gateway" hcl:"vm_gateway"`
-	VMDNS                     *string                           `mapstructure:"vm_dns" cty:"vm_dns" hcl:"vm_dns"`
-	StorageProfile            *string                           `mapstructure:"storage_profile" cty:"storage_profile" hcl:"storage_profile"`
-	CPUs                      *int32                            `mapstructure:"CPUs" cty:"CPUs" hcl:"CPUs"`
-	CoresPerSocket            *int32                            `mapstructure:"cores_per_socket" cty:"cores_per_socket" hcl:"cores_per_socket"`
-	CpuHotAddEnabled          *bool                             `mapstructure:"CPU_hot_plug" cty:"CPU_hot_plug" hcl:"CPU_hot_plug"`
-	Memory                    *int64                            `mapstructure:"memory" cty:"memory" hcl:"memory"`
-	MemoryHotAddEnabled       *bool                             `mapstructure:"RAM_hot_plug" cty:"RAM_hot_plug" hcl:"RAM_hot_plug"`
-	NestedHV                  *bool                             `mapstructure:"NestedHV" cty:"NestedHV" hcl:"NestedHV"`
-	Firmware                  *string                           `mapstructure:"firmware" cty:"firmware" hcl:"firmware"`
-	HardwareVersion           *string                           `mapstructure:"hw_version" cty:"hw_version" hcl:"hw_version"`
-	ForceBIOSSetup            *bool                             `mapstructure:"force_bios_setup" cty:"force_bios_setup" hcl:"force_bios_setup"`
-	VTPMEnabled               *bool                             `mapstructure:"vTPM" cty:"vTPM" hcl:"vTPM"`
-	BootDelay                 *int                              `mapstructure:"boot_delay" cty:"boot_delay" hcl:"boot_delay"`
-	VMSizingPolicy            *string                           `mapstructure:"vm_sizing_policy" cty:"vm_sizing_policy" hcl:"vm_sizing_policy"`
-	ExtraConfig               map[string]string                 `mapstructure:"extra_config" cty:"extra_config" hcl:"extra_config"`
-	ISOChecksum               *string                           `mapstructure:"iso_checksum" required:"true" cty:"iso_checksum" hcl:"iso_checksum"`
-	RawSingleISOUrl           *string                           `mapstructure:"iso_url" required:"true" cty:"iso_url" hcl:"iso_url"`
-	ISOUrls                   []string                          `mapstructure:"iso_urls" cty:"iso_urls" hcl:"iso_urls"`
-	TargetPath                *string                           `mapstructure:"iso_target_path" cty:"iso_target_path" hcl:"iso_target_path"`
-	TargetExtension           *string                           `mapstructure:"iso_target_extension" cty:"iso_target_extension" hcl:"iso_target_extension"`
-	BootGroupInterval         *string                           `mapstructure:"boot_keygroup_interval" cty:"boot_keygroup_interval" hcl:"boot_keygroup_interval"`
-	BootWait                  *string                           `mapstructure:"boot_wait" cty:"boot_wait" hcl:"boot_wait"`
-	BootCommand               []string                          `mapstructure:"boot_command" cty:"boot_command" hcl:"boot_command"`
-	BootKeyInterval           *string                           `mapstructure:"boot_key_interval" cty:"boot_key_interval" hcl:"boot_key_interval"`
-	RemoveNetworkAdapter      *bool                             `mapstructure:"remove_network_adapter" cty:"remove_network_adapter" hcl:"remove_network_adapter"`
-	BootOrder                 *string                           `mapstructure:"boot_order" cty:"boot_order" hcl:"boot_order"`
-	WaitTimeout               *string                           `mapstructure:"ip_wait_timeout" cty:"ip_wait_timeout" hcl:"ip_wait_timeout"`
-	SettleTimeout             *string                           `mapstructure:"ip_settle_timeout" cty:"ip_settle_timeout" hcl:"ip_settle_timeout"`
-	Type                      *string                           `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
-	PauseBeforeConnect        *string                           `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
-	SSHHost                   *string                           `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
-	SSHPort                   *int                              `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
-	SSHUsername               *string                           `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
-	SSHPassword               *string                           `mapstructure:"ssh_password" cty:"ssh_password" hcl:"ssh_password"`
-	SSHKeyPairName            *string                           `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
-	SSHTemporaryKeyPairName   *string                           `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
-	SSHTemporaryKeyPairType   *string                           `mapstructure:"temporary_key_pair_type" cty:"temporary_key_pair_type" hcl:"temporary_key_pair_type"`
-	SSHTemporaryKeyPairBits   *int                              `mapstructure:"temporary_key_pair_bits" cty:"temporary_key_pair_bits" hcl:"temporary_key_pair_bits"`
-	SSHCiphers                []string                          `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
-	SSHClearAuthorizedKeys    *bool                             `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
-	SSHKEXAlgos               []string                          `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
-	SSHPrivateKeyFile         *string                           `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
-	SSHCertificateFile        *string                           `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
-	SSHPty                    *bool                             `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
-	SSHTimeout                *string                           `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
-	SSHWaitTimeout            *string                           `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
-	SSHAgentAuth              *bool                             `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
-	SSHDisableAgentForwarding *bool                             `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
-	SSHHandshakeAttempts      *int                              `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
-	SSHBastionHost            *string                           `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
-	SSHBastionPort            *int                              `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
-	SSHBastionAgentAuth       *bool                             `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
-	SSHBastionUsername        *string                           `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
-	SSHBastionPassword        *string                           `mapstructure:"ssh_bastion_password" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
-	SSHBastionInteractive     *bool                             `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
-	SSHBastionPrivateKeyFile  *string                           `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
-	SSHBastionCertificateFile *string                           `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
-	SSHFileTransferMethod     *string                           `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
-	SSHProxyHost              *string                           `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
-	SSHProxyPort              *int                              `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
-	SSHProxyUsername          *string                           `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
-	SSHProxyPassword          *string                           `mapstructure:"ssh_proxy_password" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
-	SSHKeepAliveInterval      *string                           `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
-	SSHReadWriteTimeout       *string                           `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
-	SSHRemoteTunnels          []string                          `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
-	SSHLocalTunnels           []string                          `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
-	SSHPublicKey              []byte                            `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
-	SSHPrivateKey             []byte                            `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
-	WinRMUser                 *string                           `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
-	WinRMPassword             *string                           `mapstructure:"winrm_password" cty:"winrm_password" hcl:"winrm_password"`
-	WinRMHost                 *string                           `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
-	WinRMNoProxy              *bool                             `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
-	WinRMPort                 *int                              `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
-	WinRMTimeout              *string                           `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
-	WinRMUseSSL               *bool                             `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
-	WinRMInsecure             *bool                             `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
-	WinRMUseNTLM              *bool                             `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
-	Command                   *string                           `mapstructure:"shutdown_command" cty:"shutdown_command" hcl:"shutdown_command"`
-	Timeout                   *string                           `mapstructure:"shutdown_timeout" cty:"shutdown_timeout" hcl:"shutdown_timeout"`
-	DisableShutdown           *bool                             `mapstructure:"disable_shutdown" cty:"disable_shutdown" hcl:"disable_shutdown"`
-	Export                    *common.FlatExportConfig          `mapstructure:"export" cty:"export" hcl:"export"`
-	ExportToCatalog           *common.FlatExportToCatalogConfig `mapstructure:"export_to_catalog" cty:"export_to_catalog" hcl:"export_to_catalog"`
+	PackerBuildName           *string                              `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
+	PackerBuilderType         *string                              `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
+	PackerCoreVersion         *string                              `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
+	PackerDebug               *bool                                `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
+	PackerForce               *bool                                `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
+	PackerOnError             *string                              `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
+	PackerUserVars            map[string]string                    `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
+	PackerSensitiveVars       []string                             `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
+	HTTPDir                   *string                              `mapstructure:"http_directory" cty:"http_directory" hcl:"http_directory"`
+	HTTPContent               map[string]string                    `mapstructure:"http_content" cty:"http_content" hcl:"http_content"`
+	HTTPPortMin               *int                                 `mapstructure:"http_port_min" cty:"http_port_min" hcl:"http_port_min"`
+	HTTPPortMax               *int                                 `mapstructure:"http_port_max" cty:"http_port_max" hcl:"http_port_max"`
+	HTTPAddress               *string                              `mapstructure:"http_bind_address" cty:"http_bind_address" hcl:"http_bind_address"`
+	HTTPInterface             *string                              `mapstructure:"http_interface" undocumented:"true" cty:"http_interface" hcl:"http_interface"`
+	HTTPNetworkProtocol       *string                              `mapstructure:"http_network_protocol" cty:"http_network_protocol" hcl:"http_network_protocol"`
+	CDFiles                   []string                             `mapstructure:"cd_files" cty:"cd_files" hcl:"cd_files"`
+	CDContent                 map[string]string                    `mapstructure:"cd_content" cty:"cd_content" hcl:"cd_content"`
+	CDLabel                   *string                              `mapstructure:"cd_label" cty:"cd_label" hcl:"cd_label"`
+	FloppyFiles               []string                             `mapstructure:"floppy_files" cty:"floppy_files" hcl:"floppy_files"`
+	FloppyDirectories         []string                             `mapstructure:"floppy_dirs" cty:"floppy_dirs" hcl:"floppy_dirs"`
+	FloppyContent             map[string]string                    `mapstructure:"floppy_content" cty:"floppy_content" hcl:"floppy_content"`
+	FloppyLabel               *string                              `mapstructure:"floppy_label" cty:"floppy_label" hcl:"floppy_label"`
+	Host                      *string                              `mapstructure:"host" cty:"host" hcl:"host"`
+	Org                       *string                              `mapstructure:"org" cty:"org" hcl:"org"`
+	TenantOrg                 *string                              `mapstructure:"tenant_org" cty:"tenant_org" hcl:"tenant_org"`
+	Username                  *string                              `mapstructure:"username" cty:"username" hcl:"username"`
+	Password                  *string                              `mapstructure:"password" cty:"password" hcl:"password"`
+	Token                     *string                              `mapstructure:"token" cty:"token" hcl:"token"`
+	BearerToken               string                               `mapstructure:"bearer_token" cty:"bearer_token" hcl:"bearer_token"`
+	InsecureConnection        *bool                                `mapstructure:"insecure_connection" cty:"insecure_connection" hcl:"insecure_connection"`
+	CAFile                    *string                              `mapstructure:"ca_file" cty:"ca_file" hcl:"ca_file"`
+	CAPEM                     *string                              `mapstructure:"ca_pem" cty:"ca_pem" hcl:"ca_pem"`
+	APIPageSize               *int                                 `mapstructure:"api_page_size" cty:"api_page_size" hcl:"api_page_size"`
+	MaxConcurrentTransfers    *int                                 `mapstructure:"max_concurrent_transfers" cty:"max_concurrent_transfers" hcl:"max_concurrent_transfers"`
+	UploadParallelism         *int                                 `mapstructure:"upload_parallelism" cty:"upload_parallelism" hcl:"upload_parallelism"`
+	UploadBandwidthLimit      *string                              `mapstructure:"upload_bandwidth_limit" cty:"upload_bandwidth_limit" hcl:"upload_bandwidth_limit"`
+	APIRetryCount             *int                                 `mapstructure:"api_retry_count" cty:"api_retry_count" hcl:"api_retry_count"`
+	APIRetryWait              *string                              `mapstructure:"api_retry_wait" cty:"api_retry_wait" hcl:"api_retry_wait"`
+	APIRateLimit              *float64                             `mapstructure:"api_rate_limit" cty:"api_rate_limit" hcl:"api_rate_limit"`
+	QueueTimeout              *string                              `mapstructure:"queue_timeout" cty:"queue_timeout" hcl:"queue_timeout"`
+	APIDebugLog               *bool                                `mapstructure:"api_debug_log" cty:"api_debug_log" hcl:"api_debug_log"`
+	ProxyURL                  *string                              `mapstructure:"proxy_url" cty:"proxy_url" hcl:"proxy_url"`
+	NoProxy                   *string                              `mapstructure:"no_proxy" cty:"no_proxy" hcl:"no_proxy"`
+	ConsoleProxyURL           *string                              `mapstructure:"console_proxy_url" cty:"console_proxy_url" hcl:"console_proxy_url"`
+	ISOCatalog                *string                              `mapstructure:"iso_catalog" cty:"iso_catalog" hcl:"iso_catalog"`
+	ISOMediaName              *string                              `mapstructure:"iso_media_name" cty:"iso_media_name" hcl:"iso_media_name"`
+	TempCatalogPrefix         *string                              `mapstructure:"temp_catalog_prefix" cty:"temp_catalog_prefix" hcl:"temp_catalog_prefix"`
+	CacheISO                  *bool                                `mapstructure:"cache_iso" cty:"cache_iso" hcl:"cache_iso"`
+	CacheOverwrite            *bool                                `mapstructure:"cache_overwrite" cty:"cache_overwrite" hcl:"cache_overwrite"`
+	ISOSearch                 *bool                                `mapstructure:"iso_search" cty:"iso_search" hcl:"iso_search"`
+	MediaResolveTimeout       *string                              `mapstructure:"media_resolve_timeout" cty:"media_resolve_timeout" hcl:"media_resolve_timeout"`
+	ISOUploadWaitTimeout      *string                              `mapstructure:"iso_upload_wait_timeout" cty:"iso_upload_wait_timeout" hcl:"iso_upload_wait_timeout"`
+	CleanupISO                *bool                                `mapstructure:"cleanup_iso" cty:"cleanup_iso" hcl:"cleanup_iso"`
+	ModifiedISOCacheMaxAge    *string                              `mapstructure:"modified_iso_cache_max_age" cty:"modified_iso_cache_max_age" hcl:"modified_iso_cache_max_age"`
+	ModifiedISOCacheMaxSizeMB *int64                               `mapstructure:"modified_iso_cache_max_size_mb" cty:"modified_iso_cache_max_size_mb" hcl:"modified_iso_cache_max_size_mb"`
+	DisableModifiedISOCache   *bool                                `mapstructure:"disable_modified_iso_cache" cty:"disable_modified_iso_cache" hcl:"disable_modified_iso_cache"`
+	Version                   *string                              `mapstructure:"vm_version" cty:"vm_version" hcl:"vm_version"`
+	GuestOSType               *string                              `mapstructure:"guest_os_type" cty:"guest_os_type" hcl:"guest_os_type"`
+	Description               *string                              `mapstructure:"vm_description" cty:"vm_description" hcl:"vm_description"`
+	DiskSizeMB                *int64                               `mapstructure:"disk_size_mb" cty:"disk_size_mb" hcl:"disk_size_mb"`
+	VMName                    *string                              `mapstructure:"vm_name" cty:"vm_name" hcl:"vm_name"`
+	VApp                      *string                              `mapstructure:"vapp" cty:"vapp" hcl:"vapp"`
+	VDC                       *string                              `mapstructure:"vdc" cty:"vdc" hcl:"vdc"`
+	TenantVDC                 *string                              `mapstructure:"tenant_vdc" cty:"tenant_vdc" hcl:"tenant_vdc"`
+	CreateVApp                *bool                                `mapstructure:"create_vapp" cty:"create_vapp" hcl:"create_vapp"`
+	Network                   *string                              `mapstructure:"network" cty:"network" hcl:"network"`
+	Networks                  []string                             `mapstructure:"networks" cty:"networks" hcl:"networks"`
+	NetworkSelection          *string                              `mapstructure:"network_selection" cty:"network_selection" hcl:"network_selection"`
+	IPAllocationMode          *string                              `mapstructure:"ip_allocation_mode" cty:"ip_allocation_mode" hcl:"ip_allocation_mode"`
+	VMIPAddress               *string                              `mapstructure:"vm_ip" cty:"vm_ip" hcl:"vm_ip"`
+	VMGateway                 *string                              `mapstructure:"vm_gateway" cty:"vm_gateway" hcl:"vm_gateway"`
+	VMDNS                     *string                              `mapstructure:"vm_dns" cty:"vm_dns" hcl:"vm_dns"`
+	StorageProfile            *string                              `mapstructure:"storage_profile" cty:"storage_profile" hcl:"storage_profile"`
+	ISOStorageProfile         *string                              `mapstructure:"iso_storage_profile" cty:"iso_storage_profile" hcl:"iso_storage_profile"`
+	VMStorageProfile          *string                              `mapstructure:"vm_storage_profile" cty:"vm_storage_profile" hcl:"vm_storage_profile"`
+	CatalogStorageProfile     *string                              `mapstructure:"catalog_storage_profile" cty:"catalog_storage_profile" hcl:"catalog_storage_profile"`
+	ValidateRemote            *bool                                `mapstructure:"validate_remote" cty:"validate_remote" hcl:"validate_remote"`
+	CPUs                      *int32                               `mapstructure:"CPUs" cty:"CPUs" hcl:"CPUs"`
+	CoresPerSocket            *int32                               `mapstructure:"cores_per_socket" cty:"cores_per_socket" hcl:"cores_per_socket"`
+	CpuHotAddEnabled          *bool                                `mapstructure:"CPU_hot_plug" cty:"CPU_hot_plug" hcl:"CPU_hot_plug"`
+	Memory                    *int64                               `mapstructure:"memory" cty:"memory" hcl:"memory"`
+	MemoryHotAddEnabled       *bool                                `mapstructure:"RAM_hot_plug" cty:"RAM_hot_plug" hcl:"RAM_hot_plug"`
+	NestedHV                  *bool                                `mapstructure:"NestedHV" cty:"NestedHV" hcl:"NestedHV"`
+	Firmware                  *string                              `mapstructure:"firmware" cty:"firmware" hcl:"firmware"`
+	HardwareVersion           *string                              `mapstructure:"hw_version" cty:"hw_version" hcl:"hw_version"`
+	ForceBIOSSetup            *bool                                `mapstructure:"force_bios_setup" cty:"force_bios_setup" hcl:"force_bios_setup"`
+	VTPMEnabled               *bool                                `mapstructure:"vTPM" cty:"vTPM" hcl:"vTPM"`
+	BootDelay                 *int                                 `mapstructure:"boot_delay" cty:"boot_delay" hcl:"boot_delay"`
+	VMSizingPolicy            *string                              `mapstructure:"vm_sizing_policy" cty:"vm_sizing_policy" hcl:"vm_sizing_policy"`
+	VMPlacementPolicy         *string                              `mapstructure:"vm_placement_policy" cty:"vm_placement_policy" hcl:"vm_placement_policy"`
+	ExtraConfig               map[string]string                    `mapstructure:"extra_config" cty:"extra_config" hcl:"extra_config"`
+	TimeSyncWithHost          *bool                                `mapstructure:"time_sync_with_host" cty:"time_sync_with_host" hcl:"time_sync_with_host"`
+	FirmwareClockUTC          *bool                                `mapstructure:"firmware_clock_utc" cty:"firmware_clock_utc" hcl:"firmware_clock_utc"`
+	ISOChecksum               *string                              `mapstructure:"iso_checksum" required:"true" cty:"iso_checksum" hcl:"iso_checksum"`
+	RawSingleISOUrl           *string                              `mapstructure:"iso_url" required:"true" cty:"iso_url" hcl:"iso_url"`
+	ISOUrls                   []string                             `mapstructure:"iso_urls" cty:"iso_urls" hcl:"iso_urls"`
+	TargetPath                *string                              `mapstructure:"iso_target_path" cty:"iso_target_path" hcl:"iso_target_path"`
+	TargetExtension           *string                              `mapstructure:"iso_target_extension" cty:"iso_target_extension" hcl:"iso_target_extension"`
+	BIOSBootImage             *string                              `mapstructure:"bios_boot_image" cty:"bios_boot_image" hcl:"bios_boot_image"`
+	BIOSBootLoadSize          *int                                 `mapstructure:"bios_boot_load_size" cty:"bios_boot_load_size" hcl:"bios_boot_load_size"`
+	BIOSBootLoadSegment       *string                              `mapstructure:"bios_boot_load_segment" cty:"bios_boot_load_segment" hcl:"bios_boot_load_segment"`
+	BIOSBootInfoTable         *bool                                `mapstructure:"bios_boot_info_table" cty:"bios_boot_info_table" hcl:"bios_boot_info_table"`
+	EFIBootImage              *string                              `mapstructure:"efi_boot_image" cty:"efi_boot_image" hcl:"efi_boot_image"`
+	EFIBootContent            map[string]string                    `mapstructure:"efi_boot_content" cty:"efi_boot_content" hcl:"efi_boot_content"`
+	BootParameters            *string                              `mapstructure:"boot_parameters" cty:"boot_parameters" hcl:"boot_parameters"`
+	BootGroupInterval         *string                              `mapstructure:"boot_keygroup_interval" cty:"boot_keygroup_interval" hcl:"boot_keygroup_interval"`
+	BootWait                  *string                              `mapstructure:"boot_wait" cty:"boot_wait" hcl:"boot_wait"`
+	BootCommand               []string                             `mapstructure:"boot_command" cty:"boot_command" hcl:"boot_command"`
+	BootKeyInterval           *string                              `mapstructure:"boot_key_interval" cty:"boot_key_interval" hcl:"boot_key_interval"`
+	BootKeyboardLayout        *string                              `mapstructure:"boot_keyboard_layout" cty:"boot_keyboard_layout" hcl:"boot_keyboard_layout"`
+	BootCommandTranscript     *string                              `mapstructure:"boot_command_transcript" cty:"boot_command_transcript" hcl:"boot_command_transcript"`
+	RebootExpected            *bool                                `mapstructure:"reboot_expected" cty:"reboot_expected" hcl:"reboot_expected"`
+	RebootTimeout             *string                              `mapstructure:"reboot_timeout" cty:"reboot_timeout" hcl:"reboot_timeout"`
+	WaitForText               []string                             `mapstructure:"wait_for_text" cty:"wait_for_text" hcl:"wait_for_text"`
+	WaitForTextTimeout        *string                              `mapstructure:"wait_for_text_timeout" cty:"wait_for_text_timeout" hcl:"wait_for_text_timeout"`
+	ConsoleProbeTimeout       *string                              `mapstructure:"console_probe_timeout" cty:"console_probe_timeout" hcl:"console_probe_timeout"`
+	ConsoleRecording          *bool                                `mapstructure:"console_recording" cty:"console_recording" hcl:"console_recording"`
+	ConsoleRecordingInterval  *string                              `mapstructure:"console_recording_interval" cty:"console_recording_interval" hcl:"console_recording_interval"`
+	GuestInfo                 map[string]string                    `mapstructure:"guestinfo" cty:"guestinfo" hcl:"guestinfo"`
+	GuestInfoKeep             *bool                                `mapstructure:"guestinfo_keep" cty:"guestinfo_keep" hcl:"guestinfo_keep"`
+	OVFProperties             map[string]string                    `mapstructure:"ovf_properties" cty:"ovf_properties" hcl:"ovf_properties"`
+	OVFPropertiesKeep         *bool                                `mapstructure:"ovf_properties_keep" cty:"ovf_properties_keep" hcl:"ovf_properties_keep"`
+	RemoveNetworkAdapter      *bool                                `mapstructure:"remove_network_adapter" cty:"remove_network_adapter" hcl:"remove_network_adapter"`
+	BootOrder                 *string                              `mapstructure:"boot_order" cty:"boot_order" hcl:"boot_order"`
+	PowerOnMode               *string                              `mapstructure:"power_on_mode" cty:"power_on_mode" hcl:"power_on_mode"`
+	PowerOnForceCustomization *bool                                `mapstructure:"power_on_force_customization" cty:"power_on_force_customization" hcl:"power_on_force_customization"`
+	WaitTimeout               *string                              `mapstructure:"ip_wait_timeout" cty:"ip_wait_timeout" hcl:"ip_wait_timeout"`
+	SettleTimeout             *string                              `mapstructure:"ip_settle_timeout" cty:"ip_settle_timeout" hcl:"ip_settle_timeout"`
+	InstallProgressInterval   *string                              `mapstructure:"install_progress_interval" cty:"install_progress_interval" hcl:"install_progress_interval"`
+	InstallProgressPattern    *string                              `mapstructure:"install_progress_pattern" cty:"install_progress_pattern" hcl:"install_progress_pattern"`
+	VerifyDiskBoot            *bool                                `mapstructure:"verify_disk_boot" cty:"verify_disk_boot" hcl:"verify_disk_boot"`
+	VerifyDiskBootTimeout     *string                              `mapstructure:"verify_disk_boot_timeout" cty:"verify_disk_boot_timeout" hcl:"verify_disk_boot_timeout"`
+	PortForwards              []common.FlatPortForwardRule         `mapstructure:"port_forward" cty:"port_forward" hcl:"port_forward"`
+	PortForwardEdgeGateway    *string                              `mapstructure:"port_forward_edge_gateway" cty:"port_forward_edge_gateway" hcl:"port_forward_edge_gateway"`
+	PortForwardExternalIP     *string                              `mapstructure:"port_forward_external_ip" cty:"port_forward_external_ip" hcl:"port_forward_external_ip"`
+	Type                      *string                              `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
+	PauseBeforeConnect        *string                              `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
+	SSHHost                   *string                              `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
+	SSHPort                   *int                                 `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
+	SSHUsername               *string                              `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
+	SSHPassword               *string                              `mapstructure:"ssh_password" cty:"ssh_password" hcl:"ssh_password"`
+	SSHKeyPairName            *string                              `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
+	SSHTemporaryKeyPairName   *string                              `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
+	SSHTemporaryKeyPairType   *string                              `mapstructure:"temporary_key_pair_type" cty:"temporary_key_pair_type" hcl:"temporary_key_pair_type"`
+	SSHTemporaryKeyPairBits   *int                                 `mapstructure:"temporary_key_pair_bits" cty:"temporary_key_pair_bits" hcl:"temporary_key_pair_bits"`
+	SSHCiphers                []string                             `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
+	SSHClearAuthorizedKeys    *bool                                `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
+	SSHKEXAlgos               []string                             `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
+	SSHPrivateKeyFile         *string                              `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
+	SSHCertificateFile        *string                              `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
+	SSHPty                    *bool                                `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
+	SSHTimeout                *string                              `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
+	SSHWaitTimeout            *string                              `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
+	SSHAgentAuth              *bool                                `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
+	SSHDisableAgentForwarding *bool                                `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
+	SSHHandshakeAttempts      *int                                 `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
+	SSHBastionHost            *string                              `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
+	SSHBastionPort            *int                                 `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
+	SSHBastionAgentAuth       *bool                                `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
+	SSHBastionUsername        *string                              `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
+	SSHBastionPassword        *string                              `mapstructure:"ssh_bastion_password" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
+	SSHBastionInteractive     *bool                                `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
+	SSHBastionPrivateKeyFile  *string                              `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
+	SSHBastionCertificateFile *string                              `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
+	SSHFileTransferMethod     *string                              `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
+	SSHProxyHost              *string                              `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
+	SSHProxyPort              *int                                 `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
+	SSHProxyUsername          *string                              `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
+	SSHProxyPassword          *string                              `mapstructure:"ssh_proxy_password" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
+	SSHKeepAliveInterval      *string                              `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
+	SSHReadWriteTimeout       *string                              `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
+	SSHRemoteTunnels          []string                             `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
+	SSHLocalTunnels           []string                             `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
+	SSHPublicKey              []byte                               `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
+	SSHPrivateKey             []byte                               `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
+	WinRMUser                 *string                              `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
+	WinRMPassword             *string                              `mapstructure:"winrm_password" cty:"winrm_password" hcl:"winrm_password"`
+	WinRMHost                 *string                              `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
+	WinRMNoProxy              *bool                                `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
+	WinRMPort                 *int                                 `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
+	WinRMTimeout              *string                              `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
+	WinRMUseSSL               *bool                                `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
+	WinRMInsecure             *bool                                `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
+	WinRMUseNTLM              *bool                                `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
+	SnapshotBeforeProvision   *bool                                `mapstructure:"snapshot_before_provision" cty:"snapshot_before_provision" hcl:"snapshot_before_provision"`
+	ProvisionRetries          *int                                 `mapstructure:"provision_retries" cty:"provision_retries" hcl:"provision_retries"`
+	Command                   *string                              `mapstructure:"shutdown_command" cty:"shutdown_command" hcl:"shutdown_command"`
+	Timeout                   *string                              `mapstructure:"shutdown_timeout" cty:"shutdown_timeout" hcl:"shutdown_timeout"`
+	DisableShutdown           *bool                                `mapstructure:"disable_shutdown" cty:"disable_shutdown" hcl:"disable_shutdown"`
+	Metadata                  map[string]string                    `mapstructure:"metadata" cty:"metadata" hcl:"metadata"`
+	WorkDirectory             *string                              `mapstructure:"work_directory" cty:"work_directory" hcl:"work_directory"`
+	DebugRenderDir            *string                              `mapstructure:"debug_render_dir" cty:"debug_render_dir" hcl:"debug_render_dir"`
+	ISOBuilderTool            *string                              `mapstructure:"iso_builder_tool" cty:"iso_builder_tool" hcl:"iso_builder_tool"`
+	ISOTargetVolumeLabel      *string                              `mapstructure:"iso_target_volume_label" cty:"iso_target_volume_label" hcl:"iso_target_volume_label"`
+	CDSecondaryDrive          *bool                                `mapstructure:"cd_secondary_drive" cty:"cd_secondary_drive" hcl:"cd_secondary_drive"`
+	CDContentBase64           map[string]string                    `mapstructure:"cd_content_base64" cty:"cd_content_base64" hcl:"cd_content_base64"`
+	BootWIMContent            map[string]string                    `mapstructure:"boot_wim_content" cty:"boot_wim_content" hcl:"boot_wim_content"`
+	GuestCustomization        *common.FlatGuestCustomizationConfig `mapstructure:"guest_customization" cty:"guest_customization" hcl:"guest_customization"`
+	Export                    *common.FlatExportConfig             `mapstructure:"export" cty:"export" hcl:"export"`
+	ExportToCatalog           *common.FlatExportToCatalogConfig    `mapstructure:"export_to_catalog" cty:"export_to_catalog" hcl:"export_to_catalog"`
 }
 
 // FlatMapstructure returns a new FlatConfig.
@@ -147,128 +225,206 @@ func (*Config) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec }
 // The decoded values from this spec will then be applied to a FlatConfig.
 func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 	s := map[string]hcldec.Spec{
-		"packer_build_name":            &hcldec.AttrSpec{Name: "packer_build_name", Type: cty.String, Required: false},
-		"packer_builder_type":          &hcldec.AttrSpec{Name: "packer_builder_type", Type: cty.String, Required: false},
-		"packer_core_version":          &hcldec.AttrSpec{Name: "packer_core_version", Type: cty.String, Required: false},
-		"packer_debug":                 &hcldec.AttrSpec{Name: "packer_debug", Type: cty.Bool, Required: false},
-		"packer_force":                 &hcldec.AttrSpec{Name: "packer_force", Type: cty.Bool, Required: false},
-		"packer_on_error":              &hcldec.AttrSpec{Name: "packer_on_error", Type: cty.String, Required: false},
-		"packer_user_variables":        &hcldec.AttrSpec{Name: "packer_user_variables", Type: cty.Map(cty.String), Required: false},
-		"packer_sensitive_variables":   &hcldec.AttrSpec{Name: "packer_sensitive_variables", Type: cty.List(cty.String), Required: false},
-		"http_directory":               &hcldec.AttrSpec{Name: "http_directory", Type: cty.String, Required: false},
-		"http_content":                 &hcldec.AttrSpec{Name: "http_content", Type: cty.Map(cty.String), Required: false},
-		"http_port_min":                &hcldec.AttrSpec{Name: "http_port_min", Type: cty.Number, Required: false},
-		"http_port_max":                &hcldec.AttrSpec{Name: "http_port_max", Type: cty.Number, Required: false},
-		"http_bind_address":            &hcldec.AttrSpec{Name: "http_bind_address", Type: cty.String, Required: false},
-		"http_interface":               &hcldec.AttrSpec{Name: "http_interface", Type: cty.String, Required: false},
-		"http_network_protocol":        &hcldec.AttrSpec{Name: "http_network_protocol", Type: cty.String, Required: false},
-		"cd_files":                     &hcldec.AttrSpec{Name: "cd_files", Type: cty.List(cty.String), Required: false},
-		"cd_content":                   &hcldec.AttrSpec{Name: "cd_content", Type: cty.Map(cty.String), Required: false},
-		"cd_label":                     &hcldec.AttrSpec{Name: "cd_label", Type: cty.String, Required: false},
-		"host":                         &hcldec.AttrSpec{Name: "host", Type: cty.String, Required: false},
-		"org":                          &hcldec.AttrSpec{Name: "org", Type: cty.String, Required: false},
-		"username":                     &hcldec.AttrSpec{Name: "username", Type: cty.String, Required: false},
-		"password":                     &hcldec.AttrSpec{Name: "password", Type: cty.String, Required: false},
-		"token":                        &hcldec.AttrSpec{Name: "token", Type: cty.String, Required: false},
-		"insecure_connection":          &hcldec.AttrSpec{Name: "insecure_connection", Type: cty.Bool, Required: false},
-		"iso_catalog":                  &hcldec.AttrSpec{Name: "iso_catalog", Type: cty.String, Required: false},
-		"temp_catalog_prefix":          &hcldec.AttrSpec{Name: "temp_catalog_prefix", Type: cty.String, Required: false},
-		"cache_iso":                    &hcldec.AttrSpec{Name: "cache_iso", Type: cty.Bool, Required: false},
-		"cache_overwrite":              &hcldec.AttrSpec{Name: "cache_overwrite", Type: cty.Bool, Required: false},
-		"vm_version":                   &hcldec.AttrSpec{Name: "vm_version", Type: cty.String, Required: false},
-		"guest_os_type":                &hcldec.AttrSpec{Name: "guest_os_type", Type: cty.String, Required: false},
-		"vm_description":               &hcldec.AttrSpec{Name: "vm_description", Type: cty.String, Required: false},
-		"disk_size_mb":                 &hcldec.AttrSpec{Name: "disk_size_mb", Type: cty.Number, Required: false},
-		"vm_name":                      &hcldec.AttrSpec{Name: "vm_name", Type: cty.String, Required: false},
-		"vapp":                         &hcldec.AttrSpec{Name: "vapp", Type: cty.String, Required: false},
-		"vdc":                          &hcldec.AttrSpec{Name: "vdc", Type: cty.String, Required: false},
-		"create_vapp":                  &hcldec.AttrSpec{Name: "create_vapp", Type: cty.Bool, Required: false},
-		"network":                      &hcldec.AttrSpec{Name: "network", Type: cty.String, Required: false},
-		"ip_allocation_mode":           &hcldec.AttrSpec{Name: "ip_allocation_mode", Type: cty.String, Required: false},
-		"vm_ip":                        &hcldec.AttrSpec{Name: "vm_ip", Type: cty.String, Required: false},
-		"vm_gateway":                   &hcldec.AttrSpec{Name: "vm_gateway", Type: cty.String, Required: false},
-		"vm_dns":                       &hcldec.AttrSpec{Name: "vm_dns", Type: cty.String, Required: false},
-		"storage_profile":              &hcldec.AttrSpec{Name: "storage_profile", Type: cty.String, Required: false},
-		"CPUs":                         &hcldec.AttrSpec{Name: "CPUs", Type: cty.Number, Required: false},
-		"cores_per_socket":             &hcldec.AttrSpec{Name: "cores_per_socket", Type: cty.Number, Required: false},
-		"CPU_hot_plug":                 &hcldec.AttrSpec{Name: "CPU_hot_plug", Type: cty.Bool, Required: false},
-		"memory":                       &hcldec.AttrSpec{Name: "memory", Type: cty.Number, Required: false},
-		"RAM_hot_plug":                 &hcldec.AttrSpec{Name: "RAM_hot_plug", Type: cty.Bool, Required: false},
-		"NestedHV":                     &hcldec.AttrSpec{Name: "NestedHV", Type: cty.Bool, Required: false},
-		"firmware":                     &hcldec.AttrSpec{Name: "firmware", Type: cty.String, Required: false},
-		"hw_version":                   &hcldec.AttrSpec{Name: "hw_version", Type: cty.String, Required: false},
-		"force_bios_setup":             &hcldec.AttrSpec{Name: "force_bios_setup", Type: cty.Bool, Required: false},
-		"vTPM":                         &hcldec.AttrSpec{Name: "vTPM", Type: cty.Bool, Required: false},
-		"boot_delay":                   &hcldec.AttrSpec{Name: "boot_delay", Type: cty.Number, Required: false},
-		"vm_sizing_policy":             &hcldec.AttrSpec{Name: "vm_sizing_policy", Type: cty.String, Required: false},
-		"extra_config":                 &hcldec.AttrSpec{Name: "extra_config", Type: cty.Map(cty.String), Required: false},
-		"iso_checksum":                 &hcldec.AttrSpec{Name: "iso_checksum", Type: cty.String, Required: false},
-		"iso_url":                      &hcldec.AttrSpec{Name: "iso_url", Type: cty.String, Required: false},
-		"iso_urls":                     &hcldec.AttrSpec{Name: "iso_urls", Type: cty.List(cty.String), Required: false},
-		"iso_target_path":              &hcldec.AttrSpec{Name: "iso_target_path", Type: cty.String, Required: false},
-		"iso_target_extension":         &hcldec.AttrSpec{Name: "iso_target_extension", Type: cty.String, Required: false},
-		"boot_keygroup_interval":       &hcldec.AttrSpec{Name: "boot_keygroup_interval", Type: cty.String, Required: false},
-		"boot_wait":                    &hcldec.AttrSpec{Name: "boot_wait", Type: cty.String, Required: false},
-		"boot_command":                 &hcldec.AttrSpec{Name: "boot_command", Type: cty.List(cty.String), Required: false},
-		"boot_key_interval":            &hcldec.AttrSpec{Name: "boot_key_interval", Type: cty.String, Required: false},
-		"remove_network_adapter":       &hcldec.AttrSpec{Name: "remove_network_adapter", Type: cty.Bool, Required: false},
-		"boot_order":                   &hcldec.AttrSpec{Name: "boot_order", Type: cty.String, Required: false},
-		"ip_wait_timeout":              &hcldec.AttrSpec{Name: "ip_wait_timeout", Type: cty.String, Required: false},
-		"ip_settle_timeout":            &hcldec.AttrSpec{Name: "ip_settle_timeout", Type: cty.String, Required: false},
-		"communicator":                 &hcldec.AttrSpec{Name: "communicator", Type: cty.String, Required: false},
-		"pause_before_connecting":      &hcldec.AttrSpec{Name: "pause_before_connecting", Type: cty.String, Required: false},
-		"ssh_host":                     &hcldec.AttrSpec{Name: "ssh_host", Type: cty.String, Required: false},
-		"ssh_port":                     &hcldec.AttrSpec{Name: "ssh_port", Type: cty.Number, Required: false},
-		"ssh_username":                 &hcldec.AttrSpec{Name: "ssh_username", Type: cty.String, Required: false},
-		"ssh_password":                 &hcldec.AttrSpec{Name: "ssh_password", Type: cty.String, Required: false},
-		"ssh_keypair_name":             &hcldec.AttrSpec{Name: "ssh_keypair_name", Type: cty.String, Required: false},
-		"temporary_key_pair_name":      &hcldec.AttrSpec{Name: "temporary_key_pair_name", Type: cty.String, Required: false},
-		"temporary_key_pair_type":      &hcldec.AttrSpec{Name: "temporary_key_pair_type", Type: cty.String, Required: false},
-		"temporary_key_pair_bits":      &hcldec.AttrSpec{Name: "temporary_key_pair_bits", Type: cty.Number, Required: false},
-		"ssh_ciphers":                  &hcldec.AttrSpec{Name: "ssh_ciphers", Type: cty.List(cty.String), Required: false},
-		"ssh_clear_authorized_keys":    &hcldec.AttrSpec{Name: "ssh_clear_authorized_keys", Type: cty.Bool, Required: false},
-		"ssh_key_exchange_algorithms":  &hcldec.AttrSpec{Name: "ssh_key_exchange_algorithms", Type: cty.List(cty.String), Required: false},
-		"ssh_private_key_file":         &hcldec.AttrSpec{Name: "ssh_private_key_file", Type: cty.String, Required: false},
-		"ssh_certificate_file":         &hcldec.AttrSpec{Name: "ssh_certificate_file", Type: cty.String, Required: false},
-		"ssh_pty":                      &hcldec.AttrSpec{Name: "ssh_pty", Type: cty.Bool, Required: false},
-		"ssh_timeout":                  &hcldec.AttrSpec{Name: "ssh_timeout", Type: cty.String, Required: false},
-		"ssh_wait_timeout":             &hcldec.AttrSpec{Name: "ssh_wait_timeout", Type: cty.String, Required: false},
-		"ssh_agent_auth":               &hcldec.AttrSpec{Name: "ssh_agent_auth", Type: cty.Bool, Required: false},
-		"ssh_disable_agent_forwarding": &hcldec.AttrSpec{Name: "ssh_disable_agent_forwarding", Type: cty.Bool, Required: false},
-		"ssh_handshake_attempts":       &hcldec.AttrSpec{Name: "ssh_handshake_attempts", Type: cty.Number, Required: false},
-		"ssh_bastion_host":             &hcldec.AttrSpec{Name: "ssh_bastion_host", Type: cty.String, Required: false},
-		"ssh_bastion_port":             &hcldec.AttrSpec{Name: "ssh_bastion_port", Type: cty.Number, Required: false},
-		"ssh_bastion_agent_auth":       &hcldec.AttrSpec{Name: "ssh_bastion_agent_auth", Type: cty.Bool, Required: false},
-		"ssh_bastion_username":         &hcldec.AttrSpec{Name: "ssh_bastion_username", Type: cty.String, Required: false},
-		"ssh_bastion_password":         &hcldec.AttrSpec{Name: "ssh_bastion_password", Type: cty.String, Required: false},
-		"ssh_bastion_interactive":      &hcldec.AttrSpec{Name: "ssh_bastion_interactive", Type: cty.Bool, Required: false},
-		"ssh_bastion_private_key_file": &hcldec.AttrSpec{Name: "ssh_bastion_private_key_file", Type: cty.String, Required: false},
-		"ssh_bastion_certificate_file": &hcldec.AttrSpec{Name: "ssh_bastion_certificate_file", Type: cty.String, Required: false},
-		"ssh_file_transfer_method":     &hcldec.AttrSpec{Name: "ssh_file_transfer_method", Type: cty.String, Required: false},
-		"ssh_proxy_host":               &hcldec.AttrSpec{Name: "ssh_proxy_host", Type: cty.String, Required: false},
-		"ssh_proxy_port":               &hcldec.AttrSpec{Name: "ssh_proxy_port", Type: cty.Number, Required: false},
-		"ssh_proxy_username":           &hcldec.AttrSpec{Name: "ssh_proxy_username", Type: cty.String, Required: false},
-		"ssh_proxy_password":           &hcldec.AttrSpec{Name: "ssh_proxy_password", Type: cty.String, Required: false},
-		"ssh_keep_alive_interval":      &hcldec.AttrSpec{Name: "ssh_keep_alive_interval", Type: cty.String, Required: false},
-		"ssh_read_write_timeout":       &hcldec.AttrSpec{Name: "ssh_read_write_timeout", Type: cty.String, Required: false},
-		"ssh_remote_tunnels":           &hcldec.AttrSpec{Name: "ssh_remote_tunnels", Type: cty.List(cty.String), Required: false},
-		"ssh_local_tunnels":            &hcldec.AttrSpec{Name: "ssh_local_tunnels", Type: cty.List(cty.String), Required: false},
-		"ssh_public_key":               &hcldec.AttrSpec{Name: "ssh_public_key", Type: cty.List(cty.Number), Required: false},
-		"ssh_private_key":              &hcldec.AttrSpec{Name: "ssh_private_key", Type: cty.List(cty.Number), Required: false},
-		"winrm_username":               &hcldec.AttrSpec{Name: "winrm_username", Type: cty.String, Required: false},
-		"winrm_password":               &hcldec.AttrSpec{Name: "winrm_password", Type: cty.String, Required: false},
-		"winrm_host":                   &hcldec.AttrSpec{Name: "winrm_host", Type: cty.String, Required: false},
-		"winrm_no_proxy":               &hcldec.AttrSpec{Name: "winrm_no_proxy", Type: cty.Bool, Required: false},
-		"winrm_port":                   &hcldec.AttrSpec{Name: "winrm_port", Type: cty.Number, Required: false},
-		"winrm_timeout":                &hcldec.AttrSpec{Name: "winrm_timeout", Type: cty.String, Required: false},
-		"winrm_use_ssl":                &hcldec.AttrSpec{Name: "winrm_use_ssl", Type: cty.Bool, Required: false},
-		"winrm_insecure":               &hcldec.AttrSpec{Name: "winrm_insecure", Type: cty.Bool, Required: false},
-		"winrm_use_ntlm":               &hcldec.AttrSpec{Name: "winrm_use_ntlm", Type: cty.Bool, Required: false},
-		"shutdown_command":             &hcldec.AttrSpec{Name: "shutdown_command", Type: cty.String, Required: false},
-		"shutdown_timeout":             &hcldec.AttrSpec{Name: "shutdown_timeout", Type: cty.String, Required: false},
-		"disable_shutdown":             &hcldec.AttrSpec{Name: "disable_shutdown", Type: cty.Bool, Required: false},
-		"export":                       &hcldec.BlockSpec{TypeName: "export", Nested: hcldec.ObjectSpec((*common.FlatExportConfig)(nil).HCL2Spec())},
-		"export_to_catalog":            &hcldec.BlockSpec{TypeName: "export_to_catalog", Nested: hcldec.ObjectSpec((*common.FlatExportToCatalogConfig)(nil).HCL2Spec())},
+		"packer_build_name":              &hcldec.AttrSpec{Name: "packer_build_name", Type: cty.String, Required: false},
+		"packer_builder_type":            &hcldec.AttrSpec{Name: "packer_builder_type", Type: cty.String, Required: false},
+		"packer_core_version":            &hcldec.AttrSpec{Name: "packer_core_version", Type: cty.String, Required: false},
+		"packer_debug":                   &hcldec.AttrSpec{Name: "packer_debug", Type: cty.Bool, Required: false},
+		"packer_force":                   &hcldec.AttrSpec{Name: "packer_force", Type: cty.Bool, Required: false},
+		"packer_on_error":                &hcldec.AttrSpec{Name: "packer_on_error", Type: cty.String, Required: false},
+		"packer_user_variables":          &hcldec.AttrSpec{Name: "packer_user_variables", Type: cty.Map(cty.String), Required: false},
+		"packer_sensitive_variables":     &hcldec.AttrSpec{Name: "packer_sensitive_variables", Type: cty.List(cty.String), Required: false},
+		"http_directory":                 &hcldec.AttrSpec{Name: "http_directory", Type: cty.String, Required: false},
+		"http_content":                   &hcldec.AttrSpec{Name: "http_content", Type: cty.Map(cty.String), Required: false},
+		"http_port_min":                  &hcldec.AttrSpec{Name: "http_port_min", Type: cty.Number, Required: false},
+		"http_port_max":                  &hcldec.AttrSpec{Name: "http_port_max", Type: cty.Number, Required: false},
+		"http_bind_address":              &hcldec.AttrSpec{Name: "http_bind_address", Type: cty.String, Required: false},
+		"http_interface":                 &hcldec.AttrSpec{Name: "http_interface", Type: cty.String, Required: false},
+		"http_network_protocol":          &hcldec.AttrSpec{Name: "http_network_protocol", Type: cty.String, Required: false},
+		"cd_files":                       &hcldec.AttrSpec{Name: "cd_files", Type: cty.List(cty.String), Required: false},
+		"cd_content":                     &hcldec.AttrSpec{Name: "cd_content", Type: cty.Map(cty.String), Required: false},
+		"cd_label":                       &hcldec.AttrSpec{Name: "cd_label", Type: cty.String, Required: false},
+		"floppy_files":                   &hcldec.AttrSpec{Name: "floppy_files", Type: cty.List(cty.String), Required: false},
+		"floppy_dirs":                    &hcldec.AttrSpec{Name: "floppy_dirs", Type: cty.List(cty.String), Required: false},
+		"floppy_content":                 &hcldec.AttrSpec{Name: "floppy_content", Type: cty.Map(cty.String), Required: false},
+		"floppy_label":                   &hcldec.AttrSpec{Name: "floppy_label", Type: cty.String, Required: false},
+		"host":                           &hcldec.AttrSpec{Name: "host", Type: cty.String, Required: false},
+		"org":                            &hcldec.AttrSpec{Name: "org", Type: cty.String, Required: false},
+		"tenant_org":                     &hcldec.AttrSpec{Name: "tenant_org", Type: cty.String, Required: false},
+		"username":                       &hcldec.AttrSpec{Name: "username", Type: cty.String, Required: false},
+		"password":                       &hcldec.AttrSpec{Name: "password", Type: cty.String, Required: false},
+		"token":                          &hcldec.AttrSpec{Name: "token", Type: cty.String, Required: false},
+		"bearer_token":                   &hcldec.AttrSpec{Name: "bearer_token", Type: cty.String, Required: false},
+		"insecure_connection":            &hcldec.AttrSpec{Name: "insecure_connection", Type: cty.Bool, Required: false},
+		"ca_file":                        &hcldec.AttrSpec{Name: "ca_file", Type: cty.String, Required: false},
+		"ca_pem":                         &hcldec.AttrSpec{Name: "ca_pem", Type: cty.String, Required: false},
+		"api_page_size":                  &hcldec.AttrSpec{Name: "api_page_size", Type: cty.Number, Required: false},
+		"max_concurrent_transfers":       &hcldec.AttrSpec{Name: "max_concurrent_transfers", Type: cty.Number, Required: false},
+		"upload_parallelism":             &hcldec.AttrSpec{Name: "upload_parallelism", Type: cty.Number, Required: false},
+		"upload_bandwidth_limit":         &hcldec.AttrSpec{Name: "upload_bandwidth_limit", Type: cty.String, Required: false},
+		"api_retry_count":                &hcldec.AttrSpec{Name: "api_retry_count", Type: cty.Number, Required: false},
+		"api_retry_wait":                 &hcldec.AttrSpec{Name: "api_retry_wait", Type: cty.String, Required: false},
+		"api_rate_limit":                 &hcldec.AttrSpec{Name: "api_rate_limit", Type: cty.Number, Required: false},
+		"queue_timeout":                  &hcldec.AttrSpec{Name: "queue_timeout", Type: cty.String, Required: false},
+		"api_debug_log":                  &hcldec.AttrSpec{Name: "api_debug_log", Type: cty.Bool, Required: false},
+		"proxy_url":                      &hcldec.AttrSpec{Name: "proxy_url", Type: cty.String, Required: false},
+		"no_proxy":                       &hcldec.AttrSpec{Name: "no_proxy", Type: cty.String, Required: false},
+		"console_proxy_url":              &hcldec.AttrSpec{Name: "console_proxy_url", Type: cty.String, Required: false},
+		"iso_catalog":                    &hcldec.AttrSpec{Name: "iso_catalog", Type: cty.String, Required: false},
+		"iso_media_name":                 &hcldec.AttrSpec{Name: "iso_media_name", Type: cty.String, Required: false},
+		"temp_catalog_prefix":            &hcldec.AttrSpec{Name: "temp_catalog_prefix", Type: cty.String, Required: false},
+		"cache_iso":                      &hcldec.AttrSpec{Name: "cache_iso", Type: cty.Bool, Required: false},
+		"cache_overwrite":                &hcldec.AttrSpec{Name: "cache_overwrite", Type: cty.Bool, Required: false},
+		"iso_search":                     &hcldec.AttrSpec{Name: "iso_search", Type: cty.Bool, Required: false},
+		"media_resolve_timeout":          &hcldec.AttrSpec{Name: "media_resolve_timeout", Type: cty.String, Required: false},
+		"iso_upload_wait_timeout":        &hcldec.AttrSpec{Name: "iso_upload_wait_timeout", Type: cty.String, Required: false},
+		"cleanup_iso":                    &hcldec.AttrSpec{Name: "cleanup_iso", Type: cty.Bool, Required: false},
+		"modified_iso_cache_max_age":     &hcldec.AttrSpec{Name: "modified_iso_cache_max_age", Type: cty.String, Required: false},
+		"modified_iso_cache_max_size_mb": &hcldec.AttrSpec{Name: "modified_iso_cache_max_size_mb", Type: cty.Number, Required: false},
+		"disable_modified_iso_cache":     &hcldec.AttrSpec{Name: "disable_modified_iso_cache", Type: cty.Bool, Required: false},
+		"vm_version":                     &hcldec.AttrSpec{Name: "vm_version", Type: cty.String, Required: false},
+		"guest_os_type":                  &hcldec.AttrSpec{Name: "guest_os_type", Type: cty.String, Required: false},
+		"vm_description":                 &hcldec.AttrSpec{Name: "vm_description", Type: cty.String, Required: false},
+		"disk_size_mb":                   &hcldec.AttrSpec{Name: "disk_size_mb", Type: cty.Number, Required: false},
+		"vm_name":                        &hcldec.AttrSpec{Name: "vm_name", Type: cty.String, Required: false},
+		"vapp":                           &hcldec.AttrSpec{Name: "vapp", Type: cty.String, Required: false},
+		"vdc":                            &hcldec.AttrSpec{Name: "vdc", Type: cty.String, Required: false},
+		"tenant_vdc":                     &hcldec.AttrSpec{Name: "tenant_vdc", Type: cty.String, Required: false},
+		"create_vapp":                    &hcldec.AttrSpec{Name: "create_vapp", Type: cty.Bool, Required: false},
+		"network":                        &hcldec.AttrSpec{Name: "network", Type: cty.String, Required: false},
+		"networks":                       &hcldec.AttrSpec{Name: "networks", Type: cty.List(cty.String), Required: false},
+		"network_selection":              &hcldec.AttrSpec{Name: "network_selection", Type: cty.String, Required: false},
+		"ip_allocation_mode":             &hcldec.AttrSpec{Name: "ip_allocation_mode", Type: cty.String, Required: false},
+		"vm_ip":                          &hcldec.AttrSpec{Name: "vm_ip", Type: cty.String, Required: false},
+		"vm_gateway":                     &hcldec.AttrSpec{Name: "vm_gateway", Type: cty.String, Required: false},
+		"vm_dns":                         &hcldec.AttrSpec{Name: "vm_dns", Type: cty.String, Required: false},
+		"storage_profile":                &hcldec.AttrSpec{Name: "storage_profile", Type: cty.String, Required: false},
+		"iso_storage_profile":            &hcldec.AttrSpec{Name: "iso_storage_profile", Type: cty.String, Required: false},
+		"vm_storage_profile":             &hcldec.AttrSpec{Name: "vm_storage_profile", Type: cty.String, Required: false},
+		"catalog_storage_profile":        &hcldec.AttrSpec{Name: "catalog_storage_profile", Type: cty.String, Required: false},
+		"validate_remote":                &hcldec.AttrSpec{Name: "validate_remote", Type: cty.Bool, Required: false},
+		"CPUs":                           &hcldec.AttrSpec{Name: "CPUs", Type: cty.Number, Required: false},
+		"cores_per_socket":               &hcldec.AttrSpec{Name: "cores_per_socket", Type: cty.Number, Required: false},
+		"CPU_hot_plug":                   &hcldec.AttrSpec{Name: "CPU_hot_plug", Type: cty.Bool, Required: false},
+		"memory":                         &hcldec.AttrSpec{Name: "memory", Type: cty.Number, Required: false},
+		"RAM_hot_plug":                   &hcldec.AttrSpec{Name: "RAM_hot_plug", Type: cty.Bool, Required: false},
+		"NestedHV":                       &hcldec.AttrSpec{Name: "NestedHV", Type: cty.Bool, Required: false},
+		"firmware":                       &hcldec.AttrSpec{Name: "firmware", Type: cty.String, Required: false},
+		"hw_version":                     &hcldec.AttrSpec{Name: "hw_version", Type: cty.String, Required: false},
+		"force_bios_setup":               &hcldec.AttrSpec{Name: "force_bios_setup", Type: cty.Bool, Required: false},
+		"vTPM":                           &hcldec.AttrSpec{Name: "vTPM", Type: cty.Bool, Required: false},
+		"boot_delay":                     &hcldec.AttrSpec{Name: "boot_delay", Type: cty.Number, Required: false},
+		"vm_sizing_policy":               &hcldec.AttrSpec{Name: "vm_sizing_policy", Type: cty.String, Required: false},
+		"vm_placement_policy":            &hcldec.AttrSpec{Name: "vm_placement_policy", Type: cty.String, Required: false},
+		"extra_config":                   &hcldec.AttrSpec{Name: "extra_config", Type: cty.Map(cty.String), Required: false},
+		"time_sync_with_host":            &hcldec.AttrSpec{Name: "time_sync_with_host", Type: cty.Bool, Required: false},
+		"firmware_clock_utc":             &hcldec.AttrSpec{Name: "firmware_clock_utc", Type: cty.Bool, Required: false},
+		"iso_checksum":                   &hcldec.AttrSpec{Name: "iso_checksum", Type: cty.String, Required: false},
+		"iso_url":                        &hcldec.AttrSpec{Name: "iso_url", Type: cty.String, Required: false},
+		"iso_urls":                       &hcldec.AttrSpec{Name: "iso_urls", Type: cty.List(cty.String), Required: false},
+		"iso_target_path":                &hcldec.AttrSpec{Name: "iso_target_path", Type: cty.String, Required: false},
+		"iso_target_extension":           &hcldec.AttrSpec{Name: "iso_target_extension", Type: cty.String, Required: false},
+		"bios_boot_image":                &hcldec.AttrSpec{Name: "bios_boot_image", Type: cty.String, Required: false},
+		"bios_boot_load_size":            &hcldec.AttrSpec{Name: "bios_boot_load_size", Type: cty.Number, Required: false},
+		"bios_boot_load_segment":         &hcldec.AttrSpec{Name: "bios_boot_load_segment", Type: cty.String, Required: false},
+		"bios_boot_info_table":           &hcldec.AttrSpec{Name: "bios_boot_info_table", Type: cty.Bool, Required: false},
+		"efi_boot_image":                 &hcldec.AttrSpec{Name: "efi_boot_image", Type: cty.String, Required: false},
+		"efi_boot_content":               &hcldec.AttrSpec{Name: "efi_boot_content", Type: cty.Map(cty.String), Required: false},
+		"boot_parameters":                &hcldec.AttrSpec{Name: "boot_parameters", Type: cty.String, Required: false},
+		"boot_keygroup_interval":         &hcldec.AttrSpec{Name: "boot_keygroup_interval", Type: cty.String, Required: false},
+		"boot_wait":                      &hcldec.AttrSpec{Name: "boot_wait", Type: cty.String, Required: false},
+		"boot_command":                   &hcldec.AttrSpec{Name: "boot_command", Type: cty.List(cty.String), Required: false},
+		"boot_key_interval":              &hcldec.AttrSpec{Name: "boot_key_interval", Type: cty.String, Required: false},
+		"boot_keyboard_layout":           &hcldec.AttrSpec{Name: "boot_keyboard_layout", Type: cty.String, Required: false},
+		"boot_command_transcript":        &hcldec.AttrSpec{Name: "boot_command_transcript", Type: cty.String, Required: false},
+		"reboot_expected":                &hcldec.AttrSpec{Name: "reboot_expected", Type: cty.Bool, Required: false},
+		"reboot_timeout":                 &hcldec.AttrSpec{Name: "reboot_timeout", Type: cty.String, Required: false},
+		"wait_for_text":                  &hcldec.AttrSpec{Name: "wait_for_text", Type: cty.List(cty.String), Required: false},
+		"wait_for_text_timeout":          &hcldec.AttrSpec{Name: "wait_for_text_timeout", Type: cty.String, Required: false},
+		"console_probe_timeout":          &hcldec.AttrSpec{Name: "console_probe_timeout", Type: cty.String, Required: false},
+		"console_recording":              &hcldec.AttrSpec{Name: "console_recording", Type: cty.Bool, Required: false},
+		"console_recording_interval":     &hcldec.AttrSpec{Name: "console_recording_interval", Type: cty.String, Required: false},
+		"guestinfo":                      &hcldec.AttrSpec{Name: "guestinfo", Type: cty.Map(cty.String), Required: false},
+		"guestinfo_keep":                 &hcldec.AttrSpec{Name: "guestinfo_keep", Type: cty.Bool, Required: false},
+		"ovf_properties":                 &hcldec.AttrSpec{Name: "ovf_properties", Type: cty.Map(cty.String), Required: false},
+		"ovf_properties_keep":            &hcldec.AttrSpec{Name: "ovf_properties_keep", Type: cty.Bool, Required: false},
+		"remove_network_adapter":         &hcldec.AttrSpec{Name: "remove_network_adapter", Type: cty.Bool, Required: false},
+		"boot_order":                     &hcldec.AttrSpec{Name: "boot_order", Type: cty.String, Required: false},
+		"power_on_mode":                  &hcldec.AttrSpec{Name: "power_on_mode", Type: cty.String, Required: false},
+		"power_on_force_customization":   &hcldec.AttrSpec{Name: "power_on_force_customization", Type: cty.Bool, Required: false},
+		"ip_wait_timeout":                &hcldec.AttrSpec{Name: "ip_wait_timeout", Type: cty.String, Required: false},
+		"ip_settle_timeout":              &hcldec.AttrSpec{Name: "ip_settle_timeout", Type: cty.String, Required: false},
+		"install_progress_interval":      &hcldec.AttrSpec{Name: "install_progress_interval", Type: cty.String, Required: false},
+		"install_progress_pattern":       &hcldec.AttrSpec{Name: "install_progress_pattern", Type: cty.String, Required: false},
+		"verify_disk_boot":               &hcldec.AttrSpec{Name: "verify_disk_boot", Type: cty.Bool, Required: false},
+		"verify_disk_boot_timeout":       &hcldec.AttrSpec{Name: "verify_disk_boot_timeout", Type: cty.String, Required: false},
+		"port_forward":                   &hcldec.BlockListSpec{TypeName: "port_forward", Nested: hcldec.ObjectSpec((*common.FlatPortForwardRule)(nil).HCL2Spec())},
+		"port_forward_edge_gateway":      &hcldec.AttrSpec{Name: "port_forward_edge_gateway", Type: cty.String, Required: false},
+		"port_forward_external_ip":       &hcldec.AttrSpec{Name: "port_forward_external_ip", Type: cty.String, Required: false},
+		"communicator":                   &hcldec.AttrSpec{Name: "communicator", Type: cty.String, Required: false},
+		"pause_before_connecting":        &hcldec.AttrSpec{Name: "pause_before_connecting", Type: cty.String, Required: false},
+		"ssh_host":                       &hcldec.AttrSpec{Name: "ssh_host", Type: cty.String, Required: false},
+		"ssh_port":                       &hcldec.AttrSpec{Name: "ssh_port", Type: cty.Number, Required: false},
+		"ssh_username":                   &hcldec.AttrSpec{Name: "ssh_username", Type: cty.String, Required: false},
+		"ssh_password":                   &hcldec.AttrSpec{Name: "ssh_password", Type: cty.String, Required: false},
+		"ssh_keypair_name":               &hcldec.AttrSpec{Name: "ssh_keypair_name", Type: cty.String, Required: false},
+		"temporary_key_pair_name":        &hcldec.AttrSpec{Name: "temporary_key_pair_name", Type: cty.String, Required: false},
+		"temporary_key_pair_type":        &hcldec.AttrSpec{Name: "temporary_key_pair_type", Type: cty.String, Required: false},
+		"temporary_key_pair_bits":        &hcldec.AttrSpec{Name: "temporary_key_pair_bits", Type: cty.Number, Required: false},
+		"ssh_ciphers":                    &hcldec.AttrSpec{Name: "ssh_ciphers", Type: cty.List(cty.String), Required: false},
+		"ssh_clear_authorized_keys":      &hcldec.AttrSpec{Name: "ssh_clear_authorized_keys", Type: cty.Bool, Required: false},
+		"ssh_key_exchange_algorithms":    &hcldec.AttrSpec{Name: "ssh_key_exchange_algorithms", Type: cty.List(cty.String), Required: false},
+		"ssh_private_key_file":           &hcldec.AttrSpec{Name: "ssh_private_key_file", Type: cty.String, Required: false},
+		"ssh_certificate_file":           &hcldec.AttrSpec{Name: "ssh_certificate_file", Type: cty.String, Required: false},
+		"ssh_pty":                        &hcldec.AttrSpec{Name: "ssh_pty", Type: cty.Bool, Required: false},
+		"ssh_timeout":                    &hcldec.AttrSpec{Name: "ssh_timeout", Type: cty.String, Required: false},
+		"ssh_wait_timeout":               &hcldec.AttrSpec{Name: "ssh_wait_timeout", Type: cty.String, Required: false},
+		"ssh_agent_auth":                 &hcldec.AttrSpec{Name: "ssh_agent_auth", Type: cty.Bool, Required: false},
+		"ssh_disable_agent_forwarding":   &hcldec.AttrSpec{Name: "ssh_disable_agent_forwarding", Type: cty.Bool, Required: false},
+		"ssh_handshake_attempts":         &hcldec.AttrSpec{Name: "ssh_handshake_attempts", Type: cty.Number, Required: false},
+		"ssh_bastion_host":               &hcldec.AttrSpec{Name: "ssh_bastion_host", Type: cty.String, Required: false},
+		"ssh_bastion_port":               &hcldec.AttrSpec{Name: "ssh_bastion_port", Type: cty.Number, Required: false},
+		"ssh_bastion_agent_auth":         &hcldec.AttrSpec{Name: "ssh_bastion_agent_auth", Type: cty.Bool, Required: false},
+		"ssh_bastion_username":           &hcldec.AttrSpec{Name: "ssh_bastion_username", Type: cty.String, Required: false},
+		"ssh_bastion_password":           &hcldec.AttrSpec{Name: "ssh_bastion_password", Type: cty.String, Required: false},
+		"ssh_bastion_interactive":        &hcldec.AttrSpec{Name: "ssh_bastion_interactive", Type: cty.Bool, Required: false},
+		"ssh_bastion_private_key_file":   &hcldec.AttrSpec{Name: "ssh_bastion_private_key_file", Type: cty.String, Required: false},
+		"ssh_bastion_certificate_file":   &hcldec.AttrSpec{Name: "ssh_bastion_certificate_file", Type: cty.String, Required: false},
+		"ssh_file_transfer_method":       &hcldec.AttrSpec{Name: "ssh_file_transfer_method", Type: cty.String, Required: false},
+		"ssh_proxy_host":                 &hcldec.AttrSpec{Name: "ssh_proxy_host", Type: cty.String, Required: false},
+		"ssh_proxy_port":                 &hcldec.AttrSpec{Name: "ssh_proxy_port", Type: cty.Number, Required: false},
+		"ssh_proxy_username":             &hcldec.AttrSpec{Name: "ssh_proxy_username", Type: cty.String, Required: false},
+		"ssh_proxy_password":             &hcldec.AttrSpec{Name: "ssh_proxy_password", Type: cty.String, Required: false},
+		"ssh_keep_alive_interval":        &hcldec.AttrSpec{Name: "ssh_keep_alive_interval", Type: cty.String, Required: false},
+		"ssh_read_write_timeout":         &hcldec.AttrSpec{Name: "ssh_read_write_timeout", Type: cty.String, Required: false},
+		"ssh_remote_tunnels":             &hcldec.AttrSpec{Name: "ssh_remote_tunnels", Type: cty.List(cty.String), Required: false},
+		"ssh_local_tunnels":              &hcldec.AttrSpec{Name: "ssh_local_tunnels", Type: cty.List(cty.String), Required: false},
+		"ssh_public_key":                 &hcldec.AttrSpec{Name: "ssh_public_key", Type: cty.List(cty.Number), Required: false},
+		"ssh_private_key":                &hcldec.AttrSpec{Name: "ssh_private_key", Type: cty.List(cty.Number), Required: false},
+		"winrm_username":                 &hcldec.AttrSpec{Name: "winrm_username", Type: cty.String, Required: false},
+		"winrm_password":                 &hcldec.AttrSpec{Name: "winrm_password", Type: cty.String, Required: false},
+		"winrm_host":                     &hcldec.AttrSpec{Name: "winrm_host", Type: cty.String, Required: false},
+		"winrm_no_proxy":                 &hcldec.AttrSpec{Name: "winrm_no_proxy", Type: cty.Bool, Required: false},
+		"winrm_port":                     &hcldec.AttrSpec{Name: "winrm_port", Type: cty.Number, Required: false},
+		"winrm_timeout":                  &hcldec.AttrSpec{Name: "winrm_timeout", Type: cty.String, Required: false},
+		"winrm_use_ssl":                  &hcldec.AttrSpec{Name: "winrm_use_ssl", Type: cty.Bool, Required: false},
+		"winrm_insecure":                 &hcldec.AttrSpec{Name: "winrm_insecure", Type: cty.Bool, Required: false},
+		"winrm_use_ntlm":                 &hcldec.AttrSpec{Name: "winrm_use_ntlm", Type: cty.Bool, Required: false},
+		"snapshot_before_provision":      &hcldec.AttrSpec{Name: "snapshot_before_provision", Type: cty.Bool, Required: false},
+		"provision_retries":              &hcldec.AttrSpec{Name: "provision_retries", Type: cty.Number, Required: false},
+		"shutdown_command":               &hcldec.AttrSpec{Name: "shutdown_command", Type: cty.String, Required: false},
+		"shutdown_timeout":               &hcldec.AttrSpec{Name: "shutdown_timeout", Type: cty.String, Required: false},
+		"disable_shutdown":               &hcldec.AttrSpec{Name: "disable_shutdown", Type: cty.Bool, Required: false},
+		"metadata":                       &hcldec.AttrSpec{Name: "metadata", Type: cty.Map(cty.String), Required: false},
+		"work_directory":                 &hcldec.AttrSpec{Name: "work_directory", Type: cty.String, Required: false},
+		"debug_render_dir":               &hcldec.AttrSpec{Name: "debug_render_dir", Type: cty.String, Required: false},
+		"iso_builder_tool":               &hcldec.AttrSpec{Name: "iso_builder_tool", Type: cty.String, Required: false},
+		"iso_target_volume_label":        &hcldec.AttrSpec{Name: "iso_target_volume_label", Type: cty.String, Required: false},
+		"cd_secondary_drive":             &hcldec.AttrSpec{Name: "cd_secondary_drive", Type: cty.Bool, Required: false},
+		"cd_content_base64":              &hcldec.AttrSpec{Name: "cd_content_base64", Type: cty.Map(cty.String), Required: false},
+		"boot_wim_content":               &hcldec.AttrSpec{Name: "boot_wim_content", Type: cty.Map(cty.String), Required: false},
+		"guest_customization":            &hcldec.BlockSpec{TypeName: "guest_customization", Nested: hcldec.ObjectSpec((*common.FlatGuestCustomizationConfig)(nil).HCL2Spec())},
+		"export":                         &hcldec.BlockSpec{TypeName: "export", Nested: hcldec.ObjectSpec((*common.FlatExportConfig)(nil).HCL2Spec())},
+		"export_to_catalog":              &hcldec.BlockSpec{TypeName: "export_to_catalog", Nested: hcldec.ObjectSpec((*common.FlatExportToCatalogConfig)(nil).HCL2Spec())},
 	}
 	return s
 }
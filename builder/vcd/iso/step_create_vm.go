@@ -6,6 +6,7 @@ import (
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/common"
 	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
 	"github.com/vmware/go-vcloud-director/v3/govcd"
 	"github.com/vmware/go-vcloud-director/v3/types/v56"
@@ -21,6 +22,7 @@ type StepCreateVM struct {
 	Firmware         string
 	HardwareVersion  string
 	DiskSizeMB       int64
+	TimeSyncWithHost bool
 }
 
 func (s *StepCreateVM) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
@@ -42,12 +44,6 @@ func (s *StepCreateVM) Run(_ context.Context, state multistep.StateBag) multiste
 		storageProfileRef = &sp
 	}
 
-	// Get the computer name from VM name (sanitized)
-	computerName := s.VMName
-	if len(computerName) > 15 {
-		computerName = computerName[:15]
-	}
-
 	// Determine boot firmware
 	// Note: "efi-secure" is set as "efi" here, and secure boot is enabled via boot options
 	firmware := "bios"
@@ -72,7 +68,7 @@ func (s *StepCreateVM) Run(_ context.Context, state multistep.StateBag) multiste
 			Name:                      s.VMName,
 			Description:               s.Description,
 			StorageProfile:            storageProfileRef, // Set at VM level to ensure all storage uses this profile
-			GuestCustomizationSection: nil,
+			GuestCustomizationSection: nil, // Set by StepGuestCustomization (if configured)
 			VmSpecSection: &types.VmSpecSection{
 				Modified:          boolPointer(true),
 				Info:              "Virtual Machine specification",
@@ -98,7 +94,7 @@ func (s *StepCreateVM) Run(_ context.Context, state multistep.StateBag) multiste
 				HardwareVersion: &types.HardwareVersion{Value: hwVersion},
 				VmToolsVersion:  "",
 				VirtualCpuType:  "VM64",
-				TimeSyncWithHost: boolPointer(false),
+				TimeSyncWithHost: boolPointer(s.TimeSyncWithHost),
 				Firmware:        firmware,
 			},
 			BootImage: nil,
@@ -107,7 +103,8 @@ func (s *StepCreateVM) Run(_ context.Context, state multistep.StateBag) multiste
 	}
 
 	// Add network connection if specified
-	if s.Network != "" {
+	network := common.NetworkName(state, s.Network)
+	if network != "" {
 		ipAllocationMode := types.IPAllocationModePool
 		if s.IPAllocationMode == "DHCP" {
 			ipAllocationMode = types.IPAllocationModeDHCP
@@ -118,7 +115,7 @@ func (s *StepCreateVM) Run(_ context.Context, state multistep.StateBag) multiste
 		}
 
 		netConn := &types.NetworkConnection{
-			Network:                 s.Network,
+			Network:                 network,
 			NetworkConnectionIndex:  0,
 			IsConnected:             true,
 			IPAddressAllocationMode: ipAllocationMode,
@@ -185,7 +182,7 @@ func (s *StepCreateVM) Cleanup(state multistep.StateBag) {
 	// Power off if needed
 	if on, _ := vm.IsPoweredOn(); on {
 		ui.Say("Powering off VM...")
-		_ = vm.PowerOff()
+		_ = vm.PowerOff(context.Background())
 	}
 
 	// Delete the VM (govcd.VM.Delete() waits for task completion internally)
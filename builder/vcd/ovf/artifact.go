@@ -0,0 +1,44 @@
+package ovf
+
+import (
+	"fmt"
+
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/common"
+)
+
+// Artifact is the result of an import-only build: a vApp template in a
+// catalog, with no virtual machine attached.
+type Artifact struct {
+	Catalog   string
+	Name      string
+	StateData map[string]interface{}
+}
+
+func (a *Artifact) BuilderId() string {
+	return common.BuilderId
+}
+
+func (a *Artifact) Files() []string {
+	return nil
+}
+
+func (a *Artifact) Id() string {
+	return fmt.Sprintf("%s/%s", a.Catalog, a.Name)
+}
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("VCD vApp template: %s in catalog %s", a.Name, a.Catalog)
+}
+
+func (a *Artifact) State(name string) interface{} {
+	if a.StateData != nil {
+		return a.StateData[name]
+	}
+	return nil
+}
+
+// Destroy leaves the template in place, like the templates captured by the
+// other builders.
+func (a *Artifact) Destroy() error {
+	return nil
+}
@@ -0,0 +1,222 @@
+// Copyright 2025 Juan Font
+// BSD-3-Clause
+
+package ovf
+
+import (
+	"context"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/common"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+)
+
+type Builder struct {
+	config Config
+	runner multistep.Runner
+}
+
+// ConfigSpec returns an HCL2 object specification based on the Builder's configuration mapping.
+func (b *Builder) ConfigSpec() hcldec.ObjectSpec {
+	return b.config.FlatMapstructure().HCL2Spec()
+}
+
+// Prepare processes the given raw inputs, validates the configuration, and returns warnings or errors if any occur.
+func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
+	warnings, errs := b.config.Prepare(raws...)
+	if errs != nil {
+		return nil, warnings, errs
+	}
+
+	return common.PortForwardGeneratedVars, warnings, nil
+}
+
+// Run imports a local OVA/OVF into a catalog. When export_to_catalog is set,
+// the imported template is also instantiated, provisioned and captured.
+func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
+	state := new(multistep.BasicStateBag)
+	state.Put("debug", b.config.PackerDebug)
+	state.Put("hook", hook)
+	state.Put("ui", ui)
+
+	// Written onto the VM and onto the captured template
+	provenance := common.Provenance(&b.config.PackerConfig, map[string]string{
+		"source_path": b.config.SourcePath,
+	})
+
+	steps := []multistep.Step{
+		// Step 1: Connect to VCD
+		&common.StepConnect{
+			Config: &b.config.ConnectConfig,
+		},
+
+		// Step 2: Import OVA/OVF into the catalog
+		&StepImportOVF{
+			Config:          &b.config.ImportConfig,
+			DeleteOnSuccess: b.config.Provision() && !b.config.KeepImported,
+		},
+	}
+
+	if b.config.Provision() {
+		steps = append(steps,
+			// Step 3: Set IP in state (MANUAL mode)
+			&common.StepSetManualIP{
+				ManualIP:        b.config.LocationConfig.VMIPAddress,
+				OverrideGateway: b.config.LocationConfig.VMGateway,
+				OverrideDNS:     b.config.LocationConfig.VMDNS,
+			},
+
+			// Step 4: Resolve or create vApp
+			&common.StepResolveVApp{
+				VDCName:       b.config.LocationConfig.VDC,
+				VAppName:      b.config.LocationConfig.VApp,
+				Networks:      b.config.LocationConfig.Networks,
+				RandomNetwork: b.config.LocationConfig.NetworkSelection == "random",
+				CheckPool:     b.config.LocationConfig.IPAllocationMode == "POOL",
+				CreateVApp:    b.config.LocationConfig.CreateVApp,
+			},
+
+			// Step 5: Instantiate the imported template
+			&common.StepCloneVM{
+				TemplateCatalog:  b.config.ImportCatalog,
+				Template:         b.config.ImportName,
+				VMName:           b.config.LocationConfig.VMName,
+				StorageProfile:   b.config.LocationConfig.VMStorageProfile,
+				Network:          b.config.LocationConfig.Network,
+				IPAllocationMode: b.config.LocationConfig.IPAllocationMode,
+			},
+
+			// Step 6: Configure hardware (CPU, memory) - keeps imported size if unset
+			&common.StepHardware{
+				Config: &b.config.HardwareConfig,
+			},
+
+			// Step 7: Configure boot options (delay, EFI secure boot)
+			&common.StepConfigureBootOptions{
+				BootDelay: b.config.HardwareConfig.BootDelay,
+				Firmware:  b.config.HardwareConfig.Firmware,
+			},
+
+			// Step 8: Configure TPM (if enabled)
+			&common.StepConfigureTPM{
+				Enabled: b.config.HardwareConfig.VTPMEnabled,
+			},
+
+			// Step 9: Write the provenance and metadata entries onto the VM
+			&common.StepMetadata{
+				Config:     &b.config.MetadataConfig,
+				Provenance: provenance,
+			},
+
+			// Step 10: Set the OVF properties (if configured)
+			&common.StepOVFProperties{
+				Config: &b.config.OVFPropertiesConfig,
+				VMName: b.config.LocationConfig.VMName,
+				Ctx:    b.config.ctx,
+			},
+
+			// Step 11: Power on VM (with IP conflict retry logic)
+			&common.StepRun{
+				Config:      &b.config.RunConfig,
+				VDCName:     b.config.LocationConfig.VDC,
+				NetworkName: b.config.LocationConfig.Network,
+			},
+
+			// Step 12: Wait for VM to get IP address (for communicator)
+			&common.StepWaitForIP{
+				Config: &b.config.WaitIpConfig,
+			},
+
+			// Step 13: Forward extra ports for provisioners (if configured)
+			&common.StepPortForward{
+				Config: &b.config.PortForwardConfig,
+			},
+
+			// Step 14: Probe SSH/WinRM port (reports firewall vs service issues)
+			&common.StepProbeCommunicator{
+				Config: &b.config.Comm,
+			},
+
+			// Step 15: Connect to VM via SSH/WinRM
+			&communicator.StepConnect{
+				Config:    &b.config.Comm,
+				Host:      common.CommHost(b.config.Comm.Host()),
+				SSHConfig: b.config.Comm.SSHConfigFunc(),
+			},
+
+			// Step 16: Run provisioners
+			&commonsteps.StepProvision{},
+
+			// Step 17: Shutdown VM
+			&common.StepShutdown{
+				Config:   &b.config.ShutdownConfig,
+				CommType: b.config.Comm.Type,
+			},
+
+			// Step 18: Restore the OVF properties of the source
+			&common.StepRestoreOVFProperties{
+				Config: &b.config.OVFPropertiesConfig,
+			},
+
+			// Step 19: Eject media before the capture
+			&common.StepEjectMedia{
+				Config: b.config.ExportToCatalog,
+			},
+
+			// Step 20: Capture to catalog
+			&common.StepExportToCatalog{
+				Config:         b.config.ExportToCatalog,
+				StorageProfile: b.config.LocationConfig.CatalogStorageProfile,
+				Provenance:     provenance,
+				Metadata:       b.config.Metadata,
+			},
+		)
+	}
+
+	b.runner = commonsteps.NewRunnerWithPauseFn(steps, b.config.PackerConfig, ui, state)
+	b.runner.Run(b.config.ConnectConfig.TaskContext(ctx, ui), state)
+
+	if err := common.StateError(state); err != nil {
+		return nil, err
+	}
+
+	if !b.config.Provision() {
+		if _, ok := state.GetOk("imported_template"); !ok {
+			return nil, nil
+		}
+		return &Artifact{
+			Catalog: b.config.ImportCatalog,
+			Name:    b.config.ImportName,
+			StateData: map[string]interface{}{
+				"source_path":           b.config.SourcePath,
+				"vapp_template_catalog": b.config.ImportCatalog,
+				"vapp_template":         b.config.ImportName,
+			},
+		}, nil
+	}
+
+	if _, ok := state.GetOk("vm"); !ok {
+		return nil, nil
+	}
+
+	vm := state.Get("vm").(driver.VirtualMachine)
+	artifact := &common.Artifact{
+		Name:     b.config.LocationConfig.VMName,
+		Location: b.config.LocationConfig,
+		VM:       vm,
+		StateData: map[string]interface{}{
+			"source_path":    b.config.SourcePath,
+			"import_catalog": b.config.ImportCatalog,
+			"import_name":    b.config.ImportName,
+			"vapp_name":      state.Get("vapp_name"),
+		},
+	}
+
+	artifact.AddCapturedTemplate(state)
+
+	return artifact, nil
+}
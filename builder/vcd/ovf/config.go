@@ -0,0 +1,110 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package ovf
+
+import (
+	"fmt"
+
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/common"
+
+	packerCommon "github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+type Config struct {
+	packerCommon.PackerConfig `mapstructure:",squash"`
+
+	common.ConnectConfig     `mapstructure:",squash"`
+	ImportConfig             `mapstructure:",squash"`
+	common.LocationConfig    `mapstructure:",squash"`
+	common.HardwareConfig    `mapstructure:",squash"`
+	common.RunConfig         `mapstructure:",squash"`
+	common.WaitIpConfig      `mapstructure:",squash"`
+	common.PortForwardConfig `mapstructure:",squash"`
+	Comm                     communicator.Config `mapstructure:",squash"`
+
+	common.ShutdownConfig `mapstructure:",squash"`
+
+	common.OVFPropertiesConfig `mapstructure:",squash"`
+
+	common.MetadataConfig `mapstructure:",squash"`
+
+	// Instantiate the imported vApp template, run the provisioners and
+	// capture the result to a catalog. When this is not specified the
+	// builder only imports the OVA/OVF and no virtual machine is created.
+	ExportToCatalog *common.ExportToCatalogConfig `mapstructure:"export_to_catalog"`
+
+	ctx interpolate.Context
+}
+
+// Prepare processes and validates the configuration for importing and,
+// optionally, provisioning and capturing.
+// It returns a list of warnings and an error if validation fails.
+func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
+	err := config.Decode(c, &config.DecodeOpts{
+		PluginType:         common.BuilderId,
+		Interpolate:        true,
+		InterpolateContext: &c.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{
+				"ovf_properties",
+			},
+		},
+	}, raws...)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings := make([]string, 0)
+	errs := new(packersdk.MultiError)
+
+	errs = packersdk.MultiErrorAppend(errs, c.ConnectConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, c.ImportConfig.Prepare()...)
+
+	// The location, hardware and communicator settings only matter when the
+	// imported template is instantiated for provisioning.
+	if c.Provision() {
+		errs = packersdk.MultiErrorAppend(errs, c.LocationConfig.Prepare()...)
+		errs = packersdk.MultiErrorAppend(errs, c.HardwareConfig.Prepare()...)
+		errs = packersdk.MultiErrorAppend(errs, c.RunConfig.Prepare()...)
+		errs = packersdk.MultiErrorAppend(errs, c.WaitIpConfig.Prepare()...)
+		errs = packersdk.MultiErrorAppend(errs, c.PortForwardConfig.Prepare()...)
+		errs = packersdk.MultiErrorAppend(errs, c.MetadataConfig.Prepare()...)
+		errs = packersdk.MultiErrorAppend(errs, c.OVFPropertiesConfig.Prepare()...)
+		errs = packersdk.MultiErrorAppend(errs, c.Comm.Prepare(&c.ctx)...)
+
+		shutdownWarnings, shutdownErrs := c.ShutdownConfig.Prepare(c.Comm)
+		warnings = append(warnings, shutdownWarnings...)
+		errs = packersdk.MultiErrorAppend(errs, shutdownErrs...)
+
+		errs = packersdk.MultiErrorAppend(errs, c.ExportToCatalog.Prepare(&c.LocationConfig)...)
+
+		if c.ExportToCatalog.Catalog == c.ImportCatalog && c.ExportToCatalog.TemplateName == c.ImportName {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'export_to_catalog' must not target the imported vApp template %s", c.ImportName))
+		}
+	}
+
+	// Checked last, as it needs a valid connection and location
+	if c.Provision() && len(errs.Errors) == 0 {
+		errs = packersdk.MultiErrorAppend(errs, c.LocationConfig.CheckRemote(&c.ConnectConfig, &c.HardwareConfig)...)
+	}
+
+	if len(errs.Errors) > 0 {
+		return warnings, errs
+	}
+
+	return warnings, nil
+}
+
+// Provision reports whether the imported template is instantiated to run
+// provisioners before the final capture.
+func (c *Config) Provision() bool {
+	return c.ExportToCatalog != nil
+}
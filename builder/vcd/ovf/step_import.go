@@ -0,0 +1,184 @@
+package ovf
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+)
+
+type ImportConfig struct {
+	// Path to the local OVA file, or to the OVF descriptor. When an OVF is
+	// used, the disks and manifest it references must be in the same
+	// directory.
+	SourcePath string `mapstructure:"source_path" required:"true"`
+	// The name of the catalog to import the vApp template into.
+	ImportCatalog string `mapstructure:"import_catalog" required:"true"`
+	// The name of the imported vApp template. Defaults to the file name of
+	// `source_path` without its extension.
+	ImportName string `mapstructure:"import_name"`
+	// Description for the imported vApp template.
+	ImportDescription string `mapstructure:"import_description"`
+	// If true, replace an existing vApp template with the same name in the
+	// import catalog. The existing template is only deleted once the import
+	// under a temporary name has succeeded, then the import takes its name.
+	// Defaults to `false`.
+	ImportOverwrite bool `mapstructure:"import_overwrite"`
+	// When the imported template is instantiated to run provisioners, it is
+	// deleted after the final capture. Set this to `true` to keep it.
+	// Defaults to `false`.
+	KeepImported bool `mapstructure:"keep_imported"`
+}
+
+func (c *ImportConfig) Prepare() []error {
+	var errs []error
+
+	if c.SourcePath == "" {
+		errs = append(errs, fmt.Errorf("'source_path' is required"))
+	} else {
+		ext := strings.ToLower(filepath.Ext(c.SourcePath))
+		if ext != ".ova" && ext != ".ovf" {
+			errs = append(errs, fmt.Errorf("'source_path' must be an .ova or .ovf file"))
+		}
+		if _, err := os.Stat(c.SourcePath); err != nil {
+			errs = append(errs, fmt.Errorf("'source_path' is not accessible: %w", err))
+		}
+	}
+
+	if c.ImportCatalog == "" {
+		errs = append(errs, fmt.Errorf("'import_catalog' is required"))
+	}
+
+	if c.ImportName == "" && c.SourcePath != "" {
+		base := filepath.Base(c.SourcePath)
+		c.ImportName = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	return errs
+}
+
+// StepImportOVF uploads the source OVA/OVF into the import catalog as a vApp
+// template.
+type StepImportOVF struct {
+	Config *ImportConfig
+	// Remove the imported template once the build has finished successfully.
+	// Set when the template is only an intermediate for provisioning.
+	DeleteOnSuccess bool
+}
+
+func (s *StepImportOVF) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	d := state.Get("driver").(driver.Driver)
+
+	catalog, err := d.GetCatalog(s.Config.ImportCatalog)
+	if err != nil {
+		state.Put("error", fmt.Errorf("error getting import catalog: %w", err))
+		return multistep.ActionHalt
+	}
+
+	importName := s.Config.ImportName
+	existing, err := catalog.GetVAppTemplateByName(s.Config.ImportName)
+	if err != nil {
+		existing = nil
+	}
+	if existing != nil {
+		if !s.Config.ImportOverwrite {
+			state.Put("error", fmt.Errorf("vApp template %s already exists in catalog %s (set import_overwrite = true to replace it)",
+				s.Config.ImportName, s.Config.ImportCatalog))
+			return multistep.ActionHalt
+		}
+		// Keep the existing template until its replacement is imported
+		importName = fmt.Sprintf("%s-packer-import-%d", s.Config.ImportName, time.Now().Unix())
+	}
+
+	release, err := d.AcquireTransferSlot(ctx, func() {
+		ui.Say("Waiting for a free transfer slot (max_concurrent_transfers)...")
+	})
+	if err != nil {
+		state.Put("error", fmt.Errorf("error waiting for a transfer slot: %w", err))
+		return multistep.ActionHalt
+	}
+	defer release()
+
+	ui.Sayf("Importing %s into catalog %s as %s...", s.Config.SourcePath, s.Config.ImportCatalog, importName)
+
+	template, err := d.UploadOvf(ctx, catalog, importName, s.Config.ImportDescription, s.Config.SourcePath)
+	if err != nil {
+		state.Put("error", fmt.Errorf("error importing %s: %w", s.Config.SourcePath, err))
+		return multistep.ActionHalt
+	}
+
+	// Set before the swap, so that Cleanup removes the import if it fails
+	state.Put("imported_template", template)
+	state.Put("catalog_name", s.Config.ImportCatalog)
+
+	if existing != nil {
+		if err := s.replace(ui, existing, template); err != nil {
+			state.Put("error", err)
+			return multistep.ActionHalt
+		}
+	}
+
+	ui.Sayf("vApp template imported: %s", s.Config.ImportName)
+	return multistep.ActionContinue
+}
+
+// replace swaps the imported template in for the existing one of the same
+// name. The existing template is renamed out of the way first, and only
+// deleted once the import has taken its name.
+func (s *StepImportOVF) replace(ui packersdk.Ui, existing, imported *govcd.VAppTemplate) error {
+	name := s.Config.ImportName
+	replacedName := fmt.Sprintf("%s-packer-replaced-%d", name, time.Now().Unix())
+	ui.Sayf("Renaming existing vApp template %s to %s...", name, replacedName)
+	existing.VAppTemplate.Name = replacedName
+	if _, err := existing.Update(); err != nil {
+		return fmt.Errorf("error renaming existing vApp template %s: %w", name, err)
+	}
+
+	ui.Sayf("Renaming imported vApp template %s to %s...", imported.VAppTemplate.Name, name)
+	importName := imported.VAppTemplate.Name
+	imported.VAppTemplate.Name = name
+	if _, err := imported.Update(); err != nil {
+		// Put the existing template back; the import is deleted by Cleanup
+		imported.VAppTemplate.Name = importName
+		existing.VAppTemplate.Name = name
+		if _, rerr := existing.Update(); rerr != nil {
+			ui.Errorf("Error renaming vApp template %s back to %s: %s", replacedName, name, rerr)
+		}
+		return fmt.Errorf("error renaming imported vApp template to %s: %w", name, err)
+	}
+
+	ui.Sayf("Deleting replaced vApp template: %s", replacedName)
+	if err := existing.Delete(); err != nil {
+		ui.Errorf("Warning: failed to delete replaced vApp template %s: %s", replacedName, err)
+	}
+	return nil
+}
+
+func (s *StepImportOVF) Cleanup(state multistep.StateBag) {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	rawTemplate, ok := state.GetOk("imported_template")
+	if !ok {
+		return
+	}
+
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	if !cancelled && !halted && !s.DeleteOnSuccess {
+		return
+	}
+
+	template := rawTemplate.(*govcd.VAppTemplate)
+	ui.Sayf("Deleting imported vApp template: %s", template.VAppTemplate.Name)
+	if err := template.Delete(); err != nil {
+		ui.Errorf("Error deleting imported vApp template: %s", err)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -65,7 +66,7 @@ func main() {
 	catalogName := fmt.Sprintf("win11-test-%d", time.Now().Unix())
 	fmt.Printf("Creating catalog: %s\n", catalogName)
 
-	_, err = d.CreateCatalogWithStorageProfile(catalogName, "Test catalog", &storageProfileRef)
+	_, err = d.CreateCatalogWithStorageProfile(context.Background(), catalogName, "Test catalog", &storageProfileRef)
 	if err != nil {
 		log.Fatalf("Failed to create catalog: %v", err)
 	}
@@ -79,7 +80,7 @@ func main() {
 
 	// Upload ISO
 	fmt.Printf("Uploading ISO: %s (this may take several minutes)...\n", isoPath)
-	_, err = d.UploadMediaImage(catForUpload, "win11-test.iso", "Windows 11 test ISO", isoPath)
+	uploaded, err := d.UploadMediaImage(context.Background(), catForUpload, "win11-test.iso", "Windows 11 test ISO", isoPath)
 	if err != nil {
 		log.Fatalf("Failed to upload ISO: %v", err)
 	}
@@ -89,7 +90,7 @@ func main() {
 	vappName := fmt.Sprintf("win11-test-%d", time.Now().Unix())
 	fmt.Printf("Creating vApp: %s\n", vappName)
 
-	vapp, err := d.CreateVApp(vdc, vappName, "Windows 11 test vApp", network)
+	vapp, err := d.CreateVApp(context.Background(), vdc, vappName, "Windows 11 test vApp", network)
 	if err != nil {
 		log.Fatalf("Failed to create vApp: %v", err)
 	}
@@ -157,7 +158,7 @@ func main() {
 
 	// Enable TPM
 	fmt.Println("Enabling TPM...")
-	err = vm.SetTPM(true)
+	err = vm.SetTPM(context.Background(), true)
 	if err != nil {
 		log.Printf("Warning: Failed to enable TPM: %v", err)
 	} else {
@@ -166,7 +167,13 @@ func main() {
 
 	// Mount ISO
 	fmt.Println("Mounting ISO (waiting for media to be ready)...")
-	err = vm.InsertMedia(catalogName, "win11-test.iso")
+	media, err := d.WaitForMediaResolved(context.Background(), catForUpload, uploaded.Media.ID, 10*time.Minute, func(status string) {
+		fmt.Printf("Media status: %s\n", status)
+	})
+	if err != nil {
+		log.Fatalf("Media never became ready: %v", err)
+	}
+	err = vm.InsertMedia(context.Background(), media, 10*time.Minute)
 	if err != nil {
 		log.Fatalf("Failed to mount ISO: %v", err)
 	}
@@ -174,7 +181,7 @@ func main() {
 
 	// Power on
 	fmt.Println("Powering on VM...")
-	err = vm.PowerOn()
+	err = vm.PowerOn(context.Background())
 	if err != nil {
 		log.Fatalf("Failed to power on VM: %v", err)
 	}
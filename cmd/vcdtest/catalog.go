@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+	"github.com/spf13/cobra"
+	"github.com/vmware/go-vcloud-director/v3/types/v56"
+)
+
+var createCatalogCmd = &cobra.Command{
+	Use:   "create-catalog [name]",
+	Short: "Create a catalog backed by a VDC storage profile",
+	Args:  cobra.ExactArgs(1),
+	Run:   runCreateCatalog,
+}
+
+var catalogUsageCmd = &cobra.Command{
+	Use:   "catalog-usage [name]",
+	Short: "Show the media and vApp templates of a catalog and the storage they use",
+	Args:  cobra.ExactArgs(1),
+	Run:   runCatalogUsage,
+}
+
+var deleteCatalogCmd = &cobra.Command{
+	Use:   "delete-catalog [name]",
+	Short: "Delete a catalog and everything in it",
+	Args:  cobra.ExactArgs(1),
+	Run:   runDeleteCatalog,
+}
+
+var deleteMediaCmd = &cobra.Command{
+	Use:   "delete-media [catalog] [media...]",
+	Short: "Delete cached media (ISOs) from a catalog",
+	Args:  cobra.MinimumNArgs(2),
+	Run:   runDeleteMedia,
+}
+
+func init() {
+	createCatalogCmd.Flags().String("storage-profile", "", "Storage profile for the catalog (default: first VDC storage profile)")
+	createCatalogCmd.Flags().String("description", "Catalog created by vcdtest", "Catalog description")
+}
+
+func runCreateCatalog(cmd *cobra.Command, args []string) {
+	catalogName := args[0]
+	storageProfile, _ := cmd.Flags().GetString("storage-profile")
+	description, _ := cmd.Flags().GetString("description")
+
+	vdcName := getEnv("VCD_VDC", "PKR_VAR_vcd_vdc")
+	if vdcName == "" {
+		fmt.Println("Error: VCD_VDC (or PKR_VAR_vcd_vdc) environment variable is required")
+		os.Exit(1)
+	}
+
+	d, err := getDriver()
+	if err != nil {
+		fmt.Printf("Connection failed: %v\n", err)
+		os.Exit(1)
+	}
+	defer d.Cleanup()
+	fmt.Println("Connection successful!")
+
+	vdc, err := d.GetVdc(vdcName)
+	if err != nil {
+		fmt.Printf("Error getting VDC %s: %v\n", vdcName, err)
+		os.Exit(1)
+	}
+
+	// Resolve the storage profile the same way the builders do
+	var storageProfileRef *types.Reference
+	if storageProfile != "" {
+		ref, err := vdc.FindStorageProfileReference(storageProfile)
+		if err != nil {
+			fmt.Printf("Error finding storage profile %s: %v\n", storageProfile, err)
+			os.Exit(1)
+		}
+		storageProfileRef = &ref
+	} else if vdc.Vdc.VdcStorageProfiles != nil && len(vdc.Vdc.VdcStorageProfiles.VdcStorageProfile) > 0 {
+		storageProfileRef = vdc.Vdc.VdcStorageProfiles.VdcStorageProfile[0]
+	} else {
+		fmt.Println("Error: No storage profiles found in VDC")
+		os.Exit(1)
+	}
+
+	fmt.Printf("Creating catalog: %s (storage profile: %s)...\n", catalogName, storageProfileRef.Name)
+	adminCatalog, err := d.CreateCatalogWithStorageProfile(context.Background(), catalogName, description, storageProfileRef)
+	if err != nil {
+		fmt.Printf("Error creating catalog: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Catalog created: %s\n", adminCatalog.AdminCatalog.Name)
+	fmt.Printf("  ID: %s\n", adminCatalog.AdminCatalog.ID)
+	printCatalogStorageProfiles(adminCatalog.AdminCatalog.CatalogStorageProfiles)
+
+	fmt.Printf("\nUsage in template:\n")
+	fmt.Printf("  iso_catalog = \"%s\"\n", catalogName)
+}
+
+func runCatalogUsage(cmd *cobra.Command, args []string) {
+	catalogName := args[0]
+
+	d, err := getDriver()
+	if err != nil {
+		fmt.Printf("Connection failed: %v\n", err)
+		os.Exit(1)
+	}
+	defer d.Cleanup()
+	fmt.Println("Connection successful!")
+
+	adminOrg, err := d.GetAdminOrg()
+	if err != nil {
+		fmt.Printf("Error getting admin org: %v\n", err)
+		os.Exit(1)
+	}
+
+	adminCatalog, err := adminOrg.GetAdminCatalogByName(catalogName, true)
+	if err != nil {
+		fmt.Printf("Catalog not found: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Catalog: %s\n", catalogName)
+	printCatalogStorageProfiles(adminCatalog.AdminCatalog.CatalogStorageProfiles)
+
+	catalog, err := d.GetCatalog(catalogName)
+	if err != nil {
+		fmt.Printf("Error getting catalog: %v\n", err)
+		os.Exit(1)
+	}
+
+	media, err := catalog.QueryMediaList()
+	if err != nil {
+		fmt.Printf("Error listing media: %v\n", err)
+		os.Exit(1)
+	}
+
+	var total int64
+	fmt.Printf("\nMedia (%d):\n", len(media))
+	for _, m := range media {
+		fmt.Printf("  - %s  %s  %s  %s\n", m.Name, formatBytes(m.StorageB), m.Status, m.CreationDate)
+		total += m.StorageB
+	}
+
+	templates, err := d.ListVAppTemplates(catalogName, driver.VAppTemplateFilter{})
+	if err != nil {
+		fmt.Printf("Error listing vApp templates: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\nvApp templates (%d):\n", len(templates))
+	for _, t := range templates {
+		fmt.Printf("  - %s  %s  %s\n", t.Name, formatBytes(t.SizeBytes), t.Created.Format("2006-01-02 15:04:05"))
+		total += t.SizeBytes
+	}
+
+	fmt.Printf("\nTotal: %s\n", formatBytes(total))
+}
+
+func runDeleteCatalog(cmd *cobra.Command, args []string) {
+	catalogName := args[0]
+
+	d, err := getDriver()
+	if err != nil {
+		fmt.Printf("Connection failed: %v\n", err)
+		os.Exit(1)
+	}
+	defer d.Cleanup()
+	fmt.Println("Connection successful!")
+
+	adminOrg, err := d.GetAdminOrg()
+	if err != nil {
+		fmt.Printf("Error getting admin org: %v\n", err)
+		os.Exit(1)
+	}
+
+	adminCatalog, err := adminOrg.GetAdminCatalogByName(catalogName, true)
+	if err != nil {
+		fmt.Printf("Catalog not found: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Deleting catalog %s (force=true, recursive=true)...\n", catalogName)
+	if err := d.DeleteCatalog(context.Background(), adminCatalog); err != nil {
+		fmt.Printf("Error deleting catalog: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Catalog '%s' deleted successfully!\n", catalogName)
+}
+
+func runDeleteMedia(cmd *cobra.Command, args []string) {
+	catalogName := args[0]
+	mediaNames := args[1:]
+
+	d, err := getDriver()
+	if err != nil {
+		fmt.Printf("Connection failed: %v\n", err)
+		os.Exit(1)
+	}
+	defer d.Cleanup()
+	fmt.Println("Connection successful!")
+
+	catalog, err := d.GetCatalog(catalogName)
+	if err != nil {
+		fmt.Printf("Error getting catalog: %v\n", err)
+		os.Exit(1)
+	}
+
+	hasErrors := false
+	for _, mediaName := range mediaNames {
+		fmt.Printf("Deleting media: %s\n", mediaName)
+
+		media, err := catalog.GetMediaByName(mediaName, true)
+		if err != nil {
+			fmt.Printf("  Media not found: %v\n", err)
+			hasErrors = true
+			continue
+		}
+
+		task, err := media.Delete()
+		if err != nil {
+			fmt.Printf("  Error deleting media: %v\n", err)
+			hasErrors = true
+			continue
+		}
+		if err := task.WaitTaskCompletion(); err != nil {
+			fmt.Printf("  Error waiting for media deletion: %v\n", err)
+			hasErrors = true
+			continue
+		}
+		fmt.Printf("  Media '%s' deleted.\n", mediaName)
+	}
+
+	if hasErrors {
+		os.Exit(1)
+	}
+}
+
+func printCatalogStorageProfiles(profiles *types.CatalogStorageProfiles) {
+	if profiles == nil || len(profiles.VdcStorageProfile) == 0 {
+		fmt.Println("  Storage profile: (none, VCD picks one per item)")
+		return
+	}
+	for _, sp := range profiles.VdcStorageProfile {
+		fmt.Printf("  Storage profile: %s\n", sp.Name)
+	}
+}
+
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
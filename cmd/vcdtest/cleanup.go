@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -62,64 +63,12 @@ func runCleanup(cmd *cobra.Command, args []string) {
 			continue
 		}
 
-		// Refresh to get current state
-		if err := vapp.Refresh(); err != nil {
-			fmt.Printf("  Error refreshing vApp state: %v\n", err)
-			hasErrors = true
-			continue
-		}
-
-		// Check status and power off if needed
-		status, err := vapp.GetStatus()
-		if err != nil {
-			fmt.Printf("  Error getting vApp status: %v\n", err)
-		} else {
-			fmt.Printf("  Current status: %s\n", status)
-		}
-
-		if status != "POWERED_OFF" && status != "RESOLVED" {
-			fmt.Printf("  Powering off vApp...\n")
-			task, err := vapp.PowerOff()
-			if err != nil {
-				fmt.Printf("  Note: power off returned: %v\n", err)
-			} else {
-				if err := task.WaitTaskCompletion(); err != nil {
-					fmt.Printf("  Error waiting for power off: %v\n", err)
-				} else {
-					fmt.Printf("  Powered off.\n")
-				}
-			}
-		}
-
-		// Undeploy if needed
-		if err := vapp.Refresh(); err == nil {
-			status, _ := vapp.GetStatus()
-			if status != "RESOLVED" {
-				fmt.Printf("  Undeploying vApp...\n")
-				task, err := vapp.Undeploy()
-				if err != nil {
-					fmt.Printf("  Note: undeploy returned: %v\n", err)
-				} else {
-					if err := task.WaitTaskCompletion(); err != nil {
-						fmt.Printf("  Error waiting for undeploy: %v\n", err)
-					} else {
-						fmt.Printf("  Undeployed.\n")
-					}
-				}
-			}
-		}
-
-		// Delete
-		fmt.Printf("  Deleting vApp...\n")
-		task, err := vapp.Delete()
+		err = d.ForceDeleteVApp(context.Background(), vapp, func(step string) {
+			fmt.Printf("  %s...\n", step)
+		})
 		if err != nil {
 			fmt.Printf("  Error deleting vApp: %v\n", err)
 			hasErrors = true
-			continue
-		}
-		if err := task.WaitTaskCompletion(); err != nil {
-			fmt.Printf("  Error waiting for vApp deletion: %v\n", err)
-			hasErrors = true
 		} else {
 			fmt.Printf("  vApp '%s' deleted successfully!\n", vappName)
 		}
@@ -145,7 +94,7 @@ func runCleanup(cmd *cobra.Command, args []string) {
 		}
 
 		fmt.Printf("  Deleting catalog (force=true, recursive=true)...\n")
-		err = d.DeleteCatalog(adminCatalog)
+		err = d.DeleteCatalog(context.Background(), adminCatalog)
 		if err != nil {
 			fmt.Printf("  Error deleting catalog: %v\n", err)
 			hasErrors = true
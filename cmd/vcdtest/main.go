@@ -2,6 +2,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -52,6 +53,13 @@ var consoleTestCmd = &cobra.Command{
 	Run:   runConsoleTest,
 }
 
+var screenshotCmd = &cobra.Command{
+	Use:   "screenshot [vm-href]",
+	Short: "Save a PNG screenshot of the VM console",
+	Args:  cobra.ExactArgs(1),
+	Run:   runScreenshot,
+}
+
 var debugIPCmd = &cobra.Command{
 	Use:   "debug-ip",
 	Short: "Debug IP discovery - show pool ranges and used IPs",
@@ -78,14 +86,23 @@ func init() {
 	rootCmd.AddCommand(createVMCmd)
 	rootCmd.AddCommand(fullTestCmd)
 	rootCmd.AddCommand(consoleTestCmd)
+	rootCmd.AddCommand(screenshotCmd)
 	rootCmd.AddCommand(debugIPCmd)
 	rootCmd.AddCommand(listSizingPoliciesCmd)
 	rootCmd.AddCommand(cleanupCmd)
+	rootCmd.AddCommand(createCatalogCmd)
+	rootCmd.AddCommand(catalogUsageCmd)
+	rootCmd.AddCommand(deleteCatalogCmd)
+	rootCmd.AddCommand(deleteMediaCmd)
+	rootCmd.AddCommand(profilesCmd)
 
 	// Flags for console-test
 	consoleTestCmd.Flags().String("text", "hello", "Text to type via console")
 	consoleTestCmd.Flags().Bool("enter", false, "Press Enter after text")
 
+	// Flags for screenshot
+	screenshotCmd.Flags().String("output", "screenshot.png", "PNG file to write")
+
 	// Flags for create-vm
 	createVMCmd.Flags().String("catalog", "", "Catalog containing the ISO")
 	createVMCmd.Flags().String("iso", "debian-12.12.0-amd64-netinst.iso", "ISO media name")
@@ -188,7 +205,7 @@ func runUploadISO(cmd *cobra.Command, args []string) {
 	tempCatalogName := fmt.Sprintf("packer-iso-test-%d", time.Now().Unix())
 	fmt.Printf("\nCreating temporary catalog: %s (with storage profile: %s)...\n", tempCatalogName, storageProfileRef.Name)
 
-	adminCatalog, err := d.CreateCatalogWithStorageProfile(tempCatalogName, "Temporary catalog for ISO upload test", storageProfileRef)
+	adminCatalog, err := d.CreateCatalogWithStorageProfile(context.Background(), tempCatalogName, "Temporary catalog for ISO upload test", storageProfileRef)
 	if err != nil {
 		fmt.Printf("Error creating catalog: %v\n", err)
 		os.Exit(1)
@@ -209,7 +226,7 @@ func runUploadISO(cmd *cobra.Command, args []string) {
 	catalog, err := d.GetCatalog(tempCatalogName)
 	if err != nil {
 		fmt.Printf("Error getting catalog: %v\n", err)
-		_ = d.DeleteCatalog(adminCatalog)
+		_ = d.DeleteCatalog(context.Background(), adminCatalog)
 		os.Exit(1)
 	}
 
@@ -219,11 +236,11 @@ func runUploadISO(cmd *cobra.Command, args []string) {
 	fmt.Println("This may take several minutes...")
 
 	startTime := time.Now()
-	media, err := d.UploadMediaImage(catalog, mediaName, "Debian 12.12.0 netinst ISO", isoPath)
+	media, err := d.UploadMediaImage(context.Background(), catalog, mediaName, "Debian 12.12.0 netinst ISO", isoPath)
 	if err != nil {
 		fmt.Printf("Error uploading ISO: %v\n", err)
 		fmt.Println("Cleaning up catalog...")
-		_ = d.DeleteCatalog(adminCatalog)
+		_ = d.DeleteCatalog(context.Background(), adminCatalog)
 		os.Exit(1)
 	}
 
@@ -238,7 +255,7 @@ func runUploadISO(cmd *cobra.Command, args []string) {
 	fmt.Scanln(&answer)
 	if strings.ToLower(answer) == "y" {
 		fmt.Println("Deleting catalog...")
-		err = d.DeleteCatalog(adminCatalog)
+		err = d.DeleteCatalog(context.Background(), adminCatalog)
 		if err != nil {
 			fmt.Printf("Error deleting catalog: %v\n", err)
 		} else {
@@ -287,7 +304,7 @@ func runTest(cmd *cobra.Command, args []string) {
 
 	// List existing catalogs
 	fmt.Println("\nListing existing catalogs...")
-	catalogs, err := vcdOrg.QueryCatalogList()
+	catalogs, err := d.ListCatalogs()
 	if err != nil {
 		fmt.Printf("Error listing catalogs: %v\n", err)
 	} else {
@@ -382,7 +399,7 @@ func runCreateVM(cmd *cobra.Command, args []string) {
 
 	// Create vApp
 	fmt.Printf("\nCreating vApp: %s\n", vappName)
-	vapp, err := d.CreateVApp(vdc, vappName, "Packer test vApp", networkName)
+	vapp, err := d.CreateVApp(context.Background(), vdc, vappName, "Packer test vApp", networkName)
 	if err != nil {
 		fmt.Printf("Error creating vApp: %v\n", err)
 		os.Exit(1)
@@ -572,7 +589,7 @@ func runFullTest(cmd *cobra.Command, args []string) {
 	tempCatalogName := fmt.Sprintf("packer-test-%d", time.Now().Unix())
 	fmt.Printf("\nCreating catalog '%s' with VDC storage profile...\n", tempCatalogName)
 
-	adminCatalog, err := d.CreateCatalogWithStorageProfile(tempCatalogName, "Packer test catalog", storageProfileRef)
+	adminCatalog, err := d.CreateCatalogWithStorageProfile(context.Background(), tempCatalogName, "Packer test catalog", storageProfileRef)
 	if err != nil {
 		fmt.Printf("Error creating catalog: %v\n", err)
 		os.Exit(1)
@@ -583,7 +600,7 @@ func runFullTest(cmd *cobra.Command, args []string) {
 	catalog, err := d.GetCatalog(tempCatalogName)
 	if err != nil {
 		fmt.Printf("Error getting catalog: %v\n", err)
-		_ = d.DeleteCatalog(adminCatalog)
+		_ = d.DeleteCatalog(context.Background(), adminCatalog)
 		os.Exit(1)
 	}
 
@@ -593,11 +610,11 @@ func runFullTest(cmd *cobra.Command, args []string) {
 	fmt.Println("This may take several minutes...")
 
 	startTime := time.Now()
-	media, err := d.UploadMediaImage(catalog, mediaName, "Debian netinst ISO", isoPath)
+	media, err := d.UploadMediaImage(context.Background(), catalog, mediaName, "Debian netinst ISO", isoPath)
 	if err != nil {
 		fmt.Printf("Error uploading ISO: %v\n", err)
 		fmt.Println("Cleaning up catalog...")
-		_ = d.DeleteCatalog(adminCatalog)
+		_ = d.DeleteCatalog(context.Background(), adminCatalog)
 		os.Exit(1)
 	}
 	elapsed := time.Since(startTime)
@@ -616,11 +633,11 @@ func runFullTest(cmd *cobra.Command, args []string) {
 	// Create vApp
 	vappName := fmt.Sprintf("packer-vapp-%d", time.Now().Unix())
 	fmt.Printf("\nCreating vApp: %s\n", vappName)
-	vapp, err := d.CreateVApp(vdc, vappName, "Packer test vApp", networkName)
+	vapp, err := d.CreateVApp(context.Background(), vdc, vappName, "Packer test vApp", networkName)
 	if err != nil {
 		fmt.Printf("Error creating vApp: %v\n", err)
 		fmt.Println("Cleaning up...")
-		_ = d.DeleteCatalog(adminCatalog)
+		_ = d.DeleteCatalog(context.Background(), adminCatalog)
 		os.Exit(1)
 	}
 	fmt.Printf("vApp created: %s\n", vapp.VApp.Name)
@@ -685,7 +702,7 @@ func runFullTest(cmd *cobra.Command, args []string) {
 		fmt.Printf("Error creating VM: %v\n", err)
 		fmt.Println("Cleaning up...")
 		vapp.Delete()
-		_ = d.DeleteCatalog(adminCatalog)
+		_ = d.DeleteCatalog(context.Background(), adminCatalog)
 		os.Exit(1)
 	}
 	fmt.Printf("VM created: %s\n", vm.VM.Name)
@@ -757,7 +774,7 @@ func runFullTest(cmd *cobra.Command, args []string) {
 		}
 
 		fmt.Println("Deleting catalog...")
-		err = d.DeleteCatalog(adminCatalog)
+		err = d.DeleteCatalog(context.Background(), adminCatalog)
 		if err != nil {
 			fmt.Printf("Error deleting catalog: %v\n", err)
 		} else {
@@ -834,6 +851,44 @@ func runConsoleTest(cmd *cobra.Command, args []string) {
 	fmt.Println("\nConsole test complete!")
 }
 
+func runScreenshot(cmd *cobra.Command, args []string) {
+	vmHref := args[0]
+	output, _ := cmd.Flags().GetString("output")
+
+	d, err := getDriver()
+	if err != nil {
+		fmt.Printf("Connection failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Acquiring MKS ticket for VM: %s\n", vmHref)
+	ticket, err := driver.AcquireMksTicketDirect(d.GetClient(), vmHref)
+	if err != nil {
+		fmt.Printf("Error acquiring MKS ticket: %v\n", err)
+		os.Exit(1)
+	}
+
+	wmks := driver.NewWMKSClient(ticket, driver.WithInsecure(true))
+	if err := wmks.Connect(); err != nil {
+		fmt.Printf("Error connecting to console: %v\n", err)
+		os.Exit(1)
+	}
+	defer wmks.Close()
+
+	img, err := wmks.Screenshot(30 * time.Second)
+	if err != nil {
+		fmt.Printf("Error taking screenshot: %v\n", err)
+		os.Exit(1)
+	}
+	if err := driver.WritePNG(output, img); err != nil {
+		fmt.Printf("Error saving screenshot: %v\n", err)
+		os.Exit(1)
+	}
+
+	bounds := img.Bounds()
+	fmt.Printf("Screenshot (%dx%d) saved to %s\n", bounds.Dx(), bounds.Dy(), output)
+}
+
 func runDebugIP(cmd *cobra.Command, args []string) {
 	vdcName := viper.GetString("VCD_VDC")
 	networkName := viper.GetString("VCD_NETWORK")
@@ -890,10 +945,13 @@ func runDebugIP(cmd *cobra.Command, args []string) {
 
 	// Check IPs in use by VMs
 	fmt.Printf("\nIPs in use by VMs in VDC:\n")
-	vappRefs := vdc.GetVappList()
+	vappRecords, err := d.ListVApps(vdc)
+	if err != nil {
+		fmt.Printf("Error listing vApps: %v\n", err)
+	}
 	usedCount := 0
-	for _, vappRef := range vappRefs {
-		vapp, err := vdc.GetVAppByName(vappRef.Name, true)
+	for _, vappRecord := range vappRecords {
+		vapp, err := vdc.GetVAppByHref(vappRecord.HREF)
 		if err != nil {
 			continue
 		}
@@ -907,7 +965,7 @@ func runDebugIP(cmd *cobra.Command, args []string) {
 			for _, conn := range vmRef.NetworkConnectionSection.NetworkConnection {
 				if conn.IPAddress != "" {
 					fmt.Printf("  %s (VM: %s, vApp: %s, Network: %s)\n",
-						conn.IPAddress, vmRef.Name, vappRef.Name, conn.Network)
+						conn.IPAddress, vmRef.Name, vappRecord.Name, conn.Network)
 					usedCount++
 				}
 			}
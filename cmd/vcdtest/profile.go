@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Profiles hold the settings otherwise read from the environment, under
+// the same names, so several VCD endpoints and orgs can be used without
+// exporting variables between commands:
+//
+//	profiles:
+//	  lab:
+//	    VCD_HOST: vcd.lab.example.com
+//	    VCD_ORG: packer
+//	    VCD_USERNAME: admin
+//	    VCD_PASSWORD: secret
+//	    VCD_VDC: lab-vdc
+var profilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "List the connection profiles of the profiles file",
+	Run:   runProfiles,
+}
+
+func init() {
+	rootCmd.PersistentFlags().String("profile", "", "Connection profile to use (default: $VCDTEST_PROFILE)")
+	rootCmd.PersistentFlags().String("profiles-file", "", "Profiles file (default: $VCDTEST_PROFILES_FILE or <user config dir>/vcdtest/profiles.yaml)")
+	rootCmd.PersistentPreRunE = loadProfile
+}
+
+// profilesFile returns the path of the profiles file.
+func profilesFile(cmd *cobra.Command) (string, error) {
+	if path, _ := cmd.Flags().GetString("profiles-file"); path != "" {
+		return path, nil
+	}
+	if path := os.Getenv("VCDTEST_PROFILES_FILE"); path != "" {
+		return path, nil
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the user config directory, use --profiles-file: %w", err)
+	}
+	return filepath.Join(configDir, "vcdtest", "profiles.yaml"), nil
+}
+
+// readProfiles reads the profiles file.
+func readProfiles(path string) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read profiles file %s: %w", path, err)
+	}
+
+	// Profiles usually hold passwords
+	if fi, err := os.Stat(path); err == nil && fi.Mode().Perm()&0077 != 0 {
+		fmt.Fprintf(os.Stderr, "Warning: profiles file %s is readable by other users\n", path)
+	}
+	return v, nil
+}
+
+// loadProfile applies the settings of the selected profile. They take
+// precedence over the environment and the .env file, so variables left
+// exported for another cloud do not leak into the profile.
+func loadProfile(cmd *cobra.Command, args []string) error {
+	name, _ := cmd.Flags().GetString("profile")
+	if name == "" {
+		name = os.Getenv("VCDTEST_PROFILE")
+	}
+	if name == "" {
+		return nil
+	}
+
+	path, err := profilesFile(cmd)
+	if err != nil {
+		return err
+	}
+	profiles, err := readProfiles(path)
+	if err != nil {
+		return err
+	}
+
+	profile := profiles.Sub("profiles." + name)
+	if profile == nil {
+		return fmt.Errorf("profile %q not found in %s", name, path)
+	}
+	for _, key := range profile.AllKeys() {
+		viper.Set(key, profile.GetString(key))
+	}
+
+	fmt.Printf("Using profile: %s\n", name)
+	return nil
+}
+
+func runProfiles(cmd *cobra.Command, args []string) {
+	path, err := profilesFile(cmd)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	profiles, err := readProfiles(path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	names := make([]string, 0)
+	for name := range profiles.GetStringMap("profiles") {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		fmt.Printf("No profiles in %s\n", path)
+		return
+	}
+
+	fmt.Printf("Profiles in %s:\n", path)
+	for _, name := range names {
+		profile := profiles.Sub("profiles." + name)
+		if profile == nil {
+			continue
+		}
+		fmt.Printf("  - %s (host: %s, org: %s)\n", name, profile.GetString("VCD_HOST"), profile.GetString("VCD_ORG"))
+	}
+}
@@ -0,0 +1,146 @@
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,DatasourceOutput,IPRange
+
+package network
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	vcdcommon "github.com/juanfont/packer-plugin-vcd/builder/vcd/common"
+	"github.com/zclconf/go-cty/cty"
+)
+
+type Config struct {
+	vcdcommon.ConnectConfig `mapstructure:",squash"`
+
+	// The VDC the network belongs to.
+	VDC string `mapstructure:"vdc" required:"true"`
+	// The name of the org VDC network.
+	Name string `mapstructure:"name" required:"true"`
+}
+
+// IPRange is an inclusive range of addresses of the static IP pool.
+type IPRange struct {
+	// The first address of the range.
+	StartAddress string `mapstructure:"start_address"`
+	// The last address of the range.
+	EndAddress string `mapstructure:"end_address"`
+}
+
+type DatasourceOutput struct {
+	// The gateway of the network.
+	Gateway string `mapstructure:"gateway"`
+	// The network mask, e.g. `255.255.255.0`.
+	Netmask string `mapstructure:"netmask"`
+	// The prefix length of the network, e.g. `24`.
+	PrefixLength int `mapstructure:"prefix_length"`
+	// The primary DNS server.
+	DNS1 string `mapstructure:"dns1"`
+	// The secondary DNS server.
+	DNS2 string `mapstructure:"dns2"`
+	// The DNS suffix.
+	DNSSuffix string `mapstructure:"dns_suffix"`
+	// The ranges of the static IP pool.
+	StaticIPPool []IPRange `mapstructure:"static_ip_pool"`
+}
+
+// Datasource resolves an org VDC network by name and exposes its IP
+// configuration, so templates do not have to hardcode it.
+type Datasource struct {
+	config Config
+}
+
+func (d *Datasource) ConfigSpec() hcldec.ObjectSpec {
+	return d.config.FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Configure(raws ...interface{}) error {
+	err := config.Decode(&d.config, nil, raws...)
+	if err != nil {
+		return err
+	}
+
+	errs := new(packersdk.MultiError)
+	errs = packersdk.MultiErrorAppend(errs, d.config.ConnectConfig.Prepare()...)
+
+	if d.config.VDC == "" {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'vdc' is required"))
+	}
+	if d.config.Name == "" {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'name' is required"))
+	}
+
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (d *Datasource) OutputSpec() hcldec.ObjectSpec {
+	return (&DatasourceOutput{}).FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Execute() (cty.Value, error) {
+	drv, err := d.config.ConnectConfig.Connect()
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), err
+	}
+	defer func() {
+		if err := drv.Cleanup(); err != nil {
+			log.Printf("[WARN] Failed to close VCD client session: %s", err)
+		}
+	}()
+
+	vdc, err := drv.GetVdc(d.config.VDC)
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), err
+	}
+
+	info, err := drv.GetNetworkInfo(vdc, d.config.Name)
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), err
+	}
+
+	output := DatasourceOutput{
+		Gateway:      info.Gateway,
+		Netmask:      info.Netmask,
+		PrefixLength: prefixLength(info.Netmask),
+		DNS1:         info.DNS1,
+		DNS2:         info.DNS2,
+		DNSSuffix:    info.DNSSuffix,
+	}
+	for _, r := range info.IPRanges {
+		output.StaticIPPool = append(output.StaticIPPool, IPRange{
+			StartAddress: r.Start,
+			EndAddress:   r.End,
+		})
+	}
+
+	return hcl2helper.HCL2ValueFromConfig(output, d.OutputSpec()), nil
+}
+
+// prefixLength converts a dotted netmask to a prefix length, or 0 if the
+// netmask is not valid.
+func prefixLength(netmask string) int {
+	ip := net.ParseIP(netmask).To4()
+	if ip == nil {
+		// Some networks report the prefix length directly
+		if n, err := strconv.Atoi(netmask); err == nil {
+			return n
+		}
+		return 0
+	}
+	ones, bits := net.IPMask(ip).Size()
+	if bits == 0 {
+		return 0
+	}
+	return ones
+}
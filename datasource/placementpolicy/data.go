@@ -0,0 +1,169 @@
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,DatasourceOutput,PlacementPolicy
+
+package placementpolicy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	vcdcommon "github.com/juanfont/packer-plugin-vcd/builder/vcd/common"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+	"github.com/zclconf/go-cty/cty"
+)
+
+type Config struct {
+	vcdcommon.ConnectConfig `mapstructure:",squash"`
+
+	// The VDC the placement policies are assigned to.
+	VDC string `mapstructure:"vdc" required:"true"`
+	// The name of the placement policy to look up. The data source fails if
+	// no such policy is assigned to the VDC. If empty, only `policies` is set.
+	Name string `mapstructure:"name"`
+}
+
+// PlacementPolicy describes a VM placement policy assigned to the VDC.
+type PlacementPolicy struct {
+	// The URN of the policy, e.g. `urn:vcloud:vdcComputePolicy:...`.
+	ID string `mapstructure:"id"`
+	// The name of the policy.
+	Name string `mapstructure:"name"`
+	// The description of the policy.
+	Description string `mapstructure:"description"`
+	// The names of the VM groups the policy places VMs on.
+	VMGroups []string `mapstructure:"vm_groups"`
+}
+
+type DatasourceOutput struct {
+	// The URN of the policy named by `name`, for `vm_placement_policy`.
+	ID string `mapstructure:"id"`
+	// The name of the policy named by `name`.
+	Name string `mapstructure:"name"`
+	// The description of the policy named by `name`.
+	Description string `mapstructure:"description"`
+	// The names of the VM groups of the policy named by `name`.
+	VMGroups []string `mapstructure:"vm_groups"`
+	// All VM placement policies assigned to the VDC, sorted by name.
+	Policies []PlacementPolicy `mapstructure:"policies"`
+}
+
+// Datasource looks up the VM placement policies assigned to a VDC, returning
+// IDs usable by `vm_placement_policy`.
+type Datasource struct {
+	config Config
+}
+
+func (d *Datasource) ConfigSpec() hcldec.ObjectSpec {
+	return d.config.FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Configure(raws ...interface{}) error {
+	err := config.Decode(&d.config, nil, raws...)
+	if err != nil {
+		return err
+	}
+
+	errs := new(packersdk.MultiError)
+	errs = packersdk.MultiErrorAppend(errs, d.config.ConnectConfig.Prepare()...)
+
+	if d.config.VDC == "" {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'vdc' is required"))
+	}
+
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (d *Datasource) OutputSpec() hcldec.ObjectSpec {
+	return (&DatasourceOutput{}).FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Execute() (cty.Value, error) {
+	drv, err := d.config.ConnectConfig.Connect()
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), err
+	}
+	defer func() {
+		if err := drv.Cleanup(); err != nil {
+			log.Printf("[WARN] Failed to close VCD client session: %s", err)
+		}
+	}()
+
+	vdc, err := drv.GetVdc(d.config.VDC)
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), err
+	}
+
+	assigned, err := drv.GetClient().GetAllAssignedVdcComputePoliciesV2(vdc.Vdc.ID, url.Values{})
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), fmt.Errorf("error getting placement policies: %w", err)
+	}
+
+	var policies []*govcd.VdcComputePolicyV2
+	for _, p := range assigned {
+		if driver.IsVMPlacementPolicy(p) {
+			policies = append(policies, p)
+		}
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].VdcComputePolicyV2.Name < policies[j].VdcComputePolicyV2.Name
+	})
+
+	var output DatasourceOutput
+	var names []string
+	for _, p := range policies {
+		output.Policies = append(output.Policies, toPlacementPolicy(p))
+		names = append(names, p.VdcComputePolicyV2.Name)
+	}
+
+	if d.config.Name != "" {
+		policy, err := driver.GetVMPlacementPolicy(policies, d.config.Name)
+		if errors.Is(err, driver.ErrPlacementPolicyNotFound) {
+			return cty.NullVal(cty.EmptyObject), fmt.Errorf("VM placement policy '%s' not found in VDC %s (available: %s)",
+				d.config.Name, d.config.VDC, strings.Join(names, ", "))
+		}
+		if err != nil {
+			return cty.NullVal(cty.EmptyObject), err
+		}
+
+		pp := toPlacementPolicy(policy)
+		output.ID = pp.ID
+		output.Name = pp.Name
+		output.Description = pp.Description
+		output.VMGroups = pp.VMGroups
+	}
+
+	return hcl2helper.HCL2ValueFromConfig(output, d.OutputSpec()), nil
+}
+
+func toPlacementPolicy(p *govcd.VdcComputePolicyV2) PlacementPolicy {
+	policy := p.VdcComputePolicyV2
+	pp := PlacementPolicy{
+		ID:   policy.ID,
+		Name: policy.Name,
+	}
+	if policy.Description != nil {
+		pp.Description = *policy.Description
+	}
+	for _, groups := range policy.NamedVMGroups {
+		for _, group := range groups {
+			pp.VMGroups = append(pp.VMGroups, group.Name)
+		}
+	}
+	for _, group := range policy.LogicalVMGroupReferences {
+		pp.VMGroups = append(pp.VMGroups, group.Name)
+	}
+	return pp
+}
@@ -0,0 +1,181 @@
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,DatasourceOutput,SizingPolicy
+
+package sizingpolicy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	vcdcommon "github.com/juanfont/packer-plugin-vcd/builder/vcd/common"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+	"github.com/zclconf/go-cty/cty"
+)
+
+type Config struct {
+	vcdcommon.ConnectConfig `mapstructure:",squash"`
+
+	// The VDC the sizing policies are assigned to.
+	VDC string `mapstructure:"vdc" required:"true"`
+	// The name of the sizing policy to look up. The data source fails if no
+	// such policy is assigned to the VDC. If empty, only `policies` is set.
+	Name string `mapstructure:"name"`
+}
+
+// SizingPolicy describes a VM sizing policy assigned to the VDC.
+type SizingPolicy struct {
+	// The URN of the policy, e.g. `urn:vcloud:vdcComputePolicy:...`.
+	ID string `mapstructure:"id"`
+	// The name of the policy.
+	Name string `mapstructure:"name"`
+	// The description of the policy.
+	Description string `mapstructure:"description"`
+	// The number of virtual CPUs, or `0` if the policy does not set it.
+	CPUCount int `mapstructure:"cpu_count"`
+	// The number of cores per socket, or `0` if the policy does not set it.
+	CoresPerSocket int `mapstructure:"cores_per_socket"`
+	// The memory in MB, or `0` if the policy does not set it.
+	MemoryMB int `mapstructure:"memory_mb"`
+}
+
+type DatasourceOutput struct {
+	// The URN of the policy named by `name`.
+	ID string `mapstructure:"id"`
+	// The name of the policy named by `name`.
+	Name string `mapstructure:"name"`
+	// The description of the policy named by `name`.
+	Description string `mapstructure:"description"`
+	// The number of virtual CPUs of the policy named by `name`.
+	CPUCount int `mapstructure:"cpu_count"`
+	// The number of cores per socket of the policy named by `name`.
+	CoresPerSocket int `mapstructure:"cores_per_socket"`
+	// The memory in MB of the policy named by `name`.
+	MemoryMB int `mapstructure:"memory_mb"`
+	// All VM sizing policies assigned to the VDC, sorted by name.
+	Policies []SizingPolicy `mapstructure:"policies"`
+}
+
+// Datasource looks up the VM sizing policies assigned to a VDC, so
+// `vm_sizing_policy` can be validated and selected at template evaluation.
+type Datasource struct {
+	config Config
+}
+
+func (d *Datasource) ConfigSpec() hcldec.ObjectSpec {
+	return d.config.FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Configure(raws ...interface{}) error {
+	err := config.Decode(&d.config, nil, raws...)
+	if err != nil {
+		return err
+	}
+
+	errs := new(packersdk.MultiError)
+	errs = packersdk.MultiErrorAppend(errs, d.config.ConnectConfig.Prepare()...)
+
+	if d.config.VDC == "" {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'vdc' is required"))
+	}
+
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (d *Datasource) OutputSpec() hcldec.ObjectSpec {
+	return (&DatasourceOutput{}).FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Execute() (cty.Value, error) {
+	drv, err := d.config.ConnectConfig.Connect()
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), err
+	}
+	defer func() {
+		if err := drv.Cleanup(); err != nil {
+			log.Printf("[WARN] Failed to close VCD client session: %s", err)
+		}
+	}()
+
+	vdc, err := drv.GetVdc(d.config.VDC)
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), err
+	}
+
+	assigned, err := drv.GetClient().GetAllAssignedVdcComputePoliciesV2(vdc.Vdc.ID, url.Values{})
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), fmt.Errorf("error getting sizing policies: %w", err)
+	}
+
+	// Kubernetes policies are assigned to the VDC too but cannot size a VM
+	var policies []*govcd.VdcComputePolicyV2
+	for _, p := range assigned {
+		if p.VdcComputePolicyV2.PolicyType == "VdcVmPolicy" {
+			policies = append(policies, p)
+		}
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].VdcComputePolicyV2.Name < policies[j].VdcComputePolicyV2.Name
+	})
+
+	var output DatasourceOutput
+	var names []string
+	for _, p := range policies {
+		output.Policies = append(output.Policies, toSizingPolicy(p))
+		names = append(names, p.VdcComputePolicyV2.Name)
+	}
+
+	if d.config.Name != "" {
+		policy, err := driver.GetVMSizingPolicyByName(policies, d.config.Name)
+		if errors.Is(err, driver.ErrPolicyNotFound) {
+			return cty.NullVal(cty.EmptyObject), fmt.Errorf("VM sizing policy '%s' not found in VDC %s (available: %s)",
+				d.config.Name, d.config.VDC, strings.Join(names, ", "))
+		}
+		if err != nil {
+			return cty.NullVal(cty.EmptyObject), err
+		}
+
+		sp := toSizingPolicy(policy)
+		output.ID = sp.ID
+		output.Name = sp.Name
+		output.Description = sp.Description
+		output.CPUCount = sp.CPUCount
+		output.CoresPerSocket = sp.CoresPerSocket
+		output.MemoryMB = sp.MemoryMB
+	}
+
+	return hcl2helper.HCL2ValueFromConfig(output, d.OutputSpec()), nil
+}
+
+func toSizingPolicy(p *govcd.VdcComputePolicyV2) SizingPolicy {
+	policy := p.VdcComputePolicyV2
+	sp := SizingPolicy{
+		ID:   policy.ID,
+		Name: policy.Name,
+	}
+	if policy.Description != nil {
+		sp.Description = *policy.Description
+	}
+	if policy.CPUCount != nil {
+		sp.CPUCount = *policy.CPUCount
+	}
+	if policy.CoresPerSocket != nil {
+		sp.CoresPerSocket = *policy.CoresPerSocket
+	}
+	if policy.Memory != nil {
+		sp.MemoryMB = *policy.Memory
+	}
+	return sp
+}
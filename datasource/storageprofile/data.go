@@ -0,0 +1,154 @@
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,DatasourceOutput,StorageProfile
+
+package storageprofile
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	vcdcommon "github.com/juanfont/packer-plugin-vcd/builder/vcd/common"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+	"github.com/zclconf/go-cty/cty"
+)
+
+type Config struct {
+	vcdcommon.ConnectConfig `mapstructure:",squash"`
+
+	// The VDC whose storage profiles are listed.
+	VDC string `mapstructure:"vdc" required:"true"`
+}
+
+// StorageProfile describes a storage profile of the VDC.
+type StorageProfile struct {
+	// The name of the storage profile.
+	Name string `mapstructure:"name"`
+	// The storage limit in MB, or `0` if the profile is unlimited.
+	LimitMB int `mapstructure:"limit_mb"`
+	// The storage used in MB.
+	UsedMB int `mapstructure:"used_mb"`
+	// Whether this is the default storage profile of the VDC.
+	Default bool `mapstructure:"default"`
+	// Whether the storage profile is enabled.
+	Enabled bool `mapstructure:"enabled"`
+}
+
+type DatasourceOutput struct {
+	// The name of the default storage profile of the VDC.
+	Default string `mapstructure:"default"`
+	// The name of the enabled storage profile with the lowest usage relative
+	// to its limit. Unlimited profiles count as empty; ties go to the profile
+	// using the least storage.
+	LeastUsed string `mapstructure:"least_used"`
+	// All storage profiles of the VDC, sorted by name.
+	StorageProfiles []StorageProfile `mapstructure:"storage_profiles"`
+}
+
+// Datasource lists the storage profiles of a VDC, so templates can pick the
+// default or least used profile instead of hardcoding `storage_profile`.
+type Datasource struct {
+	config Config
+}
+
+func (d *Datasource) ConfigSpec() hcldec.ObjectSpec {
+	return d.config.FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Configure(raws ...interface{}) error {
+	err := config.Decode(&d.config, nil, raws...)
+	if err != nil {
+		return err
+	}
+
+	errs := new(packersdk.MultiError)
+	errs = packersdk.MultiErrorAppend(errs, d.config.ConnectConfig.Prepare()...)
+
+	if d.config.VDC == "" {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'vdc' is required"))
+	}
+
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (d *Datasource) OutputSpec() hcldec.ObjectSpec {
+	return (&DatasourceOutput{}).FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Execute() (cty.Value, error) {
+	drv, err := d.config.ConnectConfig.Connect()
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), err
+	}
+	defer func() {
+		if err := drv.Cleanup(); err != nil {
+			log.Printf("[WARN] Failed to close VCD client session: %s", err)
+		}
+	}()
+
+	vdc, err := drv.GetVdc(d.config.VDC)
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), err
+	}
+
+	profiles, err := drv.ListStorageProfiles(vdc)
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), err
+	}
+	if len(profiles) == 0 {
+		return cty.NullVal(cty.EmptyObject), fmt.Errorf("VDC %s has no storage profiles", d.config.VDC)
+	}
+
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+
+	var output DatasourceOutput
+	var leastUsed *driver.StorageProfileInfo
+	for _, p := range profiles {
+		output.StorageProfiles = append(output.StorageProfiles, StorageProfile{
+			Name:    p.Name,
+			LimitMB: int(p.LimitMB),
+			UsedMB:  int(p.UsedMB),
+			Default: p.Default,
+			Enabled: p.Enabled,
+		})
+		if p.Default {
+			output.Default = p.Name
+		}
+		if p.Enabled && (leastUsed == nil || lessUsed(p, leastUsed)) {
+			leastUsed = p
+		}
+	}
+	if leastUsed != nil {
+		output.LeastUsed = leastUsed.Name
+	}
+
+	return hcl2helper.HCL2ValueFromConfig(output, d.OutputSpec()), nil
+}
+
+// lessUsed reports whether a is less used than b relative to their limits.
+func lessUsed(a, b *driver.StorageProfileInfo) bool {
+	ua, ub := usage(a), usage(b)
+	if ua != ub {
+		return ua < ub
+	}
+	return a.UsedMB < b.UsedMB
+}
+
+// usage returns the used fraction of a storage profile, or 0 if the profile
+// has no limit.
+func usage(p *driver.StorageProfileInfo) float64 {
+	if p.LimitMB <= 0 {
+		return 0
+	}
+	return float64(p.UsedMB) / float64(p.LimitMB)
+}
@@ -0,0 +1,130 @@
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,DatasourceOutput
+
+package vapptemplate
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	vcdcommon "github.com/juanfont/packer-plugin-vcd/builder/vcd/common"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+	"github.com/zclconf/go-cty/cty"
+)
+
+type Config struct {
+	vcdcommon.ConnectConfig `mapstructure:",squash"`
+
+	// The name of the vApp template. `*` matches any sequence of characters,
+	// e.g. `ubuntu-22.04-*`. When several templates match, the newest one is
+	// returned.
+	Name string `mapstructure:"name" required:"true"`
+	// The catalog to search. If empty, every catalog of the organization is
+	// searched.
+	Catalog string `mapstructure:"catalog"`
+}
+
+type DatasourceOutput struct {
+	// The name of the newest matching vApp template.
+	Name string `mapstructure:"name"`
+	// The URN of the vApp template.
+	ID string `mapstructure:"id"`
+	// The catalog holding the vApp template.
+	Catalog string `mapstructure:"catalog"`
+	// The description of the vApp template.
+	Description string `mapstructure:"description"`
+	// The creation date of the vApp template, in RFC 3339 format.
+	CreationDate string `mapstructure:"creation_date"`
+	// The storage allocated to the vApp template, in bytes.
+	SizeBytes int64 `mapstructure:"size_bytes"`
+}
+
+// Datasource finds the newest vApp template whose name matches a pattern, so
+// clone builds can be based on the latest image without manual bookkeeping.
+type Datasource struct {
+	config Config
+}
+
+func (d *Datasource) ConfigSpec() hcldec.ObjectSpec {
+	return d.config.FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Configure(raws ...interface{}) error {
+	err := config.Decode(&d.config, nil, raws...)
+	if err != nil {
+		return err
+	}
+
+	errs := new(packersdk.MultiError)
+	errs = packersdk.MultiErrorAppend(errs, d.config.ConnectConfig.Prepare()...)
+
+	if d.config.Name == "" {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'name' is required"))
+	}
+
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (d *Datasource) OutputSpec() hcldec.ObjectSpec {
+	return (&DatasourceOutput{}).FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Execute() (cty.Value, error) {
+	drv, err := d.config.ConnectConfig.Connect()
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), err
+	}
+	defer func() {
+		if err := drv.Cleanup(); err != nil {
+			log.Printf("[WARN] Failed to close VCD client session: %s", err)
+		}
+	}()
+
+	templates, err := drv.ListVAppTemplates(d.config.Catalog, driver.VAppTemplateFilter{
+		Name: d.config.Name,
+	})
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), err
+	}
+
+	// Templates are sorted newest first; skip those still being captured or
+	// uploaded, which cannot be cloned yet
+	var latest *driver.VAppTemplateInfo
+	for _, t := range templates {
+		if t.Status == "UNRESOLVED" || t.Status == "FAILED_CREATION" {
+			log.Printf("[DEBUG] Skipping vApp template %s in catalog %s with status %s", t.Name, t.Catalog, t.Status)
+			continue
+		}
+		latest = t
+		break
+	}
+	if latest == nil {
+		where := "any catalog"
+		if d.config.Catalog != "" {
+			where = fmt.Sprintf("catalog %s", d.config.Catalog)
+		}
+		return cty.NullVal(cty.EmptyObject), fmt.Errorf("no vApp template matching '%s' found in %s", d.config.Name, where)
+	}
+
+	output := DatasourceOutput{
+		Name:        latest.Name,
+		ID:          latest.ID,
+		Catalog:     latest.Catalog,
+		Description: latest.Description,
+		SizeBytes:   latest.SizeBytes,
+	}
+	if !latest.Created.IsZero() {
+		output.CreationDate = latest.Created.UTC().Format(time.RFC3339)
+	}
+
+	return hcl2helper.HCL2ValueFromConfig(output, d.OutputSpec()), nil
+}
@@ -7,15 +7,42 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/clone"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/existing"
 	"github.com/juanfont/packer-plugin-vcd/builder/vcd/iso"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/ovf"
+	"github.com/juanfont/packer-plugin-vcd/datasource/network"
+	"github.com/juanfont/packer-plugin-vcd/datasource/placementpolicy"
+	"github.com/juanfont/packer-plugin-vcd/datasource/sizingpolicy"
+	"github.com/juanfont/packer-plugin-vcd/datasource/storageprofile"
+	"github.com/juanfont/packer-plugin-vcd/datasource/vapptemplate"
+	"github.com/juanfont/packer-plugin-vcd/post-processor/metadata"
+	"github.com/juanfont/packer-plugin-vcd/post-processor/share"
+	"github.com/juanfont/packer-plugin-vcd/post-processor/smoketest"
+	vcdpp "github.com/juanfont/packer-plugin-vcd/post-processor/vcd"
 	"github.com/juanfont/packer-plugin-vcd/version"
 
 	"github.com/hashicorp/packer-plugin-sdk/plugin"
 )
 
 func main() {
+	// Packer prefixes component names with the plugin name, so "iso" is used as
+	// vcd-iso and DEFAULT_NAME as vcd. Keep these in sync with the component
+	// slugs in .web-docs/metadata.hcl.
 	pps := plugin.NewSet()
 	pps.RegisterBuilder("iso", new(iso.Builder))
+	pps.RegisterBuilder("clone", new(clone.Builder))
+	pps.RegisterBuilder("ovf", new(ovf.Builder))
+	pps.RegisterBuilder("existing", new(existing.Builder))
+	pps.RegisterPostProcessor(plugin.DEFAULT_NAME, new(vcdpp.PostProcessor))
+	pps.RegisterPostProcessor("smoke-test", new(smoketest.PostProcessor))
+	pps.RegisterPostProcessor("share", new(share.PostProcessor))
+	pps.RegisterPostProcessor("metadata", new(metadata.PostProcessor))
+	pps.RegisterDatasource("network", new(network.Datasource))
+	pps.RegisterDatasource("storage-profile", new(storageprofile.Datasource))
+	pps.RegisterDatasource("sizing-policy", new(sizingpolicy.Datasource))
+	pps.RegisterDatasource("placement-policy", new(placementpolicy.Datasource))
+	pps.RegisterDatasource("vapp-template", new(vapptemplate.Datasource))
 	pps.SetVersion(version.PluginVersion)
 	err := pps.Run()
 	if err != nil {
@@ -0,0 +1,164 @@
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	vcdcommon "github.com/juanfont/packer-plugin-vcd/builder/vcd/common"
+)
+
+// Prefix of the provenance metadata keys written by the post-processor, the
+// same as the builders use when capturing a template.
+const keyPrefix = vcdcommon.ProvenanceKeyPrefix
+
+type Config struct {
+	common.PackerConfig     `mapstructure:",squash"`
+	vcdcommon.ConnectConfig `mapstructure:",squash"`
+
+	// The catalog holding the vApp template. Defaults to the catalog the
+	// template was captured or uploaded to by the previous builder or
+	// post-processor.
+	Catalog string `mapstructure:"catalog"`
+	// The name of the vApp template. Defaults to the template captured or
+	// uploaded by the previous builder or post-processor.
+	TemplateName string `mapstructure:"template_name"`
+	// Additional metadata entries to write onto the vApp template. Entries
+	// with the same key as a provenance entry take precedence.
+	Metadata map[string]string `mapstructure:"metadata"`
+	// The git commit of the template sources, written as `packer.git_sha`.
+	// Defaults to the output of `git rev-parse HEAD` in the current
+	// directory, and is omitted if that fails.
+	GitSHA string `mapstructure:"git_sha"`
+
+	ctx interpolate.Context
+}
+
+// PostProcessor writes provenance metadata (build date, Packer version,
+// source ISO checksum, git commit) onto a vApp template, so downstream
+// tooling can tell how an image was built.
+type PostProcessor struct {
+	config Config
+	runner multistep.Runner
+}
+
+func (p *PostProcessor) ConfigSpec() hcldec.ObjectSpec { return p.config.FlatMapstructure().HCL2Spec() }
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		PluginType:         vcdcommon.BuilderId,
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	errs := new(packersdk.MultiError)
+	errs = packersdk.MultiErrorAppend(errs, p.config.ConnectConfig.Prepare()...)
+
+	for key := range p.config.Metadata {
+		if strings.TrimSpace(key) == "" {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'metadata' keys must not be empty"))
+			break
+		}
+	}
+
+	if p.config.GitSHA == "" {
+		p.config.GitSHA = gitHead()
+	}
+
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifact packersdk.Artifact) (packersdk.Artifact, bool, bool, error) {
+	catalog := p.config.Catalog
+	if catalog == "" {
+		catalog, _ = artifact.State("vapp_template_catalog").(string)
+	}
+	template := p.config.TemplateName
+	if template == "" {
+		template, _ = artifact.State("vapp_template").(string)
+	}
+	if catalog == "" || template == "" {
+		return nil, false, false, fmt.Errorf("artifact from %s has no vApp template; set 'catalog' and 'template_name'", artifact.BuilderId())
+	}
+
+	state := new(multistep.BasicStateBag)
+	state.Put("debug", p.config.PackerDebug)
+	state.Put("ui", ui)
+
+	steps := []multistep.Step{
+		&vcdcommon.StepConnect{
+			Config: &p.config.ConnectConfig,
+		},
+		&StepWriteMetadata{
+			Catalog:      catalog,
+			TemplateName: template,
+			Metadata:     p.metadata(artifact),
+		},
+	}
+
+	p.runner = commonsteps.NewRunnerWithPauseFn(steps, p.config.PackerConfig, ui, state)
+	p.runner.Run(p.config.ConnectConfig.TaskContext(ctx, ui), state)
+
+	if err := vcdcommon.StateError(state); err != nil {
+		return nil, false, false, err
+	}
+
+	// The template itself is unchanged; pass the artifact through
+	return artifact, true, false, nil
+}
+
+// metadata returns the provenance entries merged with the user entries.
+func (p *PostProcessor) metadata(artifact packersdk.Artifact) map[string]string {
+	entries := map[string]string{
+		keyPrefix + "build_date": time.Now().UTC().Format(time.RFC3339),
+		keyPrefix + "builder_id": artifact.BuilderId(),
+	}
+	if p.config.PackerCoreVersion != "" {
+		entries[keyPrefix+"version"] = p.config.PackerCoreVersion
+	}
+	if p.config.PackerBuildName != "" {
+		entries[keyPrefix+"build_name"] = p.config.PackerBuildName
+	}
+	if checksum, ok := artifact.State("source_iso_checksum").(string); ok && checksum != "" {
+		entries[keyPrefix+"source_iso_checksum"] = checksum
+	}
+	if p.config.GitSHA != "" {
+		entries[keyPrefix+"git_sha"] = p.config.GitSHA
+	}
+
+	for key, value := range p.config.Metadata {
+		entries[key] = value
+	}
+	return entries
+}
+
+// gitHead returns the commit checked out in the current directory, or an
+// empty string when it is not a git checkout or git is not installed.
+func gitHead() string {
+	out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		log.Printf("[DEBUG] Could not determine git commit: %v", err)
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
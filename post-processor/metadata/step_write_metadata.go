@@ -0,0 +1,61 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+	"github.com/vmware/go-vcloud-director/v3/types/v56"
+)
+
+// StepWriteMetadata merges metadata entries into those already present on a
+// vApp template.
+type StepWriteMetadata struct {
+	Catalog      string
+	TemplateName string
+	Metadata     map[string]string
+}
+
+func (s *StepWriteMetadata) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	d := state.Get("driver").(driver.Driver)
+
+	catalog, err := d.GetCatalog(s.Catalog)
+	if err != nil {
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
+
+	template, err := catalog.GetVAppTemplateByName(s.TemplateName)
+	if err != nil {
+		state.Put("error", fmt.Errorf("error getting vApp template %s: %w", s.TemplateName, err))
+		return multistep.ActionHalt
+	}
+
+	ui.Sayf("Writing metadata to vApp template %s...", s.TemplateName)
+
+	keys := make([]string, 0, len(s.Metadata))
+	entries := make(map[string]interface{}, len(s.Metadata))
+	for key, value := range s.Metadata {
+		keys = append(keys, key)
+		entries[key] = value
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		ui.Message(fmt.Sprintf("%s = %s", key, s.Metadata[key]))
+	}
+
+	if err := template.MergeMetadata(types.MetadataStringValue, entries); err != nil {
+		state.Put("error", fmt.Errorf("error writing metadata to vApp template %s: %w", s.TemplateName, err))
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepWriteMetadata) Cleanup(state multistep.StateBag) {
+	// Metadata written before a failure is left in place
+}
@@ -0,0 +1,161 @@
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,CopyTarget
+
+package share
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	vcdcommon "github.com/juanfont/packer-plugin-vcd/builder/vcd/common"
+	"github.com/vmware/go-vcloud-director/v3/types/v56"
+)
+
+// CopyTarget is a catalog, possibly in another organization, that receives a
+// copy of the vApp template.
+type CopyTarget struct {
+	// The organization owning the target catalog.
+	Org string `mapstructure:"org" required:"true"`
+	// The name of the target catalog.
+	Catalog string `mapstructure:"catalog" required:"true"`
+	// The name of the copied vApp template. Defaults to the name of the
+	// source template.
+	TemplateName string `mapstructure:"template_name"`
+	// Description for the copied vApp template.
+	Description string `mapstructure:"description"`
+}
+
+type Config struct {
+	common.PackerConfig     `mapstructure:",squash"`
+	vcdcommon.ConnectConfig `mapstructure:",squash"`
+
+	// The catalog holding the vApp template to distribute. Defaults to the
+	// catalog the template was captured or uploaded to by the previous
+	// builder or post-processor.
+	Catalog string `mapstructure:"catalog"`
+	// The name of the vApp template to distribute. Defaults to the template
+	// captured or uploaded by the previous builder or post-processor. Only
+	// used by `copy_to`; sharing and publishing apply to the whole catalog.
+	TemplateName string `mapstructure:"template_name"`
+	// Organizations to share the catalog with.
+	ShareWithOrgs []string `mapstructure:"share_with_orgs"`
+	// The access level granted to `share_with_orgs`. Valid values are:
+	// ReadOnly, Change, FullControl. Defaults to `ReadOnly`.
+	ShareAccessLevel string `mapstructure:"share_access_level"`
+	// If true, publish the catalog read-only to all organizations.
+	// Defaults to `false`.
+	PublishToAllOrgs bool `mapstructure:"publish_to_all_orgs"`
+	// Catalogs to copy the vApp template to. Each copy is independent of
+	// the source template.
+	CopyTo []CopyTarget `mapstructure:"copy_to"`
+
+	ctx interpolate.Context
+}
+
+// PostProcessor makes a captured vApp template available to other
+// organizations by sharing or publishing its catalog, or by copying the
+// template into catalogs of other organizations. Looking up other
+// organizations requires a provider (System) session.
+type PostProcessor struct {
+	config Config
+	runner multistep.Runner
+}
+
+func (p *PostProcessor) ConfigSpec() hcldec.ObjectSpec { return p.config.FlatMapstructure().HCL2Spec() }
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		PluginType:         vcdcommon.BuilderId,
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	errs := new(packersdk.MultiError)
+	errs = packersdk.MultiErrorAppend(errs, p.config.ConnectConfig.Prepare()...)
+
+	if len(p.config.ShareWithOrgs) == 0 && !p.config.PublishToAllOrgs && len(p.config.CopyTo) == 0 {
+		errs = packersdk.MultiErrorAppend(errs,
+			fmt.Errorf("at least one of 'share_with_orgs', 'publish_to_all_orgs' or 'copy_to' is required"))
+	}
+
+	if p.config.ShareAccessLevel == "" {
+		p.config.ShareAccessLevel = types.ControlAccessReadOnly
+	}
+	switch p.config.ShareAccessLevel {
+	case types.ControlAccessReadOnly, types.ControlAccessReadWrite, types.ControlAccessFullControl:
+	default:
+		errs = packersdk.MultiErrorAppend(errs,
+			fmt.Errorf("'share_access_level' must be one of: ReadOnly, Change, FullControl"))
+	}
+
+	for i, target := range p.config.CopyTo {
+		if target.Org == "" {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'org' is required for copy_to[%d]", i))
+		}
+		if target.Catalog == "" {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'catalog' is required for copy_to[%d]", i))
+		}
+	}
+
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifact packersdk.Artifact) (packersdk.Artifact, bool, bool, error) {
+	catalog := p.config.Catalog
+	if catalog == "" {
+		catalog, _ = artifact.State("vapp_template_catalog").(string)
+	}
+	template := p.config.TemplateName
+	if template == "" {
+		template, _ = artifact.State("vapp_template").(string)
+	}
+	if catalog == "" {
+		return nil, false, false, fmt.Errorf("artifact from %s has no vApp template; set 'catalog'", artifact.BuilderId())
+	}
+	if len(p.config.CopyTo) > 0 && template == "" {
+		return nil, false, false, fmt.Errorf("artifact from %s has no vApp template; set 'template_name' to use copy_to", artifact.BuilderId())
+	}
+
+	state := new(multistep.BasicStateBag)
+	state.Put("debug", p.config.PackerDebug)
+	state.Put("ui", ui)
+
+	steps := []multistep.Step{
+		&vcdcommon.StepConnect{
+			Config: &p.config.ConnectConfig,
+		},
+		&StepShareTemplate{
+			Catalog:          catalog,
+			TemplateName:     template,
+			ShareWithOrgs:    p.config.ShareWithOrgs,
+			ShareAccessLevel: p.config.ShareAccessLevel,
+			PublishToAllOrgs: p.config.PublishToAllOrgs,
+			CopyTo:           p.config.CopyTo,
+		},
+	}
+
+	p.runner = commonsteps.NewRunnerWithPauseFn(steps, p.config.PackerConfig, ui, state)
+	p.runner.Run(p.config.ConnectConfig.TaskContext(ctx, ui), state)
+
+	if err := vcdcommon.StateError(state); err != nil {
+		return nil, false, false, err
+	}
+
+	// Sharing does not produce a new artifact; pass the template through so
+	// later post-processors still see it.
+	return artifact, true, false, nil
+}
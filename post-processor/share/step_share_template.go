@@ -0,0 +1,76 @@
+package share
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+)
+
+// StepShareTemplate shares or publishes the catalog holding the vApp template
+// and copies the template to other catalogs.
+type StepShareTemplate struct {
+	Catalog          string
+	TemplateName     string
+	ShareWithOrgs    []string
+	ShareAccessLevel string
+	PublishToAllOrgs bool
+	CopyTo           []CopyTarget
+}
+
+func (s *StepShareTemplate) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	d := state.Get("driver").(driver.Driver)
+
+	catalog, err := d.GetCatalog(s.Catalog)
+	if err != nil {
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
+
+	if s.PublishToAllOrgs {
+		ui.Sayf("Publishing catalog %s to all organizations...", s.Catalog)
+		if err := d.PublishCatalog(catalog); err != nil {
+			state.Put("error", err)
+			return multistep.ActionHalt
+		}
+	}
+
+	if len(s.ShareWithOrgs) > 0 {
+		ui.Sayf("Sharing catalog %s (%s) with: %s", s.Catalog, s.ShareAccessLevel, strings.Join(s.ShareWithOrgs, ", "))
+		if err := d.ShareCatalog(catalog, s.ShareWithOrgs, s.ShareAccessLevel); err != nil {
+			state.Put("error", err)
+			return multistep.ActionHalt
+		}
+	}
+
+	for _, target := range s.CopyTo {
+		name := target.TemplateName
+		if name == "" {
+			name = s.TemplateName
+		}
+
+		targetCatalog, err := d.GetOrgCatalog(target.Org, target.Catalog)
+		if err != nil {
+			state.Put("error", err)
+			return multistep.ActionHalt
+		}
+
+		ui.Sayf("Copying vApp template %s to %s/%s as %s...", s.TemplateName, target.Org, target.Catalog, name)
+		if err := d.CopyVAppTemplate(ctx, catalog, s.TemplateName, targetCatalog, name, target.Description); err != nil {
+			state.Put("error", fmt.Errorf("error copying vApp template to org %s: %w", target.Org, err))
+			return multistep.ActionHalt
+		}
+	}
+
+	ui.Sayf("vApp template %s distributed", s.TemplateName)
+
+	return multistep.ActionContinue
+}
+
+func (s *StepShareTemplate) Cleanup(state multistep.StateBag) {
+	// Shares and copies are intentional and never rolled back
+}
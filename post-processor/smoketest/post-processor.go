@@ -0,0 +1,199 @@
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package smoketest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	vcdcommon "github.com/juanfont/packer-plugin-vcd/builder/vcd/common"
+)
+
+type Config struct {
+	common.PackerConfig     `mapstructure:",squash"`
+	vcdcommon.ConnectConfig `mapstructure:",squash"`
+
+	// The VDC to instantiate the template in.
+	VDC string `mapstructure:"vdc" required:"true"`
+	// The catalog holding the vApp template to test. Defaults to the catalog
+	// the template was captured or uploaded to by the previous builder or
+	// post-processor.
+	Catalog string `mapstructure:"catalog"`
+	// The name of the vApp template to test. Defaults to the template
+	// captured or uploaded by the previous builder or post-processor.
+	Template string `mapstructure:"template"`
+	// The network to attach the test virtual machine to. If not set, the
+	// network adapters of the template are kept as-is.
+	Network string `mapstructure:"network"`
+	// The IP allocation mode for the network connection. Valid values are:
+	// POOL, DHCP, MANUAL, NONE. Defaults to POOL.
+	IPAllocationMode string `mapstructure:"ip_allocation_mode"`
+	// The storage profile to use for the test virtual machine. If not
+	// specified, the default storage profile for the VDC will be used.
+	StorageProfile string `mapstructure:"storage_profile"`
+
+	vcdcommon.WaitIpConfig `mapstructure:",squash"`
+	Comm                   communicator.Config `mapstructure:",squash"`
+
+	// A command to run on the test virtual machine through the communicator
+	// once it has an IP address. The smoke test fails if the command exits
+	// with a non-zero status. If not set, the test only checks that the
+	// virtual machine boots and gets an IP address, and no communicator is
+	// used.
+	HealthCheckCommand string `mapstructure:"health_check_command"`
+
+	ctx interpolate.Context
+}
+
+// PostProcessor instantiates a freshly captured vApp template into a
+// temporary vApp, checks that it boots (and optionally that a command
+// succeeds on it), then destroys the test vApp.
+type PostProcessor struct {
+	config Config
+	runner multistep.Runner
+}
+
+func (p *PostProcessor) ConfigSpec() hcldec.ObjectSpec { return p.config.FlatMapstructure().HCL2Spec() }
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		PluginType:         vcdcommon.BuilderId,
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	errs := new(packersdk.MultiError)
+	errs = packersdk.MultiErrorAppend(errs, p.config.ConnectConfig.Prepare()...)
+	errs = packersdk.MultiErrorAppend(errs, p.config.WaitIpConfig.Prepare()...)
+
+	if p.config.VDC == "" {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'vdc' is required"))
+	}
+
+	if p.config.IPAllocationMode == "" {
+		p.config.IPAllocationMode = "POOL"
+	}
+
+	// Without a health check there is nothing to connect for
+	if p.config.HealthCheckCommand == "" {
+		p.config.Comm.Type = "none"
+	}
+	errs = packersdk.MultiErrorAppend(errs, p.config.Comm.Prepare(&p.config.ctx)...)
+
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifact packersdk.Artifact) (packersdk.Artifact, bool, bool, error) {
+	catalog := p.config.Catalog
+	if catalog == "" {
+		catalog, _ = artifact.State("vapp_template_catalog").(string)
+	}
+	template := p.config.Template
+	if template == "" {
+		template, _ = artifact.State("vapp_template").(string)
+	}
+	if catalog == "" || template == "" {
+		return nil, false, false, fmt.Errorf("artifact from %s has no vApp template; set 'catalog' and 'template'", artifact.BuilderId())
+	}
+
+	ui.Sayf("Smoke testing vApp template %s from catalog %s", template, catalog)
+
+	suffix := time.Now().UnixNano()
+	vappName := fmt.Sprintf("packer-smoke-test-%d", suffix)
+	vmName := fmt.Sprintf("packer-smoke-test-%d", suffix)
+
+	state := new(multistep.BasicStateBag)
+	state.Put("debug", p.config.PackerDebug)
+	state.Put("ui", ui)
+
+	var networks []string
+	if p.config.Network != "" {
+		networks = []string{p.config.Network}
+	}
+
+	steps := []multistep.Step{
+		// Step 1: Connect to VCD
+		&vcdcommon.StepConnect{
+			Config: &p.config.ConnectConfig,
+		},
+
+		// Step 2: Create the throwaway test vApp
+		&vcdcommon.StepResolveVApp{
+			VDCName:         p.config.VDC,
+			VAppName:        vappName,
+			Networks:        networks,
+			CreateVApp:      true,
+			DeleteOnSuccess: true,
+		},
+
+		// Step 3: Instantiate the template into the test vApp
+		&vcdcommon.StepCloneVM{
+			TemplateCatalog:  catalog,
+			Template:         template,
+			Description:      "Packer smoke test",
+			VMName:           vmName,
+			StorageProfile:   p.config.StorageProfile,
+			Network:          p.config.Network,
+			IPAllocationMode: p.config.IPAllocationMode,
+		},
+
+		// Step 4: Power on the test VM
+		&vcdcommon.StepRun{
+			Config:      &vcdcommon.RunConfig{},
+			VDCName:     p.config.VDC,
+			NetworkName: p.config.Network,
+		},
+
+		// Step 5: Wait for guest tools to report an IP address
+		&vcdcommon.StepWaitForIP{
+			Config: &p.config.WaitIpConfig,
+		},
+
+		// Step 6: Probe SSH/WinRM port (reports firewall vs service issues)
+		&vcdcommon.StepProbeCommunicator{
+			Config: &p.config.Comm,
+		},
+
+		// Step 7: Connect to the test VM (only with a health check)
+		&communicator.StepConnect{
+			Config:    &p.config.Comm,
+			Host:      vcdcommon.CommHost(p.config.Comm.Host()),
+			SSHConfig: p.config.Comm.SSHConfigFunc(),
+		},
+
+		// Step 8: Run the health check command (optional)
+		&StepHealthCheck{
+			Command: p.config.HealthCheckCommand,
+		},
+	}
+
+	p.runner = commonsteps.NewRunnerWithPauseFn(steps, p.config.PackerConfig, ui, state)
+	p.runner.Run(p.config.ConnectConfig.TaskContext(ctx, ui), state)
+
+	if err := vcdcommon.StateError(state); err != nil {
+		return nil, false, false, fmt.Errorf("smoke test of vApp template %s failed: %w", template, err)
+	}
+
+	ui.Sayf("Smoke test of vApp template %s passed", template)
+
+	// Pass the tested artifact through untouched; destroying it here would
+	// delete what was just validated.
+	return artifact, true, true, nil
+}
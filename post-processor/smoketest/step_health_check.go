@@ -0,0 +1,44 @@
+package smoketest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// StepHealthCheck runs a command on the test virtual machine and fails if
+// it exits with a non-zero status.
+type StepHealthCheck struct {
+	Command string
+}
+
+func (s *StepHealthCheck) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Command == "" {
+		return multistep.ActionContinue
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	comm := state.Get("communicator").(packersdk.Communicator)
+
+	ui.Sayf("Running health check: %s", s.Command)
+
+	cmd := &packersdk.RemoteCmd{Command: s.Command}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		state.Put("error", fmt.Errorf("error running health check: %w", err))
+		return multistep.ActionHalt
+	}
+
+	if cmd.ExitStatus() != 0 {
+		state.Put("error", fmt.Errorf("health check exited with status %d", cmd.ExitStatus()))
+		return multistep.ActionHalt
+	}
+
+	ui.Say("Health check passed")
+	return multistep.ActionContinue
+}
+
+func (s *StepHealthCheck) Cleanup(state multistep.StateBag) {
+	// Nothing to clean up
+}
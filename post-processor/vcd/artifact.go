@@ -0,0 +1,44 @@
+package vcd
+
+import (
+	"fmt"
+)
+
+const BuilderId = "vcd.post-processor"
+
+// Artifact is a vApp template uploaded to a catalog by the post-processor.
+type Artifact struct {
+	Catalog string
+	Name    string
+	Source  string
+}
+
+func (a *Artifact) BuilderId() string {
+	return BuilderId
+}
+
+func (a *Artifact) Files() []string {
+	return nil
+}
+
+func (a *Artifact) Id() string {
+	return fmt.Sprintf("%s/%s", a.Catalog, a.Name)
+}
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("VCD vApp template: %s in catalog %s (uploaded from %s)", a.Name, a.Catalog, a.Source)
+}
+
+func (a *Artifact) State(name string) interface{} {
+	switch name {
+	case "vapp_template_catalog":
+		return a.Catalog
+	case "vapp_template":
+		return a.Name
+	}
+	return nil
+}
+
+func (a *Artifact) Destroy() error {
+	return nil
+}
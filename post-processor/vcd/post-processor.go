@@ -1,20 +1,139 @@
+//go:generate packer-sdc struct-markdown
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
 package vcd
 
-import "github.com/hashicorp/packer-plugin-sdk/common"
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	vcdcommon "github.com/juanfont/packer-plugin-vcd/builder/vcd/common"
+)
 
 type Config struct {
-	common.PackerConfig `mapstructure:",squash"`
-	// The fully qualified domain name or IP address of the vCloud Director endpoint.
-	Host string `mapstructure:"host" required:"true"`
-	// The username to use to authenticate to the vCloud Director endpoint.
-	Username string `mapstructure:"username" required:"true"`
-	// The password to use to authenticate to the vCloud Director endpoint.
-	Password string `mapstructure:"password" required:"true"`
-	// The token to use to authenticate to the vCloud Director endpoint (if not provided, username and password will be used)
-	Token string `mapstructure:"token"`
-	// Skip the verification of the server certificate. Defaults to `false`.
-	Insecure bool `mapstructure:"insecure"`
-	// The name of the virtual datacenter to use.
-	// Required when the vCloud Director instance endpoint has more than one virtual datacenter.
-	VirtualDatacenter string `mapstructure:"virtual_datacenter"`
+	common.PackerConfig     `mapstructure:",squash"`
+	vcdcommon.ConnectConfig `mapstructure:",squash"`
+
+	// The name of the catalog to upload the vApp template to.
+	Catalog string `mapstructure:"catalog" required:"true"`
+	// The name of the vApp template. Defaults to the file name of the OVA or
+	// OVF descriptor without its extension.
+	TemplateName string `mapstructure:"template_name"`
+	// Description for the vApp template.
+	Description string `mapstructure:"description"`
+	// If true, replace an existing vApp template with the same name in the
+	// catalog. The new template is uploaded under a temporary name, and
+	// replaces the existing one once VCD has imported it, so that a failed
+	// upload leaves the existing template in place. Defaults to `false`.
+	Overwrite bool `mapstructure:"overwrite"`
+
+	ctx interpolate.Context
+}
+
+// PostProcessor uploads an OVA/OVF produced by another builder into a VCD
+// catalog as a vApp template.
+type PostProcessor struct {
+	config Config
+	runner multistep.Runner
+}
+
+func (p *PostProcessor) ConfigSpec() hcldec.ObjectSpec { return p.config.FlatMapstructure().HCL2Spec() }
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		PluginType:         BuilderId,
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	errs := new(packersdk.MultiError)
+	errs = packersdk.MultiErrorAppend(errs, p.config.ConnectConfig.Prepare()...)
+
+	if p.config.Catalog == "" {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("'catalog' is required"))
+	}
+
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifact packersdk.Artifact) (packersdk.Artifact, bool, bool, error) {
+	source, err := findOvf(artifact.Files())
+	if err != nil {
+		return nil, false, false, fmt.Errorf("artifact from %s: %w", artifact.BuilderId(), err)
+	}
+
+	templateName := p.config.TemplateName
+	if templateName == "" {
+		base := filepath.Base(source)
+		templateName = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", ui)
+
+	steps := []multistep.Step{
+		&vcdcommon.StepConnect{
+			Config: &p.config.ConnectConfig,
+		},
+		&StepUploadTemplate{
+			Catalog:      p.config.Catalog,
+			TemplateName: templateName,
+			Description:  p.config.Description,
+			Overwrite:    p.config.Overwrite,
+			SourcePath:   source,
+		},
+	}
+
+	p.runner = commonsteps.NewRunnerWithPauseFn(steps, p.config.PackerConfig, ui, state)
+	p.runner.Run(p.config.ConnectConfig.TaskContext(ctx, ui), state)
+
+	if err := vcdcommon.StateError(state); err != nil {
+		return nil, false, false, err
+	}
+
+	result := &Artifact{
+		Catalog: p.config.Catalog,
+		Name:    templateName,
+		Source:  source,
+	}
+
+	// Whether the local OVA/OVF is kept is left to keep_input_artifact
+	return result, false, false, nil
+}
+
+// findOvf returns the OVA, or failing that the OVF descriptor, among the
+// files of an artifact.
+func findOvf(files []string) (string, error) {
+	var ovf string
+	for _, f := range files {
+		switch strings.ToLower(filepath.Ext(f)) {
+		case ".ova":
+			return f, nil
+		case ".ovf":
+			if ovf == "" {
+				ovf = f
+			}
+		}
+	}
+
+	if ovf == "" {
+		return "", fmt.Errorf("no .ova or .ovf file found in artifact")
+	}
+	return ovf, nil
 }
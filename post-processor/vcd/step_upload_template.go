@@ -0,0 +1,140 @@
+package vcd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/juanfont/packer-plugin-vcd/builder/vcd/driver"
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+)
+
+// StepUploadTemplate uploads an OVA/OVF into a catalog as a vApp template,
+// replacing an existing template of the same name when Overwrite is set.
+// The replacement is uploaded under a temporary name, and only swapped in
+// once VCD has imported it, so that a failed upload leaves the existing
+// template in place.
+type StepUploadTemplate struct {
+	Catalog      string
+	TemplateName string
+	Description  string
+	Overwrite    bool
+	SourcePath   string
+
+	catalog    *govcd.Catalog
+	uploadName string // name of the template being uploaded, if any
+}
+
+func (s *StepUploadTemplate) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+	d := state.Get("driver").(driver.Driver)
+
+	catalog, err := d.GetCatalog(s.Catalog)
+	if err != nil {
+		state.Put("error", fmt.Errorf("error getting catalog %s: %w", s.Catalog, err))
+		return multistep.ActionHalt
+	}
+
+	uploadName := s.TemplateName
+	existing, err := catalog.GetVAppTemplateByName(s.TemplateName)
+	if err != nil {
+		existing = nil
+	}
+	if existing != nil {
+		if !s.Overwrite {
+			state.Put("error", fmt.Errorf("vApp template %s already exists in catalog %s (set overwrite = true to replace it)",
+				s.TemplateName, s.Catalog))
+			return multistep.ActionHalt
+		}
+		uploadName = fmt.Sprintf("%s-packer-upload-%d", s.TemplateName, time.Now().Unix())
+	}
+
+	release, err := d.AcquireTransferSlot(ctx, func() {
+		ui.Say("Waiting for a free transfer slot (max_concurrent_transfers)...")
+	})
+	if err != nil {
+		state.Put("error", fmt.Errorf("error waiting for a transfer slot: %w", err))
+		return multistep.ActionHalt
+	}
+	defer release()
+
+	s.catalog = catalog
+	s.uploadName = uploadName
+	ui.Sayf("Uploading %s to catalog %s as %s...", s.SourcePath, s.Catalog, uploadName)
+
+	template, err := d.UploadOvf(ctx, catalog, uploadName, s.Description, s.SourcePath)
+	if err != nil {
+		state.Put("error", fmt.Errorf("error uploading %s: %w", s.SourcePath, err))
+		return multistep.ActionHalt
+	}
+
+	if existing != nil {
+		if err := s.replace(ui, existing, template); err != nil {
+			state.Put("error", err)
+			return multistep.ActionHalt
+		}
+	}
+	s.uploadName = ""
+
+	state.Put("template", template)
+
+	ui.Sayf("vApp template uploaded: %s", s.TemplateName)
+	return multistep.ActionContinue
+}
+
+// replace swaps the uploaded template in for the existing one: the existing
+// template is renamed out of the way, the uploaded one is renamed to the
+// template name, then the existing one is deleted.
+func (s *StepUploadTemplate) replace(ui packersdk.Ui, existing, uploaded *govcd.VAppTemplate) error {
+	replacedName := fmt.Sprintf("%s-packer-replaced-%d", s.TemplateName, time.Now().Unix())
+	ui.Sayf("Renaming existing vApp template %s to %s...", s.TemplateName, replacedName)
+	existing.VAppTemplate.Name = replacedName
+	if _, err := existing.Update(); err != nil {
+		return fmt.Errorf("error renaming existing vApp template %s: %w", s.TemplateName, err)
+	}
+
+	ui.Sayf("Renaming uploaded vApp template %s to %s...", uploaded.VAppTemplate.Name, s.TemplateName)
+	uploaded.VAppTemplate.Name = s.TemplateName
+	if _, err := uploaded.Update(); err != nil {
+		// Put the existing template back; the upload is deleted by Cleanup
+		existing.VAppTemplate.Name = s.TemplateName
+		if _, rerr := existing.Update(); rerr != nil {
+			ui.Errorf("Error renaming vApp template %s back to %s: %s", replacedName, s.TemplateName, rerr)
+		}
+		return fmt.Errorf("error renaming uploaded vApp template to %s: %w", s.TemplateName, err)
+	}
+
+	ui.Sayf("Deleting replaced vApp template: %s", replacedName)
+	if err := existing.Delete(); err != nil {
+		ui.Errorf("Warning: failed to delete replaced vApp template %s: %s", replacedName, err)
+	}
+	return nil
+}
+
+func (s *StepUploadTemplate) Cleanup(state multistep.StateBag) {
+	if s.uploadName == "" {
+		return
+	}
+
+	// Only clean up on failure
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	if !cancelled && !halted {
+		return
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+
+	// Remove whatever the failed upload left behind in the catalog
+	template, err := s.catalog.GetVAppTemplateByName(s.uploadName)
+	if err != nil {
+		return
+	}
+
+	ui.Sayf("Deleting incomplete vApp template: %s", s.uploadName)
+	if err := template.Delete(); err != nil {
+		ui.Errorf("Error deleting incomplete vApp template: %s", err)
+	}
+}